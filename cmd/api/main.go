@@ -7,8 +7,14 @@ import (
 	"github.com/oggyb/insider-assessment/internal/cache/redis"
 	"github.com/oggyb/insider-assessment/internal/config"
 	"github.com/oggyb/insider-assessment/internal/db/gormdb"
+	domain "github.com/oggyb/insider-assessment/internal/domain/message"
+	"github.com/oggyb/insider-assessment/internal/event"
 	"github.com/oggyb/insider-assessment/internal/handler"
+	"github.com/oggyb/insider-assessment/internal/metrics"
+	"github.com/oggyb/insider-assessment/internal/middleware"
+	"github.com/oggyb/insider-assessment/internal/reporter"
 	mesgRepo "github.com/oggyb/insider-assessment/internal/repository/gorm/message"
+	"github.com/oggyb/insider-assessment/internal/response"
 	routes "github.com/oggyb/insider-assessment/internal/router"
 	"github.com/oggyb/insider-assessment/internal/scheduler"
 	"github.com/oggyb/insider-assessment/internal/server"
@@ -28,30 +34,181 @@ func main() {
 
 	// Load configuration from environment/.env.
 	cfg := config.New()
+	clampPerMessageTimeout(cfg)
+
+	// Message content rules, shared by the handler and the service.
+	contentRules := domain.ContentRules{
+		MinLength:            cfg.Message.MinContentLength,
+		Prefix:               cfg.Message.ContentPrefix,
+		Footer:               cfg.Message.ContentFooter,
+		NormalizeLineEndings: cfg.Message.NormalizeLineEndings,
+		EnforceGSM7:          cfg.Message.EnforceGSM7,
+	}
+
+	// Init error reporter used for recovered panics.
+	errReporter := reporter.New(cfg.App.ErrorReporter)
 
 	// Init cache.
-	cache := redis.New(cfg.Redis.Addr, cfg.Redis.Password, cfg.Redis.DB)
+	var redisOpts []redis.Option
+	if cfg.Redis.TLS {
+		tlsCfg, err := redis.TLSConfig(cfg.Redis.TLSCAFile, cfg.Redis.TLSCertFile, cfg.Redis.TLSKeyFile)
+		if err != nil {
+			log.Fatalf("invalid config: %v", err)
+		}
+		redisOpts = append(redisOpts, redis.WithTLS(tlsCfg))
+	}
+	cache := redis.New(cfg.Redis.Addr, cfg.Redis.Password, cfg.Redis.DB, redisOpts...)
 	if err := cache.Ping(rootCtx); err != nil {
 		log.Fatalf("failed to connect to redis: %v", err)
 	}
 
 	// Init DB.
+	naming, err := mesgRepo.TableNaming{Schema: cfg.DB.Schema, Prefix: cfg.DB.TablePrefix}.NamingStrategy()
+	if err != nil {
+		log.Fatalf("invalid config: %v", err)
+	}
 	dsn := cfg.PostgresDSN()
-	db, err := gormdb.New(dsn)
+	db, err := gormdb.NewWithRetry(dsn, cfg.DB.ConnectAttempts, cfg.DB.ConnectBackoff, gormdb.WithNamingStrategy(naming))
 	if err != nil {
 		log.Fatalf("failed to connect db: %v", err)
 	}
 
 	// Init SMS provider client.
-	smsClient := sms.NewWebhookClient(cfg.SMS.ProviderURL, cfg.SMS.ProviderKey)
+	defaultProvider := config.SMSProvider{
+		URL:             cfg.SMS.ProviderURL,
+		Key:             cfg.SMS.ProviderKey,
+		PayloadTemplate: cfg.SMS.PayloadTemplate,
+		PayloadFormat:   cfg.SMS.PayloadFormat,
+		AuthHeader:      cfg.SMS.AuthHeader,
+		AuthScheme:      cfg.SMS.AuthScheme,
+	}
+	smsClient := sms.NewWebhookClient(defaultProvider.URL, defaultProvider.Key, webhookOptions("default", defaultProvider, cfg)...)
 	if err := smsClient.Health(rootCtx); err != nil {
 		log.Fatalf("failed to ping SMS provider: %v", err)
 	}
 
+	// Named providers for per-message routing; the client above is the default.
+	// Their endpoints and keys can be rotated by a reload.
+	smsClients := map[string]sms.Client{sms.DefaultProvider: smsClient}
+	reloadable := map[string]providerUpdater{sms.DefaultProvider: smsClient}
+	for name, p := range cfg.SMS.Providers {
+		c := sms.NewWebhookClient(p.URL, p.Key, webhookOptions(name, p, cfg)...)
+		if err := c.Health(rootCtx); err != nil {
+			log.Fatalf("failed to ping SMS provider %q: %v", name, err)
+		}
+		smsClients[name] = c
+		reloadable[name] = c
+	}
+
+	// Optional load balancing: messages without a provider are spread over
+	// the balanced providers, while "default" still names the one above.
+	var defaultClient sms.Client = smsClient
+	if cfg.SMS.BalanceStrategy != "" {
+		defaultClient, err = balancingClient(rootCtx, cfg, smsClients)
+		if err != nil {
+			log.Fatalf("failed to set up SMS balancing: %v", err)
+		}
+		log.Printf("[Main] Balancing SMS sends (%s) over %d providers.", cfg.SMS.BalanceStrategy, len(cfg.SMS.BalanceWeights))
+	}
+
+	smsRouter := sms.NewRouter(defaultClient)
+	for name, c := range smsClients {
+		smsRouter.Register(name, c)
+	}
+
+	// Optional read replica for read-only queries.
+	retryPriority, err := mesgRepo.ParseRetryPriority(cfg.Worker.RetryPriority)
+	if err != nil {
+		log.Fatalf("invalid RETRY_PRIORITY: %v", err)
+	}
+	repoOpts := []mesgRepo.Option{
+		mesgRepo.WithContentDedup(cfg.Message.DedupContent),
+		mesgRepo.WithRetryPriority(retryPriority),
+		mesgRepo.WithRetryBudget(cfg.Worker.RetryBudget),
+		mesgRepo.WithRecipientGrouping(cfg.Worker.GroupByRecipient),
+	}
+	if readDSN, ok := cfg.ReadReplicaDSN(); ok {
+		readDB, err := gormdb.NewWithRetry(readDSN, cfg.DB.ConnectAttempts, cfg.DB.ConnectBackoff, gormdb.WithNamingStrategy(naming))
+		if err != nil {
+			log.Fatalf("failed to connect read replica: %v", err)
+		}
+		repoOpts = append(repoOpts, mesgRepo.WithReadDB(readDB))
+		log.Println("[Main] Using read replica for read-only queries.")
+	}
+
 	// Init repository and services.
 
 	// Message
-	msgRepository := mesgRepo.NewRepository(db)
+	msgRepository := mesgRepo.NewRepository(db, repoOpts...)
+
+	svcOpts := []service.Option{
+		service.WithErrorReporter(errReporter),
+		service.WithContentRules(contentRules),
+		service.WithProviderRouter(smsRouter),
+		service.WithBatchBudget(cfg.Worker.BatchBudget),
+		service.WithRampDelay(cfg.Worker.RampDelay),
+		service.WithStuckTimeout(cfg.Worker.StuckTimeout),
+		service.WithSendRetries(cfg.Worker.MaxRetries, cfg.Worker.RetryBackoffBase, cfg.Worker.RetryBackoffMax),
+		service.WithDailySendCap(cfg.Worker.DailySendCap),
+		service.WithCreateConcurrency(cfg.Message.CreateConcurrency),
+		service.WithDedupWindow(cfg.Message.DedupWindow),
+		service.WithMulticast(cfg.Message.MulticastMaxRecipients, cfg.Message.MulticastAsyncThreshold,
+			cfg.Message.MulticastMaxGroups),
+		service.WithStoreRawOnSuccess(cfg.Worker.StoreRawOnSuccess),
+		service.WithStatusRecheck(cfg.Worker.StatusRecheck),
+		service.WithWorkerPool(cfg.Worker.PoolSize),
+		service.WithAdaptiveBatchSize(cfg.Worker.AdaptiveBatchMin, cfg.Worker.AdaptiveBatchMax,
+			cfg.Worker.AdaptiveLatencyLow, cfg.Worker.AdaptiveLatencyHigh),
+	}
+	if len(cfg.Message.ContentTransforms) > 0 {
+		transforms := service.NewTransformerRegistry()
+		if cfg.Message.LinkTrackingBaseURL != "" {
+			transforms.Register(service.TrackLinksTransform, service.NewLinkTracker(msgRepository,
+				cfg.Message.LinkTrackingBaseURL, cfg.Message.OptOutLinkBaseURL))
+		}
+		transformer, err := transforms.Build(cfg.Message.ContentTransforms)
+		if err != nil {
+			log.Fatalf("invalid CONTENT_TRANSFORMS: %v", err)
+		}
+		svcOpts = append(svcOpts, service.WithContentTransformer(transformer))
+		log.Printf("[Main] Transforming content before sending: %v.", cfg.Message.ContentTransforms)
+	}
+	if len(cfg.Message.OptOut) > 0 || len(cfg.Message.AllowedPrefixes) > 0 {
+		policy := service.NewStaticRecipientPolicy(cfg.Message.OptOut, cfg.Message.AllowedPrefixes)
+		svcOpts = append(svcOpts, service.WithRecipientPolicy(policy))
+	}
+
+	// Optional provider health gate. It runs for the lifetime of the process.
+	if cfg.SMS.HealthPollInterval > 0 {
+		monitor := sms.NewHealthMonitor(defaultClient, cfg.SMS.HealthPollInterval)
+		go monitor.Run(rootCtx)
+		svcOpts = append(svcOpts, service.WithHealthGate(monitor))
+	}
+
+	// In-process event bus. Alerts are logged (the scheduler logs its own
+	// TicksSkipped warnings); further subscribers (metrics, webhooks) can be
+	// registered here, slow ones with SubscribeAsync so they are drained on
+	// shutdown.
+	bus := event.NewBus()
+	bus.Subscribe(service.HighFailureRateEvent, event.Log)
+	bus.Subscribe(service.StalePendingEvent, event.Log)
+	svcOpts = append(svcOpts,
+		service.WithFailureAlert(bus, cfg.Worker.FailureAlertPercent),
+		service.WithPendingAgeAlert(bus, cfg.Worker.PendingAgeAlert),
+	)
+
+	if cfg.DB.RepairMissingSentAt {
+		n, err := msgRepository.RepairMissingSentAt(rootCtx)
+		if err != nil {
+			log.Fatalf("failed to repair missing sent_at: %v", err)
+		}
+		log.Printf("[Main] Backfilled sent_at on %d sent messages.", n)
+	}
+	svcOpts = append(svcOpts, service.WithTemplates(msgRepository))
+	svcOpts = append(svcOpts, service.WithLinks(msgRepository))
+	if cfg.Message.OptOutLinkBaseURL != "" {
+		svcOpts = append(svcOpts, service.WithOptOutLinks(msgRepository, cfg.Message.OptOutLinkBaseURL))
+	}
 	msgSvc := service.NewMessageService(
 		msgRepository,
 		smsClient,
@@ -59,30 +216,115 @@ func main() {
 		cfg.Worker.BatchSize,
 		cfg.Worker.MaxWorkers,
 		cfg.Worker.PerMessageTimeout,
+		svcOpts...,
 	)
 
-	// Cron
-	cron := scheduler.NewSchedulerService(
-		msgSvc,
-		cfg.Scheduler.Interval,
-		cfg.Scheduler.BatchTimeout,
-	)
+	// Metrics, served on GET /metrics.
+	metricsRegistry := metrics.NewRegistry()
+	batchMetrics, err := metrics.NewBatchMetrics(metricsRegistry)
+	if err != nil {
+		log.Fatalf("failed to register batch metrics: %v", err)
+	}
+
+	// Cron. Left nil when batches are handled by a separate worker process.
+	// With leader election every replica runs one, but only the holder of
+	// the Redis lease processes batches.
+	var cron scheduler.SchedulerService
+	var elector *scheduler.LeaderElector
+	if cfg.Scheduler.Enabled {
+		schOpts := []scheduler.Option{
+			scheduler.WithFailureBackoff(cfg.Scheduler.FailureBackoffBase, cfg.Scheduler.FailureBackoffMax),
+			scheduler.WithRunOnStart(cfg.Scheduler.RunOnStart),
+			scheduler.WithRunRecorder(msgRepository),
+			scheduler.WithSkippedTickAlert(cfg.Scheduler.SkippedTicksAlert, bus),
+			scheduler.WithBatchObserver(batchMetrics),
+			scheduler.WithActivityLogSize(cfg.Scheduler.EventsSize),
+		}
+		if cfg.Scheduler.BatchWebhookURL != "" {
+			webhook := event.NewWebhook(cfg.Scheduler.BatchWebhookURL,
+				cfg.Scheduler.BatchWebhookTimeout, cfg.Scheduler.BatchWebhookAttempts)
+			bus.SubscribeAsync(scheduler.BatchCompletedEvent, webhook.Handle)
+			schOpts = append(schOpts, scheduler.WithBatchEvents(bus))
+		}
+		if cfg.Scheduler.Cron != "" {
+			schedule, err := scheduler.ParseCron(cfg.Scheduler.Cron)
+			if err != nil {
+				log.Fatalf("invalid SCHEDULER_CRON: %v", err)
+			}
+			schOpts = append(schOpts, scheduler.WithCronSchedule(schedule))
+		}
+		if cfg.Scheduler.LeaderElection {
+			elector = scheduler.NewLeaderElector(cache, cfg.Scheduler.LeaderKey,
+				cfg.Scheduler.InstanceID, cfg.Scheduler.LeaderLeaseTTL)
+			schOpts = append(schOpts, scheduler.WithLeaderElection(elector))
+		}
+		cron = scheduler.NewSchedulerService(
+			msgSvc,
+			cfg.Scheduler.Interval,
+			cfg.Scheduler.BatchTimeout,
+			schOpts...,
+		)
+	}
 
 	// HTTP dependencies & server wiring.
 
 	// Handlers
-	homeHandler := handler.NewHomeHandler()
-	messageHandler := handler.NewMessageHandler(msgSvc, cron)
+	timeFormatter, err := response.TimeFormatterFor(cfg.API.TimeFormat)
+	if err != nil {
+		log.Fatalf("invalid config: %v", err)
+	}
+	fieldCase, err := response.FieldCaseFor(cfg.API.JSONCase)
+	if err != nil {
+		log.Fatalf("invalid config: %v", err)
+	}
+	responder := response.NewResponder(
+		response.WithTimeFormatter(timeFormatter),
+		response.WithDefaultFieldCase(fieldCase),
+		response.WithRequestID(cfg.API.ErrorRequestID),
+		response.WithSendDuration(cfg.API.SendDuration),
+	)
+	handlerOpts := []handler.Option{
+		handler.WithResponder(responder),
+		handler.WithStrictPagination(cfg.API.StrictPagination),
+		handler.WithNumericSchedulerActions(cfg.API.NumericSchedulerActions),
+		handler.WithContentRules(contentRules),
+		handler.WithPageSizes(cfg.API.PageSizes),
+	}
+	homeHandler := handler.NewHomeHandler(msgSvc, handlerOpts...)
+	messageHandler := handler.NewMessageHandler(msgSvc, cron, handlerOpts...)
+	configHandler := handler.NewConfigHandler(msgSvc, handlerOpts...)
+	statsHandler := handler.NewStatsHandler(msgSvc, handlerOpts...)
 
 	// Init route dependencies
 	deps := routes.AppDeps{
 		Home:    homeHandler,
 		Message: messageHandler,
+		Config:  configHandler,
+		Stats:   statsHandler,
+
+		AdminAuth: middleware.RequireAPIKey(cfg.API.AdminKey, responder),
+		Metrics:   metricsRegistry.Handler(),
+		Responder: responder,
 	}
 
 	// Init Server
+	tlsConfig, err := server.TLSConfig(cfg.TLS.MinVersion, cfg.TLS.CipherSuites)
+	if err != nil {
+		log.Fatalf("invalid TLS config: %v", err)
+	}
+	tlsEnabled := cfg.TLS.CertFile != "" && cfg.TLS.KeyFile != ""
+	if !tlsEnabled && (cfg.TLS.CertFile != "" || cfg.TLS.KeyFile != "") {
+		log.Fatalf("invalid TLS config: TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+	}
+
 	addr := fmt.Sprintf("%s:%s", cfg.API.Host, cfg.API.Port)
-	srv := server.New(addr, deps)
+	srv := server.New(addr, deps, errReporter,
+		server.WithMaxConnections(cfg.API.MaxConnections),
+		server.WithTrustedProxies(cfg.API.TrustedProxies),
+		server.WithMaxURLLength(cfg.API.MaxURLLength),
+		server.WithRequestTimeouts(cfg.API.RequestTimeout, cfg.API.RouteTimeouts),
+		server.WithTLS(cfg.TLS.CertFile, cfg.TLS.KeyFile, tlsConfig),
+	)
 
 	// Create a context that is cancelled on SIGINT/SIGTERM (Ctrl+C, docker stop etc.).
 	ctx, stop := signal.NotifyContext(rootCtx, os.Interrupt, syscall.SIGTERM)
@@ -90,35 +332,76 @@ func main() {
 
 	// Start the HTTP server in a separate goroutine so we can listen for signals.
 	go func() {
-		log.Printf("HTTP server listening on %s", addr)
+		if tlsEnabled {
+			log.Printf("HTTPS server listening on %s (TLS %s+)", addr, cfg.TLS.MinVersion)
+		} else {
+			log.Printf("HTTP server listening on %s", addr)
+		}
 
 		if err := srv.Start(); err != nil && !errors.Is(err, http.ErrServerClosed) {
 			log.Fatalf("HTTP server error: %v", err)
 		}
 	}()
 
+	// Compete for leadership for as long as the scheduler runs.
+	electionCtx, stopElection := context.WithCancel(rootCtx)
+	electionDone := make(chan struct{})
+	if elector != nil {
+		log.Printf("[Main] Leader election enabled (id=%s, lease=%s).", elector.ID(), cfg.Scheduler.LeaderLeaseTTL)
+		go func() {
+			defer close(electionDone)
+			elector.Run(electionCtx)
+		}()
+	} else {
+		close(electionDone)
+	}
+
 	// Start the scheduler after everything is wired up.
-	err = cron.Start()
-	if err != nil {
-		log.Fatalf("Cron job service error: %v", err)
+	if cron != nil {
+		err = cron.Start()
+		if err != nil {
+			log.Fatalf("Cron job service error: %v", err)
+		}
+		log.Println("[Main] Scheduler started.")
+	} else {
+		log.Println("[Main] Scheduler disabled (SCHEDULER_ENABLED=false).")
 	}
-	log.Println("[Main] Scheduler started.")
+
+	// SIGHUP re-reads the config and applies the hot-reloadable settings.
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
 
 	// Block until we receive a shutdown signal.
-	<-ctx.Done()
+	for ctx.Err() == nil {
+		select {
+		case <-hup:
+			log.Println("[Main] SIGHUP received, reloading config...")
+			logReload(applyReload(cfg, config.Reload(), cron, msgSvc, reloadable))
+		case <-ctx.Done():
+		}
+	}
 	log.Println("[Main] Shutdown signal received, starting graceful shutdown...")
 
 	// Give components some time to shut down cleanly.
 	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	// Stop the scheduler (waits for in-flight batch to finish or timeout).
-	log.Println("[Main] Stopping scheduler...")
-	err = cron.Stop()
-	if err != nil {
-		log.Fatalf("Cron job could not stopped. error: %v", err)
+	// Close the scheduler (waits for in-flight batch to finish or timeout).
+	// Control requests still in flight get ErrSchedulerClosed (503) rather
+	// than restarting it.
+	if cron != nil {
+		log.Println("[Main] Stopping scheduler...")
+		err = cron.Close()
+		if err != nil {
+			log.Fatalf("Cron job could not stopped. error: %v", err)
+		}
+		log.Println("[Main] Scheduler stopped.")
 	}
-	log.Println("[Main] Scheduler stopped.")
+
+	// Hand the lease over right away instead of letting it expire.
+	stopElection()
+	<-electionDone
 
 	// Gracefully shut down the HTTP server.
 	log.Println("[Main] Shutting down HTTP server...")
@@ -128,5 +411,78 @@ func main() {
 		log.Println("[Main] HTTP server stopped.")
 	}
 
+	// No batch or multicast group can start anymore; let background groups
+	// and the shared worker pool finish.
+	if err := msgSvc.Close(); err != nil {
+		log.Printf("[Main] Message service close failed: %v", err)
+	}
+
+	// Wait for async event subscribers last: the scheduler and handlers
+	// above are the publishers.
+	if cfg.Worker.EventDrainTimeout > 0 {
+		log.Println("[Main] Draining event subscribers...")
+		drainCtx, cancelDrain := context.WithTimeout(shutdownCtx, cfg.Worker.EventDrainTimeout)
+		if err := bus.Drain(drainCtx); err != nil {
+			log.Printf("[Main] Event subscribers did not finish: %v", err)
+		} else {
+			log.Println("[Main] Event subscribers drained.")
+		}
+		cancelDrain()
+	}
+
 	log.Println("[Main] Shutdown complete.")
 }
+
+// balancingClient builds the sms.BalancingClient described by
+// SMS_BALANCE_STRATEGY and SMS_BALANCE_WEIGHTS. With SMS_HEALTH_POLL_INTERVAL
+// set, each provider gets its own monitor so unhealthy ones are skipped.
+func balancingClient(ctx context.Context, cfg *config.Config, clients map[string]sms.Client) (*sms.BalancingClient, error) {
+	strategy, err := sms.StrategyFor(cfg.SMS.BalanceStrategy)
+	if err != nil {
+		return nil, err
+	}
+
+	members := make([]sms.BalancedMember, 0, len(cfg.SMS.BalanceWeights))
+	for _, w := range cfg.SMS.BalanceWeights {
+		c, ok := clients[w.Name]
+		if !ok {
+			return nil, fmt.Errorf("unknown sms provider %q in SMS_BALANCE_WEIGHTS", w.Name)
+		}
+		m := sms.BalancedMember{Name: w.Name, Client: c, Weight: w.Weight}
+		if cfg.SMS.HealthPollInterval > 0 {
+			monitor := sms.NewHealthMonitor(c, cfg.SMS.HealthPollInterval)
+			go monitor.Run(ctx)
+			m.Health = monitor
+		}
+		members = append(members, m)
+	}
+	return sms.NewBalancingClient(strategy, members...)
+}
+
+// webhookOptions builds the client options for a provider, failing fast on
+// an invalid payload template, payload format or redirect policy.
+func webhookOptions(name string, p config.SMSProvider, cfg *config.Config) []sms.WebhookOption {
+	format, err := sms.PayloadFormatFor(p.PayloadFormat)
+	if err != nil {
+		log.Fatalf("SMS provider %q: %v", name, err)
+	}
+	redirects, err := sms.RedirectPolicyFor(cfg.SMS.RedirectPolicy)
+	if err != nil {
+		log.Fatalf("invalid config: %v", err)
+	}
+	opts := []sms.WebhookOption{
+		sms.WithAuthHeader(p.AuthHeader, p.AuthScheme),
+		sms.WithMaxResponseBytes(cfg.SMS.MaxResponseBytes),
+		sms.WithConnectTimeout(cfg.SMS.ConnectTimeout),
+		sms.WithPayloadFormat(format),
+		sms.WithRedirectPolicy(redirects),
+	}
+	if p.PayloadTemplate == "" {
+		return opts
+	}
+	tmpl, err := sms.ParsePayloadTemplate(p.PayloadTemplate)
+	if err != nil {
+		log.Fatalf("SMS provider %q: %v", name, err)
+	}
+	return append(opts, sms.WithPayloadTemplate(tmpl))
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/oggyb/insider-assessment/internal/config"
+	"github.com/oggyb/insider-assessment/internal/service"
+	"github.com/oggyb/insider-assessment/internal/sms"
+)
+
+// intervalSetter is the part of the scheduler a reload can adjust.
+type intervalSetter interface {
+	SetInterval(d time.Duration) error
+}
+
+// workerConfigUpdater is the part of the message service a reload can adjust.
+type workerConfigUpdater interface {
+	UpdateWorkerConfig(u service.WorkerConfigUpdate) (service.WorkerConfig, error)
+}
+
+// providerUpdater is the part of an SMS provider client a reload can adjust.
+type providerUpdater interface {
+	UpdateConfig(endpoint, authKey string)
+}
+
+// reloadResult summarizes what a reload did.
+type reloadResult struct {
+	// Applied lists the hot-reloadable settings that were changed live.
+	Applied []string
+	// RestartRequired lists changed settings that only take effect on restart.
+	RestartRequired []string
+	// Errors holds hot settings that changed but could not be applied.
+	Errors []error
+}
+
+// clampPerMessageTimeout lowers MESSAGE_PER_MESSAGE_TIMEOUT to
+// SCHEDULER_BATCH_TIMEOUT when it is longer: the batch context would cut
+// every send off at the batch timeout anyway. It reports whether it did.
+func clampPerMessageTimeout(cfg *config.Config) bool {
+	if !cfg.Scheduler.Enabled || cfg.Scheduler.BatchTimeout <= 0 ||
+		cfg.Worker.PerMessageTimeout <= cfg.Scheduler.BatchTimeout {
+		return false
+	}
+	log.Printf("[Main] MESSAGE_PER_MESSAGE_TIMEOUT (%s) is longer than SCHEDULER_BATCH_TIMEOUT (%s), using %s",
+		cfg.Worker.PerMessageTimeout, cfg.Scheduler.BatchTimeout, cfg.Scheduler.BatchTimeout)
+	cfg.Worker.PerMessageTimeout = cfg.Scheduler.BatchTimeout
+	return true
+}
+
+// applyReload compares next against cur and applies the hot-reloadable
+// differences (scheduler interval, worker tuning and provider endpoints and
+// keys) to the live services. Applied values are written back into cur so
+// later reloads diff against what is actually running; other changed
+// settings are only reported. sch is nil when the scheduler is disabled;
+// interval changes are then ignored. providers maps provider names
+// (sms.DefaultProvider for SMS_PROVIDER_URL/KEY) to their clients. next's
+// per-message timeout is clamped to the batch timeout as at startup.
+func applyReload(cur, next *config.Config, sch intervalSetter, svc workerConfigUpdater, providers map[string]providerUpdater) reloadResult {
+	clampPerMessageTimeout(next)
+
+	var res reloadResult
+
+	if sch != nil && next.Scheduler.Interval != cur.Scheduler.Interval {
+		if err := sch.SetInterval(next.Scheduler.Interval); err != nil {
+			res.Errors = append(res.Errors, fmt.Errorf("SCHEDULER_INTERVAL: %w", err))
+		} else {
+			cur.Scheduler.Interval = next.Scheduler.Interval
+			res.Applied = append(res.Applied, "SCHEDULER_INTERVAL")
+		}
+	}
+
+	if next.Worker.BatchSize != cur.Worker.BatchSize ||
+		next.Worker.MaxWorkers != cur.Worker.MaxWorkers ||
+		next.Worker.PerMessageTimeout != cur.Worker.PerMessageTimeout {
+		_, err := svc.UpdateWorkerConfig(service.WorkerConfigUpdate{
+			BatchSize:         &next.Worker.BatchSize,
+			MaxWorkers:        &next.Worker.MaxWorkers,
+			PerMessageTimeout: &next.Worker.PerMessageTimeout,
+		})
+		if err != nil {
+			res.Errors = append(res.Errors, fmt.Errorf("MESSAGE_*: %w", err))
+		} else {
+			cur.Worker.BatchSize = next.Worker.BatchSize
+			cur.Worker.MaxWorkers = next.Worker.MaxWorkers
+			cur.Worker.PerMessageTimeout = next.Worker.PerMessageTimeout
+			res.Applied = append(res.Applied, "MESSAGE_BATCH_SIZE/MAX_WORKERS/PER_MESSAGE_TIMEOUT")
+		}
+	}
+
+	reloadProviders(cur, next, providers, &res)
+
+	// Everything else is wired once at startup.
+	restartOnly := []struct {
+		name      string
+		cur, next any
+	}{
+		{"APP_*", cur.App, next.App},
+		{"API_*", cur.API, next.API},
+		{"TLS_*", cur.TLS, next.TLS},
+		{"DB_*", cur.DB, next.DB},
+		{"REDIS_*", cur.Redis, next.Redis},
+		{"SMS_*", cur.SMS, next.SMS},
+		{"SCHEDULER_ENABLED", cur.Scheduler.Enabled, next.Scheduler.Enabled},
+		{"SCHEDULER_CRON", cur.Scheduler.Cron, next.Scheduler.Cron},
+		{"SCHEDULER_BATCH_TIMEOUT", cur.Scheduler.BatchTimeout, next.Scheduler.BatchTimeout},
+		{"SCHEDULER_FAILURE_BACKOFF_*", [2]time.Duration{cur.Scheduler.FailureBackoffBase, cur.Scheduler.FailureBackoffMax},
+			[2]time.Duration{next.Scheduler.FailureBackoffBase, next.Scheduler.FailureBackoffMax}},
+		{"SCHEDULER_RUN_ON_START", cur.Scheduler.RunOnStart, next.Scheduler.RunOnStart},
+		{"SCHEDULER_SKIPPED_TICKS_ALERT", cur.Scheduler.SkippedTicksAlert, next.Scheduler.SkippedTicksAlert},
+		{"SCHEDULER_EVENTS_SIZE", cur.Scheduler.EventsSize, next.Scheduler.EventsSize},
+		{"SCHEDULER_LEADER_*", [3]any{cur.Scheduler.LeaderElection, cur.Scheduler.LeaderKey, cur.Scheduler.LeaderLeaseTTL},
+			[3]any{next.Scheduler.LeaderElection, next.Scheduler.LeaderKey, next.Scheduler.LeaderLeaseTTL}},
+		{"SCHEDULER_INSTANCE_ID", cur.Scheduler.InstanceID, next.Scheduler.InstanceID},
+		{"BATCH_WEBHOOK_*", [3]any{cur.Scheduler.BatchWebhookURL, cur.Scheduler.BatchWebhookTimeout, cur.Scheduler.BatchWebhookAttempts},
+			[3]any{next.Scheduler.BatchWebhookURL, next.Scheduler.BatchWebhookTimeout, next.Scheduler.BatchWebhookAttempts}},
+		{"ENFORCE_GSM7", cur.Message.EnforceGSM7, next.Message.EnforceGSM7},
+		{"CONTENT_NORMALIZE_LINE_ENDINGS", cur.Message.NormalizeLineEndings, next.Message.NormalizeLineEndings},
+		{"RECIPIENT_OPT_OUT/ALLOWED_PREFIXES", [2][]string{cur.Message.OptOut, cur.Message.AllowedPrefixes},
+			[2][]string{next.Message.OptOut, next.Message.AllowedPrefixes}},
+		{"OPT_OUT_LINK_BASE_URL", cur.Message.OptOutLinkBaseURL, next.Message.OptOutLinkBaseURL},
+		{"LINK_TRACKING_BASE_URL", cur.Message.LinkTrackingBaseURL, next.Message.LinkTrackingBaseURL},
+		{"MESSAGE_DEDUP_CONTENT", cur.Message.DedupContent, next.Message.DedupContent},
+		{"MESSAGE_DEDUP_WINDOW", cur.Message.DedupWindow, next.Message.DedupWindow},
+		{"MESSAGE_CREATE_CONCURRENCY", cur.Message.CreateConcurrency, next.Message.CreateConcurrency},
+		{"MULTICAST_*", [3]int{cur.Message.MulticastMaxRecipients, cur.Message.MulticastAsyncThreshold, cur.Message.MulticastMaxGroups},
+			[3]int{next.Message.MulticastMaxRecipients, next.Message.MulticastAsyncThreshold, next.Message.MulticastMaxGroups}},
+		{"CONTENT_PREFIX/FOOTER", [2]string{cur.Message.ContentPrefix, cur.Message.ContentFooter},
+			[2]string{next.Message.ContentPrefix, next.Message.ContentFooter}},
+		{"CONTENT_MIN_LENGTH", cur.Message.MinContentLength, next.Message.MinContentLength},
+		{"CONTENT_TRANSFORMS", cur.Message.ContentTransforms, next.Message.ContentTransforms},
+		{"MESSAGE_BATCH_BUDGET", cur.Worker.BatchBudget, next.Worker.BatchBudget},
+		{"WORKER_RAMP_DELAY", cur.Worker.RampDelay, next.Worker.RampDelay},
+		{"WORKER_POOL_SIZE", cur.Worker.PoolSize, next.Worker.PoolSize},
+		{"MESSAGE_STUCK_TIMEOUT", cur.Worker.StuckTimeout, next.Worker.StuckTimeout},
+		{"MESSAGE_MAX_RETRIES", cur.Worker.MaxRetries, next.Worker.MaxRetries},
+		{"MESSAGE_RETRY_BACKOFF_*", [2]time.Duration{cur.Worker.RetryBackoffBase, cur.Worker.RetryBackoffMax},
+			[2]time.Duration{next.Worker.RetryBackoffBase, next.Worker.RetryBackoffMax}},
+		{"RETRY_PRIORITY", cur.Worker.RetryPriority, next.Worker.RetryPriority},
+		{"MESSAGE_RETRY_BUDGET", cur.Worker.RetryBudget, next.Worker.RetryBudget},
+		{"MESSAGE_GROUP_BY_RECIPIENT", cur.Worker.GroupByRecipient, next.Worker.GroupByRecipient},
+		{"DAILY_SEND_CAP", cur.Worker.DailySendCap, next.Worker.DailySendCap},
+		{"BATCH_FAILURE_ALERT_PERCENT", cur.Worker.FailureAlertPercent, next.Worker.FailureAlertPercent},
+		{"PENDING_AGE_ALERT", cur.Worker.PendingAgeAlert, next.Worker.PendingAgeAlert},
+		{"EVENT_DRAIN_TIMEOUT", cur.Worker.EventDrainTimeout, next.Worker.EventDrainTimeout},
+		{"STORE_RAW_ON_SUCCESS", cur.Worker.StoreRawOnSuccess, next.Worker.StoreRawOnSuccess},
+		{"MESSAGE_STATUS_RECHECK", cur.Worker.StatusRecheck, next.Worker.StatusRecheck},
+		{"MESSAGE_ADAPTIVE_*", [2]int{cur.Worker.AdaptiveBatchMin, cur.Worker.AdaptiveBatchMax},
+			[2]int{next.Worker.AdaptiveBatchMin, next.Worker.AdaptiveBatchMax}},
+		{"MESSAGE_ADAPTIVE_LATENCY_*", [2]time.Duration{cur.Worker.AdaptiveLatencyLow, cur.Worker.AdaptiveLatencyHigh},
+			[2]time.Duration{next.Worker.AdaptiveLatencyLow, next.Worker.AdaptiveLatencyHigh}},
+	}
+	for _, f := range restartOnly {
+		if !reflect.DeepEqual(f.cur, f.next) {
+			res.RestartRequired = append(res.RestartRequired, f.name)
+		}
+	}
+
+	return res
+}
+
+// reloadProviders rotates the endpoint and key of providers whose URL or key
+// changed. Added or removed providers, and any other SMS_* change, still
+// require a restart.
+func reloadProviders(cur, next *config.Config, providers map[string]providerUpdater, res *reloadResult) {
+	if next.SMS.ProviderURL != cur.SMS.ProviderURL || next.SMS.ProviderKey != cur.SMS.ProviderKey {
+		if c, ok := providers[sms.DefaultProvider]; ok {
+			if next.SMS.ProviderURL == "" {
+				res.Errors = append(res.Errors, errors.New("SMS_PROVIDER_URL: must not be empty"))
+			} else {
+				c.UpdateConfig(next.SMS.ProviderURL, next.SMS.ProviderKey)
+				cur.SMS.ProviderURL, cur.SMS.ProviderKey = next.SMS.ProviderURL, next.SMS.ProviderKey
+				res.Applied = append(res.Applied, "SMS_PROVIDER_URL/KEY")
+			}
+		}
+	}
+
+	for name, np := range next.SMS.Providers {
+		cp, known := cur.SMS.Providers[name]
+		c, ok := providers[name]
+		if !known || !ok || (np.URL == cp.URL && np.Key == cp.Key) {
+			continue
+		}
+		env := "SMS_PROVIDER_" + strings.ToUpper(name)
+		if np.URL == "" {
+			res.Errors = append(res.Errors, fmt.Errorf("%s_URL: must not be empty", env))
+			continue
+		}
+		c.UpdateConfig(np.URL, np.Key)
+		cp.URL, cp.Key = np.URL, np.Key
+		cur.SMS.Providers[name] = cp
+		res.Applied = append(res.Applied, env+"_URL/KEY")
+	}
+}
+
+// logReload prints the outcome of a reload.
+func logReload(res reloadResult) {
+	if len(res.Applied) == 0 && len(res.RestartRequired) == 0 && len(res.Errors) == 0 {
+		log.Println("[Main] Reload: no changes.")
+		return
+	}
+	for _, name := range res.Applied {
+		log.Printf("[Main] Reload: applied %s.", name)
+	}
+	for _, err := range res.Errors {
+		log.Printf("[Main] Reload: rejected %v", err)
+	}
+	for _, name := range res.RestartRequired {
+		log.Printf("[Main] Reload: %s changed; restart required to apply.", name)
+	}
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/oggyb/insider-assessment/internal/config"
+	"github.com/oggyb/insider-assessment/internal/service"
+	"github.com/oggyb/insider-assessment/internal/sms"
+)
+
+type fakeScheduler struct {
+	interval time.Duration
+}
+
+func (f *fakeScheduler) SetInterval(d time.Duration) error {
+	f.interval = d
+	return nil
+}
+
+type fakeWorkerService struct {
+	cfg service.WorkerConfig
+}
+
+func (f *fakeWorkerService) UpdateWorkerConfig(u service.WorkerConfigUpdate) (service.WorkerConfig, error) {
+	next := service.WorkerConfig{BatchSize: *u.BatchSize, MaxWorkers: *u.MaxWorkers, PerMessageTimeout: *u.PerMessageTimeout}
+	if err := next.Validate(); err != nil {
+		return service.WorkerConfig{}, err
+	}
+	f.cfg = next
+	return next, nil
+}
+
+func baseConfig() *config.Config {
+	cfg := &config.Config{}
+	cfg.API.Port = "8080"
+	cfg.Scheduler.Interval = 5 * time.Second
+	cfg.Worker.BatchSize = 100
+	cfg.Worker.MaxWorkers = 4
+	cfg.Worker.PerMessageTimeout = 5 * time.Second
+	return cfg
+}
+
+func TestApplyReload_HotFieldsUpdateAndImmutableAreReported(t *testing.T) {
+	cur := baseConfig()
+	next := baseConfig()
+	next.Scheduler.Interval = time.Second
+	next.Worker.BatchSize = 250
+	next.API.Port = "9090"
+	next.Message.EnforceGSM7 = true
+
+	sch := &fakeScheduler{}
+	svc := &fakeWorkerService{}
+	res := applyReload(cur, next, sch, svc, nil)
+
+	if sch.interval != time.Second {
+		t.Fatalf("expected scheduler interval 1s, got %s", sch.interval)
+	}
+	if svc.cfg.BatchSize != 250 {
+		t.Fatalf("expected batch size 250, got %d", svc.cfg.BatchSize)
+	}
+	if len(res.Applied) != 2 || len(res.Errors) != 0 {
+		t.Fatalf("expected 2 applied and no errors, got %+v", res)
+	}
+	if !slices.Contains(res.RestartRequired, "API_*") || !slices.Contains(res.RestartRequired, "ENFORCE_GSM7") {
+		t.Fatalf("expected API_* and ENFORCE_GSM7 to require a restart, got %v", res.RestartRequired)
+	}
+
+	// Applied values are tracked; immutable ones are reported again next time.
+	if cur.Scheduler.Interval != time.Second || cur.Worker.BatchSize != 250 {
+		t.Fatalf("expected applied values to be written back, got %+v", cur)
+	}
+	if cur.API.Port != "8080" {
+		t.Fatalf("expected restart-only values to stay as running, got %q", cur.API.Port)
+	}
+}
+
+func TestApplyReload_InvalidWorkerConfigIsRejected(t *testing.T) {
+	cur := baseConfig()
+	next := baseConfig()
+	next.Worker.MaxWorkers = 0
+
+	svc := &fakeWorkerService{}
+	res := applyReload(cur, next, &fakeScheduler{}, svc, nil)
+
+	if len(res.Errors) != 1 || !errors.Is(res.Errors[0], service.ErrInvalidWorkerConfig) {
+		t.Fatalf("expected an invalid worker config error, got %v", res.Errors)
+	}
+	if cur.Worker.MaxWorkers != 4 {
+		t.Fatalf("expected running config to be kept, got %d", cur.Worker.MaxWorkers)
+	}
+}
+
+func TestApplyReload_DisabledSchedulerIgnoresInterval(t *testing.T) {
+	cur := baseConfig()
+	next := baseConfig()
+	next.Scheduler.Interval = time.Second
+
+	res := applyReload(cur, next, nil, &fakeWorkerService{}, nil)
+	if len(res.Applied)+len(res.RestartRequired)+len(res.Errors) != 0 {
+		t.Fatalf("expected the interval change to be ignored, got %+v", res)
+	}
+}
+
+func TestApplyReload_NoChanges(t *testing.T) {
+	res := applyReload(baseConfig(), baseConfig(), &fakeScheduler{}, &fakeWorkerService{}, nil)
+	if len(res.Applied)+len(res.RestartRequired)+len(res.Errors) != 0 {
+		t.Fatalf("expected no changes, got %+v", res)
+	}
+}
+
+type fakeProvider struct {
+	endpoint, key string
+}
+
+func (f *fakeProvider) UpdateConfig(endpoint, authKey string) {
+	f.endpoint, f.key = endpoint, authKey
+}
+
+func TestApplyReload_RotatesProviderEndpointsAndKeys(t *testing.T) {
+	cur := baseConfig()
+	cur.SMS.ProviderURL, cur.SMS.ProviderKey = "https://old.example", "old"
+	cur.SMS.Providers = map[string]config.SMSProvider{"otp": {URL: "https://otp.example", Key: "otp-old"}}
+
+	next := baseConfig()
+	next.SMS.ProviderURL, next.SMS.ProviderKey = "https://new.example", "new"
+	next.SMS.Providers = map[string]config.SMSProvider{"otp": {URL: "https://otp.example", Key: "otp-new"}}
+
+	def, otp := &fakeProvider{}, &fakeProvider{}
+	res := applyReload(cur, next, &fakeScheduler{}, &fakeWorkerService{},
+		map[string]providerUpdater{sms.DefaultProvider: def, "otp": otp})
+
+	if def.endpoint != "https://new.example" || def.key != "new" {
+		t.Fatalf("expected the default provider to be rotated, got %+v", def)
+	}
+	if otp.endpoint != "https://otp.example" || otp.key != "otp-new" {
+		t.Fatalf("expected the otp key to be rotated, got %+v", otp)
+	}
+	if len(res.Applied) != 2 || len(res.RestartRequired) != 0 || len(res.Errors) != 0 {
+		t.Fatalf("expected both rotations applied without a restart, got %+v", res)
+	}
+
+	// A further change elsewhere in SMS_* still needs a restart.
+	next.SMS.AuthScheme = "Bearer"
+	res = applyReload(cur, next, &fakeScheduler{}, &fakeWorkerService{},
+		map[string]providerUpdater{sms.DefaultProvider: def, "otp": otp})
+	if len(res.Applied) != 0 || !slices.Contains(res.RestartRequired, "SMS_*") {
+		t.Fatalf("expected only SMS_* to require a restart, got %+v", res)
+	}
+}
+
+func TestApplyReload_EmptyProviderURLIsRejected(t *testing.T) {
+	cur := baseConfig()
+	cur.SMS.ProviderURL = "https://old.example"
+	next := baseConfig()
+
+	def := &fakeProvider{}
+	res := applyReload(cur, next, &fakeScheduler{}, &fakeWorkerService{},
+		map[string]providerUpdater{sms.DefaultProvider: def})
+	if len(res.Errors) != 1 || def.endpoint != "" || cur.SMS.ProviderURL != "https://old.example" {
+		t.Fatalf("expected the empty URL to be rejected and the running one kept, got %+v", res)
+	}
+}
+
+func TestClampPerMessageTimeout(t *testing.T) {
+	cfg := baseConfig()
+	cfg.Scheduler.Enabled = true
+	cfg.Scheduler.BatchTimeout = 2 * time.Second
+
+	if !clampPerMessageTimeout(cfg) || cfg.Worker.PerMessageTimeout != 2*time.Second {
+		t.Fatalf("expected the 5s per-message timeout clamped to 2s, got %s", cfg.Worker.PerMessageTimeout)
+	}
+	if clampPerMessageTimeout(cfg) {
+		t.Fatal("expected a per-message timeout equal to the batch timeout to be kept")
+	}
+
+	// Without a scheduler in this process there is no batch timeout to fit.
+	cfg = baseConfig()
+	cfg.Scheduler.BatchTimeout = 2 * time.Second
+	if clampPerMessageTimeout(cfg) || cfg.Worker.PerMessageTimeout != 5*time.Second {
+		t.Fatalf("expected no clamp with the scheduler disabled, got %s", cfg.Worker.PerMessageTimeout)
+	}
+}
+
+func TestApplyReload_ClampsPerMessageTimeout(t *testing.T) {
+	cur := baseConfig()
+	cur.Scheduler.Enabled = true
+	cur.Scheduler.BatchTimeout = 10 * time.Second
+	next := baseConfig()
+	next.Scheduler.Enabled = true
+	next.Scheduler.BatchTimeout = 10 * time.Second
+	next.Worker.PerMessageTimeout = time.Minute
+
+	svc := &fakeWorkerService{}
+	applyReload(cur, next, &fakeScheduler{}, svc, nil)
+	if svc.cfg.PerMessageTimeout != 10*time.Second || cur.Worker.PerMessageTimeout != 10*time.Second {
+		t.Fatalf("expected the reloaded timeout clamped to 10s, got %s", svc.cfg.PerMessageTimeout)
+	}
+}
@@ -20,8 +20,14 @@ func main() {
 	// Load application configuration (DB, Redis, etc.) from env/.env.
 	cfg := config.New()
 
+	tableNaming := mesgRepo.TableNaming{Schema: cfg.DB.Schema, Prefix: cfg.DB.TablePrefix}
+	naming, err := tableNaming.NamingStrategy()
+	if err != nil {
+		log.Fatalf("[Seed] Invalid config: %v", err)
+	}
+
 	// Open a Postgres connection through our GORM adapter.
-	gormAdapter, err := gormdb.New(cfg.PostgresDSN())
+	gormAdapter, err := gormdb.New(cfg.PostgresDSN(), gormdb.WithNamingStrategy(naming))
 	if err != nil {
 		log.Fatalf("[Seed] Failed to connect to database: %v", err)
 	}
@@ -32,7 +38,18 @@ func main() {
 	// We go through the adapter to access the underlying *gorm.DB.
 	rawDB := gormAdapter.Conn().(*gorm.DB)
 
-	if err := rawDB.AutoMigrate(&mesgRepo.MessageModel{}); err != nil {
+	// AutoMigrate creates tables, not schemas. The name was validated by
+	// TableNaming.NamingStrategy, so it is safe to quote into the statement.
+	if cfg.DB.Schema != "" {
+		if err := rawDB.Exec(fmt.Sprintf(`CREATE SCHEMA IF NOT EXISTS %q`, cfg.DB.Schema)).Error; err != nil {
+			log.Fatalf("[Seed] Failed to create schema %q: %v", cfg.DB.Schema, err)
+		}
+	}
+
+	if err := mesgRepo.RenameLegacyIndexes(rawDB, tableNaming); err != nil {
+		log.Fatalf("[Seed] Failed to rename legacy indexes: %v", err)
+	}
+	if err := rawDB.AutoMigrate(&mesgRepo.MessageModel{}, &mesgRepo.StatusEventModel{}, &mesgRepo.BatchRunModel{}, &mesgRepo.TemplateModel{}, &mesgRepo.OptOutTokenModel{}, &mesgRepo.LinkModel{}); err != nil {
 		log.Fatalf("[Seed] AutoMigrate failed: %v", err)
 	}
 	log.Println("[Seed] Messages table is up to date (AutoMigrate completed).")
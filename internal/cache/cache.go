@@ -13,6 +13,10 @@ type Cache interface {
 	// Set stores a value with the given TTL.
 	Set(ctx context.Context, key string, value string, ttl time.Duration) error
 
+	// SetNX stores a value with the given TTL only if the key does not exist yet.
+	// It reports whether the value was stored.
+	SetNX(ctx context.Context, key string, value string, ttl time.Duration) (bool, error)
+
 	// Get retrieves a value by key.
 	// Implementations should return a clear "not found" error if missing.
 	Get(ctx context.Context, key string) (string, error)
@@ -25,4 +29,7 @@ type Cache interface {
 
 	// Decr atomically decrements a numeric value and returns the new value.
 	Decr(ctx context.Context, key string) (int64, error)
+
+	// Expire sets a TTL on an existing key. No-op if the key does not exist.
+	Expire(ctx context.Context, key string, ttl time.Duration) error
 }
@@ -6,6 +6,12 @@ type Prefix string
 
 const (
 	SentMessages Prefix = "sent_messages"
+	// ExternalIDs maps a provider message ID to the internal message ID it was
+	// first assigned to, so duplicate IDs from the provider can be detected.
+	ExternalIDs Prefix = "external_ids"
+	// GlobalSent counts send attempts per UTC day (keyed by YYYY-MM-DD) for
+	// the daily send cap.
+	GlobalSent Prefix = "global_sent"
 )
 
 func (p Prefix) Key(id string) string {
@@ -2,6 +2,7 @@ package redis
 
 import (
 	"context"
+	"crypto/tls"
 	"github.com/oggyb/insider-assessment/internal/cache"
 	"time"
 
@@ -13,14 +14,31 @@ type Client struct {
 	rdb *redis.Client
 }
 
+// Option customizes the go-redis options at construction time.
+type Option func(*redis.Options)
+
+// WithTLS connects over TLS using cfg (see TLSConfig). A nil cfg keeps the
+// plaintext connection.
+func WithTLS(cfg *tls.Config) Option {
+	return func(o *redis.Options) {
+		if cfg != nil {
+			o.TLSConfig = cfg
+		}
+	}
+}
+
 // New creates a new Redis client with the given address, password and DB number.
-func New(addr, password string, dbNumber int) *Client {
-	rdb := redis.NewClient(&redis.Options{
+func New(addr, password string, dbNumber int, opts ...Option) *Client {
+	o := &redis.Options{
 		Addr:     addr,
 		Password: password,
 		DB:       dbNumber,
-	})
-	return &Client{rdb: rdb}
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	return &Client{rdb: redis.NewClient(o)}
 }
 
 // Ping checks if Redis is reachable.
@@ -33,6 +51,11 @@ func (c *Client) Set(ctx context.Context, key string, value string, ttl time.Dur
 	return c.rdb.Set(ctx, key, value, ttl).Err()
 }
 
+// SetNX stores a value with the given TTL only if the key is not set yet.
+func (c *Client) SetNX(ctx context.Context, key string, value string, ttl time.Duration) (bool, error) {
+	return c.rdb.SetNX(ctx, key, value, ttl).Result()
+}
+
 // Get retrieves a value by key.
 func (c *Client) Get(ctx context.Context, key string) (string, error) {
 	return c.rdb.Get(ctx, key).Result()
@@ -53,4 +76,37 @@ func (c *Client) Decr(ctx context.Context, key string) (int64, error) {
 	return c.rdb.Decr(ctx, key).Result()
 }
 
+// Expire sets a TTL on an existing key.
+func (c *Client) Expire(ctx context.Context, key string, ttl time.Duration) error {
+	return c.rdb.Expire(ctx, key, ttl).Err()
+}
+
+// renewIfHeld extends the TTL of KEYS[1] only while it holds ARGV[1].
+var renewIfHeld = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+// releaseIfHeld deletes KEYS[1] only while it holds ARGV[1].
+var releaseIfHeld = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// RenewIfHeld extends the TTL of key if it still holds value, reporting
+// whether it did. Check and extension are one atomic script.
+func (c *Client) RenewIfHeld(ctx context.Context, key, value string, ttl time.Duration) (bool, error) {
+	n, err := renewIfHeld.Run(ctx, c.rdb, []string{key}, value, ttl.Milliseconds()).Int()
+	return n == 1, err
+}
+
+// ReleaseIfHeld deletes key if it still holds value.
+func (c *Client) ReleaseIfHeld(ctx context.Context, key, value string) error {
+	return releaseIfHeld.Run(ctx, c.rdb, []string{key}, value).Err()
+}
+
 var _ cache.Cache = (*Client)(nil)
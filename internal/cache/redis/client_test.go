@@ -0,0 +1,100 @@
+package redis
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestNew_TLSOption(t *testing.T) {
+	plain := New("localhost:6379", "", 0)
+	t.Cleanup(func() { _ = plain.rdb.Close() })
+	if plain.rdb.Options().TLSConfig != nil {
+		t.Fatalf("expected a plaintext connection by default")
+	}
+
+	tlsCfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	secure := New("localhost:6379", "", 0, WithTLS(tlsCfg))
+	t.Cleanup(func() { _ = secure.rdb.Close() })
+	if secure.rdb.Options().TLSConfig != tlsCfg {
+		t.Fatalf("expected the TLS config to be passed to the client")
+	}
+}
+
+// writeSelfSigned writes a self-signed certificate and its key as PEM files
+// into dir and returns their paths.
+func writeSelfSigned(t *testing.T, dir string) (certFile, keyFile string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "redis-test"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("create certificate: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("marshal key: %v", err)
+	}
+
+	certFile = filepath.Join(dir, "cert.pem")
+	keyFile = filepath.Join(dir, "key.pem")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatalf("write cert: %v", err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatalf("write key: %v", err)
+	}
+	return certFile, keyFile
+}
+
+func TestTLSConfig(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeSelfSigned(t, dir)
+
+	cfg, err := TLSConfig("", "", "")
+	if err != nil {
+		t.Fatalf("TLSConfig: %v", err)
+	}
+	if cfg.RootCAs != nil || len(cfg.Certificates) != 0 || cfg.MinVersion != tls.VersionTLS12 {
+		t.Fatalf("expected system roots and no client certificate, got %+v", cfg)
+	}
+
+	cfg, err = TLSConfig(certFile, certFile, keyFile)
+	if err != nil {
+		t.Fatalf("TLSConfig: %v", err)
+	}
+	if cfg.RootCAs == nil || len(cfg.Certificates) != 1 {
+		t.Fatalf("expected a custom CA and a client certificate, got %+v", cfg)
+	}
+
+	if _, err := TLSConfig(filepath.Join(dir, "missing.pem"), "", ""); err == nil {
+		t.Fatalf("expected an error for a missing CA file")
+	}
+	if _, err := TLSConfig(keyFile, "", ""); err == nil {
+		t.Fatalf("expected an error for a CA file without certificates")
+	}
+	if _, err := TLSConfig("", certFile, ""); err == nil {
+		t.Fatalf("expected an error for a certificate without a key")
+	}
+}
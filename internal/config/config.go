@@ -3,21 +3,72 @@ package config
 import (
 	"fmt"
 	"github.com/joho/godotenv"
+	"log"
 	"os"
 	"strconv"
 	"strings"
 	"time"
+
+	domain "github.com/oggyb/insider-assessment/internal/domain/message"
 )
 
 type Config struct {
 	App struct {
-		Name string
-		Env  string
+		Name          string
+		Env           string
+		ErrorReporter string
 	}
 
 	API struct {
-		Host string
-		Port string
+		Host             string
+		Port             string
+		StrictPagination bool
+		// NumericSchedulerActions lets POST /scheduler accept 1/0 as start/stop.
+		NumericSchedulerActions bool
+		// TimeFormat is the envelope timestamp format: rfc3339 | rfc3339nano | unix | unixmilli.
+		TimeFormat string
+		// JSONCase is the default message field casing: camel | snake. Clients
+		// can override it per request with Accept: application/json; case=...
+		JSONCase string
+		// MaxConnections caps simultaneously open HTTP connections; 0 means unlimited.
+		MaxConnections int
+		// ErrorRequestID adds the request's X-Request-ID to error envelopes.
+		ErrorRequestID bool
+		// SendDuration adds each message's last provider round-trip time
+		// to message responses.
+		SendDuration bool
+		// AdminKey is the X-API-Key required by operator-only routes. Empty disables them.
+		AdminKey string
+		// TrustedProxies are the IPs/CIDRs whose X-Forwarded-For and
+		// X-Real-IP headers are trusted to carry the client IP.
+		TrustedProxies []string
+		// MaxURLLength rejects request URIs longer than this many bytes
+		// with 414; 0 disables the check.
+		MaxURLLength int
+		// RequestTimeout is the default request deadline and RouteTimeouts
+		// overrides it per mux pattern, configured via
+		// API_ROUTE_TIMEOUTS=GET /health=2s,/messages/export=5m. 0 means no
+		// deadline.
+		RequestTimeout time.Duration
+		RouteTimeouts  map[string]time.Duration
+
+		// PageSizes overrides the default and maximum page size of list
+		// endpoints per path, configured via
+		// API_PAGE_SIZES=/messages/sent=10:50,/scheduler/runs=5. Sizes may
+		// not exceed domain.MaxPageLimit.
+		PageSizes map[string]domain.PageSize
+	}
+
+	// TLS serves the API over HTTPS when both CertFile and KeyFile are set;
+	// otherwise it stays plaintext.
+	TLS struct {
+		CertFile string
+		KeyFile  string
+		// MinVersion is the lowest accepted protocol version: 1.2 | 1.3.
+		MinVersion string
+		// CipherSuites optionally restricts TLS 1.2 suites by their Go
+		// names; empty keeps Go's secure defaults.
+		CipherSuites []string
 	}
 
 	DB struct {
@@ -27,43 +78,319 @@ type Config struct {
 		Password string
 		Name     string
 		SSLMode  string
+
+		// ConnectAttempts and ConnectBackoff retry the initial connection,
+		// for databases that are still starting when the service does.
+		ConnectAttempts int
+		ConnectBackoff  time.Duration
+
+		// Schema and TablePrefix place the tables under a schema other than
+		// the search_path default and/or prefix their names, e.g. for
+		// several tenants in one database.
+		Schema      string
+		TablePrefix string
+
+		// RepairMissingSentAt backfills sent_at from updated_at on SUCCESS
+		// messages that lack it, once at startup.
+		RepairMissingSentAt bool
+
+		// ReadURL is an optional DSN for a read replica. When empty, the
+		// replica is built from DB_READ_HOST (plus optional DB_READ_* overrides);
+		// when that is empty too, reads go to the primary.
+		ReadURL string
+		Read    struct {
+			Host     string
+			Port     int
+			User     string
+			Password string
+			Name     string
+		}
 	}
 
 	Redis struct {
 		Addr     string
 		Password string
 		DB       int
+
+		// TLS enables TLS to Redis. TLSCAFile optionally replaces the system
+		// roots; TLSCertFile/TLSKeyFile optionally present a client certificate.
+		TLS         bool
+		TLSCAFile   string
+		TLSCertFile string
+		TLSKeyFile  string
 	}
 
 	SMS struct {
 		ProviderURL string
 		ProviderKey string
+		// PayloadTemplate optionally reshapes the request body (text/template,
+		// see sms.ParsePayloadTemplate). Empty keeps the default {to, content}.
+		PayloadTemplate string
+		// PayloadFormat is "json" (default) or "form" for providers that
+		// only accept application/x-www-form-urlencoded. It is inherited
+		// by named providers.
+		PayloadFormat string
+
+		// AuthHeader is the request header carrying ProviderKey, and AuthScheme
+		// an optional prefix for its value (e.g. "Bearer"). They default to
+		// x-ins-auth-key with no scheme and are inherited by named providers.
+		AuthHeader string
+		AuthScheme string
+
+		// MaxResponseBytes caps how much of a provider response is read;
+		// larger responses fail the send.
+		MaxResponseBytes int64
+
+		// ConnectTimeout bounds establishing a connection to a provider,
+		// independently of the overall request timeout; 0 keeps Go's default.
+		ConnectTimeout time.Duration
+
+		// RedirectPolicy is "same-host" (default: follow redirects, auth
+		// header only to the original host) or "none" (redirects fail).
+		RedirectPolicy string
+
+		// HealthPollInterval controls how often the default provider's health
+		// is polled; sends pause while it is unhealthy. 0 disables polling.
+		HealthPollInterval time.Duration
+
+		// Providers holds additional named providers, keyed by lowercase name.
+		// Configured via SMS_PROVIDERS=otp,marketing plus
+		// SMS_PROVIDER_<NAME>_URL / SMS_PROVIDER_<NAME>_KEY
+		// (and optional SMS_PROVIDER_<NAME>_PAYLOAD_TEMPLATE,
+		// SMS_PROVIDER_<NAME>_AUTH_HEADER / _AUTH_SCHEME) per name.
+		Providers map[string]SMSProvider
+
+		// BalanceStrategy spreads messages without an explicit provider over
+		// BalanceWeights ("round-robin" or "weighted"); empty sends them all
+		// to the default provider.
+		BalanceStrategy string
+		// BalanceWeights lists the balanced providers in order, configured
+		// via SMS_BALANCE_WEIGHTS=default=3,otp=1. "default" is the provider
+		// above; a missing or invalid weight counts as 1.
+		BalanceWeights []SMSBalanceWeight
 	}
 
 	Scheduler struct {
+		// Enabled runs the batch scheduler in this process. Disable it on
+		// API replicas when a separate worker process handles batches.
+		Enabled bool
+
 		Interval     time.Duration
 		BatchTimeout time.Duration
+
+		// Cron, when set, runs batches at the times of this cron
+		// expression (e.g. "*/5 9-17 * * 1-5") instead of every Interval.
+		Cron string
+
+		// FailureBackoffBase/Max stretch the interval after failed batches.
+		// A zero base disables backoff.
+		FailureBackoffBase time.Duration
+		FailureBackoffMax  time.Duration
+
+		// RunOnStart runs a batch immediately on Start instead of after the first interval.
+		RunOnStart bool
+
+		// SkippedTicksAlert warns after this many consecutive ticks were
+		// skipped because batches outran the interval; 0 disables it.
+		SkippedTicksAlert int
+
+		// EventsSize is how many recent scheduler events GET
+		// /scheduler/events keeps in memory; 0 disables the log.
+		EventsSize int
+
+		// LeaderElection lets only the replica holding a Redis lease under
+		// LeaderKey run batches; the others take over once it expires
+		// after LeaderLeaseTTL. InstanceID names this replica in the lease
+		// and defaults to the host name plus a random suffix.
+		LeaderElection bool
+		LeaderKey      string
+		LeaderLeaseTTL time.Duration
+		InstanceID     string
+
+		// BatchWebhookURL, when set, receives every batch result as a JSON
+		// POST. Each attempt is bounded by BatchWebhookTimeout and a failed
+		// delivery is tried up to BatchWebhookAttempts times.
+		BatchWebhookURL      string
+		BatchWebhookTimeout  time.Duration
+		BatchWebhookAttempts int
+	}
+
+	Message struct {
+		// EnforceGSM7 rejects content with characters outside the GSM-7 alphabet.
+		EnforceGSM7 bool
+		// NormalizeLineEndings converts CRLF/CR line breaks in new content
+		// to LF.
+		NormalizeLineEndings bool
+		// ContentPrefix/ContentFooter are added around every message's
+		// content and count toward its length limit. Empty disables them.
+		ContentPrefix string
+		ContentFooter string
+		// MinContentLength is the fewest characters content may have after
+		// trimming, not counting the prefix/footer.
+		MinContentLength int
+
+		// ContentTransforms names the pre-send content transformers, applied
+		// in order (see service.NewTransformerRegistry).
+		ContentTransforms []string
+
+		// OptOut lists recipients that must no longer be messaged, and
+		// AllowedPrefixes (if any) the number prefixes that may be. Both are
+		// checked right before each send.
+		OptOut          []string
+		AllowedPrefixes []string
+
+		// OptOutLinkBaseURL, when set, appends a personal unsubscribe link
+		// (this URL plus the recipient's token, resolved by GET
+		// /optout/{token}) to every message.
+		OptOutLinkBaseURL string
+
+		// LinkTrackingBaseURL, when set, makes the "track-links" content
+		// transform available: it replaces URLs with this URL plus a token
+		// that GET /l/{token} counts and redirects.
+		LinkTrackingBaseURL string
+
+		// DedupContent rejects a new message whose recipient and content
+		// match a message that has not been sent yet.
+		DedupContent bool
+
+		// DedupWindow rejects a new message whose recipient and content
+		// match a pending or sent message created within it, checked in
+		// the database; 0 disables it.
+		DedupWindow time.Duration
+
+		// CreateConcurrency caps concurrent message creates; further ones
+		// get 503. 0 means unlimited.
+		CreateConcurrency int
+
+		// MulticastMaxRecipients caps the recipients of POST
+		// /messages/multicast; groups above MulticastAsyncThreshold are
+		// created in the background, at most MulticastMaxGroups at once.
+		MulticastMaxRecipients  int
+		MulticastAsyncThreshold int
+		MulticastMaxGroups      int
 	}
 
 	Worker struct {
 		BatchSize         int
 		MaxWorkers        int
 		PerMessageTimeout time.Duration
+		// PoolSize runs batch workers on this many long-lived goroutines
+		// shared by all batches; 0 starts workers per batch.
+		PoolSize int
+		// BatchBudget stops dispatching new messages once a batch has run this
+		// long; 0 disables the budget.
+		BatchBudget time.Duration
+		// RampDelay staggers worker start-up within a batch; 0 starts all
+		// workers at once.
+		RampDelay time.Duration
+		// StuckTimeout is how long a message may stay PROCESSING before it is
+		// returned to PENDING; keep it well above SCHEDULER_BATCH_TIMEOUT.
+		StuckTimeout time.Duration
+		// MaxRetries is how often a failed provider send is retried before
+		// the message is FAILED, waiting RetryBackoffBase doubling up to
+		// RetryBackoffMax in between; 0 disables retries.
+		MaxRetries       int
+		RetryBackoffBase time.Duration
+		RetryBackoffMax  time.Duration
+		// RetryPriority hands out retried messages "first" or "last"
+		// relative to fresh ones; empty keeps plain creation order.
+		RetryPriority string
+		// RetryBudget caps the fraction (0 to 1) of a batch that retried
+		// messages may take while fresh ones wait; 0 disables the cap.
+		RetryBudget float64
+		// GroupByRecipient orders each fetched batch by recipient, for
+		// providers that send several messages per call.
+		GroupByRecipient bool
+		// DailySendCap limits send attempts per UTC day across instances
+		// (counted in Redis); 0 means unlimited.
+		DailySendCap int
+		// FailureAlertPercent publishes a HighFailureRate event after batches
+		// in which more than this percentage of messages failed; -1 disables.
+		FailureAlertPercent int
+		// PendingAgeAlert flags the queue unhealthy (GET /stats/pending) and
+		// publishes StalePending once the oldest pending message is older
+		// than this; 0 disables.
+		PendingAgeAlert time.Duration
+		// EventDrainTimeout bounds how long shutdown waits for async event
+		// subscribers still handling an event; 0 skips the wait.
+		EventDrainTimeout time.Duration
+		// StoreRawOnSuccess keeps the provider's full response body for
+		// successful sends; when false only the external ID is recorded.
+		// Failures always keep the full body.
+		StoreRawOnSuccess bool
+		// StatusRecheck re-reads each message's status before sending and
+		// skips those already SUCCESS, FAILED or EXPIRED.
+		StatusRecheck bool
+		// AdaptiveBatchMin/Max bound the batch size when it follows provider
+		// latency: it grows after batches averaging under
+		// AdaptiveLatencyLow per send and halves above AdaptiveLatencyHigh
+		// or on send timeouts. AdaptiveBatchMax 0 keeps BatchSize fixed.
+		AdaptiveBatchMin    int
+		AdaptiveBatchMax    int
+		AdaptiveLatencyLow  time.Duration
+		AdaptiveLatencyHigh time.Duration
 	}
 }
 
+// SMSProvider holds the connection settings of a single named SMS provider.
+type SMSProvider struct {
+	URL             string
+	Key             string
+	PayloadTemplate string
+	PayloadFormat   string
+	AuthHeader      string
+	AuthScheme      string
+}
+
+// SMSBalanceWeight is one provider's share of balanced sends.
+type SMSBalanceWeight struct {
+	Name   string
+	Weight int
+}
+
 func New() *Config {
 	_ = godotenv.Load()
+	return load()
+}
+
+// Reload re-reads the .env file, overriding values loaded from it earlier,
+// and returns a fresh Config. It is meant for SIGHUP handling; the caller
+// decides which of the changed fields can be applied without a restart.
+func Reload() *Config {
+	_ = godotenv.Overload()
+	return load()
+}
 
+func load() *Config {
 	cfg := &Config{}
 
 	// App
 	cfg.App.Name = getEnv("APP_NAME", "kitabist")
 	cfg.App.Env = getEnv("APP_ENV", "development")
+	cfg.App.ErrorReporter = getEnv("APP_ERROR_REPORTER", "noop")
 
 	// API
 	cfg.API.Host = getEnv("API_HOST", "0.0.0.0")
 	cfg.API.Port = getEnv("API_PORT", "8080")
+	cfg.API.StrictPagination = getBool("API_STRICT_PAGINATION", false)
+	cfg.API.NumericSchedulerActions = getBool("API_SCHEDULER_NUMERIC_ACTIONS", false)
+	cfg.API.TimeFormat = getEnv("RESPONSE_TIME_FORMAT", "rfc3339")
+	cfg.API.JSONCase = getEnv("RESPONSE_JSON_CASE", "camel")
+	cfg.API.MaxConnections = getInt("MAX_CONNECTIONS", 0)
+	cfg.API.AdminKey = getEnv("API_ADMIN_KEY", "")
+	cfg.API.ErrorRequestID = getBool("API_ERROR_REQUEST_ID", true)
+	cfg.API.SendDuration = getBool("RESPONSE_SEND_DURATION", false)
+	cfg.API.TrustedProxies = getList("API_TRUSTED_PROXIES")
+	cfg.API.MaxURLLength = getInt("API_MAX_URL_LENGTH", 8192)
+	cfg.API.RequestTimeout = getDuration("API_REQUEST_TIMEOUT", 0)
+	cfg.API.RouteTimeouts = getRouteTimeouts("API_ROUTE_TIMEOUTS")
+	cfg.API.PageSizes = getPageSizes("API_PAGE_SIZES")
+
+	// TLS (optional)
+	cfg.TLS.CertFile = getEnv("TLS_CERT_FILE", "")
+	cfg.TLS.KeyFile = getEnv("TLS_KEY_FILE", "")
+	cfg.TLS.MinVersion = getEnv("TLS_MIN_VERSION", "1.2")
+	cfg.TLS.CipherSuites = getList("TLS_CIPHER_SUITES")
 
 	// DB
 	cfg.DB.Host = getEnv("DB_HOST", "db")
@@ -72,24 +399,104 @@ func New() *Config {
 	cfg.DB.Password = getEnv("DB_PASSWORD", "123456")
 	cfg.DB.Name = getEnv("DB_NAME", "db_ins_message")
 	cfg.DB.SSLMode = getEnv("DB_SSLMODE", "disable")
+	cfg.DB.ConnectAttempts = getInt("DB_CONNECT_ATTEMPTS", 10)
+	cfg.DB.ConnectBackoff = getDuration("DB_CONNECT_BACKOFF", 2*time.Second)
+	cfg.DB.RepairMissingSentAt = getBool("DB_REPAIR_MISSING_SENT_AT", false)
+	cfg.DB.Schema = getEnv("DB_SCHEMA", "")
+	cfg.DB.TablePrefix = getEnv("DB_TABLE_PREFIX", "")
+
+	// DB read replica (optional)
+	cfg.DB.ReadURL = getEnv("DATABASE_READ_URL", "")
+	cfg.DB.Read.Host = getEnv("DB_READ_HOST", "")
+	cfg.DB.Read.Port = getInt("DB_READ_PORT", cfg.DB.Port)
+	cfg.DB.Read.User = getEnv("DB_READ_USER", cfg.DB.User)
+	cfg.DB.Read.Password = getEnv("DB_READ_PASSWORD", cfg.DB.Password)
+	cfg.DB.Read.Name = getEnv("DB_READ_NAME", cfg.DB.Name)
 
 	// Redis
 	cfg.Redis.Addr = getEnv("REDIS_ADDR", "redis:6379")
 	cfg.Redis.Password = getEnv("REDIS_PASSWORD", "")
 	cfg.Redis.DB = getInt("REDIS_DB", 0)
+	cfg.Redis.TLS = getBool("REDIS_TLS", false)
+	cfg.Redis.TLSCAFile = getEnv("REDIS_TLS_CA_FILE", "")
+	cfg.Redis.TLSCertFile = getEnv("REDIS_TLS_CERT_FILE", "")
+	cfg.Redis.TLSKeyFile = getEnv("REDIS_TLS_KEY_FILE", "")
 
 	// SMS Service
 	cfg.SMS.ProviderURL = getEnv("SMS_PROVIDER_URL", "")
 	cfg.SMS.ProviderKey = getEnv("SMS_PROVIDER_KEY", "")
+	cfg.SMS.PayloadTemplate = getEnv("SMS_PAYLOAD_TEMPLATE", "")
+	cfg.SMS.PayloadFormat = getEnv("SMS_PAYLOAD_FORMAT", "json")
+	cfg.SMS.AuthHeader = getEnv("SMS_AUTH_HEADER", "x-ins-auth-key")
+	cfg.SMS.AuthScheme = getEnv("SMS_AUTH_SCHEME", "")
+	cfg.SMS.MaxResponseBytes = int64(getInt("SMS_MAX_RESPONSE_BYTES", 1<<20))
+	cfg.SMS.ConnectTimeout = getDuration("SMS_CONNECT_TIMEOUT", 0)
+	cfg.SMS.RedirectPolicy = getEnv("SMS_REDIRECT_POLICY", "same-host")
+	cfg.SMS.Providers = getSMSProviders("SMS_PROVIDERS", cfg.SMS.PayloadFormat, cfg.SMS.AuthHeader, cfg.SMS.AuthScheme)
+	cfg.SMS.HealthPollInterval = getDuration("SMS_HEALTH_POLL_INTERVAL", 0)
+	cfg.SMS.BalanceStrategy = getEnv("SMS_BALANCE_STRATEGY", "")
+	cfg.SMS.BalanceWeights = getSMSBalanceWeights("SMS_BALANCE_WEIGHTS")
 
 	// Worker
+	cfg.Scheduler.Enabled = getBool("SCHEDULER_ENABLED", true)
 	cfg.Scheduler.Interval = getDuration("SCHEDULER_INTERVAL", 5*time.Second)
 	cfg.Scheduler.BatchTimeout = getDuration("SCHEDULER_BATCH_TIMEOUT", 30*time.Second)
+	cfg.Scheduler.Cron = getEnv("SCHEDULER_CRON", "")
+	cfg.Scheduler.FailureBackoffBase = getDuration("SCHEDULER_FAILURE_BACKOFF_BASE", 0)
+	cfg.Scheduler.FailureBackoffMax = getDuration("SCHEDULER_FAILURE_BACKOFF_MAX", 5*time.Minute)
+	cfg.Scheduler.RunOnStart = getBool("SCHEDULER_RUN_ON_START", true)
+	cfg.Scheduler.SkippedTicksAlert = getInt("SCHEDULER_SKIPPED_TICKS_ALERT", 3)
+	cfg.Scheduler.EventsSize = getInt("SCHEDULER_EVENTS_SIZE", 100)
+	cfg.Scheduler.LeaderElection = getBool("SCHEDULER_LEADER_ELECTION", false)
+	cfg.Scheduler.LeaderKey = getEnv("SCHEDULER_LEADER_KEY", "scheduler:leader")
+	cfg.Scheduler.LeaderLeaseTTL = getDuration("SCHEDULER_LEADER_LEASE_TTL", 15*time.Second)
+	cfg.Scheduler.InstanceID = getEnv("SCHEDULER_INSTANCE_ID", "")
+	cfg.Scheduler.BatchWebhookURL = getEnv("BATCH_WEBHOOK_URL", "")
+	cfg.Scheduler.BatchWebhookTimeout = getDuration("BATCH_WEBHOOK_TIMEOUT", 5*time.Second)
+	cfg.Scheduler.BatchWebhookAttempts = getInt("BATCH_WEBHOOK_ATTEMPTS", 3)
+
+	// Message rules
+	cfg.Message.EnforceGSM7 = getBool("ENFORCE_GSM7", false)
+	cfg.Message.NormalizeLineEndings = getBool("CONTENT_NORMALIZE_LINE_ENDINGS", false)
+	cfg.Message.ContentPrefix = getEnv("CONTENT_PREFIX", "")
+	cfg.Message.ContentFooter = getEnv("CONTENT_FOOTER", "")
+	cfg.Message.MinContentLength = getInt("CONTENT_MIN_LENGTH", 1)
+	cfg.Message.ContentTransforms = getList("CONTENT_TRANSFORMS")
+	cfg.Message.OptOut = getList("RECIPIENT_OPT_OUT")
+	cfg.Message.OptOutLinkBaseURL = getEnv("OPT_OUT_LINK_BASE_URL", "")
+	cfg.Message.LinkTrackingBaseURL = getEnv("LINK_TRACKING_BASE_URL", "")
+	cfg.Message.AllowedPrefixes = getList("RECIPIENT_ALLOWED_PREFIXES")
+	cfg.Message.DedupContent = getBool("MESSAGE_DEDUP_CONTENT", false)
+	cfg.Message.DedupWindow = getDuration("MESSAGE_DEDUP_WINDOW", 0)
+	cfg.Message.CreateConcurrency = getInt("MESSAGE_CREATE_CONCURRENCY", 0)
+	cfg.Message.MulticastMaxRecipients = getInt("MULTICAST_MAX_RECIPIENTS", 1000)
+	cfg.Message.MulticastAsyncThreshold = getInt("MULTICAST_ASYNC_THRESHOLD", 100)
+	cfg.Message.MulticastMaxGroups = getInt("MULTICAST_MAX_GROUPS", 2)
 
 	// Worker / message processing
 	cfg.Worker.BatchSize = getInt("MESSAGE_BATCH_SIZE", 100)
 	cfg.Worker.MaxWorkers = getInt("MESSAGE_MAX_WORKERS", 4)
+	cfg.Worker.PoolSize = getInt("WORKER_POOL_SIZE", 0)
 	cfg.Worker.PerMessageTimeout = getDuration("MESSAGE_PER_MESSAGE_TIMEOUT", 5*time.Second)
+	cfg.Worker.BatchBudget = getDuration("MESSAGE_BATCH_BUDGET", 0)
+	cfg.Worker.RampDelay = getDuration("WORKER_RAMP_DELAY", 0)
+	cfg.Worker.StuckTimeout = getDuration("MESSAGE_STUCK_TIMEOUT", 5*time.Minute)
+	cfg.Worker.MaxRetries = getInt("MESSAGE_MAX_RETRIES", 0)
+	cfg.Worker.RetryBackoffBase = getDuration("MESSAGE_RETRY_BACKOFF_BASE", 30*time.Second)
+	cfg.Worker.RetryBackoffMax = getDuration("MESSAGE_RETRY_BACKOFF_MAX", 10*time.Minute)
+	cfg.Worker.RetryPriority = getEnv("RETRY_PRIORITY", "")
+	cfg.Worker.RetryBudget = getFloat("MESSAGE_RETRY_BUDGET", 0)
+	cfg.Worker.GroupByRecipient = getBool("MESSAGE_GROUP_BY_RECIPIENT", false)
+	cfg.Worker.DailySendCap = getInt("DAILY_SEND_CAP", 0)
+	cfg.Worker.FailureAlertPercent = getInt("BATCH_FAILURE_ALERT_PERCENT", -1)
+	cfg.Worker.PendingAgeAlert = getDuration("PENDING_AGE_ALERT", 0)
+	cfg.Worker.EventDrainTimeout = getDuration("EVENT_DRAIN_TIMEOUT", 5*time.Second)
+	cfg.Worker.StoreRawOnSuccess = getBool("STORE_RAW_ON_SUCCESS", false)
+	cfg.Worker.StatusRecheck = getBool("MESSAGE_STATUS_RECHECK", false)
+	cfg.Worker.AdaptiveBatchMin = getInt("MESSAGE_ADAPTIVE_BATCH_MIN", 1)
+	cfg.Worker.AdaptiveBatchMax = getInt("MESSAGE_ADAPTIVE_BATCH_MAX", 0)
+	cfg.Worker.AdaptiveLatencyLow = getDuration("MESSAGE_ADAPTIVE_LATENCY_LOW", 200*time.Millisecond)
+	cfg.Worker.AdaptiveLatencyHigh = getDuration("MESSAGE_ADAPTIVE_LATENCY_HIGH", 2*time.Second)
 
 	return cfg
 }
@@ -111,6 +518,14 @@ func isTruthy(v string) bool {
 	}
 }
 
+func getBool(key string, def bool) bool {
+	v := strings.TrimSpace(os.Getenv(key))
+	if v == "" {
+		return def
+	}
+	return isTruthy(v)
+}
+
 func getInt(key string, def int) int {
 	v := strings.TrimSpace(os.Getenv(key))
 	if v == "" {
@@ -135,6 +550,113 @@ func getDuration(key string, def time.Duration) time.Duration {
 	return d
 }
 
+func getFloat(key string, def float64) float64 {
+	v := strings.TrimSpace(os.Getenv(key))
+	if v == "" {
+		return def
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return def
+	}
+	return f
+}
+
+// getList splits a comma-separated variable into trimmed, non-empty items.
+func getList(key string) []string {
+	var out []string
+	for _, item := range strings.Split(getEnv(key, ""), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+func getSMSProviders(key, payloadFormat, authHeader, authScheme string) map[string]SMSProvider {
+	out := map[string]SMSProvider{}
+	for _, name := range strings.Split(getEnv(key, ""), ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		prefix := "SMS_PROVIDER_" + strings.ToUpper(name)
+		out[name] = SMSProvider{
+			URL:             getEnv(prefix+"_URL", ""),
+			Key:             getEnv(prefix+"_KEY", ""),
+			PayloadTemplate: getEnv(prefix+"_PAYLOAD_TEMPLATE", ""),
+			PayloadFormat:   getEnv(prefix+"_PAYLOAD_FORMAT", payloadFormat),
+			AuthHeader:      getEnv(prefix+"_AUTH_HEADER", authHeader),
+			AuthScheme:      getEnv(prefix+"_AUTH_SCHEME", authScheme),
+		}
+	}
+	return out
+}
+
+// getSMSBalanceWeights parses name=weight items; a bare name weighs 1.
+func getSMSBalanceWeights(key string) []SMSBalanceWeight {
+	var out []SMSBalanceWeight
+	for _, item := range getList(key) {
+		name, weight, _ := strings.Cut(item, "=")
+		w, err := strconv.Atoi(strings.TrimSpace(weight))
+		if err != nil || w < 1 {
+			w = 1
+		}
+		out = append(out, SMSBalanceWeight{Name: strings.ToLower(strings.TrimSpace(name)), Weight: w})
+	}
+	return out
+}
+
+// getRouteTimeouts parses "pattern=duration" pairs. Runs of whitespace in a
+// pattern are collapsed so "GET  /health" matches the mux pattern;
+// entries with an invalid duration are skipped.
+func getRouteTimeouts(key string) map[string]time.Duration {
+	out := map[string]time.Duration{}
+	for _, item := range getList(key) {
+		pattern, value, ok := strings.Cut(item, "=")
+		if !ok {
+			continue
+		}
+		d, err := time.ParseDuration(strings.TrimSpace(value))
+		if err != nil {
+			continue
+		}
+		out[strings.Join(strings.Fields(pattern), " ")] = d
+	}
+	return out
+}
+
+// getPageSizes parses "path=default[:max]" pairs; entries with a
+// non-numeric size are skipped, and entries with a size above
+// domain.MaxPageLimit are skipped with a warning rather than quietly
+// capped.
+func getPageSizes(key string) map[string]domain.PageSize {
+	out := map[string]domain.PageSize{}
+	for _, item := range getList(key) {
+		path, value, ok := strings.Cut(item, "=")
+		if !ok {
+			continue
+		}
+		def, maxSize, hasMax := strings.Cut(value, ":")
+		var size domain.PageSize
+		var err error
+		if size.Default, err = strconv.Atoi(strings.TrimSpace(def)); err != nil {
+			continue
+		}
+		if hasMax {
+			if size.Max, err = strconv.Atoi(strings.TrimSpace(maxSize)); err != nil {
+				continue
+			}
+		}
+		if size.Default > domain.MaxPageLimit || size.Max > domain.MaxPageLimit {
+			log.Printf("[Config] Ignoring %s entry %q: page sizes may not exceed %d", key, item, domain.MaxPageLimit)
+			continue
+		}
+		out[strings.TrimSpace(path)] = size
+	}
+	return out
+}
+
 func (c *Config) PostgresDSN() string {
 	return fmt.Sprintf(
 		"host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
@@ -146,3 +668,22 @@ func (c *Config) PostgresDSN() string {
 		c.DB.SSLMode,
 	)
 }
+
+// ReadReplicaDSN returns the DSN of the read replica and whether one is configured.
+func (c *Config) ReadReplicaDSN() (string, bool) {
+	if c.DB.ReadURL != "" {
+		return c.DB.ReadURL, true
+	}
+	if c.DB.Read.Host == "" {
+		return "", false
+	}
+	return fmt.Sprintf(
+		"host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
+		c.DB.Read.Host,
+		c.DB.Read.Port,
+		c.DB.Read.User,
+		c.DB.Read.Password,
+		c.DB.Read.Name,
+		c.DB.SSLMode,
+	), true
+}
@@ -0,0 +1,25 @@
+package config
+
+import (
+	"testing"
+
+	domain "github.com/oggyb/insider-assessment/internal/domain/message"
+)
+
+func TestGetPageSizes(t *testing.T) {
+	t.Setenv("API_PAGE_SIZES", "/messages/sent=10:50, /scheduler/runs=5, /messages=50:500, /stats=200, /bad=x")
+
+	got := getPageSizes("API_PAGE_SIZES")
+	want := map[string]domain.PageSize{
+		"/messages/sent":  {Default: 10, Max: 50},
+		"/scheduler/runs": {Default: 5},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("expected sizes above %d and invalid entries to be skipped, got %v", domain.MaxPageLimit, got)
+	}
+	for path, size := range want {
+		if got[path] != size {
+			t.Errorf("%s: got %+v, want %+v", path, got[path], size)
+		}
+	}
+}
@@ -1,26 +1,73 @@
 package gormdb
 
 import (
+	"context"
+	"log"
+	"time"
+
 	"github.com/oggyb/insider-assessment/internal/db"
+	"github.com/oggyb/insider-assessment/internal/retry"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
 )
 
 type GormDB struct {
 	conn *gorm.DB
 }
 
-func New(dsn string) (*GormDB, error) {
-	conn, err := gorm.Open(postgres.Open(dsn), &gorm.Config{
+// Option customizes the gorm configuration of a connection.
+type Option func(*gorm.Config)
+
+// WithNamingStrategy names tables and indexes with namer instead of gorm's
+// default, e.g. to place them under a schema or table prefix.
+func WithNamingStrategy(namer schema.Namer) Option {
+	return func(c *gorm.Config) {
+		c.NamingStrategy = namer
+	}
+}
+
+// New opens a connection to dsn. gorm pings the database while opening,
+// so an unreachable server fails here rather than on first use.
+func New(dsn string, opts ...Option) (*GormDB, error) {
+	cfg := &gorm.Config{
 		PrepareStmt:            true,
 		SkipDefaultTransaction: true,
-	})
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	conn, err := gorm.Open(postgres.Open(dsn), cfg)
 	if err != nil {
 		return nil, err
 	}
 	return &GormDB{conn: conn}, nil
 }
 
+// NewWithRetry is New for startup: it keeps trying up to attempts times,
+// waiting backoff in between, so a database that is still starting (e.g.
+// in docker-compose) does not crash-loop the service.
+func NewWithRetry(dsn string, attempts int, backoff time.Duration, opts ...Option) (*GormDB, error) {
+	return connectWithRetry(func() (*GormDB, error) { return New(dsn, opts...) }, attempts, backoff)
+}
+
+// connectWithRetry retries connect, logging every failed attempt.
+func connectWithRetry(connect func() (*GormDB, error), attempts int, backoff time.Duration) (*GormDB, error) {
+	var g *GormDB
+	err := retry.Do(context.Background(), attempts, backoff, func(attempt int) error {
+		var err error
+		if g, err = connect(); err != nil {
+			log.Printf("[DB] Connection attempt %d/%d failed: %v", attempt, attempts, err)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
 func (g *GormDB) Conn() any {
 	return g.conn
 }
@@ -0,0 +1,29 @@
+//go:build integration
+
+package gormdb
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestNewWithRetry_ConnectsIntegration(t *testing.T) {
+	dsn := os.Getenv("TEST_DATABASE_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_DSN not set")
+	}
+
+	g, err := NewWithRetry(dsn, 3, 100*time.Millisecond)
+	if err != nil {
+		t.Fatalf("NewWithRetry: %v", err)
+	}
+	sqlDB, err := g.conn.DB()
+	if err != nil {
+		t.Fatalf("DB: %v", err)
+	}
+	t.Cleanup(func() { _ = sqlDB.Close() })
+	if err := sqlDB.Ping(); err != nil {
+		t.Fatalf("Ping: %v", err)
+	}
+}
@@ -0,0 +1,45 @@
+package gormdb
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestConnectWithRetry_ConnectsAfterTransientFailures(t *testing.T) {
+	calls := 0
+	want := &GormDB{}
+	got, err := connectWithRetry(func() (*GormDB, error) {
+		calls++
+		if calls < 3 {
+			return nil, errors.New("connection refused")
+		}
+		return want, nil
+	}, 5, time.Millisecond)
+	if err != nil {
+		t.Fatalf("connectWithRetry: %v", err)
+	}
+	if got != want || calls != 3 {
+		t.Fatalf("expected the third attempt's connection, got %v after %d calls", got, calls)
+	}
+}
+
+func TestConnectWithRetry_GivesUpAfterAttempts(t *testing.T) {
+	calls := 0
+	refused := errors.New("connection refused")
+	_, err := connectWithRetry(func() (*GormDB, error) {
+		calls++
+		return nil, refused
+	}, 3, time.Millisecond)
+	if !errors.Is(err, refused) || calls != 3 {
+		t.Fatalf("expected to fail with the last error after 3 calls, got %v after %d", err, calls)
+	}
+}
+
+func TestNewWithRetry_UnreachableServer(t *testing.T) {
+	// Nothing listens on port 1, so every attempt is refused.
+	_, err := NewWithRetry("host=127.0.0.1 port=1 user=x password=x dbname=x sslmode=disable connect_timeout=1", 2, time.Millisecond)
+	if err == nil {
+		t.Fatal("expected an unreachable database to fail")
+	}
+}
@@ -35,6 +35,170 @@ const docTemplate = `{
                 }
             }
         },
+        "/config/worker": {
+            "get": {
+                "description": "Returns the batch size, worker count and per-message timeout currently used by the batch processor.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "config"
+                ],
+                "summary": "Get worker config",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/response.WorkerConfigResponse"
+                        }
+                    }
+                }
+            },
+            "patch": {
+                "description": "Partially updates the batch processor settings. Omitted fields are left unchanged; the next batch picks up the new values. Requires the X-API-Key header.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "config"
+                ],
+                "summary": "Update worker config",
+                "parameters": [
+                    {
+                        "description": "Fields to update",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/request.WorkerConfigRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/response.WorkerConfigResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/dedup/{to}": {
+            "delete": {
+                "description": "Lets an identical message be enqueued again for the recipient (MESSAGE_DEDUP_CONTENT) by releasing the claims of its unsent messages, which stay queued. A recipient without any reports cleared=0. Requires the X-API-Key header.\nContent de-duplication is enforced by a unique index in the database, not by the cache: this clears the content hash of the recipient's PENDING and PROCESSING messages, and no cache keys are involved.\nIt does not lift MESSAGE_DEDUP_WINDOW: an identical message created within the window is still rejected with 409.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "messages"
+                ],
+                "summary": "Clear a recipient's de-duplication claims",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Recipient phone number",
+                        "name": "to",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/response.DedupClearResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/groups/{id}/status": {
+            "get": {
+                "description": "Reports how many messages of a multicast group have been created so far. Progress is kept in memory by the instance that accepted the group, for an hour after it finishes.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "messages"
+                ],
+                "summary": "Multicast group progress",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Group ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/response.GroupStatusResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
         "/health": {
             "get": {
                 "description": "Returns a basic status payload to indicate the API is running.",
@@ -55,16 +219,86 @@ const docTemplate = `{
                 }
             }
         },
-        "/messages/sent": {
+        "/health/ready": {
+            "get": {
+                "description": "Pings the database and reports whether the API can serve traffic. Unlike /health it fails while the database is unreachable.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "home"
+                ],
+                "summary": "Readiness check",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/response.HealthResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Database unreachable",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/l/{token}": {
+            "get": {
+                "description": "Counts a click on a short link written into a message by the track-links content transform (LINK_TRACKING_BASE_URL) and redirects to the URL it replaced.",
+                "tags": [
+                    "messages"
+                ],
+                "summary": "Follow a tracking link",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Link token",
+                        "name": "token",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "302": {
+                        "description": "Found"
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/messages": {
             "get": {
-                "description": "Returns a paginated list of successfully sent messages.",
+                "description": "Returns a paginated list of messages of any status, newest first.\nFilter by tags with tag.\u003ckey\u003e=\u003cvalue\u003e query parameters; all given tags must match.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
                     "messages"
                 ],
-                "summary": "List sent messages",
+                "summary": "List messages",
                 "parameters": [
                     {
                         "type": "integer",
@@ -76,16 +310,22 @@ const docTemplate = `{
                     {
                         "type": "integer",
                         "default": 20,
-                        "description": "Page size (max 100)",
+                        "description": "Page size (max 100; see API_PAGE_SIZES)",
                         "name": "limit",
                         "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Example tag filter (any tag.\u003ckey\u003e is accepted)",
+                        "name": "tag.env",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/response.SentMessagesResponse"
+                            "$ref": "#/definitions/response.MessageListResponse"
                         }
                     },
                     "500": {
@@ -98,11 +338,9 @@ const docTemplate = `{
                         }
                     }
                 }
-            }
-        },
-        "/scheduler": {
+            },
             "post": {
-                "description": "Starts or stops the background scheduler based on the given action.",
+                "description": "Enqueues a new PENDING message. An optional expiresAt (RFC3339) or ttl (e.g. \"15m\") drops the message as EXPIRED if it cannot be sent in time.\nAn optional sendTimeout (e.g. \"2s\", at least 1ms) overrides the per-message provider timeout for this message.\nAn optional encoding (GSM7 or UCS2) overrides the encoding hint sent to the provider, which is otherwise derived from the content.",
                 "consumes": [
                     "application/json"
                 ],
@@ -110,25 +348,25 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "scheduler"
+                    "messages"
                 ],
-                "summary": "Control scheduler",
+                "summary": "Create message",
                 "parameters": [
                     {
-                        "description": "Scheduler action (start|stop)",
+                        "description": "Message to enqueue",
                         "name": "request",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/request.SchedulerRequest"
+                            "$ref": "#/definitions/request.CreateMessageRequest"
                         }
                     }
                 ],
                 "responses": {
-                    "200": {
-                        "description": "OK",
+                    "201": {
+                        "description": "Created",
                         "schema": {
-                            "$ref": "#/definitions/response.SchedulerControlResponse"
+                            "$ref": "#/definitions/response.MessageResponse"
                         }
                     },
                     "400": {
@@ -139,34 +377,1246 @@ const docTemplate = `{
                                 "type": "string"
                             }
                         }
+                    },
+                    "409": {
+                        "description": "Identical message already pending (MESSAGE_DEDUP_CONTENT) or sent or queued within MESSAGE_DEDUP_WINDOW",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "503": {
+                        "description": "Too many concurrent creates (MESSAGE_CREATE_CONCURRENCY)",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/messages/export": {
+            "get": {
+                "description": "Streams every message created between from and until (UTC days, inclusive) as CSV, oldest first, without pagination.\nDefaults to the last 7 days; the range may span at most 92 days. Requires the X-API-Key header.",
+                "produces": [
+                    "text/csv"
+                ],
+                "tags": [
+                    "messages"
+                ],
+                "summary": "Export messages as CSV",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "First day (YYYY-MM-DD)",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Last day (YYYY-MM-DD), defaults to today",
+                        "name": "until",
+                        "in": "query"
                     }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "CSV file",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/messages/multicast": {
+            "post": {
+                "description": "Enqueues one PENDING message per recipient, tagged group=\u003cid\u003e. The optional fields behave as in POST /messages and apply to every recipient.\nUp to MULTICAST_ASYNC_THRESHOLD recipients the messages are created before responding (201). Larger groups, up to MULTICAST_MAX_RECIPIENTS, are accepted with 202 and created in the background; follow them with GET /groups/{id}/status.\nRecipients whose message cannot be saved (e.g. duplicates) are counted as failed without stopping the group.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "messages"
+                ],
+                "summary": "Send a message to several recipients",
+                "parameters": [
+                    {
+                        "description": "Message and recipients",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/request.MulticastRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/response.MulticastResponse"
+                        }
+                    },
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "$ref": "#/definitions/response.GroupStatusResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "503": {
+                        "description": "Too many groups or creates in progress",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/messages/sent": {
+            "get": {
+                "description": "Returns a paginated list of successfully sent messages.\nPages past the last page are flagged with outOfRange, or return 404 when strict pagination is enabled.\nWith format=ndjson, every sent message is streamed instead, one JSON object per line without the envelope; page and limit are ignored.",
+                "produces": [
+                    "application/json",
+                    "application/x-ndjson"
+                ],
+                "tags": [
+                    "messages"
+                ],
+                "summary": "List sent messages",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "default": 1,
+                        "description": "Page number",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 20,
+                        "description": "Page size (max 100; see API_PAGE_SIZES)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "json (default) or ndjson",
+                        "name": "format",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/response.SentMessagesResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/messages/{id}": {
+            "delete": {
+                "description": "Soft-deletes a message: it disappears from listings and is never sent, but its row is kept. Requires the X-API-Key header.",
+                "tags": [
+                    "messages"
+                ],
+                "summary": "Delete a message",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Message ID (UUID)",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/messages/{id}/retry": {
+            "post": {
+                "description": "Requeues a single FAILED message as PENDING (resetting retryCount) so the next batch sends it again; answers 409 if it is not, or no longer, FAILED. Requires the X-API-Key header.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "messages"
+                ],
+                "summary": "Retry a failed message",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Message ID (UUID)",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/response.MessageResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/messages/{id}/timeline": {
+            "get": {
+                "description": "Returns every status transition of a message (from, to, at), oldest first.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "messages"
+                ],
+                "summary": "Message status timeline",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Message ID (UUID)",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/response.TimelineResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/optout/{token}": {
+            "get": {
+                "description": "Unsubscribes the recipient whose personal token is in the link appended to their messages (OPT_OUT_LINK_BASE_URL). Following the link again keeps the first opt-out time.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "messages"
+                ],
+                "summary": "Follow an opt-out link",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Opt-out token",
+                        "name": "token",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/response.OptOutResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/scheduler": {
+            "post": {
+                "description": "Starts or stops the background scheduler based on the given action.\nThe response reports the prior (wasRunning) and new (running) state. Starting a running or stopping a stopped scheduler changes nothing and answers 409.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "scheduler"
+                ],
+                "summary": "Control scheduler",
+                "parameters": [
+                    {
+                        "description": "Scheduler action (start|stop)",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/request.SchedulerRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/response.SchedulerControlResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "409": {
+                        "description": "Scheduler disabled (SCHEDULER_ENABLED=false), already running or already stopped",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "503": {
+                        "description": "Scheduler closed (the process is shutting down)",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/scheduler/events": {
+            "get": {
+                "description": "Returns what the scheduler of this process did lately (starts, stops, batch runs with their counts, failures and interval changes), most recent first.\nThe activity is kept in memory, so it only covers this process since it started and holds at most SCHEDULER_EVENTS_SIZE entries.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "scheduler"
+                ],
+                "summary": "Recent scheduler activity",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/response.SchedulerEventListResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/scheduler/runs": {
+            "get": {
+                "description": "Returns a paginated history of batch runs (start, duration, processed, succeeded and failed counts), most recent first.\nRuns are read from the database, so this also works when SCHEDULER_ENABLED=false and another process runs the batches.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "scheduler"
+                ],
+                "summary": "List scheduler batch runs",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "default": 1,
+                        "description": "Page number",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 20,
+                        "description": "Page size (max 100; see API_PAGE_SIZES)",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/response.BatchRunListResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/scheduler/status": {
+            "get": {
+                "description": "Reports whether this process's scheduler is running and, with SCHEDULER_LEADER_ELECTION, whether it currently holds the leadership lease.\nOnly the leader runs batches; the other replicas stand by and take over once its lease expires.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "scheduler"
+                ],
+                "summary": "Scheduler status",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/response.SchedulerStatusResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/stats/latency": {
+            "get": {
+                "description": "Returns the average and 95th percentile provider call duration of messages sent between from (inclusive) and until (exclusive).\nDefaults to the last 24 hours; the window may span at most 92 days.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "stats"
+                ],
+                "summary": "Send latency percentiles",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Window start (RFC3339)",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Window end (RFC3339), defaults to now",
+                        "name": "until",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/response.LatencyResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/stats/pending": {
+            "get": {
+                "description": "Returns how long the oldest PENDING message has been waiting (0 when none is pending).\nhealthy is false once it exceeds PENDING_AGE_ALERT, which also publishes a StalePending event.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "stats"
+                ],
+                "summary": "Oldest pending message age",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/response.PendingAgeResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/stats/report": {
+            "get": {
+                "description": "Returns sent and failed message counts per UTC day between from and until (inclusive).\nDefaults to the last 7 days; the range may span at most 92 days.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "stats"
+                ],
+                "summary": "Daily send report",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "First day (YYYY-MM-DD)",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Last day (YYYY-MM-DD), defaults to today",
+                        "name": "until",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/response.ReportResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/stats/top-recipients": {
+            "get": {
+                "description": "Ranks recipients by the number of messages created for them between from (inclusive) and until (exclusive), e.g. to spot abuse.\nDefaults to the last 24 hours and the top 10; the window may span at most 92 days and limit may be at most 100.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "stats"
+                ],
+                "summary": "Recipients with the most messages",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Window start (RFC3339)",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Window end (RFC3339), defaults to now",
+                        "name": "until",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 10,
+                        "description": "Number of recipients (max 100)",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/response.TopRecipientsResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "request.CreateMessageRequest": {
+            "type": "object",
+            "properties": {
+                "content": {
+                    "type": "string"
+                },
+                "encoding": {
+                    "description": "Encoding optionally overrides the encoding hint sent to the provider\n(\"GSM7\" or \"UCS2\"); by default it is derived from the content.",
+                    "type": "string"
+                },
+                "expiresAt": {
+                    "description": "ExpiresAt is an optional absolute expiry (RFC3339).",
+                    "type": "string"
+                },
+                "provider": {
+                    "description": "Provider optionally routes the message to a named SMS provider.",
+                    "type": "string"
+                },
+                "sendTimeout": {
+                    "description": "SendTimeout optionally overrides the per-message send timeout as a Go\nduration of at least 1ms (e.g. \"2s\"), for messages that should fail\nfast.",
+                    "type": "string"
+                },
+                "tags": {
+                    "description": "Tags are optional labels (e.g. {\"env\": \"staging\"}) for later filtering.",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "to": {
+                    "type": "string"
+                },
+                "ttl": {
+                    "description": "TTL is an optional relative expiry as a Go duration (e.g. \"15m\").\nOnly one of ExpiresAt and TTL may be set.",
+                    "type": "string"
+                }
+            }
+        },
+        "request.MulticastRequest": {
+            "type": "object",
+            "properties": {
+                "content": {
+                    "type": "string"
+                },
+                "encoding": {
+                    "type": "string"
+                },
+                "expiresAt": {
+                    "type": "string"
+                },
+                "provider": {
+                    "type": "string"
+                },
+                "sendTimeout": {
+                    "type": "string"
+                },
+                "tags": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "to": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "ttl": {
+                    "type": "string"
+                }
+            }
+        },
+        "request.SchedulerRequest": {
+            "type": "object",
+            "properties": {
+                "action": {
+                    "description": "Action controls the scheduler. Allowed values:\n- \"start\": start processing batches\n- \"stop\":  stop processing batches",
+                    "type": "string"
+                }
+            }
+        },
+        "request.WorkerConfigRequest": {
+            "type": "object",
+            "properties": {
+                "batchSize": {
+                    "type": "integer"
+                },
+                "maxWorkers": {
+                    "type": "integer"
+                },
+                "perMessageTimeout": {
+                    "description": "PerMessageTimeout is a Go duration string (e.g. \"5s\").",
+                    "type": "string"
+                }
+            }
+        },
+        "response.BatchRunDTO": {
+            "type": "object",
+            "properties": {
+                "durationMs": {
+                    "type": "integer"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "failed": {
+                    "type": "integer"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "processed": {
+                    "type": "integer"
+                },
+                "startedAt": {
+                    "type": "string"
+                },
+                "succeeded": {
+                    "type": "integer"
+                },
+                "workers": {
+                    "type": "integer"
+                }
+            }
+        },
+        "response.BatchRunListPayload": {
+            "type": "object",
+            "properties": {
+                "items": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/response.BatchRunDTO"
+                    }
+                },
+                "limit": {
+                    "type": "integer"
+                },
+                "page": {
+                    "type": "integer"
+                },
+                "total": {
+                    "type": "integer"
+                }
+            }
+        },
+        "response.BatchRunListResponse": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "$ref": "#/definitions/response.BatchRunListPayload"
+                },
+                "success": {
+                    "type": "boolean"
+                },
+                "timestamp": {
+                    "type": "string"
+                }
+            }
+        },
+        "response.DayStatDTO": {
+            "type": "object",
+            "properties": {
+                "day": {
+                    "type": "string"
+                },
+                "failed": {
+                    "type": "integer"
+                },
+                "sent": {
+                    "type": "integer"
+                }
+            }
+        },
+        "response.DedupClearPayload": {
+            "type": "object",
+            "properties": {
+                "cleared": {
+                    "type": "integer"
+                },
+                "to": {
+                    "type": "string"
+                }
+            }
+        },
+        "response.DedupClearResponse": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "$ref": "#/definitions/response.DedupClearPayload"
+                },
+                "success": {
+                    "type": "boolean"
+                },
+                "timestamp": {
+                    "type": "string"
+                }
+            }
+        },
+        "response.GroupStatusPayload": {
+            "type": "object",
+            "properties": {
+                "created": {
+                    "type": "integer"
+                },
+                "done": {
+                    "type": "boolean"
+                },
+                "failed": {
+                    "type": "integer"
+                },
+                "finishedAt": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "startedAt": {
+                    "type": "string"
+                },
+                "total": {
+                    "type": "integer"
+                }
+            }
+        },
+        "response.GroupStatusResponse": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "$ref": "#/definitions/response.GroupStatusPayload"
+                },
+                "success": {
+                    "type": "boolean"
+                },
+                "timestamp": {
+                    "type": "string"
+                }
+            }
+        },
+        "response.HealthPayload": {
+            "type": "object",
+            "properties": {
+                "status": {
+                    "type": "string"
+                }
+            }
+        },
+        "response.HealthResponse": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "$ref": "#/definitions/response.HealthPayload"
+                },
+                "success": {
+                    "type": "boolean"
+                },
+                "timestamp": {
+                    "type": "string"
+                }
+            }
+        },
+        "response.LatencyPayload": {
+            "type": "object",
+            "properties": {
+                "avgMs": {
+                    "type": "number"
+                },
+                "from": {
+                    "type": "string"
+                },
+                "p95Ms": {
+                    "type": "number"
+                },
+                "until": {
+                    "type": "string"
+                }
+            }
+        },
+        "response.LatencyResponse": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "$ref": "#/definitions/response.LatencyPayload"
+                },
+                "success": {
+                    "type": "boolean"
+                },
+                "timestamp": {
+                    "type": "string"
+                }
+            }
+        },
+        "response.MessageDTO": {
+            "type": "object",
+            "properties": {
+                "content": {
+                    "type": "string"
+                },
+                "createdAt": {
+                    "type": "string"
+                },
+                "encoding": {
+                    "type": "string"
+                },
+                "expiresAt": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "messageId": {
+                    "type": "string"
+                },
+                "provider": {
+                    "type": "string"
+                },
+                "retryCount": {
+                    "type": "integer"
+                },
+                "segments": {
+                    "description": "Segments is how many SMS parts the content is sent as.",
+                    "type": "integer"
+                },
+                "sendDurationMs": {
+                    "description": "SendDurationMs is the provider round-trip time of the last send,\npresent only with RESPONSE_SEND_DURATION enabled.",
+                    "type": "integer"
+                },
+                "sentAt": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "statusReason": {
+                    "type": "string"
+                },
+                "tags": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "to": {
+                    "type": "string"
+                },
+                "updatedAt": {
+                    "type": "string"
+                }
+            }
+        },
+        "response.MessageListPayload": {
+            "type": "object",
+            "properties": {
+                "items": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/response.MessageDTO"
+                    }
+                },
+                "limit": {
+                    "type": "integer"
+                },
+                "page": {
+                    "type": "integer"
+                },
+                "total": {
+                    "type": "integer"
                 }
             }
-        }
-    },
-    "definitions": {
-        "request.SchedulerRequest": {
+        },
+        "response.MessageListResponse": {
             "type": "object",
             "properties": {
-                "action": {
-                    "description": "Action controls the scheduler. Allowed values:\n- \"start\": start processing batches\n- \"stop\":  stop processing batches",
+                "data": {
+                    "$ref": "#/definitions/response.MessageListPayload"
+                },
+                "success": {
+                    "type": "boolean"
+                },
+                "timestamp": {
                     "type": "string"
                 }
             }
         },
-        "response.HealthPayload": {
+        "response.MessageResponse": {
             "type": "object",
             "properties": {
-                "status": {
+                "data": {
+                    "$ref": "#/definitions/response.MessageDTO"
+                },
+                "success": {
+                    "type": "boolean"
+                },
+                "timestamp": {
                     "type": "string"
                 }
             }
         },
-        "response.HealthResponse": {
+        "response.MulticastPayload": {
+            "type": "object",
+            "properties": {
+                "group": {
+                    "$ref": "#/definitions/response.GroupStatusPayload"
+                },
+                "messages": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/response.MessageDTO"
+                    }
+                }
+            }
+        },
+        "response.MulticastResponse": {
             "type": "object",
             "properties": {
                 "data": {
-                    "$ref": "#/definitions/response.HealthPayload"
+                    "$ref": "#/definitions/response.MulticastPayload"
                 },
                 "success": {
                     "type": "boolean"
@@ -176,31 +1626,104 @@ const docTemplate = `{
                 }
             }
         },
-        "response.MessageDTO": {
+        "response.OptOutPayload": {
             "type": "object",
             "properties": {
-                "content": {
+                "message": {
                     "type": "string"
                 },
-                "createdAt": {
+                "optedOutAt": {
                     "type": "string"
+                }
+            }
+        },
+        "response.OptOutResponse": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "$ref": "#/definitions/response.OptOutPayload"
                 },
-                "id": {
+                "success": {
+                    "type": "boolean"
+                },
+                "timestamp": {
                     "type": "string"
+                }
+            }
+        },
+        "response.PendingAgePayload": {
+            "type": "object",
+            "properties": {
+                "alertThresholdSeconds": {
+                    "description": "AlertThresholdSeconds is 0 when no alert is configured.",
+                    "type": "number"
                 },
-                "messageId": {
+                "healthy": {
+                    "type": "boolean"
+                },
+                "oldestPendingAgeSeconds": {
+                    "type": "number"
+                }
+            }
+        },
+        "response.PendingAgeResponse": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "$ref": "#/definitions/response.PendingAgePayload"
+                },
+                "success": {
+                    "type": "boolean"
+                },
+                "timestamp": {
                     "type": "string"
+                }
+            }
+        },
+        "response.RecipientCountDTO": {
+            "type": "object",
+            "properties": {
+                "count": {
+                    "type": "integer"
                 },
-                "sentAt": {
+                "to": {
                     "type": "string"
+                }
+            }
+        },
+        "response.ReportPayload": {
+            "type": "object",
+            "properties": {
+                "days": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/response.DayStatDTO"
+                    }
                 },
-                "status": {
+                "from": {
                     "type": "string"
                 },
-                "to": {
+                "totalFailed": {
+                    "type": "integer"
+                },
+                "totalSent": {
+                    "type": "integer"
+                },
+                "until": {
                     "type": "string"
+                }
+            }
+        },
+        "response.ReportResponse": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "$ref": "#/definitions/response.ReportPayload"
                 },
-                "updatedAt": {
+                "success": {
+                    "type": "boolean"
+                },
+                "timestamp": {
                     "type": "string"
                 }
             }
@@ -210,6 +1733,12 @@ const docTemplate = `{
             "properties": {
                 "message": {
                     "type": "string"
+                },
+                "running": {
+                    "type": "boolean"
+                },
+                "wasRunning": {
+                    "type": "boolean"
                 }
             }
         },
@@ -227,6 +1756,91 @@ const docTemplate = `{
                 }
             }
         },
+        "response.SchedulerEventDTO": {
+            "type": "object",
+            "properties": {
+                "at": {
+                    "type": "string"
+                },
+                "durationMs": {
+                    "type": "integer"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "failed": {
+                    "type": "integer"
+                },
+                "kind": {
+                    "type": "string"
+                },
+                "message": {
+                    "type": "string"
+                },
+                "processed": {
+                    "type": "integer"
+                },
+                "succeeded": {
+                    "type": "integer"
+                }
+            }
+        },
+        "response.SchedulerEventListPayload": {
+            "type": "object",
+            "properties": {
+                "items": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/response.SchedulerEventDTO"
+                    }
+                }
+            }
+        },
+        "response.SchedulerEventListResponse": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "$ref": "#/definitions/response.SchedulerEventListPayload"
+                },
+                "success": {
+                    "type": "boolean"
+                },
+                "timestamp": {
+                    "type": "string"
+                }
+            }
+        },
+        "response.SchedulerStatusPayload": {
+            "type": "object",
+            "properties": {
+                "instanceId": {
+                    "type": "string"
+                },
+                "leader": {
+                    "type": "boolean"
+                },
+                "leaderElection": {
+                    "type": "boolean"
+                },
+                "running": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "response.SchedulerStatusResponse": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "$ref": "#/definitions/response.SchedulerStatusPayload"
+                },
+                "success": {
+                    "type": "boolean"
+                },
+                "timestamp": {
+                    "type": "string"
+                }
+            }
+        },
         "response.SentMessagesPayload": {
             "type": "object",
             "properties": {
@@ -239,6 +1853,9 @@ const docTemplate = `{
                 "limit": {
                     "type": "integer"
                 },
+                "outOfRange": {
+                    "type": "boolean"
+                },
                 "page": {
                     "type": "integer"
                 },
@@ -261,6 +1878,79 @@ const docTemplate = `{
                 }
             }
         },
+        "response.StatusEventDTO": {
+            "type": "object",
+            "properties": {
+                "at": {
+                    "type": "string"
+                },
+                "from": {
+                    "type": "string"
+                },
+                "to": {
+                    "type": "string"
+                }
+            }
+        },
+        "response.TimelinePayload": {
+            "type": "object",
+            "properties": {
+                "events": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/response.StatusEventDTO"
+                    }
+                },
+                "messageId": {
+                    "type": "string"
+                }
+            }
+        },
+        "response.TimelineResponse": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "$ref": "#/definitions/response.TimelinePayload"
+                },
+                "success": {
+                    "type": "boolean"
+                },
+                "timestamp": {
+                    "type": "string"
+                }
+            }
+        },
+        "response.TopRecipientsPayload": {
+            "type": "object",
+            "properties": {
+                "from": {
+                    "type": "string"
+                },
+                "recipients": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/response.RecipientCountDTO"
+                    }
+                },
+                "until": {
+                    "type": "string"
+                }
+            }
+        },
+        "response.TopRecipientsResponse": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "$ref": "#/definitions/response.TopRecipientsPayload"
+                },
+                "success": {
+                    "type": "boolean"
+                },
+                "timestamp": {
+                    "type": "string"
+                }
+            }
+        },
         "response.WelcomePayload": {
             "type": "object",
             "properties": {
@@ -282,6 +1972,34 @@ const docTemplate = `{
                     "type": "string"
                 }
             }
+        },
+        "response.WorkerConfigPayload": {
+            "type": "object",
+            "properties": {
+                "batchSize": {
+                    "type": "integer"
+                },
+                "maxWorkers": {
+                    "type": "integer"
+                },
+                "perMessageTimeout": {
+                    "type": "string"
+                }
+            }
+        },
+        "response.WorkerConfigResponse": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "$ref": "#/definitions/response.WorkerConfigPayload"
+                },
+                "success": {
+                    "type": "boolean"
+                },
+                "timestamp": {
+                    "type": "string"
+                }
+            }
         }
     }
 }`
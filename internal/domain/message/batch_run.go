@@ -0,0 +1,33 @@
+package message
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// BatchResult summarises a single ProcessBatch run.
+type BatchResult struct {
+	StartedAt time.Time
+	Duration  time.Duration
+
+	// Processed is the number of messages handed to workers; Succeeded and
+	// Failed break it down by outcome.
+	Processed int
+	Succeeded int
+	Failed    int
+
+	// Workers is the number of worker goroutines the batch started. It is
+	// never more than Processed plus undispatched messages, and 0 for a
+	// batch that found nothing to send.
+	Workers int
+
+	// Error is set when the batch as a whole failed.
+	Error string
+}
+
+// BatchRun is a recorded BatchResult.
+type BatchRun struct {
+	ID uuid.UUID
+	BatchResult
+}
@@ -0,0 +1,95 @@
+package message
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf16"
+)
+
+// gsm7Basic is the GSM 03.38 default alphabet (minus the escape character).
+const gsm7Basic = "@£$¥èéùìòÇ\nØø\rÅåΔ_ΦΓΛΩΠΨΣΘΞÆæßÉ !\"#¤%&'()*+,-./0123456789:;<=>?" +
+	"¡ABCDEFGHIJKLMNOPQRSTUVWXYZÄÖÑÜ§¿abcdefghijklmnopqrstuvwxyzäöñüà"
+
+// gsm7Extension holds characters reachable through the GSM 03.38 escape
+// sequence. They are valid but count as two septets each.
+const gsm7Extension = "\f^{}\\[~]|€"
+
+// gsm7Charset is the lookup table built from the basic and extension sets.
+var gsm7Charset = func() map[rune]struct{} {
+	set := make(map[rune]struct{}, len(gsm7Basic)+len(gsm7Extension))
+	for _, r := range gsm7Basic + gsm7Extension {
+		set[r] = struct{}{}
+	}
+	return set
+}()
+
+// IsGSM7 reports whether every character of s can be encoded in the
+// GSM-7 alphabet (including the extension table).
+func IsGSM7(s string) bool {
+	for _, r := range s {
+		if _, ok := gsm7Charset[r]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// Encoding is the data coding hint passed to the provider so that content
+// in non-Latin scripts renders correctly.
+type Encoding string
+
+const (
+	// EncodingGSM7 is the default 7-bit GSM alphabet.
+	EncodingGSM7 Encoding = "GSM7"
+	// EncodingUCS2 is 16-bit UCS-2, needed for anything outside GSM-7
+	// (e.g. Turkish ş, ğ, ı or emoji).
+	EncodingUCS2 Encoding = "UCS2"
+)
+
+// ClassifyEncoding returns the encoding content needs: EncodingGSM7 if every
+// character is in the GSM-7 alphabet, EncodingUCS2 otherwise.
+func ClassifyEncoding(content string) Encoding {
+	if IsGSM7(content) {
+		return EncodingGSM7
+	}
+	return EncodingUCS2
+}
+
+// ParseEncoding resolves an encoding name case-insensitively, accepting
+// "UCS-2" and "GSM-7" spellings too.
+func ParseEncoding(name string) (Encoding, error) {
+	switch e := Encoding(strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(name), "-", ""))); e {
+	case EncodingGSM7, EncodingUCS2:
+		return e, nil
+	default:
+		return "", fmt.Errorf("%w: %q (want GSM7 or UCS2)", ErrInvalidEncoding, name)
+	}
+}
+
+// Segments returns how many SMS parts content is split into: up to 160
+// septets in one part or 153 per part when concatenated for GSM-7 (where
+// extension characters take two septets), and 70 or 67 UTF-16 code units
+// for UCS-2. Empty content has no parts.
+func Segments(content string) int {
+	if content == "" {
+		return 0
+	}
+
+	single, multi, units := 160, 153, 0
+	if IsGSM7(content) {
+		for _, r := range content {
+			units++
+			if strings.ContainsRune(gsm7Extension, r) {
+				units++
+			}
+		}
+	} else {
+		single, multi = 70, 67
+		units = len(utf16.Encode([]rune(content)))
+	}
+
+	if units <= single {
+		return 1
+	}
+	return (units + multi - 1) / multi
+}
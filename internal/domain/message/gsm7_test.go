@@ -0,0 +1,94 @@
+package message
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestIsGSM7(t *testing.T) {
+	cases := map[string]bool{
+		"Hello, your code is 1234.": true,
+		"Prix: 5€ [promo] {50%}":    true,
+		"Ünlü café à Ñ, £10 @ÆØ§":   true,
+		"Merhaba, şifreniz: 1234":   false, // ş
+		"İstanbul'a hoş geldiniz":   false, // İ, ş
+		"Great news 🎉":              false,
+		"ğ":                         false,
+	}
+
+	for s, want := range cases {
+		if got := IsGSM7(s); got != want {
+			t.Fatalf("IsGSM7(%q) = %v, want %v", s, got, want)
+		}
+	}
+}
+
+func TestNewMessage_EnforceGSM7(t *testing.T) {
+	rules := ContentRules{EnforceGSM7: true}
+
+	if _, err := rules.NewMessage("+905000000000", "Your code is 1234"); err != nil {
+		t.Fatalf("expected GSM-7 content to pass, got %v", err)
+	}
+	if _, err := rules.NewMessage("+905000000000", "Şifreniz: 1234"); err != ErrNonGSM7Content {
+		t.Fatalf("expected ErrNonGSM7Content for Turkish content, got %v", err)
+	}
+	if _, err := rules.NewMessage("+905000000000", "Thanks 🙏"); err != ErrNonGSM7Content {
+		t.Fatalf("expected ErrNonGSM7Content for emoji content, got %v", err)
+	}
+}
+
+func TestNewMessage_GSM7NotEnforcedByDefault(t *testing.T) {
+	if _, err := NewMessage("+905000000000", "Şifreniz: 1234 🙏"); err != nil {
+		t.Fatalf("expected non-GSM content to be accepted when enforcement is off, got %v", err)
+	}
+}
+
+func TestClassifyEncoding(t *testing.T) {
+	cases := map[string]Encoding{
+		"Hello, your code is 1234.": EncodingGSM7,
+		"Ünlü café à Ñ":             EncodingGSM7,
+		"Merhaba, şifreniz: 1234":   EncodingUCS2,
+		"Iğdır'a hoş geldiniz":      EncodingUCS2,
+		"Great news 🎉":              EncodingUCS2,
+	}
+	for s, want := range cases {
+		if got := ClassifyEncoding(s); got != want {
+			t.Fatalf("ClassifyEncoding(%q) = %s, want %s", s, got, want)
+		}
+	}
+}
+
+func TestParseEncoding(t *testing.T) {
+	for in, want := range map[string]Encoding{"gsm7": EncodingGSM7, "GSM-7": EncodingGSM7, " ucs-2 ": EncodingUCS2, "UCS2": EncodingUCS2} {
+		if got, err := ParseEncoding(in); err != nil || got != want {
+			t.Fatalf("ParseEncoding(%q) = %s, %v; want %s", in, got, err, want)
+		}
+	}
+	if _, err := ParseEncoding("latin1"); !errors.Is(err, ErrInvalidEncoding) {
+		t.Fatalf("expected ErrInvalidEncoding, got %v", err)
+	}
+}
+
+func TestSegments(t *testing.T) {
+	cases := []struct {
+		name    string
+		content string
+		want    int
+	}{
+		{"empty", "", 0},
+		{"short", "hello", 1},
+		{"gsm7 single", strings.Repeat("a", 160), 1},
+		{"gsm7 concatenated", strings.Repeat("a", 161), 2},
+		{"gsm7 three parts", strings.Repeat("a", 307), 3},
+		{"extension counts twice", strings.Repeat("€", 81), 2},
+		{"ucs2 single", strings.Repeat("ş", 70), 1},
+		{"ucs2 concatenated", strings.Repeat("ş", 71), 2},
+		{"surrogate pairs", strings.Repeat("🎉", 35), 1},
+	}
+	for _, tc := range cases {
+		if got := Segments(tc.content); got != tc.want {
+			t.Errorf("%s: Segments = %d, want %d", tc.name, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package message
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// linkTokenBytes is the entropy of a tracking link token; base64url encoded
+// it is 11 characters.
+const linkTokenBytes = 8
+
+// ErrLinkNotFound is returned when a tracking link carries an unknown
+// token.
+var ErrLinkNotFound = errors.New("link not found")
+
+// ErrInvalidLinkURL is returned for link targets that are not absolute
+// http(s) URLs.
+var ErrInvalidLinkURL = errors.New("link target must be an absolute http or https URL")
+
+// Link maps the token of a short tracking URL to the URL it replaced in a
+// message, and counts how often it was followed.
+type Link struct {
+	Token     string
+	URL       string
+	MessageID uuid.UUID
+	CreatedAt time.Time
+	Clicks    int64
+	// FirstClickedAt and LastClickedAt are nil until the link is followed.
+	FirstClickedAt *time.Time
+	LastClickedAt  *time.Time
+}
+
+// NewLink creates a link with a random, URL-safe token pointing at target,
+// as found in the message messageID.
+func NewLink(target string, messageID uuid.UUID) (*Link, error) {
+	u, err := url.Parse(strings.TrimSpace(target))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, ErrInvalidLinkURL
+	}
+
+	b := make([]byte, linkTokenBytes)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	return &Link{
+		Token:     base64.RawURLEncoding.EncodeToString(b),
+		URL:       u.String(),
+		MessageID: messageID,
+		CreatedAt: time.Now(),
+	}, nil
+}
+
+// LinkRepository stores tracking links and records their clicks.
+type LinkRepository interface {
+	// SaveLink persists a new link.
+	SaveLink(ctx context.Context, l *Link) error
+
+	// RecordLinkClick counts a click on the link behind token at at and
+	// returns the updated link, or ErrLinkNotFound.
+	RecordLinkClick(ctx context.Context, token string, at time.Time) (*Link, error)
+}
@@ -3,22 +3,44 @@ package message
 
 import (
 	"errors"
+	"fmt"
 	"github.com/google/uuid"
 	"strings"
 	"time"
+	"unicode/utf8"
 )
 
 const (
 	// MaxContentLength is the maximum allowed length for message content.
 	MaxContentLength = 255
+
+	// MaxPageLimit is the largest page size any listing query will honor.
+	// Handlers clamp to it, and repositories enforce it again as a safety net.
+	MaxPageLimit = 100
+
+	// MaxTags is the maximum number of tags a single message may carry.
+	MaxTags = 20
+	// MaxTagLength is the maximum length of a tag key or value.
+	MaxTagLength = 64
 )
 
+// PageSize is the default and maximum limit of a list endpoint. Zero
+// values keep the endpoint's built-in default and MaxPageLimit.
+type PageSize struct {
+	Default int
+	Max     int
+}
+
 type Status string
 
 const (
 	StatusPending Status = "PENDING"
-	StatusSuccess Status = "SUCCESS"
-	StatusFailed  Status = "FAILED"
+	// StatusProcessing marks a message claimed by a batch whose send has not
+	// been confirmed yet.
+	StatusProcessing Status = "PROCESSING"
+	StatusSuccess    Status = "SUCCESS"
+	StatusFailed     Status = "FAILED"
+	StatusExpired    Status = "EXPIRED"
 )
 
 var (
@@ -26,10 +48,50 @@ var (
 	ErrEmptyRecipient = errors.New("recipient phone number is required")
 	// ErrEmptyContent is returned when the message body is empty.
 	ErrEmptyContent = errors.New("message content is required")
+	// ErrContentTooShort is returned when the message body, after trimming,
+	// has fewer than ContentRules.MinLength characters.
+	ErrContentTooShort = errors.New("message content is too short")
 	// ErrContentTooLong is returned when the message body exceeds MaxContentLength.
 	ErrContentTooLong = errors.New("message content exceeds maximum length")
+	// ErrNonGSM7Content is returned when GSM-7 is enforced and the content has
+	// characters outside the GSM-7 alphabet.
+	ErrNonGSM7Content = errors.New("message content contains characters outside the GSM-7 alphabet")
+	// ErrInvalidEncoding is returned for an unknown encoding name.
+	ErrInvalidEncoding = errors.New("invalid message encoding")
+	// ErrExpiryInPast is returned when a message is created with an expiry that has already passed.
+	ErrExpiryInPast = errors.New("message expiry must be in the future")
+	// ErrInvalidTags is returned when tags exceed MaxTags, have an empty key, or
+	// have a key or value longer than MaxTagLength.
+	ErrInvalidTags = errors.New("invalid message tags")
+	// ErrNotRetryable is returned when requeueing a message that is not FAILED.
+	ErrNotRetryable = errors.New("only FAILED messages can be retried")
 )
 
+// ContentRules are the configurable rules for message content, applied on
+// top of MaxContentLength wherever content is built or changed. The zero
+// value keeps content as submitted, without prefix or footer, and accepts
+// any alphabet and any non-empty content.
+type ContentRules struct {
+	// MinLength is the fewest characters the trimmed content must have,
+	// before Prefix/Footer are added, for providers that reject very short
+	// messages. Values below 1 behave like 1.
+	MinLength int
+
+	// Prefix and Footer are added before and after the content, separated
+	// by a space (e.g. an opt-out footer such as "Reply STOP to
+	// unsubscribe"). They count toward MaxContentLength.
+	Prefix, Footer string
+
+	// NormalizeLineEndings converts "\r\n" and "\r" to "\n" before the
+	// length checks, so Windows line breaks do not cost an extra character
+	// each and inflate the segment count.
+	NormalizeLineEndings bool
+
+	// EnforceGSM7 rejects content that cannot be encoded in GSM-7, for
+	// carriers that silently drop other characters.
+	EnforceGSM7 bool
+}
+
 // Message is the core domain entity representing an outgoing SMS message.
 type Message struct {
 	ID          uuid.UUID
@@ -41,30 +103,250 @@ type Message struct {
 	SentAt      *time.Time
 	CreatedAt   time.Time
 	UpdatedAt   time.Time
+
+	// ExpiresAt is the optional point in time after which the message is no
+	// longer worth sending. Nil means the message never expires.
+	ExpiresAt *time.Time
+	// StatusReason is a short, human-readable explanation of the current
+	// status (e.g. why a message was expired).
+	StatusReason string
+	// Provider optionally names the SMS provider this message must be sent
+	// through. Empty means the default provider.
+	Provider string
+	// RetryCount is the number of automatic send attempts made since the
+	// message was last (re)queued.
+	RetryCount int
+	// NextRetryAt is when a message put back to PENDING after a failed
+	// attempt may be picked up again. Nil means it is due immediately.
+	NextRetryAt *time.Time
+	// Tags are free-form labels (e.g. env=staging) used for filtering.
+	// They also fill the placeholders of the message's template.
+	Tags map[string]string
+	// SendTimeout optionally overrides the service-wide per-message send
+	// timeout, e.g. to fail latency-sensitive OTPs fast.
+	SendTimeout *time.Duration
+	// Encoding optionally overrides the encoding hint sent to the provider.
+	// Empty means it is derived from the content (see ClassifyEncoding).
+	Encoding Encoding
+	// ProviderCode is the result or error code the provider returned with
+	// the last attempt (see ParseProviderCode), e.g. to group failures by
+	// reason. Empty when the response carried none.
+	ProviderCode string
+	// SendDuration is how long the provider call of the last send attempt
+	// took. Nil until the message has been handed to a provider.
+	SendDuration *time.Duration
+	// TemplateID optionally references a Template whose body replaces
+	// Content at send time. Content then only serves as a preview.
+	TemplateID *uuid.UUID
 }
 
-// NewMessage constructs a new pending Message and enforces basic domain rules.
-func NewMessage(to, content string) (*Message, error) {
-	to = strings.TrimSpace(to)
-	content = strings.TrimSpace(content)
+// Option customizes optional fields of a Message at construction time.
+type Option func(*Message)
+
+// WithExpiresAt sets an absolute expiry on the message.
+func WithExpiresAt(t time.Time) Option {
+	return func(m *Message) {
+		m.ExpiresAt = &t
+	}
+}
+
+// WithTTL sets the expiry relative to the message creation time.
+// A non-positive ttl leaves the message without an expiry.
+func WithTTL(ttl time.Duration) Option {
+	return func(m *Message) {
+		if ttl <= 0 {
+			return
+		}
+		t := m.CreatedAt.Add(ttl)
+		m.ExpiresAt = &t
+	}
+}
+
+// WithProvider routes the message to the named SMS provider.
+func WithProvider(name string) Option {
+	return func(m *Message) {
+		m.Provider = strings.TrimSpace(name)
+	}
+}
+
+// WithSendTimeout bounds the provider call for this message to d instead of
+// the service-wide per-message timeout. A non-positive d is ignored.
+func WithSendTimeout(d time.Duration) Option {
+	return func(m *Message) {
+		if d > 0 {
+			m.SendTimeout = &d
+		}
+	}
+}
 
+// WithEncoding overrides the encoding hint the provider receives for this
+// message instead of classifying its content.
+func WithEncoding(e Encoding) Option {
+	return func(m *Message) {
+		m.Encoding = e
+	}
+}
+
+// WithTemplate renders the message from the given template at send time.
+func WithTemplate(id uuid.UUID) Option {
+	return func(m *Message) {
+		m.TemplateID = &id
+	}
+}
+
+// WithTags attaches labels to the message. Keys and values are trimmed;
+// they are validated by NewMessage.
+func WithTags(tags map[string]string) Option {
+	return func(m *Message) {
+		if len(tags) == 0 {
+			return
+		}
+		m.Tags = make(map[string]string, len(tags))
+		for k, v := range tags {
+			m.Tags[strings.TrimSpace(k)] = strings.TrimSpace(v)
+		}
+	}
+}
+
+// ValidateTags checks tags against MaxTags and MaxTagLength.
+func ValidateTags(tags map[string]string) error {
+	if len(tags) > MaxTags {
+		return fmt.Errorf("%w: at most %d tags are allowed", ErrInvalidTags, MaxTags)
+	}
+	for k, v := range tags {
+		if k == "" {
+			return fmt.Errorf("%w: tag keys must not be empty", ErrInvalidTags)
+		}
+		if len(k) > MaxTagLength || len(v) > MaxTagLength {
+			return fmt.Errorf("%w: tag keys and values must be at most %d characters", ErrInvalidTags, MaxTagLength)
+		}
+	}
+	return nil
+}
+
+// NewMessage constructs a new pending Message and enforces basic domain
+// rules, with the default ContentRules.
+func NewMessage(to, content string, opts ...Option) (*Message, error) {
+	return ContentRules{}.NewMessage(to, content, opts...)
+}
+
+// NewMessage constructs a new pending Message like the package-level
+// NewMessage, applying r to its content.
+func (r ContentRules) NewMessage(to, content string, opts ...Option) (*Message, error) {
+	to = strings.TrimSpace(to)
 	if to == "" {
 		return nil, ErrEmptyRecipient
 	}
-	if content == "" {
-		return nil, ErrEmptyContent
-	}
-	if len(content) > MaxContentLength {
-		return nil, ErrContentTooLong
+	content, err := r.prepare(content)
+	if err != nil {
+		return nil, err
 	}
 
-	return &Message{
+	m := &Message{
 		ID:        uuid.New(),
 		To:        to,
 		Content:   content,
 		Status:    StatusPending,
 		CreatedAt: time.Now(),
-	}, nil
+	}
+
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	if m.ExpiresAt != nil && !m.ExpiresAt.After(m.CreatedAt) {
+		return nil, ErrExpiryInPast
+	}
+	if err := ValidateTags(m.Tags); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// lineEndings rewrites CRLF and lone CR line breaks to LF.
+var lineEndings = strings.NewReplacer("\r\n", "\n", "\r", "\n")
+
+// prepare turns submitted content into final content: trimmed, with line
+// endings normalized if configured, checked against MinLength, decorated
+// with Prefix and Footer and checked again as a whole.
+func (r ContentRules) prepare(content string) (string, error) {
+	content = strings.TrimSpace(content)
+	if r.NormalizeLineEndings {
+		content = lineEndings.Replace(content)
+	}
+	if content == "" {
+		return "", ErrEmptyContent
+	}
+	// Count characters rather than bytes.
+	if utf8.RuneCountInString(content) < max(r.MinLength, 1) {
+		return "", ErrContentTooShort
+	}
+
+	content = r.decorate(content)
+	if err := r.check(content); err != nil {
+		return "", err
+	}
+	return content, nil
+}
+
+// decorate wraps content in Prefix and Footer.
+func (r ContentRules) decorate(content string) string {
+	parts := make([]string, 0, 3)
+	for _, p := range []string{r.Prefix, content, r.Footer} {
+		if p = strings.TrimSpace(p); p != "" {
+			parts = append(parts, p)
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// check checks final, decorated content against MaxContentLength and, if
+// enforced, the GSM-7 alphabet.
+func (r ContentRules) check(content string) error {
+	if len(content) > MaxContentLength {
+		return ErrContentTooLong
+	}
+	if r.EnforceGSM7 && !IsGSM7(content) {
+		return ErrNonGSM7Content
+	}
+	return nil
+}
+
+// SetContent replaces the content with final content produced after
+// creation (e.g. by a pre-send transform), holding it to r: it must not be
+// blank and must pass MaxContentLength and the GSM-7 rule. The content is
+// left unchanged on error.
+func (m *Message) SetContent(content string, r ContentRules) error {
+	content = strings.TrimSpace(content)
+	if content == "" {
+		return ErrEmptyContent
+	}
+	if err := r.check(content); err != nil {
+		return err
+	}
+	m.Content = content
+	return nil
+}
+
+// IsExpired reports whether the message has an expiry that is at or before now.
+func (m *Message) IsExpired(now time.Time) bool {
+	return m.ExpiresAt != nil && !m.ExpiresAt.After(now)
+}
+
+// MarkProcessing claims the message for the current batch. UpdatedAt records
+// the claim time so stuck claims can be recognised.
+func (m *Message) MarkProcessing() {
+	m.Status = StatusProcessing
+	m.UpdatedAt = time.Now()
+}
+
+// Release returns a claimed message to PENDING, e.g. when the batch ends
+// before the message was handed to a worker.
+func (m *Message) Release() {
+	if m.Status == StatusProcessing {
+		m.Status = StatusPending
+	}
 }
 
 // MarkSent marks the message as successfully sent and records provider metadata.
@@ -74,10 +356,64 @@ func (m *Message) MarkSent(msgID string, raw string) {
 	m.Status = StatusSuccess
 	m.MessageID = msgID
 	m.RawResponse = raw
+	m.ProviderCode = ParseProviderCode(raw)
 }
 
 // MarkFailed marks the message as failed and stores the raw provider response.
 func (m *Message) MarkFailed(raw string) {
 	m.Status = StatusFailed
 	m.RawResponse = raw
+	m.ProviderCode = ParseProviderCode(raw)
+}
+
+// ScheduleRetry returns a failed attempt to PENDING instead of FAILED: it
+// counts the attempt, keeps the provider response and reason, and sets
+// NextRetryAt to backoff after now.
+func (m *Message) ScheduleRetry(raw, reason string, now time.Time, backoff time.Duration) {
+	m.Status = StatusPending
+	m.RetryCount++
+	m.RawResponse = raw
+	m.ProviderCode = ParseProviderCode(raw)
+	m.StatusReason = reason
+	next := now.Add(backoff)
+	m.NextRetryAt = &next
+}
+
+// RetryBackoff returns how long to wait before the given (1-based) retry:
+// base*2^(retry-1), capped at limit (never below base).
+func RetryBackoff(retry int, base, limit time.Duration) time.Duration {
+	if base <= 0 {
+		return 0
+	}
+	limit = max(limit, base)
+	d := base
+	for i := 1; i < retry && d < limit; i++ {
+		d *= 2
+	}
+	return min(d, limit)
+}
+
+// MarkExpired marks the message as expired and records why.
+func (m *Message) MarkExpired(reason string) {
+	m.Status = StatusExpired
+	m.StatusReason = reason
+}
+
+// Requeue resets a FAILED message to PENDING so it is picked up by the next
+// batch. Provider metadata from the failed attempt is cleared. It returns
+// ErrNotRetryable for messages in any other status.
+func (m *Message) Requeue() error {
+	if m.Status != StatusFailed {
+		return ErrNotRetryable
+	}
+	m.Status = StatusPending
+	m.RetryCount = 0
+	m.NextRetryAt = nil
+	m.MessageID = ""
+	m.RawResponse = ""
+	m.ProviderCode = ""
+	m.StatusReason = ""
+	m.SentAt = nil
+	m.SendDuration = nil
+	return nil
 }
@@ -1,22 +1,145 @@
 package message
 
-import "context"
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ErrNotFound is returned by repositories when a message does not exist.
+var ErrNotFound = errors.New("message not found")
+
+// ErrDuplicateMessage is returned by repositories that de-duplicate content
+// when a message with the same recipient and content is already waiting to
+// be sent.
+var ErrDuplicateMessage = errors.New("an identical message is already pending")
+
+// ErrRecentDuplicate is returned when a message with the same recipient and
+// content was sent or queued within the configured de-duplication window.
+var ErrRecentDuplicate = errors.New("an identical message was sent or queued recently")
+
+// ListFilter narrows a message listing. Zero values match everything.
+type ListFilter struct {
+	// Tags matches messages carrying every given key=value pair.
+	Tags map[string]string
+}
 
 // Repository defines the persistence operations for Message aggregates.
 //
 // It is implemented by infrastructure layers (e.g. GORM, sqlc, etc.)
 // while the domain and service layers depend only on this interface.
 type Repository interface {
-	// Save persists a new message.
+	// Save persists a new message. It may return ErrDuplicateMessage.
 	Save(ctx context.Context, m *Message) error
 
+	// List returns a page of messages of any status matching f, newest
+	// first, and the total number of matches.
+	List(ctx context.Context, f ListFilter, page, limit int) ([]*Message, int64, error)
+
+	// GetByProviderCode returns a page of messages whose last provider
+	// response carried the given code, newest first, and the total number
+	// of matches.
+	GetByProviderCode(ctx context.Context, code string, page, limit int) ([]*Message, int64, error)
+
+	// ClearContentDedup releases the content de-duplication claims of the
+	// recipient's unsent messages, so an identical message may be enqueued
+	// again. It returns how many messages were released, which is 0 when
+	// there were none.
+	ClearContentDedup(ctx context.Context, to string) (int64, error)
+
+	// FindRecentDuplicate returns the most recent message to the recipient
+	// with the same content that was created at or after since and is
+	// PENDING, PROCESSING or SUCCESS, or ErrNotFound. It reads the primary,
+	// so a message created a moment ago is seen.
+	FindRecentDuplicate(ctx context.Context, to, content string, since time.Time) (*Message, error)
+
+	// GetByID returns a single message, or ErrNotFound.
+	GetByID(ctx context.Context, id uuid.UUID) (*Message, error)
+
+	// GetStatus returns a message's current status, or ErrNotFound. Unlike
+	// GetByID it always reads the primary, so it sees a send confirmed a
+	// moment ago.
+	GetStatus(ctx context.Context, id uuid.UUID) (Status, error)
+
+	// SoftDelete hides a message from all reads while keeping its row, or
+	// returns ErrNotFound.
+	SoftDelete(ctx context.Context, id uuid.UUID) error
+
+	// StreamByCreatedRange calls fn for every message created in
+	// [from, until), oldest first, without loading them all into memory.
+	// It stops at and returns the first error fn returns.
+	StreamByCreatedRange(ctx context.Context, from, until time.Time, fn func(*Message) error) error
+
+	// StreamSent calls fn for every successfully sent message, most
+	// recently sent first, without loading them all into memory. It stops at
+	// and returns the first error fn returns.
+	StreamSent(ctx context.Context, fn func(*Message) error) error
+
 	// GetPending returns up to limit messages that are still waiting to be sent.
+	// Messages whose expiry has passed are never returned.
 	GetPending(ctx context.Context, limit int) ([]*Message, error)
 
+	// ResetStuck returns PROCESSING messages claimed at or before before to
+	// PENDING, returning the IDs it changed. It recovers claims left behind
+	// by a crash between send and confirmation.
+	ResetStuck(ctx context.Context, before time.Time) ([]uuid.UUID, error)
+
+	// WithTx runs fn against a Repository bound to a single transaction,
+	// committing if fn returns nil and rolling back otherwise.
+	WithTx(ctx context.Context, fn func(tx Repository) error) error
+
+	// ExpirePending transitions all pending messages whose expiry is at or
+	// before now to EXPIRED with the given reason, returning the IDs it changed.
+	ExpirePending(ctx context.Context, now time.Time, reason string) ([]uuid.UUID, error)
+
 	// GetSent returns a paginated list of successfully sent messages
 	// along with the total number of sent records.
 	GetSent(ctx context.Context, page, limit int) ([]*Message, int64, error)
 
 	// UpdateStatus updates the status and metadata of an existing message.
+	// Requeueing may return ErrDuplicateMessage.
 	UpdateStatus(ctx context.Context, m *Message) error
+
+	// Requeue stores m, requeued by Message.Requeue, only while the stored
+	// message is still FAILED and returns ErrNotRetryable otherwise, so a
+	// stale read or a concurrent retry cannot requeue it twice. It may
+	// return ErrDuplicateMessage.
+	Requeue(ctx context.Context, m *Message) error
+
+	// AddStatusEvents appends status transitions to the audit timeline.
+	AddStatusEvents(ctx context.Context, events ...*StatusEvent) error
+
+	// SentStatsByDay returns per-day SUCCESS/FAILED counts for messages that
+	// reached that status in [from, until), ordered by day. Days without any
+	// such messages are omitted.
+	SentStatsByDay(ctx context.Context, from, until time.Time) ([]DayStat, error)
+
+	// SendLatencyStats returns the average and 95th percentile provider call
+	// duration of messages sent successfully in [from, until). Both are zero
+	// when no such message recorded a duration.
+	SendLatencyStats(ctx context.Context, from, until time.Time) (avg, p95 time.Duration, err error)
+
+	// TopRecipients returns the limit recipients with the most messages
+	// created in [from, until), most messages first.
+	TopRecipients(ctx context.Context, from, until time.Time, limit int) ([]RecipientCount, error)
+
+	// OldestPendingAge returns how long the oldest PENDING message has been
+	// waiting, or zero when nothing is pending.
+	OldestPendingAge(ctx context.Context) (time.Duration, error)
+
+	// GetTimeline returns the status transitions of a message, oldest first.
+	GetTimeline(ctx context.Context, messageID uuid.UUID) ([]*StatusEvent, error)
+
+	// Ping checks that the database answers a trivial query, for readiness
+	// probes.
+	Ping(ctx context.Context) error
+
+	// RecordBatchRun stores the outcome of a batch run.
+	RecordBatchRun(ctx context.Context, result BatchResult) error
+
+	// ListBatchRuns returns a page of recorded batch runs, most recent
+	// first, and the total number of recorded runs.
+	ListBatchRuns(ctx context.Context, page, limit int) ([]*BatchRun, int64, error)
 }
@@ -0,0 +1,183 @@
+package message
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewMessage_AddsPrefixAndFooter(t *testing.T) {
+	rules := ContentRules{Prefix: "ACME:", Footer: "Reply STOP to unsubscribe"}
+
+	msg, err := rules.NewMessage("+905000000000", "  Big sale today!  ")
+	if err != nil {
+		t.Fatalf("NewMessage: %v", err)
+	}
+	if want := "ACME: Big sale today! Reply STOP to unsubscribe"; msg.Content != want {
+		t.Fatalf("content = %q, want %q", msg.Content, want)
+	}
+}
+
+func TestNewMessage_FooterCountsTowardLength(t *testing.T) {
+	const footer = "Reply STOP to unsubscribe"
+	rules := ContentRules{Footer: footer}
+
+	// Exactly fills MaxContentLength together with the separating space.
+	fits := strings.Repeat("a", MaxContentLength-len(footer)-1)
+	msg, err := rules.NewMessage("+905000000000", fits)
+	if err != nil {
+		t.Fatalf("expected content that fits with the footer to pass, got %v", err)
+	}
+	if len(msg.Content) != MaxContentLength {
+		t.Fatalf("expected %d characters, got %d", MaxContentLength, len(msg.Content))
+	}
+
+	if _, err := rules.NewMessage("+905000000000", fits+"a"); err != ErrContentTooLong {
+		t.Fatalf("expected ErrContentTooLong once the footer overflows, got %v", err)
+	}
+}
+
+func TestNewMessage_NoDecorationByDefault(t *testing.T) {
+	msg, err := NewMessage("+905000000000", "hello")
+	if err != nil {
+		t.Fatalf("NewMessage: %v", err)
+	}
+	if msg.Content != "hello" {
+		t.Fatalf("content = %q, want it unchanged", msg.Content)
+	}
+}
+
+func TestNewMessage_MinContentLength(t *testing.T) {
+	// The footer must not make short content long enough.
+	rules := ContentRules{MinLength: 3, Footer: "Reply STOP to unsubscribe"}
+
+	for _, content := range []string{"a", "  ab  ", "ğü"} {
+		if _, err := rules.NewMessage("+905000000000", content); err != ErrContentTooShort {
+			t.Fatalf("content %q: expected ErrContentTooShort, got %v", content, err)
+		}
+	}
+
+	// Characters, not bytes, are counted: "ğüş" is 3 characters.
+	for _, content := range []string{"abc", " abc ", "ğüş"} {
+		if _, err := rules.NewMessage("+905000000000", content); err != nil {
+			t.Fatalf("content %q at the minimum: expected success, got %v", content, err)
+		}
+	}
+
+	if _, err := rules.NewMessage("+905000000000", "   "); err != ErrEmptyContent {
+		t.Fatalf("expected whitespace-only content to stay ErrEmptyContent, got %v", err)
+	}
+}
+
+func TestNewMessage_DefaultMinContentLengthAllowsOneCharacter(t *testing.T) {
+	if _, err := NewMessage("+905000000000", "a"); err != nil {
+		t.Fatalf("expected a single character to pass by default, got %v", err)
+	}
+}
+
+func TestNewMessage_NormalizeLineEndings(t *testing.T) {
+	// 15 lines of 9 characters: 163 characters with CRLF, 149 with LF.
+	lines := make([]string, 15)
+	for i := range lines {
+		lines[i] = "abcdefghi"
+	}
+	content := strings.Join(lines, "\r\n")
+
+	m, err := NewMessage("+905000000000", content)
+	if err != nil {
+		t.Fatalf("NewMessage: %v", err)
+	}
+	if m.Content != content || Segments(m.Content) != 2 {
+		t.Fatalf("expected content kept as submitted in 2 segments by default, got %q (%d)", m.Content, Segments(m.Content))
+	}
+
+	rules := ContentRules{NormalizeLineEndings: true}
+
+	m, err = rules.NewMessage("+905000000000", content)
+	if err != nil {
+		t.Fatalf("NewMessage: %v", err)
+	}
+	if want := strings.Join(lines, "\n"); m.Content != want {
+		t.Fatalf("expected LF line endings, got %q", m.Content)
+	}
+	if got := Segments(m.Content); got != 1 {
+		t.Fatalf("expected 1 segment after normalizing, got %d", got)
+	}
+
+	m, _ = rules.NewMessage("+905000000000", "old\rmac\r\nmixed\n")
+	if m.Content != "old\nmac\nmixed" {
+		t.Fatalf("expected lone CRs normalized too, got %q", m.Content)
+	}
+}
+
+func TestNewMessage_NormalizedContentFitsLengthLimit(t *testing.T) {
+	// 128 CRLF-separated characters: 382 bytes raw, 255 normalized.
+	content := strings.Repeat("a\r\n", 127) + "a"
+
+	if _, err := NewMessage("+905000000000", content); err != ErrContentTooLong {
+		t.Fatalf("expected ErrContentTooLong without normalizing, got %v", err)
+	}
+
+	rules := ContentRules{NormalizeLineEndings: true}
+	if _, err := rules.NewMessage("+905000000000", content); err != nil {
+		t.Fatalf("expected the normalized content to fit, got %v", err)
+	}
+}
+
+func TestSetContent_KeepsContentRules(t *testing.T) {
+	msg, _ := NewMessage("+905000000000", "hello")
+
+	for content, want := range map[string]error{
+		"   ":                                   ErrEmptyContent,
+		strings.Repeat("a", MaxContentLength+1): ErrContentTooLong,
+	} {
+		if err := msg.SetContent(content, ContentRules{}); err != want {
+			t.Fatalf("SetContent(%d bytes): expected %v, got %v", len(content), want, err)
+		}
+		if msg.Content != "hello" {
+			t.Fatalf("expected the content to be unchanged on error, got %q", msg.Content)
+		}
+	}
+
+	if err := msg.SetContent(" hello there ", ContentRules{}); err != nil || msg.Content != "hello there" {
+		t.Fatalf("expected the trimmed content to be set, got %q (%v)", msg.Content, err)
+	}
+
+	if err := msg.SetContent("Şifreniz: 1234", ContentRules{EnforceGSM7: true}); err != ErrNonGSM7Content {
+		t.Fatalf("expected ErrNonGSM7Content under the given rules, got %v", err)
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	cases := []struct {
+		retry int
+		want  time.Duration
+	}{
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{3, 2 * time.Minute},
+		{5, 5 * time.Minute}, // 8m, capped
+		{50, 5 * time.Minute},
+	}
+	for _, tc := range cases {
+		if got := RetryBackoff(tc.retry, 30*time.Second, 5*time.Minute); got != tc.want {
+			t.Fatalf("RetryBackoff(%d) = %v, want %v", tc.retry, got, tc.want)
+		}
+	}
+	if got := RetryBackoff(1, 0, time.Minute); got != 0 {
+		t.Fatalf("expected no backoff without a base, got %v", got)
+	}
+}
+
+func TestRequeue_ClearsRetryState(t *testing.T) {
+	msg, _ := NewMessage("+905000000000", "hello")
+	msg.ScheduleRetry("{}", "unavailable", time.Now(), time.Minute)
+	msg.MarkFailed("{}")
+
+	if err := msg.Requeue(); err != nil {
+		t.Fatalf("Requeue: %v", err)
+	}
+	if msg.RetryCount != 0 || msg.NextRetryAt != nil {
+		t.Fatalf("expected retry state to be reset, got retries=%d next=%v", msg.RetryCount, msg.NextRetryAt)
+	}
+}
@@ -0,0 +1,77 @@
+package message
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"time"
+)
+
+// optOutTokenBytes is the entropy of an opt-out token; base64url encoded it
+// is 16 characters, short enough to fit in an SMS link.
+const optOutTokenBytes = 12
+
+// ErrOptOutTokenNotFound is returned when an opt-out link carries an
+// unknown token.
+var ErrOptOutTokenNotFound = errors.New("opt-out token not found")
+
+// OptOutToken identifies a recipient in their personal unsubscribe link.
+// Each recipient has a single token, reused in every message they get.
+type OptOutToken struct {
+	Token     string
+	To        string
+	CreatedAt time.Time
+	// OptedOutAt is when the link was first followed; nil while the
+	// recipient is still subscribed.
+	OptedOutAt *time.Time
+}
+
+// NewOptOutToken creates a random, URL-safe token for to.
+func NewOptOutToken(to string) (*OptOutToken, error) {
+	to = strings.TrimSpace(to)
+	if to == "" {
+		return nil, ErrEmptyRecipient
+	}
+
+	b := make([]byte, optOutTokenBytes)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	return &OptOutToken{
+		Token:     base64.RawURLEncoding.EncodeToString(b),
+		To:        to,
+		CreatedAt: time.Now(),
+	}, nil
+}
+
+// AppendLink adds link to the end of the message content, separated by a
+// space, and re-checks the decorated content against MaxContentLength and
+// r's GSM-7 rule. The content is left unchanged on error.
+func (m *Message) AppendLink(link string, r ContentRules) error {
+	link = strings.TrimSpace(link)
+	if link == "" {
+		return nil
+	}
+	content := strings.TrimSpace(m.Content + " " + link)
+	if err := r.check(content); err != nil {
+		return err
+	}
+	m.Content = content
+	return nil
+}
+
+// OptOutRepository stores opt-out tokens and resolves unsubscribe links.
+type OptOutRepository interface {
+	// OptOutTokenFor returns the token of to, creating it on first use.
+	OptOutTokenFor(ctx context.Context, to string) (*OptOutToken, error)
+
+	// ResolveOptOutToken records that the recipient behind token opted out
+	// at at (keeping the first time if it was already resolved) and
+	// returns the token, or ErrOptOutTokenNotFound.
+	ResolveOptOutToken(ctx context.Context, token string, at time.Time) (*OptOutToken, error)
+
+	// IsOptedOut reports whether to followed their opt-out link.
+	IsOptedOut(ctx context.Context, to string) (bool, error)
+}
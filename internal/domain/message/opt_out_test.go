@@ -0,0 +1,48 @@
+package message
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestNewOptOutToken(t *testing.T) {
+	a, err := NewOptOutToken(" +905000000001 ")
+	if err != nil {
+		t.Fatalf("NewOptOutToken: %v", err)
+	}
+	b, _ := NewOptOutToken("+905000000001")
+
+	if a.To != "+905000000001" || a.OptedOutAt != nil || a.CreatedAt.IsZero() {
+		t.Fatalf("unexpected token %+v", a)
+	}
+	if len(a.Token) != 16 || strings.ContainsAny(a.Token, "+/=") {
+		t.Fatalf("expected a 16 character URL-safe token, got %q", a.Token)
+	}
+	if a.Token == b.Token {
+		t.Fatal("expected tokens to be random")
+	}
+
+	if _, err := NewOptOutToken(" "); !errors.Is(err, ErrEmptyRecipient) {
+		t.Fatalf("expected ErrEmptyRecipient, got %v", err)
+	}
+}
+
+func TestMessage_AppendLink(t *testing.T) {
+	msg, _ := NewMessage("+905000000001", "hello")
+	if err := msg.AppendLink("https://x.example/o/abc", ContentRules{}); err != nil {
+		t.Fatalf("AppendLink: %v", err)
+	}
+	if msg.Content != "hello https://x.example/o/abc" {
+		t.Fatalf("content = %q", msg.Content)
+	}
+
+	long, _ := NewMessage("+905000000001", strings.Repeat("a", MaxContentLength-3))
+	before := long.Content
+	if err := long.AppendLink("https://x.example/o/abc", ContentRules{}); !errors.Is(err, ErrContentTooLong) {
+		t.Fatalf("expected ErrContentTooLong, got %v", err)
+	}
+	if long.Content != before {
+		t.Fatal("expected the content to be left unchanged")
+	}
+}
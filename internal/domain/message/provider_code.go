@@ -0,0 +1,66 @@
+package message
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+)
+
+// maxProviderCodeLength bounds stored provider codes so an unexpected
+// response cannot bloat the column.
+const maxProviderCodeLength = 64
+
+// providerCodeKeys are the JSON fields, in order of preference, that
+// providers use for a machine-readable result or error code.
+var providerCodeKeys = []string{"code", "errorCode", "error_code", "statusCode", "status_code"}
+
+// ParseProviderCode extracts the provider's result code from a raw
+// response body, e.g. "INVALID_NUMBER" from {"code":"INVALID_NUMBER"} or
+// "21211" from {"error":{"code":21211}}. Top-level fields win over the
+// nested "error" object. It returns "" when the body is not a JSON object
+// or carries no code.
+func ParseProviderCode(raw string) string {
+	var body map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(raw), &body); err != nil {
+		return ""
+	}
+	if code := codeField(body); code != "" {
+		return code
+	}
+
+	var nested map[string]json.RawMessage
+	if err := json.Unmarshal(body["error"], &nested); err != nil {
+		return ""
+	}
+	return codeField(nested)
+}
+
+// codeField returns the first providerCodeKeys value of obj that is a
+// non-empty string or a number.
+func codeField(obj map[string]json.RawMessage) string {
+	for _, key := range providerCodeKeys {
+		v, ok := obj[key]
+		if !ok {
+			continue
+		}
+
+		var code string
+		if err := json.Unmarshal(v, &code); err != nil {
+			var n json.Number
+			dec := json.NewDecoder(bytes.NewReader(v))
+			dec.UseNumber()
+			if dec.Decode(&n) != nil {
+				continue
+			}
+			code = n.String()
+		}
+
+		if code = strings.TrimSpace(code); code != "" {
+			if len(code) > maxProviderCodeLength {
+				code = code[:maxProviderCodeLength]
+			}
+			return code
+		}
+	}
+	return ""
+}
@@ -0,0 +1,48 @@
+package message
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseProviderCode(t *testing.T) {
+	cases := map[string]string{
+		`{"code":"INVALID_NUMBER","message":"bad recipient"}`: "INVALID_NUMBER",
+		`{"errorCode":30007}`:                                   "30007",
+		`{"error_code":" throttled "}`:                          "throttled",
+		`{"error":{"code":21211,"message":"invalid 'To'"}}`:     "21211",
+		`{"code":"TOP","error":{"code":"NESTED"}}`:              "TOP",
+		`{"code":"","statusCode":"E42"}`:                        "E42",
+		`{"message":"Accepted","messageId":"abc"}`:              "",
+		`{"code":null,"error":"quota exceeded"}`:                "",
+		`Internal Server Error`:                                 "",
+		``:                                                      "",
+		`["code"]`:                                              "",
+		`{"code":` + `"` + strings.Repeat("x", 100) + `"` + `}`: strings.Repeat("x", maxProviderCodeLength),
+	}
+	for raw, want := range cases {
+		if got := ParseProviderCode(raw); got != want {
+			t.Errorf("ParseProviderCode(%q) = %q, want %q", raw, got, want)
+		}
+	}
+}
+
+func TestMarkFailed_RecordsProviderCode(t *testing.T) {
+	msg, _ := NewMessage("+905000000000", "hello")
+	msg.MarkFailed(`{"code":"INVALID_NUMBER"}`)
+	if msg.ProviderCode != "INVALID_NUMBER" {
+		t.Fatalf("ProviderCode = %q, want INVALID_NUMBER", msg.ProviderCode)
+	}
+
+	if err := msg.Requeue(); err != nil {
+		t.Fatalf("Requeue: %v", err)
+	}
+	if msg.ProviderCode != "" {
+		t.Fatalf("expected Requeue to clear the provider code, got %q", msg.ProviderCode)
+	}
+
+	msg.MarkSent("ext-1", `{"message":"Accepted","messageId":"ext-1"}`)
+	if msg.ProviderCode != "" {
+		t.Fatalf("expected no provider code for a plain acceptance, got %q", msg.ProviderCode)
+	}
+}
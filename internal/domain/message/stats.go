@@ -0,0 +1,18 @@
+package message
+
+import "time"
+
+// DayStat holds the number of messages that reached a final send status on
+// a single UTC day.
+type DayStat struct {
+	// Day is midnight UTC of the day the counts belong to.
+	Day    time.Time
+	Sent   int64
+	Failed int64
+}
+
+// RecipientCount holds how many messages were addressed to one recipient.
+type RecipientCount struct {
+	To    string
+	Count int64
+}
@@ -0,0 +1,28 @@
+package message
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// StatusEvent records a single status transition of a message.
+// From is empty for the initial transition into PENDING on creation.
+type StatusEvent struct {
+	ID        uuid.UUID
+	MessageID uuid.UUID
+	From      Status
+	To        Status
+	At        time.Time
+}
+
+// NewStatusEvent builds a transition event for the given message at now.
+func NewStatusEvent(messageID uuid.UUID, from, to Status, at time.Time) *StatusEvent {
+	return &StatusEvent{
+		ID:        uuid.New(),
+		MessageID: messageID,
+		From:      from,
+		To:        to,
+		At:        at,
+	}
+}
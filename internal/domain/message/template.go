@@ -0,0 +1,95 @@
+package message
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MaxTemplateNameLength is the maximum length of a template name.
+const MaxTemplateNameLength = 100
+
+var (
+	// ErrTemplateNotFound is returned when a referenced template does not exist.
+	ErrTemplateNotFound = errors.New("template not found")
+	// ErrInvalidTemplate is returned when a template has no name or body, or
+	// its name is longer than MaxTemplateNameLength.
+	ErrInvalidTemplate = errors.New("invalid template")
+	// ErrMissingTemplateVar is returned when a template placeholder has no
+	// matching message tag.
+	ErrMissingTemplateVar = errors.New("missing template variable")
+)
+
+// placeholderPattern matches {{name}} placeholders, allowing inner spaces.
+var placeholderPattern = regexp.MustCompile(`\{\{\s*([A-Za-z0-9_.-]+)\s*\}\}`)
+
+// Template is a named message body stored once and referenced by messages.
+// Its body is rendered when a message is sent, not when it is enqueued, so
+// editing a template changes every message still waiting to be sent.
+type Template struct {
+	ID        uuid.UUID
+	Name      string
+	Body      string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// NewTemplate constructs a template and validates its name and body.
+func NewTemplate(name, body string) (*Template, error) {
+	name = strings.TrimSpace(name)
+	body = strings.TrimSpace(body)
+
+	if name == "" || len(name) > MaxTemplateNameLength {
+		return nil, fmt.Errorf("%w: name must be 1-%d characters", ErrInvalidTemplate, MaxTemplateNameLength)
+	}
+	if body == "" {
+		return nil, fmt.Errorf("%w: body is required", ErrInvalidTemplate)
+	}
+
+	now := time.Now()
+	return &Template{
+		ID:        uuid.New(),
+		Name:      name,
+		Body:      body,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}, nil
+}
+
+// Render replaces every {{name}} placeholder in the body with vars[name].
+// It returns ErrMissingTemplateVar if a placeholder has no value. Like
+// ContentRules.NewMessage, it applies r to the result.
+func (t *Template) Render(vars map[string]string, r ContentRules) (string, error) {
+	var missing []string
+	out := placeholderPattern.ReplaceAllStringFunc(t.Body, func(p string) string {
+		name := placeholderPattern.FindStringSubmatch(p)[1]
+		v, ok := vars[name]
+		if !ok {
+			missing = append(missing, name)
+		}
+		return v
+	})
+	if len(missing) > 0 {
+		return "", fmt.Errorf("%w: %s", ErrMissingTemplateVar, strings.Join(missing, ", "))
+	}
+
+	return r.prepare(out)
+}
+
+// TemplateRepository defines the persistence operations for templates.
+type TemplateRepository interface {
+	// SaveTemplate stores t, replacing the body of an existing template
+	// with the same name.
+	SaveTemplate(ctx context.Context, t *Template) error
+
+	// GetTemplate returns a template by ID, or ErrTemplateNotFound.
+	GetTemplate(ctx context.Context, id uuid.UUID) (*Template, error)
+
+	// GetTemplateByName returns a template by name, or ErrTemplateNotFound.
+	GetTemplateByName(ctx context.Context, name string) (*Template, error)
+}
@@ -0,0 +1,50 @@
+package message
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestTemplate_Render(t *testing.T) {
+	tmpl, err := NewTemplate("greeting", "Hi {{name}}, your order {{ order.id }} shipped.")
+	if err != nil {
+		t.Fatalf("NewTemplate: %v", err)
+	}
+
+	got, err := tmpl.Render(map[string]string{"name": "Ada", "order.id": "42"}, ContentRules{})
+	if err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	if want := "Hi Ada, your order 42 shipped."; got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+
+	if _, err := tmpl.Render(map[string]string{"name": "Ada"}, ContentRules{}); !errors.Is(err, ErrMissingTemplateVar) {
+		t.Fatalf("expected ErrMissingTemplateVar, got %v", err)
+	}
+	if _, err := tmpl.Render(map[string]string{"name": strings.Repeat("a", MaxContentLength), "order.id": "1"}, ContentRules{}); !errors.Is(err, ErrContentTooLong) {
+		t.Fatalf("expected ErrContentTooLong, got %v", err)
+	}
+
+	rules := ContentRules{Prefix: "ACME:", MinLength: 50}
+	if _, err := tmpl.Render(map[string]string{"name": "Ada", "order.id": "42"}, rules); !errors.Is(err, ErrContentTooShort) {
+		t.Fatalf("expected ErrContentTooShort under the given rules, got %v", err)
+	}
+	rules.MinLength = 0
+	if got, _ := tmpl.Render(map[string]string{"name": "Ada", "order.id": "42"}, rules); got != "ACME: Hi Ada, your order 42 shipped." {
+		t.Fatalf("expected the prefix from the given rules, got %q", got)
+	}
+}
+
+func TestNewTemplate_Validates(t *testing.T) {
+	for _, tc := range []struct{ name, body string }{
+		{"", "body"},
+		{strings.Repeat("n", MaxTemplateNameLength+1), "body"},
+		{"name", "  "},
+	} {
+		if _, err := NewTemplate(tc.name, tc.body); !errors.Is(err, ErrInvalidTemplate) {
+			t.Errorf("NewTemplate(%q, %q): expected ErrInvalidTemplate, got %v", tc.name, tc.body, err)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+// Package event is a small in-process publish/subscribe bus. Publishers
+// announce things that happened (e.g. a batch with a high failure rate) and
+// any number of subscribers (logging, metrics, webhooks) react to them,
+// without either side knowing about the other.
+package event
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+)
+
+// Event is something that happened. Name identifies its kind and is the key
+// subscribers register under.
+type Event interface {
+	Name() string
+}
+
+// Handler reacts to a published event. Handlers registered with Subscribe
+// run synchronously on the publisher's goroutine, so slow work should be
+// registered with SubscribeAsync instead.
+type Handler func(Event)
+
+// subscription is a handler plus how it is delivered.
+type subscription struct {
+	h     Handler
+	async bool
+}
+
+// Bus dispatches published events to the handlers subscribed to their name.
+// It is safe for concurrent use; the zero value is not, use NewBus.
+type Bus struct {
+	mu       sync.RWMutex
+	handlers map[string][]subscription
+
+	// inflight counts async deliveries that have not returned yet. Once
+	// draining is set no more are added, so Drain can wait on it safely.
+	inflight sync.WaitGroup
+	draining bool
+}
+
+// NewBus returns an empty bus.
+func NewBus() *Bus {
+	return &Bus{handlers: map[string][]subscription{}}
+}
+
+// Subscribe registers h for events with the given name.
+func (b *Bus) Subscribe(name string, h Handler) {
+	b.subscribe(name, subscription{h: h})
+}
+
+// SubscribeAsync registers h for events with the given name, delivered on
+// a goroutine of its own so a slow subscriber (e.g. a webhook) does not
+// hold up the publisher. Deliveries still running are awaited by Drain.
+func (b *Bus) SubscribeAsync(name string, h Handler) {
+	b.subscribe(name, subscription{h: h, async: true})
+}
+
+func (b *Bus) subscribe(name string, sub subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.handlers[name] = append(b.handlers[name], sub)
+}
+
+// Publish delivers e to every handler subscribed to e.Name(), in
+// subscription order; async handlers are started in that order but may
+// finish in any. A panicking handler is logged and does not stop delivery
+// to the others or reach the publisher.
+func (b *Bus) Publish(e Event) {
+	b.mu.RLock()
+	subs := b.handlers[e.Name()]
+	b.mu.RUnlock()
+
+	for _, sub := range subs {
+		if sub.async && b.startAsync() {
+			go func() {
+				defer b.inflight.Done()
+				deliver(sub.h, e)
+			}()
+			continue
+		}
+		deliver(sub.h, e)
+	}
+}
+
+// startAsync reserves an in-flight slot for an async delivery. It reports
+// false once Drain has started; the event is then delivered synchronously
+// rather than dropped.
+func (b *Bus) startAsync() bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if b.draining {
+		return false
+	}
+	b.inflight.Add(1)
+	return true
+}
+
+// Drain waits for async deliveries still running, or until ctx is done.
+// It is meant for shutdown: async handlers of events published afterwards
+// run on the publisher's goroutine instead. It returns ctx.Err() if
+// handlers were still running when ctx ended.
+func (b *Bus) Drain(ctx context.Context) error {
+	b.mu.Lock()
+	b.draining = true
+	b.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		b.inflight.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("event handlers still running: %w", ctx.Err())
+	}
+}
+
+func deliver(h Handler, e Event) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("[Event] Handler for %s panicked: %v", e.Name(), r)
+		}
+	}()
+	h(e)
+}
+
+// Log is a Handler that writes the event to the standard logger.
+func Log(e Event) {
+	log.Printf("[Event] %s: %s", e.Name(), describe(e))
+}
+
+func describe(e Event) string {
+	if s, ok := e.(fmt.Stringer); ok {
+		return s.String()
+	}
+	return fmt.Sprintf("%+v", e)
+}
@@ -0,0 +1,110 @@
+package event
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type testEvent struct{ name string }
+
+func (e testEvent) Name() string { return e.name }
+
+func TestBus_PublishDeliversToSubscribersOfName(t *testing.T) {
+	bus := NewBus()
+
+	var got []string
+	bus.Subscribe("a", func(e Event) { got = append(got, "first:"+e.Name()) })
+	bus.Subscribe("a", func(e Event) { got = append(got, "second:"+e.Name()) })
+	bus.Subscribe("b", func(e Event) { got = append(got, "other:"+e.Name()) })
+
+	bus.Publish(testEvent{name: "a"})
+
+	if len(got) != 2 || got[0] != "first:a" || got[1] != "second:a" {
+		t.Fatalf("expected both 'a' handlers in order, got %v", got)
+	}
+}
+
+func TestBus_PanickingHandlerDoesNotStopDelivery(t *testing.T) {
+	bus := NewBus()
+
+	delivered := false
+	bus.Subscribe("a", func(Event) { panic("boom") })
+	bus.Subscribe("a", func(Event) { delivered = true })
+
+	bus.Publish(testEvent{name: "a"})
+
+	if !delivered {
+		t.Fatal("expected the second handler to run after the first panicked")
+	}
+}
+
+func TestBus_DrainWaitsForAsyncHandler(t *testing.T) {
+	bus := NewBus()
+
+	release := make(chan struct{})
+	var finished atomic.Bool
+	bus.SubscribeAsync("a", func(Event) {
+		<-release
+		finished.Store(true)
+	})
+
+	bus.Publish(testEvent{name: "a"})
+
+	drained := make(chan error, 1)
+	go func() { drained <- bus.Drain(context.Background()) }()
+
+	select {
+	case err := <-drained:
+		t.Fatalf("Drain returned before the handler finished: %v", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	close(release)
+	if err := <-drained; err != nil {
+		t.Fatalf("Drain: %v", err)
+	}
+	if !finished.Load() {
+		t.Fatal("expected the handler to have finished when Drain returned")
+	}
+}
+
+func TestBus_DrainGivesUpAfterTimeout(t *testing.T) {
+	bus := NewBus()
+
+	hang := make(chan struct{})
+	defer close(hang)
+	bus.SubscribeAsync("a", func(Event) { <-hang })
+
+	bus.Publish(testEvent{name: "a"})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	err := bus.Drain(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected DeadlineExceeded, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("Drain took %v despite the timeout", elapsed)
+	}
+}
+
+func TestBus_PublishAfterDrainRunsAsyncHandlerInline(t *testing.T) {
+	bus := NewBus()
+
+	delivered := false
+	bus.SubscribeAsync("a", func(Event) { delivered = true })
+
+	if err := bus.Drain(context.Background()); err != nil {
+		t.Fatalf("Drain: %v", err)
+	}
+	bus.Publish(testEvent{name: "a"})
+
+	if !delivered {
+		t.Fatal("expected the event to be delivered before Publish returned")
+	}
+}
@@ -0,0 +1,101 @@
+package event
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/oggyb/insider-assessment/internal/retry"
+)
+
+const (
+	// DefaultWebhookTimeout bounds a single webhook delivery attempt.
+	DefaultWebhookTimeout = 5 * time.Second
+
+	// DefaultWebhookAttempts is how often a delivery is tried before it is
+	// given up.
+	DefaultWebhookAttempts = 3
+
+	// webhookBackoff is the pause between delivery attempts.
+	webhookBackoff = time.Second
+)
+
+// Webhook POSTs events as JSON to a URL. Delivery is best effort: failed
+// attempts (transport errors and non-2xx answers) are retried, and an event
+// that still could not be delivered is logged and dropped. Register Handle
+// with SubscribeAsync so deliveries do not hold up the publisher.
+type Webhook struct {
+	url      string
+	client   *http.Client
+	timeout  time.Duration
+	attempts int
+	backoff  time.Duration
+}
+
+// NewWebhook returns a Webhook posting to url. Non-positive timeout and
+// attempts fall back to DefaultWebhookTimeout and DefaultWebhookAttempts.
+func NewWebhook(url string, timeout time.Duration, attempts int) *Webhook {
+	if timeout <= 0 {
+		timeout = DefaultWebhookTimeout
+	}
+	if attempts <= 0 {
+		attempts = DefaultWebhookAttempts
+	}
+	return &Webhook{
+		url:      url,
+		client:   &http.Client{},
+		timeout:  timeout,
+		attempts: attempts,
+		backoff:  webhookBackoff,
+	}
+}
+
+// Handle is a Handler delivering e to the webhook.
+func (w *Webhook) Handle(e Event) {
+	if err := w.Deliver(context.Background(), e); err != nil {
+		log.Printf("[Event] Webhook for %s failed: %v", e.Name(), err)
+	}
+}
+
+// Deliver POSTs e as JSON, retrying failed attempts, until it is accepted,
+// the attempts run out or ctx ends.
+func (w *Webhook) Deliver(ctx context.Context, e Event) error {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("encode %s: %w", e.Name(), err)
+	}
+
+	return retry.Do(ctx, w.attempts, w.backoff, func(int) error {
+		return w.post(ctx, e.Name(), body)
+	})
+}
+
+// post makes one delivery attempt, bounded by the webhook timeout.
+func (w *Webhook) post(ctx context.Context, name string, body []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, w.timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Event-Name", name)
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook answered %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package event
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type countEvent struct {
+	Count int `json:"count"`
+}
+
+func (countEvent) Name() string { return "Counted" }
+
+// newTestWebhook returns a Webhook for url that does not wait between
+// attempts.
+func newTestWebhook(url string, timeout time.Duration, attempts int) *Webhook {
+	w := NewWebhook(url, timeout, attempts)
+	w.backoff = time.Millisecond
+	return w
+}
+
+func TestWebhook_PostsEventAsJSON(t *testing.T) {
+	var got countEvent
+	var name, contentType string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name, contentType = r.Header.Get("X-Event-Name"), r.Header.Get("Content-Type")
+		_ = json.NewDecoder(r.Body).Decode(&got)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	if err := newTestWebhook(srv.URL, time.Second, 1).Deliver(context.Background(), countEvent{Count: 7}); err != nil {
+		t.Fatalf("Deliver: %v", err)
+	}
+	if got.Count != 7 || name != "Counted" || contentType != "application/json" {
+		t.Fatalf("expected a JSON Counted event with count 7, got %+v (name %q, type %q)", got, name, contentType)
+	}
+}
+
+func TestWebhook_RetriesFailedAttempts(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	if err := newTestWebhook(srv.URL, time.Second, 3).Deliver(context.Background(), countEvent{}); err != nil {
+		t.Fatalf("Deliver: %v", err)
+	}
+	if calls.Load() != 3 {
+		t.Fatalf("expected 3 attempts, got %d", calls.Load())
+	}
+}
+
+func TestWebhook_GivesUpAfterAttempts(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	if err := newTestWebhook(srv.URL, time.Second, 2).Deliver(context.Background(), countEvent{}); err == nil {
+		t.Fatal("expected an error once every attempt failed")
+	}
+	if calls.Load() != 2 {
+		t.Fatalf("expected 2 attempts, got %d", calls.Load())
+	}
+}
+
+func TestWebhook_AttemptsAreBoundedByTimeout(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	start := time.Now()
+	err := newTestWebhook(srv.URL, 20*time.Millisecond, 2).Deliver(context.Background(), countEvent{})
+	if err == nil {
+		t.Fatal("expected the hanging webhook to time out")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("expected two 20ms attempts, took %s", elapsed)
+	}
+}
@@ -0,0 +1,87 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/oggyb/insider-assessment/internal/request"
+	"github.com/oggyb/insider-assessment/internal/response"
+	"github.com/oggyb/insider-assessment/internal/service"
+)
+
+// ConfigHandler exposes runtime-tunable settings of the message service.
+type ConfigHandler struct {
+	msgSvc service.MessageService
+
+	settings
+}
+
+// NewConfigHandler constructs a new ConfigHandler.
+func NewConfigHandler(msgSvc service.MessageService, opts ...Option) *ConfigHandler {
+	return &ConfigHandler{msgSvc: msgSvc, settings: newSettings(opts)}
+}
+
+// GetWorkerConfig godoc
+// @Summary     Get worker config
+// @Description Returns the batch size, worker count and per-message timeout currently used by the batch processor.
+// @Tags        config
+// @Produce     json
+// @Success     200 {object} response.WorkerConfigResponse
+// @Router      /config/worker [get]
+func (h *ConfigHandler) GetWorkerConfig(w http.ResponseWriter, r *http.Request) {
+	h.resp.JSON(w, http.StatusOK, toWorkerConfigPayload(h.msgSvc.WorkerConfig()))
+}
+
+// UpdateWorkerConfig godoc
+// @Summary     Update worker config
+// @Description Partially updates the batch processor settings. Omitted fields are left unchanged; the next batch picks up the new values. Requires the X-API-Key header.
+// @Tags        config
+// @Accept      json
+// @Produce     json
+// @Param       request body request.WorkerConfigRequest true "Fields to update"
+// @Success     200 {object} response.WorkerConfigResponse
+// @Failure     400 {object} map[string]string
+// @Failure     401 {object} map[string]string
+// @Router      /config/worker [patch]
+func (h *ConfigHandler) UpdateWorkerConfig(w http.ResponseWriter, r *http.Request) {
+	var req request.WorkerConfigRequest
+
+	if err := request.DecodeStrict(r, &req); err != nil {
+		h.resp.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	update := service.WorkerConfigUpdate{
+		BatchSize:  req.BatchSize,
+		MaxWorkers: req.MaxWorkers,
+	}
+	if req.PerMessageTimeout != nil {
+		d, err := time.ParseDuration(*req.PerMessageTimeout)
+		if err != nil {
+			h.resp.Error(w, http.StatusBadRequest, "perMessageTimeout must be a duration (e.g. '5s')")
+			return
+		}
+		update.PerMessageTimeout = &d
+	}
+
+	cfg, err := h.msgSvc.UpdateWorkerConfig(update)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, service.ErrInvalidWorkerConfig) {
+			status = http.StatusBadRequest
+		}
+		h.resp.Error(w, status, err.Error())
+		return
+	}
+
+	h.resp.JSON(w, http.StatusOK, toWorkerConfigPayload(cfg))
+}
+
+func toWorkerConfigPayload(c service.WorkerConfig) response.WorkerConfigPayload {
+	return response.WorkerConfigPayload{
+		BatchSize:         c.BatchSize,
+		MaxWorkers:        c.MaxWorkers,
+		PerMessageTimeout: c.PerMessageTimeout.String(),
+	}
+}
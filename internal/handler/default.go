@@ -1,16 +1,34 @@
 package handler
 
 import (
+	"context"
 	"net/http"
+	"time"
 
 	"github.com/oggyb/insider-assessment/internal/response"
 )
 
+// Pinger checks that a dependency is reachable, e.g. the database behind
+// the message service.
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// readyTimeout bounds the dependency check of a readiness probe.
+const readyTimeout = 2 * time.Second
+
 // HomeHandler serves basic root, health and ping endpoints.
-type HomeHandler struct{}
+type HomeHandler struct {
+	// db is checked by Ready; nil reports ready without checking.
+	db Pinger
 
-// NewHomeHandler returns a new HomeHandler.
-func NewHomeHandler() *HomeHandler { return &HomeHandler{} }
+	settings
+}
+
+// NewHomeHandler returns a new HomeHandler whose readiness probe pings db.
+func NewHomeHandler(db Pinger, opts ...Option) *HomeHandler {
+	return &HomeHandler{db: db, settings: newSettings(opts)}
+}
 
 // Index godoc
 // @Summary     Welcome endpoint
@@ -24,7 +42,7 @@ func (h *HomeHandler) Index(w http.ResponseWriter, r *http.Request) {
 		Message: "Welcome to Insider Messaging Assessment",
 	}
 
-	response.RespondJSON(w, http.StatusOK, payload)
+	h.resp.JSON(w, http.StatusOK, payload)
 }
 
 // Health godoc
@@ -39,5 +57,27 @@ func (h *HomeHandler) Health(w http.ResponseWriter, r *http.Request) {
 		Status: "ok",
 	}
 
-	response.RespondJSON(w, http.StatusOK, payload)
+	h.resp.JSON(w, http.StatusOK, payload)
+}
+
+// Ready godoc
+// @Summary     Readiness check
+// @Description Pings the database and reports whether the API can serve traffic. Unlike /health it fails while the database is unreachable.
+// @Tags        home
+// @Produce     json
+// @Success     200 {object} response.HealthResponse
+// @Failure     503 {object} map[string]string "Database unreachable"
+// @Router      /health/ready [get]
+func (h *HomeHandler) Ready(w http.ResponseWriter, r *http.Request) {
+	if h.db != nil {
+		ctx, cancel := context.WithTimeout(r.Context(), readyTimeout)
+		defer cancel()
+
+		if err := h.db.Ping(ctx); err != nil {
+			h.resp.Error(w, http.StatusServiceUnavailable, "database unavailable: "+err.Error())
+			return
+		}
+	}
+
+	h.resp.JSON(w, http.StatusOK, response.HealthPayload{Status: "ready"})
 }
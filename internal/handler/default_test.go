@@ -0,0 +1,60 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/oggyb/insider-assessment/internal/response"
+)
+
+// pingFunc adapts a function to Pinger.
+type pingFunc func(ctx context.Context) error
+
+func (f pingFunc) Ping(ctx context.Context) error { return f(ctx) }
+
+func TestReady_ReportsDatabaseState(t *testing.T) {
+	cases := []struct {
+		name     string
+		ping     error
+		wantCode int
+		wantBody string
+	}{
+		{"reachable", nil, http.StatusOK, `"status":"ready"`},
+		{"unreachable", errors.New("connection refused"), http.StatusServiceUnavailable, "database unavailable: connection refused"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var hadDeadline bool
+			h := NewHomeHandler(pingFunc(func(ctx context.Context) error {
+				_, hadDeadline = ctx.Deadline()
+				return tc.ping
+			}))
+
+			rec := httptest.NewRecorder()
+			h.Ready(rec, httptest.NewRequest(http.MethodGet, "/health/ready", nil))
+
+			if rec.Code != tc.wantCode || !strings.Contains(rec.Body.String(), tc.wantBody) {
+				t.Fatalf("got %d %s, want %d containing %q", rec.Code, rec.Body.String(), tc.wantCode, tc.wantBody)
+			}
+			if !hadDeadline {
+				t.Fatal("expected the ping to be bounded by a deadline")
+			}
+		})
+	}
+}
+
+func TestHomeHandler_WritesWithConfiguredResponder(t *testing.T) {
+	unix := func(time.Time) string { return "1714979289" }
+	h := NewHomeHandler(nil, WithResponder(response.NewResponder(response.WithTimeFormatter(unix))))
+
+	rec := httptest.NewRecorder()
+	h.Health(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+	if !strings.Contains(rec.Body.String(), `"timestamp":"1714979289"`) {
+		t.Fatalf("expected the responder's timestamp format, got %s", rec.Body.String())
+	}
+}
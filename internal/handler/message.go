@@ -1,116 +1,908 @@
 package handler
 
 import (
+	"bufio"
+	"encoding/csv"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	domain "github.com/oggyb/insider-assessment/internal/domain/message"
 	"github.com/oggyb/insider-assessment/internal/request"
 	"github.com/oggyb/insider-assessment/internal/response"
 	"github.com/oggyb/insider-assessment/internal/scheduler"
 	"github.com/oggyb/insider-assessment/internal/service"
+	"log"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 )
 
 // MessageHandler wires HTTP endpoints to the message service
 // and the background scheduler.
 type MessageHandler struct {
 	msgSvc service.MessageService
+	// schSvc is nil when the scheduler is disabled in this process.
 	schSvc scheduler.SchedulerService
+
+	settings
 }
 
 // NewMessageHandler constructs a new MessageHandler with its dependencies.
-func NewMessageHandler(msgSvc service.MessageService, schSvc scheduler.SchedulerService) *MessageHandler {
+func NewMessageHandler(
+	msgSvc service.MessageService,
+	schSvc scheduler.SchedulerService,
+	opts ...Option,
+) *MessageHandler {
 	return &MessageHandler{
-		msgSvc: msgSvc,
-		schSvc: schSvc,
+		msgSvc:   msgSvc,
+		schSvc:   schSvc,
+		settings: newSettings(opts),
 	}
 }
 
 // StartStopScheduler godoc
 // @Summary     Control scheduler
 // @Description Starts or stops the background scheduler based on the given action.
+// @Description The response reports the prior (wasRunning) and new (running) state. Starting a running or stopping a stopped scheduler changes nothing and answers 409.
 // @Tags        scheduler
 // @Accept      json
 // @Produce     json
 // @Param       request body request.SchedulerRequest true "Scheduler action (start|stop)"
 // @Success     200 {object} response.SchedulerControlResponse
 // @Failure     400 {object} map[string]string
+// @Failure     409 {object} map[string]string "Scheduler disabled (SCHEDULER_ENABLED=false), already running or already stopped"
+// @Failure     503 {object} map[string]string "Scheduler closed (the process is shutting down)"
 // @Router      /scheduler [post]
 func (h *MessageHandler) StartStopScheduler(w http.ResponseWriter, r *http.Request) {
-	var req request.SchedulerRequest
+	if h.schSvc == nil {
+		h.resp.Error(w, http.StatusConflict, "scheduler disabled")
+		return
+	}
+
+	req := request.SchedulerRequest{AllowNumeric: h.numericSchedulerActions}
+
+	if err := request.DecodeStrict(r, &req); err != nil {
+		h.resp.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := req.Validate(); err != nil {
+		h.resp.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	run := req.Action == request.ActionStart
+
+	wasRunning, err := h.schSvc.SetRunning(run)
+	if errors.Is(err, scheduler.ErrSchedulerClosed) {
+		h.resp.Error(w, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+	if err != nil {
+		h.resp.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// A repeated start or stop is most likely a double-posted request;
+	// report it instead of pretending something changed.
+	if wasRunning == run {
+		h.resp.Error(w, http.StatusConflict, schedulerControlMessage(wasRunning, run))
+		return
+	}
+
+	payload := response.SchedulerControlPayload{
+		Message:    schedulerControlMessage(wasRunning, run),
+		WasRunning: wasRunning,
+		Running:    run,
+	}
+	h.resp.JSON(w, http.StatusOK, payload)
+}
+
+// ListSchedulerRuns godoc
+// @Summary     List scheduler batch runs
+// @Description Returns a paginated history of batch runs (start, duration, processed, succeeded and failed counts), most recent first.
+// @Description Runs are read from the database, so this also works when SCHEDULER_ENABLED=false and another process runs the batches.
+// @Tags        scheduler
+// @Produce     json
+// @Param       page  query int false "Page number"         default(1)
+// @Param       limit query int false "Page size (max 100; see API_PAGE_SIZES)" default(20)
+// @Success     200 {object} response.BatchRunListResponse
+// @Failure     500 {object} map[string]string
+// @Router      /scheduler/runs [get]
+func (h *MessageHandler) ListSchedulerRuns(w http.ResponseWriter, r *http.Request) {
+	page, limit := h.parsePagination(r, "/scheduler/runs")
 
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		response.RespondError(w, http.StatusBadRequest, "invalid JSON body")
+	runs, total, err := h.msgSvc.ListBatchRuns(r.Context(), page, limit)
+	if err != nil {
+		h.resp.Error(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
-	switch req.Action {
-	case "start":
-		if err := h.schSvc.Start(); err != nil {
-			response.RespondError(w, http.StatusBadRequest, err.Error())
+	h.resp.JSON(w, http.StatusOK, response.BatchRunListPayload{
+		Items: response.FromDomainBatchRuns(runs),
+		Total: total,
+		Page:  page,
+		Limit: limit,
+	})
+}
+
+// GetSchedulerStatus godoc
+// @Summary     Scheduler status
+// @Description Reports whether this process's scheduler is running and, with SCHEDULER_LEADER_ELECTION, whether it currently holds the leadership lease.
+// @Description Only the leader runs batches; the other replicas stand by and take over once its lease expires.
+// @Tags        scheduler
+// @Produce     json
+// @Success     200 {object} response.SchedulerStatusResponse
+// @Failure     409 {object} map[string]string
+// @Router      /scheduler/status [get]
+func (h *MessageHandler) GetSchedulerStatus(w http.ResponseWriter, r *http.Request) {
+	if h.schSvc == nil {
+		h.resp.Error(w, http.StatusConflict, "scheduler disabled")
+		return
+	}
+
+	l := h.schSvc.Leadership()
+	h.resp.JSON(w, http.StatusOK, response.SchedulerStatusPayload{
+		Running:        h.schSvc.IsRunning(),
+		LeaderElection: l.Enabled,
+		Leader:         l.Leader,
+		InstanceID:     l.ID,
+	})
+}
+
+// GetSchedulerEvents godoc
+// @Summary     Recent scheduler activity
+// @Description Returns what the scheduler of this process did lately (starts, stops, batch runs with their counts, failures and interval changes), most recent first.
+// @Description The activity is kept in memory, so it only covers this process since it started and holds at most SCHEDULER_EVENTS_SIZE entries.
+// @Tags        scheduler
+// @Produce     json
+// @Success     200 {object} response.SchedulerEventListResponse
+// @Failure     409 {object} map[string]string
+// @Router      /scheduler/events [get]
+func (h *MessageHandler) GetSchedulerEvents(w http.ResponseWriter, r *http.Request) {
+	if h.schSvc == nil {
+		h.resp.Error(w, http.StatusConflict, "scheduler disabled")
+		return
+	}
+
+	h.resp.JSON(w, http.StatusOK, response.SchedulerEventListPayload{
+		Items: schedulerEventDTOs(h.schSvc.RecentActivity()),
+	})
+}
+
+// schedulerEventDTOs converts scheduler activity into DTOs.
+func schedulerEventDTOs(activity []scheduler.Activity) []response.SchedulerEventDTO {
+	out := make([]response.SchedulerEventDTO, len(activity))
+	for i, a := range activity {
+		out[i] = response.SchedulerEventDTO{
+			At:      a.At,
+			Kind:    string(a.Kind),
+			Message: a.Message,
+			Error:   a.Error,
+		}
+		if a.Result != nil {
+			counts, ms := *a.Result, a.Duration.Milliseconds()
+			out[i].Processed = &counts.Processed
+			out[i].Succeeded = &counts.Succeeded
+			out[i].Failed = &counts.Failed
+			out[i].DurationMs = &ms
+		}
+	}
+	return out
+}
+
+// schedulerControlMessage describes the outcome of a start/stop request,
+// distinguishing real transitions from no-ops.
+func schedulerControlMessage(wasRunning, running bool) string {
+	switch {
+	case running && wasRunning:
+		return "scheduler already running"
+	case running:
+		return "scheduler started"
+	case wasRunning:
+		return "scheduler stopped"
+	default:
+		return "scheduler already stopped"
+	}
+}
+
+// CreateMessage godoc
+// @Summary     Create message
+// @Description Enqueues a new PENDING message. An optional expiresAt (RFC3339) or ttl (e.g. "15m") drops the message as EXPIRED if it cannot be sent in time.
+// @Description An optional sendTimeout (e.g. "2s", at least 1ms) overrides the per-message provider timeout for this message.
+// @Description An optional encoding (GSM7 or UCS2) overrides the encoding hint sent to the provider, which is otherwise derived from the content.
+// @Tags        messages
+// @Accept      json
+// @Produce     json
+// @Param       request body request.CreateMessageRequest true "Message to enqueue"
+// @Success     201 {object} response.MessageResponse
+// @Failure     400 {object} map[string]string
+// @Failure     409 {object} map[string]string "Identical message already pending (MESSAGE_DEDUP_CONTENT) or sent or queued within MESSAGE_DEDUP_WINDOW"
+// @Failure     500 {object} map[string]string
+// @Failure     503 {object} map[string]string "Too many concurrent creates (MESSAGE_CREATE_CONCURRENCY)"
+// @Router      /messages [post]
+func (h *MessageHandler) CreateMessage(w http.ResponseWriter, r *http.Request) {
+	var req request.CreateMessageRequest
+
+	if err := request.DecodeStrict(r, &req); err != nil {
+		h.resp.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	msg, err := h.newMessage(req)
+	if err != nil {
+		h.resp.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := h.msgSvc.Create(r.Context(), msg); err != nil {
+		if errors.Is(err, domain.ErrDuplicateMessage) {
+			h.resp.Error(w, http.StatusConflict, domain.ErrDuplicateMessage.Error())
 			return
 		}
+		if errors.Is(err, domain.ErrRecentDuplicate) {
+			h.resp.Error(w, http.StatusConflict, err.Error())
+			return
+		}
+		// Content checks only fail here once the opt-out link is appended.
+		if errors.Is(err, domain.ErrContentTooLong) || errors.Is(err, domain.ErrNonGSM7Content) {
+			h.resp.Error(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if errors.Is(err, service.ErrCreateSaturated) {
+			h.resp.Error(w, http.StatusServiceUnavailable, service.ErrCreateSaturated.Error())
+			return
+		}
+		h.resp.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	h.resp.JSON(w, http.StatusCreated, h.resp.Message(msg).WithFieldCase(h.resp.FieldCase(r)))
+}
 
-		payload := response.SchedulerControlPayload{
-			Message: "scheduler started",
+// newMessage builds a message from a create request under the configured
+// content rules. Its errors are meant to be reported as 400s.
+func (h *MessageHandler) newMessage(req request.CreateMessageRequest) (*domain.Message, error) {
+	var opts []domain.Option
+	switch {
+	case req.ExpiresAt != nil && req.TTL != "":
+		return nil, errors.New("only one of 'expiresAt' or 'ttl' may be set")
+	case req.ExpiresAt != nil:
+		opts = append(opts, domain.WithExpiresAt(*req.ExpiresAt))
+	case req.TTL != "":
+		ttl, err := time.ParseDuration(req.TTL)
+		if err != nil || ttl <= 0 {
+			return nil, errors.New("ttl must be a positive duration (e.g. '15m')")
 		}
-		response.RespondJSON(w, http.StatusOK, payload)
+		opts = append(opts, domain.WithTTL(ttl))
+	}
+
+	if req.SendTimeout != "" {
+		d, err := time.ParseDuration(req.SendTimeout)
+		// Send timeouts are stored in whole milliseconds.
+		if err != nil || d < time.Millisecond {
+			return nil, errors.New("sendTimeout must be a duration of at least 1ms (e.g. '2s')")
+		}
+		opts = append(opts, domain.WithSendTimeout(d))
+	}
+
+	if req.Provider != "" {
+		opts = append(opts, domain.WithProvider(req.Provider))
+	}
+	if req.Encoding != "" {
+		enc, err := domain.ParseEncoding(req.Encoding)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, domain.WithEncoding(enc))
+	}
+	if len(req.Tags) > 0 {
+		opts = append(opts, domain.WithTags(req.Tags))
+	}
+
+	return h.contentRules.NewMessage(req.To, req.Content, opts...)
+}
+
+// Multicast godoc
+// @Summary     Send a message to several recipients
+// @Description Enqueues one PENDING message per recipient, tagged group=<id>. The optional fields behave as in POST /messages and apply to every recipient.
+// @Description Up to MULTICAST_ASYNC_THRESHOLD recipients the messages are created before responding (201). Larger groups, up to MULTICAST_MAX_RECIPIENTS, are accepted with 202 and created in the background; follow them with GET /groups/{id}/status.
+// @Description Recipients whose message cannot be saved (e.g. duplicates) are counted as failed without stopping the group.
+// @Tags        messages
+// @Accept      json
+// @Produce     json
+// @Param       request body request.MulticastRequest true "Message and recipients"
+// @Success     201 {object} response.MulticastResponse
+// @Success     202 {object} response.GroupStatusResponse
+// @Failure     400 {object} map[string]string
+// @Failure     500 {object} map[string]string
+// @Failure     503 {object} map[string]string "Too many groups or creates in progress"
+// @Router      /messages/multicast [post]
+func (h *MessageHandler) Multicast(w http.ResponseWriter, r *http.Request) {
+	var req request.MulticastRequest
+
+	if err := request.DecodeStrict(r, &req); err != nil {
+		h.resp.Error(w, http.StatusBadRequest, err.Error())
 		return
+	}
 
-	case "stop":
-		if err := h.schSvc.Stop(); err != nil {
-			response.RespondError(w, http.StatusBadRequest, err.Error())
+	if len(req.To) == 0 {
+		h.resp.Error(w, http.StatusBadRequest, "to must list at least one recipient")
+		return
+	}
+	// Keys are trimmed by WithTags, so " group" would clash too.
+	for k := range req.Tags {
+		if strings.TrimSpace(k) == service.GroupTag {
+			h.resp.Error(w, http.StatusBadRequest, fmt.Sprintf("tag %q is reserved for the group id", service.GroupTag))
 			return
 		}
+	}
+	if len(req.Tags) >= domain.MaxTags {
+		h.resp.Error(w, http.StatusBadRequest, fmt.Sprintf("at most %d tags are allowed alongside the group tag", domain.MaxTags-1))
+		return
+	}
 
-		payload := response.SchedulerControlPayload{
-			Message: "scheduler stopped",
+	msgs := make([]*domain.Message, 0, len(req.To))
+	for i, to := range req.To {
+		msg, err := h.newMessage(req.Message(to))
+		if err != nil {
+			h.resp.Error(w, http.StatusBadRequest, fmt.Sprintf("to[%d]: %v", i, err))
+			return
 		}
-		response.RespondJSON(w, http.StatusOK, payload)
+		msgs = append(msgs, msg)
+	}
+
+	res, err := h.msgSvc.Multicast(r.Context(), msgs)
+	switch {
+	case errors.Is(err, service.ErrTooManyRecipients):
+		h.resp.Error(w, http.StatusBadRequest, err.Error())
+		return
+	case errors.Is(err, service.ErrMulticastSaturated), errors.Is(err, service.ErrCreateSaturated):
+		h.resp.Error(w, http.StatusServiceUnavailable, err.Error())
 		return
+	case err != nil:
+		h.resp.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
 
-	default:
-		response.RespondError(w, http.StatusBadRequest, "action must be 'start' or 'stop'")
+	if res.Async {
+		w.Header().Set("Location", "/groups/"+res.Status.ID.String()+"/status")
+		h.resp.JSON(w, http.StatusAccepted, groupStatusPayload(res.Status))
+		return
+	}
+
+	h.resp.JSON(w, http.StatusCreated, response.MulticastPayload{
+		Group:    groupStatusPayload(res.Status),
+		Messages: response.MessagesWithFieldCase(h.resp.Messages(res.Messages), h.resp.FieldCase(r)),
+	})
+}
+
+// GetGroupStatus godoc
+// @Summary     Multicast group progress
+// @Description Reports how many messages of a multicast group have been created so far. Progress is kept in memory by the instance that accepted the group, for an hour after it finishes.
+// @Tags        messages
+// @Produce     json
+// @Param       id path string true "Group ID"
+// @Success     200 {object} response.GroupStatusResponse
+// @Failure     400 {object} map[string]string
+// @Failure     404 {object} map[string]string
+// @Router      /groups/{id}/status [get]
+func (h *MessageHandler) GetGroupStatus(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		h.resp.Error(w, http.StatusBadRequest, "invalid group id")
+		return
+	}
+
+	status, err := h.msgSvc.GroupStatus(id)
+	if errors.Is(err, service.ErrGroupNotFound) {
+		h.resp.Error(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		h.resp.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	h.resp.JSON(w, http.StatusOK, groupStatusPayload(status))
+}
+
+// groupStatusPayload maps a group's progress to its response payload.
+func groupStatusPayload(g service.GroupStatus) response.GroupStatusPayload {
+	return response.GroupStatusPayload{
+		ID:         g.ID.String(),
+		Total:      g.Total,
+		Created:    g.Created,
+		Failed:     g.Failed,
+		Done:       g.Done,
+		StartedAt:  g.StartedAt,
+		FinishedAt: g.FinishedAt,
+	}
+}
+
+// ListMessages godoc
+// @Summary     List messages
+// @Description Returns a paginated list of messages of any status, newest first.
+// @Description Filter by tags with tag.<key>=<value> query parameters; all given tags must match.
+// @Tags        messages
+// @Produce     json
+// @Param       page  query int    false "Page number"         default(1)
+// @Param       limit query int    false "Page size (max 100; see API_PAGE_SIZES)" default(20)
+// @Param       tag.env query string false "Example tag filter (any tag.<key> is accepted)"
+// @Success     200 {object} response.MessageListResponse
+// @Failure     500 {object} map[string]string
+// @Router      /messages [get]
+func (h *MessageHandler) ListMessages(w http.ResponseWriter, r *http.Request) {
+	page, limit := h.parsePagination(r, "/messages")
+
+	filter := domain.ListFilter{Tags: parseTagFilter(r)}
+
+	items, total, err := h.msgSvc.List(r.Context(), filter, page, limit)
+	if err != nil {
+		h.resp.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	h.resp.JSON(w, http.StatusOK, response.MessageListPayload{
+		Items: response.MessagesWithFieldCase(h.resp.Messages(items), h.resp.FieldCase(r)),
+		Total: total,
+		Page:  page,
+		Limit: limit,
+	})
+}
+
+// exportColumns is the header row of the CSV export.
+var exportColumns = []string{
+	"id", "to", "content", "status", "message_id", "provider", "retry_count",
+	"status_reason", "created_at", "sent_at", "expires_at",
+}
+
+// exportFlushEvery is how many CSV rows are buffered before they are
+// flushed to the client.
+const exportFlushEvery = 100
+
+// ExportMessages godoc
+// @Summary     Export messages as CSV
+// @Description Streams every message created between from and until (UTC days, inclusive) as CSV, oldest first, without pagination.
+// @Description Defaults to the last 7 days; the range may span at most 92 days. Requires the X-API-Key header.
+// @Tags        messages
+// @Produce     text/csv
+// @Param       from  query string false "First day (YYYY-MM-DD)"
+// @Param       until query string false "Last day (YYYY-MM-DD), defaults to today"
+// @Success     200 {string} string "CSV file"
+// @Failure     400 {object} map[string]string
+// @Failure     401 {object} map[string]string
+// @Failure     500 {object} map[string]string
+// @Router      /messages/export [get]
+func (h *MessageHandler) ExportMessages(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	until := time.Now().UTC().Truncate(24 * time.Hour)
+	if v := q.Get("until"); v != "" {
+		t, err := time.Parse(reportDateLayout, v)
+		if err != nil {
+			h.resp.Error(w, http.StatusBadRequest, "until must be a date in YYYY-MM-DD format")
+			return
+		}
+		until = t
+	}
+
+	from := until.AddDate(0, 0, -(defaultReportDays - 1))
+	if v := q.Get("from"); v != "" {
+		t, err := time.Parse(reportDateLayout, v)
+		if err != nil {
+			h.resp.Error(w, http.StatusBadRequest, "from must be a date in YYYY-MM-DD format")
+			return
+		}
+		from = t
+	}
+
+	// Headers are sent with the first row, so a failure before any row can
+	// still be reported as a regular error response.
+	cw := csv.NewWriter(w)
+	started, rows := false, 0
+	start := func() {
+		filename := fmt.Sprintf("messages_%s_%s.csv", from.Format(reportDateLayout), until.Format(reportDateLayout))
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+		w.WriteHeader(http.StatusOK)
+		_ = cw.Write(exportColumns)
+		started = true
+	}
+
+	err := h.msgSvc.Export(r.Context(), from, until.AddDate(0, 0, 1), func(m *domain.Message) error {
+		if !started {
+			start()
+		}
+		if err := cw.Write(exportRecord(m)); err != nil {
+			return err
+		}
+		if rows++; rows%exportFlushEvery == 0 {
+			cw.Flush()
+			return cw.Error()
+		}
+		return nil
+	})
+	switch {
+	case errors.Is(err, service.ErrInvalidExportRange):
+		h.resp.Error(w, http.StatusBadRequest, err.Error())
 		return
+	case err != nil && !started:
+		h.resp.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	case err != nil:
+		// The status line is already out; all we can do is cut the file short.
+		log.Printf("[Handler] Export aborted after %d rows: %v", rows, err)
+		return
+	}
+
+	if !started {
+		start()
+	}
+	cw.Flush()
+}
+
+// exportRecord renders a message as a CSV row matching exportColumns.
+func exportRecord(m *domain.Message) []string {
+	return []string{
+		m.ID.String(),
+		m.To,
+		m.Content,
+		string(m.Status),
+		m.MessageID,
+		m.Provider,
+		strconv.Itoa(m.RetryCount),
+		m.StatusReason,
+		m.CreatedAt.UTC().Format(time.RFC3339),
+		formatOptionalTime(m.SentAt),
+		formatOptionalTime(m.ExpiresAt),
+	}
+}
+
+// formatOptionalTime renders t as RFC3339 in UTC, or "" when t is nil.
+func formatOptionalTime(t *time.Time) string {
+	if t == nil {
+		return ""
 	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// tagQueryPrefix marks query parameters that filter by tag (tag.env=staging).
+const tagQueryPrefix = "tag."
+
+// parseTagFilter collects tag.<key>=<value> query parameters. If a key is
+// repeated, the first value wins.
+func parseTagFilter(r *http.Request) map[string]string {
+	var tags map[string]string
+	for key, values := range r.URL.Query() {
+		name, ok := strings.CutPrefix(key, tagQueryPrefix)
+		if !ok || name == "" || len(values) == 0 {
+			continue
+		}
+		if tags == nil {
+			tags = map[string]string{}
+		}
+		tags[name] = values[0]
+	}
+	return tags
+}
+
+// defaultPageSize is the limit of list endpoints without a configured one.
+const defaultPageSize = 20
+
+// WithPageSizes configures the page sizes of list endpoints by path (e.g.
+// "/messages/sent"). Zero values keep defaultPageSize and
+// domain.MaxPageLimit. Max is capped at domain.MaxPageLimit, which the
+// repositories enforce anyway (config rejects larger values up front), and
+// Default at Max.
+func WithPageSizes(sizes map[string]domain.PageSize) Option {
+	return func(st *settings) {
+		st.pageSizes = make(map[string]domain.PageSize, len(sizes))
+		for path, s := range sizes {
+			if s.Max <= 0 || s.Max > domain.MaxPageLimit {
+				s.Max = domain.MaxPageLimit
+			}
+			if s.Default <= 0 {
+				s.Default = defaultPageSize
+			}
+			s.Default = min(s.Default, s.Max)
+			st.pageSizes[path] = s
+		}
+	}
+}
+
+// parsePagination reads page and limit from the query string, defaulting
+// to page 1 of path's configured page size (20 unless set). A limit above
+// the path's maximum (domain.MaxPageLimit unless set) is ignored like an
+// invalid one, so it also gets the default size.
+func (st settings) parsePagination(r *http.Request, path string) (page, limit int) {
+	size, ok := st.pageSizes[path]
+	if !ok {
+		size = domain.PageSize{Default: defaultPageSize, Max: domain.MaxPageLimit}
+	}
+	page, limit = 1, size.Default
+
+	if v, err := strconv.Atoi(r.URL.Query().Get("page")); err == nil && v > 0 {
+		page = v
+	}
+	if v, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && v > 0 && v <= size.Max {
+		limit = v
+	}
+	return page, limit
 }
 
 // GetSentMessages godoc
 // @Summary     List sent messages
 // @Description Returns a paginated list of successfully sent messages.
+// @Description Pages past the last page are flagged with outOfRange, or return 404 when strict pagination is enabled.
+// @Description With format=ndjson, every sent message is streamed instead, one JSON object per line without the envelope; page and limit are ignored.
 // @Tags        messages
 // @Produce     json
-// @Param       page  query int false "Page number"         default(1)
-// @Param       limit query int false "Page size (max 100)" default(20)
+// @Produce     application/x-ndjson
+// @Param       page   query int    false "Page number"         default(1)
+// @Param       limit  query int    false "Page size (max 100; see API_PAGE_SIZES)" default(20)
+// @Param       format query string false "json (default) or ndjson"
 // @Success     200 {object} response.SentMessagesResponse
+// @Failure     400 {object} map[string]string
+// @Failure     404 {object} map[string]string
 // @Failure     500 {object} map[string]string
 // @Router      /messages/sent [get]
 func (h *MessageHandler) GetSentMessages(w http.ResponseWriter, r *http.Request) {
-	pageStr := r.URL.Query().Get("page")
-	limitStr := r.URL.Query().Get("limit")
+	switch r.URL.Query().Get("format") {
+	case "", "json":
+	case "ndjson":
+		h.streamSentNDJSON(w, r)
+		return
+	default:
+		h.resp.Error(w, http.StatusBadRequest, "format must be json or ndjson")
+		return
+	}
 
-	page := 1
-	limit := 20
+	page, limit := h.parsePagination(r, "/messages/sent")
 
-	if v, err := strconv.Atoi(pageStr); err == nil && v > 0 {
-		page = v
+	items, total, err := h.msgSvc.GetSent(r.Context(), page, limit)
+	outOfRange := errors.Is(err, service.ErrPageOutOfRange)
+	if outOfRange && h.strictPagination {
+		h.resp.Error(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil && !outOfRange {
+		h.resp.Error(w, http.StatusInternalServerError, err.Error())
+		return
 	}
 
-	if v, err := strconv.Atoi(limitStr); err == nil && v > 0 && v <= 100 {
-		limit = v
+	payload := response.SentMessagesPayload{
+		Items:      response.MessagesWithFieldCase(h.resp.Messages(items), h.resp.FieldCase(r)),
+		Total:      total,
+		Page:       page,
+		Limit:      limit,
+		OutOfRange: outOfRange,
 	}
 
-	items, total, err := h.msgSvc.GetSent(r.Context(), page, limit)
+	h.resp.JSON(w, http.StatusOK, payload)
+}
+
+// streamSentNDJSON writes every sent message as one JSON line, flushing
+// periodically so clients can process them as they arrive.
+func (h *MessageHandler) streamSentNDJSON(w http.ResponseWriter, r *http.Request) {
+	fieldCase := h.resp.FieldCase(r)
+
+	// Headers are sent with the first line, so a failure before any line
+	// can still be reported as a regular error response.
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	started, lines := false, 0
+	start := func() {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.WriteHeader(http.StatusOK)
+		started = true
+	}
+
+	err := h.msgSvc.StreamSent(r.Context(), func(m *domain.Message) error {
+		if !started {
+			start()
+		}
+		if err := enc.Encode(h.resp.Message(m).WithFieldCase(fieldCase)); err != nil {
+			return err
+		}
+		if lines++; lines%exportFlushEvery == 0 {
+			if err := bw.Flush(); err != nil {
+				return err
+			}
+			_ = http.NewResponseController(w).Flush()
+		}
+		return nil
+	})
+	switch {
+	case err != nil && !started:
+		h.resp.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	case err != nil:
+		// The status line is already out; all we can do is cut the stream short.
+		log.Printf("[Handler] NDJSON stream aborted after %d lines: %v", lines, err)
+		return
+	}
+
+	if !started {
+		start()
+	}
+	_ = bw.Flush()
+}
+
+// GetMessageTimeline godoc
+// @Summary     Message status timeline
+// @Description Returns every status transition of a message (from, to, at), oldest first.
+// @Tags        messages
+// @Produce     json
+// @Param       id path string true "Message ID (UUID)"
+// @Success     200 {object} response.TimelineResponse
+// @Failure     400 {object} map[string]string
+// @Failure     404 {object} map[string]string
+// @Failure     500 {object} map[string]string
+// @Router      /messages/{id}/timeline [get]
+func (h *MessageHandler) GetMessageTimeline(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(r.PathValue("id"))
 	if err != nil {
-		response.RespondError(w, http.StatusInternalServerError, err.Error())
+		h.resp.Error(w, http.StatusBadRequest, "invalid message id")
 		return
 	}
 
-	payload := response.SentMessagesPayload{
-		Items: response.FromDomainMessages(items),
-		Total: total,
-		Page:  page,
-		Limit: limit,
+	events, err := h.msgSvc.GetTimeline(r.Context(), id)
+	if errors.Is(err, domain.ErrNotFound) {
+		h.resp.Error(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		h.resp.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	h.resp.JSON(w, http.StatusOK, response.TimelinePayload{
+		MessageID: id.String(),
+		Events:    response.FromDomainStatusEvents(events),
+	})
+}
+
+// RetryMessage godoc
+// @Summary     Retry a failed message
+// @Description Requeues a single FAILED message as PENDING (resetting retryCount) so the next batch sends it again; answers 409 if it is not, or no longer, FAILED. Requires the X-API-Key header.
+// @Tags        messages
+// @Produce     json
+// @Param       id path string true "Message ID (UUID)"
+// @Success     200 {object} response.MessageResponse
+// @Failure     400 {object} map[string]string
+// @Failure     401 {object} map[string]string
+// @Failure     404 {object} map[string]string
+// @Failure     409 {object} map[string]string
+// @Failure     500 {object} map[string]string
+// @Router      /messages/{id}/retry [post]
+func (h *MessageHandler) RetryMessage(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		h.resp.Error(w, http.StatusBadRequest, "invalid message id")
+		return
+	}
+
+	msg, err := h.msgSvc.Retry(r.Context(), id)
+	switch {
+	case errors.Is(err, domain.ErrNotFound):
+		h.resp.Error(w, http.StatusNotFound, err.Error())
+		return
+	case errors.Is(err, domain.ErrNotRetryable), errors.Is(err, domain.ErrDuplicateMessage):
+		h.resp.Error(w, http.StatusConflict, err.Error())
+		return
+	case err != nil:
+		h.resp.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	h.resp.JSON(w, http.StatusOK, h.resp.Message(msg).WithFieldCase(h.resp.FieldCase(r)))
+}
+
+// DeleteMessage godoc
+// @Summary     Delete a message
+// @Description Soft-deletes a message: it disappears from listings and is never sent, but its row is kept. Requires the X-API-Key header.
+// @Tags        messages
+// @Param       id path string true "Message ID (UUID)"
+// @Success     204
+// @Failure     400 {object} map[string]string
+// @Failure     401 {object} map[string]string
+// @Failure     404 {object} map[string]string
+// @Failure     500 {object} map[string]string
+// @Router      /messages/{id} [delete]
+func (h *MessageHandler) DeleteMessage(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		h.resp.Error(w, http.StatusBadRequest, "invalid message id")
+		return
+	}
+
+	err = h.msgSvc.Delete(r.Context(), id)
+	switch {
+	case errors.Is(err, domain.ErrNotFound):
+		h.resp.Error(w, http.StatusNotFound, err.Error())
+		return
+	case err != nil:
+		h.resp.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ClearDedup godoc
+// @Summary     Clear a recipient's de-duplication claims
+// @Description Lets an identical message be enqueued again for the recipient (MESSAGE_DEDUP_CONTENT) by releasing the claims of its unsent messages, which stay queued. A recipient without any reports cleared=0. Requires the X-API-Key header.
+// @Description Content de-duplication is enforced by a unique index in the database, not by the cache: this clears the content hash of the recipient's PENDING and PROCESSING messages, and no cache keys are involved.
+// @Description It does not lift MESSAGE_DEDUP_WINDOW: an identical message created within the window is still rejected with 409.
+// @Tags        messages
+// @Produce     json
+// @Param       to path string true "Recipient phone number"
+// @Success     200 {object} response.DedupClearResponse
+// @Failure     401 {object} map[string]string
+// @Failure     500 {object} map[string]string
+// @Router      /dedup/{to} [delete]
+func (h *MessageHandler) ClearDedup(w http.ResponseWriter, r *http.Request) {
+	to := r.PathValue("to")
+
+	cleared, err := h.msgSvc.ClearDedup(r.Context(), to)
+	if err != nil {
+		h.resp.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	h.resp.JSON(w, http.StatusOK, response.DedupClearPayload{To: to, Cleared: cleared})
+}
+
+// ResolveOptOut godoc
+// @Summary     Follow an opt-out link
+// @Description Unsubscribes the recipient whose personal token is in the link appended to their messages (OPT_OUT_LINK_BASE_URL). Following the link again keeps the first opt-out time.
+// @Tags        messages
+// @Produce     json
+// @Param       token path string true "Opt-out token"
+// @Success     200 {object} response.OptOutResponse
+// @Failure     404 {object} map[string]string
+// @Failure     500 {object} map[string]string
+// @Router      /optout/{token} [get]
+func (h *MessageHandler) ResolveOptOut(w http.ResponseWriter, r *http.Request) {
+	tok, err := h.msgSvc.ResolveOptOut(r.Context(), r.PathValue("token"))
+	if errors.Is(err, domain.ErrOptOutTokenNotFound) {
+		h.resp.Error(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		h.resp.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	payload := response.OptOutPayload{Message: "unsubscribed"}
+	if tok.OptedOutAt != nil {
+		payload.OptedOutAt = *tok.OptedOutAt
+	}
+	h.resp.JSON(w, http.StatusOK, payload)
+}
+
+// FollowLink godoc
+// @Summary     Follow a tracking link
+// @Description Counts a click on a short link written into a message by the track-links content transform (LINK_TRACKING_BASE_URL) and redirects to the URL it replaced.
+// @Tags        messages
+// @Param       token path string true "Link token"
+// @Success     302
+// @Failure     404 {object} map[string]string
+// @Failure     500 {object} map[string]string
+// @Router      /l/{token} [get]
+func (h *MessageHandler) FollowLink(w http.ResponseWriter, r *http.Request) {
+	link, err := h.msgSvc.FollowLink(r.Context(), r.PathValue("token"))
+	if errors.Is(err, domain.ErrLinkNotFound) {
+		h.resp.Error(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		h.resp.Error(w, http.StatusInternalServerError, err.Error())
+		return
 	}
 
-	response.RespondJSON(w, http.StatusOK, payload)
+	http.Redirect(w, r, link.URL, http.StatusFound)
 }
@@ -0,0 +1,1307 @@
+package handler
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	domain "github.com/oggyb/insider-assessment/internal/domain/message"
+	"github.com/oggyb/insider-assessment/internal/response"
+	"github.com/oggyb/insider-assessment/internal/scheduler"
+	"github.com/oggyb/insider-assessment/internal/service"
+)
+
+// fakeRepo is an in-memory domain.Repository holding a fixed set of sent messages.
+// Methods the handler tests do not exercise fall through to the nil embedded
+// interface and panic if called.
+type fakeRepo struct {
+	domain.Repository
+	sent []*domain.Message
+
+	// byID backs GetByID for single-message endpoints.
+	byID map[uuid.UUID]*domain.Message
+
+	// saved holds messages created through Save.
+	saved []*domain.Message
+	// saveErr, if set, is returned by Save instead of storing the message.
+	saveErr error
+
+	// runs backs ListBatchRuns, most recent first.
+	runs []*domain.BatchRun
+
+	// dedup counts the unsent messages per recipient holding a content
+	// de-duplication claim, for ClearContentDedup.
+	dedup map[string]int64
+
+	// top backs TopRecipients, already ranked.
+	top []domain.RecipientCount
+
+	// requeueErr is returned by Requeue, e.g. for a message that stopped
+	// being FAILED after it was read.
+	requeueErr error
+}
+
+func (f *fakeRepo) GetByID(ctx context.Context, id uuid.UUID) (*domain.Message, error) {
+	m, ok := f.byID[id]
+	if !ok {
+		return nil, domain.ErrNotFound
+	}
+	return m, nil
+}
+
+func (f *fakeRepo) SoftDelete(ctx context.Context, id uuid.UUID) error {
+	if _, ok := f.byID[id]; !ok {
+		return domain.ErrNotFound
+	}
+	delete(f.byID, id)
+	return nil
+}
+
+func (f *fakeRepo) ClearContentDedup(ctx context.Context, to string) (int64, error) {
+	n := f.dedup[to]
+	delete(f.dedup, to)
+	return n, nil
+}
+
+func (f *fakeRepo) TopRecipients(ctx context.Context, from, until time.Time, limit int) ([]domain.RecipientCount, error) {
+	return f.top[:min(limit, len(f.top))], nil
+}
+
+func (f *fakeRepo) AddStatusEvents(ctx context.Context, events ...*domain.StatusEvent) error {
+	return nil
+}
+
+func (f *fakeRepo) Save(ctx context.Context, m *domain.Message) error {
+	if f.saveErr != nil {
+		return f.saveErr
+	}
+	f.saved = append(f.saved, m)
+	return nil
+}
+
+// List filters saved messages by tags, mirroring JSONB containment.
+func (f *fakeRepo) List(ctx context.Context, filter domain.ListFilter, page, limit int) ([]*domain.Message, int64, error) {
+	var out []*domain.Message
+	for _, m := range f.saved {
+		match := true
+		for k, v := range filter.Tags {
+			if got, ok := m.Tags[k]; !ok || got != v {
+				match = false
+			}
+		}
+		if match {
+			out = append(out, m)
+		}
+	}
+	return out, int64(len(out)), nil
+}
+
+// StreamByCreatedRange yields saved messages created in [from, until), oldest first.
+func (f *fakeRepo) StreamByCreatedRange(ctx context.Context, from, until time.Time, fn func(*domain.Message) error) error {
+	var rows []*domain.Message
+	for _, m := range f.saved {
+		if !m.CreatedAt.Before(from) && m.CreatedAt.Before(until) {
+			rows = append(rows, m)
+		}
+	}
+	sort.SliceStable(rows, func(i, j int) bool { return rows[i].CreatedAt.Before(rows[j].CreatedAt) })
+	for _, m := range rows {
+		if err := fn(m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// StreamSent yields the sent messages in the order GetSent pages them.
+func (f *fakeRepo) StreamSent(ctx context.Context, fn func(*domain.Message) error) error {
+	for _, m := range f.sent {
+		if err := fn(m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *fakeRepo) GetPending(ctx context.Context, limit int) ([]*domain.Message, error) {
+	return nil, nil
+}
+
+func (f *fakeRepo) ExpirePending(ctx context.Context, now time.Time, reason string) ([]uuid.UUID, error) {
+	return nil, nil
+}
+
+func (f *fakeRepo) GetSent(ctx context.Context, page, limit int) ([]*domain.Message, int64, error) {
+	total := int64(len(f.sent))
+	start := (page - 1) * limit
+	if start >= len(f.sent) {
+		return []*domain.Message{}, total, nil
+	}
+	end := start + limit
+	if end > len(f.sent) {
+		end = len(f.sent)
+	}
+	return f.sent[start:end], total, nil
+}
+
+func (f *fakeRepo) UpdateStatus(ctx context.Context, m *domain.Message) error { return nil }
+
+func (f *fakeRepo) Requeue(ctx context.Context, m *domain.Message) error { return f.requeueErr }
+
+func (f *fakeRepo) ListBatchRuns(ctx context.Context, page, limit int) ([]*domain.BatchRun, int64, error) {
+	return f.runs, int64(len(f.runs)), nil
+}
+
+func newSentRepo(t *testing.T, n int) *fakeRepo {
+	t.Helper()
+
+	repo := &fakeRepo{}
+	for i := 0; i < n; i++ {
+		msg, err := domain.NewMessage("+905000000000", "hello")
+		if err != nil {
+			t.Fatalf("NewMessage: %v", err)
+		}
+		msg.MarkSent("ext", "{}")
+		repo.sent = append(repo.sent, msg)
+	}
+	return repo
+}
+
+// sentEnvelope mirrors the JSON envelope for GET /messages/sent.
+type sentEnvelope struct {
+	Success bool `json:"success"`
+	Data    struct {
+		Items      []json.RawMessage `json:"items"`
+		Total      int64             `json:"total"`
+		OutOfRange bool              `json:"outOfRange"`
+	} `json:"data"`
+}
+
+func getSent(t *testing.T, h *MessageHandler, query string) (*httptest.ResponseRecorder, sentEnvelope) {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodGet, "/messages/sent?"+query, nil)
+	rec := httptest.NewRecorder()
+	h.GetSentMessages(rec, req)
+
+	var env sentEnvelope
+	if err := json.NewDecoder(rec.Body).Decode(&env); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	return rec, env
+}
+
+func TestGetSentMessages_InRangePage(t *testing.T) {
+	svc := service.NewMessageService(newSentRepo(t, 5), nil, nil, 0, 0, 0)
+	h := NewMessageHandler(svc, nil, WithStrictPagination(true))
+
+	rec, env := getSent(t, h, "page=2&limit=2")
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	if len(env.Data.Items) != 2 || env.Data.Total != 5 {
+		t.Fatalf("expected 2 items of 5, got %d of %d", len(env.Data.Items), env.Data.Total)
+	}
+	if env.Data.OutOfRange {
+		t.Fatalf("expected outOfRange=false for an in-range page")
+	}
+}
+
+func TestGetSentMessages_ConfiguredPageSize(t *testing.T) {
+	svc := service.NewMessageService(newSentRepo(t, 10), nil, nil, 0, 0, 0)
+	h := NewMessageHandler(svc, nil, WithPageSizes(map[string]domain.PageSize{"/messages/sent": {Default: 3, Max: 4}}))
+
+	if _, env := getSent(t, h, ""); len(env.Data.Items) != 3 {
+		t.Fatalf("expected the configured default of 3 items, got %d", len(env.Data.Items))
+	}
+	if _, env := getSent(t, h, "limit=4"); len(env.Data.Items) != 4 {
+		t.Fatalf("expected the configured max of 4 items, got %d", len(env.Data.Items))
+	}
+	if _, env := getSent(t, h, "limit=50"); len(env.Data.Items) != 3 {
+		t.Fatalf("expected a limit over the max to fall back to the default of 3, got %d", len(env.Data.Items))
+	}
+}
+
+func TestParsePagination_PageSizes(t *testing.T) {
+	st := newSettings([]Option{WithPageSizes(map[string]domain.PageSize{
+		"/messages/sent":  {Default: 10, Max: 50},
+		"/scheduler/runs": {Default: 5},
+		"/messages":       {Default: 80, Max: 500}, // max capped at domain.MaxPageLimit
+		"/other":          {Default: 60, Max: 30},  // default capped at max
+	})})
+
+	cases := []struct {
+		path, query string
+		want        int
+	}{
+		{"/messages/sent", "", 10},
+		{"/messages/sent", "limit=50", 50},
+		{"/messages/sent", "limit=70", 10}, // over the max: default
+		{"/scheduler/runs", "", 5},
+		{"/scheduler/runs", "limit=100", domain.MaxPageLimit},
+		{"/messages", "limit=500", 80},
+		{"/messages", "limit=100", domain.MaxPageLimit},
+		{"/messages", "", 80},
+		{"/other", "", 30},
+		{"/unconfigured", "", 20},
+		{"/unconfigured", "limit=abc", 20},
+		{"/unconfigured", "limit=101", 20},
+	}
+	for _, tc := range cases {
+		_, limit := st.parsePagination(httptest.NewRequest(http.MethodGet, tc.path+"?"+tc.query, nil), tc.path)
+		if limit != tc.want {
+			t.Errorf("%s?%s: limit = %d, want %d", tc.path, tc.query, limit, tc.want)
+		}
+	}
+}
+
+func TestGetSentMessages_OutOfRangeLenient(t *testing.T) {
+	svc := service.NewMessageService(newSentRepo(t, 5), nil, nil, 0, 0, 0)
+	h := NewMessageHandler(svc, nil)
+
+	rec, env := getSent(t, h, "page=9999&limit=2")
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	if len(env.Data.Items) != 0 {
+		t.Fatalf("expected no items, got %d", len(env.Data.Items))
+	}
+	if !env.Data.OutOfRange {
+		t.Fatalf("expected outOfRange=true for a page past the end")
+	}
+}
+
+func TestGetSentMessages_OutOfRangeStrict(t *testing.T) {
+	svc := service.NewMessageService(newSentRepo(t, 5), nil, nil, 0, 0, 0)
+	h := NewMessageHandler(svc, nil, WithStrictPagination(true))
+
+	rec, env := getSent(t, h, "page=9999&limit=2")
+
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", rec.Code)
+	}
+	if env.Success {
+		t.Fatalf("expected success=false in the error envelope")
+	}
+}
+
+func TestGetSentMessages_EmptyFirstPageIsInRange(t *testing.T) {
+	svc := service.NewMessageService(newSentRepo(t, 0), nil, nil, 0, 0, 0)
+	h := NewMessageHandler(svc, nil, WithStrictPagination(true))
+
+	rec, env := getSent(t, h, "")
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	if env.Data.OutOfRange {
+		t.Fatalf("expected first page to be in range even with no records")
+	}
+}
+
+// noopProcessor satisfies scheduler.BatchProcessor without doing any work.
+type noopProcessor struct{}
+
+func (noopProcessor) ProcessBatch(ctx context.Context) (domain.BatchResult, error) {
+	return domain.BatchResult{}, nil
+}
+
+// controlEnvelope mirrors the JSON envelope for POST /scheduler.
+type controlEnvelope struct {
+	Data struct {
+		Message    string `json:"message"`
+		WasRunning bool   `json:"wasRunning"`
+		Running    bool   `json:"running"`
+	} `json:"data"`
+}
+
+func postScheduler(t *testing.T, h *MessageHandler, body string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodPost, "/scheduler", strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	h.StartStopScheduler(rec, req)
+	return rec
+}
+
+// controlSucceeds posts body and decodes the 200 envelope.
+func controlSucceeds(t *testing.T, h *MessageHandler, body string) controlEnvelope {
+	t.Helper()
+
+	rec := postScheduler(t, h, body)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var env controlEnvelope
+	if err := json.NewDecoder(rec.Body).Decode(&env); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	return env
+}
+
+func TestStartStopScheduler_ReportsPriorState(t *testing.T) {
+	sch := scheduler.NewSchedulerService(noopProcessor{}, time.Hour, time.Second)
+	h := NewMessageHandler(nil, sch)
+	defer sch.Stop()
+
+	env := controlSucceeds(t, h, `{"action":"start"}`)
+	if env.Data.WasRunning || !env.Data.Running || env.Data.Message != "scheduler started" {
+		t.Fatalf("start-when-stopped: expected wasRunning=false running=true, got %+v", env.Data)
+	}
+
+	env = controlSucceeds(t, h, `{"action":"stop"}`)
+	if !env.Data.WasRunning || env.Data.Running || env.Data.Message != "scheduler stopped" {
+		t.Fatalf("stop-when-running: expected wasRunning=true running=false, got %+v", env.Data)
+	}
+}
+
+func TestStartStopScheduler_NumericActions(t *testing.T) {
+	sch := scheduler.NewSchedulerService(noopProcessor{}, time.Hour, time.Second)
+	defer sch.Stop()
+
+	if rec := postScheduler(t, NewMessageHandler(nil, sch), `{"action":1}`); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected numeric actions to be rejected by default, got %d", rec.Code)
+	}
+
+	h := NewMessageHandler(nil, sch, WithNumericSchedulerActions(true))
+	if env := controlSucceeds(t, h, `{"action":1}`); !env.Data.Running {
+		t.Fatalf("expected 1 to start the scheduler, got %+v", env.Data)
+	}
+	if env := controlSucceeds(t, h, `{"action":0}`); env.Data.Running {
+		t.Fatalf("expected 0 to stop the scheduler, got %+v", env.Data)
+	}
+}
+
+func TestStartStopScheduler_DuplicateStartReturnsConflict(t *testing.T) {
+	sch := scheduler.NewSchedulerService(noopProcessor{}, time.Hour, time.Second)
+	h := NewMessageHandler(nil, sch)
+	defer sch.Stop()
+
+	controlSucceeds(t, h, `{"action":"start"}`)
+
+	rec := postScheduler(t, h, `{"action":"START"}`)
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("expected 409, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if !strings.Contains(rec.Body.String(), "scheduler already running") {
+		t.Fatalf("expected an already running error, got %s", rec.Body.String())
+	}
+	if !sch.IsRunning() {
+		t.Fatal("expected the scheduler to keep running")
+	}
+}
+
+func TestStartStopScheduler_DuplicateStopReturnsConflict(t *testing.T) {
+	sch := scheduler.NewSchedulerService(noopProcessor{}, time.Hour, time.Second)
+	h := NewMessageHandler(nil, sch)
+
+	rec := postScheduler(t, h, `{"action":"stop"}`)
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("expected 409, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if !strings.Contains(rec.Body.String(), "scheduler already stopped") {
+		t.Fatalf("expected an already stopped error, got %s", rec.Body.String())
+	}
+}
+
+func TestStartStopScheduler_DisabledReturnsConflict(t *testing.T) {
+	h := NewMessageHandler(nil, nil)
+
+	req := httptest.NewRequest(http.MethodPost, "/scheduler", strings.NewReader(`{"action":"start"}`))
+	rec := httptest.NewRecorder()
+	h.StartStopScheduler(rec, req)
+
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("expected 409, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if !strings.Contains(rec.Body.String(), "scheduler disabled") {
+		t.Fatalf("expected a scheduler disabled error, got %s", rec.Body.String())
+	}
+}
+
+func TestStartStopScheduler_ClosedReturnsServiceUnavailable(t *testing.T) {
+	sch := scheduler.NewSchedulerService(noopProcessor{}, time.Hour, time.Second)
+	if err := sch.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	h := NewMessageHandler(nil, sch)
+
+	req := httptest.NewRequest(http.MethodPost, "/scheduler", strings.NewReader(`{"action":"start"}`))
+	rec := httptest.NewRecorder()
+	h.StartStopScheduler(rec, req)
+
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestGetSchedulerEvents_ListsRecentActivity(t *testing.T) {
+	sch := scheduler.NewSchedulerService(noopProcessor{}, time.Hour, time.Second, scheduler.WithRunOnStart(true))
+	h := NewMessageHandler(nil, sch)
+	if err := sch.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	if err := sch.Stop(); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	h.GetSchedulerEvents(rec, httptest.NewRequest(http.MethodGet, "/scheduler/events", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var env struct {
+		Data struct {
+			Items []struct {
+				Kind      string `json:"kind"`
+				Processed *int   `json:"processed"`
+			} `json:"items"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&env); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	items := env.Data.Items
+	if len(items) != 3 || items[0].Kind != "stopped" || items[1].Kind != "batch" || items[2].Kind != "started" {
+		t.Fatalf("expected stopped, batch, started; got %+v", items)
+	}
+	if items[1].Processed == nil || items[0].Processed != nil {
+		t.Fatalf("expected counts only on the batch event, got %+v", items)
+	}
+}
+
+func TestGetSchedulerStatus_WithoutElectionLeads(t *testing.T) {
+	sch := scheduler.NewSchedulerService(noopProcessor{}, time.Hour, time.Second)
+	h := NewMessageHandler(nil, sch)
+	defer sch.Close()
+	if err := sch.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	h.GetSchedulerStatus(rec, httptest.NewRequest(http.MethodGet, "/scheduler/status", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var env struct {
+		Data struct {
+			Running        bool   `json:"running"`
+			LeaderElection bool   `json:"leaderElection"`
+			Leader         bool   `json:"leader"`
+			InstanceID     string `json:"instanceId"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&env); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if d := env.Data; !d.Running || d.LeaderElection || !d.Leader || d.InstanceID != "" {
+		t.Fatalf("expected a running leader without election, got %+v", d)
+	}
+}
+
+func TestGetSchedulerEvents_DisabledReturnsConflict(t *testing.T) {
+	h := NewMessageHandler(nil, nil)
+
+	rec := httptest.NewRecorder()
+	h.GetSchedulerEvents(rec, httptest.NewRequest(http.MethodGet, "/scheduler/events", nil))
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("expected 409, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func newRetryHandler(t *testing.T, msgs ...*domain.Message) *MessageHandler {
+	t.Helper()
+
+	repo := &fakeRepo{byID: map[uuid.UUID]*domain.Message{}}
+	for _, m := range msgs {
+		repo.byID[m.ID] = m
+	}
+	return NewMessageHandler(service.NewMessageService(repo, nil, nil, 0, 0, 0), nil)
+}
+
+func postRetry(h *MessageHandler, id string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/messages/"+id+"/retry", nil)
+	req.SetPathValue("id", id)
+	rec := httptest.NewRecorder()
+	h.RetryMessage(rec, req)
+	return rec
+}
+
+func TestRetryMessage_RequeuesFailedMessage(t *testing.T) {
+	msg, _ := domain.NewMessage("+905000000000", "hello")
+	msg.MarkFailed(`{"error":"bad gateway"}`)
+	msg.RetryCount = 3
+
+	rec := postRetry(newRetryHandler(t, msg), msg.ID.String())
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var env struct {
+		Data struct {
+			Status     string `json:"status"`
+			RetryCount int    `json:"retryCount"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&env); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if env.Data.Status != string(domain.StatusPending) || env.Data.RetryCount != 0 {
+		t.Fatalf("expected PENDING with retryCount=0, got %+v", env.Data)
+	}
+	if msg.Status != domain.StatusPending || msg.RawResponse != "" {
+		t.Fatalf("expected stored message to be requeued, got status=%s raw=%q", msg.Status, msg.RawResponse)
+	}
+}
+
+func TestRetryMessage_RejectsNonFailedMessages(t *testing.T) {
+	pending, _ := domain.NewMessage("+905000000000", "hello")
+	sent, _ := domain.NewMessage("+905000000001", "hello")
+	sent.MarkSent("ext", "{}")
+
+	h := newRetryHandler(t, pending, sent)
+
+	for _, m := range []*domain.Message{pending, sent} {
+		before := m.Status
+		if rec := postRetry(h, m.ID.String()); rec.Code != http.StatusConflict {
+			t.Fatalf("%s: expected 409, got %d", before, rec.Code)
+		}
+		if m.Status != before {
+			t.Fatalf("%s: expected status to be unchanged, got %s", before, m.Status)
+		}
+	}
+}
+
+func TestRetryMessage_ConflictsWhenNoLongerFailed(t *testing.T) {
+	msg, _ := domain.NewMessage("+905000000000", "hello")
+	msg.MarkFailed("{}")
+
+	// The read still sees FAILED, but the conditional update matches no row.
+	repo := &fakeRepo{byID: map[uuid.UUID]*domain.Message{msg.ID: msg}, requeueErr: domain.ErrNotRetryable}
+	h := NewMessageHandler(service.NewMessageService(repo, nil, nil, 0, 0, 0), nil)
+
+	rec := postRetry(h, msg.ID.String())
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("expected 409, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if !strings.Contains(rec.Body.String(), domain.ErrNotRetryable.Error()) {
+		t.Fatalf("expected the not-retryable error, got %s", rec.Body.String())
+	}
+}
+
+func TestRetryMessage_UnknownAndInvalidIDs(t *testing.T) {
+	h := newRetryHandler(t)
+
+	if rec := postRetry(h, uuid.NewString()); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for an unknown id, got %d", rec.Code)
+	}
+	if rec := postRetry(h, "not-a-uuid"); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an invalid id, got %d", rec.Code)
+	}
+}
+
+func createMessage(t *testing.T, h *MessageHandler, body string) {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodPost, "/messages", strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	h.CreateMessage(rec, req)
+
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestListMessages_FiltersByTag(t *testing.T) {
+	repo := &fakeRepo{}
+	h := NewMessageHandler(service.NewMessageService(repo, nil, nil, 0, 0, 0), nil)
+
+	createMessage(t, h, `{"to":"+905000000001","content":"a","tags":{"env":"staging","team":"growth"}}`)
+	createMessage(t, h, `{"to":"+905000000002","content":"b","tags":{"env":"production","team":"growth"}}`)
+	createMessage(t, h, `{"to":"+905000000003","content":"c"}`)
+
+	req := httptest.NewRequest(http.MethodGet, "/messages?tag.env=staging&tag.team=growth", nil)
+	rec := httptest.NewRecorder()
+	h.ListMessages(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+
+	var env struct {
+		Data struct {
+			Items []struct {
+				To   string            `json:"to"`
+				Tags map[string]string `json:"tags"`
+			} `json:"items"`
+			Total int64 `json:"total"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&env); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+
+	if env.Data.Total != 1 || len(env.Data.Items) != 1 {
+		t.Fatalf("expected exactly one match, got %d", env.Data.Total)
+	}
+	item := env.Data.Items[0]
+	if item.To != "+905000000001" || item.Tags["env"] != "staging" || item.Tags["team"] != "growth" {
+		t.Fatalf("unexpected match %+v", item)
+	}
+}
+
+func TestCreateMessage_RejectsInvalidTags(t *testing.T) {
+	h := NewMessageHandler(service.NewMessageService(&fakeRepo{}, nil, nil, 0, 0, 0), nil)
+
+	req := httptest.NewRequest(http.MethodPost, "/messages", strings.NewReader(`{"to":"+905000000001","content":"a","tags":{"":"x"}}`))
+	rec := httptest.NewRecorder()
+	h.CreateMessage(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", rec.Code)
+	}
+}
+
+func TestListSchedulerRuns_ReturnsRecordedRuns(t *testing.T) {
+	started := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	repo := &fakeRepo{runs: []*domain.BatchRun{{
+		ID: uuid.New(),
+		BatchResult: domain.BatchResult{
+			StartedAt: started,
+			Duration:  1500 * time.Millisecond,
+			Processed: 3,
+			Succeeded: 2,
+			Failed:    1,
+		},
+	}}}
+	h := NewMessageHandler(service.NewMessageService(repo, nil, nil, 0, 0, 0), nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/scheduler/runs?page=1&limit=10", nil)
+	rec := httptest.NewRecorder()
+	h.ListSchedulerRuns(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var env struct {
+		Data struct {
+			Items []struct {
+				StartedAt  time.Time `json:"startedAt"`
+				DurationMs int64     `json:"durationMs"`
+				Processed  int       `json:"processed"`
+				Succeeded  int       `json:"succeeded"`
+				Failed     int       `json:"failed"`
+			} `json:"items"`
+			Total int64 `json:"total"`
+			Limit int   `json:"limit"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&env); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if env.Data.Total != 1 || env.Data.Limit != 10 || len(env.Data.Items) != 1 {
+		t.Fatalf("expected one run on a page of 10, got %+v", env.Data)
+	}
+	run := env.Data.Items[0]
+	if !run.StartedAt.Equal(started) || run.DurationMs != 1500 ||
+		run.Processed != 3 || run.Succeeded != 2 || run.Failed != 1 {
+		t.Fatalf("unexpected run %+v", run)
+	}
+}
+
+func TestDeleteMessage_SoftDeletes(t *testing.T) {
+	msg, _ := domain.NewMessage("+905000000000", "hello")
+	h := newRetryHandler(t, msg)
+
+	del := func(id string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodDelete, "/messages/"+id, nil)
+		req.SetPathValue("id", id)
+		rec := httptest.NewRecorder()
+		h.DeleteMessage(rec, req)
+		return rec
+	}
+
+	if rec := del(msg.ID.String()); rec.Code != http.StatusNoContent || rec.Body.Len() != 0 {
+		t.Fatalf("expected an empty 204, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := del(msg.ID.String()); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for an already deleted message, got %d", rec.Code)
+	}
+	if rec := del("not-a-uuid"); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an invalid id, got %d", rec.Code)
+	}
+}
+
+func TestClearDedup_ReleasesRecipientClaims(t *testing.T) {
+	repo := &fakeRepo{dedup: map[string]int64{"+905000000000": 2}}
+	h := NewMessageHandler(service.NewMessageService(repo, nil, nil, 0, 0, 0), nil)
+
+	clear := func(to string) response.DedupClearPayload {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodDelete, "/dedup/"+to, nil)
+		req.SetPathValue("to", to)
+		rec := httptest.NewRecorder()
+		h.ClearDedup(rec, req)
+
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+		}
+		var body response.DedupClearResponse
+		if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		return body.Data
+	}
+
+	if got := clear("+905000000000"); got.To != "+905000000000" || got.Cleared != 2 {
+		t.Fatalf("expected 2 claims cleared, got %+v", got)
+	}
+	// Nothing left to clear is not an error.
+	if got := clear("+905000000000"); got.Cleared != 0 {
+		t.Fatalf("expected nothing cleared the second time, got %+v", got)
+	}
+}
+
+func TestListMessages_NegotiatesSnakeCase(t *testing.T) {
+	repo := &fakeRepo{}
+	h := NewMessageHandler(service.NewMessageService(repo, nil, nil, 0, 0, 0), nil)
+	createMessage(t, h, `{"to":"+905000000001","content":"a"}`)
+	snakeByDefault := NewMessageHandler(service.NewMessageService(repo, nil, nil, 0, 0, 0), nil,
+		WithResponder(response.NewResponder(response.WithDefaultFieldCase(response.SnakeCase))))
+
+	for _, tc := range []struct {
+		h      *MessageHandler
+		accept string
+		field  string
+	}{
+		{h, "application/json", "createdAt"},
+		{h, "application/json; case=snake", "created_at"},
+		{snakeByDefault, "application/json", "created_at"},
+		{snakeByDefault, "application/json; case=camel", "createdAt"},
+	} {
+		h, accept, field := tc.h, tc.accept, tc.field
+		req := httptest.NewRequest(http.MethodGet, "/messages", nil)
+		req.Header.Set("Accept", accept)
+		rec := httptest.NewRecorder()
+		h.ListMessages(rec, req)
+
+		var env struct {
+			Data struct {
+				Items []map[string]any `json:"items"`
+			} `json:"data"`
+		}
+		if err := json.NewDecoder(rec.Body).Decode(&env); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		if len(env.Data.Items) != 1 {
+			t.Fatalf("expected one item, got %d", len(env.Data.Items))
+		}
+		if _, ok := env.Data.Items[0][field]; !ok {
+			t.Fatalf("Accept %q: expected field %q, got %v", accept, field, env.Data.Items[0])
+		}
+	}
+}
+
+func TestExportMessages_StreamsRangeAsCSV(t *testing.T) {
+	day := time.Date(2025, 3, 10, 0, 0, 0, 0, time.UTC)
+	repo := &fakeRepo{}
+	for _, at := range []time.Time{
+		day.Add(30 * time.Hour), // 11th, in range
+		day.Add(-time.Minute),   // 9th, before range
+		day.Add(2 * time.Hour),  // 10th, in range
+		day.Add(48 * time.Hour), // 12th, after range
+		day.Add(47 * time.Hour), // 11th, in range
+	} {
+		msg, err := domain.NewMessage("+905000000000", "hi, there")
+		if err != nil {
+			t.Fatalf("NewMessage: %v", err)
+		}
+		msg.CreatedAt = at
+		repo.saved = append(repo.saved, msg)
+	}
+	h := NewMessageHandler(service.NewMessageService(repo, nil, nil, 0, 0, 0), nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/messages/export?from=2025-03-10&until=2025-03-11", nil)
+	rec := httptest.NewRecorder()
+	h.ExportMessages(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", rec.Code, rec.Body.String())
+	}
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/csv") {
+		t.Fatalf("Content-Type = %q, want text/csv", ct)
+	}
+
+	records, err := csv.NewReader(rec.Body).ReadAll()
+	if err != nil {
+		t.Fatalf("read csv: %v", err)
+	}
+	if len(records) != 4 {
+		t.Fatalf("got %d records, want header + 3 rows", len(records))
+	}
+	if records[0][0] != "id" || records[0][8] != "created_at" {
+		t.Fatalf("unexpected header %v", records[0])
+	}
+	want := []string{repo.saved[2].ID.String(), repo.saved[0].ID.String(), repo.saved[4].ID.String()}
+	for i, id := range want {
+		if got := records[i+1][0]; got != id {
+			t.Errorf("row %d id = %s, want %s", i, got, id)
+		}
+	}
+	if records[1][2] != "hi, there" {
+		t.Errorf("content = %q, want the comma preserved", records[1][2])
+	}
+}
+
+func TestExportMessages_RejectsBadRange(t *testing.T) {
+	h := NewMessageHandler(service.NewMessageService(&fakeRepo{}, nil, nil, 0, 0, 0), nil)
+
+	for _, query := range []string{
+		"from=2025-03-12&until=2025-03-10",
+		"from=yesterday",
+		"from=2025-01-01&until=2025-12-31", // longer than MaxReportDays
+	} {
+		rec := httptest.NewRecorder()
+		h.ExportMessages(rec, httptest.NewRequest(http.MethodGet, "/messages/export?"+query, nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", query, rec.Code)
+		}
+	}
+}
+
+func TestCreateMessage_RejectsInvalidSendTimeout(t *testing.T) {
+	h := NewMessageHandler(service.NewMessageService(&fakeRepo{}, nil, nil, 0, 0, 0), nil)
+
+	for _, timeout := range []string{"soon", "-1s", "0s", "500us"} {
+		body := `{"to":"+905000000000","content":"hi","sendTimeout":"` + timeout + `"}`
+		rec := httptest.NewRecorder()
+		h.CreateMessage(rec, httptest.NewRequest(http.MethodPost, "/messages", strings.NewReader(body)))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("sendTimeout %q: status = %d, want 400", timeout, rec.Code)
+		}
+	}
+
+	repo := &fakeRepo{}
+	h = NewMessageHandler(service.NewMessageService(repo, nil, nil, 0, 0, 0), nil)
+	createMessage(t, h, `{"to":"+905000000000","content":"hi","sendTimeout":"2s"}`)
+	if got := repo.saved[0].SendTimeout; got == nil || *got != 2*time.Second {
+		t.Fatalf("expected a 2s send timeout to be stored, got %v", got)
+	}
+}
+
+func TestCreateMessage_ContentRules(t *testing.T) {
+	repo := &fakeRepo{}
+	rules := domain.ContentRules{MinLength: 3, Footer: "Reply STOP"}
+	h := NewMessageHandler(service.NewMessageService(repo, nil, nil, 0, 0, 0), nil, WithContentRules(rules))
+
+	rec := httptest.NewRecorder()
+	h.CreateMessage(rec, httptest.NewRequest(http.MethodPost, "/messages", strings.NewReader(`{"to":"+905000000000","content":"hi"}`)))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected content under the minimum length to be rejected, got %d", rec.Code)
+	}
+
+	createMessage(t, h, `{"to":"+905000000000","content":"hello"}`)
+	if got := repo.saved[0].Content; got != "hello Reply STOP" {
+		t.Fatalf("expected the configured footer, got %q", got)
+	}
+}
+
+func TestCreateMessage_EncodingOverride(t *testing.T) {
+	h := NewMessageHandler(service.NewMessageService(&fakeRepo{}, nil, nil, 0, 0, 0), nil)
+
+	rec := httptest.NewRecorder()
+	body := `{"to":"+905000000000","content":"hi","encoding":"latin1"}`
+	h.CreateMessage(rec, httptest.NewRequest(http.MethodPost, "/messages", strings.NewReader(body)))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want 400", rec.Code)
+	}
+
+	repo := &fakeRepo{}
+	h = NewMessageHandler(service.NewMessageService(repo, nil, nil, 0, 0, 0), nil)
+	createMessage(t, h, `{"to":"+905000000000","content":"hi","encoding":"ucs-2"}`)
+	if got := repo.saved[0].Encoding; got != domain.EncodingUCS2 {
+		t.Fatalf("expected the UCS2 override to be stored, got %q", got)
+	}
+}
+
+func TestCreateMessage_DuplicateIsConflict(t *testing.T) {
+	repo := &fakeRepo{saveErr: domain.ErrDuplicateMessage}
+	h := NewMessageHandler(service.NewMessageService(repo, nil, nil, 0, 0, 0), nil)
+
+	rec := httptest.NewRecorder()
+	body := `{"to":"+905000000000","content":"hi"}`
+	h.CreateMessage(rec, httptest.NewRequest(http.MethodPost, "/messages", strings.NewReader(body)))
+
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("status = %d, want 409: %s", rec.Code, rec.Body.String())
+	}
+}
+
+// blockingSaveRepo holds Save until release is closed.
+type blockingSaveRepo struct {
+	*fakeRepo
+	entered chan struct{}
+	release chan struct{}
+}
+
+func (b *blockingSaveRepo) Save(ctx context.Context, m *domain.Message) error {
+	b.entered <- struct{}{}
+	<-b.release
+	return b.fakeRepo.Save(ctx, m)
+}
+
+func TestCreateMessage_SaturatedIsServiceUnavailable(t *testing.T) {
+	repo := &blockingSaveRepo{fakeRepo: &fakeRepo{}, entered: make(chan struct{}, 1), release: make(chan struct{})}
+	svc := service.NewMessageService(repo, nil, nil, 0, 0, 0, service.WithCreateConcurrency(1))
+	h := NewMessageHandler(svc, nil)
+	body := `{"to":"+905000000000","content":"hi"}`
+
+	first := httptest.NewRecorder()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		h.CreateMessage(first, httptest.NewRequest(http.MethodPost, "/messages", strings.NewReader(body)))
+	}()
+	<-repo.entered
+
+	rec := httptest.NewRecorder()
+	h.CreateMessage(rec, httptest.NewRequest(http.MethodPost, "/messages", strings.NewReader(body)))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status = %d, want 503: %s", rec.Code, rec.Body.String())
+	}
+
+	close(repo.release)
+	<-done
+	if first.Code != http.StatusCreated {
+		t.Fatalf("in-flight create: status = %d, want 201", first.Code)
+	}
+	createMessage(t, h, body)
+}
+
+func TestGetLatency_RejectsBadWindow(t *testing.T) {
+	h := NewStatsHandler(service.NewMessageService(&fakeRepo{}, nil, nil, 0, 0, 0))
+
+	for _, query := range []string{
+		"from=2025-03-12T00:00:00Z&until=2025-03-10T00:00:00Z",
+		"from=2025-03-10T00:00:00Z&until=2025-03-10T00:00:00Z",
+		"from=2025-03-10",
+	} {
+		rec := httptest.NewRecorder()
+		h.GetLatency(rec, httptest.NewRequest(http.MethodGet, "/stats/latency?"+query, nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", query, rec.Code)
+		}
+	}
+}
+
+func TestGetSentMessages_StreamsNDJSON(t *testing.T) {
+	repo := newSentRepo(t, 250)
+	h := NewMessageHandler(service.NewMessageService(repo, nil, nil, 0, 0, 0), nil)
+
+	rec := httptest.NewRecorder()
+	h.GetSentMessages(rec, httptest.NewRequest(http.MethodGet, "/messages/sent?format=ndjson&limit=10", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/x-ndjson" {
+		t.Fatalf("Content-Type = %q, want application/x-ndjson", ct)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(rec.Body.String(), "\n"), "\n")
+	if len(lines) != len(repo.sent) {
+		t.Fatalf("expected %d lines (all sent messages, ignoring limit), got %d", len(repo.sent), len(lines))
+	}
+	for i, line := range lines {
+		var dto response.MessageDTO
+		dec := json.NewDecoder(strings.NewReader(line))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&dto); err != nil {
+			t.Fatalf("line %d is not a MessageDTO: %v: %s", i, err, line)
+		}
+		if dto.ID != repo.sent[i].ID.String() || dto.Status != string(domain.StatusSuccess) || dto.MessageID != "ext" {
+			t.Fatalf("line %d: unexpected message %+v", i, dto)
+		}
+	}
+}
+
+func TestGetSentMessages_NDJSONEdgeCases(t *testing.T) {
+	h := NewMessageHandler(service.NewMessageService(newSentRepo(t, 0), nil, nil, 0, 0, 0), nil)
+
+	rec := httptest.NewRecorder()
+	h.GetSentMessages(rec, httptest.NewRequest(http.MethodGet, "/messages/sent?format=ndjson", nil))
+	if rec.Code != http.StatusOK || rec.Body.Len() != 0 {
+		t.Fatalf("expected an empty 200 stream, got %d: %q", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	h.GetSentMessages(rec, httptest.NewRequest(http.MethodGet, "/messages/sent?format=xml", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want 400 for an unknown format", rec.Code)
+	}
+}
+
+func TestGetTopRecipients_ReturnsRanking(t *testing.T) {
+	repo := &fakeRepo{top: []domain.RecipientCount{
+		{To: "+905000000002", Count: 5},
+		{To: "+905000000001", Count: 3},
+		{To: "+905000000003", Count: 1},
+	}}
+	h := NewStatsHandler(service.NewMessageService(repo, nil, nil, 0, 0, 0))
+
+	rec := httptest.NewRecorder()
+	h.GetTopRecipients(rec, httptest.NewRequest(http.MethodGet,
+		"/stats/top-recipients?from=2026-03-01T00:00:00Z&until=2026-03-02T00:00:00Z&limit=2", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+
+	var env struct {
+		Data response.TopRecipientsPayload `json:"data"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&env); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	want := []response.RecipientCountDTO{{To: "+905000000002", Count: 5}, {To: "+905000000001", Count: 3}}
+	if !slices.Equal(env.Data.Recipients, want) {
+		t.Fatalf("recipients = %v, want %v", env.Data.Recipients, want)
+	}
+}
+
+func TestGetTopRecipients_RejectsBadWindowAndLimit(t *testing.T) {
+	h := NewStatsHandler(service.NewMessageService(&fakeRepo{}, nil, nil, 0, 0, 0))
+
+	for _, query := range []string{
+		"from=2025-03-12T00:00:00Z&until=2025-03-10T00:00:00Z",
+		"from=2025-03-10",
+		"limit=0",
+		"limit=101",
+		"limit=ten",
+	} {
+		rec := httptest.NewRecorder()
+		h.GetTopRecipients(rec, httptest.NewRequest(http.MethodGet, "/stats/top-recipients?"+query, nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", query, rec.Code)
+		}
+	}
+}
+
+// fakeOptOuts is a domain.OptOutRepository holding a single token.
+type fakeOptOuts struct {
+	domain.OptOutRepository
+	tok *domain.OptOutToken
+}
+
+func (f *fakeOptOuts) ResolveOptOutToken(ctx context.Context, token string, at time.Time) (*domain.OptOutToken, error) {
+	if f.tok == nil || f.tok.Token != token {
+		return nil, domain.ErrOptOutTokenNotFound
+	}
+	if f.tok.OptedOutAt == nil {
+		f.tok.OptedOutAt = &at
+	}
+	return f.tok, nil
+}
+
+func TestResolveOptOut(t *testing.T) {
+	tok, _ := domain.NewOptOutToken("+905000000000")
+	svc := service.NewMessageService(&fakeRepo{}, nil, nil, 0, 0, 0,
+		service.WithOptOutLinks(&fakeOptOuts{tok: tok}, "https://sms.example.com/optout"))
+	h := NewMessageHandler(svc, nil)
+
+	resolve := func(token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/optout/"+token, nil)
+		req.SetPathValue("token", token)
+		rec := httptest.NewRecorder()
+		h.ResolveOptOut(rec, req)
+		return rec
+	}
+
+	rec := resolve(tok.Token)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var body response.OptOutResponse
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if body.Data.OptedOutAt.IsZero() || strings.Contains(rec.Body.String(), tok.To) {
+		t.Fatalf("expected the opt-out time without the number, got %+v", body.Data)
+	}
+
+	if rec := resolve("unknown"); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for an unknown token, got %d", rec.Code)
+	}
+}
+
+// fakeLinks is a domain.LinkRepository holding a single link.
+type fakeLinks struct {
+	domain.LinkRepository
+	link *domain.Link
+}
+
+func (f *fakeLinks) RecordLinkClick(ctx context.Context, token string, at time.Time) (*domain.Link, error) {
+	if f.link == nil || f.link.Token != token {
+		return nil, domain.ErrLinkNotFound
+	}
+	f.link.Clicks++
+	f.link.LastClickedAt = &at
+	return f.link, nil
+}
+
+func TestFollowLink_RedirectsAndCountsClick(t *testing.T) {
+	link, _ := domain.NewLink("https://example.com/offer?id=1", uuid.New())
+	links := &fakeLinks{link: link}
+	h := NewMessageHandler(service.NewMessageService(&fakeRepo{}, nil, nil, 0, 0, 0, service.WithLinks(links)), nil)
+
+	follow := func(token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/l/"+token, nil)
+		req.SetPathValue("token", token)
+		rec := httptest.NewRecorder()
+		h.FollowLink(rec, req)
+		return rec
+	}
+
+	rec := follow(link.Token)
+	if rec.Code != http.StatusFound {
+		t.Fatalf("expected 302, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if loc := rec.Header().Get("Location"); loc != "https://example.com/offer?id=1" {
+		t.Fatalf("expected a redirect to the original URL, got %q", loc)
+	}
+	if link.Clicks != 1 || link.LastClickedAt == nil {
+		t.Fatalf("expected the click to be recorded, got %+v", link)
+	}
+
+	if rec := follow("unknown"); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for an unknown token, got %d", rec.Code)
+	}
+}
+
+// multicastBody builds a multicast request for n distinct recipients.
+func multicastBody(n int) string {
+	to := make([]string, n)
+	for i := range to {
+		to[i] = fmt.Sprintf(`"+9050000000%02d"`, i)
+	}
+	return `{"to":[` + strings.Join(to, ",") + `],"content":"hi","tags":{"env":"test"}}`
+}
+
+func postMulticast(h *MessageHandler, body string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	h.Multicast(rec, httptest.NewRequest(http.MethodPost, "/messages/multicast", strings.NewReader(body)))
+	return rec
+}
+
+func getGroupStatus(h *MessageHandler, id string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/groups/"+id+"/status", nil)
+	req.SetPathValue("id", id)
+	rec := httptest.NewRecorder()
+	h.GetGroupStatus(rec, req)
+	return rec
+}
+
+func TestMulticast_SmallGroupReturnsCreated(t *testing.T) {
+	repo := &fakeRepo{}
+	svc := service.NewMessageService(repo, nil, nil, 0, 0, 0, service.WithMulticast(10, 3, 1))
+	h := NewMessageHandler(svc, nil)
+
+	rec := postMulticast(h, multicastBody(3))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var body response.MulticastResponse
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if !body.Data.Group.Done || body.Data.Group.Created != 3 || len(body.Data.Messages) != 3 {
+		t.Fatalf("expected a finished group of 3, got %+v", body.Data.Group)
+	}
+	for _, m := range repo.saved {
+		if m.Tags["env"] != "test" || m.Tags[service.GroupTag] != body.Data.Group.ID {
+			t.Fatalf("expected request and group tags, got %v", m.Tags)
+		}
+	}
+}
+
+func TestMulticast_LargeGroupReturnsAccepted(t *testing.T) {
+	repo := &fakeRepo{}
+	svc := service.NewMessageService(repo, nil, nil, 0, 0, 0, service.WithMulticast(10, 3, 1))
+	h := NewMessageHandler(svc, nil)
+
+	rec := postMulticast(h, multicastBody(5))
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var accepted response.GroupStatusResponse
+	if err := json.NewDecoder(rec.Body).Decode(&accepted); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	id := accepted.Data.ID
+	if accepted.Data.Total != 5 || rec.Header().Get("Location") != "/groups/"+id+"/status" {
+		t.Fatalf("expected a group of 5 with its status location, got %+v (Location %q)", accepted.Data, rec.Header().Get("Location"))
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		rec := getGroupStatus(h, id)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+		}
+		var status response.GroupStatusResponse
+		if err := json.NewDecoder(rec.Body).Decode(&status); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		if status.Data.Done {
+			if status.Data.Created != 5 || len(repo.saved) != 5 {
+				t.Fatalf("expected 5 created messages, got %+v and %d saved", status.Data, len(repo.saved))
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("group not done in time: %+v", status.Data)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestMulticast_RejectsInvalidRequests(t *testing.T) {
+	svc := service.NewMessageService(&fakeRepo{}, nil, nil, 0, 0, 0, service.WithMulticast(2, 0, 0))
+	h := NewMessageHandler(svc, nil)
+
+	for name, body := range map[string]string{
+		"no recipients":    `{"to":[],"content":"hi"}`,
+		"bad recipient":    `{"to":["+905000000000",""],"content":"hi"}`,
+		"reserved tag":     `{"to":["+905000000000"],"content":"hi","tags":{"group":"x"}}`,
+		"padded tag":       `{"to":["+905000000000"],"content":"hi","tags":{" group ":"x"}}`,
+		"over the cap":     multicastBody(3),
+		"unknown field":    `{"to":["+905000000000"],"content":"hi","priority":1}`,
+		"single recipient": `{"to":"+905000000000","content":"hi"}`,
+	} {
+		if rec := postMulticast(h, body); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", name, rec.Code)
+		}
+	}
+
+	if rec := getGroupStatus(h, uuid.NewString()); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for an unknown group, got %d", rec.Code)
+	}
+	if rec := getGroupStatus(h, "nope"); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an invalid group id, got %d", rec.Code)
+	}
+}
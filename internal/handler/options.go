@@ -0,0 +1,76 @@
+package handler
+
+import (
+	domain "github.com/oggyb/insider-assessment/internal/domain/message"
+	"github.com/oggyb/insider-assessment/internal/response"
+)
+
+// Option configures the handlers built by the New*Handler constructors.
+// Each handler uses the settings that apply to it and ignores the rest, so
+// main can pass the same options to all of them.
+type Option func(*settings)
+
+// settings holds what the options configure.
+type settings struct {
+	// resp writes every response; it defaults to response.NewResponder().
+	resp *response.Responder
+
+	// numericSchedulerActions accepts 1/0 for start/stop in POST /scheduler.
+	numericSchedulerActions bool
+
+	// strictPagination makes out-of-range pages return 404 instead of
+	// an empty 200 response flagged with outOfRange.
+	strictPagination bool
+
+	// contentRules are applied to the content of created messages.
+	contentRules domain.ContentRules
+
+	// pageSizes holds page sizes per list path; unlisted paths use
+	// defaultPageSize and domain.MaxPageLimit.
+	pageSizes map[string]domain.PageSize
+}
+
+// newSettings applies opts to the defaults.
+func newSettings(opts []Option) settings {
+	var s settings
+	for _, opt := range opts {
+		opt(&s)
+	}
+	if s.resp == nil {
+		s.resp = response.NewResponder()
+	}
+	return s
+}
+
+// WithResponder writes responses with rp, so they follow its envelope
+// settings (timestamp format, ...).
+func WithResponder(rp *response.Responder) Option {
+	return func(s *settings) {
+		s.resp = rp
+	}
+}
+
+// WithNumericSchedulerActions lets POST /scheduler take the action as 1
+// (start) or 0 (stop). It is off by default.
+func WithNumericSchedulerActions(on bool) Option {
+	return func(s *settings) {
+		s.numericSchedulerActions = on
+	}
+}
+
+// WithStrictPagination makes GET /messages/sent answer 404 for a page past
+// the last one instead of an empty page flagged with outOfRange. It is off
+// by default.
+func WithStrictPagination(on bool) Option {
+	return func(s *settings) {
+		s.strictPagination = on
+	}
+}
+
+// WithContentRules creates messages under r instead of the default
+// domain.ContentRules.
+func WithContentRules(r domain.ContentRules) Option {
+	return func(s *settings) {
+		s.contentRules = r
+	}
+}
@@ -0,0 +1,240 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	domain "github.com/oggyb/insider-assessment/internal/domain/message"
+	"github.com/oggyb/insider-assessment/internal/response"
+	"github.com/oggyb/insider-assessment/internal/service"
+)
+
+// reportDateLayout is the date format of the report query parameters.
+const reportDateLayout = "2006-01-02"
+
+// defaultReportDays is the report length when "from" is omitted (one week).
+const defaultReportDays = 7
+
+// defaultLatencyWindow is the latency window when "from" is omitted.
+const defaultLatencyWindow = 24 * time.Hour
+
+// defaultTopRecipients is how many recipients GetTopRecipients ranks when
+// "limit" is omitted.
+const defaultTopRecipients = 10
+
+// StatsHandler serves aggregate reports over sent messages.
+type StatsHandler struct {
+	msgSvc service.MessageService
+
+	settings
+}
+
+// NewStatsHandler constructs a new StatsHandler.
+func NewStatsHandler(msgSvc service.MessageService, opts ...Option) *StatsHandler {
+	return &StatsHandler{msgSvc: msgSvc, settings: newSettings(opts)}
+}
+
+// GetReport godoc
+// @Summary     Daily send report
+// @Description Returns sent and failed message counts per UTC day between from and until (inclusive).
+// @Description Defaults to the last 7 days; the range may span at most 92 days.
+// @Tags        stats
+// @Produce     json
+// @Param       from  query string false "First day (YYYY-MM-DD)"
+// @Param       until query string false "Last day (YYYY-MM-DD), defaults to today"
+// @Success     200 {object} response.ReportResponse
+// @Failure     400 {object} map[string]string
+// @Failure     500 {object} map[string]string
+// @Router      /stats/report [get]
+func (h *StatsHandler) GetReport(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	until := time.Now().UTC()
+	if v := q.Get("until"); v != "" {
+		t, err := time.Parse(reportDateLayout, v)
+		if err != nil {
+			h.resp.Error(w, http.StatusBadRequest, "until must be a date in YYYY-MM-DD format")
+			return
+		}
+		until = t
+	}
+
+	from := until.AddDate(0, 0, -(defaultReportDays - 1))
+	if v := q.Get("from"); v != "" {
+		t, err := time.Parse(reportDateLayout, v)
+		if err != nil {
+			h.resp.Error(w, http.StatusBadRequest, "from must be a date in YYYY-MM-DD format")
+			return
+		}
+		from = t
+	}
+
+	days, err := h.msgSvc.DailyReport(r.Context(), from, until)
+	if errors.Is(err, service.ErrInvalidReportRange) {
+		h.resp.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		h.resp.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	h.resp.JSON(w, http.StatusOK, toReportPayload(days))
+}
+
+// GetLatency godoc
+// @Summary     Send latency percentiles
+// @Description Returns the average and 95th percentile provider call duration of messages sent between from (inclusive) and until (exclusive).
+// @Description Defaults to the last 24 hours; the window may span at most 92 days.
+// @Tags        stats
+// @Produce     json
+// @Param       from  query string false "Window start (RFC3339)"
+// @Param       until query string false "Window end (RFC3339), defaults to now"
+// @Success     200 {object} response.LatencyResponse
+// @Failure     400 {object} map[string]string
+// @Failure     500 {object} map[string]string
+// @Router      /stats/latency [get]
+func (h *StatsHandler) GetLatency(w http.ResponseWriter, r *http.Request) {
+	from, until, ok := h.parseWindow(w, r, defaultLatencyWindow)
+	if !ok {
+		return
+	}
+
+	avg, p95, err := h.msgSvc.SendLatency(r.Context(), from, until)
+	if errors.Is(err, service.ErrInvalidReportRange) {
+		h.resp.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		h.resp.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	h.resp.JSON(w, http.StatusOK, response.LatencyPayload{
+		From:  from,
+		Until: until,
+		AvgMs: float64(avg) / float64(time.Millisecond),
+		P95Ms: float64(p95) / float64(time.Millisecond),
+	})
+}
+
+// GetTopRecipients godoc
+// @Summary     Recipients with the most messages
+// @Description Ranks recipients by the number of messages created for them between from (inclusive) and until (exclusive), e.g. to spot abuse.
+// @Description Defaults to the last 24 hours and the top 10; the window may span at most 92 days and limit may be at most 100.
+// @Tags        stats
+// @Produce     json
+// @Param       from  query string false "Window start (RFC3339)"
+// @Param       until query string false "Window end (RFC3339), defaults to now"
+// @Param       limit query int    false "Number of recipients (max 100)" default(10)
+// @Success     200 {object} response.TopRecipientsResponse
+// @Failure     400 {object} map[string]string
+// @Failure     500 {object} map[string]string
+// @Router      /stats/top-recipients [get]
+func (h *StatsHandler) GetTopRecipients(w http.ResponseWriter, r *http.Request) {
+	from, until, ok := h.parseWindow(w, r, defaultLatencyWindow)
+	if !ok {
+		return
+	}
+
+	limit := defaultTopRecipients
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			h.resp.Error(w, http.StatusBadRequest, "limit must be an integer")
+			return
+		}
+		limit = n
+	}
+
+	top, err := h.msgSvc.TopRecipients(r.Context(), from, until, limit)
+	if errors.Is(err, service.ErrInvalidReportRange) || errors.Is(err, service.ErrInvalidTopLimit) {
+		h.resp.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		h.resp.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	payload := response.TopRecipientsPayload{
+		From:       from,
+		Until:      until,
+		Recipients: make([]response.RecipientCountDTO, len(top)),
+	}
+	for i, rc := range top {
+		payload.Recipients[i] = response.RecipientCountDTO{To: rc.To, Count: rc.Count}
+	}
+	h.resp.JSON(w, http.StatusOK, payload)
+}
+
+// GetPendingAge godoc
+// @Summary     Oldest pending message age
+// @Description Returns how long the oldest PENDING message has been waiting (0 when none is pending).
+// @Description healthy is false once it exceeds PENDING_AGE_ALERT, which also publishes a StalePending event.
+// @Tags        stats
+// @Produce     json
+// @Success     200 {object} response.PendingAgeResponse
+// @Failure     500 {object} map[string]string
+// @Router      /stats/pending [get]
+func (h *StatsHandler) GetPendingAge(w http.ResponseWriter, r *http.Request) {
+	res, err := h.msgSvc.OldestPendingAge(r.Context())
+	if err != nil {
+		h.resp.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	h.resp.JSON(w, http.StatusOK, response.PendingAgePayload{
+		OldestPendingAgeSeconds: res.Age.Seconds(),
+		AlertThresholdSeconds:   res.Threshold.Seconds(),
+		Healthy:                 res.Healthy,
+	})
+}
+
+// parseWindow reads the RFC3339 "from" and "until" query parameters,
+// defaulting until to now and from to def before until. It answers 400 and
+// returns false if either is malformed.
+func (h *StatsHandler) parseWindow(w http.ResponseWriter, r *http.Request, def time.Duration) (from, until time.Time, ok bool) {
+	q := r.URL.Query()
+
+	until = time.Now().UTC()
+	if v := q.Get("until"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			h.resp.Error(w, http.StatusBadRequest, "until must be an RFC3339 timestamp")
+			return time.Time{}, time.Time{}, false
+		}
+		until = t
+	}
+
+	from = until.Add(-def)
+	if v := q.Get("from"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			h.resp.Error(w, http.StatusBadRequest, "from must be an RFC3339 timestamp")
+			return time.Time{}, time.Time{}, false
+		}
+		from = t
+	}
+	return from, until, true
+}
+
+func toReportPayload(days []domain.DayStat) response.ReportPayload {
+	payload := response.ReportPayload{Days: make([]response.DayStatDTO, len(days))}
+	for i, d := range days {
+		payload.Days[i] = response.DayStatDTO{
+			Day:    d.Day.Format(reportDateLayout),
+			Sent:   d.Sent,
+			Failed: d.Failed,
+		}
+		payload.TotalSent += d.Sent
+		payload.TotalFailed += d.Failed
+	}
+	if len(days) > 0 {
+		payload.From = payload.Days[0].Day
+		payload.Until = payload.Days[len(days)-1].Day
+	}
+	return payload
+}
@@ -0,0 +1,42 @@
+package metrics
+
+import (
+	"time"
+
+	domain "github.com/oggyb/insider-assessment/internal/domain/message"
+)
+
+// BatchSizeBuckets are the bucket bounds of the batch size histogram, in
+// messages.
+var BatchSizeBuckets = []float64{0, 1, 5, 10, 25, 50, 100, 250, 500, 1000}
+
+// BatchDurationBuckets are the bucket bounds of the batch duration
+// histogram, in seconds.
+var BatchDurationBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120}
+
+// BatchMetrics records the distribution of batch sizes and durations. It
+// implements scheduler.BatchObserver.
+type BatchMetrics struct {
+	Size     *Histogram
+	Duration *Histogram
+}
+
+// NewBatchMetrics creates the batch histograms and registers them with reg.
+func NewBatchMetrics(reg *Registry) (*BatchMetrics, error) {
+	m := &BatchMetrics{
+		Size: NewHistogram("batch_size_messages",
+			"Messages handed to workers per batch.", BatchSizeBuckets),
+		Duration: NewHistogram("batch_duration_seconds",
+			"Wall time of a batch run.", BatchDurationBuckets),
+	}
+	if err := reg.Register(m.Size, m.Duration); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// ObserveBatch records one batch run.
+func (m *BatchMetrics) ObserveBatch(result domain.BatchResult, elapsed time.Duration) {
+	m.Size.Observe(float64(result.Processed))
+	m.Duration.Observe(elapsed.Seconds())
+}
@@ -0,0 +1,78 @@
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	domain "github.com/oggyb/insider-assessment/internal/domain/message"
+	"github.com/oggyb/insider-assessment/internal/scheduler"
+)
+
+// sizedProcessor reports batches of a fixed size.
+type sizedProcessor struct {
+	size  int
+	calls atomic.Int32
+}
+
+func (p *sizedProcessor) ProcessBatch(ctx context.Context) (domain.BatchResult, error) {
+	p.calls.Add(1)
+	return domain.BatchResult{Processed: p.size, Succeeded: p.size}, nil
+}
+
+func TestBatchMetrics_ObservedBySchedulerAndScraped(t *testing.T) {
+	reg := NewRegistry()
+	bm, err := NewBatchMetrics(reg)
+	if err != nil {
+		t.Fatalf("NewBatchMetrics: %v", err)
+	}
+
+	proc := &sizedProcessor{size: 30}
+	s := scheduler.NewSchedulerService(proc, 5*time.Millisecond, time.Second,
+		scheduler.WithBatchObserver(bm))
+	defer s.Close()
+	if err := s.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for proc.calls.Load() < 3 {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected at least 3 batches, got %d", proc.calls.Load())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if err := s.Stop(); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	reg.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := rec.Body.String()
+
+	_, _, sizeCount := bm.Size.Snapshot()
+	_, _, durCount := bm.Duration.Snapshot()
+	if sizeCount < 3 || durCount != sizeCount {
+		t.Fatalf("expected one size and duration observation per batch, got %d and %d", sizeCount, durCount)
+	}
+
+	for _, want := range []string{
+		"# TYPE batch_size_messages histogram",
+		"# TYPE batch_duration_seconds histogram",
+		// Batches of 30 land above the 25 bucket and inside the 50 one.
+		`batch_size_messages_bucket{le="25"} 0`,
+		`batch_size_messages_bucket{le="50"} ` + itoa(sizeCount),
+		"batch_size_messages_count " + itoa(sizeCount),
+		"batch_duration_seconds_count " + itoa(durCount),
+	} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("expected %q in scrape:\n%s", want, body)
+		}
+	}
+}
+
+func itoa(n uint64) string { return formatFloat(float64(n)) }
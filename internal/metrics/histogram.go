@@ -0,0 +1,92 @@
+// Package metrics keeps in-process metrics and serves them in the
+// Prometheus text exposition format, without depending on a client library.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"strconv"
+	"sync"
+)
+
+// Histogram counts observations into cumulative buckets, like a Prometheus
+// histogram. It is safe for concurrent use.
+type Histogram struct {
+	name    string
+	help    string
+	buckets []float64
+
+	mu     sync.Mutex
+	counts []uint64 // per bucket, not cumulative; the last one is +Inf
+	sum    float64
+	count  uint64
+}
+
+// NewHistogram creates a histogram with the given upper bucket bounds. The
+// bounds are sorted and de-duplicated; +Inf is always implied.
+func NewHistogram(name, help string, buckets []float64) *Histogram {
+	b := slices.Clone(buckets)
+	slices.Sort(b)
+	b = slices.Compact(b)
+	b = slices.DeleteFunc(b, func(v float64) bool { return math.IsInf(v, +1) || math.IsNaN(v) })
+
+	return &Histogram{
+		name:    name,
+		help:    help,
+		buckets: b,
+		counts:  make([]uint64, len(b)+1),
+	}
+}
+
+// Name returns the metric name.
+func (h *Histogram) Name() string { return h.name }
+
+// Observe records v.
+func (h *Histogram) Observe(v float64) {
+	i, _ := slices.BinarySearch(h.buckets, v)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.counts[i]++
+	h.sum += v
+	h.count++
+}
+
+// Snapshot returns the cumulative count per bucket bound (the last entry
+// being +Inf), the sum of all observations and their number.
+func (h *Histogram) Snapshot() (cumulative []uint64, sum float64, count uint64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	cumulative = make([]uint64, len(h.counts))
+	var running uint64
+	for i, c := range h.counts {
+		running += c
+		cumulative[i] = running
+	}
+	return cumulative, h.sum, h.count
+}
+
+// WriteText writes the histogram in the Prometheus text format.
+func (h *Histogram) WriteText(w io.Writer) error {
+	cumulative, sum, count := h.Snapshot()
+
+	if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s histogram\n", h.name, h.help, h.name); err != nil {
+		return err
+	}
+	for i, bound := range h.buckets {
+		if _, err := fmt.Fprintf(w, "%s_bucket{le=%q} %d\n", h.name, formatFloat(bound), cumulative[i]); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintf(w, "%s_bucket{le=\"+Inf\"} %d\n%s_sum %s\n%s_count %d\n",
+		h.name, cumulative[len(cumulative)-1], h.name, formatFloat(sum), h.name, count)
+	return err
+}
+
+// formatFloat renders v the way Prometheus expects sample values.
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -0,0 +1,56 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestHistogram_CumulativeBuckets(t *testing.T) {
+	h := NewHistogram("test_seconds", "Test.", []float64{1, 0.5, 1, 5})
+	for _, v := range []float64{0.1, 0.5, 0.7, 3, 9} {
+		h.Observe(v)
+	}
+
+	cumulative, sum, count := h.Snapshot()
+	// Bounds 0.5, 1, 5, +Inf: an observation equal to a bound counts in it.
+	if want := []uint64{2, 3, 4, 5}; !slices.Equal(cumulative, want) {
+		t.Fatalf("cumulative = %v, want %v", cumulative, want)
+	}
+	if count != 5 || sum != 13.3 {
+		t.Fatalf("count=%d sum=%v, want 5 and 13.3", count, sum)
+	}
+}
+
+func TestRegistry_ServesTextFormat(t *testing.T) {
+	reg := NewRegistry()
+	h := NewHistogram("test_seconds", "Test durations.", []float64{0.5, 1})
+	if err := reg.Register(h); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	if err := reg.Register(NewHistogram("test_seconds", "Again.", nil)); err == nil {
+		t.Fatal("expected a duplicate name to be rejected")
+	}
+	h.Observe(0.25)
+	h.Observe(2)
+
+	rec := httptest.NewRecorder()
+	reg.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Fatalf("Content-Type = %q", ct)
+	}
+	want := `# HELP test_seconds Test durations.
+# TYPE test_seconds histogram
+test_seconds_bucket{le="0.5"} 1
+test_seconds_bucket{le="1"} 1
+test_seconds_bucket{le="+Inf"} 2
+test_seconds_sum 2.25
+test_seconds_count 2
+`
+	if got := rec.Body.String(); got != want {
+		t.Fatalf("body:\n%s\nwant:\n%s", got, want)
+	}
+}
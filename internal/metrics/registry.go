@@ -0,0 +1,71 @@
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+)
+
+// Collector is a metric the Registry can expose.
+type Collector interface {
+	Name() string
+	WriteText(w io.Writer) error
+}
+
+// Registry holds the metrics exposed on one endpoint.
+type Registry struct {
+	mu         sync.Mutex
+	collectors []Collector
+	names      map[string]bool
+}
+
+// NewRegistry creates an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{names: map[string]bool{}}
+}
+
+// Register adds collectors to the registry. A name may only be registered
+// once.
+func (r *Registry) Register(cs ...Collector) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, c := range cs {
+		if r.names[c.Name()] {
+			return fmt.Errorf("metric %q already registered", c.Name())
+		}
+		r.names[c.Name()] = true
+		r.collectors = append(r.collectors, c)
+	}
+	return nil
+}
+
+// WriteText writes every registered metric in the Prometheus text format,
+// in registration order.
+func (r *Registry) WriteText(w io.Writer) error {
+	r.mu.Lock()
+	cs := append([]Collector(nil), r.collectors...)
+	r.mu.Unlock()
+
+	for _, c := range cs {
+		if err := c.WriteText(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Handler serves the registry for a Prometheus scrape.
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		var buf bytes.Buffer
+		if err := r.WriteText(&buf); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		_, _ = w.Write(buf.Bytes())
+	})
+}
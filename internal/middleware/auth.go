@@ -0,0 +1,34 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+
+	"github.com/oggyb/insider-assessment/internal/response"
+)
+
+// APIKeyHeader is the request header carrying the admin API key.
+const APIKeyHeader = "X-API-Key"
+
+// RequireAPIKey only lets requests through whose X-API-Key header matches
+// key. If key is empty the guarded routes are disabled and always answer 403,
+// so forgetting to configure a key never leaves them open. Errors are
+// written with rp.
+func RequireAPIKey(key string, rp *response.Responder) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if key == "" {
+				rp.Error(w, http.StatusForbidden, "admin API is disabled")
+				return
+			}
+
+			got := r.Header.Get(APIKeyHeader)
+			if subtle.ConstantTimeCompare([]byte(got), []byte(key)) != 1 {
+				rp.Error(w, http.StatusUnauthorized, "invalid or missing API key")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
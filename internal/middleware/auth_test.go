@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/oggyb/insider-assessment/internal/response"
+)
+
+func TestRequireAPIKey(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	cases := []struct {
+		name string
+		key  string
+		sent string
+		want int
+	}{
+		{"valid key", "secret", "secret", http.StatusNoContent},
+		{"wrong key", "secret", "nope", http.StatusUnauthorized},
+		{"missing key", "secret", "", http.StatusUnauthorized},
+		{"unconfigured", "", "", http.StatusForbidden},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/messages/x/retry", nil)
+			if tc.sent != "" {
+				req.Header.Set(APIKeyHeader, tc.sent)
+			}
+			rec := httptest.NewRecorder()
+			RequireAPIKey(tc.key, response.NewResponder())(ok).ServeHTTP(rec, req)
+
+			if rec.Code != tc.want {
+				t.Fatalf("expected %d, got %d", tc.want, rec.Code)
+			}
+		})
+	}
+}
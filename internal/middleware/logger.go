@@ -7,7 +7,8 @@ import (
 )
 
 // RequestLogger logs basic information about each HTTP request,
-// including method, path, remote address and how long it took to serve.
+// including method, path, client address (the real client IP behind
+// RealIP), how long it took to serve and, behind RequestID, the request ID.
 func RequestLogger() func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -15,7 +16,11 @@ func RequestLogger() func(http.Handler) http.Handler {
 
 			next.ServeHTTP(w, r)
 
-			log.Printf("%s %s %s [%s]", r.Method, r.URL.Path, r.RemoteAddr, time.Since(start))
+			if id := RequestIDFrom(r.Context()); id != "" {
+				log.Printf("%s %s %s [%s] id=%s", r.Method, r.URL.Path, clientAddr(r), time.Since(start), id)
+				return
+			}
+			log.Printf("%s %s %s [%s]", r.Method, r.URL.Path, clientAddr(r), time.Since(start))
 		})
 	}
 }
@@ -0,0 +1,27 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/oggyb/insider-assessment/internal/response"
+)
+
+// MaxURLLength rejects requests whose raw request URI (path plus query) is
+// longer than n bytes with a 414 JSON error written with rp, before they
+// reach a handler or the database. A non-positive n disables the check.
+func MaxURLLength(n int, rp *response.Responder) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if n <= 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if len(r.RequestURI) > n {
+				rp.Error(w, http.StatusRequestURITooLong,
+					fmt.Sprintf("request URI exceeds %d bytes", n))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
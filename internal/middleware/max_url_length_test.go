@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/oggyb/insider-assessment/internal/response"
+)
+
+func serveMaxURLLength(t *testing.T, limit int, target string) (*httptest.ResponseRecorder, bool) {
+	t.Helper()
+
+	called := false
+	h := MaxURLLength(limit, response.NewResponder())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+	return rec, called
+}
+
+func TestMaxURLLength_RejectsLongURI(t *testing.T) {
+	rec, called := serveMaxURLLength(t, 64, "/messages/search?q="+strings.Repeat("a", 100))
+
+	if called {
+		t.Fatal("expected the handler not to run")
+	}
+	if rec.Code != http.StatusRequestURITooLong {
+		t.Fatalf("expected 414, got %d", rec.Code)
+	}
+
+	var body response.JSONResponse
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if body.Success || body.Error == nil || body.Error.Code != http.StatusRequestURITooLong || body.Error.Message == "" {
+		t.Fatalf("expected a 414 error envelope, got %+v", body)
+	}
+}
+
+func TestMaxURLLength_PassesNormalURI(t *testing.T) {
+	rec, called := serveMaxURLLength(t, 64, "/messages/sent?page=1&limit=10")
+	if !called || rec.Code != http.StatusOK {
+		t.Fatalf("expected the request to pass, got %d (handler called: %v)", rec.Code, called)
+	}
+}
+
+func TestMaxURLLength_DisabledWhenNonPositive(t *testing.T) {
+	rec, called := serveMaxURLLength(t, 0, "/messages/search?q="+strings.Repeat("a", 10000))
+	if !called || rec.Code != http.StatusOK {
+		t.Fatalf("expected no limit, got %d (handler called: %v)", rec.Code, called)
+	}
+}
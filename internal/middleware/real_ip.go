@@ -0,0 +1,138 @@
+package middleware
+
+import (
+	"context"
+	"log"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+const (
+	headerForwardedFor = "X-Forwarded-For"
+	headerRealIP       = "X-Real-IP"
+)
+
+type clientIPKey struct{}
+
+// RealIP stores the client IP of every request in its context (see
+// ClientIPFrom). X-Forwarded-For and X-Real-IP are only honoured when the
+// immediate peer (r.RemoteAddr) is one of trustedProxies, given as IPs or
+// CIDRs; otherwise both headers are stripped so nothing downstream can be
+// fooled by a spoofed value. Invalid entries are logged and ignored.
+//
+// X-Forwarded-For is read right to left, skipping trusted proxies, so a
+// client cannot pick its IP by prepending entries. A malformed entry makes
+// the whole header untrustworthy; X-Real-IP and then the peer are used
+// instead.
+func RealIP(trustedProxies []string) func(http.Handler) http.Handler {
+	trusted := parseProxies(trustedProxies)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			peer, ok := peerAddr(r.RemoteAddr)
+
+			client := ""
+			if ok && isTrusted(trusted, peer) {
+				client = forwardedClient(r.Header.Values(headerForwardedFor), trusted)
+				if client == "" {
+					if ip, err := netip.ParseAddr(strings.TrimSpace(r.Header.Get(headerRealIP))); err == nil {
+						client = ip.Unmap().String()
+					}
+				}
+			} else {
+				r.Header.Del(headerForwardedFor)
+				r.Header.Del(headerRealIP)
+			}
+			if client == "" && ok {
+				client = peer.String()
+			}
+
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), clientIPKey{}, client)))
+		})
+	}
+}
+
+// ClientIPFrom returns the client IP stored by RealIP, or "".
+func ClientIPFrom(ctx context.Context) string {
+	ip, _ := ctx.Value(clientIPKey{}).(string)
+	return ip
+}
+
+// clientAddr returns the client IP of r for logging, falling back to
+// r.RemoteAddr when the request did not pass through RealIP.
+func clientAddr(r *http.Request) string {
+	if ip := ClientIPFrom(r.Context()); ip != "" {
+		return ip
+	}
+	return r.RemoteAddr
+}
+
+// parseProxies turns IPs and CIDRs into prefixes, skipping invalid entries.
+func parseProxies(entries []string) []netip.Prefix {
+	var out []netip.Prefix
+	for _, e := range entries {
+		e = strings.TrimSpace(e)
+		if p, err := netip.ParsePrefix(e); err == nil {
+			out = append(out, p.Masked())
+			continue
+		}
+		if ip, err := netip.ParseAddr(e); err == nil {
+			ip = ip.Unmap()
+			out = append(out, netip.PrefixFrom(ip, ip.BitLen()))
+			continue
+		}
+		log.Printf("[RealIP] Ignoring invalid trusted proxy %q", e)
+	}
+	return out
+}
+
+// peerAddr extracts the IP of the immediate peer from a RemoteAddr.
+func peerAddr(remoteAddr string) (netip.Addr, bool) {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return ip.Unmap(), true
+}
+
+func isTrusted(trusted []netip.Prefix, ip netip.Addr) bool {
+	for _, p := range trusted {
+		if p.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// forwardedClient walks X-Forwarded-For from the nearest hop outwards and
+// returns the first address that is not a trusted proxy. If every hop is
+// trusted it returns the farthest one. It returns "" when the header is
+// missing or any entry is not an IP.
+func forwardedClient(values []string, trusted []netip.Prefix) string {
+	var hops []netip.Addr
+	for _, v := range values {
+		for _, part := range strings.Split(v, ",") {
+			ip, err := netip.ParseAddr(strings.TrimSpace(part))
+			if err != nil {
+				return ""
+			}
+			hops = append(hops, ip.Unmap())
+		}
+	}
+	if len(hops) == 0 {
+		return ""
+	}
+
+	for i := len(hops) - 1; i >= 0; i-- {
+		if !isTrusted(trusted, hops[i]) {
+			return hops[i].String()
+		}
+	}
+	return hops[0].String()
+}
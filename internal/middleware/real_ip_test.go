@@ -0,0 +1,106 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// serveRealIP runs a request from remoteAddr with the given headers through
+// RealIP and returns the client IP and the headers the next handler saw.
+func serveRealIP(t *testing.T, trusted []string, remoteAddr string, headers map[string]string) (string, http.Header) {
+	t.Helper()
+
+	var ip string
+	var seen http.Header
+	h := RealIP(trusted)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip = ClientIPFrom(r.Context())
+		seen = r.Header.Clone()
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/health", nil)
+	req.RemoteAddr = remoteAddr
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	return ip, seen
+}
+
+func TestRealIP_TrustedPeer(t *testing.T) {
+	trusted := []string{"10.0.0.0/8", "192.168.1.1"}
+
+	cases := []struct {
+		name    string
+		peer    string
+		headers map[string]string
+		want    string
+	}{
+		{"forwarded for", "10.1.2.3:4567", map[string]string{"X-Forwarded-For": "203.0.113.7"}, "203.0.113.7"},
+		{"skips trusted hops", "10.1.2.3:4567", map[string]string{"X-Forwarded-For": "203.0.113.7, 10.9.9.9"}, "203.0.113.7"},
+		{"ignores spoofed prefix", "10.1.2.3:4567", map[string]string{"X-Forwarded-For": "1.1.1.1, 203.0.113.7"}, "203.0.113.7"},
+		{"single trusted ip", "192.168.1.1:80", map[string]string{"X-Forwarded-For": "198.51.100.2"}, "198.51.100.2"},
+		{"all hops trusted", "10.1.2.3:4567", map[string]string{"X-Forwarded-For": "10.5.5.5, 10.6.6.6"}, "10.5.5.5"},
+		{"real ip", "10.1.2.3:4567", map[string]string{"X-Real-IP": "203.0.113.9"}, "203.0.113.9"},
+		{"ipv6", "[::ffff:10.1.2.3]:4567", map[string]string{"X-Forwarded-For": "2001:db8::1"}, "2001:db8::1"},
+		{"no headers", "10.1.2.3:4567", nil, "10.1.2.3"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got, _ := serveRealIP(t, trusted, tc.peer, tc.headers); got != tc.want {
+				t.Fatalf("client IP = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRealIP_UntrustedPeerStripsHeaders(t *testing.T) {
+	ip, seen := serveRealIP(t, []string{"10.0.0.0/8"}, "203.0.113.50:1234", map[string]string{
+		"X-Forwarded-For": "1.2.3.4",
+		"X-Real-IP":       "1.2.3.4",
+	})
+
+	if ip != "203.0.113.50" {
+		t.Fatalf("client IP = %q, want the peer", ip)
+	}
+	if seen.Get("X-Forwarded-For") != "" || seen.Get("X-Real-IP") != "" {
+		t.Fatalf("expected forwarding headers to be stripped, got %v", seen)
+	}
+}
+
+func TestRealIP_NoTrustedProxiesUsesPeer(t *testing.T) {
+	ip, _ := serveRealIP(t, nil, "10.1.2.3:4567", map[string]string{"X-Forwarded-For": "1.2.3.4"})
+	if ip != "10.1.2.3" {
+		t.Fatalf("client IP = %q, want the peer", ip)
+	}
+}
+
+func TestRealIP_MalformedHeaders(t *testing.T) {
+	trusted := []string{"10.0.0.0/8", "not-a-cidr"}
+
+	cases := []struct {
+		name    string
+		headers map[string]string
+		want    string
+	}{
+		{"garbage forwarded for", map[string]string{"X-Forwarded-For": "<script>"}, "10.1.2.3"},
+		{"one bad hop", map[string]string{"X-Forwarded-For": "203.0.113.7, bogus"}, "10.1.2.3"},
+		{"empty hop", map[string]string{"X-Forwarded-For": "203.0.113.7,,"}, "10.1.2.3"},
+		{"bad hop falls back to real ip", map[string]string{"X-Forwarded-For": "bogus", "X-Real-IP": "203.0.113.9"}, "203.0.113.9"},
+		{"garbage real ip", map[string]string{"X-Real-IP": "203.0.113.9:80"}, "10.1.2.3"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got, _ := serveRealIP(t, trusted, "10.1.2.3:4567", tc.headers); got != tc.want {
+				t.Fatalf("client IP = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRealIP_UnparsablePeer(t *testing.T) {
+	ip, _ := serveRealIP(t, []string{"10.0.0.0/8"}, "pipe", map[string]string{"X-Forwarded-For": "1.2.3.4"})
+	if ip != "" {
+		t.Fatalf("client IP = %q, want empty for an unparsable peer", ip)
+	}
+}
@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/oggyb/insider-assessment/internal/reporter"
+	"github.com/oggyb/insider-assessment/internal/response"
+)
+
+// Recoverer catches panics raised by downstream handlers, reports them to
+// the given ErrorReporter and answers with a 500 JSON error written with rp
+// instead of dropping the connection.
+func Recoverer(rep reporter.ErrorReporter, rp *response.Responder) func(http.Handler) http.Handler {
+	if rep == nil {
+		rep = reporter.Noop{}
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			defer func() {
+				rec := recover()
+				if rec == nil {
+					return
+				}
+				// http.ErrAbortHandler is used deliberately to abort a response;
+				// let net/http handle it as usual.
+				if rec == http.ErrAbortHandler {
+					panic(rec)
+				}
+
+				err := fmt.Errorf("panic: %v", rec)
+				log.Printf("[Recover] %s %s: %v", r.Method, r.URL.Path, rec)
+
+				rep.Report(err, map[string]any{
+					"panic":      rec,
+					"method":     r.Method,
+					"path":       r.URL.Path,
+					"remoteAddr": r.RemoteAddr,
+					"clientIp":   ClientIPFrom(r.Context()),
+					"requestId":  RequestIDFrom(r.Context()),
+					"stack":      string(debug.Stack()),
+				})
+
+				rp.Error(w, http.StatusInternalServerError, "internal server error")
+			}()
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
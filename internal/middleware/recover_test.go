@@ -0,0 +1,72 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/oggyb/insider-assessment/internal/response"
+)
+
+// recordingReporter captures every Report call for assertions.
+type recordingReporter struct {
+	mu    sync.Mutex
+	errs  []error
+	ctxes []map[string]any
+}
+
+func (r *recordingReporter) Report(err error, ctx map[string]any) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.errs = append(r.errs, err)
+	r.ctxes = append(r.ctxes, ctx)
+}
+
+func TestRecoverer_ReportsPanic(t *testing.T) {
+	rep := &recordingReporter{}
+
+	h := Recoverer(rep, response.NewResponder())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/messages/sent", nil)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("expected 500, got %d", rec.Code)
+	}
+	if len(rep.errs) != 1 {
+		t.Fatalf("expected reporter to be called once, got %d", len(rep.errs))
+	}
+
+	ctx := rep.ctxes[0]
+	if ctx["panic"] != "boom" {
+		t.Fatalf("expected panic value %q, got %v", "boom", ctx["panic"])
+	}
+	if ctx["method"] != http.MethodGet || ctx["path"] != "/messages/sent" {
+		t.Fatalf("unexpected request context: %v", ctx)
+	}
+	if s, _ := ctx["stack"].(string); s == "" {
+		t.Fatalf("expected a stack trace in the report context")
+	}
+}
+
+func TestRecoverer_NoPanicNoReport(t *testing.T) {
+	rep := &recordingReporter{}
+
+	h := Recoverer(rep, response.NewResponder())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", rec.Code)
+	}
+	if len(rep.errs) != 0 {
+		t.Fatalf("expected no reports, got %d", len(rep.errs))
+	}
+}
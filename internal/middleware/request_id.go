@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/oggyb/insider-assessment/internal/response"
+)
+
+// maxRequestIDLength bounds client-supplied request IDs so they cannot
+// bloat logs and responses.
+const maxRequestIDLength = 128
+
+type requestIDKey struct{}
+
+// RequestID tags every request with an ID: the client's X-Request-ID if it
+// sent a usable one, otherwise a new UUID. The ID is echoed in the
+// X-Request-ID response header, which also lets response.Responder.Error
+// include it in error envelopes, and is stored in the request context
+// (see RequestIDFrom).
+func RequestID() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id := r.Header.Get(response.RequestIDHeader)
+			if !validRequestID(id) {
+				id = uuid.NewString()
+			}
+
+			w.Header().Set(response.RequestIDHeader, id)
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id)))
+		})
+	}
+}
+
+// RequestIDFrom returns the request ID stored by RequestID, or "".
+func RequestIDFrom(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// validRequestID accepts non-empty IDs of printable ASCII up to
+// maxRequestIDLength characters.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,81 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/oggyb/insider-assessment/internal/response"
+)
+
+// failingHandler answers every request with a 404 error envelope.
+var failingHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	response.NewResponder().Error(w, http.StatusNotFound, "message not found")
+})
+
+func errorRequestID(t *testing.T, rec *httptest.ResponseRecorder) string {
+	t.Helper()
+
+	var env response.JSONResponse
+	if err := json.NewDecoder(rec.Body).Decode(&env); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if env.Error == nil {
+		t.Fatalf("expected an error envelope, got %+v", env)
+	}
+	return env.Error.RequestID
+}
+
+func TestRequestID_EchoesClientIDInErrorEnvelope(t *testing.T) {
+	h := RequestID()(failingHandler)
+
+	req := httptest.NewRequest(http.MethodGet, "/messages/x", nil)
+	req.Header.Set(response.RequestIDHeader, "support-1234")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	if got := rec.Header().Get(response.RequestIDHeader); got != "support-1234" {
+		t.Fatalf("response header = %q, want the client's ID", got)
+	}
+	if got := errorRequestID(t, rec); got != "support-1234" {
+		t.Fatalf("error.requestId = %q, want the client's ID", got)
+	}
+}
+
+func TestRequestID_GeneratesIDWhenMissingOrInvalid(t *testing.T) {
+	h := RequestID()(failingHandler)
+
+	for _, sent := range []string{"", "has spaces", strings.Repeat("x", maxRequestIDLength+1)} {
+		req := httptest.NewRequest(http.MethodGet, "/messages/x", nil)
+		if sent != "" {
+			req.Header.Set(response.RequestIDHeader, sent)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+
+		header := rec.Header().Get(response.RequestIDHeader)
+		if header == "" || header == sent {
+			t.Fatalf("sent %q: expected a generated ID, got %q", sent, header)
+		}
+		if got := errorRequestID(t, rec); got != header {
+			t.Fatalf("sent %q: error.requestId = %q, want %q", sent, got, header)
+		}
+	}
+}
+
+func TestRequestID_StoredInContext(t *testing.T) {
+	var seen string
+	h := RequestID()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = RequestIDFrom(r.Context())
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(response.RequestIDHeader, "abc")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	if seen != "abc" {
+		t.Fatalf("RequestIDFrom = %q, want abc", seen)
+	}
+}
@@ -0,0 +1,61 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// RouteMatcher resolves the pattern a request is routed to;
+// *http.ServeMux implements it.
+type RouteMatcher interface {
+	Handler(r *http.Request) (h http.Handler, pattern string)
+}
+
+// Timeout puts a deadline on every request's context: the duration listed
+// in routes for the pattern the request matches, otherwise def. Route keys
+// are mux patterns either with the method ("GET /messages/sent") or
+// without it ("/messages/sent"); the former wins. A non-positive duration
+// means no deadline for that route.
+//
+// The deadline is enforced through the context, so it ends database and
+// provider calls made with it; a handler that already streams a response
+// finishes writing what it has.
+func Timeout(matcher RouteMatcher, def time.Duration, routes map[string]time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			d := routeTimeout(matcher, r, def, routes)
+			if d <= 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			ctx, cancel := context.WithTimeout(r.Context(), d)
+			defer cancel()
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// routeTimeout returns the timeout configured for r's route, or def.
+func routeTimeout(matcher RouteMatcher, r *http.Request, def time.Duration, routes map[string]time.Duration) time.Duration {
+	if len(routes) == 0 || matcher == nil {
+		return def
+	}
+
+	_, pattern := matcher.Handler(r)
+	if pattern == "" {
+		return def
+	}
+	if d, ok := routes[pattern]; ok {
+		return d
+	}
+	// Patterns may carry a method ("GET /health"); fall back to the path.
+	if _, path, ok := strings.Cut(pattern, " "); ok {
+		if d, ok := routes[strings.TrimSpace(path)]; ok {
+			return d
+		}
+	}
+	return def
+}
@@ -0,0 +1,107 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// deadlineMux routes a few patterns to a handler that records how long the
+// request context had left when the handler started.
+func deadlineMux(left *time.Duration, hasDeadline *bool) *http.ServeMux {
+	record := func(w http.ResponseWriter, r *http.Request) {
+		deadline, ok := r.Context().Deadline()
+		*hasDeadline = ok
+		*left = time.Until(deadline)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /health", record)
+	mux.HandleFunc("GET /messages/sent", record)
+	mux.HandleFunc("GET /messages/{id}/timeline", record)
+	mux.HandleFunc("POST /messages", record)
+	return mux
+}
+
+func TestTimeout_PerRouteDeadlines(t *testing.T) {
+	var left time.Duration
+	var hasDeadline bool
+	mux := deadlineMux(&left, &hasDeadline)
+
+	h := Timeout(mux, 10*time.Second, map[string]time.Duration{
+		"GET /health":             time.Second,
+		"/messages/sent":          time.Minute,
+		"/messages/{id}/timeline": 0,
+	})(mux)
+
+	cases := []struct {
+		method, target string
+		want           time.Duration // 0: no deadline expected
+	}{
+		{http.MethodGet, "/health", time.Second},
+		{http.MethodGet, "/messages/sent?page=2", time.Minute},
+		{http.MethodPost, "/messages", 10 * time.Second},
+		{http.MethodGet, "/messages/abc/timeline", 0},
+	}
+	for _, tc := range cases {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(tc.method, tc.target, nil))
+
+		if tc.want == 0 {
+			if hasDeadline {
+				t.Errorf("%s %s: expected no deadline, got %v left", tc.method, tc.target, left)
+			}
+			continue
+		}
+		if !hasDeadline || left > tc.want || left < tc.want-time.Second {
+			t.Errorf("%s %s: deadline in %v (set: %v), want about %v", tc.method, tc.target, left, hasDeadline, tc.want)
+		}
+	}
+}
+
+func TestTimeout_ExpiresSlowRequest(t *testing.T) {
+	mux := http.NewServeMux()
+	var err error
+	mux.HandleFunc("GET /messages/sent", func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+			err = r.Context().Err()
+		case <-time.After(time.Second):
+		}
+	})
+	mux.HandleFunc("GET /messages/export", func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+			err = r.Context().Err()
+		case <-time.After(50 * time.Millisecond):
+			err = nil
+		}
+	})
+
+	h := Timeout(mux, 20*time.Millisecond, map[string]time.Duration{
+		"GET /messages/export": time.Second,
+	})(mux)
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/messages/sent", nil))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("default route: expected the deadline to expire, got %v", err)
+	}
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/messages/export", nil))
+	if err != nil {
+		t.Fatalf("longer route: expected the request to finish, got %v", err)
+	}
+}
+
+func TestTimeout_DisabledByDefault(t *testing.T) {
+	var left time.Duration
+	var hasDeadline bool
+	mux := deadlineMux(&left, &hasDeadline)
+
+	Timeout(mux, 0, nil)(mux).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/health", nil))
+	if hasDeadline {
+		t.Fatalf("expected no deadline without a timeout, got %v left", left)
+	}
+}
@@ -0,0 +1,81 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/oggyb/insider-assessment/internal/response"
+)
+
+// MaxWebhookBodyBytes caps how much of an inbound webhook body is buffered
+// for signature verification; larger bodies get 413.
+const MaxWebhookBodyBytes = 1 << 20
+
+type rawBodyKey struct{}
+
+// VerifyWebhookSignature only lets requests through whose header carries
+// the hex HMAC-SHA256 of the raw request body under secret, optionally
+// prefixed with "sha256=" as many providers send it. Mismatching or missing
+// signatures get 401. If secret is empty every request is rejected with 403,
+// so an unconfigured secret never leaves an inbound endpoint open. Errors
+// are written with rp.
+//
+// The body is read once to verify it; the handler gets an identical,
+// re-readable r.Body and can also take the raw bytes from RawBodyFrom.
+func VerifyWebhookSignature(secret, header string, rp *response.Responder) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if secret == "" {
+				rp.Error(w, http.StatusForbidden, "webhook endpoint is disabled")
+				return
+			}
+
+			body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, MaxWebhookBodyBytes))
+			if err != nil {
+				var tooLarge *http.MaxBytesError
+				if errors.As(err, &tooLarge) {
+					rp.Error(w, http.StatusRequestEntityTooLarge, "webhook body too large")
+					return
+				}
+				rp.Error(w, http.StatusBadRequest, "failed to read webhook body")
+				return
+			}
+
+			if !validSignature(secret, body, r.Header.Get(header)) {
+				rp.Error(w, http.StatusUnauthorized, "invalid or missing webhook signature")
+				return
+			}
+
+			r.Body = io.NopCloser(bytes.NewReader(body))
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), rawBodyKey{}, body)))
+		})
+	}
+}
+
+// RawBodyFrom returns the request body verified by VerifyWebhookSignature,
+// or nil.
+func RawBodyFrom(ctx context.Context) []byte {
+	body, _ := ctx.Value(rawBodyKey{}).([]byte)
+	return body
+}
+
+// validSignature reports whether sig is the hex HMAC-SHA256 of body under
+// secret, comparing in constant time.
+func validSignature(secret string, body []byte, sig string) bool {
+	sig = strings.TrimPrefix(strings.TrimSpace(sig), "sha256=")
+	got, err := hex.DecodeString(sig)
+	if err != nil || len(got) != sha256.Size {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(got, mac.Sum(nil))
+}
@@ -0,0 +1,91 @@
+package middleware
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/oggyb/insider-assessment/internal/response"
+)
+
+const testSignatureHeader = "X-Signature"
+
+func sign(secret, body string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(body))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// serveSigned sends body with the given signature through
+// VerifyWebhookSignature and returns the response plus what the handler
+// read from r.Body and RawBodyFrom.
+func serveSigned(t *testing.T, secret, body, sig string) (rec *httptest.ResponseRecorder, readBody, rawBody string) {
+	t.Helper()
+
+	h := VerifyWebhookSignature(secret, testSignatureHeader, response.NewResponder())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("read body: %v", err)
+		}
+		readBody = string(b)
+		rawBody = string(RawBodyFrom(r.Context()))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/webhooks/sms", strings.NewReader(body))
+	if sig != "" {
+		req.Header.Set(testSignatureHeader, sig)
+	}
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec, readBody, rawBody
+}
+
+func TestVerifyWebhookSignature_ValidPayload(t *testing.T) {
+	body := `{"messageId":"abc","status":"DELIVERED"}`
+
+	for _, sig := range []string{sign("s3cret", body), "sha256=" + sign("s3cret", body)} {
+		rec, readBody, rawBody := serveSigned(t, "s3cret", body, sig)
+		if rec.Code != http.StatusNoContent {
+			t.Fatalf("signature %q: expected 204, got %d: %s", sig, rec.Code, rec.Body.String())
+		}
+		if readBody != body || rawBody != body {
+			t.Fatalf("expected the handler to see the original body, got %q / %q", readBody, rawBody)
+		}
+	}
+}
+
+func TestVerifyWebhookSignature_Rejects(t *testing.T) {
+	body := `{"messageId":"abc","status":"DELIVERED"}`
+
+	cases := []struct {
+		name   string
+		secret string
+		body   string
+		sig    string
+		want   int
+	}{
+		{"tampered body", "s3cret", `{"messageId":"abc","status":"FAILED"}`, sign("s3cret", body), http.StatusUnauthorized},
+		{"wrong secret", "s3cret", body, sign("other", body), http.StatusUnauthorized},
+		{"missing signature", "s3cret", body, "", http.StatusUnauthorized},
+		{"malformed signature", "s3cret", body, "not-hex", http.StatusUnauthorized},
+		{"unconfigured", "", body, sign("", body), http.StatusForbidden},
+		{"oversized body", "s3cret", strings.Repeat("a", MaxWebhookBodyBytes+1), "", http.StatusRequestEntityTooLarge},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			rec, readBody, _ := serveSigned(t, tc.secret, tc.body, tc.sig)
+			if rec.Code != tc.want {
+				t.Fatalf("expected %d, got %d", tc.want, rec.Code)
+			}
+			if readBody != "" {
+				t.Fatal("expected the handler not to run")
+			}
+		})
+	}
+}
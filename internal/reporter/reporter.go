@@ -0,0 +1,58 @@
+// Package reporter defines a small port for shipping unexpected errors
+// (e.g. recovered panics) to an external error tracker.
+package reporter
+
+import (
+	"encoding/json"
+	"log"
+)
+
+// ErrorReporter receives errors together with structured context.
+// Implementations must be safe for concurrent use and must not panic.
+type ErrorReporter interface {
+	Report(err error, ctx map[string]any)
+}
+
+// Noop is an ErrorReporter that discards everything. It is the default
+// when no external sink is configured.
+type Noop struct{}
+
+// Report implements ErrorReporter.
+func (Noop) Report(error, map[string]any) {}
+
+// Log is an ErrorReporter that writes each report as a single JSON line
+// to the standard logger. Useful when logs are already shipped to an
+// aggregator that can index JSON fields.
+type Log struct{}
+
+// Report implements ErrorReporter.
+func (Log) Report(err error, ctx map[string]any) {
+	entry := map[string]any{
+		"error":   err.Error(),
+		"context": ctx,
+	}
+
+	b, mErr := json.Marshal(entry)
+	if mErr != nil {
+		log.Printf("[Reporter] %v (context: %v)", err, ctx)
+		return
+	}
+	log.Printf("[Reporter] %s", b)
+}
+
+// New returns the reporter registered under the given name.
+// Unknown or empty names fall back to Noop.
+func New(name string) ErrorReporter {
+	switch name {
+	case "log":
+		return Log{}
+	default:
+		return Noop{}
+	}
+}
+
+// compile-time checks
+var (
+	_ ErrorReporter = Noop{}
+	_ ErrorReporter = Log{}
+)
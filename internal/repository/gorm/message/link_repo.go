@@ -0,0 +1,42 @@
+package messagegorm
+
+import (
+	"context"
+	"time"
+
+	"github.com/oggyb/insider-assessment/internal/domain/message"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// SaveLink inserts a new tracking link.
+func (r *Repository) SaveLink(ctx context.Context, l *message.Link) error {
+	return r.db.WithContext(ctx).Create(linkFromDomain(l)).Error
+}
+
+// RecordLinkClick increments the link's click count and sets its click
+// times in a single UPDATE ... RETURNING, so concurrent clicks are all
+// counted.
+func (r *Repository) RecordLinkClick(ctx context.Context, token string, at time.Time) (*message.Link, error) {
+	var models []LinkModel
+
+	res := r.db.WithContext(ctx).
+		Model(&models).
+		Clauses(clause.Returning{}).
+		Where("token = ?", token).
+		Updates(map[string]any{
+			"clicks":           gorm.Expr("clicks + 1"),
+			"first_clicked_at": gorm.Expr("COALESCE(first_clicked_at, ?)", at),
+			"last_clicked_at":  at,
+		})
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if res.RowsAffected == 0 || len(models) == 0 {
+		return nil, message.ErrLinkNotFound
+	}
+	return linkToDomain(&models[0]), nil
+}
+
+// compile-time interface check
+var _ message.LinkRepository = (*Repository)(nil)
@@ -1,21 +1,37 @@
 package messagegorm
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	"github.com/google/uuid"
 	"github.com/oggyb/insider-assessment/internal/domain/message"
 )
 
 // toDomain maps a GORM MessageModel to a domain-level Message.
 func toDomain(m *MessageModel) *message.Message {
 	return &message.Message{
-		ID:          m.ID,
-		To:          m.To,
-		Content:     m.Content,
-		Status:      message.Status(m.Status),
-		MessageID:   m.MessageID,
-		RawResponse: m.RawResponse,
-		SentAt:      m.SentAt,
-		CreatedAt:   m.CreatedAt,
-		UpdatedAt:   m.UpdatedAt,
+		ID:           m.ID,
+		To:           m.To,
+		Content:      m.Content,
+		Status:       message.Status(m.Status),
+		MessageID:    m.MessageID,
+		RawResponse:  m.RawResponse,
+		SentAt:       m.SentAt,
+		CreatedAt:    m.CreatedAt,
+		UpdatedAt:    m.UpdatedAt,
+		ExpiresAt:    m.ExpiresAt,
+		StatusReason: m.StatusReason,
+		Provider:     m.Provider,
+		RetryCount:   m.RetryCount,
+		NextRetryAt:  m.NextRetryAt,
+		Tags:         m.Tags,
+		TemplateID:   m.TemplateID,
+		SendTimeout:  durationFromMs(m.SendTimeoutMs),
+		SendDuration: durationFromMs(m.SendDurationMs),
+		Encoding:     message.Encoding(m.Encoding),
+		ProviderCode: m.ProviderCode,
 	}
 }
 
@@ -31,14 +47,173 @@ func toDomainMany(models []MessageModel) []*message.Message {
 // fromDomain maps a domain-level Message to a GORM MessageModel.
 func fromDomain(d *message.Message) *MessageModel {
 	return &MessageModel{
-		ID:          d.ID,
-		To:          d.To,
-		Content:     d.Content,
-		Status:      string(d.Status),
-		MessageID:   d.MessageID,
-		RawResponse: d.RawResponse,
-		SentAt:      d.SentAt,
-		CreatedAt:   d.CreatedAt,
-		UpdatedAt:   d.UpdatedAt,
+		ID:             d.ID,
+		To:             d.To,
+		Content:        d.Content,
+		Status:         string(d.Status),
+		MessageID:      d.MessageID,
+		RawResponse:    d.RawResponse,
+		SentAt:         d.SentAt,
+		CreatedAt:      d.CreatedAt,
+		UpdatedAt:      d.UpdatedAt,
+		ExpiresAt:      d.ExpiresAt,
+		StatusReason:   d.StatusReason,
+		Provider:       d.Provider,
+		RetryCount:     d.RetryCount,
+		NextRetryAt:    d.NextRetryAt,
+		Tags:           d.Tags,
+		TemplateID:     d.TemplateID,
+		SendTimeoutMs:  durationToMs(d.SendTimeout),
+		SendDurationMs: durationToMs(d.SendDuration),
+		Encoding:       string(d.Encoding),
+		ProviderCode:   d.ProviderCode,
+		ContentHash:    contentHash(d.To, d.Content),
+	}
+}
+
+// contentHash returns the hex SHA-256 of to and content. A newline separates
+// them, which cannot occur in a phone number, so different splits of the
+// same characters never collide.
+func contentHash(to, content string) *string {
+	sum := sha256.Sum256([]byte(to + "\n" + content))
+	h := hex.EncodeToString(sum[:])
+	return &h
+}
+
+// durationToMs converts an optional duration to whole milliseconds.
+func durationToMs(d *time.Duration) *int64 {
+	if d == nil {
+		return nil
+	}
+	ms := d.Milliseconds()
+	return &ms
+}
+
+// durationFromMs converts optional milliseconds back to a duration.
+func durationFromMs(ms *int64) *time.Duration {
+	if ms == nil {
+		return nil
+	}
+	d := time.Duration(*ms) * time.Millisecond
+	return &d
+}
+
+// statusEventToDomain maps a StatusEventModel to a domain StatusEvent.
+func statusEventToDomain(m *StatusEventModel) *message.StatusEvent {
+	return &message.StatusEvent{
+		ID:        m.ID,
+		MessageID: m.MessageID,
+		From:      message.Status(m.FromStatus),
+		To:        message.Status(m.ToStatus),
+		At:        m.At,
+	}
+}
+
+// statusEventFromDomain maps a domain StatusEvent to a StatusEventModel.
+func statusEventFromDomain(e *message.StatusEvent) *StatusEventModel {
+	return &StatusEventModel{
+		ID:         e.ID,
+		MessageID:  e.MessageID,
+		FromStatus: string(e.From),
+		ToStatus:   string(e.To),
+		At:         e.At,
+	}
+}
+
+// batchRunToDomain maps a BatchRunModel to a domain BatchRun.
+func batchRunToDomain(m *BatchRunModel) *message.BatchRun {
+	return &message.BatchRun{
+		ID: m.ID,
+		BatchResult: message.BatchResult{
+			StartedAt: m.StartedAt,
+			Duration:  time.Duration(m.DurationMs) * time.Millisecond,
+			Processed: m.Processed,
+			Succeeded: m.Succeeded,
+			Failed:    m.Failed,
+			Workers:   m.Workers,
+			Error:     m.Error,
+		},
+	}
+}
+
+// batchRunFromDomain maps a domain BatchResult to a new BatchRunModel.
+func batchRunFromDomain(r message.BatchResult) *BatchRunModel {
+	return &BatchRunModel{
+		ID:         uuid.New(),
+		StartedAt:  r.StartedAt,
+		DurationMs: r.Duration.Milliseconds(),
+		Processed:  r.Processed,
+		Succeeded:  r.Succeeded,
+		Failed:     r.Failed,
+		Workers:    r.Workers,
+		Error:      r.Error,
+	}
+}
+
+// templateToDomain maps a TemplateModel to a domain Template.
+func templateToDomain(m *TemplateModel) *message.Template {
+	return &message.Template{
+		ID:        m.ID,
+		Name:      m.Name,
+		Body:      m.Body,
+		CreatedAt: m.CreatedAt,
+		UpdatedAt: m.UpdatedAt,
+	}
+}
+
+// templateFromDomain maps a domain Template to a TemplateModel.
+func templateFromDomain(t *message.Template) *TemplateModel {
+	return &TemplateModel{
+		ID:        t.ID,
+		Name:      t.Name,
+		Body:      t.Body,
+		CreatedAt: t.CreatedAt,
+		UpdatedAt: t.UpdatedAt,
+	}
+}
+
+// optOutTokenToDomain maps an OptOutTokenModel to a domain OptOutToken.
+func optOutTokenToDomain(m *OptOutTokenModel) *message.OptOutToken {
+	return &message.OptOutToken{
+		Token:      m.Token,
+		To:         m.To,
+		CreatedAt:  m.CreatedAt,
+		OptedOutAt: m.OptedOutAt,
+	}
+}
+
+// optOutTokenFromDomain maps a domain OptOutToken to an OptOutTokenModel.
+func optOutTokenFromDomain(t *message.OptOutToken) *OptOutTokenModel {
+	return &OptOutTokenModel{
+		Token:      t.Token,
+		To:         t.To,
+		CreatedAt:  t.CreatedAt,
+		OptedOutAt: t.OptedOutAt,
+	}
+}
+
+// linkToDomain maps a LinkModel to a domain Link.
+func linkToDomain(m *LinkModel) *message.Link {
+	return &message.Link{
+		Token:          m.Token,
+		URL:            m.URL,
+		MessageID:      m.MessageID,
+		CreatedAt:      m.CreatedAt,
+		Clicks:         m.Clicks,
+		FirstClickedAt: m.FirstClickedAt,
+		LastClickedAt:  m.LastClickedAt,
+	}
+}
+
+// linkFromDomain maps a domain Link to a LinkModel.
+func linkFromDomain(l *message.Link) *LinkModel {
+	return &LinkModel{
+		Token:          l.Token,
+		URL:            l.URL,
+		MessageID:      l.MessageID,
+		CreatedAt:      l.CreatedAt,
+		Clicks:         l.Clicks,
+		FirstClickedAt: l.FirstClickedAt,
+		LastClickedAt:  l.LastClickedAt,
 	}
 }
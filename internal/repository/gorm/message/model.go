@@ -1,30 +1,121 @@
 package messagegorm
 
 import (
+	"fmt"
+	"regexp"
 	"time"
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
 )
 
+// identifier matches the unquoted Postgres names accepted for the schema
+// and table prefix.
+var identifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// TableNaming places every table under Schema (empty: the connection's
+// search_path, normally public) and prepends Prefix to its name, so e.g.
+// {"tenant", "acme_"} maps messages to tenant.acme_messages. It is applied
+// per connection through NamingStrategy, so one process may hold
+// repositories with different namings.
+type TableNaming struct {
+	Schema string
+	Prefix string
+}
+
+// NamingStrategy returns the GORM naming strategy that applies n, or an
+// error if the schema or prefix is not a plain Postgres identifier. Pass it
+// to every connection a Repository uses (see gormdb.WithNamingStrategy).
+//
+// Indexes are named after the qualified table (idx_acme_messages_tags, ...)
+// rather than fixed, so tenants told apart only by their table prefix do
+// not collide in one schema.
+func (n TableNaming) NamingStrategy() (schema.NamingStrategy, error) {
+	if n.Schema != "" && !identifier.MatchString(n.Schema) {
+		return schema.NamingStrategy{}, fmt.Errorf("invalid DB schema %q", n.Schema)
+	}
+	if n.Prefix != "" && !identifier.MatchString(n.Prefix) {
+		return schema.NamingStrategy{}, fmt.Errorf("invalid DB table prefix %q", n.Prefix)
+	}
+	prefix := n.Prefix
+	if n.Schema != "" {
+		prefix = n.Schema + "." + prefix
+	}
+	return schema.NamingStrategy{TablePrefix: prefix}, nil
+}
+
+// legacyStatusEventIndex is the fixed name the status event index had
+// before index names followed the table name.
+const legacyStatusEventIndex = "idx_status_events_message_at"
+
+// RenameLegacyIndexes gives an index created under its former fixed name
+// the name AutoMigrate now expects under n, so it is not built a second
+// time. Only unprefixed tables can own the old name. Call it before
+// AutoMigrate.
+func RenameLegacyIndexes(db *gorm.DB, n TableNaming) error {
+	namer, err := n.NamingStrategy()
+	if err != nil {
+		return err
+	}
+	if n.Prefix != "" {
+		return nil
+	}
+	legacy := legacyStatusEventIndex
+	if n.Schema != "" {
+		legacy = n.Schema + "." + legacy
+	}
+	return db.Exec(fmt.Sprintf("ALTER INDEX IF EXISTS %s RENAME TO %s",
+		legacy, namer.IndexName(StatusEventModel{}.TableName(namer), "message_at"))).Error
+}
+
+// contentHashIndex returns the name of the unsent content hash index under
+// namer.
+func contentHashIndex(namer schema.Namer) string {
+	return namer.IndexName(MessageModel{}.TableName(namer), "unsent_content_hash")
+}
+
 // MessageModel is the GORM persistence model for messages.
 // It maps directly to the "messages" table in Postgres.
 type MessageModel struct {
-	ID          uuid.UUID  `gorm:"type:uuid;primaryKey"`
-	To          string     `gorm:"size:20;not null"`
-	Content     string     `gorm:"size:255;not null"`
-	Status      string     `gorm:"size:20;not null"`
-	RawResponse string     `gorm:"type:text"`
-	MessageID   string     `gorm:"size:100;index"`
-	SentAt      *time.Time `gorm:"index"`
-	CreatedAt   time.Time  `gorm:"not null;index"`
-	UpdatedAt   time.Time
-	DeletedAt   gorm.DeletedAt `gorm:"index"`
+	ID           uuid.UUID  `gorm:"type:uuid;primaryKey"`
+	To           string     `gorm:"size:20;not null;index:,composite:to_created_at,priority:1"`
+	Content      string     `gorm:"size:255;not null"`
+	Status       string     `gorm:"size:20;not null"`
+	RawResponse  string     `gorm:"type:text"`
+	MessageID    string     `gorm:"size:100;index"`
+	SentAt       *time.Time `gorm:"index"`
+	CreatedAt    time.Time  `gorm:"not null;index;index:,composite:to_created_at,priority:2"`
+	UpdatedAt    time.Time
+	DeletedAt    gorm.DeletedAt `gorm:"index"`
+	ExpiresAt    *time.Time     `gorm:"index"`
+	StatusReason string         `gorm:"size:255"`
+	Provider     string         `gorm:"size:50"`
+	RetryCount   int            `gorm:"not null;default:0"`
+	Tags         Tags           `gorm:"type:jsonb;index:,type:gin"`
+	TemplateID   *uuid.UUID     `gorm:"type:uuid;index"`
+	// SendTimeoutMs is the optional per-message send timeout in milliseconds.
+	SendTimeoutMs *int64
+	// SendDurationMs is how long the last provider call took in milliseconds.
+	SendDurationMs *int64
+	// Encoding is the optional per-message encoding hint (GSM7 or UCS2).
+	Encoding string `gorm:"size:10"`
+	// ProviderCode is the result or error code parsed from RawResponse,
+	// indexed so failures can be grouped by reason.
+	ProviderCode string `gorm:"size:64;index"`
+	// NextRetryAt holds a retried message back from GetPending until its
+	// backoff has elapsed.
+	NextRetryAt *time.Time
+	// ContentHash is the hex SHA-256 of recipient and content. The partial
+	// unique index rejects a second identical message while one is still
+	// unsent; it is NULL when content de-duplication is disabled.
+	ContentHash *string `gorm:"size:64;uniqueIndex:,composite:unsent_content_hash,where:(status = 'PENDING' OR status = 'PROCESSING') AND deleted_at IS NULL"`
 }
 
-// TableName overrides the default table name used by GORM.
-func (MessageModel) TableName() string {
-	return "messages"
+// TableName overrides the default table name used by GORM with messages,
+// prefixed by the connection's naming strategy (see TableNaming).
+func (MessageModel) TableName(namer schema.Namer) string {
+	return namer.TableName("Message")
 }
 
 // BeforeCreate ensures a UUID is set before inserting a new record.
@@ -34,3 +125,87 @@ func (m *MessageModel) BeforeCreate(tx *gorm.DB) error {
 	}
 	return nil
 }
+
+// StatusEventModel is the GORM persistence model for message status transitions.
+// It maps to the "message_status_events" table.
+type StatusEventModel struct {
+	ID         uuid.UUID `gorm:"type:uuid;primaryKey"`
+	MessageID  uuid.UUID `gorm:"type:uuid;not null;index:,composite:message_at,priority:1"`
+	FromStatus string    `gorm:"size:20"`
+	ToStatus   string    `gorm:"size:20;not null"`
+	At         time.Time `gorm:"not null;index:,composite:message_at,priority:2"`
+}
+
+// TableName overrides the default table name used by GORM with message_status_events,
+// prefixed by the connection's naming strategy (see TableNaming).
+func (StatusEventModel) TableName(namer schema.Namer) string {
+	return namer.TableName("MessageStatusEvent")
+}
+
+// BatchRunModel is the GORM persistence model for recorded batch runs.
+// It maps to the "batch_runs" table.
+type BatchRunModel struct {
+	ID         uuid.UUID `gorm:"type:uuid;primaryKey"`
+	StartedAt  time.Time `gorm:"not null;index"`
+	DurationMs int64     `gorm:"not null"`
+	Processed  int       `gorm:"not null"`
+	Succeeded  int       `gorm:"not null"`
+	Failed     int       `gorm:"not null"`
+	Workers    int       `gorm:"not null;default:0"`
+	Error      string    `gorm:"type:text"`
+}
+
+// TableName overrides the default table name used by GORM with batch_runs,
+// prefixed by the connection's naming strategy (see TableNaming).
+func (BatchRunModel) TableName(namer schema.Namer) string {
+	return namer.TableName("BatchRun")
+}
+
+// TemplateModel is the GORM persistence model for message templates.
+// It maps to the "message_templates" table.
+type TemplateModel struct {
+	ID        uuid.UUID `gorm:"type:uuid;primaryKey"`
+	Name      string    `gorm:"size:100;not null;uniqueIndex"`
+	Body      string    `gorm:"type:text;not null"`
+	CreatedAt time.Time `gorm:"not null"`
+	UpdatedAt time.Time `gorm:"not null"`
+}
+
+// TableName overrides the default table name used by GORM with message_templates,
+// prefixed by the connection's naming strategy (see TableNaming).
+func (TemplateModel) TableName(namer schema.Namer) string {
+	return namer.TableName("MessageTemplate")
+}
+
+// OptOutTokenModel is the GORM persistence model for recipients' opt-out
+// tokens. It maps to the "opt_out_tokens" table.
+type OptOutTokenModel struct {
+	Token      string     `gorm:"size:32;primaryKey"`
+	To         string     `gorm:"size:20;not null;uniqueIndex"`
+	CreatedAt  time.Time  `gorm:"not null"`
+	OptedOutAt *time.Time `gorm:"index"`
+}
+
+// TableName overrides the default table name used by GORM with opt_out_tokens,
+// prefixed by the connection's naming strategy (see TableNaming).
+func (OptOutTokenModel) TableName(namer schema.Namer) string {
+	return namer.TableName("OptOutToken")
+}
+
+// LinkModel is the GORM persistence model for click tracking links. It maps
+// to the "links" table.
+type LinkModel struct {
+	Token          string    `gorm:"size:32;primaryKey"`
+	URL            string    `gorm:"type:text;not null"`
+	MessageID      uuid.UUID `gorm:"type:uuid;not null;index"`
+	CreatedAt      time.Time `gorm:"not null"`
+	Clicks         int64     `gorm:"not null;default:0"`
+	FirstClickedAt *time.Time
+	LastClickedAt  *time.Time
+}
+
+// TableName overrides the default table name used by GORM with links,
+// prefixed by the connection's naming strategy (see TableNaming).
+func (LinkModel) TableName(namer schema.Namer) string {
+	return namer.TableName("Link")
+}
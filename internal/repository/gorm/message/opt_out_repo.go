@@ -0,0 +1,69 @@
+package messagegorm
+
+import (
+	"context"
+	"time"
+
+	"github.com/oggyb/insider-assessment/internal/domain/message"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// OptOutTokenFor returns the opt-out token of to. The first call for a
+// recipient inserts a new token with INSERT ... ON CONFLICT ("to") DO
+// NOTHING, so concurrent callers end up sharing whichever one won.
+func (r *Repository) OptOutTokenFor(ctx context.Context, to string) (*message.OptOutToken, error) {
+	tok, err := message.NewOptOutToken(to)
+	if err != nil {
+		return nil, err
+	}
+
+	err = r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "to"}},
+			DoNothing: true,
+		}).
+		Create(optOutTokenFromDomain(tok)).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var model OptOutTokenModel
+	if err := r.db.WithContext(ctx).Where(`"to" = ?`, tok.To).Take(&model).Error; err != nil {
+		return nil, err
+	}
+	return optOutTokenToDomain(&model), nil
+}
+
+// ResolveOptOutToken sets opted_out_at on the token's row unless it is
+// already set, in a single UPDATE ... RETURNING.
+func (r *Repository) ResolveOptOutToken(ctx context.Context, token string, at time.Time) (*message.OptOutToken, error) {
+	var models []OptOutTokenModel
+
+	res := r.db.WithContext(ctx).
+		Model(&models).
+		Clauses(clause.Returning{}).
+		Where("token = ?", token).
+		Update("opted_out_at", gorm.Expr("COALESCE(opted_out_at, ?)", at))
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if res.RowsAffected == 0 || len(models) == 0 {
+		return nil, message.ErrOptOutTokenNotFound
+	}
+	return optOutTokenToDomain(&models[0]), nil
+}
+
+// IsOptedOut reports whether to has resolved their opt-out token. It reads
+// from the primary so an opt-out takes effect on the very next send.
+func (r *Repository) IsOptedOut(ctx context.Context, to string) (bool, error) {
+	var n int64
+	err := r.db.WithContext(ctx).
+		Model(&OptOutTokenModel{}).
+		Where(`"to" = ? AND opted_out_at IS NOT NULL`, to).
+		Count(&n).Error
+	return n > 0, err
+}
+
+// compile-time interface check
+var _ message.OptOutRepository = (*Repository)(nil)
@@ -2,6 +2,15 @@ package messagegorm
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
 
 	"github.com/oggyb/insider-assessment/internal/db"
 	"github.com/oggyb/insider-assessment/internal/domain/message"
@@ -11,23 +20,163 @@ import (
 
 // Repository is a GORM-backed implementation of the message.Repository interface.
 type Repository struct {
+	// db is the primary connection, used for all writes and for reads
+	// that take row locks.
 	db *gorm.DB
+
+	// reader serves read-only queries (listings, counts). It points at a
+	// read replica when one is configured, otherwise at the primary.
+	reader *gorm.DB
+
+	// dedupContent stores content hashes so the unique index rejects
+	// identical unsent messages.
+	dedupContent bool
+
+	// retryPriority orders retried messages before or after fresh ones in
+	// GetPending; empty keeps plain creation order.
+	retryPriority RetryPriority
+
+	// retryBudget caps the share of a GetPending batch that retried
+	// messages may take while fresh ones are waiting; 0 disables it.
+	retryBudget float64
+
+	// groupByRecipient orders each GetPending batch by recipient.
+	groupByRecipient bool
+}
+
+// RetryPriority decides whether GetPending hands out retried messages
+// (retry_count > 0) before or after fresh ones.
+type RetryPriority string
+
+const (
+	// RetryPriorityFirst fetches retried messages before fresh ones.
+	RetryPriorityFirst RetryPriority = "first"
+	// RetryPriorityLast fetches fresh messages before retried ones.
+	RetryPriorityLast RetryPriority = "last"
+)
+
+// ParseRetryPriority resolves a configured retry priority. An empty name
+// means no preference.
+func ParseRetryPriority(name string) (RetryPriority, error) {
+	switch p := RetryPriority(strings.ToLower(strings.TrimSpace(name))); p {
+	case "", RetryPriorityFirst, RetryPriorityLast:
+		return p, nil
+	default:
+		return "", fmt.Errorf("unknown retry priority %q (want first or last)", name)
+	}
+}
+
+// Option customizes a Repository at construction time.
+type Option func(*Repository)
+
+// WithReadDB routes read-only queries to the given DB adapter (e.g. a read
+// replica). A nil adapter keeps reads on the primary.
+func WithReadDB(d db.DB) Option {
+	return func(r *Repository) {
+		if d != nil {
+			r.reader = d.Conn().(*gorm.DB)
+		}
+	}
+}
+
+// WithContentDedup makes Save reject a message whose recipient and content
+// match a message that is still PENDING or PROCESSING, returning
+// message.ErrDuplicateMessage.
+func WithContentDedup(enabled bool) Option {
+	return func(r *Repository) {
+		r.dedupContent = enabled
+	}
+}
+
+// WithRetryPriority makes GetPending fetch retried messages before
+// (RetryPriorityFirst) or after (RetryPriorityLast) fresh ones; within each
+// group messages stay in creation order.
+func WithRetryPriority(p RetryPriority) Option {
+	return func(r *Repository) {
+		r.retryPriority = p
+	}
+}
+
+// WithRetryBudget limits retried messages to fraction (0 to 1) of each
+// GetPending batch, reserving the rest for fresh messages so a backlog of
+// retries cannot starve new traffic. Retries still fill any capacity fresh
+// messages leave unused. At least one retry is admitted per batch, and
+// values outside (0, 1) disable the budget.
+func WithRetryBudget(fraction float64) Option {
+	return func(r *Repository) {
+		if fraction > 0 && fraction < 1 {
+			r.retryBudget = fraction
+		} else {
+			r.retryBudget = 0
+		}
+	}
+}
+
+// WithRecipientGrouping makes GetPending order each batch by recipient,
+// so providers with bulk endpoints get a recipient's messages together.
+// Which messages are claimed does not change, only their order within the
+// batch; a recipient's own messages keep their order.
+func WithRecipientGrouping(enabled bool) Option {
+	return func(r *Repository) {
+		r.groupByRecipient = enabled
+	}
 }
 
 // NewRepository constructs a message repository using the given DB adapter.
-func NewRepository(d db.DB) *Repository {
-	return &Repository{
-		db: d.Conn().(*gorm.DB),
+func NewRepository(d db.DB, opts ...Option) *Repository {
+	primary := d.Conn().(*gorm.DB)
+
+	r := &Repository{
+		db:     primary,
+		reader: primary,
+	}
+
+	for _, opt := range opts {
+		opt(r)
 	}
+
+	return r
 }
 
-// GetPending returns up to limit pending messages ordered by creation time,
-// using SELECT ... FOR UPDATE SKIP LOCKED to avoid double-processing in concurrent workers.
+// GetPending returns up to limit pending, non-expired messages ordered by creation
+// time, using SELECT ... FOR UPDATE SKIP LOCKED to avoid double-processing in
+// concurrent workers. Messages waiting out a retry backoff (next_retry_at in
+// the future) are skipped. With WithRetryPriority, retried messages are
+// grouped before or after fresh ones; with WithRetryBudget, at most the
+// budgeted share of them is included while fresh messages are waiting.
+// With WithRecipientGrouping, the claimed batch is then ordered by recipient.
+//
+// If the context is already cancelled or past its deadline, GetPending returns
+// the context error without touching the database, so no rows get locked by a
+// query that is about to be aborted anyway.
 func (r *Repository) GetPending(ctx context.Context, limit int) ([]*message.Message, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	pending := func() *gorm.DB {
+		return r.db.WithContext(ctx).
+			Where("status = ?", message.StatusPending).
+			Where("expires_at IS NULL OR expires_at > ?", now).
+			Where("next_retry_at IS NULL OR next_retry_at <= ?", now)
+	}
+
+	if r.retryBudget > 0 {
+		return r.getPendingWithBudget(pending, limit)
+	}
+
 	var models []MessageModel
 
-	err := r.db.WithContext(ctx).
-		Where("status = ?", message.StatusPending).
+	query := pending()
+	switch r.retryPriority {
+	case RetryPriorityFirst:
+		query = query.Order("retry_count > 0 DESC")
+	case RetryPriorityLast:
+		query = query.Order("retry_count > 0 ASC")
+	}
+
+	err := query.
 		Order("created_at ASC").
 		Limit(limit).
 		Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
@@ -37,56 +186,690 @@ func (r *Repository) GetPending(ctx context.Context, limit int) ([]*message.Mess
 		return nil, err
 	}
 
+	if r.groupByRecipient {
+		sortByRecipient(models)
+	}
+	return toDomainMany(models), nil
+}
+
+// sortByRecipient orders a claimed batch by recipient, keeping each
+// recipient's messages in the order they were fetched.
+func sortByRecipient(models []MessageModel) {
+	slices.SortStableFunc(models, func(a, b MessageModel) int {
+		return strings.Compare(a.To, b.To)
+	})
+}
+
+// retryBudgetCap is how many of limit rows retried messages may take under
+// budget: the floor of the share, but at least one so retries keep moving.
+func retryBudgetCap(limit int, budget float64) int {
+	return min(max(int(float64(limit)*budget), 1), limit)
+}
+
+// getPendingWithBudget claims retried messages up to the budget, fills the
+// rest of the batch with fresh ones and, if those run out, tops it up with
+// further retries. The groups are then ordered as GetPending would.
+func (r *Repository) getPendingWithBudget(pending func() *gorm.DB, limit int) ([]*message.Message, error) {
+	if limit <= 0 {
+		return nil, nil
+	}
+
+	lock := clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}
+	claim := func(retried bool, n int, exclude []MessageModel) ([]MessageModel, error) {
+		var models []MessageModel
+		if n <= 0 {
+			return nil, nil
+		}
+		query := pending()
+		if retried {
+			query = query.Where("retry_count > 0")
+		} else {
+			query = query.Where("retry_count = 0")
+		}
+		if len(exclude) > 0 {
+			ids := make([]uuid.UUID, len(exclude))
+			for i, m := range exclude {
+				ids[i] = m.ID
+			}
+			// Rows this transaction already locked are not skipped.
+			query = query.Where("id NOT IN ?", ids)
+		}
+		err := query.Order("created_at ASC").Limit(n).Clauses(lock).Find(&models).Error
+		return models, err
+	}
+
+	budget := retryBudgetCap(limit, r.retryBudget)
+	retried, err := claim(true, budget, nil)
+	if err != nil {
+		return nil, err
+	}
+	fresh, err := claim(false, limit-len(retried), nil)
+	if err != nil {
+		return nil, err
+	}
+	if spare := limit - len(retried) - len(fresh); spare > 0 && len(retried) == budget {
+		more, err := claim(true, spare, retried)
+		if err != nil {
+			return nil, err
+		}
+		retried = append(retried, more...)
+	}
+
+	var models []MessageModel
+	switch r.retryPriority {
+	case RetryPriorityFirst:
+		models = append(retried, fresh...)
+	case RetryPriorityLast:
+		models = append(fresh, retried...)
+	default:
+		models = append(fresh, retried...)
+		slices.SortStableFunc(models, func(a, b MessageModel) int {
+			return a.CreatedAt.Compare(b.CreatedAt)
+		})
+	}
+
+	if r.groupByRecipient {
+		sortByRecipient(models)
+	}
 	return toDomainMany(models), nil
 }
 
 // GetSent returns a paginated list of successfully sent messages and the total count.
+// It is served from the read connection. page and limit are clamped to sane bounds
+// (limit at most message.MaxPageLimit) regardless of what the caller passes.
+// Errors name the phase that failed, "count sent messages" or "list sent
+// messages", and wrap the context error if the context ended first.
 func (r *Repository) GetSent(ctx context.Context, page, limit int) ([]*message.Message, int64, error) {
+	page, limit = clampPage(page, limit)
+
 	var models []MessageModel
 	var total int64
 
-	query := r.db.WithContext(ctx).
+	query := r.reader.WithContext(ctx).
 		Model(&MessageModel{}).
 		Where("status = ?", message.StatusSuccess)
 
+	// Each phase checks the context first, so a request cancelled before or
+	// between the two queries reports which one it stopped at instead of a
+	// driver error.
+	if err := ctx.Err(); err != nil {
+		return nil, 0, fmt.Errorf("count sent messages: %w", err)
+	}
 	if err := query.Count(&total).Error; err != nil {
-		return nil, 0, err
+		return nil, 0, fmt.Errorf("count sent messages: %w", err)
 	}
 
 	offset := (page - 1) * limit
 
+	if err := ctx.Err(); err != nil {
+		return nil, 0, fmt.Errorf("list sent messages: %w", err)
+	}
 	err := query.
-		Order("sent_at DESC").
+		Order(sentOrder).
 		Limit(limit).
 		Offset(offset).
 		Find(&models).Error
 
 	if err != nil {
-		return nil, 0, err
+		return nil, 0, fmt.Errorf("list sent messages: %w", err)
 	}
 
 	return toDomainMany(models), total, nil
 }
 
+// sentOrder is the order sent messages are listed in: most recently sent
+// first. A SUCCESS row without sent_at (which only older data can have; see
+// RepairMissingSentAt) sorts last, and the id breaks ties so pages never
+// overlap or skip rows.
+const sentOrder = "sent_at DESC NULLS LAST, id DESC"
+
+// RepairMissingSentAt sets sent_at to updated_at, the closest record of
+// when the send was confirmed, on SUCCESS messages that lack it, and returns
+// how many it fixed. updated_at itself is left unchanged.
+func (r *Repository) RepairMissingSentAt(ctx context.Context) (int64, error) {
+	res := r.db.WithContext(ctx).
+		Model(&MessageModel{}).
+		Where("status = ?", message.StatusSuccess).
+		Where("sent_at IS NULL").
+		UpdateColumn("sent_at", gorm.Expr("updated_at"))
+	return res.RowsAffected, res.Error
+}
+
 // UpdateStatus persists the current status and metadata of a message.
 func (r *Repository) UpdateStatus(ctx context.Context, m *message.Message) error {
-	updates := map[string]interface{}{
-		"status":       string(m.Status),
-		"message_id":   m.MessageID,
-		"raw_response": m.RawResponse,
-		"sent_at":      m.SentAt,
-	}
+	err := r.db.WithContext(ctx).
+		Model(&MessageModel{}).
+		Where("id = ?", m.ID).
+		Updates(statusUpdates(m)).Error
+	return r.translateDuplicate(err)
+}
 
-	return r.db.WithContext(ctx).
+// Requeue writes the requeued message with a conditional UPDATE that only
+// matches while the row is still FAILED.
+func (r *Repository) Requeue(ctx context.Context, m *message.Message) error {
+	res := r.db.WithContext(ctx).
 		Model(&MessageModel{}).
 		Where("id = ?", m.ID).
-		Updates(updates).Error
+		Where("status = ?", string(message.StatusFailed)).
+		Updates(statusUpdates(m))
+	if err := r.translateDuplicate(res.Error); err != nil {
+		return err
+	}
+	if res.RowsAffected == 0 {
+		return message.ErrNotRetryable
+	}
+	return nil
+}
+
+// statusUpdates lists the columns UpdateStatus and Requeue write.
+func statusUpdates(m *message.Message) map[string]interface{} {
+	return map[string]interface{}{
+		"status":           string(m.Status),
+		"message_id":       m.MessageID,
+		"raw_response":     m.RawResponse,
+		"provider_code":    m.ProviderCode,
+		"sent_at":          m.SentAt,
+		"status_reason":    m.StatusReason,
+		"retry_count":      m.RetryCount,
+		"next_retry_at":    m.NextRetryAt,
+		"send_duration_ms": durationToMs(m.SendDuration),
+		// Templated messages store the content they were actually sent with.
+		"content": m.Content,
+	}
+}
+
+// ResetStuck moves PROCESSING messages whose claim (updated_at) is at or
+// before the cutoff back to PENDING in a single UPDATE ... RETURNING id.
+func (r *Repository) ResetStuck(ctx context.Context, before time.Time) ([]uuid.UUID, error) {
+	var reset []MessageModel
+
+	err := r.db.WithContext(ctx).
+		Model(&reset).
+		Clauses(clause.Returning{Columns: []clause.Column{{Name: "id"}}}).
+		Where("status = ?", message.StatusProcessing).
+		Where("updated_at <= ?", before).
+		Update("status", string(message.StatusPending)).Error
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]uuid.UUID, len(reset))
+	for i := range reset {
+		ids[i] = reset[i].ID
+	}
+	return ids, nil
+}
+
+// WithTx runs fn with a repository whose reads and writes all go through
+// one transaction on the primary, so row locks taken by GetPending are held
+// until fn returns.
+func (r *Repository) WithTx(ctx context.Context, fn func(tx message.Repository) error) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(r.bound(tx))
+	})
+}
+
+// bound returns a copy of r, keeping its options, whose reads and writes
+// all go through conn.
+func (r *Repository) bound(conn *gorm.DB) *Repository {
+	c := *r
+	c.db, c.reader = conn, conn
+	return &c
+}
+
+// ExpirePending marks every pending message whose expiry is at or before now
+// as EXPIRED in a single UPDATE ... RETURNING id and returns the affected IDs.
+func (r *Repository) ExpirePending(ctx context.Context, now time.Time, reason string) ([]uuid.UUID, error) {
+	var expired []MessageModel
+
+	err := r.db.WithContext(ctx).
+		Model(&expired).
+		Clauses(clause.Returning{Columns: []clause.Column{{Name: "id"}}}).
+		Where("status = ?", message.StatusPending).
+		Where("expires_at IS NOT NULL AND expires_at <= ?", now).
+		Updates(map[string]interface{}{
+			"status":        string(message.StatusExpired),
+			"status_reason": reason,
+		}).Error
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]uuid.UUID, len(expired))
+	for i := range expired {
+		ids[i] = expired[i].ID
+	}
+	return ids, nil
+}
+
+// List returns a page of messages matching f, newest first, and the total
+// number of matches. Tag filters use JSONB containment so they can be
+// served by the GIN index on tags. It is served from the read connection.
+func (r *Repository) List(ctx context.Context, f message.ListFilter, page, limit int) ([]*message.Message, int64, error) {
+	page, limit = clampPage(page, limit)
+
+	query := r.reader.WithContext(ctx).Model(&MessageModel{})
+	if len(f.Tags) > 0 {
+		tags, err := Tags(f.Tags).Value()
+		if err != nil {
+			return nil, 0, err
+		}
+		query = query.Where("tags @> ?::jsonb", tags)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var models []MessageModel
+	err := query.
+		Order("created_at DESC").
+		Limit(limit).
+		Offset((page - 1) * limit).
+		Find(&models).Error
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return toDomainMany(models), total, nil
+}
+
+// GetByProviderCode pages through messages with the given provider code,
+// newest first, using the provider_code index. It is served from the read
+// connection; page and limit are clamped like in GetSent.
+func (r *Repository) GetByProviderCode(ctx context.Context, code string, page, limit int) ([]*message.Message, int64, error) {
+	page, limit = clampPage(page, limit)
+
+	query := r.reader.WithContext(ctx).
+		Model(&MessageModel{}).
+		Where("provider_code = ?", code)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var models []MessageModel
+	err := query.
+		Order("created_at DESC").
+		Limit(limit).
+		Offset((page - 1) * limit).
+		Find(&models).Error
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return toDomainMany(models), total, nil
+}
+
+// StreamByCreatedRange iterates messages created in [from, until) row by
+// row, ordered by created_at so the index on it backs the scan. It is
+// served from the read connection.
+func (r *Repository) StreamByCreatedRange(ctx context.Context, from, until time.Time, fn func(*message.Message) error) error {
+	query := r.reader.WithContext(ctx).
+		Model(&MessageModel{}).
+		Where("created_at >= ? AND created_at < ?", from, until).
+		Order("created_at ASC")
+
+	return streamRows(query, fn)
+}
+
+// StreamSent iterates SUCCESS messages row by row in the order GetSent
+// pages through them (sentOrder). It is served from the read connection.
+func (r *Repository) StreamSent(ctx context.Context, fn func(*message.Message) error) error {
+	query := r.reader.WithContext(ctx).
+		Model(&MessageModel{}).
+		Where("status = ?", message.StatusSuccess).
+		Order(sentOrder)
+
+	return streamRows(query, fn)
+}
+
+// streamRows runs query and calls fn for every resulting message, one row
+// at a time.
+func streamRows(query *gorm.DB, fn func(*message.Message) error) error {
+	rows, err := query.Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var model MessageModel
+		if err := query.ScanRows(rows, &model); err != nil {
+			return err
+		}
+		if err := fn(toDomain(&model)); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// dayStatRow is the scan target of the SentStatsByDay aggregate.
+type dayStatRow struct {
+	Day    time.Time
+	Sent   int64
+	Failed int64
+}
+
+// SentStatsByDay groups finished messages by the UTC day they reached their
+// final status (sent_at for SUCCESS, updated_at for FAILED). It is served
+// from the read connection.
+func (r *Repository) SentStatsByDay(ctx context.Context, from, until time.Time) ([]message.DayStat, error) {
+	var rows []dayStatRow
+
+	err := r.reader.WithContext(ctx).
+		Model(&MessageModel{}).
+		Select(
+			"date_trunc('day', COALESCE(sent_at, updated_at) AT TIME ZONE 'UTC') AS day, "+
+				"COUNT(*) FILTER (WHERE status = ?) AS sent, "+
+				"COUNT(*) FILTER (WHERE status = ?) AS failed",
+			message.StatusSuccess, message.StatusFailed,
+		).
+		Where("status IN ?", []message.Status{message.StatusSuccess, message.StatusFailed}).
+		Where("COALESCE(sent_at, updated_at) >= ? AND COALESCE(sent_at, updated_at) < ?", from, until).
+		Group("day").
+		Order("day ASC").
+		Find(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]message.DayStat, len(rows))
+	for i, row := range rows {
+		out[i] = message.DayStat{
+			Day:    time.Date(row.Day.Year(), row.Day.Month(), row.Day.Day(), 0, 0, 0, 0, time.UTC),
+			Sent:   row.Sent,
+			Failed: row.Failed,
+		}
+	}
+	return out, nil
+}
+
+// latencyRow is the scan target of the SendLatencyStats aggregate. Both
+// columns are NULL when no row matches.
+type latencyRow struct {
+	AvgMs *float64
+	P95Ms *float64
+}
+
+// SendLatencyStats computes the average and continuous 95th percentile of
+// send_duration_ms over messages that reached SUCCESS in [from, until).
+func (r *Repository) SendLatencyStats(ctx context.Context, from, until time.Time) (time.Duration, time.Duration, error) {
+	var row latencyRow
+
+	err := r.reader.WithContext(ctx).
+		Model(&MessageModel{}).
+		Select(
+			"AVG(send_duration_ms) AS avg_ms, "+
+				"percentile_cont(0.95) WITHIN GROUP (ORDER BY send_duration_ms) AS p95_ms",
+		).
+		Where("status = ?", message.StatusSuccess).
+		Where("send_duration_ms IS NOT NULL").
+		Where("sent_at >= ? AND sent_at < ?", from, until).
+		Find(&row).Error
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return msToDuration(row.AvgMs), msToDuration(row.P95Ms), nil
+}
+
+// msToDuration converts a nullable fractional millisecond aggregate to a
+// duration, treating NULL as zero.
+func msToDuration(ms *float64) time.Duration {
+	if ms == nil {
+		return 0
+	}
+	return time.Duration(math.Round(*ms * float64(time.Millisecond)))
+}
+
+// TopRecipients counts messages created in [from, until) per recipient,
+// regardless of status, and returns the limit largest counts. Ties are
+// ordered by recipient. It is served from the read connection.
+func (r *Repository) TopRecipients(ctx context.Context, from, until time.Time, limit int) ([]message.RecipientCount, error) {
+	var rows []message.RecipientCount
+
+	err := r.reader.WithContext(ctx).
+		Model(&MessageModel{}).
+		Select(`"to", COUNT(*) AS count`).
+		Where("created_at >= ? AND created_at < ?", from, until).
+		Group(`"to"`).
+		Order(`count DESC, "to" ASC`).
+		Limit(limit).
+		Find(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// oldestPendingRow is the scan target of the OldestPendingAge aggregate.
+// Oldest is NULL when nothing is pending.
+type oldestPendingRow struct {
+	Oldest *time.Time
+}
+
+// OldestPendingAge returns the age of the oldest PENDING message, measured
+// from its created_at, or zero when there is none.
+func (r *Repository) OldestPendingAge(ctx context.Context) (time.Duration, error) {
+	var row oldestPendingRow
+
+	err := r.reader.WithContext(ctx).
+		Model(&MessageModel{}).
+		Select("MIN(created_at) AS oldest").
+		Where("status = ?", message.StatusPending).
+		Find(&row).Error
+	if err != nil {
+		return 0, err
+	}
+
+	if row.Oldest == nil {
+		return 0, nil
+	}
+	return max(time.Since(*row.Oldest), 0), nil
+}
+
+// GetByID returns a single message by its ID, or message.ErrNotFound.
+func (r *Repository) GetByID(ctx context.Context, id uuid.UUID) (*message.Message, error) {
+	var model MessageModel
+
+	err := r.reader.WithContext(ctx).
+		Where("id = ?", id).
+		Take(&model).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, message.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return toDomain(&model), nil
+}
+
+// GetStatus returns a message's current status from the primary, or
+// message.ErrNotFound.
+func (r *Repository) GetStatus(ctx context.Context, id uuid.UUID) (message.Status, error) {
+	var model MessageModel
+
+	err := r.db.WithContext(ctx).
+		Select("status").
+		Where("id = ?", id).
+		Take(&model).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", message.ErrNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return message.Status(model.Status), nil
+}
+
+// SoftDelete sets deleted_at on a message so GORM excludes it from every
+// subsequent query. It returns message.ErrNotFound if no live message has id.
+func (r *Repository) SoftDelete(ctx context.Context, id uuid.UUID) error {
+	res := r.db.WithContext(ctx).
+		Where("id = ?", id).
+		Delete(&MessageModel{})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return message.ErrNotFound
+	}
+	return nil
+}
+
+// AddStatusEvents inserts status transitions into the audit timeline.
+func (r *Repository) AddStatusEvents(ctx context.Context, events ...*message.StatusEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	models := make([]*StatusEventModel, len(events))
+	for i, e := range events {
+		models[i] = statusEventFromDomain(e)
+	}
+
+	return r.db.WithContext(ctx).Create(&models).Error
+}
+
+// GetTimeline returns the status transitions of a message ordered by time.
+func (r *Repository) GetTimeline(ctx context.Context, messageID uuid.UUID) ([]*message.StatusEvent, error) {
+	var models []StatusEventModel
+
+	err := r.reader.WithContext(ctx).
+		Where("message_id = ?", messageID).
+		Order("at ASC").
+		Find(&models).Error
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]*message.StatusEvent, len(models))
+	for i := range models {
+		out[i] = statusEventToDomain(&models[i])
+	}
+	return out, nil
+}
+
+// RecordBatchRun inserts the outcome of a batch run.
+func (r *Repository) RecordBatchRun(ctx context.Context, result message.BatchResult) error {
+	return r.db.WithContext(ctx).Create(batchRunFromDomain(result)).Error
+}
+
+// ListBatchRuns returns a page of recorded batch runs, most recent first,
+// and the total count. It is served from the read connection.
+func (r *Repository) ListBatchRuns(ctx context.Context, page, limit int) ([]*message.BatchRun, int64, error) {
+	page, limit = clampPage(page, limit)
+
+	query := r.reader.WithContext(ctx).Model(&BatchRunModel{})
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var models []BatchRunModel
+	err := query.
+		Order("started_at DESC").
+		Limit(limit).
+		Offset((page - 1) * limit).
+		Find(&models).Error
+	if err != nil {
+		return nil, 0, err
+	}
+
+	out := make([]*message.BatchRun, len(models))
+	for i := range models {
+		out[i] = batchRunToDomain(&models[i])
+	}
+	return out, total, nil
 }
 
 // Save inserts a new message record into the database.
 func (r *Repository) Save(ctx context.Context, msg *message.Message) error {
 	dbModel := fromDomain(msg)
-	return r.db.WithContext(ctx).Create(dbModel).Error
+	if !r.dedupContent {
+		dbModel.ContentHash = nil
+	}
+	return r.translateDuplicate(r.db.WithContext(ctx).Create(dbModel).Error)
+}
+
+// ClearContentDedup nulls the content hashes of the recipient's unsent
+// messages, taking them out of the partial unique index. The messages
+// themselves stay queued.
+func (r *Repository) ClearContentDedup(ctx context.Context, to string) (int64, error) {
+	res := r.db.WithContext(ctx).
+		Model(&MessageModel{}).
+		Where(`"to" = ?`, to).
+		Where("content_hash IS NOT NULL").
+		Where("status IN ?", []string{string(message.StatusPending), string(message.StatusProcessing)}).
+		Update("content_hash", gorm.Expr("NULL"))
+	return res.RowsAffected, res.Error
+}
+
+// FindRecentDuplicate returns the newest PENDING, PROCESSING or SUCCESS
+// message to the recipient with the same content created at or after
+// since. The (to, created_at) index narrows the lookup to the recipient's
+// recent messages before content is compared.
+func (r *Repository) FindRecentDuplicate(ctx context.Context, to, content string, since time.Time) (*message.Message, error) {
+	var m MessageModel
+	err := r.db.WithContext(ctx).
+		Where(`"to" = ? AND created_at >= ?`, to, since).
+		Where("content = ?", content).
+		Where("status IN ?", []string{
+			string(message.StatusPending),
+			string(message.StatusProcessing),
+			string(message.StatusSuccess),
+		}).
+		Order("created_at DESC").
+		First(&m).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, message.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return toDomain(&m), nil
+}
+
+// uniqueViolation is the Postgres SQLSTATE for unique constraint violations.
+const uniqueViolation = "23505"
+
+// translateDuplicate maps a violation of the unsent content hash index to
+// message.ErrDuplicateMessage and returns other errors unchanged.
+func (r *Repository) translateDuplicate(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation &&
+		pgErr.ConstraintName == contentHashIndex(r.db.NamingStrategy) {
+		return message.ErrDuplicateMessage
+	}
+	return err
+}
+
+// clampPage normalizes pagination input: page is at least 1 and limit is
+// between 1 and message.MaxPageLimit.
+func clampPage(page, limit int) (int, int) {
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 {
+		limit = 1
+	}
+	if limit > message.MaxPageLimit {
+		limit = message.MaxPageLimit
+	}
+	return page, limit
+}
+
+// Ping runs SELECT 1 on the primary connection, which is the one sends
+// and writes depend on.
+func (r *Repository) Ping(ctx context.Context) error {
+	return r.db.WithContext(ctx).Exec("SELECT 1").Error
 }
 
 // compile-time interface check
@@ -0,0 +1,679 @@
+//go:build integration
+
+package messagegorm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/oggyb/insider-assessment/internal/domain/message"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// openIntegrationDB connects to the Postgres named by TEST_DATABASE_DSN and
+// returns a transaction that is rolled back when the test ends, so tests
+// leave no rows or schema changes behind. Tests are skipped without a DSN.
+//
+//	TEST_DATABASE_DSN="host=localhost user=root password=123456 dbname=db_ins_message sslmode=disable" \
+//	  go test -tags integration ./internal/repository/gorm/message/
+func openIntegrationDB(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	dsn := os.Getenv("TEST_DATABASE_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_DSN not set")
+	}
+
+	conn, err := gorm.Open(postgres.Open(dsn), &gorm.Config{SkipDefaultTransaction: true})
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+
+	tx := conn.Begin()
+	if tx.Error != nil {
+		t.Fatalf("failed to begin: %v", tx.Error)
+	}
+	t.Cleanup(func() { tx.Rollback() })
+
+	if err := tx.AutoMigrate(&MessageModel{}); err != nil {
+		t.Fatalf("AutoMigrate: %v", err)
+	}
+	return tx
+}
+
+func TestRepository_PingIntegration(t *testing.T) {
+	tx := openIntegrationDB(t)
+	if err := NewRepository(fakeDB{conn: tx}).Ping(context.Background()); err != nil {
+		t.Fatalf("Ping: %v", err)
+	}
+}
+
+func TestRepository_OptOutTokenIntegration(t *testing.T) {
+	tx := openIntegrationDB(t)
+	if err := tx.AutoMigrate(&OptOutTokenModel{}); err != nil {
+		t.Fatalf("AutoMigrate: %v", err)
+	}
+	repo := NewRepository(fakeDB{conn: tx})
+	ctx := context.Background()
+
+	to := "+90500" + uuid.NewString()[:7]
+	first, err := repo.OptOutTokenFor(ctx, to)
+	if err != nil {
+		t.Fatalf("OptOutTokenFor: %v", err)
+	}
+	again, err := repo.OptOutTokenFor(ctx, to)
+	if err != nil || again.Token != first.Token {
+		t.Fatalf("expected the stored token to be reused, got %+v, %v", again, err)
+	}
+
+	if opted, err := repo.IsOptedOut(ctx, to); err != nil || opted {
+		t.Fatalf("IsOptedOut before resolving = %v, %v", opted, err)
+	}
+
+	at := time.Now().Add(-time.Minute).UTC().Truncate(time.Microsecond)
+	resolved, err := repo.ResolveOptOutToken(ctx, first.Token, at)
+	if err != nil || resolved.To != to || resolved.OptedOutAt == nil || !resolved.OptedOutAt.Equal(at) {
+		t.Fatalf("ResolveOptOutToken = %+v, %v", resolved, err)
+	}
+	resolved, err = repo.ResolveOptOutToken(ctx, first.Token, time.Now())
+	if err != nil || !resolved.OptedOutAt.Equal(at) {
+		t.Fatalf("expected the first opt-out time to be kept, got %+v, %v", resolved, err)
+	}
+	if opted, err := repo.IsOptedOut(ctx, to); err != nil || !opted {
+		t.Fatalf("IsOptedOut after resolving = %v, %v", opted, err)
+	}
+
+	if _, err := repo.ResolveOptOutToken(ctx, "missing", at); !errors.Is(err, message.ErrOptOutTokenNotFound) {
+		t.Fatalf("expected ErrOptOutTokenNotFound, got %v", err)
+	}
+}
+
+func TestRepository_SendLatencyStatsIntegration(t *testing.T) {
+	tx := openIntegrationDB(t)
+	repo := NewRepository(fakeDB{conn: tx})
+	ctx := context.Background()
+
+	// A window far in the past keeps existing rows out of the aggregate.
+	from := time.Date(2001, time.January, 1, 0, 0, 0, 0, time.UTC)
+	until := from.Add(time.Hour)
+
+	save := func(status message.Status, sentAt time.Time, d time.Duration) {
+		t.Helper()
+		m, err := message.NewMessage("+905000000000", "latency")
+		if err != nil {
+			t.Fatalf("NewMessage: %v", err)
+		}
+		m.Status = status
+		m.SentAt = &sentAt
+		m.SendDuration = &d
+		if err := repo.Save(ctx, m); err != nil {
+			t.Fatalf("Save: %v", err)
+		}
+	}
+
+	// 1ms..100ms: avg 50.5ms, percentile_cont(0.95) = 95.05ms.
+	for i := 1; i <= 100; i++ {
+		save(message.StatusSuccess, from.Add(time.Duration(i)*time.Second), time.Duration(i)*time.Millisecond)
+	}
+	// Neither a failure nor a send outside the window may skew the result.
+	save(message.StatusFailed, from.Add(time.Minute), 10*time.Second)
+	save(message.StatusSuccess, until, 10*time.Second)
+
+	avg, p95, err := repo.SendLatencyStats(ctx, from, until)
+	if err != nil {
+		t.Fatalf("SendLatencyStats: %v", err)
+	}
+	if avg != 50500*time.Microsecond {
+		t.Fatalf("expected avg 50.5ms, got %v", avg)
+	}
+	if p95 != 95050*time.Microsecond {
+		t.Fatalf("expected p95 95.05ms, got %v", p95)
+	}
+
+	avg, p95, err = repo.SendLatencyStats(ctx, until.Add(time.Hour), until.Add(2*time.Hour))
+	if err != nil {
+		t.Fatalf("SendLatencyStats (empty window): %v", err)
+	}
+	if avg != 0 || p95 != 0 {
+		t.Fatalf("expected zero stats for an empty window, got avg=%v p95=%v", avg, p95)
+	}
+}
+
+func TestRepository_SaveRejectsDuplicateUnsentIntegration(t *testing.T) {
+	tx := openIntegrationDB(t)
+	repo := NewRepository(fakeDB{conn: tx}, WithContentDedup(true))
+	ctx := context.Background()
+
+	newMsg := func() *message.Message {
+		m, err := message.NewMessage("+905000000000", "dedup integration "+t.Name())
+		if err != nil {
+			t.Fatalf("NewMessage: %v", err)
+		}
+		return m
+	}
+
+	first := newMsg()
+	if err := repo.Save(ctx, first); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	// The violation aborts the surrounding transaction; roll back to a
+	// savepoint so the test can carry on.
+	tx.SavePoint("duplicate")
+	if err := repo.Save(ctx, newMsg()); !errors.Is(err, message.ErrDuplicateMessage) {
+		t.Fatalf("expected ErrDuplicateMessage for a second pending copy, got %v", err)
+	}
+	tx.RollbackTo("duplicate")
+
+	// Once the first copy is sent, the same content may be enqueued again.
+	first.MarkSent("ext", "{}")
+	if err := repo.UpdateStatus(ctx, first); err != nil {
+		t.Fatalf("UpdateStatus: %v", err)
+	}
+	if err := repo.Save(ctx, newMsg()); err != nil {
+		t.Fatalf("expected a new copy to be accepted after the first was sent, got %v", err)
+	}
+}
+
+func TestRepository_OldestPendingAgeIntegration(t *testing.T) {
+	tx := openIntegrationDB(t)
+	repo := NewRepository(fakeDB{conn: tx})
+	ctx := context.Background()
+
+	// Other rows in the database may already be pending; clear the way.
+	if err := tx.Where("status = ?", message.StatusPending).Delete(&MessageModel{}).Error; err != nil {
+		t.Fatalf("clear pending: %v", err)
+	}
+
+	age, err := repo.OldestPendingAge(ctx)
+	if err != nil {
+		t.Fatalf("OldestPendingAge: %v", err)
+	}
+	if age != 0 {
+		t.Fatalf("expected zero age with nothing pending, got %v", age)
+	}
+
+	save := func(status message.Status, age time.Duration) {
+		t.Helper()
+		m, err := message.NewMessage("+905000000000", "pending age "+age.String())
+		if err != nil {
+			t.Fatalf("NewMessage: %v", err)
+		}
+		m.Status = status
+		m.CreatedAt = time.Now().Add(-age)
+		if err := repo.Save(ctx, m); err != nil {
+			t.Fatalf("Save: %v", err)
+		}
+	}
+	save(message.StatusPending, 10*time.Minute)
+	save(message.StatusPending, 2*time.Hour)
+	save(message.StatusSuccess, 48*time.Hour)
+
+	age, err = repo.OldestPendingAge(ctx)
+	if err != nil {
+		t.Fatalf("OldestPendingAge: %v", err)
+	}
+	if age < 2*time.Hour || age > 2*time.Hour+time.Minute {
+		t.Fatalf("expected an age of about 2h, got %v", age)
+	}
+}
+
+func TestRepository_GetPendingWaitsForRetryBackoffIntegration(t *testing.T) {
+	tx := openIntegrationDB(t)
+	repo := NewRepository(fakeDB{conn: tx})
+	ctx := context.Background()
+
+	// Other rows in the database may already be pending; clear the way.
+	if err := tx.Where("status = ?", message.StatusPending).Delete(&MessageModel{}).Error; err != nil {
+		t.Fatalf("clear pending: %v", err)
+	}
+
+	m, err := message.NewMessage("+905000000000", "retry backoff")
+	if err != nil {
+		t.Fatalf("NewMessage: %v", err)
+	}
+	if err := repo.Save(ctx, m); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	// A just-failed message waits out its backoff...
+	m.ScheduleRetry("{}", "unavailable", time.Now(), time.Hour)
+	if err := repo.UpdateStatus(ctx, m); err != nil {
+		t.Fatalf("UpdateStatus: %v", err)
+	}
+	pending, err := repo.GetPending(ctx, 10)
+	if err != nil {
+		t.Fatalf("GetPending: %v", err)
+	}
+	if len(pending) != 0 {
+		t.Fatalf("expected the message to be held back during its backoff, got %d", len(pending))
+	}
+
+	// ...and is fetched again once it has elapsed.
+	elapsed := time.Now().Add(-time.Second)
+	m.NextRetryAt = &elapsed
+	if err := repo.UpdateStatus(ctx, m); err != nil {
+		t.Fatalf("UpdateStatus: %v", err)
+	}
+	pending, err = repo.GetPending(ctx, 10)
+	if err != nil {
+		t.Fatalf("GetPending: %v", err)
+	}
+	if len(pending) != 1 || pending[0].ID != m.ID || pending[0].RetryCount != 1 {
+		t.Fatalf("expected the retried message after its backoff, got %+v", pending)
+	}
+}
+
+func TestRepository_RequeueOnlyFailedIntegration(t *testing.T) {
+	tx := openIntegrationDB(t)
+	repo := NewRepository(fakeDB{conn: tx})
+	ctx := context.Background()
+
+	m, err := message.NewMessage("+905000000000", "requeue once")
+	if err != nil {
+		t.Fatalf("NewMessage: %v", err)
+	}
+	m.MarkFailed("{}")
+	if err := repo.Save(ctx, m); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	// Two retries that both read FAILED: only the first requeues.
+	first, second := *m, *m
+	if err := first.Requeue(); err != nil {
+		t.Fatalf("Requeue: %v", err)
+	}
+	if err := repo.Requeue(ctx, &first); err != nil {
+		t.Fatalf("first Requeue: %v", err)
+	}
+	if err := second.Requeue(); err != nil {
+		t.Fatalf("Requeue: %v", err)
+	}
+	if err := repo.Requeue(ctx, &second); !errors.Is(err, message.ErrNotRetryable) {
+		t.Fatalf("expected ErrNotRetryable for the second retry, got %v", err)
+	}
+
+	status, err := repo.GetStatus(ctx, m.ID)
+	if err != nil || status != message.StatusPending {
+		t.Fatalf("expected PENDING, got %s (%v)", status, err)
+	}
+}
+
+func TestRepository_GetPendingRetryPriorityIntegration(t *testing.T) {
+	tx := openIntegrationDB(t)
+	ctx := context.Background()
+
+	if err := tx.Where("status = ?", message.StatusPending).Delete(&MessageModel{}).Error; err != nil {
+		t.Fatalf("clear pending: %v", err)
+	}
+
+	// The retried message is the newest, so plain creation order would put
+	// it last.
+	base := time.Now().Add(-time.Hour)
+	var ids []uuid.UUID
+	for i, retries := range []int{0, 0, 2} {
+		m, err := message.NewMessage("+905000000000", fmt.Sprintf("priority %d", i))
+		if err != nil {
+			t.Fatalf("NewMessage: %v", err)
+		}
+		m.CreatedAt = base.Add(time.Duration(i) * time.Minute)
+		m.RetryCount = retries
+		if err := NewRepository(fakeDB{conn: tx}).Save(ctx, m); err != nil {
+			t.Fatalf("Save: %v", err)
+		}
+		ids = append(ids, m.ID)
+	}
+
+	cases := []struct {
+		priority RetryPriority
+		want     []uuid.UUID
+	}{
+		{RetryPriorityFirst, []uuid.UUID{ids[2], ids[0], ids[1]}},
+		{RetryPriorityLast, []uuid.UUID{ids[0], ids[1], ids[2]}},
+	}
+	for _, tc := range cases {
+		pending, err := NewRepository(fakeDB{conn: tx}, WithRetryPriority(tc.priority)).GetPending(ctx, 10)
+		if err != nil {
+			t.Fatalf("GetPending: %v", err)
+		}
+		if len(pending) != len(tc.want) {
+			t.Fatalf("priority %q: expected %d messages, got %d", tc.priority, len(tc.want), len(pending))
+		}
+		for i, m := range pending {
+			if m.ID != tc.want[i] {
+				t.Fatalf("priority %q: position %d is %s, want %s", tc.priority, i, m.ID, tc.want[i])
+			}
+		}
+	}
+}
+
+func TestRepository_GetPendingRetryBudgetIntegration(t *testing.T) {
+	tx := openIntegrationDB(t)
+	ctx := context.Background()
+
+	if err := tx.Where("status = ?", message.StatusPending).Delete(&MessageModel{}).Error; err != nil {
+		t.Fatalf("clear pending: %v", err)
+	}
+
+	// A backlog of older retries ahead of fresh messages: creation order
+	// alone would fill the batch with retries.
+	seed := func(prefix string, n, retries int, base time.Time) {
+		for i := range n {
+			m, err := message.NewMessage("+905000000000", fmt.Sprintf("%s %d", prefix, i))
+			if err != nil {
+				t.Fatalf("NewMessage: %v", err)
+			}
+			m.CreatedAt = base.Add(time.Duration(i) * time.Second)
+			m.RetryCount = retries
+			if err := NewRepository(fakeDB{conn: tx}).Save(ctx, m); err != nil {
+				t.Fatalf("Save: %v", err)
+			}
+		}
+	}
+	base := time.Now().Add(-time.Hour)
+	seed("retry", 20, 1, base)
+	seed("fresh", 20, 0, base.Add(time.Minute))
+
+	countRetried := func(msgs []*message.Message) int {
+		n := 0
+		for _, m := range msgs {
+			if m.RetryCount > 0 {
+				n++
+			}
+		}
+		return n
+	}
+
+	repo := NewRepository(fakeDB{conn: tx}, WithRetryBudget(0.3))
+	pending, err := repo.GetPending(ctx, 10)
+	if err != nil {
+		t.Fatalf("GetPending: %v", err)
+	}
+	if len(pending) != 10 || countRetried(pending) != 3 {
+		t.Fatalf("expected 10 messages with 3 retries, got %d with %d", len(pending), countRetried(pending))
+	}
+	for i := 1; i < len(pending); i++ {
+		if pending[i].CreatedAt.Before(pending[i-1].CreatedAt) {
+			t.Fatalf("expected creation order, position %d is older than %d", i, i-1)
+		}
+	}
+
+	// With fewer fresh messages than their share, retries use the spare
+	// capacity instead of leaving the batch short.
+	if err := tx.Where("status = ? AND retry_count = 0", message.StatusPending).Delete(&MessageModel{}).Error; err != nil {
+		t.Fatalf("clear fresh: %v", err)
+	}
+	seed("late", 2, 0, base.Add(2*time.Minute))
+
+	pending, err = repo.GetPending(ctx, 10)
+	if err != nil {
+		t.Fatalf("GetPending: %v", err)
+	}
+	seen := map[uuid.UUID]bool{}
+	for _, m := range pending {
+		if seen[m.ID] {
+			t.Fatalf("message %s returned twice", m.ID)
+		}
+		seen[m.ID] = true
+	}
+	if len(pending) != 10 || countRetried(pending) != 8 {
+		t.Fatalf("expected 10 messages with 8 retries, got %d with %d", len(pending), countRetried(pending))
+	}
+}
+
+func TestRepository_GetByProviderCodeIntegration(t *testing.T) {
+	tx := openIntegrationDB(t)
+	repo := NewRepository(fakeDB{conn: tx})
+	ctx := context.Background()
+
+	code := "TEST_" + uuid.NewString()[:8]
+	var failed *message.Message
+	for i, raw := range []string{`{"code":"` + code + `"}`, `{"code":"OTHER"}`} {
+		m, err := message.NewMessage("+905000000000", fmt.Sprintf("provider code %d", i))
+		if err != nil {
+			t.Fatalf("NewMessage: %v", err)
+		}
+		if err := repo.Save(ctx, m); err != nil {
+			t.Fatalf("Save: %v", err)
+		}
+		m.MarkFailed(raw)
+		if err := repo.UpdateStatus(ctx, m); err != nil {
+			t.Fatalf("UpdateStatus: %v", err)
+		}
+		if i == 0 {
+			failed = m
+		}
+	}
+
+	got, total, err := repo.GetByProviderCode(ctx, code, 1, 10)
+	if err != nil {
+		t.Fatalf("GetByProviderCode: %v", err)
+	}
+	if total != 1 || len(got) != 1 || got[0].ID != failed.ID || got[0].ProviderCode != code {
+		t.Fatalf("expected only the message with code %s, got total=%d %+v", code, total, got)
+	}
+}
+
+func TestRepository_FindRecentDuplicateIntegration(t *testing.T) {
+	tx := openIntegrationDB(t)
+	repo := NewRepository(fakeDB{conn: tx})
+	ctx := context.Background()
+
+	to, content := "+905000000000", "dedup window "+t.Name()
+	sent, err := message.NewMessage(to, content)
+	if err != nil {
+		t.Fatalf("NewMessage: %v", err)
+	}
+	if err := repo.Save(ctx, sent); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	sent.MarkSent("ext", "{}")
+	if err := repo.UpdateStatus(ctx, sent); err != nil {
+		t.Fatalf("UpdateStatus: %v", err)
+	}
+
+	got, err := repo.FindRecentDuplicate(ctx, to, content, sent.CreatedAt.Add(-time.Minute))
+	if err != nil || got.ID != sent.ID {
+		t.Fatalf("expected the sent copy within the window, got %v, %v", got, err)
+	}
+	if _, err := repo.FindRecentDuplicate(ctx, to, content, sent.CreatedAt.Add(time.Minute)); !errors.Is(err, message.ErrNotFound) {
+		t.Fatalf("expected nothing once the copy is older than the window, got %v", err)
+	}
+	if _, err := repo.FindRecentDuplicate(ctx, to, content+" (edited)", sent.CreatedAt.Add(-time.Minute)); !errors.Is(err, message.ErrNotFound) {
+		t.Fatalf("expected other content not to match, got %v", err)
+	}
+}
+
+func TestRepository_LinkClicksIntegration(t *testing.T) {
+	tx := openIntegrationDB(t)
+	if err := tx.AutoMigrate(&LinkModel{}); err != nil {
+		t.Fatalf("AutoMigrate: %v", err)
+	}
+	repo := NewRepository(fakeDB{conn: tx})
+	ctx := context.Background()
+
+	link, err := message.NewLink("https://example.com/"+t.Name(), uuid.New())
+	if err != nil {
+		t.Fatalf("NewLink: %v", err)
+	}
+	if err := repo.SaveLink(ctx, link); err != nil {
+		t.Fatalf("SaveLink: %v", err)
+	}
+
+	first := time.Now().UTC().Truncate(time.Microsecond)
+	if _, err := repo.RecordLinkClick(ctx, link.Token, first); err != nil {
+		t.Fatalf("RecordLinkClick: %v", err)
+	}
+	got, err := repo.RecordLinkClick(ctx, link.Token, first.Add(time.Minute))
+	if err != nil {
+		t.Fatalf("RecordLinkClick: %v", err)
+	}
+	if got.URL != link.URL || got.Clicks != 2 || !got.FirstClickedAt.Equal(first) || !got.LastClickedAt.Equal(first.Add(time.Minute)) {
+		t.Fatalf("expected two clicks keeping the first click time, got %+v", got)
+	}
+
+	if _, err := repo.RecordLinkClick(ctx, "unknown", first); !errors.Is(err, message.ErrLinkNotFound) {
+		t.Fatalf("expected ErrLinkNotFound, got %v", err)
+	}
+}
+
+func TestRepository_ClearContentDedupAllowsResendIntegration(t *testing.T) {
+	tx := openIntegrationDB(t)
+	repo := NewRepository(fakeDB{conn: tx}, WithContentDedup(true))
+	ctx := context.Background()
+
+	to := "+905000000000"
+	newMsg := func() *message.Message {
+		m, err := message.NewMessage(to, "dedup clear "+t.Name())
+		if err != nil {
+			t.Fatalf("NewMessage: %v", err)
+		}
+		return m
+	}
+
+	if err := repo.Save(ctx, newMsg()); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	tx.SavePoint("duplicate")
+	if err := repo.Save(ctx, newMsg()); !errors.Is(err, message.ErrDuplicateMessage) {
+		t.Fatalf("expected ErrDuplicateMessage before clearing, got %v", err)
+	}
+	tx.RollbackTo("duplicate")
+
+	cleared, err := repo.ClearContentDedup(ctx, to)
+	if err != nil {
+		t.Fatalf("ClearContentDedup: %v", err)
+	}
+	if cleared < 1 {
+		t.Fatalf("expected the pending copy to be released, got %d", cleared)
+	}
+	if err := repo.Save(ctx, newMsg()); err != nil {
+		t.Fatalf("expected the duplicate to be accepted after clearing, got %v", err)
+	}
+
+	// An unknown recipient has nothing to clear.
+	if cleared, err := repo.ClearContentDedup(ctx, "+900000000000"); err != nil || cleared != 0 {
+		t.Fatalf("expected nothing to clear for an unknown recipient, got %d, %v", cleared, err)
+	}
+}
+
+func TestRepository_TopRecipientsIntegration(t *testing.T) {
+	tx := openIntegrationDB(t)
+	repo := NewRepository(fakeDB{conn: tx})
+	ctx := context.Background()
+
+	// A window far in the past keeps existing rows out of the ranking.
+	from := time.Date(2001, time.January, 1, 0, 0, 0, 0, time.UTC)
+	until := from.Add(time.Hour)
+
+	save := func(to string, n int, created time.Time, status message.Status) {
+		t.Helper()
+		for range n {
+			m, err := message.NewMessage(to, "top")
+			if err != nil {
+				t.Fatalf("NewMessage: %v", err)
+			}
+			m.CreatedAt = created
+			m.Status = status
+			if err := repo.Save(ctx, m); err != nil {
+				t.Fatalf("Save: %v", err)
+			}
+		}
+	}
+	save("+905000000001", 4, from.Add(time.Minute), message.StatusSuccess)
+	save("+905000000002", 2, from.Add(time.Minute), message.StatusFailed)
+	save("+905000000002", 1, from.Add(2*time.Minute), message.StatusPending)
+	save("+905000000003", 1, from.Add(time.Minute), message.StatusSuccess)
+	save("+905000000004", 6, until, message.StatusSuccess)
+
+	top, err := repo.TopRecipients(ctx, from, until, 2)
+	if err != nil {
+		t.Fatalf("TopRecipients: %v", err)
+	}
+	want := []message.RecipientCount{
+		{To: "+905000000001", Count: 4},
+		{To: "+905000000002", Count: 3},
+	}
+	if !slices.Equal(top, want) {
+		t.Fatalf("top recipients = %v, want %v", top, want)
+	}
+}
+
+func TestRepository_GetSentMissingSentAtIntegration(t *testing.T) {
+	tx := openIntegrationDB(t)
+	repo := NewRepository(fakeDB{conn: tx})
+	ctx := context.Background()
+
+	// Far-future send times put these rows first, ahead of existing data.
+	base := time.Date(2999, time.January, 1, 0, 0, 0, 0, time.UTC)
+	var dated []uuid.UUID
+	for i := range 5 {
+		m, err := message.NewMessage("+905000000000", "sent")
+		if err != nil {
+			t.Fatalf("NewMessage: %v", err)
+		}
+		m.Status = message.StatusSuccess
+		sentAt := base.Add(-time.Duration(i) * time.Minute)
+		m.SentAt = &sentAt
+		if err := repo.Save(ctx, m); err != nil {
+			t.Fatalf("Save: %v", err)
+		}
+		dated = append(dated, m.ID)
+	}
+	broken, _ := message.NewMessage("+905000000000", "sent without sent_at")
+	broken.Status = message.StatusSuccess
+	if err := repo.Save(ctx, broken); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	// Page through everything: no row may repeat or go missing, and the
+	// row without sent_at comes after every dated one.
+	seen := map[uuid.UUID]int{}
+	var total int64
+	for page, pos := 1, 0; ; page++ {
+		items, n, err := repo.GetSent(ctx, page, 2)
+		if err != nil {
+			t.Fatalf("GetSent page %d: %v", page, err)
+		}
+		total = n
+		if len(items) == 0 {
+			break
+		}
+		for _, m := range items {
+			if _, dup := seen[m.ID]; dup {
+				t.Fatalf("message %s listed twice", m.ID)
+			}
+			seen[m.ID] = pos
+			pos++
+		}
+	}
+	if int64(len(seen)) != total {
+		t.Fatalf("paged through %d messages, total says %d", len(seen), total)
+	}
+	for i, id := range dated {
+		if seen[id] != i {
+			t.Fatalf("dated message %d at position %d, want %d", i, seen[id], i)
+		}
+	}
+	if pos, ok := seen[broken.ID]; !ok || pos < len(dated) {
+		t.Fatalf("expected the message without sent_at after the dated ones, got position %d (listed=%v)", pos, ok)
+	}
+
+	n, err := repo.RepairMissingSentAt(ctx)
+	if err != nil || n < 1 {
+		t.Fatalf("RepairMissingSentAt = %d, %v; want at least 1", n, err)
+	}
+	var repaired MessageModel
+	if err := tx.First(&repaired, "id = ?", broken.ID).Error; err != nil {
+		t.Fatalf("load repaired: %v", err)
+	}
+	if repaired.SentAt == nil || !repaired.SentAt.Equal(repaired.UpdatedAt) {
+		t.Fatalf("expected sent_at to fall back to updated_at, got sent_at=%v updated_at=%v", repaired.SentAt, repaired.UpdatedAt)
+	}
+}
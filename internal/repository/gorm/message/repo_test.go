@@ -0,0 +1,1115 @@
+package messagegorm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/oggyb/insider-assessment/internal/domain/message"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// fakeDB satisfies db.DB by handing out a pre-built *gorm.DB.
+type fakeDB struct {
+	conn *gorm.DB
+}
+
+func (f fakeDB) Conn() any { return f.conn }
+
+// newUnreachableRepo builds a repository on top of a GORM handle that points
+// at a closed port. Any query that actually reaches the driver fails (or hangs
+// until the dial times out), which lets tests assert that a code path never
+// hits the database.
+func newUnreachableRepo(t *testing.T) *Repository {
+	t.Helper()
+
+	conn, err := gorm.Open(postgres.Open("host=127.0.0.1 port=1 user=x password=x dbname=x sslmode=disable connect_timeout=5"), &gorm.Config{
+		DisableAutomaticPing: true,
+	})
+	if err != nil {
+		t.Fatalf("failed to open gorm handle: %v", err)
+	}
+
+	return NewRepository(fakeDB{conn: conn})
+}
+
+func TestRepository_GetPendingCancelledContext(t *testing.T) {
+	repo := newUnreachableRepo(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	start := time.Now()
+	msgs, err := repo.GetPending(ctx, 10)
+
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if msgs != nil {
+		t.Fatalf("expected no messages, got %d", len(msgs))
+	}
+	if elapsed := time.Since(start); elapsed > 50*time.Millisecond {
+		t.Fatalf("GetPending did not return promptly (took %s)", elapsed)
+	}
+}
+
+func TestRepository_GetSentCancelledContext(t *testing.T) {
+	repo := newUnreachableRepo(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	start := time.Now()
+	_, _, err := repo.GetSent(ctx, 1, 10)
+	if !errors.Is(err, context.Canceled) || !strings.HasPrefix(err.Error(), "count sent messages: ") {
+		t.Fatalf("expected a cancelled count phase, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 50*time.Millisecond {
+		t.Fatalf("GetSent did not return promptly (took %s)", elapsed)
+	}
+}
+
+func TestRepository_GetSentCancelledBetweenQueries(t *testing.T) {
+	conn := newDryRunConn(t, "primary", &connRecorder{})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// The context ends once the count has run.
+	queries := 0
+	_ = conn.Callback().Query().After("gorm:query").Register("test:cancel", func(tx *gorm.DB) {
+		queries++
+		cancel()
+	})
+
+	_, _, err := NewRepository(fakeDB{conn: conn}).GetSent(ctx, 1, 10)
+	if !errors.Is(err, context.Canceled) || !strings.HasPrefix(err.Error(), "list sent messages: ") {
+		t.Fatalf("expected a cancelled list phase, got %v", err)
+	}
+	if queries != 1 {
+		t.Fatalf("expected only the count query to run, got %d queries", queries)
+	}
+}
+
+func TestRepository_GetPendingExpiredDeadline(t *testing.T) {
+	repo := newUnreachableRepo(t)
+
+	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+
+	_, err := repo.GetPending(ctx, 10)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+	}
+}
+
+// connRecorder remembers which named connection executed each statement.
+type connRecorder struct {
+	mu   sync.Mutex
+	used []string
+}
+
+func (c *connRecorder) record(name string) func(*gorm.DB) {
+	return func(*gorm.DB) {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		c.used = append(c.used, name)
+	}
+}
+
+func (c *connRecorder) reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.used = nil
+}
+
+func (c *connRecorder) only(t *testing.T, want string) {
+	t.Helper()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.used) == 0 {
+		t.Fatalf("expected statements on %q, got none", want)
+	}
+	for _, name := range c.used {
+		if name != want {
+			t.Fatalf("expected all statements on %q, got %v", want, c.used)
+		}
+	}
+}
+
+// newDryRunConn opens a GORM handle in DryRun mode (SQL is built but never
+// executed) and tags every statement it runs with name.
+func newDryRunConn(t *testing.T, name string, rec *connRecorder) *gorm.DB {
+	t.Helper()
+
+	conn, err := gorm.Open(postgres.Open("host=127.0.0.1 port=1 user=x password=x dbname=x sslmode=disable"), &gorm.Config{
+		DisableAutomaticPing:   true,
+		DryRun:                 true,
+		SkipDefaultTransaction: true,
+	})
+	if err != nil {
+		t.Fatalf("failed to open gorm handle: %v", err)
+	}
+
+	cb := conn.Callback()
+	_ = cb.Query().Before("gorm:query").Register("test:record", rec.record(name))
+	_ = cb.Create().Before("gorm:create").Register("test:record", rec.record(name))
+	_ = cb.Update().Before("gorm:update").Register("test:record", rec.record(name))
+
+	return conn
+}
+
+func TestRepository_ReadWriteSplit(t *testing.T) {
+	rec := &connRecorder{}
+	primary := newDryRunConn(t, "primary", rec)
+	replica := newDryRunConn(t, "replica", rec)
+
+	repo := NewRepository(fakeDB{conn: primary}, WithReadDB(fakeDB{conn: replica}))
+	ctx := context.Background()
+
+	if _, _, err := repo.GetSent(ctx, 1, 10); err != nil {
+		t.Fatalf("GetSent: %v", err)
+	}
+	rec.only(t, "replica")
+
+	rec.reset()
+	msg, _ := message.NewMessage("+905000000000", "hello")
+	if err := repo.Save(ctx, msg); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if err := repo.UpdateStatus(ctx, msg); err != nil {
+		t.Fatalf("UpdateStatus: %v", err)
+	}
+	if _, err := repo.GetPending(ctx, 10); err != nil {
+		t.Fatalf("GetPending: %v", err)
+	}
+	// The pre-send status recheck must not see replica lag.
+	_, _ = repo.GetStatus(ctx, msg.ID)
+	// Nor may the duplicate check miss a message created a moment ago.
+	_, _ = repo.FindRecentDuplicate(ctx, msg.To, msg.Content, time.Now().Add(-time.Hour))
+	rec.only(t, "primary")
+}
+
+func TestRepository_ReadsDefaultToPrimary(t *testing.T) {
+	rec := &connRecorder{}
+	primary := newDryRunConn(t, "primary", rec)
+
+	repo := NewRepository(fakeDB{conn: primary})
+
+	if _, _, err := repo.GetSent(context.Background(), 1, 10); err != nil {
+		t.Fatalf("GetSent: %v", err)
+	}
+	rec.only(t, "primary")
+}
+
+func TestRepository_GetSentClampsLimit(t *testing.T) {
+	conn, err := gorm.Open(postgres.Open("host=127.0.0.1 port=1 user=x password=x dbname=x sslmode=disable"), &gorm.Config{
+		DisableAutomaticPing:   true,
+		DryRun:                 true,
+		SkipDefaultTransaction: true,
+	})
+	if err != nil {
+		t.Fatalf("failed to open gorm handle: %v", err)
+	}
+
+	// Capture the LIMIT clause rather than the SQL text: in DryRun mode the
+	// statement SQL is not reset between Count and Find on the same chain.
+	var mu sync.Mutex
+	var limits []int
+	_ = conn.Callback().Query().Before("gorm:query").Register("test:capture", func(tx *gorm.DB) {
+		c, ok := tx.Statement.Clauses["LIMIT"]
+		if !ok {
+			return
+		}
+		if l, ok := c.Expression.(clause.Limit); ok && l.Limit != nil {
+			mu.Lock()
+			limits = append(limits, *l.Limit)
+			mu.Unlock()
+		}
+	})
+
+	repo := NewRepository(fakeDB{conn: conn})
+	if _, _, err := repo.GetSent(context.Background(), 1, 1_000_000); err != nil {
+		t.Fatalf("GetSent: %v", err)
+	}
+
+	if len(limits) != 1 || limits[0] != message.MaxPageLimit {
+		t.Fatalf("expected a single LIMIT %d, got %v", message.MaxPageLimit, limits)
+	}
+}
+
+func TestRepository_SentStatsByDayGroupsOnReplica(t *testing.T) {
+	rec := &connRecorder{}
+	primary := newDryRunConn(t, "primary", rec)
+	replica := newDryRunConn(t, "replica", rec)
+
+	var sql string
+	_ = replica.Callback().Query().After("gorm:query").Register("test:sql", func(tx *gorm.DB) {
+		sql = tx.Statement.SQL.String()
+	})
+
+	repo := NewRepository(fakeDB{conn: primary}, WithReadDB(fakeDB{conn: replica}))
+	from := time.Date(2026, time.March, 1, 0, 0, 0, 0, time.UTC)
+	if _, err := repo.SentStatsByDay(context.Background(), from, from.AddDate(0, 0, 7)); err != nil {
+		t.Fatalf("SentStatsByDay: %v", err)
+	}
+
+	rec.only(t, "replica")
+	for _, want := range []string{"date_trunc('day'", "GROUP BY", "ORDER BY day ASC"} {
+		if !strings.Contains(sql, want) {
+			t.Fatalf("expected %q in query, got %s", want, sql)
+		}
+	}
+}
+
+func TestRepository_TopRecipientsGroupsOnReplica(t *testing.T) {
+	rec := &connRecorder{}
+	primary := newDryRunConn(t, "primary", rec)
+	replica := newDryRunConn(t, "replica", rec)
+
+	var sql string
+	_ = replica.Callback().Query().After("gorm:query").Register("test:sql", func(tx *gorm.DB) {
+		sql = tx.Statement.SQL.String()
+	})
+
+	repo := NewRepository(fakeDB{conn: primary}, WithReadDB(fakeDB{conn: replica}))
+	from := time.Date(2026, time.March, 1, 0, 0, 0, 0, time.UTC)
+	if _, err := repo.TopRecipients(context.Background(), from, from.Add(time.Hour), 5); err != nil {
+		t.Fatalf("TopRecipients: %v", err)
+	}
+
+	rec.only(t, "replica")
+	for _, want := range []string{`COUNT(*) AS count`, `GROUP BY "to"`, `ORDER BY count DESC, "to" ASC`, "LIMIT", "created_at >="} {
+		if !strings.Contains(sql, want) {
+			t.Fatalf("expected %q in query, got %s", want, sql)
+		}
+	}
+}
+
+func TestRepository_SendLatencyStatsUsesPercentileOnReplica(t *testing.T) {
+	rec := &connRecorder{}
+	primary := newDryRunConn(t, "primary", rec)
+	replica := newDryRunConn(t, "replica", rec)
+
+	var sql string
+	_ = replica.Callback().Query().After("gorm:query").Register("test:sql", func(tx *gorm.DB) {
+		sql = tx.Statement.SQL.String()
+	})
+
+	repo := NewRepository(fakeDB{conn: primary}, WithReadDB(fakeDB{conn: replica}))
+	from := time.Date(2026, time.March, 1, 0, 0, 0, 0, time.UTC)
+	if _, _, err := repo.SendLatencyStats(context.Background(), from, from.Add(time.Hour)); err != nil {
+		t.Fatalf("SendLatencyStats: %v", err)
+	}
+
+	rec.only(t, "replica")
+	for _, want := range []string{"AVG(send_duration_ms)", "percentile_cont(0.95) WITHIN GROUP (ORDER BY send_duration_ms)", "sent_at >="} {
+		if !strings.Contains(sql, want) {
+			t.Fatalf("expected %q in query, got %s", want, sql)
+		}
+	}
+}
+
+func TestRepository_OldestPendingAgeOnReplica(t *testing.T) {
+	rec := &connRecorder{}
+	primary := newDryRunConn(t, "primary", rec)
+	replica := newDryRunConn(t, "replica", rec)
+
+	var sql string
+	_ = replica.Callback().Query().After("gorm:query").Register("test:sql", func(tx *gorm.DB) {
+		sql = tx.Statement.SQL.String()
+	})
+
+	repo := NewRepository(fakeDB{conn: primary}, WithReadDB(fakeDB{conn: replica}))
+	age, err := repo.OldestPendingAge(context.Background())
+	if err != nil {
+		t.Fatalf("OldestPendingAge: %v", err)
+	}
+	if age != 0 {
+		t.Fatalf("expected zero age without rows, got %v", age)
+	}
+
+	rec.only(t, "replica")
+	for _, want := range []string{"MIN(created_at)", "status = $1"} {
+		if !strings.Contains(sql, want) {
+			t.Fatalf("expected %q in query, got %s", want, sql)
+		}
+	}
+}
+
+func TestRepository_GetPendingSkipsRetryBackoff(t *testing.T) {
+	conn := newDryRunConn(t, "primary", &connRecorder{})
+
+	var sql string
+	_ = conn.Callback().Query().After("gorm:query").Register("test:sql", func(tx *gorm.DB) {
+		sql = tx.Statement.SQL.String()
+	})
+
+	if _, err := NewRepository(fakeDB{conn: conn}).GetPending(context.Background(), 10); err != nil {
+		t.Fatalf("GetPending: %v", err)
+	}
+	if !strings.Contains(sql, "next_retry_at IS NULL OR next_retry_at <=") {
+		t.Fatalf("expected messages waiting for a retry to be skipped, got %s", sql)
+	}
+}
+
+func TestRepository_GetPendingRetryPriority(t *testing.T) {
+	cases := []struct {
+		priority RetryPriority
+		want     string
+	}{
+		{"", `ORDER BY created_at ASC`},
+		{RetryPriorityFirst, `ORDER BY retry_count > 0 DESC,created_at ASC`},
+		{RetryPriorityLast, `ORDER BY retry_count > 0 ASC,created_at ASC`},
+	}
+	for _, tc := range cases {
+		conn := newDryRunConn(t, "primary", &connRecorder{})
+
+		var sql string
+		_ = conn.Callback().Query().After("gorm:query").Register("test:sql", func(tx *gorm.DB) {
+			sql = tx.Statement.SQL.String()
+		})
+
+		repo := NewRepository(fakeDB{conn: conn}, WithRetryPriority(tc.priority))
+		if _, err := repo.GetPending(context.Background(), 10); err != nil {
+			t.Fatalf("GetPending: %v", err)
+		}
+		if !strings.Contains(sql, tc.want) {
+			t.Fatalf("priority %q: expected %q, got %s", tc.priority, tc.want, sql)
+		}
+	}
+}
+
+func TestRepository_GetPendingRetryBudget(t *testing.T) {
+	conn := newDryRunConn(t, "primary", &connRecorder{})
+
+	// Record each claim's retry filter and LIMIT; the dry run returns no
+	// rows, so the retried share comes up empty and fresh messages may take
+	// the whole batch.
+	var claims []string
+	_ = conn.Callback().Query().Before("gorm:query").Register("test:capture", func(tx *gorm.DB) {
+		kind := "any"
+		if w, ok := tx.Statement.Clauses["WHERE"].Expression.(clause.Where); ok {
+			for _, e := range w.Exprs {
+				if expr, ok := e.(clause.Expr); ok && strings.HasPrefix(expr.SQL, "retry_count") {
+					kind = expr.SQL
+				}
+			}
+		}
+		if l, ok := tx.Statement.Clauses["LIMIT"].Expression.(clause.Limit); ok && l.Limit != nil {
+			kind += fmt.Sprintf(" LIMIT %d", *l.Limit)
+		}
+		claims = append(claims, kind)
+	})
+
+	repo := NewRepository(fakeDB{conn: conn}, WithRetryBudget(0.25))
+	if _, err := repo.GetPending(context.Background(), 10); err != nil {
+		t.Fatalf("GetPending: %v", err)
+	}
+
+	want := []string{"retry_count > 0 LIMIT 2", "retry_count = 0 LIMIT 10"}
+	if !slices.Equal(claims, want) {
+		t.Fatalf("claims = %q, want %q", claims, want)
+	}
+}
+
+func TestRetryBudgetCap(t *testing.T) {
+	cases := []struct {
+		limit  int
+		budget float64
+		want   int
+	}{
+		{10, 0.25, 2},
+		{100, 0.3, 30},
+		{3, 0.1, 1}, // never starve retries entirely
+		{1, 0.5, 1},
+	}
+	for _, tc := range cases {
+		if got := retryBudgetCap(tc.limit, tc.budget); got != tc.want {
+			t.Fatalf("retryBudgetCap(%d, %v) = %d, want %d", tc.limit, tc.budget, got, tc.want)
+		}
+	}
+
+	for _, off := range []float64{0, 1, -0.5, 2} {
+		if r := NewRepository(fakeDB{conn: newDryRunConn(t, "primary", &connRecorder{})}, WithRetryBudget(off)); r.retryBudget != 0 {
+			t.Fatalf("WithRetryBudget(%v) should disable the budget, got %v", off, r.retryBudget)
+		}
+	}
+}
+
+func TestRepository_TransactionKeepsOptions(t *testing.T) {
+	primary := newDryRunConn(t, "primary", &connRecorder{})
+	replica := newDryRunConn(t, "replica", &connRecorder{})
+	tx := newDryRunConn(t, "tx", &connRecorder{})
+
+	repo := NewRepository(fakeDB{conn: primary}, WithReadDB(fakeDB{conn: replica}),
+		WithContentDedup(true), WithRetryPriority(RetryPriorityLast), WithRetryBudget(0.5))
+	bound := repo.bound(tx)
+
+	if bound.db != tx || bound.reader != tx {
+		t.Fatal("expected every query of the bound repository to use the transaction")
+	}
+	if !bound.dedupContent || bound.retryPriority != RetryPriorityLast || bound.retryBudget != 0.5 {
+		t.Fatalf("expected options to carry over, got dedup=%v priority=%q budget=%v", bound.dedupContent, bound.retryPriority, bound.retryBudget)
+	}
+}
+
+func TestParseRetryPriority(t *testing.T) {
+	if p, err := ParseRetryPriority(" First "); err != nil || p != RetryPriorityFirst {
+		t.Fatalf("ParseRetryPriority(first) = %q, %v", p, err)
+	}
+	if p, err := ParseRetryPriority(""); err != nil || p != "" {
+		t.Fatalf("ParseRetryPriority(\"\") = %q, %v", p, err)
+	}
+	if _, err := ParseRetryPriority("middle"); err == nil {
+		t.Fatal("expected an unknown priority to be rejected")
+	}
+}
+
+func TestRepository_UpdateStatusWritesNextRetryAt(t *testing.T) {
+	conn := newDryRunConn(t, "primary", &connRecorder{})
+
+	var sql string
+	_ = conn.Callback().Update().After("gorm:update").Register("test:sql", func(tx *gorm.DB) {
+		sql = tx.Statement.SQL.String()
+	})
+
+	msg, _ := message.NewMessage("+905000000000", "hello")
+	msg.ScheduleRetry("{}", "unavailable", time.Now(), time.Minute)
+	if err := NewRepository(fakeDB{conn: conn}).UpdateStatus(context.Background(), msg); err != nil {
+		t.Fatalf("UpdateStatus: %v", err)
+	}
+	if !strings.Contains(sql, `"next_retry_at"=`) {
+		t.Fatalf("expected next_retry_at to be persisted, got %s", sql)
+	}
+}
+
+func TestRepository_GetByProviderCodeFiltersOnReplica(t *testing.T) {
+	rec := &connRecorder{}
+	primary := newDryRunConn(t, "primary", rec)
+	replica := newDryRunConn(t, "replica", rec)
+
+	var sql []string
+	_ = replica.Callback().Query().After("gorm:query").Register("test:sql", func(tx *gorm.DB) {
+		sql = append(sql, tx.Statement.SQL.String())
+	})
+
+	repo := NewRepository(fakeDB{conn: primary}, WithReadDB(fakeDB{conn: replica}))
+	if _, _, err := repo.GetByProviderCode(context.Background(), "INVALID_NUMBER", 1, 10); err != nil {
+		t.Fatalf("GetByProviderCode: %v", err)
+	}
+	rec.only(t, "replica")
+	if len(sql) == 0 || !strings.Contains(sql[len(sql)-1], "provider_code = $1") {
+		t.Fatalf("expected a provider_code filter, got %v", sql)
+	}
+}
+
+func TestRepository_UpdateStatusWritesProviderCode(t *testing.T) {
+	conn := newDryRunConn(t, "primary", &connRecorder{})
+
+	var sql string
+	var vars []any
+	_ = conn.Callback().Update().After("gorm:update").Register("test:sql", func(tx *gorm.DB) {
+		sql = tx.Statement.SQL.String()
+		vars = tx.Statement.Vars
+	})
+
+	msg, _ := message.NewMessage("+905000000000", "hello")
+	msg.MarkFailed(`{"code":"INVALID_NUMBER"}`)
+	if err := NewRepository(fakeDB{conn: conn}).UpdateStatus(context.Background(), msg); err != nil {
+		t.Fatalf("UpdateStatus: %v", err)
+	}
+	if !strings.Contains(sql, `"provider_code"=`) || !slices.Contains(vars, any("INVALID_NUMBER")) {
+		t.Fatalf("expected the provider code to be persisted, got %s %v", sql, vars)
+	}
+}
+
+func TestRepository_ClearContentDedupTargetsUnsentRecipient(t *testing.T) {
+	conn := newDryRunConn(t, "primary", &connRecorder{})
+
+	var sql string
+	_ = conn.Callback().Update().After("gorm:update").Register("test:sql", func(tx *gorm.DB) {
+		sql = tx.Statement.SQL.String()
+	})
+
+	if _, err := NewRepository(fakeDB{conn: conn}).ClearContentDedup(context.Background(), "+905000000000"); err != nil {
+		t.Fatalf("ClearContentDedup: %v", err)
+	}
+	for _, want := range []string{`"content_hash"=NULL`, `"to" = $`, "content_hash IS NOT NULL", "status IN ("} {
+		if !strings.Contains(sql, want) {
+			t.Fatalf("expected %q in %s", want, sql)
+		}
+	}
+}
+
+func TestRepository_GetSentSortsMissingSentAtLast(t *testing.T) {
+	conn := newDryRunConn(t, "primary", &connRecorder{})
+
+	// Capture the ORDER BY clause: in DryRun mode the statement SQL is not
+	// reset between Count and Find on the same chain.
+	var orders []string
+	_ = conn.Callback().Query().Before("gorm:query").Register("test:capture", func(tx *gorm.DB) {
+		if c, ok := tx.Statement.Clauses["ORDER BY"]; ok {
+			if o, ok := c.Expression.(clause.OrderBy); ok {
+				for _, col := range o.Columns {
+					orders = append(orders, col.Column.Name)
+				}
+			}
+		}
+	})
+
+	if _, _, err := NewRepository(fakeDB{conn: conn}).GetSent(context.Background(), 2, 10); err != nil {
+		t.Fatalf("GetSent: %v", err)
+	}
+	if len(orders) != 1 || orders[0] != "sent_at DESC NULLS LAST, id DESC" {
+		t.Fatalf("expected a deterministic NULLS LAST order, got %q", orders)
+	}
+}
+
+func TestRepository_RepairMissingSentAtKeepsUpdatedAt(t *testing.T) {
+	conn := newDryRunConn(t, "primary", &connRecorder{})
+
+	var sql string
+	_ = conn.Callback().Update().After("gorm:update").Register("test:sql", func(tx *gorm.DB) {
+		sql = tx.Statement.SQL.String()
+	})
+
+	if _, err := NewRepository(fakeDB{conn: conn}).RepairMissingSentAt(context.Background()); err != nil {
+		t.Fatalf("RepairMissingSentAt: %v", err)
+	}
+	for _, want := range []string{`"sent_at"=updated_at`, "status = $", "sent_at IS NULL"} {
+		if !strings.Contains(sql, want) {
+			t.Fatalf("expected %q in %s", want, sql)
+		}
+	}
+	if strings.Contains(sql, `"updated_at"=`) {
+		t.Fatalf("expected updated_at to be left alone, got %s", sql)
+	}
+}
+
+func TestRepository_SaveTranslatesDuplicateContent(t *testing.T) {
+	for _, dedup := range []bool{false, true} {
+		rec := &connRecorder{}
+		conn := newDryRunConn(t, "primary", rec)
+
+		var hash *string
+		_ = conn.Callback().Create().After("gorm:create").Register("test:conflict", func(tx *gorm.DB) {
+			hash = tx.Statement.Dest.(*MessageModel).ContentHash
+			_ = tx.AddError(&pgconn.PgError{Code: "23505", ConstraintName: "idx_messages_unsent_content_hash"})
+		})
+
+		repo := NewRepository(fakeDB{conn: conn}, WithContentDedup(dedup))
+		msg, _ := message.NewMessage("+905000000000", "hello")
+		err := repo.Save(context.Background(), msg)
+
+		if !errors.Is(err, message.ErrDuplicateMessage) {
+			t.Fatalf("dedup=%v: expected ErrDuplicateMessage, got %v", dedup, err)
+		}
+		if (hash != nil) != dedup {
+			t.Fatalf("dedup=%v: unexpected content hash %v", dedup, hash)
+		}
+	}
+}
+
+func TestContentHash_SeparatesRecipientFromContent(t *testing.T) {
+	a := contentHash("+9050", "00hello")
+	b := contentHash("+905000", "hello")
+	if *a == *b {
+		t.Fatal("expected different recipient/content splits to hash differently")
+	}
+	if *a != *contentHash("+9050", "00hello") || len(*a) != 64 {
+		t.Fatalf("expected a stable 64-char hex hash, got %q", *a)
+	}
+}
+
+func TestTags_RoundTrip(t *testing.T) {
+	msg, err := message.NewMessage("+905000000000", "hello", message.WithTags(map[string]string{"env": "staging", "team": "growth"}))
+	if err != nil {
+		t.Fatalf("NewMessage: %v", err)
+	}
+
+	model := fromDomain(msg)
+	v, err := model.Tags.Value()
+	if err != nil {
+		t.Fatalf("Value: %v", err)
+	}
+
+	// Postgres hands JSONB back as bytes.
+	var scanned Tags
+	if err := scanned.Scan([]byte(v.(string))); err != nil {
+		t.Fatalf("Scan: %v", err)
+	}
+	model.Tags = scanned
+
+	got := toDomain(model).Tags
+	if len(got) != 2 || got["env"] != "staging" || got["team"] != "growth" {
+		t.Fatalf("tags did not round-trip: %v", got)
+	}
+
+	var empty Tags
+	if v, _ := empty.Value(); v != nil {
+		t.Fatalf("expected empty tags to be stored as NULL, got %v", v)
+	}
+	if err := scanned.Scan(nil); err != nil || scanned != nil {
+		t.Fatalf("expected NULL to scan into nil tags, got %v (%v)", scanned, err)
+	}
+}
+
+func TestRepository_ListFiltersByTagContainment(t *testing.T) {
+	rec := &connRecorder{}
+	primary := newDryRunConn(t, "primary", rec)
+	replica := newDryRunConn(t, "replica", rec)
+
+	var mu sync.Mutex
+	var sql string
+	var vars []any
+	_ = replica.Callback().Query().After("gorm:query").Register("test:sql", func(tx *gorm.DB) {
+		mu.Lock()
+		defer mu.Unlock()
+		sql, vars = tx.Statement.SQL.String(), tx.Statement.Vars
+	})
+
+	repo := NewRepository(fakeDB{conn: primary}, WithReadDB(fakeDB{conn: replica}))
+	filter := message.ListFilter{Tags: map[string]string{"env": "staging"}}
+	if _, _, err := repo.List(context.Background(), filter, 1, 10); err != nil {
+		t.Fatalf("List: %v", err)
+	}
+
+	rec.only(t, "replica")
+	mu.Lock()
+	defer mu.Unlock()
+	if !strings.Contains(sql, "tags @> $1::jsonb") {
+		t.Fatalf("expected a JSONB containment filter, got %s", sql)
+	}
+	if len(vars) == 0 || vars[0] != `{"env":"staging"}` {
+		t.Fatalf("expected the tag filter as JSON, got %v", vars)
+	}
+}
+
+func TestRepository_BatchRunsRecordOnPrimaryListOnReplica(t *testing.T) {
+	rec := &connRecorder{}
+	primary := newDryRunConn(t, "primary", rec)
+	replica := newDryRunConn(t, "replica", rec)
+
+	repo := NewRepository(fakeDB{conn: primary}, WithReadDB(fakeDB{conn: replica}))
+	ctx := context.Background()
+
+	result := message.BatchResult{StartedAt: time.Now(), Duration: 1500 * time.Millisecond, Processed: 3, Succeeded: 2, Failed: 1}
+	if err := repo.RecordBatchRun(ctx, result); err != nil {
+		t.Fatalf("RecordBatchRun: %v", err)
+	}
+	rec.only(t, "primary")
+
+	rec.reset()
+	if _, _, err := repo.ListBatchRuns(ctx, 1, 10); err != nil {
+		t.Fatalf("ListBatchRuns: %v", err)
+	}
+	rec.only(t, "replica")
+
+	// The model keeps the duration in milliseconds.
+	model := batchRunFromDomain(result)
+	if model.ID == uuid.Nil || model.DurationMs != 1500 {
+		t.Fatalf("unexpected model %+v", model)
+	}
+	if got := batchRunToDomain(model); got.BatchResult != result {
+		t.Fatalf("batch result did not round-trip: %+v", got.BatchResult)
+	}
+}
+
+func TestRepository_SoftDeleteKeepsRowAndHidesFromListings(t *testing.T) {
+	conn, err := gorm.Open(postgres.Open("host=127.0.0.1 port=1 user=x password=x dbname=x sslmode=disable"), &gorm.Config{
+		DisableAutomaticPing:   true,
+		DryRun:                 true,
+		SkipDefaultTransaction: true,
+	})
+	if err != nil {
+		t.Fatalf("failed to open gorm handle: %v", err)
+	}
+
+	var mu sync.Mutex
+	var sqls []string
+	capture := func(tx *gorm.DB) {
+		mu.Lock()
+		defer mu.Unlock()
+		sqls = append(sqls, tx.Statement.SQL.String())
+	}
+	_ = conn.Callback().Delete().After("gorm:delete").Register("test:sql", capture)
+	_ = conn.Callback().Query().After("gorm:query").Register("test:sql", capture)
+
+	repo := NewRepository(fakeDB{conn: conn})
+	ctx := context.Background()
+
+	// DryRun affects no rows, so the missing-row error is expected here.
+	if err := repo.SoftDelete(ctx, uuid.New()); !errors.Is(err, message.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound from a dry run, got %v", err)
+	}
+
+	mu.Lock()
+	deleteSQL := sqls[0]
+	sqls = nil
+	mu.Unlock()
+	if !strings.HasPrefix(deleteSQL, `UPDATE "messages" SET "deleted_at"=`) {
+		t.Fatalf("expected a soft delete that keeps the row, got %s", deleteSQL)
+	}
+
+	if _, err := repo.GetPending(ctx, 10); err != nil {
+		t.Fatalf("GetPending: %v", err)
+	}
+	if _, _, err := repo.GetSent(ctx, 1, 10); err != nil {
+		t.Fatalf("GetSent: %v", err)
+	}
+	if _, _, err := repo.List(ctx, message.ListFilter{}, 1, 10); err != nil {
+		t.Fatalf("List: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(sqls) == 0 {
+		t.Fatalf("expected listing queries to be captured")
+	}
+	for _, sql := range sqls {
+		if !strings.Contains(sql, `"messages"."deleted_at" IS NULL`) {
+			t.Fatalf("expected listing to exclude soft-deleted rows, got %s", sql)
+		}
+	}
+}
+
+func TestRepository_ResetStuckTargetsStaleProcessingRows(t *testing.T) {
+	rec := &connRecorder{}
+	primary := newDryRunConn(t, "primary", rec)
+	replica := newDryRunConn(t, "replica", rec)
+
+	var sql string
+	var vars []any
+	_ = primary.Callback().Update().After("gorm:update").Register("test:sql", func(tx *gorm.DB) {
+		sql, vars = tx.Statement.SQL.String(), tx.Statement.Vars
+	})
+
+	repo := NewRepository(fakeDB{conn: primary}, WithReadDB(fakeDB{conn: replica}))
+	cutoff := time.Now().Add(-5 * time.Minute)
+	if _, err := repo.ResetStuck(context.Background(), cutoff); err != nil {
+		t.Fatalf("ResetStuck: %v", err)
+	}
+
+	rec.only(t, "primary")
+	for _, want := range []string{`SET "status"=`, "status = $", "updated_at <= $", "RETURNING"} {
+		if !strings.Contains(sql, want) {
+			t.Fatalf("expected %q in query, got %s", want, sql)
+		}
+	}
+	if !slices.Contains(vars, any(string(message.StatusPending))) || !slices.Contains(vars, any(message.StatusProcessing)) {
+		t.Fatalf("expected PROCESSING rows to be moved to PENDING, got vars %v", vars)
+	}
+}
+
+func TestRepository_StreamByCreatedRangeScansReplicaInOrder(t *testing.T) {
+	rec := &connRecorder{}
+	primary := newDryRunConn(t, "primary", rec)
+	replica := newDryRunConn(t, "replica", rec)
+
+	var sql string
+	_ = replica.Callback().Row().After("gorm:row").Register("test:sql", func(tx *gorm.DB) {
+		rec.record("replica")(tx)
+		sql = tx.Statement.SQL.String()
+	})
+
+	repo := NewRepository(fakeDB{conn: primary}, WithReadDB(fakeDB{conn: replica}))
+	from := time.Date(2026, time.March, 1, 0, 0, 0, 0, time.UTC)
+	err := repo.StreamByCreatedRange(context.Background(), from, from.AddDate(0, 0, 7), func(*message.Message) error {
+		t.Fatal("dry run must not yield rows")
+		return nil
+	})
+	if !errors.Is(err, gorm.ErrDryRunModeUnsupported) {
+		t.Fatalf("expected dry-run error from Rows, got %v", err)
+	}
+
+	rec.only(t, "replica")
+	for _, want := range []string{"created_at >= $1 AND created_at < $2", `"deleted_at" IS NULL`, "ORDER BY created_at ASC"} {
+		if !strings.Contains(sql, want) {
+			t.Fatalf("expected %q in query, got %s", want, sql)
+		}
+	}
+}
+
+func TestRepository_StreamSentScansReplicaNewestFirst(t *testing.T) {
+	rec := &connRecorder{}
+	primary := newDryRunConn(t, "primary", rec)
+	replica := newDryRunConn(t, "replica", rec)
+
+	var sql string
+	_ = replica.Callback().Row().After("gorm:row").Register("test:sql", func(tx *gorm.DB) {
+		rec.record("replica")(tx)
+		sql = tx.Statement.SQL.String()
+	})
+
+	repo := NewRepository(fakeDB{conn: primary}, WithReadDB(fakeDB{conn: replica}))
+	err := repo.StreamSent(context.Background(), func(*message.Message) error {
+		t.Fatal("dry run must not yield rows")
+		return nil
+	})
+	if !errors.Is(err, gorm.ErrDryRunModeUnsupported) {
+		t.Fatalf("expected dry-run error from Rows, got %v", err)
+	}
+
+	rec.only(t, "replica")
+	for _, want := range []string{"status = $1", `"deleted_at" IS NULL`, "ORDER BY sent_at DESC NULLS LAST, id DESC"} {
+		if !strings.Contains(sql, want) {
+			t.Fatalf("expected %q in query, got %s", want, sql)
+		}
+	}
+}
+
+func TestRepository_SaveTemplateUpsertsByName(t *testing.T) {
+	rec := &connRecorder{}
+	primary := newDryRunConn(t, "primary", rec)
+	replica := newDryRunConn(t, "replica", rec)
+
+	var sql string
+	_ = primary.Callback().Create().After("gorm:create").Register("test:sql", func(tx *gorm.DB) {
+		sql = tx.Statement.SQL.String()
+	})
+
+	repo := NewRepository(fakeDB{conn: primary}, WithReadDB(fakeDB{conn: replica}))
+	tmpl, err := message.NewTemplate("otp", "Your code is {{code}}")
+	if err != nil {
+		t.Fatalf("NewTemplate: %v", err)
+	}
+	if err := repo.SaveTemplate(context.Background(), tmpl); err != nil {
+		t.Fatalf("SaveTemplate: %v", err)
+	}
+
+	rec.only(t, "primary")
+	for _, want := range []string{`INSERT INTO "message_templates"`, `ON CONFLICT ("name") DO UPDATE SET "body"="excluded"."body"`} {
+		if !strings.Contains(sql, want) {
+			t.Fatalf("expected %q in query, got %s", want, sql)
+		}
+	}
+}
+
+func TestRepository_PingUsesPrimary(t *testing.T) {
+	primary := newDryRunConn(t, "primary", &connRecorder{})
+	replica := newDryRunConn(t, "replica", &connRecorder{})
+
+	var used, sql string
+	for name, conn := range map[string]*gorm.DB{"primary": primary, "replica": replica} {
+		_ = conn.Callback().Raw().After("gorm:raw").Register("test:sql", func(tx *gorm.DB) {
+			used, sql = name, tx.Statement.SQL.String()
+		})
+	}
+
+	repo := NewRepository(fakeDB{conn: primary}, WithReadDB(fakeDB{conn: replica}))
+	if err := repo.Ping(context.Background()); err != nil {
+		t.Fatalf("Ping: %v", err)
+	}
+	if used != "primary" || sql != "SELECT 1" {
+		t.Fatalf("expected SELECT 1 on the primary, got %q on %q", sql, used)
+	}
+}
+
+func TestRepository_PingFailsOnClosedConnection(t *testing.T) {
+	conn, err := gorm.Open(postgres.Open("host=127.0.0.1 port=1 user=x password=x dbname=x sslmode=disable"), &gorm.Config{
+		DisableAutomaticPing: true,
+	})
+	if err != nil {
+		t.Fatalf("failed to open gorm handle: %v", err)
+	}
+	sqlDB, err := conn.DB()
+	if err != nil {
+		t.Fatalf("DB: %v", err)
+	}
+	_ = sqlDB.Close()
+
+	if err := NewRepository(fakeDB{conn: conn}).Ping(context.Background()); err == nil {
+		t.Fatal("expected Ping to fail on a closed connection")
+	}
+}
+
+func TestRepository_OptOutTokenForUpsertsByRecipient(t *testing.T) {
+	conn := newDryRunConn(t, "primary", &connRecorder{})
+
+	var sqls []string
+	capture := func(tx *gorm.DB) { sqls = append(sqls, tx.Statement.SQL.String()) }
+	_ = conn.Callback().Create().After("gorm:create").Register("test:sql", capture)
+	_ = conn.Callback().Query().After("gorm:query").Register("test:sql", capture)
+
+	if _, err := NewRepository(fakeDB{conn: conn}).OptOutTokenFor(context.Background(), "+905000000001"); err != nil {
+		t.Fatalf("OptOutTokenFor: %v", err)
+	}
+
+	if len(sqls) != 2 {
+		t.Fatalf("expected an insert and a select, got %q", sqls)
+	}
+	if !strings.Contains(sqls[0], `INSERT INTO "opt_out_tokens"`) || !strings.Contains(sqls[0], `ON CONFLICT ("to") DO NOTHING`) {
+		t.Fatalf("unexpected insert: %s", sqls[0])
+	}
+	if !strings.Contains(sqls[1], `"to" = $1`) {
+		t.Fatalf("expected the token to be read back by recipient, got %s", sqls[1])
+	}
+}
+
+func TestRepository_ResolveOptOutTokenKeepsFirstTime(t *testing.T) {
+	conn := newDryRunConn(t, "primary", &connRecorder{})
+
+	var sql string
+	_ = conn.Callback().Update().After("gorm:update").Register("test:sql", func(tx *gorm.DB) {
+		sql = tx.Statement.SQL.String()
+	})
+
+	_, err := NewRepository(fakeDB{conn: conn}).ResolveOptOutToken(context.Background(), "abc", time.Now())
+	if !errors.Is(err, message.ErrOptOutTokenNotFound) {
+		t.Fatalf("expected ErrOptOutTokenNotFound from the dry run, got %v", err)
+	}
+	for _, want := range []string{`"opted_out_at"=COALESCE(opted_out_at, $1)`, "token = $2", "RETURNING"} {
+		if !strings.Contains(sql, want) {
+			t.Fatalf("expected %q in %s", want, sql)
+		}
+	}
+}
+
+// namingFor returns the naming strategy of n, failing the test if n is
+// invalid.
+func namingFor(t *testing.T, n TableNaming) schema.NamingStrategy {
+	t.Helper()
+	namer, err := n.NamingStrategy()
+	if err != nil {
+		t.Fatalf("NamingStrategy(%+v): %v", n, err)
+	}
+	return namer
+}
+
+// parseModel parses model under namer with a fresh cache, so the table
+// name is read again.
+func parseModel(t *testing.T, model any, namer schema.Namer) *schema.Schema {
+	t.Helper()
+	s, err := schema.Parse(model, &sync.Map{}, namer)
+	if err != nil {
+		t.Fatalf("schema.Parse: %v", err)
+	}
+	return s
+}
+
+func TestTableNaming_NamingStrategy(t *testing.T) {
+	cases := []struct {
+		naming TableNaming
+		model  any
+		want   string
+	}{
+		{TableNaming{}, &MessageModel{}, "messages"},
+		{TableNaming{}, &StatusEventModel{}, "message_status_events"},
+		{TableNaming{}, &BatchRunModel{}, "batch_runs"},
+		{TableNaming{}, &TemplateModel{}, "message_templates"},
+		{TableNaming{}, &OptOutTokenModel{}, "opt_out_tokens"},
+		{TableNaming{}, &LinkModel{}, "links"},
+		{TableNaming{Schema: "tenant_a", Prefix: "acme_"}, &MessageModel{}, "tenant_a.acme_messages"},
+		{TableNaming{Schema: "tenant_a", Prefix: "acme_"}, &StatusEventModel{}, "tenant_a.acme_message_status_events"},
+		{TableNaming{Prefix: "acme_"}, &BatchRunModel{}, "acme_batch_runs"},
+	}
+	for _, tc := range cases {
+		if got := parseModel(t, tc.model, namingFor(t, tc.naming)).Table; got != tc.want {
+			t.Errorf("%+v %T: table = %q, want %q", tc.naming, tc.model, got, tc.want)
+		}
+	}
+
+	for _, bad := range []TableNaming{{Schema: "tenant;drop"}, {Prefix: "acme-"}, {Schema: "1tenant"}} {
+		if _, err := bad.NamingStrategy(); err == nil {
+			t.Fatalf("expected %+v to be rejected", bad)
+		}
+	}
+}
+
+func TestTableNaming_PrefixesIndexNames(t *testing.T) {
+	indexNames := func(model any, namer schema.Namer) []string {
+		t.Helper()
+		var names []string
+		for _, idx := range parseModel(t, model, namer).ParseIndexes() {
+			names = append(names, idx.Name)
+		}
+		return names
+	}
+
+	// Unprefixed tables keep the names indexes always had.
+	plain := namingFor(t, TableNaming{})
+	for _, want := range []string{"idx_messages_to_created_at", "idx_messages_tags", "idx_messages_unsent_content_hash"} {
+		if !slices.Contains(indexNames(&MessageModel{}, plain), want) {
+			t.Fatalf("expected index %s, got %v", want, indexNames(&MessageModel{}, plain))
+		}
+	}
+
+	acme := namingFor(t, TableNaming{Prefix: "acme_"})
+	for _, name := range append(indexNames(&MessageModel{}, acme), indexNames(&StatusEventModel{}, acme)...) {
+		if !strings.HasPrefix(name, "idx_acme_") {
+			t.Fatalf("expected every index to carry the table prefix, got %s", name)
+		}
+	}
+
+	conn, err := gorm.Open(postgres.Open("host=127.0.0.1 port=1 user=x password=x dbname=x sslmode=disable"), &gorm.Config{
+		DisableAutomaticPing: true,
+		DryRun:               true,
+		NamingStrategy:       acme,
+	})
+	if err != nil {
+		t.Fatalf("failed to open gorm handle: %v", err)
+	}
+	dup := &pgconn.PgError{Code: "23505", ConstraintName: "idx_acme_messages_unsent_content_hash"}
+	if !errors.Is(NewRepository(fakeDB{conn: conn}).translateDuplicate(dup), message.ErrDuplicateMessage) {
+		t.Fatal("expected a violation of the prefixed content hash index to be a duplicate")
+	}
+	if errors.Is(newUnreachableRepo(t).translateDuplicate(dup), message.ErrDuplicateMessage) {
+		t.Fatal("expected another tenant's index not to count as a duplicate")
+	}
+}
+
+func TestRepository_QueriesUseConfiguredTable(t *testing.T) {
+	// Two repositories with different namings side by side in one process.
+	for naming, want := range map[TableNaming]string{
+		{Schema: "tenant_a", Prefix: "acme_"}: `FROM "tenant_a"."acme_messages"`,
+		{}:                                    `FROM "messages"`,
+	} {
+		conn, err := gorm.Open(postgres.Open("host=127.0.0.1 port=1 user=x password=x dbname=x sslmode=disable"), &gorm.Config{
+			DisableAutomaticPing: true,
+			DryRun:               true,
+			NamingStrategy:       namingFor(t, naming),
+		})
+		if err != nil {
+			t.Fatalf("failed to open gorm handle: %v", err)
+		}
+
+		var sql string
+		_ = conn.Callback().Query().After("gorm:query").Register("test:sql", func(tx *gorm.DB) {
+			sql = tx.Statement.SQL.String()
+		})
+
+		_, _ = NewRepository(fakeDB{conn: conn}).GetByID(context.Background(), uuid.New())
+		if !strings.Contains(sql, want) {
+			t.Fatalf("%+v: expected the query to contain %s, got %s", naming, want, sql)
+		}
+	}
+}
+
+func TestSortByRecipient(t *testing.T) {
+	models := []MessageModel{
+		{To: "+905000000002", Content: "b1"},
+		{To: "+905000000001", Content: "a1"},
+		{To: "+905000000002", Content: "b2"},
+		{To: "+905000000001", Content: "a2"},
+	}
+
+	sortByRecipient(models)
+
+	var got []string
+	for _, m := range models {
+		got = append(got, m.Content)
+	}
+	if want := []string{"a1", "a2", "b1", "b2"}; !slices.Equal(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+}
@@ -0,0 +1,48 @@
+package messagegorm
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/oggyb/insider-assessment/internal/domain/message"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// SaveTemplate inserts t, or replaces the body of the template with the same
+// name in a single INSERT ... ON CONFLICT (name) DO UPDATE.
+func (r *Repository) SaveTemplate(ctx context.Context, t *message.Template) error {
+	return r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "name"}},
+			DoUpdates: clause.AssignmentColumns([]string{"body", "updated_at"}),
+		}).
+		Create(templateFromDomain(t)).Error
+}
+
+// GetTemplate returns a template by ID, or message.ErrTemplateNotFound. It
+// reads from the primary so a just-edited body is what gets sent.
+func (r *Repository) GetTemplate(ctx context.Context, id uuid.UUID) (*message.Template, error) {
+	return r.takeTemplate(r.db.WithContext(ctx).Where("id = ?", id))
+}
+
+// GetTemplateByName returns a template by name, or message.ErrTemplateNotFound.
+func (r *Repository) GetTemplateByName(ctx context.Context, name string) (*message.Template, error) {
+	return r.takeTemplate(r.db.WithContext(ctx).Where("name = ?", name))
+}
+
+func (r *Repository) takeTemplate(query *gorm.DB) (*message.Template, error) {
+	var model TemplateModel
+	err := query.Take(&model).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, message.ErrTemplateNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return templateToDomain(&model), nil
+}
+
+// compile-time interface check
+var _ message.TemplateRepository = (*Repository)(nil)
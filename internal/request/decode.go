@@ -0,0 +1,53 @@
+package request
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// DecodeStrict decodes the JSON body of r into v. Unlike a plain
+// json.Decoder it rejects unknown fields, an empty body and trailing data
+// after the first JSON value. Returned errors are meant to be shown to the
+// client as-is.
+func DecodeStrict(r *http.Request, v any) error {
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(v); err != nil {
+		return describeDecodeError(err)
+	}
+
+	if _, err := dec.Token(); !errors.Is(err, io.EOF) {
+		return errors.New("request body must contain a single JSON value")
+	}
+	return nil
+}
+
+// describeDecodeError turns encoding/json errors into client-facing messages.
+func describeDecodeError(err error) error {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+
+	switch {
+	case errors.Is(err, io.EOF):
+		return errors.New("request body is required")
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return errors.New("invalid JSON body: unexpected end of input")
+	case errors.As(err, &syntaxErr):
+		return fmt.Errorf("invalid JSON body at offset %d", syntaxErr.Offset)
+	case errors.As(err, &typeErr):
+		if typeErr.Field != "" {
+			return fmt.Errorf("field %q must be of type %s", typeErr.Field, typeErr.Type)
+		}
+		return fmt.Errorf("request body must be a JSON %s", typeErr.Type)
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		// encoding/json has no typed error for this case.
+		return fmt.Errorf("unknown field %s", strings.TrimPrefix(err.Error(), "json: unknown field "))
+	default:
+		return err
+	}
+}
@@ -0,0 +1,55 @@
+package request
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func decodeBody(t *testing.T, body string, v any) error {
+	t.Helper()
+
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	return DecodeStrict(r, v)
+}
+
+func TestDecodeStrict_ValidBody(t *testing.T) {
+	var req CreateMessageRequest
+	if err := decodeBody(t, `{"to":"+905000000000","content":"hi","ttl":"15m"}`+"\n", &req); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if req.To != "+905000000000" || req.Content != "hi" || req.TTL != "15m" {
+		t.Fatalf("unexpected decode result %+v", req)
+	}
+}
+
+func TestDecodeStrict_Rejects(t *testing.T) {
+	cases := []struct {
+		name string
+		body string
+		v    any
+		want string
+	}{
+		{"unknown field", `{"to":"x","content":"y","priority":1}`, &CreateMessageRequest{}, `unknown field "priority"`},
+		{"unknown scheduler field", `{"action":"start","force":true}`, &SchedulerRequest{}, `unknown field "force"`},
+		{"trailing object", `{"to":"x"}{"to":"y"}`, &CreateMessageRequest{}, "single JSON value"},
+		{"trailing garbage", `{"to":"x"} garbage`, &CreateMessageRequest{}, "single JSON value"},
+		{"empty body", ``, &CreateMessageRequest{}, "request body is required"},
+		{"truncated", `{"to":"x"`, &CreateMessageRequest{}, "unexpected end of input"},
+		{"syntax error", `{"to":}`, &CreateMessageRequest{}, "invalid JSON body at offset"},
+		{"wrong type", `{"to":5}`, &CreateMessageRequest{}, `field "to" must be of type string`},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := decodeBody(t, tc.body, tc.v)
+			if err == nil {
+				t.Fatalf("expected an error")
+			}
+			if !strings.Contains(err.Error(), tc.want) {
+				t.Fatalf("expected error containing %q, got %q", tc.want, err.Error())
+			}
+		})
+	}
+}
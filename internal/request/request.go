@@ -1,14 +1,158 @@
 package request
 
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	ActionStart = "start"
+	ActionStop  = "stop"
+)
+
+// SchedulerActions lists the actions accepted by SchedulerRequest, in the
+// order they are presented to clients in error messages.
+var SchedulerActions = []string{ActionStart, ActionStop}
+
 // SchedulerRequest represents the JSON body for scheduler control.
 type SchedulerRequest struct {
 	// Action controls the scheduler. Allowed values:
 	// - "start": start processing batches
 	// - "stop":  stop processing batches
 	Action string `json:"action"`
+
+	// AllowNumeric, set before decoding, coerces numeric actions
+	// (1 => "start", 0 => "stop"). It is not part of the body.
+	AllowNumeric bool `json:"-"`
+}
+
+// UnmarshalJSON normalizes the action so clients sending "START" or
+// " stop " are accepted, and coerces numeric actions if AllowNumeric is set.
+// Unknown actions are not rejected here; use Validate for that so the
+// caller can return a precise message. Unknown fields are always rejected,
+// since a custom unmarshaler does not inherit DisallowUnknownFields.
+func (r *SchedulerRequest) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Action json.RawMessage `json:"action"`
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&raw); err != nil {
+		return err
+	}
+
+	action := bytes.TrimSpace(raw.Action)
+	if len(action) == 0 || bytes.Equal(action, []byte("null")) {
+		r.Action = ""
+		return nil
+	}
+
+	var s string
+	if err := json.Unmarshal(action, &s); err == nil {
+		r.Action = strings.ToLower(strings.TrimSpace(s))
+		return nil
+	}
+
+	var n json.Number
+	if err := json.Unmarshal(action, &n); err != nil {
+		return fmt.Errorf("action must be a string")
+	}
+	if !r.AllowNumeric {
+		return fmt.Errorf("action must be a string, one of: %s", strings.Join(SchedulerActions, ", "))
+	}
+
+	switch n.String() {
+	case "1":
+		r.Action = ActionStart
+	case "0":
+		r.Action = ActionStop
+	default:
+		return fmt.Errorf("numeric action must be 1 (start) or 0 (stop)")
+	}
+	return nil
+}
+
+// Validate checks that the action is one of SchedulerActions.
+func (r SchedulerRequest) Validate() error {
+	for _, a := range SchedulerActions {
+		if r.Action == a {
+			return nil
+		}
+	}
+	return fmt.Errorf("action must be one of: %s", strings.Join(SchedulerActions, ", "))
+}
+
+// CreateMessageRequest represents the JSON body for creating a message.
+type CreateMessageRequest struct {
+	To      string `json:"to"`
+	Content string `json:"content"`
+
+	// ExpiresAt is an optional absolute expiry (RFC3339).
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+	// TTL is an optional relative expiry as a Go duration (e.g. "15m").
+	// Only one of ExpiresAt and TTL may be set.
+	TTL string `json:"ttl,omitempty"`
+
+	// SendTimeout optionally overrides the per-message send timeout as a Go
+	// duration of at least 1ms (e.g. "2s"), for messages that should fail
+	// fast.
+	SendTimeout string `json:"sendTimeout,omitempty"`
+
+	// Provider optionally routes the message to a named SMS provider.
+	Provider string `json:"provider,omitempty"`
+
+	// Encoding optionally overrides the encoding hint sent to the provider
+	// ("GSM7" or "UCS2"); by default it is derived from the content.
+	Encoding string `json:"encoding,omitempty"`
+
+	// Tags are optional labels (e.g. {"env": "staging"}) for later filtering.
+	Tags map[string]string `json:"tags,omitempty"`
+}
+
+// MulticastRequest represents the JSON body for sending the same message to
+// several recipients. The optional fields apply to every recipient as in
+// CreateMessageRequest.
+type MulticastRequest struct {
+	To      []string `json:"to"`
+	Content string   `json:"content"`
+
+	ExpiresAt   *time.Time        `json:"expiresAt,omitempty"`
+	TTL         string            `json:"ttl,omitempty"`
+	SendTimeout string            `json:"sendTimeout,omitempty"`
+	Provider    string            `json:"provider,omitempty"`
+	Encoding    string            `json:"encoding,omitempty"`
+	Tags        map[string]string `json:"tags,omitempty"`
+}
+
+// Message returns the create request for a single recipient.
+func (r MulticastRequest) Message(to string) CreateMessageRequest {
+	return CreateMessageRequest{
+		To:          to,
+		Content:     r.Content,
+		ExpiresAt:   r.ExpiresAt,
+		TTL:         r.TTL,
+		SendTimeout: r.SendTimeout,
+		Provider:    r.Provider,
+		Encoding:    r.Encoding,
+		Tags:        r.Tags,
+	}
+}
+
+// WorkerConfigRequest is a partial update of the batch processor settings.
+// Omitted fields are left unchanged.
+type WorkerConfigRequest struct {
+	BatchSize  *int `json:"batchSize,omitempty"`
+	MaxWorkers *int `json:"maxWorkers,omitempty"`
+	// PerMessageTimeout is a Go duration string (e.g. "5s").
+	PerMessageTimeout *string `json:"perMessageTimeout,omitempty"`
 }
 
 type WebhookRequest struct {
 	To      string `json:"to"`
 	Content string `json:"content"`
+	// Encoding is the data coding hint, GSM7 or UCS2.
+	Encoding string `json:"encoding"`
 }
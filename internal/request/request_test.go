@@ -0,0 +1,76 @@
+package request
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func decodeScheduler(t *testing.T, body string) (SchedulerRequest, error) {
+	t.Helper()
+	return decodeSchedulerWith(t, body, false)
+}
+
+func decodeSchedulerWith(t *testing.T, body string, allowNumeric bool) (SchedulerRequest, error) {
+	t.Helper()
+
+	req := SchedulerRequest{AllowNumeric: allowNumeric}
+	err := json.Unmarshal([]byte(body), &req)
+	return req, err
+}
+
+func TestSchedulerRequest_NormalizesAction(t *testing.T) {
+	cases := map[string]string{
+		`{"action":"START"}`:      ActionStart,
+		`{"action":"  Stop \t"}`:  ActionStop,
+		`{"action":"start"}`:      ActionStart,
+		`{"action":"\nSTOP\n  "}`: ActionStop,
+	}
+
+	for body, want := range cases {
+		req, err := decodeScheduler(t, body)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", body, err)
+		}
+		if req.Action != want {
+			t.Fatalf("%s: expected %q, got %q", body, want, req.Action)
+		}
+		if err := req.Validate(); err != nil {
+			t.Fatalf("%s: expected valid action, got %v", body, err)
+		}
+	}
+}
+
+func TestSchedulerRequest_InvalidAction(t *testing.T) {
+	req, err := decodeScheduler(t, `{"action":"restart"}`)
+	if err != nil {
+		t.Fatalf("unexpected decode error: %v", err)
+	}
+
+	err = req.Validate()
+	if err == nil {
+		t.Fatalf("expected invalid action to fail validation")
+	}
+	if want := "action must be one of: start, stop"; err.Error() != want {
+		t.Fatalf("expected %q, got %q", want, err.Error())
+	}
+}
+
+func TestSchedulerRequest_NumericAction(t *testing.T) {
+	if _, err := decodeScheduler(t, `{"action":1}`); err == nil {
+		t.Fatalf("expected numeric action to be rejected by default")
+	}
+
+	req, err := decodeSchedulerWith(t, `{"action":1}`, true)
+	if err != nil || req.Action != ActionStart {
+		t.Fatalf("expected 1 => start, got %q (%v)", req.Action, err)
+	}
+
+	req, err = decodeSchedulerWith(t, `{"action":0}`, true)
+	if err != nil || req.Action != ActionStop {
+		t.Fatalf("expected 0 => stop, got %q (%v)", req.Action, err)
+	}
+
+	if _, err := decodeSchedulerWith(t, `{"action":7}`, true); err == nil {
+		t.Fatalf("expected unsupported numeric action to be rejected")
+	}
+}
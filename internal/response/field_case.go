@@ -0,0 +1,109 @@
+package response
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// FieldCase selects the JSON field naming of message DTOs.
+type FieldCase int
+
+const (
+	// CamelCase renders fields as messageId, createdAt, ... (the default).
+	CamelCase FieldCase = iota
+	// SnakeCase renders fields as message_id, created_at, ...
+	SnakeCase
+)
+
+// WithDefaultFieldCase makes FieldCase fall back to c instead of CamelCase
+// when a request does not ask for a casing.
+func WithDefaultFieldCase(c FieldCase) Option {
+	return func(rp *Responder) {
+		rp.fieldCase = c
+	}
+}
+
+// FieldCaseFor returns the casing registered under name: "camel" (default)
+// or "snake".
+func FieldCaseFor(name string) (FieldCase, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "", "camel":
+		return CamelCase, nil
+	case "snake":
+		return SnakeCase, nil
+	default:
+		return CamelCase, fmt.Errorf("unknown JSON field case %q", name)
+	}
+}
+
+// FieldCase returns the casing a request negotiated through a case
+// parameter on a JSON media range (Accept: application/json; case=snake),
+// falling back to the responder's default.
+func (rp *Responder) FieldCase(r *http.Request) FieldCase {
+	for _, accept := range r.Header.Values("Accept") {
+		for _, part := range strings.Split(accept, ",") {
+			mediaType, params, err := mime.ParseMediaType(part)
+			if err != nil || (mediaType != "application/json" && mediaType != "*/*") {
+				continue
+			}
+			name, ok := params["case"]
+			if !ok {
+				continue
+			}
+			if c, err := FieldCaseFor(name); err == nil {
+				return c
+			}
+		}
+	}
+	return rp.fieldCase
+}
+
+// WithFieldCase returns d rendered with casing c.
+func (d MessageDTO) WithFieldCase(c FieldCase) MessageDTO {
+	d.fieldCase = c
+	return d
+}
+
+// MessagesWithFieldCase renders every DTO in dtos with casing c.
+func MessagesWithFieldCase(dtos []MessageDTO, c FieldCase) []MessageDTO {
+	for i := range dtos {
+		dtos[i].fieldCase = c
+	}
+	return dtos
+}
+
+// snakeMessageDTO mirrors MessageDTO with snake_case field names. It must
+// keep the same fields in the same order so the two convert into each other.
+type snakeMessageDTO struct {
+	ID             string            `json:"id"`
+	To             string            `json:"to"`
+	Content        string            `json:"content"`
+	Status         string            `json:"status"`
+	MessageID      string            `json:"message_id"`
+	SentAt         *time.Time        `json:"sent_at,omitempty"`
+	ExpiresAt      *time.Time        `json:"expires_at,omitempty"`
+	StatusReason   string            `json:"status_reason,omitempty"`
+	Provider       string            `json:"provider,omitempty"`
+	Encoding       string            `json:"encoding,omitempty"`
+	Segments       int               `json:"segments"`
+	RetryCount     int               `json:"retry_count"`
+	Tags           map[string]string `json:"tags,omitempty"`
+	SendDurationMs *int64            `json:"send_duration_ms,omitempty"`
+	CreatedAt      time.Time         `json:"created_at"`
+	UpdatedAt      time.Time         `json:"updated_at"`
+	fieldCase      FieldCase
+}
+
+// MarshalJSON renders the DTO in its field casing.
+func (d MessageDTO) MarshalJSON() ([]byte, error) {
+	if d.fieldCase == SnakeCase {
+		return json.Marshal(snakeMessageDTO(d))
+	}
+	// The local type drops this method so the default encoding is used.
+	type camelMessageDTO MessageDTO
+	return json.Marshal(camelMessageDTO(d))
+}
@@ -4,10 +4,92 @@ package response
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 )
 
+// TimeFormatter renders the envelope timestamp.
+type TimeFormatter func(t time.Time) string
+
+// formatRFC3339 is the default TimeFormatter.
+func formatRFC3339(t time.Time) string {
+	return t.Format(time.RFC3339)
+}
+
+// RequestIDHeader is the header carrying the request ID. It is set on the
+// response by middleware.RequestID and echoed in error envelopes.
+const RequestIDHeader = "X-Request-ID"
+
+// Responder writes API responses in the envelope format configured by its
+// options. It is safe for concurrent use; build one with NewResponder.
+type Responder struct {
+	// clock stamps envelopes; it is time.Now outside tests.
+	clock         func() time.Time
+	timeFormatter TimeFormatter
+	fieldCase     FieldCase
+	requestID     bool
+	sendDuration  bool
+}
+
+// Option customizes a Responder.
+type Option func(*Responder)
+
+// WithTimeFormatter renders envelope timestamps with f instead of RFC3339.
+// A nil formatter is ignored.
+func WithTimeFormatter(f TimeFormatter) Option {
+	return func(rp *Responder) {
+		if f != nil {
+			rp.timeFormatter = f
+		}
+	}
+}
+
+// WithRequestID turns the requestId field of error envelopes on or off. It
+// is on by default.
+func WithRequestID(on bool) Option {
+	return func(rp *Responder) {
+		rp.requestID = on
+	}
+}
+
+// WithSendDuration turns the sendDurationMs field of messages built by
+// Message and Messages on or off. It is off by default.
+func WithSendDuration(on bool) Option {
+	return func(rp *Responder) {
+		rp.sendDuration = on
+	}
+}
+
+// NewResponder returns a Responder with the given options applied to the
+// defaults.
+func NewResponder(opts ...Option) *Responder {
+	rp := &Responder{clock: time.Now, timeFormatter: formatRFC3339, requestID: true}
+	for _, opt := range opts {
+		opt(rp)
+	}
+	return rp
+}
+
+// TimeFormatterFor returns the formatter registered under name:
+// "rfc3339" (default), "rfc3339nano", "unix" or "unixmilli".
+func TimeFormatterFor(name string) (TimeFormatter, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "", "rfc3339":
+		return formatRFC3339, nil
+	case "rfc3339nano":
+		return func(t time.Time) string { return t.Format(time.RFC3339Nano) }, nil
+	case "unix":
+		return func(t time.Time) string { return strconv.FormatInt(t.Unix(), 10) }, nil
+	case "unixmilli":
+		return func(t time.Time) string { return strconv.FormatInt(t.UnixMilli(), 10) }, nil
+	default:
+		return nil, fmt.Errorf("unknown response time format %q", name)
+	}
+}
+
 // JSONResponse is the common response envelope for all API endpoints.
 type JSONResponse struct {
 	Success   bool        `json:"success"`
@@ -20,27 +102,36 @@ type JSONResponse struct {
 type ErrorBody struct {
 	Code    int    `json:"code"`
 	Message string `json:"message"`
+	// RequestID identifies the failed request for support, when the
+	// request passed through middleware.RequestID.
+	RequestID string `json:"requestId,omitempty"`
 }
 
-// RespondJSON writes a successful JSON response with the given status code and payload.
-func RespondJSON(w http.ResponseWriter, status int, payload interface{}) {
+// JSON writes a successful JSON response with the given status code and payload.
+func (rp *Responder) JSON(w http.ResponseWriter, status int, payload interface{}) {
 	resp := JSONResponse{
 		Success:   true,
 		Data:      payload,
-		Timestamp: time.Now().Format(time.RFC3339),
+		Timestamp: rp.timeFormatter(rp.clock()),
 	}
 	writeJSON(w, status, resp)
 }
 
-// RespondError writes an error JSON response with the given status code and message.
-func RespondError(w http.ResponseWriter, status int, msg string) {
+// Error writes an error JSON response with the given status code and message.
+// The request ID already set on w's X-Request-ID header, if any, is included.
+func (rp *Responder) Error(w http.ResponseWriter, status int, msg string) {
+	body := &ErrorBody{
+		Code:    status,
+		Message: msg,
+	}
+	if rp.requestID {
+		body.RequestID = w.Header().Get(RequestIDHeader)
+	}
+
 	resp := JSONResponse{
-		Success: false,
-		Error: &ErrorBody{
-			Code:    status,
-			Message: msg,
-		},
-		Timestamp: time.Now().Format(time.RFC3339),
+		Success:   false,
+		Error:     body,
+		Timestamp: rp.timeFormatter(rp.clock()),
 	}
 	writeJSON(w, status, resp)
 }
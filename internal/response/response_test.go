@@ -0,0 +1,198 @@
+package response
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	domain "github.com/oggyb/insider-assessment/internal/domain/message"
+)
+
+func TestTimeFormatterFor(t *testing.T) {
+	ts := time.Date(2024, 5, 6, 7, 8, 9, 123456789, time.UTC)
+
+	cases := map[string]string{
+		"":            "2024-05-06T07:08:09Z",
+		"rfc3339":     "2024-05-06T07:08:09Z",
+		"RFC3339Nano": "2024-05-06T07:08:09.123456789Z",
+		"unix":        "1714979289",
+		"unixmilli":   "1714979289123",
+	}
+
+	for name, want := range cases {
+		f, err := TimeFormatterFor(name)
+		if err != nil {
+			t.Fatalf("%q: unexpected error: %v", name, err)
+		}
+		if got := f(ts); got != want {
+			t.Fatalf("%q: expected %q, got %q", name, want, got)
+		}
+	}
+
+	if _, err := TimeFormatterFor("iso8601-ish"); err == nil {
+		t.Fatalf("expected an error for an unknown format")
+	}
+}
+
+func TestResponder_UsesConfiguredTimeFormat(t *testing.T) {
+	clock := func() time.Time { return time.UnixMilli(1714979289123).UTC() }
+	f, _ := TimeFormatterFor("unixmilli")
+	rp := NewResponder(WithTimeFormatter(f))
+	rp.clock = clock
+
+	for _, write := range []func(http.ResponseWriter){
+		func(w http.ResponseWriter) { rp.JSON(w, http.StatusOK, "ok") },
+		func(w http.ResponseWriter) { rp.Error(w, http.StatusBadRequest, "bad") },
+	} {
+		rec := httptest.NewRecorder()
+		write(rec)
+
+		var env JSONResponse
+		if err := json.NewDecoder(rec.Body).Decode(&env); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		if env.Timestamp != "1714979289123" {
+			t.Fatalf("expected unixmilli timestamp, got %q", env.Timestamp)
+		}
+	}
+
+	rp = NewResponder(WithTimeFormatter(nil))
+	rp.clock = clock
+	rec := httptest.NewRecorder()
+	rp.JSON(rec, http.StatusOK, "ok")
+	if !strings.Contains(rec.Body.String(), `"timestamp":"2024-05-06T07:08:09Z"`) {
+		t.Fatalf("expected the RFC3339 default, got %s", rec.Body.String())
+	}
+}
+
+func TestMessageDTO_FieldCasing(t *testing.T) {
+	sentAt := time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC)
+	dto := MessageDTO{ID: "id-1", MessageID: "ext-1", SentAt: &sentAt, RetryCount: 2, CreatedAt: sentAt}
+
+	cases := map[FieldCase][]string{
+		CamelCase: {"messageId", "sentAt", "retryCount", "createdAt", "updatedAt"},
+		SnakeCase: {"message_id", "sent_at", "retry_count", "created_at", "updated_at"},
+	}
+	for c, keys := range cases {
+		raw, err := json.Marshal(dto.WithFieldCase(c))
+		if err != nil {
+			t.Fatalf("case %d: marshal: %v", c, err)
+		}
+
+		var got map[string]any
+		if err := json.Unmarshal(raw, &got); err != nil {
+			t.Fatalf("case %d: unmarshal: %v", c, err)
+		}
+		if len(got) != len(keys)+5 || got["id"] != "id-1" || got["to"] != "" {
+			t.Fatalf("case %d: unexpected fields %v", c, got)
+		}
+		for _, k := range keys {
+			if _, ok := got[k]; !ok {
+				t.Fatalf("case %d: expected field %q in %s", c, k, raw)
+			}
+		}
+		if got[keys[0]] != "ext-1" {
+			t.Fatalf("case %d: expected %s=ext-1, got %v", c, keys[0], got[keys[0]])
+		}
+	}
+}
+
+func TestResponder_FieldCase(t *testing.T) {
+	rp := NewResponder()
+
+	cases := map[string]FieldCase{
+		"":                                       CamelCase,
+		"application/json":                       CamelCase,
+		"application/json; case=snake":           SnakeCase,
+		"text/html, application/json;case=SNAKE": SnakeCase,
+		"text/plain; case=snake":                 CamelCase,
+		"application/json; case=kebab":           CamelCase,
+	}
+	for accept, want := range cases {
+		req := httptest.NewRequest(http.MethodGet, "/messages", nil)
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		if got := rp.FieldCase(req); got != want {
+			t.Fatalf("Accept %q: expected %d, got %d", accept, want, got)
+		}
+	}
+
+	// Without a case parameter the configured default applies.
+	rp = NewResponder(WithDefaultFieldCase(SnakeCase))
+	req := httptest.NewRequest(http.MethodGet, "/messages", nil)
+	req.Header.Set("Accept", "application/json")
+	if got := rp.FieldCase(req); got != SnakeCase {
+		t.Fatalf("expected the configured default, got %d", got)
+	}
+	req.Header.Set("Accept", "application/json; case=camel")
+	if got := rp.FieldCase(req); got != CamelCase {
+		t.Fatalf("expected the request to override the default, got %d", got)
+	}
+
+	if _, err := FieldCaseFor("kebab"); err == nil {
+		t.Fatalf("expected an error for an unknown case")
+	}
+}
+
+func TestResponderError_RequestID(t *testing.T) {
+	rec := httptest.NewRecorder()
+	rec.Header().Set(RequestIDHeader, "req-1")
+	NewResponder().Error(rec, http.StatusBadRequest, "bad")
+	if !strings.Contains(rec.Body.String(), `"requestId":"req-1"`) {
+		t.Fatalf("expected the request ID in the envelope, got %s", rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	NewResponder().Error(rec, http.StatusBadRequest, "bad")
+	if strings.Contains(rec.Body.String(), "requestId") {
+		t.Fatalf("expected no request ID without the middleware, got %s", rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	rec.Header().Set(RequestIDHeader, "req-1")
+	NewResponder(WithRequestID(false)).Error(rec, http.StatusBadRequest, "bad")
+	if strings.Contains(rec.Body.String(), "requestId") {
+		t.Fatalf("expected no request ID when disabled, got %s", rec.Body.String())
+	}
+}
+
+func TestResponderMessage_SendDuration(t *testing.T) {
+	d := 1500 * time.Millisecond
+	m := &domain.Message{SendDuration: &d}
+
+	if dto := NewResponder().Message(m); dto.SendDurationMs != nil {
+		t.Fatalf("expected no send duration by default, got %d", *dto.SendDurationMs)
+	}
+	if dto := FromDomainMessage(m); dto.SendDurationMs != nil {
+		t.Fatalf("expected FromDomainMessage to leave out the send duration, got %d", *dto.SendDurationMs)
+	}
+
+	rp := NewResponder(WithSendDuration(true))
+	raw, err := json.Marshal(rp.Message(m).WithFieldCase(SnakeCase))
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	if !strings.Contains(string(raw), `"send_duration_ms":1500`) {
+		t.Fatalf("expected send_duration_ms=1500, got %s", raw)
+	}
+	if dto := rp.Message(&domain.Message{}); dto.SendDurationMs != nil {
+		t.Fatalf("expected no send duration for an unsent message, got %d", *dto.SendDurationMs)
+	}
+}
+
+func TestFromDomainMessage_Segments(t *testing.T) {
+	cases := map[string]int{
+		"hello":                  1,
+		strings.Repeat("a", 161): 2,
+		strings.Repeat("ş", 71):  2, // UCS-2: 70 per single part
+	}
+	for content, want := range cases {
+		if got := FromDomainMessage(&domain.Message{Content: content}).Segments; got != want {
+			t.Errorf("%d characters: segments = %d, want %d", len([]rune(content)), got, want)
+		}
+	}
+}
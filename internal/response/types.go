@@ -37,7 +37,9 @@ type PingResponse struct {
 }
 
 type SchedulerControlPayload struct {
-	Message string `json:"message"`
+	Message    string `json:"message"`
+	WasRunning bool   `json:"wasRunning"`
+	Running    bool   `json:"running"`
 }
 
 type SchedulerControlResponse struct {
@@ -46,27 +48,72 @@ type SchedulerControlResponse struct {
 	Timestamp string                  `json:"timestamp"`
 }
 
+type WorkerConfigPayload struct {
+	BatchSize         int    `json:"batchSize"`
+	MaxWorkers        int    `json:"maxWorkers"`
+	PerMessageTimeout string `json:"perMessageTimeout"`
+}
+
+type WorkerConfigResponse struct {
+	Success   bool                `json:"success"`
+	Data      WorkerConfigPayload `json:"data"`
+	Timestamp string              `json:"timestamp"`
+}
+
 // MessageDTO is a public-facing representation of a message
 // used in API responses. It decouples the wire format from
-// the domain entity and plays nicely with Swagger.
+// the domain entity and plays nicely with Swagger. Field names
+// are camelCase unless WithFieldCase selects snake_case.
 type MessageDTO struct {
-	ID        string     `json:"id"`
-	To        string     `json:"to"`
-	Content   string     `json:"content"`
-	Status    string     `json:"status"`
-	MessageID string     `json:"messageId"`
-	SentAt    *time.Time `json:"sentAt,omitempty"`
-	CreatedAt time.Time  `json:"createdAt"`
-	UpdatedAt time.Time  `json:"updatedAt"`
+	ID           string     `json:"id"`
+	To           string     `json:"to"`
+	Content      string     `json:"content"`
+	Status       string     `json:"status"`
+	MessageID    string     `json:"messageId"`
+	SentAt       *time.Time `json:"sentAt,omitempty"`
+	ExpiresAt    *time.Time `json:"expiresAt,omitempty"`
+	StatusReason string     `json:"statusReason,omitempty"`
+	Provider     string     `json:"provider,omitempty"`
+	Encoding     string     `json:"encoding,omitempty"`
+	// Segments is how many SMS parts the content is sent as.
+	Segments   int               `json:"segments"`
+	RetryCount int               `json:"retryCount"`
+	Tags       map[string]string `json:"tags,omitempty"`
+	// SendDurationMs is the provider round-trip time of the last send,
+	// present only with RESPONSE_SEND_DURATION enabled.
+	SendDurationMs *int64    `json:"sendDurationMs,omitempty"`
+	CreatedAt      time.Time `json:"createdAt"`
+	UpdatedAt      time.Time `json:"updatedAt"`
+	fieldCase      FieldCase
+}
+
+type MessageResponse struct {
+	Success   bool       `json:"success"`
+	Data      MessageDTO `json:"data"`
+	Timestamp string     `json:"timestamp"`
 }
 
 type SentMessagesPayload struct {
+	Items      []MessageDTO `json:"items"`
+	Total      int64        `json:"total"`
+	Page       int          `json:"page"`
+	Limit      int          `json:"limit"`
+	OutOfRange bool         `json:"outOfRange"`
+}
+
+type MessageListPayload struct {
 	Items []MessageDTO `json:"items"`
 	Total int64        `json:"total"`
 	Page  int          `json:"page"`
 	Limit int          `json:"limit"`
 }
 
+type MessageListResponse struct {
+	Success   bool               `json:"success"`
+	Data      MessageListPayload `json:"data"`
+	Timestamp string             `json:"timestamp"`
+}
+
 type SentMessagesResponse struct {
 	Success   bool                `json:"success"`
 	Data      SentMessagesPayload `json:"data"`
@@ -78,20 +125,291 @@ type SentMessagesResponse struct {
 func FromDomainMessages(msgs []*domain.Message) []MessageDTO {
 	out := make([]MessageDTO, len(msgs))
 	for i, m := range msgs {
-		out[i] = MessageDTO{
-			ID:        m.ID.String(),
-			To:        m.To,
-			Content:   m.Content,
-			Status:    string(m.Status),
-			MessageID: m.MessageID,
-			SentAt:    m.SentAt,
-			CreatedAt: m.CreatedAt,
-			UpdatedAt: m.UpdatedAt,
+		out[i] = FromDomainMessage(m)
+	}
+	return out
+}
+
+// FromDomainMessage converts a single domain message into a DTO, without
+// its send duration (see Responder.Message).
+func FromDomainMessage(m *domain.Message) MessageDTO {
+	return MessageDTO{
+		ID:           m.ID.String(),
+		To:           m.To,
+		Content:      m.Content,
+		Status:       string(m.Status),
+		MessageID:    m.MessageID,
+		SentAt:       m.SentAt,
+		ExpiresAt:    m.ExpiresAt,
+		StatusReason: m.StatusReason,
+		Provider:     m.Provider,
+		Encoding:     string(m.Encoding),
+		Segments:     domain.Segments(m.Content),
+		RetryCount:   m.RetryCount,
+		Tags:         m.Tags,
+		CreatedAt:    m.CreatedAt,
+		UpdatedAt:    m.UpdatedAt,
+	}
+}
+
+// Message converts m into a DTO like FromDomainMessage, adding its send
+// duration if the responder includes it.
+func (rp *Responder) Message(m *domain.Message) MessageDTO {
+	dto := FromDomainMessage(m)
+	if rp.sendDuration && m.SendDuration != nil {
+		ms := m.SendDuration.Milliseconds()
+		dto.SendDurationMs = &ms
+	}
+	return dto
+}
+
+// Messages converts msgs into DTOs with Message.
+func (rp *Responder) Messages(msgs []*domain.Message) []MessageDTO {
+	out := make([]MessageDTO, len(msgs))
+	for i, m := range msgs {
+		out[i] = rp.Message(m)
+	}
+	return out
+}
+
+// StatusEventDTO is a single status transition in a message timeline.
+type StatusEventDTO struct {
+	From string    `json:"from,omitempty"`
+	To   string    `json:"to"`
+	At   time.Time `json:"at"`
+}
+
+type TimelinePayload struct {
+	MessageID string           `json:"messageId"`
+	Events    []StatusEventDTO `json:"events"`
+}
+
+type TimelineResponse struct {
+	Success   bool            `json:"success"`
+	Data      TimelinePayload `json:"data"`
+	Timestamp string          `json:"timestamp"`
+}
+
+// FromDomainStatusEvents converts status transitions into DTOs.
+func FromDomainStatusEvents(events []*domain.StatusEvent) []StatusEventDTO {
+	out := make([]StatusEventDTO, len(events))
+	for i, e := range events {
+		out[i] = StatusEventDTO{
+			From: string(e.From),
+			To:   string(e.To),
+			At:   e.At,
+		}
+	}
+	return out
+}
+
+// DayStatDTO holds the counts of a single UTC day in a report.
+type DayStatDTO struct {
+	Day    string `json:"day"`
+	Sent   int64  `json:"sent"`
+	Failed int64  `json:"failed"`
+}
+
+type ReportPayload struct {
+	From        string       `json:"from"`
+	Until       string       `json:"until"`
+	TotalSent   int64        `json:"totalSent"`
+	TotalFailed int64        `json:"totalFailed"`
+	Days        []DayStatDTO `json:"days"`
+}
+
+type ReportResponse struct {
+	Success   bool          `json:"success"`
+	Data      ReportPayload `json:"data"`
+	Timestamp string        `json:"timestamp"`
+}
+
+// LatencyPayload holds provider call latency over a time window.
+type LatencyPayload struct {
+	From  time.Time `json:"from"`
+	Until time.Time `json:"until"`
+	AvgMs float64   `json:"avgMs"`
+	P95Ms float64   `json:"p95Ms"`
+}
+
+type LatencyResponse struct {
+	Success   bool           `json:"success"`
+	Data      LatencyPayload `json:"data"`
+	Timestamp string         `json:"timestamp"`
+}
+
+// RecipientCountDTO is one recipient's message count.
+type RecipientCountDTO struct {
+	To    string `json:"to"`
+	Count int64  `json:"count"`
+}
+
+// TopRecipientsPayload ranks the recipients with the most messages in a
+// time window.
+type TopRecipientsPayload struct {
+	From       time.Time           `json:"from"`
+	Until      time.Time           `json:"until"`
+	Recipients []RecipientCountDTO `json:"recipients"`
+}
+
+type TopRecipientsResponse struct {
+	Success   bool                 `json:"success"`
+	Data      TopRecipientsPayload `json:"data"`
+	Timestamp string               `json:"timestamp"`
+}
+
+// PendingAgePayload reports how long the oldest pending message has waited.
+type PendingAgePayload struct {
+	OldestPendingAgeSeconds float64 `json:"oldestPendingAgeSeconds"`
+	// AlertThresholdSeconds is 0 when no alert is configured.
+	AlertThresholdSeconds float64 `json:"alertThresholdSeconds"`
+	Healthy               bool    `json:"healthy"`
+}
+
+type PendingAgeResponse struct {
+	Success   bool              `json:"success"`
+	Data      PendingAgePayload `json:"data"`
+	Timestamp string            `json:"timestamp"`
+}
+
+// DedupClearPayload reports how many unsent messages of a recipient had
+// their de-duplication claim released.
+type DedupClearPayload struct {
+	To      string `json:"to"`
+	Cleared int64  `json:"cleared"`
+}
+
+type DedupClearResponse struct {
+	Success   bool              `json:"success"`
+	Data      DedupClearPayload `json:"data"`
+	Timestamp string            `json:"timestamp"`
+}
+
+// OptOutPayload confirms that the recipient behind an opt-out link was
+// unsubscribed. The number itself is not echoed back.
+type OptOutPayload struct {
+	Message    string    `json:"message"`
+	OptedOutAt time.Time `json:"optedOutAt"`
+}
+
+type OptOutResponse struct {
+	Success   bool          `json:"success"`
+	Data      OptOutPayload `json:"data"`
+	Timestamp string        `json:"timestamp"`
+}
+
+// GroupStatusPayload is the creation progress of a multicast group. Its
+// messages carry the tag group=<id>.
+type GroupStatusPayload struct {
+	ID         string     `json:"id"`
+	Total      int        `json:"total"`
+	Created    int        `json:"created"`
+	Failed     int        `json:"failed"`
+	Done       bool       `json:"done"`
+	StartedAt  time.Time  `json:"startedAt"`
+	FinishedAt *time.Time `json:"finishedAt,omitempty"`
+}
+
+type GroupStatusResponse struct {
+	Success   bool               `json:"success"`
+	Data      GroupStatusPayload `json:"data"`
+	Timestamp string             `json:"timestamp"`
+}
+
+// MulticastPayload is a multicast group created synchronously, with the
+// messages that were saved.
+type MulticastPayload struct {
+	Group    GroupStatusPayload `json:"group"`
+	Messages []MessageDTO       `json:"messages"`
+}
+
+type MulticastResponse struct {
+	Success   bool             `json:"success"`
+	Data      MulticastPayload `json:"data"`
+	Timestamp string           `json:"timestamp"`
+}
+
+// BatchRunDTO is a single recorded scheduler batch run.
+type BatchRunDTO struct {
+	ID         string    `json:"id"`
+	StartedAt  time.Time `json:"startedAt"`
+	DurationMs int64     `json:"durationMs"`
+	Processed  int       `json:"processed"`
+	Succeeded  int       `json:"succeeded"`
+	Failed     int       `json:"failed"`
+	Workers    int       `json:"workers"`
+	Error      string    `json:"error,omitempty"`
+}
+
+type BatchRunListPayload struct {
+	Items []BatchRunDTO `json:"items"`
+	Total int64         `json:"total"`
+	Page  int           `json:"page"`
+	Limit int           `json:"limit"`
+}
+
+type BatchRunListResponse struct {
+	Success   bool                `json:"success"`
+	Data      BatchRunListPayload `json:"data"`
+	Timestamp string              `json:"timestamp"`
+}
+
+// FromDomainBatchRuns converts recorded batch runs into DTOs.
+func FromDomainBatchRuns(runs []*domain.BatchRun) []BatchRunDTO {
+	out := make([]BatchRunDTO, len(runs))
+	for i, r := range runs {
+		out[i] = BatchRunDTO{
+			ID:         r.ID.String(),
+			StartedAt:  r.StartedAt,
+			DurationMs: r.Duration.Milliseconds(),
+			Processed:  r.Processed,
+			Succeeded:  r.Succeeded,
+			Failed:     r.Failed,
+			Workers:    r.Workers,
+			Error:      r.Error,
 		}
 	}
 	return out
 }
 
+// SchedulerStatusPayload is the state of this process's scheduler.
+// InstanceID is only set with leader election.
+type SchedulerStatusPayload struct {
+	Running        bool   `json:"running"`
+	LeaderElection bool   `json:"leaderElection"`
+	Leader         bool   `json:"leader"`
+	InstanceID     string `json:"instanceId,omitempty"`
+}
+
+type SchedulerStatusResponse struct {
+	Success   bool                   `json:"success"`
+	Data      SchedulerStatusPayload `json:"data"`
+	Timestamp string                 `json:"timestamp"`
+}
+
+// SchedulerEventDTO is one entry of the scheduler's recent activity. The
+// counts and duration are only set for batch events.
+type SchedulerEventDTO struct {
+	At         time.Time `json:"at"`
+	Kind       string    `json:"kind"`
+	Message    string    `json:"message"`
+	Processed  *int      `json:"processed,omitempty"`
+	Succeeded  *int      `json:"succeeded,omitempty"`
+	Failed     *int      `json:"failed,omitempty"`
+	DurationMs *int64    `json:"durationMs,omitempty"`
+	Error      string    `json:"error,omitempty"`
+}
+
+type SchedulerEventListPayload struct {
+	Items []SchedulerEventDTO `json:"items"`
+}
+
+type SchedulerEventListResponse struct {
+	Success   bool                      `json:"success"`
+	Data      SchedulerEventListPayload `json:"data"`
+	Timestamp string                    `json:"timestamp"`
+}
+
 type WebhookResponse struct {
 	Message   string `json:"message"`
 	MessageID string `json:"messageId"`
@@ -0,0 +1,38 @@
+// Package retry runs an operation until it succeeds or runs out of attempts.
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/oggyb/insider-assessment/internal/timeutil"
+)
+
+// ErrNoAttempts is returned by Do when asked to make no attempts at all.
+var ErrNoAttempts = errors.New("retry: attempts must be positive")
+
+// Do calls fn up to attempts times, waiting backoff between calls, until fn
+// returns nil. fn receives the 1-based attempt number. Do gives up early
+// when ctx ends, returning ctx's error joined with fn's last error;
+// otherwise it returns fn's last error once attempts are exhausted.
+func Do(ctx context.Context, attempts int, backoff time.Duration, fn func(attempt int) error) error {
+	if attempts <= 0 {
+		return ErrNoAttempts
+	}
+
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		if err = fn(attempt); err == nil {
+			return nil
+		}
+		if attempt == attempts {
+			break
+		}
+		if !timeutil.SleepCtx(ctx, backoff) {
+			return errors.Join(ctx.Err(), err)
+		}
+	}
+	return fmt.Errorf("after %d attempts: %w", attempts, err)
+}
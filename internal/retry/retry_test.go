@@ -0,0 +1,64 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+var errTransient = errors.New("transient")
+
+func TestDo_StopsAtFirstSuccess(t *testing.T) {
+	calls := 0
+	err := Do(context.Background(), 5, time.Millisecond, func(attempt int) error {
+		calls++
+		if attempt < 3 {
+			return errTransient
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Do: %v", err)
+	}
+	if calls != 3 {
+		t.Fatalf("expected 3 calls, got %d", calls)
+	}
+}
+
+func TestDo_ReturnsLastErrorWhenExhausted(t *testing.T) {
+	calls := 0
+	err := Do(context.Background(), 3, time.Millisecond, func(int) error {
+		calls++
+		return errTransient
+	})
+	if !errors.Is(err, errTransient) {
+		t.Fatalf("expected the last error, got %v", err)
+	}
+	if calls != 3 {
+		t.Fatalf("expected 3 calls, got %d", calls)
+	}
+}
+
+func TestDo_GivesUpWhenContextEnds(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := 0
+	start := time.Now()
+	err := Do(ctx, 10, time.Hour, func(int) error {
+		calls++
+		cancel()
+		return errTransient
+	})
+	if !errors.Is(err, context.Canceled) || !errors.Is(err, errTransient) {
+		t.Fatalf("expected the cancellation and the last error, got %v", err)
+	}
+	if calls != 1 || time.Since(start) > time.Second {
+		t.Fatalf("expected to stop right away, got %d calls in %v", calls, time.Since(start))
+	}
+}
+
+func TestDo_RejectsNoAttempts(t *testing.T) {
+	if err := Do(context.Background(), 0, 0, func(int) error { return nil }); !errors.Is(err, ErrNoAttempts) {
+		t.Fatalf("expected ErrNoAttempts, got %v", err)
+	}
+}
@@ -10,30 +10,100 @@ import (
 type AppDeps struct {
 	Home    HomeHandler
 	Message MessageHandler
+	Config  ConfigHandler
+	Stats   StatsHandler
+
+	// AdminAuth guards operator-only routes.
+	AdminAuth func(http.Handler) http.Handler
+
+	// Metrics, when set, serves Prometheus metrics on GET /metrics.
+	Metrics http.Handler
+
+	// Responder writes the fallback 404 and the errors of server-wide
+	// middleware; nil uses response.NewResponder().
+	Responder *response.Responder
 }
 
 type HomeHandler interface {
 	Index(w http.ResponseWriter, r *http.Request)
 	Health(w http.ResponseWriter, r *http.Request)
+	Ready(w http.ResponseWriter, r *http.Request)
 }
 
 type MessageHandler interface {
+	CreateMessage(w http.ResponseWriter, r *http.Request)
+	Multicast(w http.ResponseWriter, r *http.Request)
+	GetGroupStatus(w http.ResponseWriter, r *http.Request)
+	ListMessages(w http.ResponseWriter, r *http.Request)
+	ExportMessages(w http.ResponseWriter, r *http.Request)
 	GetSentMessages(w http.ResponseWriter, r *http.Request)
+	GetMessageTimeline(w http.ResponseWriter, r *http.Request)
+	RetryMessage(w http.ResponseWriter, r *http.Request)
+	DeleteMessage(w http.ResponseWriter, r *http.Request)
+	ClearDedup(w http.ResponseWriter, r *http.Request)
+	ResolveOptOut(w http.ResponseWriter, r *http.Request)
+	FollowLink(w http.ResponseWriter, r *http.Request)
 	StartStopScheduler(w http.ResponseWriter, r *http.Request)
+	ListSchedulerRuns(w http.ResponseWriter, r *http.Request)
+	GetSchedulerEvents(w http.ResponseWriter, r *http.Request)
+	GetSchedulerStatus(w http.ResponseWriter, r *http.Request)
+}
+
+type StatsHandler interface {
+	GetReport(w http.ResponseWriter, r *http.Request)
+	GetLatency(w http.ResponseWriter, r *http.Request)
+	GetTopRecipients(w http.ResponseWriter, r *http.Request)
+	GetPendingAge(w http.ResponseWriter, r *http.Request)
+}
+
+type ConfigHandler interface {
+	GetWorkerConfig(w http.ResponseWriter, r *http.Request)
+	UpdateWorkerConfig(w http.ResponseWriter, r *http.Request)
 }
 
 func Register(mux *http.ServeMux, d AppDeps) {
+	if d.Responder == nil {
+		d.Responder = response.NewResponder()
+	}
+
 	mux.HandleFunc("GET /{$}", d.Home.Index)
 	mux.HandleFunc("GET /health", d.Home.Health)
+	mux.HandleFunc("GET /health/ready", d.Home.Ready)
 
+	mux.HandleFunc("POST /messages", d.Message.CreateMessage)
+	mux.HandleFunc("POST /messages/multicast", d.Message.Multicast)
+	mux.HandleFunc("GET /groups/{id}/status", d.Message.GetGroupStatus)
+	mux.HandleFunc("GET /messages", d.Message.ListMessages)
 	mux.HandleFunc("GET /messages/sent", d.Message.GetSentMessages)
+	mux.Handle("GET /messages/export", d.AdminAuth(http.HandlerFunc(d.Message.ExportMessages)))
+	mux.HandleFunc("GET /messages/{id}/timeline", d.Message.GetMessageTimeline)
+	mux.Handle("POST /messages/{id}/retry", d.AdminAuth(http.HandlerFunc(d.Message.RetryMessage)))
+	mux.Handle("DELETE /messages/{id}", d.AdminAuth(http.HandlerFunc(d.Message.DeleteMessage)))
+	mux.Handle("DELETE /dedup/{to}", d.AdminAuth(http.HandlerFunc(d.Message.ClearDedup)))
+	mux.HandleFunc("GET /optout/{token}", d.Message.ResolveOptOut)
+	mux.HandleFunc("GET /l/{token}", d.Message.FollowLink)
 	mux.HandleFunc("POST /scheduler", d.Message.StartStopScheduler)
+	mux.HandleFunc("GET /scheduler/runs", d.Message.ListSchedulerRuns)
+	mux.HandleFunc("GET /scheduler/events", d.Message.GetSchedulerEvents)
+	mux.HandleFunc("GET /scheduler/status", d.Message.GetSchedulerStatus)
+
+	mux.HandleFunc("GET /stats/report", d.Stats.GetReport)
+	mux.HandleFunc("GET /stats/latency", d.Stats.GetLatency)
+	mux.HandleFunc("GET /stats/top-recipients", d.Stats.GetTopRecipients)
+	mux.HandleFunc("GET /stats/pending", d.Stats.GetPendingAge)
+
+	mux.HandleFunc("GET /config/worker", d.Config.GetWorkerConfig)
+	mux.Handle("PATCH /config/worker", d.AdminAuth(http.HandlerFunc(d.Config.UpdateWorkerConfig)))
+
+	if d.Metrics != nil {
+		mux.Handle("GET /metrics", d.Metrics)
+	}
 
 	//Swagger
 	mux.HandleFunc("GET /swagger/", swaggerHandler.WrapHandler)
 
 	// Fallback handler for undefined routes (404)
 	mux.Handle("/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		response.RespondError(w, http.StatusNotFound, "route not found")
+		d.Responder.Error(w, http.StatusNotFound, "route not found")
 	}))
 }
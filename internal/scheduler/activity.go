@@ -0,0 +1,140 @@
+package scheduler
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	domain "github.com/oggyb/insider-assessment/internal/domain/message"
+)
+
+// DefaultActivityLogSize is how many recent activities a scheduler keeps
+// unless WithActivityLogSize says otherwise.
+const DefaultActivityLogSize = 100
+
+// ActivityKind classifies an Activity.
+type ActivityKind string
+
+const (
+	ActivityStarted         ActivityKind = "started"
+	ActivityStopped         ActivityKind = "stopped"
+	ActivityBatch           ActivityKind = "batch"
+	ActivityBatchFailed     ActivityKind = "batch_failed"
+	ActivityIntervalChanged ActivityKind = "interval_changed"
+	ActivityClosed          ActivityKind = "closed"
+)
+
+// Activity is one entry of the scheduler's recent activity, mirroring what
+// it logs. Result, Duration and Error are only set for batches.
+type Activity struct {
+	At       time.Time
+	Kind     ActivityKind
+	Message  string
+	Result   *BatchCounts
+	Duration time.Duration
+	Error    string
+}
+
+// BatchCounts are the counts of a batch Activity.
+type BatchCounts struct {
+	Processed int
+	Succeeded int
+	Failed    int
+}
+
+// activityLog is a fixed-size ring buffer of activities. The control loop
+// appends to it while API requests read it, so it has its own lock.
+type activityLog struct {
+	mu      sync.Mutex
+	entries []Activity
+	next    int
+	full    bool
+}
+
+func newActivityLog(size int) *activityLog {
+	return &activityLog{entries: make([]Activity, size)}
+}
+
+// add appends a, overwriting the oldest entry once the log is full.
+func (l *activityLog) add(a Activity) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.entries[l.next] = a
+	l.next = (l.next + 1) % len(l.entries)
+	if l.next == 0 {
+		l.full = true
+	}
+}
+
+// recent returns the logged activities, most recent first.
+func (l *activityLog) recent() []Activity {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	n := l.next
+	if l.full {
+		n = len(l.entries)
+	}
+	out := make([]Activity, 0, n)
+	for i := 1; i <= n; i++ {
+		out = append(out, l.entries[(l.next-i+len(l.entries))%len(l.entries)])
+	}
+	return out
+}
+
+// WithActivityLogSize keeps the last size activities for RecentActivity
+// instead of DefaultActivityLogSize. A non-positive size disables the log.
+func WithActivityLogSize(size int) Option {
+	return func(s *schedulerService) {
+		s.activity = nil
+		if size > 0 {
+			s.activity = newActivityLog(size)
+		}
+	}
+}
+
+// RecentActivity returns the scheduler's recent activity, most recent
+// first. It is answered without the control loop, so it works after Close.
+func (s *schedulerService) RecentActivity() []Activity {
+	if s.activity == nil {
+		return []Activity{}
+	}
+	return s.activity.recent()
+}
+
+// note records an activity of the given kind, timestamped by the
+// scheduler's clock.
+func (s *schedulerService) note(kind ActivityKind, message string) {
+	s.noteActivity(Activity{Kind: kind, Message: message})
+}
+
+func (s *schedulerService) noteActivity(a Activity) {
+	if s.activity == nil {
+		return
+	}
+	a.At = s.clock.Now()
+	s.activity.add(a)
+}
+
+// noteBatch records the outcome of a batch run.
+func (s *schedulerService) noteBatch(result domain.BatchResult, elapsed time.Duration, err error) {
+	a := Activity{
+		Kind: ActivityBatch,
+		Result: &BatchCounts{
+			Processed: result.Processed,
+			Succeeded: result.Succeeded,
+			Failed:    result.Failed,
+		},
+		Duration: elapsed,
+	}
+	if err != nil {
+		a.Kind = ActivityBatchFailed
+		a.Message = "Batch failed."
+		a.Error = err.Error()
+	} else {
+		a.Message = fmt.Sprintf("Batch completed: %d processed, %d succeeded, %d failed.",
+			result.Processed, result.Succeeded, result.Failed)
+	}
+	s.noteActivity(a)
+}
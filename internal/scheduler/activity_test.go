@@ -0,0 +1,83 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestActivityLog_AccumulatesMostRecentFirst(t *testing.T) {
+	l := newActivityLog(3)
+	if got := l.recent(); len(got) != 0 {
+		t.Fatalf("expected an empty log, got %+v", got)
+	}
+
+	l.add(Activity{Message: "a"})
+	l.add(Activity{Message: "b"})
+
+	got := l.recent()
+	if len(got) != 2 || got[0].Message != "b" || got[1].Message != "a" {
+		t.Fatalf("expected [b a], got %+v", got)
+	}
+}
+
+func TestActivityLog_EvictsOldestPastCapacity(t *testing.T) {
+	l := newActivityLog(3)
+	for _, m := range []string{"a", "b", "c", "d", "e"} {
+		l.add(Activity{Message: m})
+	}
+
+	got := l.recent()
+	if len(got) != 3 {
+		t.Fatalf("expected 3 entries, got %d", len(got))
+	}
+	for i, want := range []string{"e", "d", "c"} {
+		if got[i].Message != want {
+			t.Fatalf("entry %d: expected %q, got %q", i, want, got[i].Message)
+		}
+	}
+}
+
+func TestScheduler_RecordsActivity(t *testing.T) {
+	p := &scriptedProcessor{failFor: 1}
+	s := NewSchedulerService(p, time.Hour, time.Second, WithRunOnStart(true), WithActivityLogSize(10))
+
+	if err := s.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	// Stop is handled after the immediate batch, which runs on the loop.
+	if err := s.Stop(); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+	if err := s.SetInterval(time.Minute); err != nil {
+		t.Fatalf("SetInterval: %v", err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	got := s.RecentActivity()
+	want := []ActivityKind{ActivityClosed, ActivityIntervalChanged, ActivityStopped, ActivityBatchFailed, ActivityStarted}
+	if len(got) != len(want) {
+		t.Fatalf("expected %d activities, got %+v", len(want), got)
+	}
+	for i, k := range want {
+		if got[i].Kind != k || got[i].At.IsZero() {
+			t.Fatalf("activity %d: expected a timestamped %q, got %+v", i, k, got[i])
+		}
+	}
+	if failed := got[3]; failed.Error != "db down" || failed.Result == nil {
+		t.Fatalf("expected the failed batch with its error and counts, got %+v", failed)
+	}
+}
+
+func TestScheduler_ActivityLogCanBeDisabled(t *testing.T) {
+	s := NewSchedulerService(newFakeBatchProcessor(), time.Hour, time.Second, WithActivityLogSize(0))
+	defer s.Close()
+
+	if err := s.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	if got := s.RecentActivity(); len(got) != 0 {
+		t.Fatalf("expected no activity, got %+v", got)
+	}
+}
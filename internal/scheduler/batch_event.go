@@ -0,0 +1,62 @@
+package scheduler
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	domain "github.com/oggyb/insider-assessment/internal/domain/message"
+)
+
+// BatchCompletedEvent is the name BatchCompleted is published under.
+const BatchCompletedEvent = "BatchCompleted"
+
+// BatchCompleted is published after every batch run, including failed ones,
+// e.g. for a webhook feeding an ops dashboard.
+type BatchCompleted struct {
+	Result domain.BatchResult
+}
+
+// Name implements event.Event.
+func (BatchCompleted) Name() string { return BatchCompletedEvent }
+
+// String implements fmt.Stringer.
+func (e BatchCompleted) String() string {
+	r := e.Result
+	s := fmt.Sprintf("batch started at %s took %s: %d processed, %d succeeded, %d failed",
+		r.StartedAt.Format(time.RFC3339), r.Duration, r.Processed, r.Succeeded, r.Failed)
+	if r.Error != "" {
+		s += "; error: " + r.Error
+	}
+	return s
+}
+
+// MarshalJSON encodes the result the way GET /scheduler/runs lists runs.
+func (e BatchCompleted) MarshalJSON() ([]byte, error) {
+	r := e.Result
+	return json.Marshal(struct {
+		StartedAt  time.Time `json:"startedAt"`
+		DurationMs int64     `json:"durationMs"`
+		Processed  int       `json:"processed"`
+		Succeeded  int       `json:"succeeded"`
+		Failed     int       `json:"failed"`
+		Workers    int       `json:"workers"`
+		Error      string    `json:"error,omitempty"`
+	}{r.StartedAt, r.Duration.Milliseconds(), r.Processed, r.Succeeded, r.Failed, r.Workers, r.Error})
+}
+
+// WithBatchEvents publishes a BatchCompleted event to p after every batch.
+// Subscribers that do slow work (webhooks) should be asynchronous, as
+// Publish runs on the control loop.
+func WithBatchEvents(p EventPublisher) Option {
+	return func(s *schedulerService) {
+		s.batchEvents = p
+	}
+}
+
+// publishBatch publishes the completed result of a batch, if configured.
+func (s *schedulerService) publishBatch(result domain.BatchResult) {
+	if s.batchEvents != nil {
+		s.batchEvents.Publish(BatchCompleted{Result: result})
+	}
+}
@@ -0,0 +1,112 @@
+package scheduler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	domain "github.com/oggyb/insider-assessment/internal/domain/message"
+	"github.com/oggyb/insider-assessment/internal/event"
+)
+
+func TestScheduler_PublishesBatchCompleted(t *testing.T) {
+	p := &scriptedProcessor{
+		failFor: 1,
+		result:  domain.BatchResult{Processed: 5, Succeeded: 4, Failed: 1, Workers: 2},
+	}
+	events := eventRecorder{ch: make(chan event.Event, 10)}
+	s := NewSchedulerService(p, 10*time.Millisecond, time.Second, WithBatchEvents(events))
+	defer s.Stop()
+
+	if err := s.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+
+	var got []domain.BatchResult
+	for len(got) < 2 {
+		select {
+		case e := <-events.ch:
+			done, ok := e.(BatchCompleted)
+			if !ok {
+				t.Fatalf("expected BatchCompleted, got %T", e)
+			}
+			got = append(got, done.Result)
+		case <-time.After(time.Second):
+			t.Fatalf("expected two BatchCompleted events, got %d", len(got))
+		}
+	}
+
+	if got[0].Error != "db down" || got[0].StartedAt.IsZero() {
+		t.Fatalf("expected the failed batch with its error and start, got %+v", got[0])
+	}
+	if r := got[1]; r.Processed != 5 || r.Succeeded != 4 || r.Failed != 1 || r.Workers != 2 || r.Error != "" {
+		t.Fatalf("expected the successful batch's counts, got %+v", r)
+	}
+}
+
+func TestScheduler_BatchWebhookReceivesCounts(t *testing.T) {
+	bodies := make(chan map[string]any, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		bodies <- body
+	}))
+	defer srv.Close()
+
+	bus := event.NewBus()
+	bus.SubscribeAsync(BatchCompletedEvent, event.NewWebhook(srv.URL, time.Second, 1).Handle)
+
+	p := &scriptedProcessor{result: domain.BatchResult{Processed: 3, Succeeded: 2, Failed: 1, Workers: 1}}
+	s := NewSchedulerService(p, time.Hour, time.Second, WithRunOnStart(true), WithBatchEvents(bus))
+	defer s.Stop()
+	if err := s.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+
+	select {
+	case body := <-bodies:
+		if body["processed"] != 3.0 || body["succeeded"] != 2.0 || body["failed"] != 1.0 || body["workers"] != 1.0 {
+			t.Fatalf("expected the batch counts in the webhook body, got %v", body)
+		}
+		if _, ok := body["error"]; ok {
+			t.Fatalf("expected no error on a successful batch, got %v", body["error"])
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the webhook to be called")
+	}
+}
+
+func TestScheduler_FailingBatchWebhookDoesNotAffectScheduling(t *testing.T) {
+	// The webhook hangs until its timeout, so every delivery fails.
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	bus := event.NewBus()
+	bus.SubscribeAsync(BatchCompletedEvent, event.NewWebhook(srv.URL, 200*time.Millisecond, 1).Handle)
+
+	p := &scriptedProcessor{}
+	s := NewSchedulerService(p, 10*time.Millisecond, time.Second, WithBatchEvents(bus))
+	if err := s.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	if n := len(p.gaps()); n < 3 {
+		t.Fatalf("expected batches to keep running every tick, got %d gaps", n)
+	}
+	if !s.IsRunning() {
+		t.Fatal("expected the scheduler to keep running")
+	}
+	if err := s.Stop(); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+}
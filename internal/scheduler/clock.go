@@ -0,0 +1,41 @@
+package scheduler
+
+import "time"
+
+// Clock is the scheduler's source of time and ticks. It is the real clock
+// unless WithClock replaces it, e.g. in tests that step time by hand.
+type Clock interface {
+	Now() time.Time
+	NewTicker(d time.Duration) Ticker
+}
+
+// Ticker is the part of time.Ticker the control loop uses.
+type Ticker interface {
+	C() <-chan time.Time
+	Reset(d time.Duration)
+	Stop()
+}
+
+// WithClock makes the scheduler read the time and create its ticker through
+// c. Control timeouts keep using real time. A nil c keeps the real clock.
+func WithClock(c Clock) Option {
+	return func(s *schedulerService) {
+		if c != nil {
+			s.clock = c
+		}
+	}
+}
+
+// realClock is the Clock backed by the time package.
+type realClock struct{}
+
+func (realClock) Now() time.Time { return time.Now() }
+
+func (realClock) NewTicker(d time.Duration) Ticker {
+	return realTicker{time.NewTicker(d)}
+}
+
+// realTicker adapts *time.Ticker to Ticker.
+type realTicker struct{ *time.Ticker }
+
+func (t realTicker) C() <-chan time.Time { return t.Ticker.C }
@@ -0,0 +1,71 @@
+package scheduler
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// CronSchedule is a parsed cron expression the scheduler can run batches on
+// instead of a fixed interval.
+type CronSchedule struct {
+	spec     string
+	schedule cron.Schedule
+}
+
+// ParseCron parses a standard five-field cron expression ("minute hour
+// day-of-month month day-of-week"), e.g. "*/5 9-17 * * 1-5" for every five
+// minutes during weekday office hours. Descriptors such as "@hourly" and a
+// leading "CRON_TZ=Europe/Istanbul" are accepted too; without a time zone
+// the scheduler's clock's local time is used.
+func ParseCron(spec string) (*CronSchedule, error) {
+	schedule, err := cron.ParseStandard(spec)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cron expression %q: %w", spec, err)
+	}
+	return &CronSchedule{spec: spec, schedule: schedule}, nil
+}
+
+// Next returns the first run time strictly after t, or the zero time if the
+// expression never matches again.
+func (c *CronSchedule) Next(t time.Time) time.Time {
+	return c.schedule.Next(t)
+}
+
+// String returns the expression the schedule was parsed from.
+func (c *CronSchedule) String() string {
+	return c.spec
+}
+
+// WithCronSchedule runs batches at the times of c instead of every
+// interval. The interval then only serves as the floor of a failure backoff,
+// and run-on-start and the skipped-tick alert do not apply. A nil c keeps
+// the fixed interval.
+func WithCronSchedule(c *CronSchedule) Option {
+	return func(s *schedulerService) {
+		s.cron = c
+	}
+}
+
+// nextRunAt returns when the next regular batch is due: one interval from
+// now, or the next time of the cron schedule.
+func (s *schedulerService) nextRunAt() time.Time {
+	now := s.clock.Now()
+	if s.cron == nil {
+		return now.Add(s.interval)
+	}
+	next := s.cron.Next(now)
+	if next.IsZero() {
+		// Never matches again (e.g. a past date); fall back to the
+		// interval rather than stalling.
+		return now.Add(s.interval)
+	}
+	return next
+}
+
+// regularDelay returns how long the ticker should wait for the next
+// regular batch.
+func (s *schedulerService) regularDelay() time.Duration {
+	return max(s.nextRunAt().Sub(s.clock.Now()), time.Millisecond)
+}
@@ -0,0 +1,192 @@
+package scheduler
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	domain "github.com/oggyb/insider-assessment/internal/domain/message"
+)
+
+// fakeClock is a Clock whose time only moves when Advance is called. Its
+// tickers fire, like time.Ticker, at most once per pending tick.
+type fakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	tickers []*fakeTicker
+}
+
+type fakeTicker struct {
+	clock  *fakeClock
+	c      chan time.Time
+	period time.Duration
+	next   time.Time
+}
+
+func newFakeClock(now time.Time) *fakeClock { return &fakeClock{now: now} }
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) NewTicker(d time.Duration) Ticker {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := &fakeTicker{clock: c, c: make(chan time.Time, 1), period: d, next: c.now.Add(d)}
+	c.tickers = append(c.tickers, t)
+	return t
+}
+
+// Advance moves the clock forward by d and fires the tickers that came due.
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	for _, t := range c.tickers {
+		if t.period <= 0 || c.now.Before(t.next) {
+			continue
+		}
+		select {
+		case t.c <- c.now:
+		default:
+		}
+		t.next = c.now.Add(t.period)
+	}
+}
+
+func (t *fakeTicker) C() <-chan time.Time { return t.c }
+
+func (t *fakeTicker) Reset(d time.Duration) {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	t.period, t.next = d, t.clock.now.Add(d)
+}
+
+func (t *fakeTicker) Stop() {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	t.period = 0
+}
+
+// signalingProcessor reports every batch on calls.
+type signalingProcessor struct {
+	calls chan time.Time
+	clock Clock
+}
+
+func (p *signalingProcessor) ProcessBatch(ctx context.Context) (domain.BatchResult, error) {
+	p.calls <- p.clock.Now()
+	return domain.BatchResult{}, nil
+}
+
+func TestParseCron_NextRunTimes(t *testing.T) {
+	c, err := ParseCron("0 9-18 * * 1-5")
+	if err != nil {
+		t.Fatalf("ParseCron: %v", err)
+	}
+
+	cases := []struct {
+		name     string
+		from     time.Time
+		wantNext time.Time
+	}{
+		{"within hours", time.Date(2026, time.March, 3, 10, 15, 0, 0, time.UTC), time.Date(2026, time.March, 3, 11, 0, 0, 0, time.UTC)},
+		{"on the hour", time.Date(2026, time.March, 3, 11, 0, 0, 0, time.UTC), time.Date(2026, time.March, 3, 12, 0, 0, 0, time.UTC)},
+		{"after hours", time.Date(2026, time.March, 3, 18, 30, 0, 0, time.UTC), time.Date(2026, time.March, 4, 9, 0, 0, 0, time.UTC)},
+		{"friday evening", time.Date(2026, time.March, 6, 18, 30, 0, 0, time.UTC), time.Date(2026, time.March, 9, 9, 0, 0, 0, time.UTC)},
+	}
+	for _, tc := range cases {
+		if got := c.Next(tc.from); !got.Equal(tc.wantNext) {
+			t.Errorf("%s: Next(%s) = %s, want %s", tc.name, tc.from, got, tc.wantNext)
+		}
+	}
+
+	for _, bad := range []string{"", "* * *", "61 * * * *", "every minute"} {
+		if _, err := ParseCron(bad); err == nil {
+			t.Errorf("expected %q to be rejected", bad)
+		}
+	}
+}
+
+func TestScheduler_CronTicksFireAtScheduledTimes(t *testing.T) {
+	clock := newFakeClock(time.Date(2026, time.March, 3, 12, 3, 0, 0, time.UTC))
+	cron, err := ParseCron("*/10 * * * *")
+	if err != nil {
+		t.Fatalf("ParseCron: %v", err)
+	}
+
+	proc := &signalingProcessor{calls: make(chan time.Time, 4), clock: clock}
+	s := NewSchedulerService(proc, time.Minute, time.Second,
+		WithClock(clock), WithCronSchedule(cron), WithRunOnStart(true))
+	defer s.Close()
+
+	if err := s.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	// IsRunning is answered by the loop, so Start has been fully handled
+	// (ticker aimed at 12:10) once it returns.
+	s.IsRunning()
+
+	expectNoBatch := func(when string) {
+		t.Helper()
+		s.IsRunning()
+		select {
+		case at := <-proc.calls:
+			t.Fatalf("%s: unexpected batch at %s", when, at.Format(time.Kitchen))
+		default:
+		}
+	}
+	expectBatch := func(want time.Time) {
+		t.Helper()
+		select {
+		case at := <-proc.calls:
+			if !at.Equal(want) {
+				t.Fatalf("batch ran at %s, want %s", at.Format(time.Kitchen), want.Format(time.Kitchen))
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("expected a batch at %s", want.Format(time.Kitchen))
+		}
+		// Let the loop re-aim the ticker before time moves on.
+		s.IsRunning()
+	}
+
+	// Run-on-start does not apply to a cron schedule.
+	expectNoBatch("after Start")
+
+	clock.Advance(6 * time.Minute) // 12:09
+	expectNoBatch("12:09")
+
+	clock.Advance(time.Minute) // 12:10
+	expectBatch(time.Date(2026, time.March, 3, 12, 10, 0, 0, time.UTC))
+
+	clock.Advance(9 * time.Minute) // 12:19
+	expectNoBatch("12:19")
+
+	clock.Advance(time.Minute) // 12:20
+	expectBatch(time.Date(2026, time.March, 3, 12, 20, 0, 0, time.UTC))
+}
+
+func TestScheduler_WithoutCronKeepsInterval(t *testing.T) {
+	clock := newFakeClock(time.Date(2026, time.March, 3, 12, 3, 0, 0, time.UTC))
+	proc := &signalingProcessor{calls: make(chan time.Time, 4), clock: clock}
+	s := NewSchedulerService(proc, 7*time.Minute, time.Second, WithClock(clock), WithCronSchedule(nil))
+	defer s.Close()
+
+	if err := s.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	s.IsRunning()
+
+	clock.Advance(7 * time.Minute)
+	select {
+	case at := <-proc.calls:
+		if want := time.Date(2026, time.March, 3, 12, 10, 0, 0, time.UTC); !at.Equal(want) {
+			t.Fatalf("batch ran at %s, want %s", at, want)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected a batch one interval after Start")
+	}
+}
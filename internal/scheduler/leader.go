@@ -0,0 +1,214 @@
+package scheduler
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultLeaseTTL is how long a leadership lease lasts unless renewed.
+const DefaultLeaseTTL = 15 * time.Second
+
+// LeaseStore holds the leadership lease shared by all replicas, e.g. in
+// Redis. The conditional calls only act while key still holds value, so a
+// replica can never extend or release a lease another one has taken over.
+type LeaseStore interface {
+	SetNX(ctx context.Context, key, value string, ttl time.Duration) (bool, error)
+	RenewIfHeld(ctx context.Context, key, value string, ttl time.Duration) (bool, error)
+	ReleaseIfHeld(ctx context.Context, key, value string) error
+}
+
+// LeaderStatus describes this replica's part in leader election. Without
+// election every scheduler leads.
+type LeaderStatus struct {
+	Enabled bool
+	Leader  bool
+	ID      string
+}
+
+// LeaderElector decides which of several replicas runs batches. It holds a
+// lease with a TTL in a LeaseStore and renews it every third of the TTL;
+// the others retry at the same pace and take over once it expires, e.g.
+// after the leader crashed. Leadership is given up as soon as the lease
+// is lost, or when it could not be renewed and may run out before the next
+// attempt, so two replicas never lead at once as long as their clocks run
+// at the same rate. Giving it up also cancels the batch running under the
+// leader's term (see termContext).
+type LeaderElector struct {
+	store LeaseStore
+	key   string
+	id    string
+	ttl   time.Duration
+	clock Clock
+
+	leader atomic.Bool
+
+	// term is cancelled when this replica stops leading; batches run under
+	// it. Guarded by mu.
+	mu      sync.Mutex
+	term    context.Context
+	endTerm context.CancelFunc
+
+	// leaseUntil is when the lease held by this replica runs out unless
+	// renewed. Only Run's goroutine touches it.
+	leaseUntil time.Time
+}
+
+// NewLeaderElector creates an elector competing for key in store. An empty
+// id is replaced by the host name plus a random suffix, and a non-positive
+// ttl by DefaultLeaseTTL. Nothing happens until Run is called.
+func NewLeaderElector(store LeaseStore, key, id string, ttl time.Duration) *LeaderElector {
+	if id == "" {
+		id = defaultInstanceID()
+	}
+	if ttl <= 0 {
+		ttl = DefaultLeaseTTL
+	}
+	return &LeaderElector{store: store, key: key, id: id, ttl: ttl, clock: realClock{}}
+}
+
+// defaultInstanceID returns "<hostname>-<random hex>", unique per process
+// even when replicas share a host name.
+func defaultInstanceID() string {
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		host = "scheduler"
+	}
+	b := make([]byte, 4)
+	_, _ = rand.Read(b)
+	return host + "-" + hex.EncodeToString(b)
+}
+
+// WithLeaderElection makes the scheduler run batches only while e holds
+// the lease. Replicas that do not lead stand by: they keep ticking and
+// accept Start/Stop as usual, but skip every batch.
+func WithLeaderElection(e *LeaderElector) Option {
+	return func(s *schedulerService) {
+		s.leader = e
+	}
+}
+
+// IsLeader reports whether this replica currently holds the lease.
+func (e *LeaderElector) IsLeader() bool {
+	return e.leader.Load()
+}
+
+// ID returns the value this replica stores in the lease.
+func (e *LeaderElector) ID() string {
+	return e.id
+}
+
+// Run competes for the lease until ctx is cancelled, then releases it if
+// held so another replica can take over without waiting for the TTL. It
+// is meant to be started in its own goroutine.
+func (e *LeaderElector) Run(ctx context.Context) {
+	ticker := e.clock.NewTicker(e.ttl / 3)
+	defer ticker.Stop()
+
+	e.step(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			e.release()
+			return
+		case <-ticker.C():
+			e.step(ctx)
+		}
+	}
+}
+
+// step renews the lease when leading and tries to acquire it otherwise.
+func (e *LeaderElector) step(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, e.ttl/3)
+	defer cancel()
+
+	now := e.clock.Now()
+	if e.leader.Load() {
+		ok, err := e.store.RenewIfHeld(ctx, e.key, e.id, e.ttl)
+		switch {
+		case err != nil && now.Add(e.ttl/3).Before(e.leaseUntil):
+			log.Printf("[Scheduler] Failed to renew leadership lease, retrying: %v\n", err)
+		case err != nil:
+			e.stepDown("lease expired while it could not be renewed: " + err.Error())
+		case !ok:
+			e.stepDown("lease was taken over")
+		default:
+			e.leaseUntil = now.Add(e.ttl)
+		}
+		return
+	}
+
+	ok, err := e.store.SetNX(ctx, e.key, e.id, e.ttl)
+	if err != nil {
+		log.Printf("[Scheduler] Failed to acquire leadership lease: %v\n", err)
+		return
+	}
+	if ok {
+		e.leaseUntil = now.Add(e.ttl)
+		e.beginTerm()
+		log.Printf("[Scheduler] Acquired leadership (id=%s); running batches.\n", e.id)
+	}
+}
+
+// stepDown gives up leadership without touching the lease.
+func (e *LeaderElector) stepDown(reason string) {
+	e.finishTerm()
+	log.Printf("[Scheduler] Lost leadership (%s); standing by.\n", reason)
+}
+
+// beginTerm marks this replica as the leader and opens a new term.
+func (e *LeaderElector) beginTerm() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.term, e.endTerm = context.WithCancel(context.Background())
+	e.leader.Store(true)
+}
+
+// finishTerm marks this replica as standing by and cancels its term.
+func (e *LeaderElector) finishTerm() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.leader.Store(false)
+	if e.endTerm != nil {
+		e.endTerm()
+	}
+}
+
+// termContext returns a context cancelled once this replica stops leading,
+// and false if it does not lead right now.
+func (e *LeaderElector) termContext() (context.Context, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if !e.leader.Load() {
+		return nil, false
+	}
+	return e.term, true
+}
+
+// release deletes the lease if this replica still holds it.
+func (e *LeaderElector) release() {
+	if !e.leader.Load() {
+		return
+	}
+	e.finishTerm()
+	ctx, cancel := context.WithTimeout(context.Background(), e.ttl/3)
+	defer cancel()
+	if err := e.store.ReleaseIfHeld(ctx, e.key, e.id); err != nil {
+		log.Printf("[Scheduler] Failed to release leadership lease: %v\n", err)
+		return
+	}
+	log.Println("[Scheduler] Released leadership.")
+}
+
+// Leadership reports this scheduler's leader election state.
+func (s *schedulerService) Leadership() LeaderStatus {
+	if s.leader == nil {
+		return LeaderStatus{Leader: true}
+	}
+	return LeaderStatus{Enabled: true, Leader: s.leader.IsLeader(), ID: s.leader.ID()}
+}
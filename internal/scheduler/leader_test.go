@@ -0,0 +1,253 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	domain "github.com/oggyb/insider-assessment/internal/domain/message"
+)
+
+// fakeLeaseStore is an in-memory LeaseStore whose keys expire by clock.
+type fakeLeaseStore struct {
+	mu      sync.Mutex
+	clock   Clock
+	value   string
+	expires time.Time
+	err     error
+}
+
+func (f *fakeLeaseStore) held() bool {
+	return f.value != "" && f.clock.Now().Before(f.expires)
+}
+
+func (f *fakeLeaseStore) SetNX(ctx context.Context, key, value string, ttl time.Duration) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.err != nil {
+		return false, f.err
+	}
+	if f.held() {
+		return false, nil
+	}
+	f.value, f.expires = value, f.clock.Now().Add(ttl)
+	return true, nil
+}
+
+func (f *fakeLeaseStore) RenewIfHeld(ctx context.Context, key, value string, ttl time.Duration) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.err != nil {
+		return false, f.err
+	}
+	if !f.held() || f.value != value {
+		return false, nil
+	}
+	f.expires = f.clock.Now().Add(ttl)
+	return true, nil
+}
+
+func (f *fakeLeaseStore) ReleaseIfHeld(ctx context.Context, key, value string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.value == value {
+		f.value = ""
+	}
+	return nil
+}
+
+func (f *fakeLeaseStore) setErr(err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.err = err
+}
+
+// newTestElectors returns two electors sharing one store and fake clock.
+func newTestElectors(ttl time.Duration) (*fakeClock, *fakeLeaseStore, *LeaderElector, *LeaderElector) {
+	clock := newFakeClock(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+	store := &fakeLeaseStore{clock: clock}
+	a := NewLeaderElector(store, "leader", "a", ttl)
+	b := NewLeaderElector(store, "leader", "b", ttl)
+	a.clock, b.clock = clock, clock
+	return clock, store, a, b
+}
+
+func TestLeaderElector_OnlyOneAcquiresTheLease(t *testing.T) {
+	_, _, a, b := newTestElectors(15 * time.Second)
+	ctx := context.Background()
+
+	a.step(ctx)
+	b.step(ctx)
+
+	if !a.IsLeader() || b.IsLeader() {
+		t.Fatalf("expected only a to lead, got a=%v b=%v", a.IsLeader(), b.IsLeader())
+	}
+}
+
+func TestLeaderElector_RenewalKeepsLeadership(t *testing.T) {
+	clock, _, a, b := newTestElectors(15 * time.Second)
+	ctx := context.Background()
+
+	a.step(ctx)
+	// Renewing every third of the TTL keeps the lease alive well past it.
+	for range 9 {
+		clock.Advance(5 * time.Second)
+		a.step(ctx)
+		b.step(ctx)
+	}
+
+	if !a.IsLeader() || b.IsLeader() {
+		t.Fatalf("expected a to keep leading, got a=%v b=%v", a.IsLeader(), b.IsLeader())
+	}
+}
+
+func TestLeaderElector_FailsOverWhenTheLeaseExpires(t *testing.T) {
+	clock, _, a, b := newTestElectors(15 * time.Second)
+	ctx := context.Background()
+
+	a.step(ctx)
+
+	// a stops renewing, e.g. because it crashed.
+	clock.Advance(10 * time.Second)
+	b.step(ctx)
+	if b.IsLeader() {
+		t.Fatal("expected b to wait while a's lease is still valid")
+	}
+
+	clock.Advance(6 * time.Second)
+	b.step(ctx)
+	if !b.IsLeader() {
+		t.Fatal("expected b to take over once a's lease expired")
+	}
+
+	// a comes back and finds its lease taken.
+	a.step(ctx)
+	if a.IsLeader() {
+		t.Fatal("expected a to step down after losing the lease")
+	}
+}
+
+func TestLeaderElector_StepsDownWhenRenewalFailsPastTheLease(t *testing.T) {
+	clock, store, a, _ := newTestElectors(15 * time.Second)
+	ctx := context.Background()
+
+	a.step(ctx)
+	store.setErr(errors.New("redis down"))
+
+	clock.Advance(5 * time.Second)
+	a.step(ctx)
+	if !a.IsLeader() {
+		t.Fatal("expected a to keep leading while its lease is still valid")
+	}
+
+	// The lease would run out before the next attempt at 15s.
+	clock.Advance(5 * time.Second)
+	a.step(ctx)
+	if a.IsLeader() {
+		t.Fatal("expected a to step down before its lease may expire")
+	}
+}
+
+func TestLeaderElector_RunReleasesTheLeaseOnCancel(t *testing.T) {
+	_, _, a, b := newTestElectors(15 * time.Second)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		a.Run(ctx)
+	}()
+
+	deadline := time.Now().Add(time.Second)
+	for !a.IsLeader() {
+		if time.Now().After(deadline) {
+			t.Fatal("expected a to acquire the lease")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	<-done
+
+	// b takes over immediately instead of waiting for the TTL.
+	b.step(context.Background())
+	if a.IsLeader() || !b.IsLeader() {
+		t.Fatalf("expected b to lead after a released, got a=%v b=%v", a.IsLeader(), b.IsLeader())
+	}
+}
+
+func TestScheduler_StandbyReplicaSkipsBatches(t *testing.T) {
+	_, _, a, b := newTestElectors(15 * time.Second)
+	a.step(context.Background())
+
+	proc := newFakeBatchProcessor()
+	close(proc.block)
+	s := NewSchedulerService(proc, 5*time.Millisecond, time.Second, WithLeaderElection(b))
+	defer s.Close()
+
+	if err := s.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if n := proc.Calls(); n != 0 {
+		t.Fatalf("expected no batches on the standby replica, got %d", n)
+	}
+	if l := s.Leadership(); !l.Enabled || l.Leader || l.ID != "b" {
+		t.Fatalf("expected standby leadership for b, got %+v", l)
+	}
+
+	// a goes away; b takes over and starts running batches.
+	a.release()
+	b.step(context.Background())
+	select {
+	case <-proc.started:
+	case <-time.After(time.Second):
+		t.Fatal("expected the new leader to run a batch")
+	}
+}
+
+// cancelRecorder blocks every batch until its context ends and reports why.
+type cancelRecorder struct {
+	started chan struct{}
+	ended   chan error
+}
+
+func (p cancelRecorder) ProcessBatch(ctx context.Context) (domain.BatchResult, error) {
+	p.started <- struct{}{}
+	<-ctx.Done()
+	p.ended <- ctx.Err()
+	return domain.BatchResult{}, nil
+}
+
+func TestScheduler_LosingLeadershipCancelsTheRunningBatch(t *testing.T) {
+	clock, _, a, b := newTestElectors(15 * time.Second)
+	a.step(context.Background())
+
+	proc := cancelRecorder{started: make(chan struct{}, 1), ended: make(chan error, 1)}
+	s := NewSchedulerService(proc, time.Hour, time.Hour, WithRunOnStart(true), WithLeaderElection(a))
+	defer s.Close()
+
+	if err := s.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	select {
+	case <-proc.started:
+	case <-time.After(time.Second):
+		t.Fatal("expected the leader to start a batch")
+	}
+
+	// a's lease runs out mid-batch and b takes over.
+	clock.Advance(16 * time.Second)
+	b.step(context.Background())
+	a.step(context.Background())
+
+	select {
+	case err := <-proc.ended:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("expected the batch to be cancelled, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the batch to stop once a lost the lease")
+	}
+}
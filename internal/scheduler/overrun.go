@@ -0,0 +1,76 @@
+package scheduler
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/oggyb/insider-assessment/internal/event"
+)
+
+// TicksSkippedEvent is the name TicksSkipped is published under.
+const TicksSkippedEvent = "TicksSkipped"
+
+// EventPublisher is the part of event.Bus the scheduler publishes to.
+type EventPublisher interface {
+	Publish(e event.Event)
+}
+
+// TicksSkipped is published when consecutive batches kept running past
+// their tick, so ticks were skipped. It suggests the interval is too short
+// for the work (or the batches too large).
+type TicksSkipped struct {
+	// Skipped is the number of consecutive ticks that fired mid-batch.
+	Skipped   int
+	Interval  time.Duration
+	LastBatch time.Duration
+}
+
+// Name implements event.Event.
+func (TicksSkipped) Name() string { return TicksSkippedEvent }
+
+// String implements fmt.Stringer.
+func (e TicksSkipped) String() string {
+	return fmt.Sprintf("%d consecutive ticks skipped; last batch took %s with an interval of %s",
+		e.Skipped, e.LastBatch, e.Interval)
+}
+
+// WithSkippedTickAlert warns once n consecutive ticks have been skipped
+// because the batch before them was still running. The warning is logged
+// and, if p is not nil, published as a TicksSkipped event. The count
+// restarts after every warning and whenever a batch finishes within its
+// interval. A non-positive n disables the check.
+func WithSkippedTickAlert(n int, p EventPublisher) Option {
+	return func(s *schedulerService) {
+		if n > 0 {
+			s.skipAlertAfter = n
+			s.events = p
+		}
+	}
+}
+
+// trackSkipped adds the ticks that fired during a batch lasting elapsed to
+// skipped, warns once it reaches the threshold, and returns the new count.
+// Ticks are counted against the regular interval, even during a backoff.
+// A cron schedule has no regular interval, so nothing is counted.
+func (s *schedulerService) trackSkipped(skipped int, elapsed time.Duration) int {
+	if s.skipAlertAfter <= 0 || s.cron != nil {
+		return 0
+	}
+
+	missed := int(elapsed / s.interval)
+	if missed == 0 {
+		return 0
+	}
+	skipped += missed
+	if skipped < s.skipAlertAfter {
+		return skipped
+	}
+
+	e := TicksSkipped{Skipped: skipped, Interval: s.interval, LastBatch: elapsed}
+	log.Printf("[Scheduler] Warning: %s; consider a longer SCHEDULER_INTERVAL.\n", e)
+	if s.events != nil {
+		s.events.Publish(e)
+	}
+	return 0
+}
@@ -2,26 +2,55 @@ package scheduler
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"time"
+
+	domain "github.com/oggyb/insider-assessment/internal/domain/message"
 )
 
 // BatchProcessor is the dependency that actually does the work.
-// The scheduler will call ProcessBatch on a fixed interval.
+// The scheduler will call ProcessBatch on a fixed interval, or at the times
+// of a cron schedule.
 type BatchProcessor interface {
-	ProcessBatch(ctx context.Context) error
+	ProcessBatch(ctx context.Context) (domain.BatchResult, error)
+}
+
+// RunRecorder persists the outcome of each batch run.
+type RunRecorder interface {
+	RecordBatchRun(ctx context.Context, result domain.BatchResult) error
+}
+
+// BatchObserver is told about every batch run, e.g. to keep histograms of
+// batch sizes and durations. It is called on the control loop and must not
+// block.
+type BatchObserver interface {
+	ObserveBatch(result domain.BatchResult, elapsed time.Duration)
 }
 
 // SchedulerService exposes a small control surface for the scheduler.
-// Start/Stop are synchronous controls, and IsRunning reports
-// whether the scheduler is currently accepting ticks.
+// Start/Stop are synchronous controls, SetRunning does the same while
+// also reporting the prior state, SetInterval changes the tick interval
+// at runtime, and IsRunning reports whether the scheduler is currently
+// accepting ticks. Close stops the scheduler for good; every control
+// call after it returns ErrSchedulerClosed. RecentActivity lists what the
+// scheduler did lately, most recent first, and Leadership reports whether
+// this replica is the one running batches.
 type SchedulerService interface {
 	Start() error
 	Stop() error
+	SetRunning(run bool) (wasRunning bool, err error)
+	SetInterval(d time.Duration) error
 	IsRunning() bool
+	Close() error
+	RecentActivity() []Activity
+	Leadership() LeaderStatus
 }
 
+// ErrSchedulerClosed is returned by control calls made after Close.
+var ErrSchedulerClosed = errors.New("scheduler closed")
+
 // DefaultInterval is used when no custom interval is provided.
 // This is the "safe fallback" value.
 const DefaultInterval = 2 * time.Minute
@@ -35,6 +64,10 @@ const DefaultBatchTimeout = 30 * time.Second
 // callers from hanging forever if the loop is not running.
 const controlTimeout = 2 * time.Second
 
+// recordTimeout bounds how long recording a batch run may take, so a slow
+// database cannot stall the control loop.
+const recordTimeout = 5 * time.Second
+
 // controlOp represents the kind of command sent into the internal control loop.
 type controlOp int
 
@@ -42,12 +75,15 @@ const (
 	opStart controlOp = iota
 	opStop
 	opStatus
+	opSetInterval
+	opClose
 )
 
 // controlMsg is sent over the ctrl channel to drive the scheduler's state.
 type controlMsg struct {
-	op   controlOp
-	resp chan bool // used by callers to get a synchronous answer
+	op       controlOp
+	interval time.Duration // new tick interval for opSetInterval
+	resp     chan bool     // used by callers to get a synchronous answer (prior running state for start/stop)
 }
 
 // schedulerService owns the internal state and runs the control loop.
@@ -57,6 +93,91 @@ type schedulerService struct {
 	interval       time.Duration
 	batchTimeout   time.Duration
 	ctrl           chan controlMsg
+
+	// closed is closed by the control loop when it exits after Close, so
+	// later callers fail fast instead of waiting for a loop that is gone.
+	closed chan struct{}
+
+	// backoffBase and backoffMax control how the tick interval grows after
+	// consecutive failed batches. A zero backoffBase disables backoff.
+	backoffBase time.Duration
+	backoffMax  time.Duration
+
+	// runOnStart triggers a batch immediately when the scheduler is started
+	// instead of waiting for the first tick.
+	runOnStart bool
+
+	// recorder, when set, receives the result of every batch run.
+	recorder RunRecorder
+
+	// observer, when set, is told about every batch run.
+	observer BatchObserver
+
+	// batchEvents, when set, receives BatchCompleted after every batch run.
+	batchEvents EventPublisher
+
+	// skipAlertAfter is how many consecutive skipped ticks trigger a
+	// warning, published to events when set; 0 disables the check.
+	skipAlertAfter int
+	events         EventPublisher
+
+	// cron, when set, replaces the fixed interval: every tick is aimed at
+	// its next run time.
+	cron *CronSchedule
+
+	// activity keeps the most recent starts, stops, batches and errors;
+	// nil when disabled.
+	activity *activityLog
+
+	// leader, when set, lets batches run only while this replica holds
+	// the leadership lease.
+	leader *LeaderElector
+
+	// clock supplies the time and the ticker.
+	clock Clock
+}
+
+// Option customizes optional behaviour of the scheduler.
+type Option func(*schedulerService)
+
+// WithFailureBackoff lengthens the tick interval after failed batches:
+// the n-th consecutive failure waits base*2^(n-1), never less than the
+// regular interval and never more than max. The next successful batch
+// restores the regular interval. A non-positive base disables backoff.
+func WithFailureBackoff(base, max time.Duration) Option {
+	return func(s *schedulerService) {
+		if base <= 0 {
+			return
+		}
+		if max < base {
+			max = base
+		}
+		s.backoffBase = base
+		s.backoffMax = max
+	}
+}
+
+// WithRunOnStart makes Start trigger a batch immediately (after acknowledging
+// the caller) rather than waiting a full interval for the first tick.
+func WithRunOnStart(enabled bool) Option {
+	return func(s *schedulerService) {
+		s.runOnStart = enabled
+	}
+}
+
+// WithRunRecorder records the result of every batch, including failed ones,
+// through r. Recording errors are logged and never affect scheduling.
+func WithRunRecorder(r RunRecorder) Option {
+	return func(s *schedulerService) {
+		s.recorder = r
+	}
+}
+
+// WithBatchObserver reports every batch, including failed ones, to o.
+func WithBatchObserver(o BatchObserver) Option {
+	return func(s *schedulerService) {
+		s.observer = o
+	}
 }
 
 // NewSchedulerService creates a new scheduler with the given interval
@@ -65,6 +186,7 @@ func NewSchedulerService(
 	msgService BatchProcessor,
 	interval time.Duration,
 	batchTimeout time.Duration,
+	opts ...Option,
 ) SchedulerService {
 	if interval <= 0 {
 		interval = DefaultInterval
@@ -78,6 +200,13 @@ func NewSchedulerService(
 		interval:       interval,
 		batchTimeout:   batchTimeout,
 		ctrl:           make(chan controlMsg),
+		closed:         make(chan struct{}),
+		activity:       newActivityLog(DefaultActivityLogSize),
+		clock:          realClock{},
+	}
+
+	for _, opt := range opts {
+		opt(s)
 	}
 
 	// The control loop is started in its own goroutine and lives
@@ -91,25 +220,8 @@ func NewSchedulerService(
 // It blocks until the internal loop has acknowledged the state change,
 // or returns an error if the control loop does not respond in time.
 func (s *schedulerService) Start() error {
-	resp := make(chan bool)
-	msg := controlMsg{op: opStart, resp: resp}
-
-	// First: make sure the control loop is actually listening
-	// on the ctrl channel.
-	select {
-	case s.ctrl <- msg:
-		// sent ok
-	case <-time.After(controlTimeout):
-		return fmt.Errorf("[Scheduler] Start: control loop not responding")
-	}
-
-	// Then: wait for the loop to acknowledge the state change.
-	select {
-	case <-resp:
-		return nil
-	case <-time.After(controlTimeout):
-		return fmt.Errorf("[Scheduler] Start: acknowledgement timeout")
-	}
+	_, err := s.SetRunning(true)
+	return err
 }
 
 // Stop tells the scheduler to stop accepting new ticks.
@@ -117,39 +229,96 @@ func (s *schedulerService) Start() error {
 // finishes (or times out) before returning. If the control loop does
 // not respond, Stop returns an error instead of blocking forever.
 func (s *schedulerService) Stop() error {
+	_, err := s.SetRunning(false)
+	return err
+}
+
+// SetRunning starts (run=true) or stops (run=false) the scheduler with the
+// same semantics as Start/Stop, and additionally reports whether the
+// scheduler was running before the call. This lets callers tell a real
+// state change apart from a no-op.
+func (s *schedulerService) SetRunning(run bool) (bool, error) {
+	op, name := opStart, "Start"
+	if !run {
+		op, name = opStop, "Stop"
+	}
+
+	return s.send(controlMsg{op: op}, name)
+}
+
+// Close stops the scheduler like Stop and then ends its control loop, so a
+// shutdown cannot race with a late Start. Start, Stop, SetRunning and
+// SetInterval return ErrSchedulerClosed afterwards and IsRunning reports
+// false. Closing twice is a no-op.
+func (s *schedulerService) Close() error {
+	_, err := s.send(controlMsg{op: opClose}, "Close")
+	if errors.Is(err, ErrSchedulerClosed) {
+		return nil
+	}
+	return err
+}
+
+// SetInterval changes the tick interval of the running control loop. The
+// next tick fires one new interval from now, unless a failure backoff is
+// in effect, in which case the new interval applies once it recovers.
+func (s *schedulerService) SetInterval(d time.Duration) error {
+	if d <= 0 {
+		return fmt.Errorf("[Scheduler] SetInterval: interval must be positive, got %s", d)
+	}
+	_, err := s.send(controlMsg{op: opSetInterval, interval: d}, "SetInterval")
+	return err
+}
+
+// send delivers msg to the control loop and waits for its answer, giving
+// up with an error if the loop does not accept or acknowledge it in time.
+func (s *schedulerService) send(msg controlMsg, name string) (bool, error) {
 	resp := make(chan bool)
-	msg := controlMsg{op: opStop, resp: resp}
+	msg.resp = resp
 
-	// Try to send the Stop command to the control loop.
+	// First: make sure the control loop is actually listening
+	// on the ctrl channel.
 	select {
 	case s.ctrl <- msg:
 		// sent ok
+	case <-s.closed:
+		return false, ErrSchedulerClosed
 	case <-time.After(controlTimeout):
-		return fmt.Errorf("[Scheduler] Stop: control loop not responding")
+		return false, fmt.Errorf("[Scheduler] %s: control loop not responding", name)
 	}
 
-	// Wait for the loop to confirm that it has stopped.
+	// Then: wait for the loop to acknowledge the state change.
 	select {
-	case <-resp:
-		return nil
+	case answer := <-resp:
+		return answer, nil
 	case <-time.After(controlTimeout):
-		return fmt.Errorf("[Scheduler] Stop: acknowledgement timeout")
+		return false, fmt.Errorf("[Scheduler] %s: acknowledgement timeout", name)
 	}
 }
 
 // IsRunning reports whether the scheduler is currently in "running" mode.
 // It does not mean that a batch is actively executing, only that new ticks
 // will be processed when the timer fires.
+//
+// The status is answered by the control loop itself, which handles one
+// control message at a time and changes state before acknowledging it. A
+// control call that has returned therefore happens before any IsRunning
+// issued after it (by the same goroutine, or one synchronized with it):
+// Start followed by IsRunning always reports true, Stop followed by
+// IsRunning always false. Concurrent calls are ordered by the loop.
 func (s *schedulerService) IsRunning() bool {
 	resp := make(chan bool)
-	s.ctrl <- controlMsg{op: opStatus, resp: resp}
-	return <-resp
+	select {
+	case s.ctrl <- controlMsg{op: opStatus, resp: resp}:
+		return <-resp
+	case <-s.closed:
+		return false
+	}
 }
 
 // loop is the heart of the scheduler. It owns all mutable state
 // and reacts to either control messages or timer ticks.
 func (s *schedulerService) loop() {
-	ticker := time.NewTicker(s.interval)
+	ticker := s.clock.NewTicker(s.regularDelay())
 	defer ticker.Stop()
 
 	// running: whether we should accept new ticks
@@ -159,7 +328,89 @@ func (s *schedulerService) loop() {
 
 	// pendingStop is a response channel to be completed once
 	// the current batch finishes, if Stop was called mid-batch.
+	// pendingStopWasRunning is the prior state to answer it with.
 	var pendingStop chan bool
+	pendingStopWasRunning := false
+
+	// failures counts consecutive failed batches for backoff purposes.
+	failures := 0
+
+	// skipped counts consecutive ticks that fired while a batch was running.
+	// The ticker drops them while the loop is busy, so they are derived from
+	// each batch's duration rather than observed.
+	skipped := 0
+
+	// runBatch executes one batch inline, updates the backoff state and
+	// completes a Stop that was requested while the batch was running.
+	runBatch := func() {
+		// Replicas that do not hold the lease stand by. A leader runs the
+		// batch under its term, which ends as soon as it loses the lease.
+		parent := context.Background()
+		if s.leader != nil {
+			term, ok := s.leader.termContext()
+			if !ok {
+				return
+			}
+			parent = term
+		}
+
+		inBatch = true
+		log.Println("[Scheduler] Triggering batch...")
+
+		// Time-bound the batch execution so Stop doesn't hang forever
+		// if ProcessBatch never returns.
+		ctx, cancel := context.WithTimeout(parent, s.batchTimeout)
+
+		start := s.clock.Now()
+		result, err := s.messageService.ProcessBatch(ctx)
+		cancel()
+
+		elapsed := s.clock.Now().Sub(start)
+		run := completeResult(start, result, elapsed, err)
+		s.recordRun(run)
+		s.publishBatch(run)
+		if s.observer != nil {
+			s.observer.ObserveBatch(result, elapsed)
+		}
+		skipped = s.trackSkipped(skipped, elapsed)
+
+		s.noteBatch(result, elapsed, err)
+
+		if err != nil {
+			log.Printf("[Scheduler] Batch failed: %v\n", err)
+			failures++
+			if s.backoffBase > 0 {
+				next := s.nextInterval(failures)
+				log.Printf("[Scheduler] Backing off: next tick in %s (failures=%d)\n", next, failures)
+				ticker.Reset(next)
+			} else if s.cron != nil {
+				ticker.Reset(s.regularDelay())
+			}
+		} else {
+			log.Println("[Scheduler] Batch completed.")
+			switch {
+			case failures > 0 && s.backoffBase > 0:
+				log.Printf("[Scheduler] Recovered, restoring regular schedule (next run at %s)\n",
+					s.nextRunAt().Format(time.RFC3339))
+				ticker.Reset(s.regularDelay())
+			case s.cron != nil:
+				// Cron times are not evenly spaced, so aim at the next one.
+				ticker.Reset(s.regularDelay())
+			}
+			failures = 0
+		}
+
+		inBatch = false
+
+		// If a Stop was requested while we were in a batch,
+		// complete it now and clear the pending channel.
+		if pendingStop != nil {
+			s.note(ActivityStopped, "Stopped after the running batch finished.")
+			pendingStop <- pendingStopWasRunning
+			pendingStop = nil
+			log.Println("[Scheduler] Stopped (no active batch).")
+		}
+	}
 
 	for {
 		select {
@@ -167,70 +418,145 @@ func (s *schedulerService) loop() {
 			switch msg.op {
 			case opStart:
 				if !running {
-					log.Printf("[Scheduler] Started (interval=%s, batchTimeout=%s)\n",
-						s.interval, s.batchTimeout)
+					schedule := "interval=" + s.interval.String()
+					if s.cron != nil {
+						schedule = fmt.Sprintf("cron=%q, next run at %s", s.cron, s.nextRunAt().Format(time.RFC3339))
+					}
+					log.Printf("[Scheduler] Started (%s, batchTimeout=%s)\n", schedule, s.batchTimeout)
+					s.note(ActivityStarted, fmt.Sprintf("Started (%s, batchTimeout=%s)", schedule, s.batchTimeout))
 				}
+				wasRunning := running
 				running = true
-				msg.resp <- true
+				msg.resp <- wasRunning
+
+				if !wasRunning {
+					// Optionally run the first batch right away instead of
+					// waiting a full interval. A cron schedule only runs at
+					// its own times.
+					if s.runOnStart && s.cron == nil && !inBatch {
+						runBatch()
+					}
+					// Regular ticking starts one interval from now, or at the
+					// next cron time (unless a failed immediate batch already
+					// scheduled a backoff).
+					if failures == 0 || s.backoffBase <= 0 {
+						ticker.Reset(s.regularDelay())
+					}
+				}
 
 			case opStop:
 				// If we're already idle and not in a batch,
 				// just acknowledge the Stop immediately.
 				if !running && !inBatch {
 					log.Println("[Scheduler] Stop requested, but already idle.")
-					msg.resp <- true
+					msg.resp <- false
 					continue
 				}
 
 				log.Println("[Scheduler] Stop requested. Waiting for current batch (if any)...")
 
 				// Mark as not running so future ticks are ignored.
+				wasRunning := running
 				running = false
 
 				if inBatch {
 					// Defer the response until the batch completes.
 					pendingStop = msg.resp
+					pendingStopWasRunning = wasRunning
 				} else {
 					// No active batch, we can safely stop now.
-					msg.resp <- true
+					s.note(ActivityStopped, "Stopped.")
+					msg.resp <- wasRunning
 				}
 
 			case opStatus:
 				msg.resp <- running
+
+			case opSetInterval:
+				log.Printf("[Scheduler] Interval changed: %s -> %s\n", s.interval, msg.interval)
+				s.note(ActivityIntervalChanged, fmt.Sprintf("Interval changed: %s -> %s", s.interval, msg.interval))
+				s.interval = msg.interval
+				// Keep an active backoff; runBatch restores the (new)
+				// interval after the next success.
+				if failures == 0 || s.backoffBase <= 0 {
+					ticker.Reset(s.regularDelay())
+				}
+				msg.resp <- running
+
+			case opClose:
+				// Batches run inline, so none is in flight here; stopping
+				// is just leaving the loop.
+				if running {
+					log.Println("[Scheduler] Stopped.")
+					s.note(ActivityStopped, "Stopped.")
+				}
+				wasRunning := running
+				running = false
+				s.note(ActivityClosed, "Closed.")
+				msg.resp <- wasRunning
+				close(s.closed)
+				log.Println("[Scheduler] Closed.")
+				return
 			}
 
-		case <-ticker.C:
+		case <-ticker.C():
 			// If we're not running or already processing a batch,
 			// ignore this tick.
 			if !running || inBatch {
 				continue
 			}
 
-			inBatch = true
-			log.Println("[Scheduler] Triggering batch...")
+			runBatch()
+		}
+	}
+}
+
+// completeResult fills in what the processor left out of the result of a
+// batch that started at start and took elapsed: timing from the
+// scheduler's own clock and the batch error, if any.
+func completeResult(start time.Time, result domain.BatchResult, elapsed time.Duration, err error) domain.BatchResult {
+	if result.StartedAt.IsZero() {
+		result.StartedAt = start
+	}
+	if result.Duration == 0 {
+		result.Duration = elapsed
+	}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	return result
+}
 
-			// Time-bound the batch execution so Stop doesn't hang forever
-			// if ProcessBatch never returns.
-			ctx, cancel := context.WithTimeout(context.Background(), s.batchTimeout)
+// recordRun hands the result of a batch run to the recorder, if any.
+func (s *schedulerService) recordRun(result domain.BatchResult) {
+	if s.recorder == nil {
+		return
+	}
 
-			err := s.messageService.ProcessBatch(ctx)
-			cancel()
+	ctx, cancel := context.WithTimeout(context.Background(), recordTimeout)
+	defer cancel()
 
-			if err != nil {
-				log.Printf("[Scheduler] Batch failed: %v\n", err)
-			} else {
-				log.Println("[Scheduler] Batch completed.")
-			}
+	if rErr := s.recorder.RecordBatchRun(ctx, result); rErr != nil {
+		log.Printf("[Scheduler] Failed to record batch run: %v\n", rErr)
+	}
+}
 
-			inBatch = false
+// nextInterval returns the tick interval to use after the given number of
+// consecutive failures: base*2^(failures-1), clamped to [interval, backoffMax].
+func (s *schedulerService) nextInterval(failures int) time.Duration {
+	if s.backoffBase <= 0 || failures <= 0 {
+		return s.interval
+	}
 
-			// If a Stop was requested while we were in a batch,
-			// complete it now and clear the pending channel.
-			if pendingStop != nil {
-				pendingStop <- true
-				pendingStop = nil
-				log.Println("[Scheduler] Stopped (no active batch).")
-			}
-		}
+	d := s.backoffBase
+	for i := 1; i < failures && d < s.backoffMax; i++ {
+		d *= 2
+	}
+	if d > s.backoffMax {
+		d = s.backoffMax
+	}
+	if d < s.interval {
+		d = s.interval
 	}
+	return d
 }
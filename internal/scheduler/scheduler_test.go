@@ -2,10 +2,14 @@ package scheduler
 
 import (
 	"context"
+	"errors"
 	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
+
+	domain "github.com/oggyb/insider-assessment/internal/domain/message"
+	"github.com/oggyb/insider-assessment/internal/event"
 )
 
 // fakeBatchProcessor is a test double that counts ProcessBatch calls,
@@ -24,7 +28,7 @@ func newFakeBatchProcessor() *fakeBatchProcessor {
 	}
 }
 
-func (f *fakeBatchProcessor) ProcessBatch(ctx context.Context) error {
+func (f *fakeBatchProcessor) ProcessBatch(ctx context.Context) (domain.BatchResult, error) {
 	atomic.AddInt32(&f.callCount, 1)
 
 	// Signal "started" only once (non-blocking).
@@ -39,7 +43,7 @@ func (f *fakeBatchProcessor) ProcessBatch(ctx context.Context) error {
 	case <-ctx.Done():
 	}
 
-	return nil
+	return domain.BatchResult{}, nil
 }
 
 func (f *fakeBatchProcessor) Calls() int32 {
@@ -179,3 +183,411 @@ func TestScheduler_RaceStartStop(t *testing.T) {
 
 	wg.Wait()
 }
+
+// scriptedProcessor fails the first `failFor` calls, then succeeds with
+// result, recording when each call happened.
+type scriptedProcessor struct {
+	mu      sync.Mutex
+	failFor int
+	result  domain.BatchResult
+	calls   []time.Time
+}
+
+func (p *scriptedProcessor) ProcessBatch(ctx context.Context) (domain.BatchResult, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.calls = append(p.calls, time.Now())
+	if len(p.calls) <= p.failFor {
+		return domain.BatchResult{}, errors.New("db down")
+	}
+	return p.result, nil
+}
+
+func (p *scriptedProcessor) gaps() []time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var out []time.Duration
+	for i := 1; i < len(p.calls); i++ {
+		out = append(out, p.calls[i].Sub(p.calls[i-1]))
+	}
+	return out
+}
+
+func TestScheduler_NextIntervalBackoff(t *testing.T) {
+	s := &schedulerService{interval: 10 * time.Millisecond}
+	WithFailureBackoff(20*time.Millisecond, 100*time.Millisecond)(s)
+
+	want := []time.Duration{
+		10 * time.Millisecond,  // no failures => regular interval
+		20 * time.Millisecond,  // 1st failure => base
+		40 * time.Millisecond,  // 2nd
+		80 * time.Millisecond,  // 3rd
+		100 * time.Millisecond, // capped
+		100 * time.Millisecond,
+	}
+	for failures, w := range want {
+		if got := s.nextInterval(failures); got != w {
+			t.Fatalf("failures=%d: expected %s, got %s", failures, w, got)
+		}
+	}
+}
+
+func TestScheduler_FailureBackoffGrowsAndRecovers(t *testing.T) {
+	proc := &scriptedProcessor{failFor: 3}
+	s := NewSchedulerService(proc, 10*time.Millisecond, time.Second,
+		WithFailureBackoff(40*time.Millisecond, 160*time.Millisecond))
+
+	_ = s.Start()
+	defer s.Stop()
+
+	// 3 failures (gaps ~40, ~80, ~160ms) then successes at the regular interval.
+	deadline := time.After(2 * time.Second)
+	for len(proc.gaps()) < 5 {
+		select {
+		case <-deadline:
+			t.Fatalf("not enough batches ran: %v", proc.gaps())
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+
+	gaps := proc.gaps()
+	if gaps[0] < 35*time.Millisecond || gaps[1] < 75*time.Millisecond || gaps[2] < 150*time.Millisecond {
+		t.Fatalf("expected interval to grow after failures, got %v", gaps[:3])
+	}
+	if gaps[2] <= gaps[1] || gaps[1] <= gaps[0] {
+		t.Fatalf("expected strictly growing backoff, got %v", gaps[:3])
+	}
+	if gaps[4] > 35*time.Millisecond {
+		t.Fatalf("expected interval to recover after success, got %v", gaps[4])
+	}
+}
+
+func TestScheduler_RunOnStartTriggersImmediateBatch(t *testing.T) {
+	fake := newFakeBatchProcessor()
+	close(fake.block) // let batches finish immediately
+
+	// Interval far longer than the test so only the immediate batch can run.
+	s := NewSchedulerService(fake, time.Hour, time.Second, WithRunOnStart(true))
+	_ = s.Start()
+	defer s.Stop()
+
+	select {
+	case <-fake.started:
+	case <-time.After(200 * time.Millisecond):
+		t.Fatalf("expected a batch right after Start when run-on-start is enabled")
+	}
+
+	// Starting again while running must not trigger another immediate batch.
+	_ = s.Start()
+	if calls := fake.Calls(); calls != 1 {
+		t.Fatalf("expected exactly 1 batch, got %d", calls)
+	}
+}
+
+func TestScheduler_RunOnStartDisabledWaitsForInterval(t *testing.T) {
+	fake := newFakeBatchProcessor()
+	close(fake.block)
+
+	interval := 150 * time.Millisecond
+	s := NewSchedulerService(fake, interval, time.Second, WithRunOnStart(false))
+	start := time.Now()
+	_ = s.Start()
+	defer s.Stop()
+
+	select {
+	case <-fake.started:
+		if elapsed := time.Since(start); elapsed < interval-20*time.Millisecond {
+			t.Fatalf("batch ran after %s, before the %s interval", elapsed, interval)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("expected a batch after one interval")
+	}
+}
+
+func TestScheduler_SetIntervalChangesTickRate(t *testing.T) {
+	p := &scriptedProcessor{}
+	s := NewSchedulerService(p, time.Hour, time.Second, WithRunOnStart(false))
+	defer s.Stop()
+
+	if err := s.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	if err := s.SetInterval(20 * time.Millisecond); err != nil {
+		t.Fatalf("SetInterval: %v", err)
+	}
+
+	time.Sleep(150 * time.Millisecond)
+	if n := len(p.gaps()); n < 2 {
+		t.Fatalf("expected several batches at the new interval, got %d gaps", n)
+	}
+
+	if err := s.SetInterval(0); err == nil {
+		t.Fatalf("expected an error for a non-positive interval")
+	}
+}
+
+// runRecorder collects recorded batch results.
+type runRecorder struct {
+	mu   sync.Mutex
+	runs []domain.BatchResult
+}
+
+func (r *runRecorder) RecordBatchRun(ctx context.Context, result domain.BatchResult) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.runs = append(r.runs, result)
+	return nil
+}
+
+func (r *runRecorder) recorded() []domain.BatchResult {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]domain.BatchResult(nil), r.runs...)
+}
+
+func TestScheduler_RecordsEveryBatchRun(t *testing.T) {
+	p := &scriptedProcessor{
+		failFor: 1,
+		result: domain.BatchResult{
+			StartedAt: time.Now(),
+			Duration:  3 * time.Millisecond,
+			Processed: 5,
+			Succeeded: 4,
+			Failed:    1,
+		},
+	}
+	rec := &runRecorder{}
+	s := NewSchedulerService(p, 10*time.Millisecond, time.Second, WithRunRecorder(rec))
+	defer s.Stop()
+
+	if err := s.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+
+	deadline := time.After(time.Second)
+	for len(rec.recorded()) < 2 {
+		select {
+		case <-deadline:
+			t.Fatalf("expected two recorded runs, got %d", len(rec.recorded()))
+		case <-time.After(5 * time.Millisecond):
+		}
+	}
+
+	runs := rec.recorded()
+	if runs[0].Error != "db down" || runs[0].StartedAt.IsZero() {
+		t.Fatalf("expected the failed run to be recorded with its error and start, got %+v", runs[0])
+	}
+	if got := runs[1]; got.Processed != 5 || got.Succeeded != 4 || got.Failed != 1 || got.Error != "" {
+		t.Fatalf("expected the successful run's counts to be recorded, got %+v", got)
+	}
+	if runs[1].Duration != 3*time.Millisecond {
+		t.Fatalf("expected the processor's duration to be kept, got %s", runs[1].Duration)
+	}
+}
+
+// slowProcessor takes d for every batch.
+type slowProcessor struct{ d time.Duration }
+
+func (p slowProcessor) ProcessBatch(ctx context.Context) (domain.BatchResult, error) {
+	select {
+	case <-time.After(p.d):
+	case <-ctx.Done():
+	}
+	return domain.BatchResult{}, nil
+}
+
+// eventRecorder collects published events.
+type eventRecorder struct{ ch chan event.Event }
+
+func (r eventRecorder) Publish(e event.Event) { r.ch <- e }
+
+func TestScheduler_WarnsAfterConsecutiveSkippedTicks(t *testing.T) {
+	events := eventRecorder{ch: make(chan event.Event, 10)}
+
+	// Every 35ms batch outlasts three 10ms ticks, so two batches reach five.
+	s := NewSchedulerService(slowProcessor{d: 35 * time.Millisecond}, 10*time.Millisecond, time.Second,
+		WithSkippedTickAlert(5, events))
+	if err := s.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	defer s.Stop()
+
+	select {
+	case e := <-events.ch:
+		skipped, ok := e.(TicksSkipped)
+		if !ok {
+			t.Fatalf("expected TicksSkipped, got %T", e)
+		}
+		if skipped.Skipped < 5 || skipped.Interval != 10*time.Millisecond {
+			t.Fatalf("unexpected event %+v", skipped)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected a TicksSkipped event")
+	}
+}
+
+func TestScheduler_NoWarningWhenBatchesFitTheInterval(t *testing.T) {
+	events := eventRecorder{ch: make(chan event.Event, 10)}
+
+	s := NewSchedulerService(slowProcessor{d: time.Millisecond}, 20*time.Millisecond, time.Second,
+		WithSkippedTickAlert(1, events))
+	if err := s.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	defer s.Stop()
+
+	select {
+	case e := <-events.ch:
+		t.Fatalf("expected no event, got %v", e)
+	case <-time.After(150 * time.Millisecond):
+	}
+}
+
+func TestScheduler_TrackSkippedCountsAndResets(t *testing.T) {
+	s := &schedulerService{interval: 10 * time.Millisecond, skipAlertAfter: 4}
+
+	n := s.trackSkipped(0, 25*time.Millisecond) // 2 ticks fired mid-batch
+	if n != 2 {
+		t.Fatalf("expected 2 skipped ticks, got %d", n)
+	}
+	if n = s.trackSkipped(n, 5*time.Millisecond); n != 0 {
+		t.Fatalf("expected a batch within the interval to reset the count, got %d", n)
+	}
+	n = s.trackSkipped(s.trackSkipped(0, 20*time.Millisecond), 20*time.Millisecond)
+	if n != 0 {
+		t.Fatalf("expected the count to restart after reaching the threshold, got %d", n)
+	}
+}
+
+func TestScheduler_ControlAfterCloseFailsFast(t *testing.T) {
+	fake := newFakeBatchProcessor()
+	close(fake.block)
+
+	s := NewSchedulerService(fake, time.Hour, time.Second)
+	if err := s.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	start := time.Now()
+	if err := s.Start(); !errors.Is(err, ErrSchedulerClosed) {
+		t.Fatalf("Start after Close: expected ErrSchedulerClosed, got %v", err)
+	}
+	if err := s.Stop(); !errors.Is(err, ErrSchedulerClosed) {
+		t.Fatalf("Stop after Close: expected ErrSchedulerClosed, got %v", err)
+	}
+	if _, err := s.SetRunning(true); !errors.Is(err, ErrSchedulerClosed) {
+		t.Fatalf("SetRunning after Close: expected ErrSchedulerClosed, got %v", err)
+	}
+	if err := s.SetInterval(time.Minute); !errors.Is(err, ErrSchedulerClosed) {
+		t.Fatalf("SetInterval after Close: expected ErrSchedulerClosed, got %v", err)
+	}
+	if s.IsRunning() {
+		t.Fatal("expected a closed scheduler to report not running")
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("second Close: expected no-op, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed >= controlTimeout {
+		t.Fatalf("expected control calls to fail fast after Close, took %v", elapsed)
+	}
+}
+
+func TestScheduler_CloseStopsTicking(t *testing.T) {
+	fake := newFakeBatchProcessor()
+	close(fake.block)
+
+	s := NewSchedulerService(fake, 5*time.Millisecond, time.Second)
+	if err := s.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	<-fake.started
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	calls := fake.Calls()
+	time.Sleep(30 * time.Millisecond)
+	if got := fake.Calls(); got != calls {
+		t.Fatalf("expected no batches after Close, got %d more", got-calls)
+	}
+}
+
+func TestScheduler_IsRunningReflectsLastAcknowledgedControl(t *testing.T) {
+	// A tiny interval and immediate batches keep ticks and batches
+	// interleaving with the control calls.
+	s := NewSchedulerService(slowProcessor{}, time.Millisecond, time.Second, WithRunOnStart(true))
+	defer s.Close()
+
+	// Concurrent readers must never trip the race detector; their answers
+	// may be either state.
+	done := make(chan struct{})
+	var readers sync.WaitGroup
+	for range 4 {
+		readers.Add(1)
+		go func() {
+			defer readers.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+					_ = s.IsRunning()
+				}
+			}
+		}()
+	}
+
+	for i := range 500 {
+		if err := s.Start(); err != nil {
+			t.Fatalf("iteration %d: Start: %v", i, err)
+		}
+		if !s.IsRunning() {
+			t.Fatalf("iteration %d: IsRunning = false right after Start", i)
+		}
+		if err := s.Stop(); err != nil {
+			t.Fatalf("iteration %d: Stop: %v", i, err)
+		}
+		if s.IsRunning() {
+			t.Fatalf("iteration %d: IsRunning = true right after Stop", i)
+		}
+	}
+
+	close(done)
+	readers.Wait()
+}
+
+func TestScheduler_IsRunningOrderedAcrossGoroutines(t *testing.T) {
+	s := NewSchedulerService(slowProcessor{}, time.Millisecond, time.Second)
+	defer s.Close()
+
+	// A control call that returned happens-before anything another
+	// goroutine does after synchronizing with the caller. checked holds the
+	// caller back until the state has been read.
+	acked := make(chan bool)
+	checked := make(chan struct{})
+	go func() {
+		defer close(acked)
+		for range 200 {
+			_ = s.Start()
+			acked <- true
+			<-checked
+			_ = s.Stop()
+			acked <- false
+			<-checked
+		}
+	}()
+
+	i := 0
+	for want := range acked {
+		if got := s.IsRunning(); got != want {
+			t.Fatalf("call %d: IsRunning = %v, want %v", i, got, want)
+		}
+		checked <- struct{}{}
+		i++
+	}
+}
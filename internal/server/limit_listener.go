@@ -0,0 +1,73 @@
+package server
+
+import (
+	"net"
+	"sync"
+)
+
+// limitListener caps the number of simultaneously open connections accepted
+// from the wrapped listener, in the style of netutil.LimitListener. Accept
+// blocks while the limit is reached, so excess clients wait in the kernel
+// backlog instead of being served concurrently.
+type limitListener struct {
+	net.Listener
+	sem       chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// newLimitListener returns a listener that accepts at most n simultaneous
+// connections from l.
+func newLimitListener(l net.Listener, n int) net.Listener {
+	return &limitListener{
+		Listener: l,
+		sem:      make(chan struct{}, n),
+		done:     make(chan struct{}),
+	}
+}
+
+// acquire waits for a free slot and reports whether one was taken. It
+// returns false once the listener is closed.
+func (l *limitListener) acquire() bool {
+	select {
+	case <-l.done:
+		return false
+	case l.sem <- struct{}{}:
+		return true
+	}
+}
+
+func (l *limitListener) release() { <-l.sem }
+
+func (l *limitListener) Accept() (net.Conn, error) {
+	if !l.acquire() {
+		// Let the underlying listener report the close error.
+		return l.Listener.Accept()
+	}
+
+	c, err := l.Listener.Accept()
+	if err != nil {
+		l.release()
+		return nil, err
+	}
+	return &limitListenerConn{Conn: c, release: l.release}, nil
+}
+
+func (l *limitListener) Close() error {
+	err := l.Listener.Close()
+	l.closeOnce.Do(func() { close(l.done) })
+	return err
+}
+
+// limitListenerConn frees its listener slot exactly once when closed.
+type limitListenerConn struct {
+	net.Conn
+	releaseOnce sync.Once
+	release     func()
+}
+
+func (c *limitListenerConn) Close() error {
+	err := c.Conn.Close()
+	c.releaseOnce.Do(c.release)
+	return err
+}
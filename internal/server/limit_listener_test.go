@@ -0,0 +1,114 @@
+package server
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+// pipeListener is a net.Listener that hands out the server side of
+// in-memory pipes queued by dial.
+type pipeListener struct {
+	conns     chan net.Conn
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func newPipeListener() *pipeListener {
+	return &pipeListener{conns: make(chan net.Conn, 16), done: make(chan struct{})}
+}
+
+// dial queues a new connection and returns its client side.
+func (l *pipeListener) dial() net.Conn {
+	server, client := net.Pipe()
+	l.conns <- server
+	return client
+}
+
+func (l *pipeListener) Accept() (net.Conn, error) {
+	select {
+	case c := <-l.conns:
+		return c, nil
+	case <-l.done:
+		return nil, net.ErrClosed
+	}
+}
+
+func (l *pipeListener) Close() error {
+	l.closeOnce.Do(func() { close(l.done) })
+	return nil
+}
+
+func (l *pipeListener) Addr() net.Addr { return &net.TCPAddr{} }
+
+func TestLimitListener_ThrottlesBeyondLimit(t *testing.T) {
+	pl := newPipeListener()
+	ln := newLimitListener(pl, 2)
+	defer ln.Close()
+
+	for i := 0; i < 3; i++ {
+		pl.dial()
+	}
+
+	first, err := ln.Accept()
+	if err != nil {
+		t.Fatalf("Accept 1: %v", err)
+	}
+	if _, err := ln.Accept(); err != nil {
+		t.Fatalf("Accept 2: %v", err)
+	}
+
+	// The third connection is queued until a slot frees up.
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		c, err := ln.Accept()
+		if err == nil {
+			accepted <- c
+		}
+	}()
+
+	select {
+	case <-accepted:
+		t.Fatalf("expected the third connection to wait while the limit is reached")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// Closing twice must only free one slot.
+	_ = first.Close()
+	_ = first.Close()
+
+	select {
+	case <-accepted:
+	case <-time.After(time.Second):
+		t.Fatalf("expected the third connection to be accepted after a close")
+	}
+}
+
+func TestLimitListener_CloseUnblocksAccept(t *testing.T) {
+	pl := newPipeListener()
+	ln := newLimitListener(pl, 1)
+
+	pl.dial()
+	if _, err := ln.Accept(); err != nil {
+		t.Fatalf("Accept: %v", err)
+	}
+
+	errc := make(chan error, 1)
+	go func() {
+		_, err := ln.Accept()
+		errc <- err
+	}()
+
+	_ = ln.Close()
+
+	select {
+	case err := <-errc:
+		if !errors.Is(err, net.ErrClosed) {
+			t.Fatalf("expected net.ErrClosed, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Accept did not return after Close")
+	}
+}
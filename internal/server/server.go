@@ -2,41 +2,161 @@ package server
 
 import (
 	"context"
+	"crypto/tls"
+	"net"
 	"net/http"
 	"time"
 
 	"github.com/oggyb/insider-assessment/internal/middleware"
+	"github.com/oggyb/insider-assessment/internal/reporter"
+	"github.com/oggyb/insider-assessment/internal/response"
 	routes "github.com/oggyb/insider-assessment/internal/router"
 )
 
 // Server owns the underlying http.Server instance.
 type Server struct {
 	http *http.Server
+
+	// maxConns caps simultaneously open connections; 0 means unlimited.
+	maxConns int
+
+	// trustedProxies may set the client IP via X-Forwarded-For/X-Real-IP.
+	trustedProxies []string
+
+	// maxURLLength rejects longer request URIs with 414; 0 disables it.
+	maxURLLength int
+
+	// requestTimeout bounds each request's context unless routeTimeouts
+	// lists its route; 0 means no deadline.
+	requestTimeout time.Duration
+	routeTimeouts  map[string]time.Duration
+
+	// certFile and keyFile switch Start and Serve to TLS; empty means
+	// plaintext.
+	certFile, keyFile string
+}
+
+// Option customizes optional behaviour of the server.
+type Option func(*Server)
+
+// WithMaxConnections caps the number of simultaneously open connections.
+// Connections beyond the limit wait to be accepted until a slot frees up.
+// Idle keep-alive connections are closed after a short timeout so they do
+// not hold slots that cheap requests such as /health need. A non-positive
+// n leaves the server unlimited.
+func WithMaxConnections(n int) Option {
+	return func(s *Server) {
+		if n > 0 {
+			s.maxConns = n
+		}
+	}
+}
+
+// WithTrustedProxies sets the proxies (IPs or CIDRs) whose X-Forwarded-For
+// and X-Real-IP headers are trusted to carry the client IP. See
+// middleware.RealIP.
+func WithTrustedProxies(proxies []string) Option {
+	return func(s *Server) {
+		s.trustedProxies = proxies
+	}
+}
+
+// WithMaxURLLength rejects requests whose raw URI is longer than n bytes
+// with a 414. A non-positive n disables the check. See
+// middleware.MaxURLLength.
+func WithMaxURLLength(n int) Option {
+	return func(s *Server) {
+		s.maxURLLength = n
+	}
+}
+
+// WithRequestTimeouts gives every request a context deadline of def, or
+// the duration listed in routes for its mux pattern (e.g. "GET
+// /messages/export" or "/health"). See middleware.Timeout.
+func WithRequestTimeouts(def time.Duration, routes map[string]time.Duration) Option {
+	return func(s *Server) {
+		s.requestTimeout = def
+		s.routeTimeouts = routes
+	}
+}
+
+// WithTLS serves HTTPS with the given certificate and key files and
+// tlsConfig (see TLSConfig); a nil tlsConfig uses Go's defaults. Without a
+// certificate the server stays plaintext.
+func WithTLS(certFile, keyFile string, tlsConfig *tls.Config) Option {
+	return func(s *Server) {
+		if certFile == "" || keyFile == "" {
+			return
+		}
+		s.certFile, s.keyFile = certFile, keyFile
+		s.http.TLSConfig = tlsConfig
+	}
 }
 
+// idleTimeoutWithLimit is how long an idle keep-alive connection may hold
+// a slot when a connection limit is set.
+const idleTimeoutWithLimit = 5 * time.Second
+
 // New creates a new HTTP server bound to the given address and configured
 // with the provided application dependencies and middleware chain.
-func New(addr string, deps routes.AppDeps) *Server {
+// Panics in handlers are recovered and forwarded to rep. Middleware errors
+// are written with deps.Responder.
+func New(addr string, deps routes.AppDeps, rep reporter.ErrorReporter, opts ...Option) *Server {
+	if deps.Responder == nil {
+		deps.Responder = response.NewResponder()
+	}
 	mux := http.NewServeMux()
 	routes.Register(mux, deps)
 
-	root := Chain(
-		mux,
-		middleware.RequestLogger(),
-	)
-
-	return &Server{
+	s := &Server{
 		http: &http.Server{
 			Addr:              addr,
-			Handler:           root,
 			ReadHeaderTimeout: 5 * time.Second,
 		},
 	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	s.http.Handler = Chain(
+		mux,
+		middleware.RequestID(),
+		middleware.RealIP(s.trustedProxies),
+		middleware.RequestLogger(),
+		middleware.MaxURLLength(s.maxURLLength, deps.Responder),
+		middleware.Recoverer(rep, deps.Responder),
+		middleware.Timeout(mux, s.requestTimeout, s.routeTimeouts),
+	)
+
+	if s.maxConns > 0 {
+		s.http.IdleTimeout = idleTimeoutWithLimit
+	}
+
+	return s
 }
 
-// Start runs the HTTP server and blocks until ListenAndServe returns.
+// Start listens on the configured address and serves until the server is
+// shut down.
 func (s *Server) Start() error {
-	return s.http.ListenAndServe()
+	ln, err := net.Listen("tcp", s.http.Addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(ln)
+}
+
+// Serve accepts connections on ln, applying the connection limit if one is
+// configured, and blocks until the server is shut down. Connections are
+// served over TLS when WithTLS set a certificate.
+func (s *Server) Serve(ln net.Listener) error {
+	if s.maxConns > 0 {
+		ln = newLimitListener(ln, s.maxConns)
+	}
+	if s.certFile != "" {
+		return s.http.ServeTLS(ln, s.certFile, s.keyFile)
+	}
+	return s.http.Serve(ln)
 }
 
 // Shutdown gracefully stops the HTTP server, waiting for in-flight
@@ -0,0 +1,48 @@
+package server
+
+import (
+	"crypto/tls"
+	"fmt"
+	"strings"
+)
+
+// tlsVersions maps the accepted TLS_MIN_VERSION values to their constants.
+// TLS 1.0 and 1.1 are deprecated and not accepted.
+var tlsVersions = map[string]uint16{
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// TLSConfig builds the server's tls.Config from a minimum version ("1.2" or
+// "1.3"; empty means 1.2) and optional cipher suite names as listed by
+// tls.CipherSuites, e.g. TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256. Insecure
+// suites are rejected. Go does not allow configuring TLS 1.3 suites, so
+// cipherSuites only affects TLS 1.2 and below.
+func TLSConfig(minVersion string, cipherSuites []string) (*tls.Config, error) {
+	v := strings.TrimPrefix(strings.TrimSpace(minVersion), "TLS")
+	if v == "" {
+		v = "1.2"
+	}
+	version, ok := tlsVersions[v]
+	if !ok {
+		return nil, fmt.Errorf("unknown TLS min version %q", minVersion)
+	}
+
+	cfg := &tls.Config{MinVersion: version}
+	if len(cipherSuites) == 0 {
+		return cfg, nil
+	}
+
+	byName := map[string]uint16{}
+	for _, cs := range tls.CipherSuites() {
+		byName[cs.Name] = cs.ID
+	}
+	for _, name := range cipherSuites {
+		id, ok := byName[strings.TrimSpace(name)]
+		if !ok {
+			return nil, fmt.Errorf("unknown or insecure TLS cipher suite %q", name)
+		}
+		cfg.CipherSuites = append(cfg.CipherSuites, id)
+	}
+	return cfg, nil
+}
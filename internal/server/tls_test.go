@@ -0,0 +1,146 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestTLSConfig_MinVersion(t *testing.T) {
+	cases := []struct {
+		in   string
+		want uint16
+	}{
+		{"", tls.VersionTLS12},
+		{"1.2", tls.VersionTLS12},
+		{"1.3", tls.VersionTLS13},
+		{"TLS1.3", tls.VersionTLS13},
+	}
+	for _, tc := range cases {
+		cfg, err := TLSConfig(tc.in, nil)
+		if err != nil {
+			t.Fatalf("TLSConfig(%q): %v", tc.in, err)
+		}
+		if cfg.MinVersion != tc.want {
+			t.Fatalf("TLSConfig(%q).MinVersion = %x, want %x", tc.in, cfg.MinVersion, tc.want)
+		}
+		if cfg.CipherSuites != nil {
+			t.Fatalf("expected Go's default suites, got %v", cfg.CipherSuites)
+		}
+	}
+
+	for _, bad := range []string{"1.0", "1.1", "TLS1.1", "1.4"} {
+		if _, err := TLSConfig(bad, nil); err == nil {
+			t.Fatalf("expected min version %q to be rejected", bad)
+		}
+	}
+}
+
+func TestTLSConfig_CipherSuites(t *testing.T) {
+	cfg, err := TLSConfig("1.2", []string{"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256", " TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384 "})
+	if err != nil {
+		t.Fatalf("TLSConfig: %v", err)
+	}
+	want := []uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256, tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384}
+	if !slices.Equal(cfg.CipherSuites, want) {
+		t.Fatalf("CipherSuites = %v, want %v", cfg.CipherSuites, want)
+	}
+
+	for _, bad := range []string{"TLS_RSA_WITH_RC4_128_SHA", "nope"} {
+		if _, err := TLSConfig("1.2", []string{bad}); err == nil {
+			t.Fatalf("expected %q to be rejected", bad)
+		}
+	}
+}
+
+// writeSelfSignedCert writes a throwaway certificate for 127.0.0.1 and
+// returns the cert and key file paths.
+func writeSelfSignedCert(t *testing.T) (string, string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "test"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("CreateCertificate: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("MarshalECPrivateKey: %v", err)
+	}
+
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+func TestWithTLS_EnforcesMinVersion(t *testing.T) {
+	certFile, keyFile := writeSelfSignedCert(t)
+	tlsConfig, err := TLSConfig("1.3", nil)
+	if err != nil {
+		t.Fatalf("TLSConfig: %v", err)
+	}
+
+	s := &Server{http: &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})}}
+	WithTLS(certFile, keyFile, tlsConfig)(s)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	go func() { _ = s.Serve(ln) }()
+	defer s.http.Close()
+
+	dial := func(maxVersion uint16) error {
+		conn, err := tls.Dial("tcp", ln.Addr().String(), &tls.Config{
+			InsecureSkipVerify: true,
+			MaxVersion:         maxVersion,
+		})
+		if err == nil {
+			_ = conn.Close()
+		}
+		return err
+	}
+
+	if err := dial(tls.VersionTLS12); err == nil {
+		t.Fatal("expected a TLS 1.2 client to be refused")
+	}
+	if err := dial(tls.VersionTLS13); err != nil {
+		t.Fatalf("expected a TLS 1.3 client to connect, got %v", err)
+	}
+}
+
+func TestWithTLS_WithoutCertStaysPlaintext(t *testing.T) {
+	s := &Server{http: &http.Server{}}
+	WithTLS("", "key.pem", &tls.Config{})(s)
+	if s.certFile != "" || s.http.TLSConfig != nil {
+		t.Fatal("expected TLS to stay off without a certificate")
+	}
+}
@@ -0,0 +1,80 @@
+package service
+
+import (
+	"log"
+	"time"
+)
+
+// adaptiveBatch holds the bounds and latency targets of the adaptive batch
+// size (see WithAdaptiveBatchSize).
+type adaptiveBatch struct {
+	min, max  int
+	low, high time.Duration
+}
+
+// batchLatency summarizes the provider calls of one batch.
+type batchLatency struct {
+	// sends is how many provider calls were made, total their summed
+	// duration.
+	sends int64
+	total time.Duration
+	// timeouts counts sends that ran into their deadline.
+	timeouts int64
+}
+
+// average returns the mean provider call duration, or 0 without sends.
+func (l batchLatency) average() time.Duration {
+	if l.sends == 0 {
+		return 0
+	}
+	return l.total / time.Duration(l.sends)
+}
+
+// WithAdaptiveBatchSize lets the batch size follow provider latency within
+// [min, max]: after a batch whose average send took less than low, the
+// size grows by a quarter (at least 1), provided the batch was full; after
+// one that averaged more than high or had a send time out, it is halved.
+// The adjusted size is what WorkerConfig reports and what a runtime update
+// starts from. min < 1, max < min or low >= high disables adaptation.
+func WithAdaptiveBatchSize(min, max int, low, high time.Duration) Option {
+	return func(s *messageService) {
+		if min < 1 || max < min || max > MaxBatchSize || low >= high {
+			return
+		}
+		s.adaptive = &adaptiveBatch{min: min, max: max, low: low, high: high}
+	}
+}
+
+// adaptBatchSize adjusts the batch size after a batch that claimed claimed
+// of the batchSize messages it asked for and saw latency l.
+func (s *messageService) adaptBatchSize(batchSize, claimed int, l batchLatency) {
+	a := s.adaptive
+	if a == nil || l.sends == 0 {
+		return
+	}
+
+	avg := l.average()
+	next := batchSize
+	switch {
+	case l.timeouts > 0 || avg > a.high:
+		next = batchSize / 2
+	case avg < a.low && claimed >= batchSize:
+		// Only grow while the queue keeps batches full; a larger size
+		// would not fetch more otherwise.
+		next = batchSize + max(batchSize/4, 1)
+	}
+	next = min(max(next, a.min), a.max)
+
+	s.cfgMu.Lock()
+	// Leave a runtime update made during the batch alone.
+	changed := next != batchSize && s.batchSize == batchSize
+	if changed {
+		s.batchSize = next
+	}
+	s.cfgMu.Unlock()
+
+	if changed {
+		log.Printf("[Service] Adaptive batch size %d -> %d (avg send %s, %d timeouts).",
+			batchSize, next, avg.Round(time.Millisecond), l.timeouts)
+	}
+}
@@ -0,0 +1,102 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/oggyb/insider-assessment/internal/sms/smstest"
+)
+
+// seedPending saves n pending messages to distinct recipients.
+func seedPending(t *testing.T, repo *fakeRepo, n int) {
+	t.Helper()
+	for i := range n {
+		_ = repo.Save(context.Background(), newPendingMessage(t, fmt.Sprintf("+90500%07d", i), "hello"))
+	}
+}
+
+func TestAdaptiveBatchSize_GrowsOnLowLatencyUpToMax(t *testing.T) {
+	repo := &fakeRepo{}
+	seedPending(t, repo, 100)
+
+	svc := NewMessageService(repo, smstest.NewFakeClient(), nil, 4, 4, 0,
+		WithAdaptiveBatchSize(2, 8, 50*time.Millisecond, time.Second))
+
+	want := []int{5, 6, 7, 8, 8}
+	for i, w := range want {
+		if _, err := svc.ProcessBatch(context.Background()); err != nil {
+			t.Fatalf("ProcessBatch: %v", err)
+		}
+		if got := svc.WorkerConfig().BatchSize; got != w {
+			t.Fatalf("after batch %d batch size = %d, want %d", i+1, got, w)
+		}
+	}
+}
+
+func TestAdaptiveBatchSize_ShrinksOnHighLatencyDownToMin(t *testing.T) {
+	repo := &fakeRepo{}
+	seedPending(t, repo, 100)
+
+	client := smstest.NewFakeClient().Delay(20 * time.Millisecond)
+	svc := NewMessageService(repo, client, nil, 16, 16, 0,
+		WithAdaptiveBatchSize(3, 32, time.Millisecond, 5*time.Millisecond))
+
+	want := []int{8, 4, 3, 3}
+	for i, w := range want {
+		if _, err := svc.ProcessBatch(context.Background()); err != nil {
+			t.Fatalf("ProcessBatch: %v", err)
+		}
+		if got := svc.WorkerConfig().BatchSize; got != w {
+			t.Fatalf("after batch %d batch size = %d, want %d", i+1, got, w)
+		}
+	}
+}
+
+func TestAdaptiveBatchSize_ShrinksOnTimeouts(t *testing.T) {
+	repo := &fakeRepo{}
+	seedPending(t, repo, 10)
+
+	// Sends time out well within the high mark, so only the timeouts can
+	// cause the shrink.
+	client := smstest.NewFakeClient().Delay(time.Second)
+	svc := NewMessageService(repo, client, nil, 10, 10, 10*time.Millisecond,
+		WithAdaptiveBatchSize(1, 20, time.Millisecond, time.Minute))
+
+	if _, err := svc.ProcessBatch(context.Background()); err != nil {
+		t.Fatalf("ProcessBatch: %v", err)
+	}
+	if got := svc.WorkerConfig().BatchSize; got != 5 {
+		t.Fatalf("batch size = %d, want 5 after timeouts", got)
+	}
+}
+
+func TestAdaptiveBatchSize_DoesNotGrowOnPartialBatch(t *testing.T) {
+	repo := &fakeRepo{}
+	seedPending(t, repo, 3)
+
+	svc := NewMessageService(repo, smstest.NewFakeClient(), nil, 10, 2, 0,
+		WithAdaptiveBatchSize(2, 50, time.Second, 2*time.Second))
+
+	if _, err := svc.ProcessBatch(context.Background()); err != nil {
+		t.Fatalf("ProcessBatch: %v", err)
+	}
+	if got := svc.WorkerConfig().BatchSize; got != 10 {
+		t.Fatalf("batch size = %d, want it unchanged when the queue ran dry", got)
+	}
+}
+
+func TestAdaptiveBatchSize_InvalidBoundsDisable(t *testing.T) {
+	for _, opt := range []Option{
+		WithAdaptiveBatchSize(0, 10, time.Millisecond, time.Second),
+		WithAdaptiveBatchSize(10, 5, time.Millisecond, time.Second),
+		WithAdaptiveBatchSize(1, 10, time.Second, time.Second),
+		WithAdaptiveBatchSize(1, MaxBatchSize+1, time.Millisecond, time.Second),
+	} {
+		svc := NewMessageService(&fakeRepo{}, nil, nil, 10, 1, 0, opt).(*messageService)
+		if svc.adaptive != nil {
+			t.Fatalf("expected adaptation to be disabled, got %+v", svc.adaptive)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package service
+
+import (
+	"fmt"
+	"time"
+
+	domain "github.com/oggyb/insider-assessment/internal/domain/message"
+	"github.com/oggyb/insider-assessment/internal/event"
+)
+
+// HighFailureRateEvent is the name HighFailureRate is published under.
+const HighFailureRateEvent = "HighFailureRate"
+
+// EventPublisher is the part of event.Bus the service publishes to.
+type EventPublisher interface {
+	Publish(e event.Event)
+}
+
+// HighFailureRate is published after a batch whose share of failed messages
+// exceeded the configured threshold.
+type HighFailureRate struct {
+	StartedAt time.Time
+	// Ratio is Failed/Processed, between 0 and 1.
+	Ratio float64
+	// ThresholdPercent is the limit Ratio exceeded, in percent.
+	ThresholdPercent int
+	Processed        int
+	Failed           int
+}
+
+// Name implements event.Event.
+func (HighFailureRate) Name() string { return HighFailureRateEvent }
+
+// String implements fmt.Stringer.
+func (e HighFailureRate) String() string {
+	return fmt.Sprintf("%d of %d messages failed (%.1f%%, threshold %d%%) in batch started at %s",
+		e.Failed, e.Processed, e.Ratio*100, e.ThresholdPercent, e.StartedAt.Format(time.RFC3339))
+}
+
+// WithFailureAlert publishes a HighFailureRate event to p after every batch
+// in which more than thresholdPercent of the processed messages failed.
+// A threshold outside 0-99 or a nil publisher disables the alert.
+func WithFailureAlert(p EventPublisher, thresholdPercent int) Option {
+	return func(s *messageService) {
+		if p != nil && thresholdPercent >= 0 && thresholdPercent < 100 {
+			s.events = p
+			s.failureAlertPercent = thresholdPercent
+		}
+	}
+}
+
+// checkFailureRate publishes HighFailureRate if r failed more messages than
+// the configured threshold allows. Batches that processed nothing never alert.
+func (s *messageService) checkFailureRate(r domain.BatchResult) {
+	if s.events == nil || r.Processed == 0 {
+		return
+	}
+
+	ratio := float64(r.Failed) / float64(r.Processed)
+	if ratio*100 <= float64(s.failureAlertPercent) {
+		return
+	}
+
+	s.events.Publish(HighFailureRate{
+		StartedAt:        r.StartedAt,
+		Ratio:            ratio,
+		ThresholdPercent: s.failureAlertPercent,
+		Processed:        r.Processed,
+		Failed:           r.Failed,
+	})
+}
@@ -0,0 +1,80 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/oggyb/insider-assessment/internal/event"
+)
+
+// recordingPublisher keeps every published event.
+type recordingPublisher struct {
+	events []event.Event
+}
+
+func (p *recordingPublisher) Publish(e event.Event) { p.events = append(p.events, e) }
+
+// runAlertBatch processes a batch of four messages, the first failures of
+// which fail, with the alert threshold set to thresholdPercent.
+func runAlertBatch(t *testing.T, failures, thresholdPercent int) *recordingPublisher {
+	t.Helper()
+
+	repo := &fakeRepo{}
+	for i := 0; i < 4; i++ {
+		content := "ok"
+		if i < failures {
+			content = "fail"
+		}
+		_ = repo.Save(context.Background(), newPendingMessage(t, "+905000000000", content))
+	}
+
+	client := &fakeSMS{send: func(ctx context.Context, to, content string) (string, string, error) {
+		if content == "fail" {
+			return "", `{"error":"rejected"}`, errors.New("rejected")
+		}
+		return "", "{}", nil
+	}}
+
+	pub := &recordingPublisher{}
+	svc := NewMessageService(repo, client, nil, 10, 2, time.Second, WithFailureAlert(pub, thresholdPercent))
+	if _, err := svc.ProcessBatch(context.Background()); err != nil {
+		t.Fatalf("ProcessBatch: %v", err)
+	}
+	return pub
+}
+
+func TestProcessBatch_FailureRateAboveThresholdPublishesEvent(t *testing.T) {
+	pub := runAlertBatch(t, 3, 50)
+
+	if len(pub.events) != 1 {
+		t.Fatalf("expected one event, got %d", len(pub.events))
+	}
+	e, ok := pub.events[0].(HighFailureRate)
+	if !ok {
+		t.Fatalf("expected HighFailureRate, got %T", pub.events[0])
+	}
+	if e.Name() != HighFailureRateEvent {
+		t.Fatalf("expected name %q, got %q", HighFailureRateEvent, e.Name())
+	}
+	if e.Processed != 4 || e.Failed != 3 || e.Ratio != 0.75 || e.ThresholdPercent != 50 {
+		t.Fatalf("unexpected event %+v", e)
+	}
+}
+
+func TestProcessBatch_FailureRateAtOrBelowThresholdIsQuiet(t *testing.T) {
+	// Exactly at the threshold does not exceed it.
+	if pub := runAlertBatch(t, 2, 50); len(pub.events) != 0 {
+		t.Fatalf("expected no event at 50%%, got %v", pub.events)
+	}
+	if pub := runAlertBatch(t, 0, 0); len(pub.events) != 0 {
+		t.Fatalf("expected no event without failures, got %v", pub.events)
+	}
+}
+
+func TestWithFailureAlert_InvalidThresholdDisables(t *testing.T) {
+	if pub := runAlertBatch(t, 4, -1); len(pub.events) != 0 {
+		t.Fatalf("expected a negative threshold to disable alerts, got %v", pub.events)
+	}
+}
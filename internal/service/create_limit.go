@@ -0,0 +1,42 @@
+package service
+
+import "errors"
+
+// ErrCreateSaturated is returned by Create when the configured number of
+// concurrent writes is already in flight.
+var ErrCreateSaturated = errors.New("too many concurrent message writes, try again later")
+
+// WithCreateConcurrency caps how many Create calls may write to the
+// repository at once. Calls beyond the cap fail fast with
+// ErrCreateSaturated instead of queueing on the database. This protects the
+// write path independently of the HTTP connection limit. A non-positive n
+// means unlimited.
+func WithCreateConcurrency(n int) Option {
+	return func(s *messageService) {
+		if n > 0 {
+			s.createSlots = make(chan struct{}, n)
+		}
+	}
+}
+
+// acquireCreate takes a write slot without blocking, reporting false when
+// all are in use. Every successful acquire must be paired with
+// releaseCreate.
+func (s *messageService) acquireCreate() bool {
+	if s.createSlots == nil {
+		return true
+	}
+	select {
+	case s.createSlots <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+// releaseCreate frees a slot taken by acquireCreate.
+func (s *messageService) releaseCreate() {
+	if s.createSlots != nil {
+		<-s.createSlots
+	}
+}
@@ -0,0 +1,70 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	domain "github.com/oggyb/insider-assessment/internal/domain/message"
+)
+
+// blockingSaveRepo holds every Save until release is closed, so tests can
+// keep creates in flight.
+type blockingSaveRepo struct {
+	*fakeRepo
+	entered chan struct{}
+	release chan struct{}
+}
+
+func (b *blockingSaveRepo) Save(ctx context.Context, m *domain.Message) error {
+	b.entered <- struct{}{}
+	<-b.release
+	return b.fakeRepo.Save(ctx, m)
+}
+
+func TestCreate_ConcurrencyLimit(t *testing.T) {
+	repo := &blockingSaveRepo{
+		fakeRepo: &fakeRepo{},
+		entered:  make(chan struct{}, 2),
+		release:  make(chan struct{}),
+	}
+	svc := NewMessageService(repo, nil, nil, 0, 0, 0, WithCreateConcurrency(2))
+	ctx := context.Background()
+
+	done := make(chan error, 2)
+	for range 2 {
+		msg := newPendingMessage(t, "+905000000000", "hello")
+		go func() { done <- svc.Create(ctx, msg) }()
+	}
+	<-repo.entered
+	<-repo.entered
+
+	// Both slots are taken: the next create fails fast without saving.
+	if err := svc.Create(ctx, newPendingMessage(t, "+905000000000", "hello")); !errors.Is(err, ErrCreateSaturated) {
+		t.Fatalf("expected ErrCreateSaturated, got %v", err)
+	}
+
+	close(repo.release)
+	for range 2 {
+		if err := <-done; err != nil {
+			t.Fatalf("in-flight Create: %v", err)
+		}
+	}
+
+	// Capacity is back once the in-flight creates finished.
+	if err := svc.Create(ctx, newPendingMessage(t, "+905000000000", "hello")); err != nil {
+		t.Fatalf("Create after release: %v", err)
+	}
+	if len(repo.pending) != 3 {
+		t.Fatalf("expected 3 saved messages, got %d", len(repo.pending))
+	}
+}
+
+func TestCreate_UnlimitedByDefault(t *testing.T) {
+	svc := NewMessageService(&fakeRepo{}, nil, nil, 0, 0, 0, WithCreateConcurrency(0))
+	for range 3 {
+		if err := svc.Create(context.Background(), newPendingMessage(t, "+905000000000", "hello")); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	domain "github.com/oggyb/insider-assessment/internal/domain/message"
+)
+
+func TestCreate_DedupWindowRejectsRecentDuplicate(t *testing.T) {
+	repo := &fakeRepo{}
+	svc := NewMessageService(repo, nil, nil, 0, 0, 0, WithDedupWindow(time.Hour))
+	ctx := context.Background()
+
+	first := newPendingMessage(t, "+905000000001", "hello")
+	if err := svc.Create(ctx, first); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	err := svc.Create(ctx, newPendingMessage(t, "+905000000001", "hello"))
+	if !errors.Is(err, domain.ErrRecentDuplicate) {
+		t.Fatalf("expected ErrRecentDuplicate, got %v", err)
+	}
+	if len(repo.pending) != 1 {
+		t.Fatalf("expected the duplicate not to be saved, got %d messages", len(repo.pending))
+	}
+
+	// A sent copy still counts; other content or recipients do not.
+	first.MarkSent("ext", "{}")
+	if err := svc.Create(ctx, newPendingMessage(t, "+905000000001", "hello")); !errors.Is(err, domain.ErrRecentDuplicate) {
+		t.Fatalf("expected a sent copy to block the duplicate, got %v", err)
+	}
+	if err := svc.Create(ctx, newPendingMessage(t, "+905000000001", "hello again")); err != nil {
+		t.Fatalf("expected different content to be accepted, got %v", err)
+	}
+	if err := svc.Create(ctx, newPendingMessage(t, "+905000000002", "hello")); err != nil {
+		t.Fatalf("expected another recipient to be accepted, got %v", err)
+	}
+}
+
+func TestCreate_DedupWindowAllowsDuplicateAfterWindow(t *testing.T) {
+	repo := &fakeRepo{}
+	svc := NewMessageService(repo, nil, nil, 0, 0, 0, WithDedupWindow(time.Hour))
+	ctx := context.Background()
+
+	first := newPendingMessage(t, "+905000000001", "hello")
+	if err := svc.Create(ctx, first); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	first.CreatedAt = first.CreatedAt.Add(-61 * time.Minute)
+
+	if err := svc.Create(ctx, newPendingMessage(t, "+905000000001", "hello")); err != nil {
+		t.Fatalf("expected the duplicate to be accepted after the window, got %v", err)
+	}
+}
+
+func TestCreate_DedupWindowIgnoresFailedCopies(t *testing.T) {
+	repo := &fakeRepo{}
+	svc := NewMessageService(repo, nil, nil, 0, 0, 0, WithDedupWindow(time.Hour))
+	ctx := context.Background()
+
+	first := newPendingMessage(t, "+905000000001", "hello")
+	if err := svc.Create(ctx, first); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	first.MarkFailed("{}")
+
+	if err := svc.Create(ctx, newPendingMessage(t, "+905000000001", "hello")); err != nil {
+		t.Fatalf("expected a failed copy not to block a resend, got %v", err)
+	}
+}
+
+func TestCreate_DedupWindowDisabledByDefault(t *testing.T) {
+	repo := &fakeRepo{}
+	svc := NewMessageService(repo, nil, nil, 0, 0, 0)
+	ctx := context.Background()
+
+	for range 2 {
+		if err := svc.Create(ctx, newPendingMessage(t, "+905000000001", "hello")); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	domain "github.com/oggyb/insider-assessment/internal/domain/message"
+)
+
+// ErrInvalidExportRange is returned when an export range is empty, reversed
+// or longer than MaxReportDays.
+var ErrInvalidExportRange = errors.New("invalid export range")
+
+// Export calls fn for every message created in [from, until), oldest first,
+// streaming rows from the repository instead of loading them all at once.
+// The range may span at most MaxReportDays. It stops at and returns the
+// first error fn returns.
+func (s *messageService) Export(ctx context.Context, from, until time.Time, fn func(*domain.Message) error) error {
+	if !until.After(from) {
+		return fmt.Errorf("%w: from must be before until", ErrInvalidExportRange)
+	}
+	if until.Sub(from) > MaxReportDays*24*time.Hour {
+		return fmt.Errorf("%w: range must not exceed %d days", ErrInvalidExportRange, MaxReportDays)
+	}
+	return s.repo.StreamByCreatedRange(ctx, from, until, fn)
+}
+
+// StreamSent calls fn for every successfully sent message, most recently
+// sent first, streaming rows from the repository. It stops at and returns
+// the first error fn returns.
+func (s *messageService) StreamSent(ctx context.Context, fn func(*domain.Message) error) error {
+	return s.repo.StreamSent(ctx, fn)
+}
@@ -0,0 +1,118 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	domain "github.com/oggyb/insider-assessment/internal/domain/message"
+)
+
+// TrackLinksTransform is the name under which main registers the
+// LinkTracker when LINK_TRACKING_BASE_URL is set.
+const TrackLinksTransform = "track-links"
+
+// urlPattern matches http(s) URLs up to the next whitespace.
+var urlPattern = regexp.MustCompile(`https?://\S+`)
+
+// LinkTracker is a ContentTransformer that replaces every http(s) URL in
+// a message with a short tracking URL, baseURL followed by a token stored
+// with the original URL, so following it can be counted before
+// redirecting. URLs starting with baseURL or one of the skipped prefixes
+// (e.g. opt-out links) are left alone, which also keeps retries from
+// wrapping a tracking URL again.
+type LinkTracker struct {
+	links   domain.LinkRepository
+	baseURL string
+	skip    []string
+}
+
+// NewLinkTracker creates a LinkTracker storing its links in links.
+func NewLinkTracker(links domain.LinkRepository, baseURL string, skipPrefixes ...string) *LinkTracker {
+	t := &LinkTracker{links: links, baseURL: strings.TrimRight(strings.TrimSpace(baseURL), "/")}
+	for _, p := range append([]string{t.baseURL}, skipPrefixes...) {
+		if p = strings.TrimSpace(p); p != "" {
+			t.skip = append(t.skip, p)
+		}
+	}
+	return t
+}
+
+// Transform implements ContentTransformer. If a link cannot be stored the
+// content is left unchanged and the error fails the message.
+func (t *LinkTracker) Transform(ctx context.Context, msg *domain.Message) error {
+	var saveErr error
+	content := urlPattern.ReplaceAllStringFunc(msg.Content, func(match string) string {
+		target, trailing := splitTrailingPunctuation(match)
+		if saveErr != nil || t.skipped(target) {
+			return match
+		}
+
+		l, err := domain.NewLink(target, msg.ID)
+		if errors.Is(err, domain.ErrInvalidLinkURL) {
+			return match
+		}
+		if err == nil {
+			err = t.links.SaveLink(ctx, l)
+		}
+		if err != nil {
+			saveErr = err
+			return match
+		}
+		return t.baseURL + "/" + l.Token + trailing
+	})
+	if saveErr != nil {
+		return fmt.Errorf("track link: %w", saveErr)
+	}
+
+	msg.Content = content
+	return nil
+}
+
+// skipped reports whether target must not be rewritten.
+func (t *LinkTracker) skipped(target string) bool {
+	for _, p := range t.skip {
+		if strings.HasPrefix(target, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// splitTrailingPunctuation separates punctuation that ends the sentence
+// rather than the URL, e.g. the dot in "see https://example.com.". A
+// closing parenthesis stays when the URL opened one.
+func splitTrailingPunctuation(match string) (target, trailing string) {
+	target = match
+	for target != "" {
+		last := target[len(target)-1]
+		if strings.IndexByte(`.,;:!?'"`, last) < 0 &&
+			(last != ')' || strings.Count(target, "(") >= strings.Count(target, ")")) {
+			break
+		}
+		target = target[:len(target)-1]
+	}
+	return target, match[len(target):]
+}
+
+// WithLinks lets FollowLink resolve the tracking links written by a
+// LinkTracker. A nil r makes every link unknown.
+func WithLinks(r domain.LinkRepository) Option {
+	return func(s *messageService) {
+		s.links = r
+	}
+}
+
+// FollowLink records a click on the tracking link behind token and returns
+// the link, whose URL the caller redirects to. It returns
+// domain.ErrLinkNotFound for unknown tokens or when WithLinks is not set.
+func (s *messageService) FollowLink(ctx context.Context, token string) (*domain.Link, error) {
+	token = strings.TrimSpace(token)
+	if s.links == nil || token == "" {
+		return nil, domain.ErrLinkNotFound
+	}
+	return s.links.RecordLinkClick(ctx, token, time.Now())
+}
@@ -0,0 +1,180 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	domain "github.com/oggyb/insider-assessment/internal/domain/message"
+	"github.com/oggyb/insider-assessment/internal/sms/smstest"
+)
+
+// fakeLinks is an in-memory domain.LinkRepository keyed by token.
+type fakeLinks struct {
+	mu      sync.Mutex
+	byToken map[string]*domain.Link
+	saveErr error
+}
+
+func newFakeLinks() *fakeLinks {
+	return &fakeLinks{byToken: map[string]*domain.Link{}}
+}
+
+func (f *fakeLinks) SaveLink(ctx context.Context, l *domain.Link) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.saveErr != nil {
+		return f.saveErr
+	}
+	f.byToken[l.Token] = l
+	return nil
+}
+
+func (f *fakeLinks) RecordLinkClick(ctx context.Context, token string, at time.Time) (*domain.Link, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	l, ok := f.byToken[token]
+	if !ok {
+		return nil, domain.ErrLinkNotFound
+	}
+	l.Clicks++
+	if l.FirstClickedAt == nil {
+		l.FirstClickedAt = &at
+	}
+	l.LastClickedAt = &at
+	return l, nil
+}
+
+// target returns the URL stored behind a tracking URL.
+func (f *fakeLinks) target(t *testing.T, trackingURL string) string {
+	t.Helper()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	l, ok := f.byToken[strings.TrimPrefix(trackingURL, linkBase+"/")]
+	if !ok {
+		t.Fatalf("no link stored for %s", trackingURL)
+	}
+	return l.URL
+}
+
+const linkBase = "https://sms.example.com/l"
+
+func TestLinkTracker_RewritesURLs(t *testing.T) {
+	links := newFakeLinks()
+	tracker := NewLinkTracker(links, linkBase+"/", optOutBase)
+	msg := newPendingMessage(t, "+905000000001",
+		"Sale at https://shop.example.com/a?b=1, see (https://example.com/x_(y)). Stop: "+optOutBase+"/tok")
+
+	if err := tracker.Transform(context.Background(), msg); err != nil {
+		t.Fatalf("Transform: %v", err)
+	}
+
+	words := strings.Fields(msg.Content)
+	if len(words) != 7 || words[0] != "Sale" || !strings.HasSuffix(words[2], ",") || !strings.HasSuffix(words[4], ").") {
+		t.Fatalf("unexpected rewritten content %q", msg.Content)
+	}
+	first := strings.TrimSuffix(words[2], ",")
+	second := strings.TrimSuffix(strings.TrimPrefix(words[4], "("), ").")
+	if got := links.target(t, first); got != "https://shop.example.com/a?b=1" {
+		t.Fatalf("expected the first URL to be stored, got %q", got)
+	}
+	if got := links.target(t, second); got != "https://example.com/x_(y)" {
+		t.Fatalf("expected the second URL with its parentheses, got %q", got)
+	}
+	if words[6] != optOutBase+"/tok" {
+		t.Fatalf("expected the opt-out link to be kept, got %q", words[6])
+	}
+	for _, l := range links.byToken {
+		if l.MessageID != msg.ID {
+			t.Fatalf("expected links to carry the message ID, got %s", l.MessageID)
+		}
+	}
+
+	// A retry sends the stored, already rewritten content unchanged.
+	rewritten := msg.Content
+	if err := tracker.Transform(context.Background(), msg); err != nil {
+		t.Fatalf("Transform: %v", err)
+	}
+	if msg.Content != rewritten || len(links.byToken) != 2 {
+		t.Fatalf("expected tracking URLs not to be wrapped again, got %q", msg.Content)
+	}
+}
+
+func TestLinkTracker_SaveErrorKeepsContent(t *testing.T) {
+	links := newFakeLinks()
+	links.saveErr = errors.New("db down")
+	msg := newPendingMessage(t, "+905000000001", "Go to https://example.com")
+
+	err := NewLinkTracker(links, linkBase).Transform(context.Background(), msg)
+	if err == nil || !strings.Contains(err.Error(), "db down") {
+		t.Fatalf("expected the save error, got %v", err)
+	}
+	if msg.Content != "Go to https://example.com" {
+		t.Fatalf("expected the content to be left unchanged, got %q", msg.Content)
+	}
+}
+
+func TestFollowLink_RecordsClicks(t *testing.T) {
+	links := newFakeLinks()
+	msg := newPendingMessage(t, "+905000000001", "Go to https://example.com/offer")
+	if err := NewLinkTracker(links, linkBase).Transform(context.Background(), msg); err != nil {
+		t.Fatalf("Transform: %v", err)
+	}
+	token := strings.TrimPrefix(strings.Fields(msg.Content)[2], linkBase+"/")
+
+	svc := NewMessageService(&fakeRepo{}, nil, nil, 0, 0, 0, WithLinks(links))
+	for range 2 {
+		if _, err := svc.FollowLink(context.Background(), token); err != nil {
+			t.Fatalf("FollowLink: %v", err)
+		}
+	}
+
+	l, err := svc.FollowLink(context.Background(), token)
+	if err != nil {
+		t.Fatalf("FollowLink: %v", err)
+	}
+	if l.URL != "https://example.com/offer" || l.Clicks != 3 || l.FirstClickedAt == nil || l.LastClickedAt == nil {
+		t.Fatalf("expected 3 recorded clicks on the original URL, got %+v", l)
+	}
+
+	if _, err := svc.FollowLink(context.Background(), "unknown"); !errors.Is(err, domain.ErrLinkNotFound) {
+		t.Fatalf("expected ErrLinkNotFound, got %v", err)
+	}
+	if _, err := NewMessageService(&fakeRepo{}, nil, nil, 0, 0, 0).FollowLink(context.Background(), token); !errors.Is(err, domain.ErrLinkNotFound) {
+		t.Fatalf("expected ErrLinkNotFound without links, got %v", err)
+	}
+}
+
+func TestProcessBatch_TrackLinksTransformSendsAndStoresTrackingURL(t *testing.T) {
+	repo := &fakeRepo{}
+	msg := newPendingMessage(t, "+905000000001", "Go to https://example.com")
+	_ = repo.Save(context.Background(), msg)
+
+	links := newFakeLinks()
+	reg := NewTransformerRegistry()
+	reg.Register(TrackLinksTransform, NewLinkTracker(links, linkBase))
+	transformer, err := reg.Build([]string{"track-links"})
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+
+	client := smstest.NewFakeClient()
+	svc := NewMessageService(repo, client, nil, 10, 1, time.Second, WithContentTransformer(transformer))
+	if _, err := svc.ProcessBatch(context.Background()); err != nil {
+		t.Fatalf("ProcessBatch: %v", err)
+	}
+
+	calls := client.Calls()
+	if len(calls) != 1 || !strings.HasPrefix(calls[0].Content, "Go to "+linkBase+"/") {
+		t.Fatalf("expected the tracking URL to be sent, got %+v", calls)
+	}
+	if msg.Status != domain.StatusSuccess || msg.Content != calls[0].Content {
+		t.Fatalf("expected SUCCESS with the sent content stored, got %s %q", msg.Status, msg.Content)
+	}
+	if got := links.target(t, strings.Fields(msg.Content)[2]); got != "https://example.com" {
+		t.Fatalf("expected the original URL to be stored, got %q", got)
+	}
+}
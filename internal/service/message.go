@@ -2,18 +2,82 @@ package service
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"github.com/google/uuid"
 	"github.com/oggyb/insider-assessment/internal/cache"
 	domain "github.com/oggyb/insider-assessment/internal/domain/message"
+	"github.com/oggyb/insider-assessment/internal/reporter"
 	"github.com/oggyb/insider-assessment/internal/sms"
+	"github.com/oggyb/insider-assessment/internal/timeutil"
 	"log"
+	"runtime/debug"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
+var (
+	// ErrPageOutOfRange is returned by GetSent when the requested page lies
+	// beyond the last page for the given limit. The total is still returned
+	// alongside it so callers can decide how to present the result.
+	ErrPageOutOfRange = errors.New("requested page is out of range")
+
+	// ErrDuplicateExternalID is reported when the provider assigns a message ID
+	// that was already assigned to a different message of ours.
+	ErrDuplicateExternalID = errors.New("provider returned a duplicate external message ID")
+)
+
+const (
+	// expiredReason is recorded on messages transitioned to EXPIRED by the reaper.
+	expiredReason = "expired before it could be sent"
+
+	// defaultStuckTimeout is how long a message may stay PROCESSING before
+	// ResetStuck assumes its batch crashed and returns it to PENDING.
+	defaultStuckTimeout = 5 * time.Minute
+
+	// releaseTimeout bounds returning undispatched claims to PENDING, which
+	// runs even if the batch context has already ended.
+	releaseTimeout = 5 * time.Second
+
+	// externalIDTTL is how long sent-message metadata and external ID claims
+	// are kept in the cache.
+	externalIDTTL = 24 * time.Hour
+)
+
+// HealthGate reports whether the SMS provider is currently considered
+// healthy. ProcessBatch skips sending while it reports false.
+type HealthGate interface {
+	Healthy() bool
+}
+
 type MessageService interface {
+	Create(ctx context.Context, msg *domain.Message) error
 	GetSent(ctx context.Context, page, limit int) ([]*domain.Message, int64, error)
-	ProcessBatch(ctx context.Context) error
+	List(ctx context.Context, f domain.ListFilter, page, limit int) ([]*domain.Message, int64, error)
+	GetTimeline(ctx context.Context, id uuid.UUID) ([]*domain.StatusEvent, error)
+	Retry(ctx context.Context, id uuid.UUID) (*domain.Message, error)
+	Delete(ctx context.Context, id uuid.UUID) error
+	ClearDedup(ctx context.Context, to string) (int64, error)
+	DailyReport(ctx context.Context, from, until time.Time) ([]domain.DayStat, error)
+	SendLatency(ctx context.Context, from, until time.Time) (avg, p95 time.Duration, err error)
+	TopRecipients(ctx context.Context, from, until time.Time, limit int) ([]domain.RecipientCount, error)
+	OldestPendingAge(ctx context.Context) (PendingAge, error)
+	Export(ctx context.Context, from, until time.Time, fn func(*domain.Message) error) error
+	StreamSent(ctx context.Context, fn func(*domain.Message) error) error
+	ProcessBatch(ctx context.Context) (domain.BatchResult, error)
+	ListBatchRuns(ctx context.Context, page, limit int) ([]*domain.BatchRun, int64, error)
+	ResolveOptOut(ctx context.Context, token string) (*domain.OptOutToken, error)
+	FollowLink(ctx context.Context, token string) (*domain.Link, error)
+	Multicast(ctx context.Context, msgs []*domain.Message) (MulticastResult, error)
+	GroupStatus(id uuid.UUID) (GroupStatus, error)
+	Ping(ctx context.Context) error
+	ReapExpired(ctx context.Context) (int64, error)
+	ResetStuck(ctx context.Context) (int64, error)
+	WorkerConfig() WorkerConfig
+	UpdateWorkerConfig(u WorkerConfigUpdate) (WorkerConfig, error)
+	Close() error
 }
 
 type messageService struct {
@@ -21,10 +85,220 @@ type messageService struct {
 	smsClient sms.Client
 	cache     cache.Cache
 
-	// Batch processing configuration, injected from config at startup.
+	// Batch processing configuration, injected from config at startup and
+	// adjustable at runtime via UpdateWorkerConfig; guarded by cfgMu.
+	cfgMu             sync.RWMutex
 	batchSize         int
 	maxWorkers        int
 	perMessageTimeout time.Duration
+
+	// reporter receives panics recovered from worker goroutines.
+	reporter reporter.ErrorReporter
+
+	// router selects the SMS client per message. It defaults to a router
+	// that only knows smsClient as the default provider.
+	router *sms.Router
+
+	// healthGate, when set, pauses batch sends during provider outages.
+	healthGate HealthGate
+
+	// batchBudget caps how long a batch keeps dispatching messages to
+	// workers; 0 means no cap beyond the batch context.
+	batchBudget time.Duration
+
+	// rampDelay spaces out worker start-up within a batch; 0 starts all
+	// workers at once.
+	rampDelay time.Duration
+
+	// stuckTimeout is how old a PROCESSING claim must be before ResetStuck
+	// returns it to PENDING.
+	stuckTimeout time.Duration
+
+	// events, when set, receives HighFailureRate after batches whose failure
+	// share exceeds failureAlertPercent.
+	events              EventPublisher
+	failureAlertPercent int
+
+	// pendingAgeAlert flags the queue unhealthy once the oldest pending
+	// message is older than this (0 disables); pendingAgeEvents then
+	// receives StalePending.
+	pendingAgeAlert  time.Duration
+	pendingAgeEvents EventPublisher
+
+	// templates resolves message templates at send time; nil means
+	// templated messages fail.
+	templates domain.TemplateRepository
+
+	// dailySendCap limits send attempts per UTC day, counted in the cache;
+	// 0 means unlimited.
+	dailySendCap int64
+
+	// recipientPolicy, when set, is re-checked for every message right
+	// before it is sent.
+	recipientPolicy RecipientPolicy
+
+	// optOuts, when set, issues the per-recipient tokens of the opt-out
+	// links appended to content under optOutBaseURL.
+	optOuts       domain.OptOutRepository
+	optOutBaseURL string
+
+	// links resolves the click tracking links written by a LinkTracker;
+	// nil means every link is unknown.
+	links domain.LinkRepository
+
+	// maxRetries is how many times a failed provider send is retried, with
+	// a backoff from retryBase up to retryLimit; 0 fails it right away.
+	maxRetries int
+	retryBase  time.Duration
+	retryLimit time.Duration
+
+	// createSlots bounds concurrent Create writes; nil means unlimited.
+	createSlots chan struct{}
+
+	// transformer rewrites content right before sending; nil sends it as
+	// stored.
+	transformer ContentTransformer
+
+	// adaptive, when set, adjusts batchSize after each batch based on
+	// provider latency.
+	adaptive *adaptiveBatch
+
+	// compactSentRaw replaces the provider body of successful sends with
+	// a summary holding only the external ID; failures keep the full body.
+	compactSentRaw bool
+
+	// statusRecheck re-reads each message's status right before sending
+	// and skips messages that are no longer waiting to be sent.
+	statusRecheck bool
+
+	// dedupWindow rejects a message whose recipient and content match one
+	// sent or queued within it; 0 disables the check.
+	dedupWindow time.Duration
+
+	// contentRules are applied to content produced or changed after
+	// creation: rendered templates, opt-out links and transforms.
+	contentRules domain.ContentRules
+
+	// pool runs batch workers on long-lived goroutines; nil starts them
+	// per batch.
+	pool *workerPool
+
+	// Multicast limits: recipients per request, the size above which a
+	// group is created in the background, and slots bounding those
+	// background groups. groups tracks their progress, and multicastWG
+	// the background groups still being written, so Close can wait for them.
+	multicastMaxRecipients  int
+	multicastAsyncThreshold int
+	multicastSlots          chan struct{}
+	multicastWG             sync.WaitGroup
+	groups                  *groupTracker
+}
+
+// Option customizes optional behaviour of the message service.
+type Option func(*messageService)
+
+// WithErrorReporter sets the sink used to report panics recovered in workers.
+func WithErrorReporter(r reporter.ErrorReporter) Option {
+	return func(s *messageService) {
+		if r != nil {
+			s.reporter = r
+		}
+	}
+}
+
+// WithProviderRouter enables per-message provider selection. Messages without
+// a provider are sent through the router's default client.
+func WithProviderRouter(r *sms.Router) Option {
+	return func(s *messageService) {
+		if r != nil {
+			s.router = r
+		}
+	}
+}
+
+// WithHealthGate pauses ProcessBatch while the gate reports the provider as
+// unhealthy. Messages are left PENDING and picked up once it recovers.
+func WithHealthGate(g HealthGate) Option {
+	return func(s *messageService) {
+		s.healthGate = g
+	}
+}
+
+// WithBatchBudget stops a batch from dispatching new messages once d has
+// elapsed since it started. Messages not yet dispatched stay PENDING for the
+// next batch; messages already being sent are not interrupted. A non-positive
+// d disables the budget.
+func WithBatchBudget(d time.Duration) Option {
+	return func(s *messageService) {
+		if d > 0 {
+			s.batchBudget = d
+		}
+	}
+}
+
+// WithRampDelay starts batch workers one after another, d apart, instead of
+// all at once, to avoid an initial burst against the provider. A
+// non-positive d disables the ramp.
+func WithRampDelay(d time.Duration) Option {
+	return func(s *messageService) {
+		if d > 0 {
+			s.rampDelay = d
+		}
+	}
+}
+
+// WithStuckTimeout sets how long a message may stay PROCESSING before it is
+// considered abandoned by a crashed batch and returned to PENDING. It must
+// comfortably exceed the batch timeout, or in-flight sends get duplicated.
+// A non-positive d keeps the default of 5 minutes.
+func WithStuckTimeout(d time.Duration) Option {
+	return func(s *messageService) {
+		if d > 0 {
+			s.stuckTimeout = d
+		}
+	}
+}
+
+// WithStoreRawOnSuccess controls whether successful sends keep the provider's
+// full response body. When store is false only {"messageId": ...} is
+// recorded; failed and retried sends always keep the full body for
+// debugging. The default stores it.
+func WithStoreRawOnSuccess(store bool) Option {
+	return func(s *messageService) {
+		s.compactSentRaw = !store
+	}
+}
+
+// WithStatusRecheck makes every send first re-read the message's status
+// from the primary and skip it when it is no longer PENDING or PROCESSING,
+// e.g. a message another instance already sent after a crash left its
+// claim behind. It costs one query per message and is off by default.
+func WithStatusRecheck(on bool) Option {
+	return func(s *messageService) {
+		s.statusRecheck = on
+	}
+}
+
+// WithDedupWindow makes Create and Multicast reject a message with
+// domain.ErrRecentDuplicate when a PENDING, PROCESSING or SUCCESS message
+// with the same recipient and content was created within window. Unlike
+// the cache and MESSAGE_DEDUP_CONTENT it covers sent messages and is read
+// from the database, so it survives a cache flush. The lookup and the
+// insert are not atomic, so two identical concurrent requests may both
+// pass. A non-positive window disables the check.
+func WithDedupWindow(window time.Duration) Option {
+	return func(s *messageService) {
+		s.dedupWindow = max(window, 0)
+	}
+}
+
+// WithContentRules applies r to content the service produces or changes
+// after creation (rendered templates, opt-out links and transforms). It
+// should match the rules the handler creates messages with.
+func WithContentRules(r domain.ContentRules) Option {
+	return func(s *messageService) {
+		s.contentRules = r
+	}
 }
 
 // NewMessageService creates a message service with the given dependencies
@@ -37,6 +311,7 @@ func NewMessageService(
 	batchSize int,
 	maxWorkers int,
 	perMessageTimeout time.Duration,
+	opts ...Option,
 ) MessageService {
 	// Apply sane defaults if config values are missing or invalid.
 	if batchSize <= 0 {
@@ -49,38 +324,342 @@ func NewMessageService(
 		perMessageTimeout = 5 * time.Second
 	}
 
-	return &messageService{
+	s := &messageService{
 		repo:              repo,
 		smsClient:         smsClient,
 		cache:             cache,
 		batchSize:         batchSize,
 		maxWorkers:        maxWorkers,
 		perMessageTimeout: perMessageTimeout,
+		reporter:          reporter.Noop{},
+		router:            sms.NewRouter(smsClient),
+		stuckTimeout:      defaultStuckTimeout,
+
+		multicastMaxRecipients:  defaultMulticastMaxRecipients,
+		multicastAsyncThreshold: defaultMulticastAsyncThreshold,
+		multicastSlots:          make(chan struct{}, defaultMulticastMaxGroups),
+		groups:                  newGroupTracker(),
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
+}
+
+// Create persists a new pending message. The message is expected to be
+// built through domain.NewMessage so its invariants already hold. It
+// returns ErrCreateSaturated when WithCreateConcurrency's limit is reached.
+func (s *messageService) Create(ctx context.Context, msg *domain.Message) error {
+	if !s.acquireCreate() {
+		return ErrCreateSaturated
+	}
+	defer s.releaseCreate()
+
+	return s.create(ctx, msg)
+}
+
+// create saves msg and records its creation, without taking a write slot.
+func (s *messageService) create(ctx context.Context, msg *domain.Message) error {
+	// Templated messages get their link when rendered at send time.
+	if msg.TemplateID == nil {
+		if err := s.appendOptOutLink(ctx, msg); err != nil {
+			return err
+		}
+	}
+
+	if err := s.checkRecentDuplicate(ctx, msg); err != nil {
+		return err
+	}
+
+	if err := s.repo.Save(ctx, msg); err != nil {
+		return fmt.Errorf("failed to save message: %w", err)
+	}
+
+	// Best-effort: the message exists even if its audit entry does not.
+	event := domain.NewStatusEvent(msg.ID, "", msg.Status, msg.CreatedAt)
+	if err := s.repo.AddStatusEvents(ctx, event); err != nil {
+		log.Printf("[Service] Failed to record creation of %s: %v", msg.ID.String(), err)
+	}
+	return nil
+}
+
+// checkRecentDuplicate returns domain.ErrRecentDuplicate, naming the
+// existing message, when WithDedupWindow is set and an identical message
+// was created within the window.
+func (s *messageService) checkRecentDuplicate(ctx context.Context, msg *domain.Message) error {
+	if s.dedupWindow <= 0 {
+		return nil
+	}
+
+	existing, err := s.repo.FindRecentDuplicate(ctx, msg.To, msg.Content, time.Now().Add(-s.dedupWindow))
+	switch {
+	case errors.Is(err, domain.ErrNotFound):
+		return nil
+	case err != nil:
+		return fmt.Errorf("failed to check for duplicates: %w", err)
+	default:
+		return fmt.Errorf("%w (message %s)", domain.ErrRecentDuplicate, existing.ID)
+	}
+}
+
+// GetTimeline returns the status transitions of a message, oldest first.
+// It returns domain.ErrNotFound if the message does not exist.
+func (s *messageService) GetTimeline(ctx context.Context, id uuid.UUID) ([]*domain.StatusEvent, error) {
+	if _, err := s.repo.GetByID(ctx, id); err != nil {
+		return nil, err
+	}
+	return s.repo.GetTimeline(ctx, id)
+}
+
+// Retry requeues a single FAILED message so the next batch sends it again.
+// It returns domain.ErrNotFound for unknown IDs and domain.ErrNotRetryable
+// for messages that are not FAILED.
+func (s *messageService) Retry(ctx context.Context, id uuid.UUID) (*domain.Message, error) {
+	msg, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	from := msg.Status
+	if err := msg.Requeue(); err != nil {
+		return nil, err
+	}
+	// The status read above may be stale (replica) or raced by another
+	// retry; the repository only requeues a message that is still FAILED.
+	if err := s.repo.Requeue(ctx, msg); err != nil {
+		if errors.Is(err, domain.ErrNotRetryable) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to requeue message: %w", err)
+	}
+	s.recordTransition(ctx, msg, from)
+
+	return msg, nil
+}
+
+// Delete soft-deletes a message so it no longer shows up in listings or
+// batches. It returns domain.ErrNotFound for unknown IDs.
+func (s *messageService) Delete(ctx context.Context, id uuid.UUID) error {
+	return s.repo.SoftDelete(ctx, id)
+}
+
+// ClearDedup lets an identical message be sent to the recipient again by
+// releasing the de-duplication claims of its unsent messages
+// (MESSAGE_DEDUP_CONTENT). The claims are the content hashes behind the
+// database's partial unique index, not cache entries, so nothing is
+// evicted from the cache. It returns how many were released. It does not
+// bypass WithDedupWindow: that check looks at the messages themselves, so
+// an identical message is still rejected until the window has passed.
+func (s *messageService) ClearDedup(ctx context.Context, to string) (int64, error) {
+	return s.repo.ClearContentDedup(ctx, to)
+}
+
+// updateStatus persists msg and, if its status differs from "from", appends
+// the transition to the audit timeline. Failing to record the event is
+// logged but does not fail the update.
+func (s *messageService) updateStatus(ctx context.Context, msg *domain.Message, from domain.Status) error {
+	if err := s.repo.UpdateStatus(ctx, msg); err != nil {
+		return err
+	}
+	s.recordTransition(ctx, msg, from)
+	return nil
+}
+
+// recordTransition appends msg's change from "from" to its current status
+// to the audit timeline, if it changed. Failures are only logged.
+func (s *messageService) recordTransition(ctx context.Context, msg *domain.Message, from domain.Status) {
+	if from == msg.Status {
+		return
+	}
+
+	event := domain.NewStatusEvent(msg.ID, from, msg.Status, time.Now())
+	if err := s.repo.AddStatusEvents(ctx, event); err != nil {
+		log.Printf("[Service] Failed to record status change of %s: %v", msg.ID.String(), err)
+	}
+}
+
+// ReapExpired transitions pending messages whose expiry has passed to
+// EXPIRED so they are never handed to the provider late.
+func (s *messageService) ReapExpired(ctx context.Context) (int64, error) {
+	now := time.Now()
+	ids, err := s.repo.ExpirePending(ctx, now, expiredReason)
+	if err != nil {
+		return 0, fmt.Errorf("failed to expire pending messages: %w", err)
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	log.Printf("[Service] Expired %d stale pending messages.", len(ids))
+
+	events := make([]*domain.StatusEvent, len(ids))
+	for i, id := range ids {
+		events[i] = domain.NewStatusEvent(id, domain.StatusPending, domain.StatusExpired, now)
+	}
+	if err := s.repo.AddStatusEvents(ctx, events...); err != nil {
+		log.Printf("[Service] Failed to record expiry of %d messages: %v", len(ids), err)
+	}
+	return int64(len(ids)), nil
+}
+
+// ResetStuck returns messages that have been PROCESSING for longer than the
+// stuck timeout to PENDING, so a batch that crashed between sending and
+// confirming does not strand them. Such messages may be sent again.
+func (s *messageService) ResetStuck(ctx context.Context) (int64, error) {
+	ids, err := s.repo.ResetStuck(ctx, time.Now().Add(-s.stuckTimeout))
+	if err != nil {
+		return 0, fmt.Errorf("failed to reset stuck messages: %w", err)
+	}
+	if len(ids) > 0 {
+		log.Printf("[Service] Reset %d stuck PROCESSING messages to PENDING.", len(ids))
+	}
+	return int64(len(ids)), nil
+}
+
+// claimPending fetches up to limit pending messages and marks them
+// PROCESSING in one transaction, so no other batch can pick them up.
+func (s *messageService) claimPending(ctx context.Context, limit int) ([]*domain.Message, error) {
+	var claimed []*domain.Message
+
+	err := s.repo.WithTx(ctx, func(tx domain.Repository) error {
+		messages, err := tx.GetPending(ctx, limit)
+		if err != nil {
+			return err
+		}
+		for _, msg := range messages {
+			msg.MarkProcessing()
+			if err := tx.UpdateStatus(ctx, msg); err != nil {
+				return err
+			}
+		}
+		claimed = messages
+		return nil
+	})
+	return claimed, err
+}
+
+// releaseClaims returns claimed messages that were never handed to a
+// worker to PENDING. Failures are logged; ResetStuck recovers them later.
+func (s *messageService) releaseClaims(ctx context.Context, messages []*domain.Message) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), releaseTimeout)
+	defer cancel()
+
+	for _, msg := range messages {
+		msg.Release()
+		if err := s.repo.UpdateStatus(ctx, msg); err != nil {
+			log.Printf("[Service] Failed to release claim on %s: %v", msg.ID.String(), err)
+		}
 	}
 }
 
+// GetSent returns a page of sent messages and the total number of sent
+// records. If the page is past the last page, the (empty) items and total
+// are returned together with ErrPageOutOfRange.
 func (s *messageService) GetSent(ctx context.Context, page, limit int) ([]*domain.Message, int64, error) {
-	return s.repo.GetSent(ctx, page, limit)
+	items, total, err := s.repo.GetSent(ctx, page, limit)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if isPageOutOfRange(page, limit, total) {
+		return items, total, ErrPageOutOfRange
+	}
+
+	return items, total, nil
+}
+
+// List returns a page of messages of any status matching f and the total
+// number of matches.
+func (s *messageService) List(ctx context.Context, f domain.ListFilter, page, limit int) ([]*domain.Message, int64, error) {
+	return s.repo.List(ctx, f, page, limit)
+}
+
+// isPageOutOfRange reports whether page lies beyond the last page for the
+// given limit and total. The first page is always considered in range,
+// even when there are no records at all.
+func isPageOutOfRange(page, limit int, total int64) bool {
+	if page <= 1 || limit <= 0 {
+		return false
+	}
+	totalPages := (total + int64(limit) - 1) / int64(limit)
+	return int64(page) > totalPages
 }
 
 // ProcessBatch pulls a batch of pending messages from the repository and
 // processes them using a small worker pool. The batch size, worker count
 // and per-message timeout are provided at construction time.
-func (s *messageService) ProcessBatch(ctx context.Context) error {
-	batchSize := s.batchSize
-	maxWorkers := s.maxWorkers
-	perMessageTimeout := s.perMessageTimeout
+//
+// Before fetching, stale messages are reaped so they are marked EXPIRED
+// instead of silently lingering as PENDING, and abandoned PROCESSING claims
+// are reset (see ResetStuck).
+//
+// Delivery is at-least-once. Messages are claimed as PROCESSING in one
+// transaction before sending, and each outcome is confirmed in another
+// transaction together with its timeline event. If the process dies between
+// a send and its confirmation, the message stays PROCESSING until
+// ResetStuck returns it to PENDING, and it is sent again. Claims that were
+// never handed to a worker (budget spent, context cancelled) are released
+// right away.
+//
+// Messages for a provider whose client implements sms.BatchSender are
+// handed to a worker in groups and sent with one SendBatch call per group;
+// all others are sent one by one with Send.
+//
+// A send never outlives ctx: when ctx has a deadline (the scheduler's batch
+// timeout) closer than a message's timeout, the message only gets the time
+// left before it.
+//
+// The returned BatchResult counts the messages handed to workers and how
+// many of them ended up sent or failed. If a failure alert is configured
+// (see WithFailureAlert) and too many of them failed, HighFailureRate is
+// published before returning.
+func (s *messageService) ProcessBatch(ctx context.Context) (domain.BatchResult, error) {
+	batchStart := time.Now()
+	result := domain.BatchResult{StartedAt: batchStart}
 
-	// Fetch pending messages from the repository.
-	messages, err := s.repo.GetPending(ctx, batchSize)
+	// Snapshot the settings so a concurrent update does not affect this batch.
+	wc := s.WorkerConfig()
+	batchSize := wc.BatchSize
+	maxWorkers := wc.MaxWorkers
+	perMessageTimeout := wc.PerMessageTimeout
+
+	// Best-effort: a failing reaper must not block sending; GetPending
+	// skips expired rows on its own anyway.
+	if _, err := s.ReapExpired(ctx); err != nil {
+		log.Printf("[Service] %v", err)
+	}
+	if _, err := s.ResetStuck(ctx); err != nil {
+		log.Printf("[Service] %v", err)
+	}
+
+	// Don't burn sends against a provider that is known to be down.
+	if s.healthGate != nil && !s.healthGate.Healthy() {
+		log.Println("[Service] SMS provider unhealthy, skipping batch.")
+		result.Duration = time.Since(batchStart)
+		return result, nil
+	}
+
+	// Leave everything PENDING once today's send cap is used up.
+	if s.dailyCapReached(ctx) {
+		log.Printf("[Service] Daily send cap of %d reached, skipping batch.", s.dailySendCap)
+		result.Duration = time.Since(batchStart)
+		return result, nil
+	}
+
+	// Claim pending messages so concurrent batches skip them.
+	messages, err := s.claimPending(ctx, batchSize)
 	if err != nil {
-		return fmt.Errorf("failed to fetch pending messages: %w", err)
+		result.Duration = time.Since(batchStart)
+		return result, fmt.Errorf("failed to fetch pending messages: %w", err)
 	}
 
 	// Nothing to do; exit quickly so the scheduler can tick again.
 	if len(messages) == 0 {
 		log.Println("[Service] No pending messages to process.")
-		return nil
+		result.Duration = time.Since(batchStart)
+		return result, nil
 	}
 
 	log.Printf(
@@ -88,30 +667,83 @@ func (s *messageService) ProcessBatch(ctx context.Context) error {
 		len(messages), batchSize, maxWorkers,
 	)
 
-	// Decide how many workers we need for this batch.
-	workerCount := len(messages)
+	// Group messages for providers that take several per call; everything
+	// else is a unit of one message.
+	units := s.sendUnits(messages)
+
+	// Decide how many workers we need for this batch. Never start more
+	// workers than there are units: the extra ones would exit at once.
+	workerCount := len(units)
 	if workerCount > maxWorkers {
 		workerCount = maxWorkers
 	}
+	if s.pool != nil && workerCount > s.pool.size {
+		workerCount = s.pool.size
+	}
 	if workerCount <= 0 {
 		workerCount = 1
 	}
 
 	var wg sync.WaitGroup
+	var processed, succeeded, failed atomic.Int64
 
-	// Simple worker pool: each worker processes a "stride" of messages.
+	// Provider latency of this batch, for the adaptive batch size.
+	var sends, sendNanos, timeouts atomic.Int64
+
+	// dispatched[i] is set by the worker that owns index i once it hands the
+	// message over; it is read only after wg.Wait.
+	dispatched := make([]bool, len(messages))
+
+	// record counts the outcome of one handed-over message.
+	record := func(msg *domain.Message, lastDuration *time.Duration, err error) {
+		// Only count messages that actually reached the provider.
+		if msg.SendDuration != nil && msg.SendDuration != lastDuration {
+			sends.Add(1)
+			sendNanos.Add(int64(*msg.SendDuration))
+			if errors.Is(err, context.DeadlineExceeded) {
+				timeouts.Add(1)
+			}
+		}
+
+		switch {
+		case err != nil || msg.Status == domain.StatusFailed:
+			failed.Add(1)
+		case msg.Status == domain.StatusSuccess:
+			succeeded.Add(1)
+		}
+	}
+
+	// timeoutFor is the per-message timeout, unless the message carries
+	// its own.
+	timeoutFor := func(msg *domain.Message) time.Duration {
+		if msg.SendTimeout != nil {
+			return *msg.SendTimeout
+		}
+		return perMessageTimeout
+	}
+
+	// Simple worker pool: each worker processes a "stride" of units.
 	// For example, with 4 workers:
-	//   worker 1: indices 0, 4, 8, ...
-	//   worker 2: indices 1, 5, 9, ...
-	//   worker 3: indices 2, 6, 10, ...
-	//   worker 4: indices 3, 7, 11, ...
+	//   worker 1: units 0, 4, 8, ...
+	//   worker 2: units 1, 5, 9, ...
+	//   worker 3: units 2, 6, 10, ...
+	//   worker 4: units 3, 7, 11, ...
 	for w := 0; w < workerCount; w++ {
+		// Stagger worker start-up; stop launching if the batch is cancelled
+		// meanwhile, leaving the unlaunched workers' messages PENDING.
+		if w > 0 && s.rampDelay > 0 && !timeutil.SleepCtx(ctx, s.rampDelay) {
+			log.Printf("[Service] Context cancelled during worker ramp-up, launched %d of %d workers", w, workerCount)
+			break
+		}
+
 		wg.Add(1)
+		result.Workers++
 
-		go func(workerID, start int) {
+		workerID, start := w+1, w
+		s.startWorker(ctx, func() {
 			defer wg.Done()
 
-			for i := start; i < len(messages); i += workerCount {
+			for u := start; u < len(units); u += workerCount {
 				// If the parent context has been cancelled (e.g. by the scheduler),
 				// stop processing new messages and exit this worker.
 				if ctx.Err() != nil {
@@ -119,78 +751,419 @@ func (s *messageService) ProcessBatch(ctx context.Context) error {
 					return
 				}
 
-				msg := messages[i]
+				// Leave the rest for the next batch once the budget is spent.
+				if s.batchBudget > 0 && time.Since(batchStart) >= s.batchBudget {
+					log.Printf("[Worker %d] Batch budget of %s spent, leaving remaining messages pending",
+						workerID, s.batchBudget)
+					return
+				}
+
+				unit := units[u]
+
+				// Stop once the daily cap is used up; the rest stay PENDING.
+				// A group still sends the messages it could reserve.
+				reserved := 0
+				for range unit.msgs {
+					if !s.reserveSend(ctx) {
+						break
+					}
+					reserved++
+				}
+				if reserved == 0 {
+					log.Printf("[Worker %d] Daily send cap of %d reached, leaving remaining messages pending",
+						workerID, s.dailySendCap)
+					return
+				}
+
+				group := make([]*domain.Message, reserved)
+				lastDurations := make([]*time.Duration, reserved)
+				var timeout time.Duration
+				for j, i := range unit.msgs[:reserved] {
+					group[j] = messages[i]
+					lastDurations[j] = messages[i].SendDuration
+					timeout = max(timeout, timeoutFor(messages[i]))
+					dispatched[i] = true
+				}
+				processed.Add(int64(reserved))
 
-				// Wrap the parent context with a per-message timeout.
-				msgCtx, cancel := context.WithTimeout(ctx, perMessageTimeout)
+				// The batch deadline wins over the message timeout; say so
+				// rather than letting the send fail as a plain timeout.
+				if left, clamped := clampToDeadline(ctx, timeout); clamped {
+					log.Printf("[Worker %d] Only %s left before the batch deadline, shortening the send timeout of %s",
+						workerID, left.Round(time.Millisecond), timeout)
+					timeout = left
+				}
 
-				log.Printf("[Worker %d] is processing.", i)
-				if err := s.processMessage(msgCtx, msg); err != nil {
-					log.Printf("[Worker %d] Failed to process %s: %v",
-						workerID, msg.ID.String(), err)
+				// Wrap the parent context with the (longest) message timeout.
+				msgCtx, cancel := context.WithTimeout(ctx, timeout)
+
+				var errs []error
+				if unit.batch != nil {
+					log.Printf("[Worker %d] is processing a group of %d.", workerID, len(group))
+					errs = s.safeProcessGroup(msgCtx, workerID, unit, group)
+				} else {
+					log.Printf("[Worker %d] is processing.", workerID)
+					errs = []error{s.safeProcessMessage(msgCtx, workerID, unit, group[0])}
+				}
+
+				for j, msg := range group {
+					if errs[j] != nil {
+						log.Printf("[Worker %d] Failed to process %s: %v",
+							workerID, msg.ID.String(), errs[j])
+					}
+					record(msg, lastDurations[j], errs[j])
 				}
 
 				// Make sure we always release the derived context.
 				cancel()
+
+				if reserved < len(unit.msgs) {
+					log.Printf("[Worker %d] Daily send cap of %d reached, leaving remaining messages pending",
+						workerID, s.dailySendCap)
+					return
+				}
 			}
-		}(w+1, w)
+		})
 	}
 
 	// Wait until all workers have finished processing their share.
 	wg.Wait()
 
+	// Hand back whatever the workers did not get to.
+	var undispatched []*domain.Message
+	for i, msg := range messages {
+		if !dispatched[i] {
+			undispatched = append(undispatched, msg)
+		}
+	}
+	if len(undispatched) > 0 {
+		s.releaseClaims(ctx, undispatched)
+	}
+
 	log.Println("[Service] Batch worker pool completed.")
-	return nil
+
+	result.Duration = time.Since(batchStart)
+	result.Processed = int(processed.Load())
+	result.Succeeded = int(succeeded.Load())
+	result.Failed = int(failed.Load())
+
+	s.checkFailureRate(result)
+	s.adaptBatchSize(batchSize, len(messages), batchLatency{
+		sends:    sends.Load(),
+		total:    time.Duration(sendNanos.Load()),
+		timeouts: timeouts.Load(),
+	})
+	return result, nil
 }
 
-// processMessage sends a single pending message via the SMS provider and
-// updates its status in the repository.
+// ListBatchRuns returns a page of recorded batch runs, most recent first.
+func (s *messageService) ListBatchRuns(ctx context.Context, page, limit int) ([]*domain.BatchRun, int64, error) {
+	return s.repo.ListBatchRuns(ctx, page, limit)
+}
+
+// Ping reports whether the database is reachable, for readiness checks.
+func (s *messageService) Ping(ctx context.Context) error {
+	return s.repo.Ping(ctx)
+}
+
+// clampToDeadline returns timeout, or the time left before ctx's deadline
+// if that is shorter, and whether it had to be shortened.
+func clampToDeadline(ctx context.Context, timeout time.Duration) (time.Duration, bool) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return timeout, false
+	}
+	if left := time.Until(deadline); left < timeout {
+		return left, true
+	}
+	return timeout, false
+}
+
+// safeProcessMessage runs processMessage behind a panic guard so that a
+// single misbehaving message cannot take down the worker (or the process).
+// Recovered panics are reported and surfaced as a regular error.
+func (s *messageService) safeProcessMessage(ctx context.Context, workerID int, unit sendUnit, msg *domain.Message) (err error) {
+	defer func() {
+		rec := recover()
+		if rec == nil {
+			return
+		}
+
+		err = fmt.Errorf("panic while processing %s: %v", msg.ID.String(), rec)
+		s.reporter.Report(err, map[string]any{
+			"panic":     rec,
+			"workerId":  workerID,
+			"messageId": msg.ID.String(),
+			"stack":     string(debug.Stack()),
+		})
+	}()
+
+	return s.processMessage(ctx, msg, unit.client, unit.routeErr)
+}
+
+// processMessage sends a single claimed message via the SMS provider and
+// confirms its outcome in the repository.
 //
 // Flow:
+//   - With WithStatusRecheck, skip messages whose stored status is already
+//     terminal (e.g. SUCCESS), leaving the stored row untouched.
+//   - Apply the content transformers (WithContentTransformer); a failing
+//     transform, or transformed content that is blank, too long or not
+//     GSM-7 where required, marks the message as FAILED with the reason.
 //   - Call the SMS client with the message content and recipient.
-//   - On failure: mark the message as FAILED and persist this status.
-//   - On success: mark the message as SUCCESS, persist it, and optionally
+//   - On failure: schedule a retry if any are left (WithSendRetries),
+//     otherwise mark the message as FAILED, and confirm this status.
+//   - On success: mark the message as SUCCESS, confirm it, and optionally
 //     cache the sent timestamp in Redis for quick lookup.
 //
 // The provided context may be cancelled or time out by the caller (e.g. the
 // scheduler), in which case the send operation should respect that.
-func (s *messageService) processMessage(ctx context.Context, msg *domain.Message) error {
+func (s *messageService) processMessage(ctx context.Context, msg *domain.Message, client sms.Client, routeErr error) error {
+	content, ok, err := s.prepareSend(ctx, msg, client, routeErr)
+	if !ok {
+		return err
+	}
+
+	// A per-message encoding overrides the one derived from the content.
+	if msg.Encoding != "" {
+		ctx = sms.ContextWithEncoding(ctx, msg.Encoding)
+	}
+
+	// Try to send the message via the external SMS provider, recording how
+	// long the provider took for the latency stats. Clients that measure
+	// the round trip themselves report it through the recorder; for others
+	// the whole call is timed.
+	sendCtx, latency := sms.ContextWithLatencyRecorder(ctx)
+	start := time.Now()
+	externalID, rawResp, err := client.Send(sendCtx, msg.To, content)
+	took := time.Since(start)
+	if d := latency.Duration(); d > 0 {
+		took = d
+	}
+
+	return s.finishSend(ctx, msg, externalID, rawResp, took, err)
+}
+
+// prepareSend runs the checks that precede a provider call to client, the
+// one msg was routed to (see sendUnits), and returns the content to send. A
+// routeErr fails the message once the other checks passed. ok is false when
+// the message was finished without a send (skipped, expired or marked
+// FAILED); the error, if any, says why.
+func (s *messageService) prepareSend(ctx context.Context, msg *domain.Message, client sms.Client, routeErr error) (string, bool, error) {
 	id := msg.ID.String()
 
-	// Try to send the message via the external SMS provider.
-	externalID, rawResp, err := s.smsClient.Send(ctx, msg.To, msg.Content)
+	// Don't send again what another worker or instance already finished.
+	if s.statusRecheck {
+		current, err := s.repo.GetStatus(ctx, msg.ID)
+		switch {
+		case errors.Is(err, domain.ErrNotFound):
+			log.Printf("[Service] Message %s no longer exists. Skipping send.", id)
+			return "", false, nil
+		case err != nil:
+			// Leave the claim in place; ResetStuck returns it to PENDING.
+			return "", false, fmt.Errorf("recheck status of %s: %w", id, err)
+		case current != domain.StatusPending && current != domain.StatusProcessing:
+			log.Printf("[Service] Message %s is already %s. Skipping send.", id, current)
+			return "", false, nil
+		}
+	}
+
+	// The batch may have been fetched just before the expiry passed;
+	// don't send a message that became stale while waiting for a worker.
+	if msg.IsExpired(time.Now()) {
+		msg.MarkExpired(expiredReason)
+		if err := s.confirm(ctx, msg); err != nil {
+			return "", false, fmt.Errorf("update status for %s: %w", id, err)
+		}
+		return "", false, nil
+	}
+
+	// Re-check rules that may have changed since the message was enqueued
+	// (e.g. the recipient opted out) before spending a provider call.
+	if err := s.preflight(ctx, msg); err != nil {
+		log.Printf("[Service] Message %s failed pre-flight: %v. Marking as FAILED.", id, err)
+		msg.MarkFailed("")
+		msg.StatusReason = err.Error()
+
+		if uErr := s.confirm(ctx, msg); uErr != nil {
+			log.Printf("[Service] Failed to persist FAILED status for %s: %v", id, uErr)
+		}
+
+		return "", false, fmt.Errorf("pre-flight %s: %w", id, err)
+	}
+
+	// Fail a message whose provider is not registered.
+	if routeErr != nil {
+		log.Printf("[Service] Cannot route message %s: %v. Marking as FAILED.", id, routeErr)
+		msg.MarkFailed("")
+		msg.StatusReason = routeErr.Error()
+
+		if uErr := s.confirm(ctx, msg); uErr != nil {
+			log.Printf("[Service] Failed to persist FAILED status for %s: %v", id, uErr)
+		}
+
+		return "", false, fmt.Errorf("route message %s: %w", id, routeErr)
+	}
+
+	// Templated messages take the template's current body.
+	content, err := s.renderContent(ctx, msg)
 	if err != nil {
-		log.Printf("[Service] Failed to send message %s: %v. Marking as FAILED.", id, err)
-		msg.MarkFailed(rawResp)
+		log.Printf("[Service] Cannot render message %s: %v. Marking as FAILED.", id, err)
+		msg.MarkFailed("")
+		msg.StatusReason = err.Error()
 
-		// Best-effort: persist the FAILED status so this message is not retried
-		// indefinitely as PENDING.
-		if uErr := s.repo.UpdateStatus(ctx, msg); uErr != nil {
+		if uErr := s.confirm(ctx, msg); uErr != nil {
 			log.Printf("[Service] Failed to persist FAILED status for %s: %v", id, uErr)
 		}
 
+		return "", false, fmt.Errorf("render message %s: %w", id, err)
+	}
+
+	// Apply the configured pre-send transforms to the content about to go out.
+	if s.transformer != nil {
+		msg.Content = content
+		err := s.transformer.Transform(ctx, msg)
+		if err == nil {
+			// Transforms may lengthen the content (e.g. tracking URLs) or
+			// leave the GSM-7 alphabet; hold the result to the rules the
+			// content was created under.
+			transformed := msg.Content
+			msg.Content = content
+			err = msg.SetContent(transformed, s.contentRules)
+		}
+		if err != nil {
+			log.Printf("[Service] Cannot transform message %s: %v. Marking as FAILED.", id, err)
+			msg.MarkFailed("")
+			msg.StatusReason = fmt.Sprintf("transform content: %v", err)
+
+			if uErr := s.confirm(ctx, msg); uErr != nil {
+				log.Printf("[Service] Failed to persist FAILED status for %s: %v", id, uErr)
+			}
+
+			return "", false, fmt.Errorf("transform message %s: %w", id, err)
+		}
+		content = msg.Content
+	}
+
+	return content, true, nil
+}
+
+// finishSend records the outcome of a provider call for msg: a scheduled
+// retry or FAILED for sendErr, SUCCESS otherwise, and confirms it.
+func (s *messageService) finishSend(ctx context.Context, msg *domain.Message, externalID, rawResp string, took time.Duration, err error) error {
+	id := msg.ID.String()
+
+	msg.SendDuration = &took
+	if err != nil {
+		if s.scheduleRetry(msg, rawResp, err) {
+			log.Printf("[Service] Failed to send message %s: %v. Retry %d/%d after %s.",
+				id, err, msg.RetryCount, s.maxRetries, msg.NextRetryAt.Format(time.RFC3339))
+		} else {
+			log.Printf("[Service] Failed to send message %s: %v. Marking as FAILED.", id, err)
+			msg.MarkFailed(rawResp)
+		}
+
+		// Best-effort: persist the new status so this message is not retried
+		// indefinitely (or right away) as PENDING.
+		if uErr := s.confirm(ctx, msg); uErr != nil {
+			log.Printf("[Service] Failed to persist %s status for %s: %v", msg.Status, id, uErr)
+		}
+
 		return fmt.Errorf("send message %s: %w", id, err)
 	}
 
 	// Mark as successfully sent and persist the new state.
 	msg.MarkSent(externalID, rawResp)
-	if err := s.repo.UpdateStatus(ctx, msg); err != nil {
+	if s.compactSentRaw {
+		msg.RawResponse = sentSummary(externalID)
+	}
+	if err := s.confirm(ctx, msg); err != nil {
 		log.Printf("[Service] Failed to persist SUCCESS status for %s: %v", id, err)
 		return fmt.Errorf("update status for %s: %w", id, err)
 	}
 
 	// Optionally cache the sent timestamp in Redis keyed by external message ID.
 	if s.cache != nil && externalID != "" {
+		// Don't let a provider-side duplicate overwrite another message's entry.
+		if s.detectDuplicateExternalID(ctx, msg, externalID) {
+			return nil
+		}
+
 		sentAt := time.Now().Format(time.RFC3339)
 		if msg.SentAt != nil {
 			sentAt = msg.SentAt.Format(time.RFC3339)
 		}
 
 		key := cache.SentMessages.Key(externalID)
-		if err := s.cache.Set(ctx, key, sentAt, 24*time.Hour); err != nil {
+		if err := s.cache.Set(ctx, key, sentAt, externalIDTTL); err != nil {
 			log.Printf("[Service] Failed to cache in Redis for %s: %v", externalID, err)
 		}
 	}
 
 	return nil
 }
+
+// confirm persists the outcome of a claimed message and its timeline event
+// in one transaction, so a message never leaves PROCESSING without a matching
+// event. The event is recorded from PENDING: the claim is an internal step,
+// not part of the timeline, and so is a scheduled retry back to PENDING.
+func (s *messageService) confirm(ctx context.Context, msg *domain.Message) error {
+	return s.repo.WithTx(ctx, func(tx domain.Repository) error {
+		if err := tx.UpdateStatus(ctx, msg); err != nil {
+			return err
+		}
+		if msg.Status == domain.StatusPending {
+			return nil
+		}
+		event := domain.NewStatusEvent(msg.ID, domain.StatusPending, msg.Status, time.Now())
+		return tx.AddStatusEvents(ctx, event)
+	})
+}
+
+// detectDuplicateExternalID claims externalID for msg in the cache. If the ID
+// is already claimed by a different message, the collision is logged, reported
+// and recorded on msg.StatusReason, and true is returned. Cache errors are
+// logged and treated as "no duplicate" so they never block sending.
+func (s *messageService) detectDuplicateExternalID(ctx context.Context, msg *domain.Message, externalID string) bool {
+	id := msg.ID.String()
+	key := cache.ExternalIDs.Key(externalID)
+
+	claimed, err := s.cache.SetNX(ctx, key, id, externalIDTTL)
+	if err != nil {
+		log.Printf("[Service] Failed to claim external ID %s for %s: %v", externalID, id, err)
+		return false
+	}
+	if claimed {
+		return false
+	}
+
+	owner, err := s.cache.Get(ctx, key)
+	if err != nil || owner == id {
+		// Either the claim just expired or this message already owns it
+		// (e.g. a re-processed message); nothing to flag.
+		return false
+	}
+
+	log.Printf("[Service] Duplicate external ID %s: assigned to %s and %s", externalID, owner, id)
+	s.reporter.Report(ErrDuplicateExternalID, map[string]any{
+		"externalId": externalID,
+		"messageId":  id,
+		"ownerId":    owner,
+	})
+
+	msg.StatusReason = fmt.Sprintf("duplicate provider messageId %s (already assigned to %s)", externalID, owner)
+	if err := s.repo.UpdateStatus(ctx, msg); err != nil {
+		log.Printf("[Service] Failed to flag duplicate external ID on %s: %v", id, err)
+	}
+
+	return true
+}
+
+// sentSummary is the compact RawResponse stored for a successful send when
+// the full body is not kept.
+func sentSummary(externalID string) string {
+	b, _ := json.Marshal(struct {
+		MessageID string `json:"messageId"`
+	}{externalID})
+	return string(b)
+}
@@ -0,0 +1,1267 @@
+package service
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"math"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	domain "github.com/oggyb/insider-assessment/internal/domain/message"
+	"github.com/oggyb/insider-assessment/internal/sms"
+	"github.com/oggyb/insider-assessment/internal/sms/smstest"
+)
+
+// fakeRepo is an in-memory domain.Repository used by service tests.
+type fakeRepo struct {
+	mu      sync.Mutex
+	pending []*domain.Message
+	updated []*domain.Message
+	events  []*domain.StatusEvent
+	runs    []*domain.BatchRun
+}
+
+func (f *fakeRepo) Save(ctx context.Context, m *domain.Message) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.pending = append(f.pending, m)
+	return nil
+}
+
+// GetPending mirrors the real repository: only PENDING, non-expired messages.
+func (f *fakeRepo) GetPending(ctx context.Context, limit int) ([]*domain.Message, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	now := time.Now()
+	var out []*domain.Message
+	for _, m := range f.pending {
+		if len(out) == limit {
+			break
+		}
+		waiting := m.NextRetryAt != nil && m.NextRetryAt.After(now)
+		if m.Status == domain.StatusPending && !m.IsExpired(now) && !waiting {
+			out = append(out, m)
+		}
+	}
+	return out, nil
+}
+
+func (f *fakeRepo) List(ctx context.Context, filter domain.ListFilter, page, limit int) ([]*domain.Message, int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var out []*domain.Message
+	for _, m := range f.pending {
+		if hasTags(m, filter.Tags) {
+			out = append(out, m)
+		}
+	}
+	return out, int64(len(out)), nil
+}
+
+// hasTags reports whether m carries every key=value pair in want.
+func hasTags(m *domain.Message, want map[string]string) bool {
+	for k, v := range want {
+		if got, ok := m.Tags[k]; !ok || got != v {
+			return false
+		}
+	}
+	return true
+}
+
+func (f *fakeRepo) GetByProviderCode(ctx context.Context, code string, page, limit int) ([]*domain.Message, int64, error) {
+	return nil, 0, nil
+}
+
+func (f *fakeRepo) ClearContentDedup(ctx context.Context, to string) (int64, error) {
+	return 0, nil
+}
+
+func (f *fakeRepo) FindRecentDuplicate(ctx context.Context, to, content string, since time.Time) (*domain.Message, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var found *domain.Message
+	for _, m := range f.pending {
+		if m.To != to || m.Content != content || m.CreatedAt.Before(since) {
+			continue
+		}
+		if m.Status != domain.StatusPending && m.Status != domain.StatusProcessing && m.Status != domain.StatusSuccess {
+			continue
+		}
+		if found == nil || m.CreatedAt.After(found.CreatedAt) {
+			found = m
+		}
+	}
+	if found == nil {
+		return nil, domain.ErrNotFound
+	}
+	return found, nil
+}
+
+func (f *fakeRepo) GetByID(ctx context.Context, id uuid.UUID) (*domain.Message, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, m := range f.pending {
+		if m.ID == id {
+			return m, nil
+		}
+	}
+	return nil, domain.ErrNotFound
+}
+
+func (f *fakeRepo) GetStatus(ctx context.Context, id uuid.UUID) (domain.Status, error) {
+	m, err := f.GetByID(ctx, id)
+	if err != nil {
+		return "", err
+	}
+	return m.Status, nil
+}
+
+// SoftDelete drops the message from the in-memory store, so it disappears
+// from every read like a soft-deleted row does.
+func (f *fakeRepo) SoftDelete(ctx context.Context, id uuid.UUID) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i, m := range f.pending {
+		if m.ID == id {
+			f.pending = slices.Delete(f.pending, i, i+1)
+			return nil
+		}
+	}
+	return domain.ErrNotFound
+}
+
+// ResetStuck mirrors the real repository, using UpdatedAt as the claim time.
+func (f *fakeRepo) ResetStuck(ctx context.Context, before time.Time) ([]uuid.UUID, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var ids []uuid.UUID
+	for _, m := range f.pending {
+		if m.Status == domain.StatusProcessing && !m.UpdatedAt.After(before) {
+			m.Status = domain.StatusPending
+			ids = append(ids, m.ID)
+		}
+	}
+	return ids, nil
+}
+
+// WithTx runs fn directly against the fake; there is nothing to roll back.
+func (f *fakeRepo) WithTx(ctx context.Context, fn func(tx domain.Repository) error) error {
+	return fn(f)
+}
+
+func (f *fakeRepo) ExpirePending(ctx context.Context, now time.Time, reason string) ([]uuid.UUID, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var ids []uuid.UUID
+	for _, m := range f.pending {
+		if m.Status == domain.StatusPending && m.IsExpired(now) {
+			m.MarkExpired(reason)
+			ids = append(ids, m.ID)
+		}
+	}
+	return ids, nil
+}
+
+// StreamByCreatedRange mirrors the real repository: [from, until), oldest first.
+func (f *fakeRepo) StreamByCreatedRange(ctx context.Context, from, until time.Time, fn func(*domain.Message) error) error {
+	f.mu.Lock()
+	var rows []*domain.Message
+	for _, m := range f.pending {
+		if !m.CreatedAt.Before(from) && m.CreatedAt.Before(until) {
+			rows = append(rows, m)
+		}
+	}
+	f.mu.Unlock()
+
+	slices.SortStableFunc(rows, func(a, b *domain.Message) int { return a.CreatedAt.Compare(b.CreatedAt) })
+	for _, m := range rows {
+		if err := fn(m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *fakeRepo) GetSent(ctx context.Context, page, limit int) ([]*domain.Message, int64, error) {
+	return nil, 0, nil
+}
+
+func (f *fakeRepo) StreamSent(ctx context.Context, fn func(*domain.Message) error) error {
+	return nil
+}
+
+func (f *fakeRepo) UpdateStatus(ctx context.Context, m *domain.Message) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.updated = append(f.updated, m)
+	return nil
+}
+
+func (f *fakeRepo) Requeue(ctx context.Context, m *domain.Message) error {
+	return f.UpdateStatus(ctx, m)
+}
+
+// SentStatsByDay mirrors the real aggregate: finished messages grouped by the
+// UTC day of sent_at (SUCCESS) or updated_at (FAILED).
+func (f *fakeRepo) SentStatsByDay(ctx context.Context, from, until time.Time) ([]domain.DayStat, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	byDay := map[time.Time]*domain.DayStat{}
+	var days []time.Time
+	for _, m := range f.pending {
+		at := m.UpdatedAt
+		if m.SentAt != nil {
+			at = *m.SentAt
+		}
+		if at.Before(from) || !at.Before(until) {
+			continue
+		}
+		day := truncateDayUTC(at)
+		st, ok := byDay[day]
+		if !ok {
+			st = &domain.DayStat{Day: day}
+			byDay[day] = st
+			days = append(days, day)
+		}
+		switch m.Status {
+		case domain.StatusSuccess:
+			st.Sent++
+		case domain.StatusFailed:
+			st.Failed++
+		}
+	}
+
+	slices.SortFunc(days, func(a, b time.Time) int { return a.Compare(b) })
+	out := make([]domain.DayStat, len(days))
+	for i, d := range days {
+		out[i] = *byDay[d]
+	}
+	return out, nil
+}
+
+// TopRecipients mirrors the real aggregate: messages created in the window
+// counted per recipient, most first, ties by recipient.
+func (f *fakeRepo) TopRecipients(ctx context.Context, from, until time.Time, limit int) ([]domain.RecipientCount, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	counts := map[string]int64{}
+	for _, m := range f.pending {
+		if m.CreatedAt.Before(from) || !m.CreatedAt.Before(until) {
+			continue
+		}
+		counts[m.To]++
+	}
+
+	out := make([]domain.RecipientCount, 0, len(counts))
+	for to, n := range counts {
+		out = append(out, domain.RecipientCount{To: to, Count: n})
+	}
+	slices.SortFunc(out, func(a, b domain.RecipientCount) int {
+		if a.Count != b.Count {
+			return cmp.Compare(b.Count, a.Count)
+		}
+		return strings.Compare(a.To, b.To)
+	})
+	return out[:min(limit, len(out))], nil
+}
+
+// OldestPendingAge mirrors the real aggregate: the age of the earliest
+// created PENDING message.
+func (f *fakeRepo) Ping(ctx context.Context) error { return nil }
+
+func (f *fakeRepo) OldestPendingAge(ctx context.Context) (time.Duration, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var oldest time.Time
+	for _, m := range f.pending {
+		if m.Status == domain.StatusPending && (oldest.IsZero() || m.CreatedAt.Before(oldest)) {
+			oldest = m.CreatedAt
+		}
+	}
+	if oldest.IsZero() {
+		return 0, nil
+	}
+	return time.Since(oldest), nil
+}
+
+// SendLatencyStats mirrors the real aggregate: AVG and a linearly
+// interpolated 95th percentile (percentile_cont) of SUCCESS send durations.
+func (f *fakeRepo) SendLatencyStats(ctx context.Context, from, until time.Time) (time.Duration, time.Duration, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var ds []time.Duration
+	var sum time.Duration
+	for _, m := range f.pending {
+		if m.Status != domain.StatusSuccess || m.SendDuration == nil || m.SentAt == nil {
+			continue
+		}
+		if m.SentAt.Before(from) || !m.SentAt.Before(until) {
+			continue
+		}
+		ds = append(ds, *m.SendDuration)
+		sum += *m.SendDuration
+	}
+	if len(ds) == 0 {
+		return 0, 0, nil
+	}
+
+	slices.Sort(ds)
+	pos := 0.95 * float64(len(ds)-1)
+	lo := int(pos)
+	p95 := ds[lo]
+	if lo+1 < len(ds) {
+		p95 += time.Duration(math.Round((pos - float64(lo)) * float64(ds[lo+1]-ds[lo])))
+	}
+	return sum / time.Duration(len(ds)), p95, nil
+}
+
+func (f *fakeRepo) AddStatusEvents(ctx context.Context, events ...*domain.StatusEvent) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.events = append(f.events, events...)
+	return nil
+}
+
+func (f *fakeRepo) RecordBatchRun(ctx context.Context, result domain.BatchResult) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.runs = append(f.runs, &domain.BatchRun{ID: uuid.New(), BatchResult: result})
+	return nil
+}
+
+// ListBatchRuns returns every recorded run, most recent first.
+func (f *fakeRepo) ListBatchRuns(ctx context.Context, page, limit int) ([]*domain.BatchRun, int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	out := slices.Clone(f.runs)
+	slices.Reverse(out)
+	return out, int64(len(out)), nil
+}
+
+func (f *fakeRepo) GetTimeline(ctx context.Context, id uuid.UUID) ([]*domain.StatusEvent, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var out []*domain.StatusEvent
+	for _, e := range f.events {
+		if e.MessageID == id {
+			out = append(out, e)
+		}
+	}
+	return out, nil
+}
+
+// fakeSMS is an sms.Client whose Send behaviour is supplied by the test.
+// Health fails while unhealthy is set.
+type fakeSMS struct {
+	send      func(ctx context.Context, to, content string) (string, string, error)
+	unhealthy atomic.Bool
+}
+
+func (f *fakeSMS) Send(ctx context.Context, to, content string) (string, string, error) {
+	return f.send(ctx, to, content)
+}
+
+func (f *fakeSMS) Health(ctx context.Context) error {
+	if f.unhealthy.Load() {
+		return errors.New("provider down")
+	}
+	return nil
+}
+
+// fakeCache is a minimal in-memory cache.Cache.
+type fakeCache struct {
+	mu   sync.Mutex
+	data map[string]string
+}
+
+func newFakeCache() *fakeCache { return &fakeCache{data: map[string]string{}} }
+
+func (c *fakeCache) Ping(ctx context.Context) error { return nil }
+
+func (c *fakeCache) Set(ctx context.Context, key, value string, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.data[key] = value
+	return nil
+}
+
+func (c *fakeCache) SetNX(ctx context.Context, key, value string, ttl time.Duration) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.data[key]; ok {
+		return false, nil
+	}
+	c.data[key] = value
+	return true, nil
+}
+
+func (c *fakeCache) Get(ctx context.Context, key string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	v, ok := c.data[key]
+	if !ok {
+		return "", errors.New("not found")
+	}
+	return v, nil
+}
+
+func (c *fakeCache) Del(ctx context.Context, key string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.data, key)
+	return nil
+}
+
+func (c *fakeCache) Incr(ctx context.Context, key string) (int64, error) { return c.add(key, 1) }
+
+func (c *fakeCache) Decr(ctx context.Context, key string) (int64, error) { return c.add(key, -1) }
+
+func (c *fakeCache) Expire(ctx context.Context, key string, ttl time.Duration) error { return nil }
+
+func (c *fakeCache) add(key string, delta int64) (int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n, _ := strconv.ParseInt(c.data[key], 10, 64)
+	n += delta
+	c.data[key] = strconv.FormatInt(n, 10)
+	return n, nil
+}
+
+// recordingReporter captures every Report call for assertions.
+type recordingReporter struct {
+	mu    sync.Mutex
+	errs  []error
+	ctxes []map[string]any
+}
+
+func (r *recordingReporter) Report(err error, ctx map[string]any) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.errs = append(r.errs, err)
+	r.ctxes = append(r.ctxes, ctx)
+}
+
+func newPendingMessage(t *testing.T, to, content string) *domain.Message {
+	t.Helper()
+
+	msg, err := domain.NewMessage(to, content)
+	if err != nil {
+		t.Fatalf("NewMessage: %v", err)
+	}
+	return msg
+}
+
+func TestProcessBatch_WorkerPanicIsReported(t *testing.T) {
+	repo := &fakeRepo{}
+	bad := newPendingMessage(t, "+905000000001", "explode")
+	good := newPendingMessage(t, "+905000000002", "fine")
+	_ = repo.Save(context.Background(), bad)
+	_ = repo.Save(context.Background(), good)
+
+	client := &fakeSMS{send: func(ctx context.Context, to, content string) (string, string, error) {
+		if content == "explode" {
+			panic("provider exploded")
+		}
+		return "ext-1", "{}", nil
+	}}
+
+	rep := &recordingReporter{}
+	svc := NewMessageService(repo, client, nil, 10, 1, 0, WithErrorReporter(rep))
+
+	if _, err := svc.ProcessBatch(context.Background()); err != nil {
+		t.Fatalf("ProcessBatch: %v", err)
+	}
+
+	if len(rep.errs) != 1 {
+		t.Fatalf("expected exactly one report, got %d", len(rep.errs))
+	}
+	ctx := rep.ctxes[0]
+	if ctx["panic"] != "provider exploded" {
+		t.Fatalf("expected panic value in context, got %v", ctx["panic"])
+	}
+	if ctx["messageId"] != bad.ID.String() {
+		t.Fatalf("expected messageId %s, got %v", bad.ID, ctx["messageId"])
+	}
+
+	// The same worker must keep going after the panic.
+	if good.Status != domain.StatusSuccess {
+		t.Fatalf("expected the next message to still be sent, status=%s", good.Status)
+	}
+}
+
+func TestProcessBatch_ExpiredMessagesAreReapedNotSent(t *testing.T) {
+	repo := &fakeRepo{}
+
+	stale := newPendingMessage(t, "+905000000001", "stale alert")
+	past := time.Now().Add(-time.Minute)
+	stale.ExpiresAt = &past // simulate a message whose TTL elapsed in the backlog
+
+	fresh, err := domain.NewMessage("+905000000002", "fresh alert", domain.WithTTL(time.Hour))
+	if err != nil {
+		t.Fatalf("NewMessage: %v", err)
+	}
+
+	_ = repo.Save(context.Background(), stale)
+	_ = repo.Save(context.Background(), fresh)
+
+	var sentTo []string
+	var mu sync.Mutex
+	client := &fakeSMS{send: func(ctx context.Context, to, content string) (string, string, error) {
+		mu.Lock()
+		sentTo = append(sentTo, to)
+		mu.Unlock()
+		return "ext", "{}", nil
+	}}
+
+	svc := NewMessageService(repo, client, nil, 10, 2, 0)
+
+	pending, _ := repo.GetPending(context.Background(), 10)
+	if len(pending) != 1 || pending[0].ID != fresh.ID {
+		t.Fatalf("expected only the fresh message to be pending, got %d", len(pending))
+	}
+
+	if _, err := svc.ProcessBatch(context.Background()); err != nil {
+		t.Fatalf("ProcessBatch: %v", err)
+	}
+
+	if stale.Status != domain.StatusExpired || stale.StatusReason == "" {
+		t.Fatalf("expected stale message to be EXPIRED with a reason, got %s (%q)", stale.Status, stale.StatusReason)
+	}
+	if len(sentTo) != 1 || sentTo[0] != fresh.To {
+		t.Fatalf("expected only the fresh message to be sent, got %v", sentTo)
+	}
+}
+
+func TestProcessBatch_PassesEncodingOverrideToProvider(t *testing.T) {
+	repo := &fakeRepo{}
+	forced, err := domain.NewMessage("+905000000001", "plain text", domain.WithEncoding(domain.EncodingUCS2))
+	if err != nil {
+		t.Fatalf("NewMessage: %v", err)
+	}
+	_ = repo.Save(context.Background(), forced)
+	_ = repo.Save(context.Background(), newPendingMessage(t, "+905000000002", "Şifreniz: 1234"))
+	_ = repo.Save(context.Background(), newPendingMessage(t, "+905000000003", "code 1234"))
+
+	var mu sync.Mutex
+	got := map[string]domain.Encoding{}
+	client := &fakeSMS{send: func(ctx context.Context, to, content string) (string, string, error) {
+		mu.Lock()
+		got[to] = sms.EncodingFor(ctx, content)
+		mu.Unlock()
+		return "ext", "{}", nil
+	}}
+
+	svc := NewMessageService(repo, client, nil, 10, 1, time.Second)
+	if _, err := svc.ProcessBatch(context.Background()); err != nil {
+		t.Fatalf("ProcessBatch: %v", err)
+	}
+
+	want := map[string]domain.Encoding{
+		"+905000000001": domain.EncodingUCS2, // overridden
+		"+905000000002": domain.EncodingUCS2, // Turkish
+		"+905000000003": domain.EncodingGSM7,
+	}
+	for to, enc := range want {
+		if got[to] != enc {
+			t.Fatalf("%s: encoding = %q, want %q", to, got[to], enc)
+		}
+	}
+}
+
+func TestProcessBatch_RoutesToNamedProvider(t *testing.T) {
+	repo := &fakeRepo{}
+
+	otp, err := domain.NewMessage("+905000000001", "code 1234", domain.WithProvider("OTP"))
+	if err != nil {
+		t.Fatalf("NewMessage: %v", err)
+	}
+	plain := newPendingMessage(t, "+905000000002", "newsletter")
+	unknown, _ := domain.NewMessage("+905000000003", "lost", domain.WithProvider("carrier-pigeon"))
+
+	_ = repo.Save(context.Background(), otp)
+	_ = repo.Save(context.Background(), plain)
+	_ = repo.Save(context.Background(), unknown)
+
+	var mu sync.Mutex
+	sentVia := map[string]string{}
+	clientNamed := func(name string) *fakeSMS {
+		return &fakeSMS{send: func(ctx context.Context, to, content string) (string, string, error) {
+			mu.Lock()
+			sentVia[to] = name
+			mu.Unlock()
+			return "ext-" + name, "{}", nil
+		}}
+	}
+
+	defaultClient := clientNamed("default")
+	router := sms.NewRouter(defaultClient)
+	router.Register("otp", clientNamed("otp"))
+
+	svc := NewMessageService(repo, defaultClient, nil, 10, 2, 0, WithProviderRouter(router))
+	if _, err := svc.ProcessBatch(context.Background()); err != nil {
+		t.Fatalf("ProcessBatch: %v", err)
+	}
+
+	if sentVia[otp.To] != "otp" {
+		t.Fatalf("expected OTP message to go through the otp provider, got %q", sentVia[otp.To])
+	}
+	if sentVia[plain.To] != "default" {
+		t.Fatalf("expected message without provider to use the default, got %q", sentVia[plain.To])
+	}
+	if _, sent := sentVia[unknown.To]; sent {
+		t.Fatalf("expected message with an unknown provider not to be sent")
+	}
+	if unknown.Status != domain.StatusFailed {
+		t.Fatalf("expected unknown provider message to be FAILED, got %s", unknown.Status)
+	}
+}
+
+func TestUpdateWorkerConfig_ConcurrentWithBatch(t *testing.T) {
+	repo := &fakeRepo{}
+	for i := 0; i < 20; i++ {
+		_ = repo.Save(context.Background(), newPendingMessage(t, "+905000000001", "hello"))
+	}
+
+	client := &fakeSMS{send: func(ctx context.Context, to, content string) (string, string, error) {
+		time.Sleep(time.Millisecond)
+		return "ext", "{}", nil
+	}}
+	svc := NewMessageService(repo, client, nil, 10, 2, time.Second)
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 3; i++ {
+			_, _ = svc.ProcessBatch(context.Background())
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 1; i <= 50; i++ {
+			size, workers, timeout := i, i%8+1, time.Duration(i)*time.Millisecond
+			if _, err := svc.UpdateWorkerConfig(WorkerConfigUpdate{
+				BatchSize:         &size,
+				MaxWorkers:        &workers,
+				PerMessageTimeout: &timeout,
+			}); err != nil {
+				t.Errorf("UpdateWorkerConfig: %v", err)
+			}
+			_ = svc.WorkerConfig()
+		}
+	}()
+	wg.Wait()
+
+	if got := svc.WorkerConfig(); got.BatchSize != 50 || got.MaxWorkers != 3 {
+		t.Fatalf("unexpected final config: %+v", got)
+	}
+}
+
+func TestUpdateWorkerConfig_RejectsInvalidValues(t *testing.T) {
+	svc := NewMessageService(&fakeRepo{}, nil, nil, 10, 2, time.Second)
+
+	zero := 0
+	if _, err := svc.UpdateWorkerConfig(WorkerConfigUpdate{MaxWorkers: &zero}); !errors.Is(err, ErrInvalidWorkerConfig) {
+		t.Fatalf("expected ErrInvalidWorkerConfig, got %v", err)
+	}
+	if got := svc.WorkerConfig(); got.MaxWorkers != 2 {
+		t.Fatalf("expected config to be unchanged after a rejected update, got %+v", got)
+	}
+}
+
+func TestProcessBatch_DuplicateExternalIDIsReported(t *testing.T) {
+	repo := &fakeRepo{}
+	first := newPendingMessage(t, "+905000000001", "one")
+	second := newPendingMessage(t, "+905000000002", "two")
+	_ = repo.Save(context.Background(), first)
+	_ = repo.Save(context.Background(), second)
+
+	// The provider (buggy) hands out the same ID for every message.
+	client := &fakeSMS{send: func(ctx context.Context, to, content string) (string, string, error) {
+		return "dup-1", "{}", nil
+	}}
+
+	rep := &recordingReporter{}
+	c := newFakeCache()
+	svc := NewMessageService(repo, client, c, 10, 1, 0, WithErrorReporter(rep))
+
+	if _, err := svc.ProcessBatch(context.Background()); err != nil {
+		t.Fatalf("ProcessBatch: %v", err)
+	}
+
+	if len(rep.errs) != 1 || !errors.Is(rep.errs[0], ErrDuplicateExternalID) {
+		t.Fatalf("expected one ErrDuplicateExternalID report, got %v", rep.errs)
+	}
+	if rep.ctxes[0]["ownerId"] != first.ID.String() || rep.ctxes[0]["messageId"] != second.ID.String() {
+		t.Fatalf("unexpected report context: %v", rep.ctxes[0])
+	}
+	if second.StatusReason == "" {
+		t.Fatalf("expected the colliding message to be flagged")
+	}
+	if first.StatusReason != "" {
+		t.Fatalf("expected the first owner not to be flagged, got %q", first.StatusReason)
+	}
+	if owner, _ := c.Get(context.Background(), "external_ids:dup-1"); owner != first.ID.String() {
+		t.Fatalf("expected the external ID claim to stay with the first message, got %q", owner)
+	}
+}
+
+func TestProcessBatch_PausesWhileProviderUnhealthy(t *testing.T) {
+	repo := &fakeRepo{}
+	msg := newPendingMessage(t, "+905000000001", "hello")
+	_ = repo.Save(context.Background(), msg)
+
+	var sends atomic.Int32
+	client := &fakeSMS{send: func(ctx context.Context, to, content string) (string, string, error) {
+		sends.Add(1)
+		return "ext", "{}", nil
+	}}
+
+	monitor := sms.NewHealthMonitor(client, 5*time.Millisecond)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go monitor.Run(ctx)
+
+	svc := NewMessageService(repo, client, nil, 10, 1, 0, WithHealthGate(monitor))
+
+	waitFor := func(want bool) {
+		t.Helper()
+		deadline := time.Now().Add(time.Second)
+		for monitor.Healthy() != want {
+			if time.Now().After(deadline) {
+				t.Fatalf("monitor did not report healthy=%v in time", want)
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	// Outage: the batch must not send and the message stays PENDING.
+	client.unhealthy.Store(true)
+	waitFor(false)
+	if _, err := svc.ProcessBatch(context.Background()); err != nil {
+		t.Fatalf("ProcessBatch: %v", err)
+	}
+	if sends.Load() != 0 || msg.Status != domain.StatusPending {
+		t.Fatalf("expected sends to pause, got %d sends and status %s", sends.Load(), msg.Status)
+	}
+
+	// Recovery: the next batch sends the message.
+	client.unhealthy.Store(false)
+	waitFor(true)
+	if _, err := svc.ProcessBatch(context.Background()); err != nil {
+		t.Fatalf("ProcessBatch: %v", err)
+	}
+	if sends.Load() != 1 || msg.Status != domain.StatusSuccess {
+		t.Fatalf("expected sends to resume, got %d sends and status %s", sends.Load(), msg.Status)
+	}
+}
+
+func TestGetTimeline_SentMessageGoesPendingToSuccess(t *testing.T) {
+	repo := &fakeRepo{}
+	client := &fakeSMS{send: func(ctx context.Context, to, content string) (string, string, error) {
+		return "ext-1", "{}", nil
+	}}
+	svc := NewMessageService(repo, client, nil, 10, 1, 0)
+
+	msg := newPendingMessage(t, "+905000000001", "hello")
+	if err := svc.Create(context.Background(), msg); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if _, err := svc.ProcessBatch(context.Background()); err != nil {
+		t.Fatalf("ProcessBatch: %v", err)
+	}
+
+	events, err := svc.GetTimeline(context.Background(), msg.ID)
+	if err != nil {
+		t.Fatalf("GetTimeline: %v", err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("expected 2 events, got %d", len(events))
+	}
+
+	created, sent := events[0], events[1]
+	if created.From != "" || created.To != domain.StatusPending {
+		t.Fatalf("expected creation event \"\"->PENDING, got %q->%q", created.From, created.To)
+	}
+	if sent.From != domain.StatusPending || sent.To != domain.StatusSuccess {
+		t.Fatalf("expected PENDING->SUCCESS, got %q->%q", sent.From, sent.To)
+	}
+	if created.At.IsZero() || sent.At.Before(created.At) {
+		t.Fatalf("expected ordered, non-zero timestamps, got %s then %s", created.At, sent.At)
+	}
+}
+
+func TestGetTimeline_UnknownMessage(t *testing.T) {
+	svc := NewMessageService(&fakeRepo{}, nil, nil, 0, 0, 0)
+
+	if _, err := svc.GetTimeline(context.Background(), uuid.New()); !errors.Is(err, domain.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestProcessBatch_BatchBudgetStopsDispatch(t *testing.T) {
+	repo := &fakeRepo{}
+	var msgs []*domain.Message
+	for i := 0; i < 6; i++ {
+		m := newPendingMessage(t, "+905000000000", "slow")
+		_ = repo.Save(context.Background(), m)
+		msgs = append(msgs, m)
+	}
+
+	var sends atomic.Int32
+	client := &fakeSMS{send: func(ctx context.Context, to, content string) (string, string, error) {
+		sends.Add(1)
+		time.Sleep(40 * time.Millisecond)
+		return "", "{}", nil
+	}}
+
+	svc := NewMessageService(repo, client, nil, 10, 1, time.Second, WithBatchBudget(100*time.Millisecond))
+	if _, err := svc.ProcessBatch(context.Background()); err != nil {
+		t.Fatalf("ProcessBatch: %v", err)
+	}
+
+	sent := int(sends.Load())
+	if sent == 0 || sent >= len(msgs) {
+		t.Fatalf("expected the budget to cut the batch short, got %d of %d sends", sent, len(msgs))
+	}
+
+	for i, m := range msgs {
+		want := domain.StatusSuccess
+		if i >= sent {
+			want = domain.StatusPending
+		}
+		if m.Status != want {
+			t.Fatalf("message %d: expected %s, got %s", i, want, m.Status)
+		}
+	}
+
+	// The leftovers are picked up by the next batch.
+	if _, err := svc.ProcessBatch(context.Background()); err != nil {
+		t.Fatalf("ProcessBatch: %v", err)
+	}
+	if got := int(sends.Load()); got <= sent {
+		t.Fatalf("expected the next batch to continue sending, got %d sends", got)
+	}
+}
+
+func TestProcessBatch_ProviderFailureMarksFailed(t *testing.T) {
+	repo := &fakeRepo{}
+	msg := newPendingMessage(t, "+905000000001", "hello")
+	_ = repo.Save(context.Background(), msg)
+
+	client := smstest.NewFakeClient().Fail(errors.New("provider rejected"))
+	svc := NewMessageService(repo, client, nil, 10, 1, time.Second)
+
+	if _, err := svc.ProcessBatch(context.Background()); err != nil {
+		t.Fatalf("ProcessBatch: %v", err)
+	}
+
+	client.AssertCallCount(t, 1)
+	client.AssertRecipients(t, "+905000000001")
+	if msg.Status != domain.StatusFailed {
+		t.Fatalf("expected %s, got %s", domain.StatusFailed, msg.Status)
+	}
+}
+
+func TestProcessBatch_ResultCountsOutcomes(t *testing.T) {
+	repo := &fakeRepo{}
+	for _, content := range []string{"ok", "fail", "ok"} {
+		_ = repo.Save(context.Background(), newPendingMessage(t, "+905000000000", content))
+	}
+
+	client := &fakeSMS{send: func(ctx context.Context, to, content string) (string, string, error) {
+		if content == "fail" {
+			return "", `{"error":"rejected"}`, errors.New("rejected")
+		}
+		return "", "{}", nil
+	}}
+
+	svc := NewMessageService(repo, client, nil, 10, 2, time.Second)
+	res, err := svc.ProcessBatch(context.Background())
+	if err != nil {
+		t.Fatalf("ProcessBatch: %v", err)
+	}
+
+	if res.Processed != 3 || res.Succeeded != 2 || res.Failed != 1 {
+		t.Fatalf("expected 3 processed, 2 succeeded, 1 failed, got %+v", res)
+	}
+	if res.StartedAt.IsZero() || res.Duration <= 0 {
+		t.Fatalf("expected start time and duration to be set, got %+v", res)
+	}
+
+	// A batch with nothing to send still reports when it ran.
+	res, err = svc.ProcessBatch(context.Background())
+	if err != nil {
+		t.Fatalf("ProcessBatch: %v", err)
+	}
+	if res.Processed != 0 || res.StartedAt.IsZero() {
+		t.Fatalf("expected an empty result, got %+v", res)
+	}
+}
+
+func TestProcessBatch_RampDelaySpacesWorkerStarts(t *testing.T) {
+	repo := &fakeRepo{}
+	for i := 0; i < 3; i++ {
+		_ = repo.Save(context.Background(), newPendingMessage(t, "+905000000000", "hi"))
+	}
+
+	var mu sync.Mutex
+	var starts []time.Time
+	client := &fakeSMS{send: func(ctx context.Context, to, content string) (string, string, error) {
+		mu.Lock()
+		starts = append(starts, time.Now())
+		mu.Unlock()
+		return "", "{}", nil
+	}}
+
+	ramp := 30 * time.Millisecond
+	svc := NewMessageService(repo, client, nil, 10, 3, time.Second, WithRampDelay(ramp))
+	if _, err := svc.ProcessBatch(context.Background()); err != nil {
+		t.Fatalf("ProcessBatch: %v", err)
+	}
+
+	if len(starts) != 3 {
+		t.Fatalf("expected 3 sends, got %d", len(starts))
+	}
+	slices.SortFunc(starts, func(a, b time.Time) int { return a.Compare(b) })
+	for i := 1; i < len(starts); i++ {
+		if gap := starts[i].Sub(starts[i-1]); gap < ramp-5*time.Millisecond {
+			t.Fatalf("expected workers to start at least %s apart, got %s between #%d and #%d", ramp, gap, i-1, i)
+		}
+	}
+}
+
+func TestProcessBatch_CrashBetweenSendAndConfirmIsRecovered(t *testing.T) {
+	repo := &fakeRepo{}
+	msg := newPendingMessage(t, "+905000000001", "hello")
+	_ = repo.Save(context.Background(), msg)
+
+	// The first send reaches the provider, then the worker dies before it
+	// can confirm the outcome.
+	var sends atomic.Int32
+	client := &fakeSMS{send: func(ctx context.Context, to, content string) (string, string, error) {
+		if sends.Add(1) == 1 {
+			panic("process died after send")
+		}
+		return "ext-1", "{}", nil
+	}}
+
+	stuck := 50 * time.Millisecond
+	svc := NewMessageService(repo, client, nil, 10, 1, time.Second, WithStuckTimeout(stuck))
+
+	if _, err := svc.ProcessBatch(context.Background()); err != nil {
+		t.Fatalf("ProcessBatch: %v", err)
+	}
+	if msg.Status != domain.StatusProcessing {
+		t.Fatalf("expected the unconfirmed message to stay %s, got %s", domain.StatusProcessing, msg.Status)
+	}
+
+	// A fresh claim may still belong to a running batch and is left alone.
+	if _, err := svc.ProcessBatch(context.Background()); err != nil {
+		t.Fatalf("ProcessBatch: %v", err)
+	}
+	if got := sends.Load(); got != 1 || msg.Status != domain.StatusProcessing {
+		t.Fatalf("expected the recent claim to be untouched, got %d sends and status %s", got, msg.Status)
+	}
+
+	// Once the claim is stale, the next batch requeues and resends it.
+	time.Sleep(stuck + 10*time.Millisecond)
+	if _, err := svc.ProcessBatch(context.Background()); err != nil {
+		t.Fatalf("ProcessBatch: %v", err)
+	}
+	if got := sends.Load(); got != 2 {
+		t.Fatalf("expected the message to be sent again, got %d sends", got)
+	}
+	if msg.Status != domain.StatusSuccess || msg.MessageID != "ext-1" {
+		t.Fatalf("expected the recovered message to be confirmed, got %s (%q)", msg.Status, msg.MessageID)
+	}
+
+	// The claim is internal: the timeline shows a single PENDING -> SUCCESS.
+	events, _ := svc.GetTimeline(context.Background(), msg.ID)
+	if len(events) != 1 || events[0].From != domain.StatusPending || events[0].To != domain.StatusSuccess {
+		t.Fatalf("expected a single PENDING -> SUCCESS event, got %+v", events)
+	}
+}
+
+func TestResetStuck_ReportsCount(t *testing.T) {
+	repo := &fakeRepo{}
+	stale := newPendingMessage(t, "+905000000001", "stale")
+	fresh := newPendingMessage(t, "+905000000002", "fresh")
+	_ = repo.Save(context.Background(), stale)
+	_ = repo.Save(context.Background(), fresh)
+
+	stale.MarkProcessing()
+	stale.UpdatedAt = time.Now().Add(-time.Hour)
+	fresh.MarkProcessing()
+
+	svc := NewMessageService(repo, nil, nil, 10, 1, time.Second, WithStuckTimeout(time.Minute))
+	n, err := svc.ResetStuck(context.Background())
+	if err != nil {
+		t.Fatalf("ResetStuck: %v", err)
+	}
+	if n != 1 || stale.Status != domain.StatusPending || fresh.Status != domain.StatusProcessing {
+		t.Fatalf("expected only the stale claim to be reset, got n=%d stale=%s fresh=%s", n, stale.Status, fresh.Status)
+	}
+}
+
+func TestProcessBatch_WorkerCountScalesWithMessagesNotMaxWorkers(t *testing.T) {
+	const messages, maxWorkers = 3, 64
+
+	repo := &fakeRepo{}
+	for i := 0; i < messages; i++ {
+		_ = repo.Save(context.Background(), newPendingMessage(t, "+905000000000", "hello"))
+	}
+
+	// Hold every send until all messages are in flight, so all workers are
+	// alive when the goroutines are counted.
+	var inFlight atomic.Int32
+	release := make(chan struct{})
+	client := &fakeSMS{send: func(ctx context.Context, to, content string) (string, string, error) {
+		inFlight.Add(1)
+		<-release
+		return "", "{}", nil
+	}}
+	svc := NewMessageService(repo, client, nil, 10, maxWorkers, time.Second)
+
+	baseline := runtime.NumGoroutine()
+	done := make(chan domain.BatchResult)
+	go func() {
+		res, err := svc.ProcessBatch(context.Background())
+		if err != nil {
+			t.Errorf("ProcessBatch: %v", err)
+		}
+		done <- res
+	}()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for inFlight.Load() < messages {
+		if time.Now().After(deadline) {
+			t.Fatalf("only %d of %d sends started", inFlight.Load(), messages)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	// The batch goroutine plus one worker per message.
+	if extra := runtime.NumGoroutine() - baseline; extra > messages+1 {
+		t.Fatalf("expected at most %d extra goroutines, got %d", messages+1, extra)
+	}
+	close(release)
+
+	if res := <-done; res.Workers != messages {
+		t.Fatalf("expected %d workers, got %d", messages, res.Workers)
+	}
+
+	// An empty batch takes the fast path and starts no workers at all.
+	res, err := svc.ProcessBatch(context.Background())
+	if err != nil {
+		t.Fatalf("ProcessBatch: %v", err)
+	}
+	if res.Workers != 0 {
+		t.Fatalf("expected no workers for an empty batch, got %d", res.Workers)
+	}
+}
+
+func TestProcessBatch_PerMessageSendTimeoutOverridesDefault(t *testing.T) {
+	repo := &fakeRepo{}
+	otp, err := domain.NewMessage("+905000000001", "otp", domain.WithSendTimeout(20*time.Millisecond))
+	if err != nil {
+		t.Fatalf("NewMessage: %v", err)
+	}
+	_ = repo.Save(context.Background(), otp)
+	_ = repo.Save(context.Background(), newPendingMessage(t, "+905000000002", "newsletter"))
+
+	// The provider hangs until the send context gives up.
+	var mu sync.Mutex
+	took := map[string]time.Duration{}
+	client := &fakeSMS{send: func(ctx context.Context, to, content string) (string, string, error) {
+		start := time.Now()
+		<-ctx.Done()
+		mu.Lock()
+		took[content] = time.Since(start)
+		mu.Unlock()
+		return "", "", ctx.Err()
+	}}
+
+	const defaultTimeout = 300 * time.Millisecond
+	svc := NewMessageService(repo, client, nil, 10, 2, defaultTimeout)
+	if _, err := svc.ProcessBatch(context.Background()); err != nil {
+		t.Fatalf("ProcessBatch: %v", err)
+	}
+
+	if took["otp"] >= defaultTimeout/2 {
+		t.Fatalf("expected the OTP to time out after ~20ms, took %s", took["otp"])
+	}
+	if took["newsletter"] < defaultTimeout {
+		t.Fatalf("expected the other message to use the %s default, took %s", defaultTimeout, took["newsletter"])
+	}
+	if otp.Status != domain.StatusFailed {
+		t.Fatalf("expected the timed-out OTP to be FAILED, got %s", otp.Status)
+	}
+}
+
+func TestProcessBatch_SendDeadlineRespectsBatchDeadline(t *testing.T) {
+	repo := &fakeRepo{}
+	_ = repo.Save(context.Background(), newPendingMessage(t, "+905000000001", "hello"))
+
+	var sendDeadline time.Time
+	client := &fakeSMS{send: func(ctx context.Context, to, content string) (string, string, error) {
+		sendDeadline, _ = ctx.Deadline()
+		return "ext-1", "{}", nil
+	}}
+
+	// The per-message timeout is far longer than the batch has left.
+	svc := NewMessageService(repo, client, nil, 10, 1, time.Minute)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	batchDeadline, _ := ctx.Deadline()
+
+	if _, err := svc.ProcessBatch(ctx); err != nil {
+		t.Fatalf("ProcessBatch: %v", err)
+	}
+	if sendDeadline.IsZero() || sendDeadline.After(batchDeadline) {
+		t.Fatalf("expected the send deadline %s to be at or before the batch deadline %s", sendDeadline, batchDeadline)
+	}
+}
+
+func TestClampToDeadline(t *testing.T) {
+	if got, clamped := clampToDeadline(context.Background(), 5*time.Second); clamped || got != 5*time.Second {
+		t.Fatalf("without a deadline expected 5s unclamped, got %s (clamped=%v)", got, clamped)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if got, clamped := clampToDeadline(ctx, 5*time.Second); !clamped || got > time.Second {
+		t.Fatalf("expected 5s clamped to at most 1s, got %s (clamped=%v)", got, clamped)
+	}
+	if got, clamped := clampToDeadline(ctx, 100*time.Millisecond); clamped || got != 100*time.Millisecond {
+		t.Fatalf("expected 100ms within the deadline to be kept, got %s (clamped=%v)", got, clamped)
+	}
+}
+
+func TestProcessBatch_CompactsRawResponseOnSuccess(t *testing.T) {
+	const body = `{"message":"Accepted","messageId":"ext-1","code":"ACK","echo":"a long provider payload"}`
+
+	for _, store := range []bool{false, true} {
+		repo := &fakeRepo{}
+		sent := newPendingMessage(t, "+905000000001", "ok")
+		failed := newPendingMessage(t, "+905000000002", "fail")
+		_ = repo.Save(context.Background(), sent)
+		_ = repo.Save(context.Background(), failed)
+
+		client := &fakeSMS{send: func(ctx context.Context, to, content string) (string, string, error) {
+			if to == failed.To {
+				return "", `{"error":{"code":"E42","message":"rejected"}}`, errors.New("rejected")
+			}
+			return "ext-1", body, nil
+		}}
+		svc := NewMessageService(repo, client, nil, 10, 1, 0, WithStoreRawOnSuccess(store))
+		if _, err := svc.ProcessBatch(context.Background()); err != nil {
+			t.Fatalf("ProcessBatch: %v", err)
+		}
+
+		want := `{"messageId":"ext-1"}`
+		if store {
+			want = body
+		}
+		if sent.RawResponse != want {
+			t.Fatalf("store=%v: success raw = %q, want %q", store, sent.RawResponse, want)
+		}
+		if sent.ProviderCode != "ACK" {
+			t.Fatalf("store=%v: expected the provider code to survive compaction, got %q", store, sent.ProviderCode)
+		}
+		if failed.RawResponse != `{"error":{"code":"E42","message":"rejected"}}` {
+			t.Fatalf("store=%v: expected failures to keep the full body, got %q", store, failed.RawResponse)
+		}
+	}
+}
+
+// sentElsewhereRepo reports some messages as SUCCESS from GetStatus, as if
+// another instance confirmed them after this batch fetched them.
+type sentElsewhereRepo struct {
+	*fakeRepo
+	sent      map[uuid.UUID]bool
+	statusErr error
+}
+
+func (r *sentElsewhereRepo) GetStatus(ctx context.Context, id uuid.UUID) (domain.Status, error) {
+	if r.statusErr != nil {
+		return "", r.statusErr
+	}
+	if r.sent[id] {
+		return domain.StatusSuccess, nil
+	}
+	return r.fakeRepo.GetStatus(ctx, id)
+}
+
+func TestProcessBatch_StatusRecheckSkipsAlreadySent(t *testing.T) {
+	fresh := newPendingMessage(t, "+905000000001", "hello")
+	done := newPendingMessage(t, "+905000000002", "hello")
+	repo := &sentElsewhereRepo{fakeRepo: &fakeRepo{}, sent: map[uuid.UUID]bool{done.ID: true}}
+	_ = repo.Save(context.Background(), fresh)
+	_ = repo.Save(context.Background(), done)
+
+	client := smstest.NewFakeClient()
+	svc := NewMessageService(repo, client, nil, 10, 1, time.Second, WithStatusRecheck(true))
+
+	res, err := svc.ProcessBatch(context.Background())
+	if err != nil {
+		t.Fatalf("ProcessBatch: %v", err)
+	}
+
+	client.AssertRecipients(t, "+905000000001")
+	if res.Succeeded != 1 || res.Failed != 0 {
+		t.Fatalf("expected 1 succeeded and no failures, got %+v", res)
+	}
+	for _, m := range repo.updated {
+		if m.ID == done.ID && m.Status != domain.StatusProcessing {
+			t.Fatalf("expected the already sent message to be left alone, got it updated to %s", m.Status)
+		}
+	}
+}
+
+func TestProcessBatch_WithoutStatusRecheckSendsEveryClaim(t *testing.T) {
+	msg := newPendingMessage(t, "+905000000001", "hello")
+	repo := &sentElsewhereRepo{fakeRepo: &fakeRepo{}, sent: map[uuid.UUID]bool{msg.ID: true}}
+	_ = repo.Save(context.Background(), msg)
+
+	client := smstest.NewFakeClient()
+	svc := NewMessageService(repo, client, nil, 10, 1, time.Second)
+
+	if _, err := svc.ProcessBatch(context.Background()); err != nil {
+		t.Fatalf("ProcessBatch: %v", err)
+	}
+	client.AssertCallCount(t, 1)
+}
+
+func TestProcessBatch_StatusRecheckErrorDoesNotSend(t *testing.T) {
+	msg := newPendingMessage(t, "+905000000001", "hello")
+	repo := &sentElsewhereRepo{fakeRepo: &fakeRepo{}, statusErr: errors.New("db down")}
+	_ = repo.Save(context.Background(), msg)
+
+	client := smstest.NewFakeClient()
+	svc := NewMessageService(repo, client, nil, 10, 1, time.Second, WithStatusRecheck(true))
+
+	res, err := svc.ProcessBatch(context.Background())
+	if err != nil {
+		t.Fatalf("ProcessBatch: %v", err)
+	}
+	client.AssertCallCount(t, 0)
+	if res.Failed != 1 {
+		t.Fatalf("expected the unverified message counted as failed, got %+v", res)
+	}
+}
@@ -0,0 +1,246 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	domain "github.com/oggyb/insider-assessment/internal/domain/message"
+)
+
+const (
+	// defaultMulticastMaxRecipients caps the recipients of one multicast.
+	defaultMulticastMaxRecipients = 1000
+
+	// defaultMulticastAsyncThreshold is the recipient count above which a
+	// multicast is created in the background.
+	defaultMulticastAsyncThreshold = 100
+
+	// defaultMulticastMaxGroups bounds how many groups are created in the
+	// background at once.
+	defaultMulticastMaxGroups = 2
+
+	// groupStatusRetention is how long a finished group's progress stays
+	// available from GroupStatus.
+	groupStatusRetention = time.Hour
+
+	// GroupTag is the tag carrying the group ID on every message of a
+	// multicast, so the group can be listed with tag.group=<id>.
+	GroupTag = "group"
+)
+
+var (
+	// ErrTooManyRecipients is returned by Multicast when the recipients
+	// exceed the configured cap.
+	ErrTooManyRecipients = errors.New("too many recipients")
+
+	// ErrMulticastSaturated is returned by Multicast when the configured
+	// number of background groups is already being created.
+	ErrMulticastSaturated = errors.New("too many multicast groups in progress, try again later")
+
+	// ErrGroupNotFound is returned by GroupStatus for unknown or expired
+	// group IDs.
+	ErrGroupNotFound = errors.New("group not found")
+)
+
+// GroupStatus is the creation progress of a multicast group. It tracks
+// writing the messages, not sending them.
+type GroupStatus struct {
+	ID         uuid.UUID
+	Total      int
+	Created    int
+	Failed     int
+	Done       bool
+	StartedAt  time.Time
+	FinishedAt *time.Time
+}
+
+// MulticastResult is the outcome of Multicast. Messages is only filled in
+// when the group was created synchronously.
+type MulticastResult struct {
+	Async    bool
+	Status   GroupStatus
+	Messages []*domain.Message
+}
+
+// WithMulticast configures Multicast: at most maxRecipients per request,
+// groups above asyncThreshold are created in the background, and at most
+// maxGroups of those run at once. Non-positive values keep the defaults of
+// 1000, 100 and 2.
+func WithMulticast(maxRecipients, asyncThreshold, maxGroups int) Option {
+	return func(s *messageService) {
+		if maxRecipients > 0 {
+			s.multicastMaxRecipients = maxRecipients
+		}
+		if asyncThreshold > 0 {
+			s.multicastAsyncThreshold = asyncThreshold
+		}
+		if maxGroups > 0 {
+			s.multicastSlots = make(chan struct{}, maxGroups)
+		}
+	}
+}
+
+// groupTracker keeps the progress of recent groups in memory. Progress is
+// per process and lost on restart; the messages themselves are not.
+type groupTracker struct {
+	mu     sync.Mutex
+	groups map[uuid.UUID]*GroupStatus
+}
+
+func newGroupTracker() *groupTracker {
+	return &groupTracker{groups: map[uuid.UUID]*GroupStatus{}}
+}
+
+// start registers a new group and drops groups finished longer than
+// groupStatusRetention ago.
+func (t *groupTracker) start(total int, now time.Time) *GroupStatus {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for id, g := range t.groups {
+		if g.FinishedAt != nil && now.Sub(*g.FinishedAt) > groupStatusRetention {
+			delete(t.groups, id)
+		}
+	}
+
+	g := &GroupStatus{ID: uuid.New(), Total: total, StartedAt: now}
+	t.groups[g.ID] = g
+	return g
+}
+
+// record counts one message of g as created or failed.
+func (t *groupTracker) record(g *GroupStatus, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if err != nil {
+		g.Failed++
+	} else {
+		g.Created++
+	}
+}
+
+// finish marks g as done.
+func (t *groupTracker) finish(g *GroupStatus, now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	g.Done = true
+	g.FinishedAt = &now
+}
+
+// snapshot returns a copy of g taken under the lock.
+func (t *groupTracker) snapshot(g *GroupStatus) GroupStatus {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return *g
+}
+
+// get returns a copy of the group's status.
+func (t *groupTracker) get(id uuid.UUID) (GroupStatus, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	g, ok := t.groups[id]
+	if !ok {
+		return GroupStatus{}, false
+	}
+	return *g, true
+}
+
+// Multicast creates one pending message per element of msgs, tagged with a
+// shared group ID. Groups up to the async threshold are written before it
+// returns; larger ones are written by a background goroutine and can be
+// followed with GroupStatus. A group counts as one writer against
+// WithCreateConcurrency while it is being written, in the background too.
+// Messages that fail to save (e.g. duplicates) are counted as failed
+// without stopping the rest of the group. Close waits for background
+// groups to finish.
+func (s *messageService) Multicast(ctx context.Context, msgs []*domain.Message) (MulticastResult, error) {
+	if len(msgs) > s.multicastMaxRecipients {
+		return MulticastResult{}, fmt.Errorf("%w: at most %d are allowed", ErrTooManyRecipients, s.multicastMaxRecipients)
+	}
+
+	if len(msgs) <= s.multicastAsyncThreshold {
+		if !s.acquireCreate() {
+			return MulticastResult{}, ErrCreateSaturated
+		}
+		defer s.releaseCreate()
+
+		g := s.groups.start(len(msgs), time.Now().UTC())
+		created := s.createGroup(ctx, g, msgs)
+		return MulticastResult{Status: s.groups.snapshot(g), Messages: created}, nil
+	}
+
+	select {
+	case s.multicastSlots <- struct{}{}:
+	default:
+		return MulticastResult{}, ErrMulticastSaturated
+	}
+	if !s.acquireCreate() {
+		<-s.multicastSlots
+		return MulticastResult{}, ErrCreateSaturated
+	}
+
+	g := s.groups.start(len(msgs), time.Now().UTC())
+	status := s.groups.snapshot(g)
+
+	// The request context ends with the 202, so the group gets its own.
+	s.multicastWG.Add(1)
+	go func() {
+		defer s.multicastWG.Done()
+		defer func() { <-s.multicastSlots }()
+		defer s.releaseCreate()
+		defer func() {
+			rec := recover()
+			if rec == nil {
+				return
+			}
+			s.groups.finish(g, time.Now().UTC())
+			s.reporter.Report(fmt.Errorf("panic while creating group %s: %v", g.ID, rec), map[string]any{
+				"panic":   rec,
+				"groupId": g.ID.String(),
+				"stack":   string(debug.Stack()),
+			})
+		}()
+		s.createGroup(context.WithoutCancel(ctx), g, msgs)
+	}()
+
+	return MulticastResult{Async: true, Status: status}, nil
+}
+
+// createGroup saves msgs one by one under g and returns the saved ones.
+func (s *messageService) createGroup(ctx context.Context, g *GroupStatus, msgs []*domain.Message) []*domain.Message {
+	created := make([]*domain.Message, 0, len(msgs))
+	for _, msg := range msgs {
+		if msg.Tags == nil {
+			msg.Tags = map[string]string{}
+		}
+		msg.Tags[GroupTag] = g.ID.String()
+
+		err := s.create(ctx, msg)
+		if err != nil {
+			log.Printf("[Service] Group %s: failed to create message to %s: %v", g.ID, msg.To, err)
+		} else {
+			created = append(created, msg)
+		}
+		s.groups.record(g, err)
+	}
+
+	s.groups.finish(g, time.Now().UTC())
+	return created
+}
+
+// GroupStatus returns the creation progress of a multicast group. It
+// returns ErrGroupNotFound for unknown IDs and for groups that finished
+// more than an hour ago.
+func (s *messageService) GroupStatus(id uuid.UUID) (GroupStatus, error) {
+	g, ok := s.groups.get(id)
+	if !ok {
+		return GroupStatus{}, ErrGroupNotFound
+	}
+	return g, nil
+}
@@ -0,0 +1,196 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	domain "github.com/oggyb/insider-assessment/internal/domain/message"
+)
+
+func newGroup(t *testing.T, n int) []*domain.Message {
+	t.Helper()
+	msgs := make([]*domain.Message, n)
+	for i := range msgs {
+		msgs[i] = newPendingMessage(t, fmt.Sprintf("+9050000000%02d", i), "hello")
+	}
+	return msgs
+}
+
+// waitGroupDone polls GroupStatus until the group is done.
+func waitGroupDone(t *testing.T, svc MessageService, id uuid.UUID) GroupStatus {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		g, err := svc.GroupStatus(id)
+		if err != nil {
+			t.Fatalf("GroupStatus: %v", err)
+		}
+		if g.Done {
+			return g
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("group %s not done in time: %+v", id, g)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestMulticast_SmallGroupIsCreatedSynchronously(t *testing.T) {
+	repo := &fakeRepo{}
+	svc := NewMessageService(repo, nil, nil, 0, 0, 0, WithMulticast(10, 5, 1))
+
+	res, err := svc.Multicast(context.Background(), newGroup(t, 3))
+	if err != nil {
+		t.Fatalf("Multicast: %v", err)
+	}
+	if res.Async || !res.Status.Done || res.Status.Created != 3 || res.Status.Total != 3 {
+		t.Fatalf("expected a finished synchronous group of 3, got %+v", res)
+	}
+	if len(res.Messages) != 3 || len(repo.pending) != 3 {
+		t.Fatalf("expected 3 created messages, got %d returned and %d saved", len(res.Messages), len(repo.pending))
+	}
+	for _, m := range repo.pending {
+		if m.Tags[GroupTag] != res.Status.ID.String() {
+			t.Fatalf("expected message tagged with group %s, got %v", res.Status.ID, m.Tags)
+		}
+	}
+}
+
+func TestMulticast_LargeGroupIsCreatedInBackground(t *testing.T) {
+	repo := &blockingSaveRepo{
+		fakeRepo: &fakeRepo{},
+		entered:  make(chan struct{}, 3),
+		release:  make(chan struct{}),
+	}
+	svc := NewMessageService(repo, nil, nil, 0, 0, 0, WithMulticast(10, 2, 1))
+
+	// Returns while the first save is still blocked.
+	res, err := svc.Multicast(context.Background(), newGroup(t, 3))
+	if err != nil {
+		t.Fatalf("Multicast: %v", err)
+	}
+	if !res.Async || res.Status.Done || res.Messages != nil {
+		t.Fatalf("expected a pending background group, got %+v", res)
+	}
+	<-repo.entered
+
+	// The only background slot is taken.
+	if _, err := svc.Multicast(context.Background(), newGroup(t, 3)); !errors.Is(err, ErrMulticastSaturated) {
+		t.Fatalf("expected ErrMulticastSaturated, got %v", err)
+	}
+
+	close(repo.release)
+	g := waitGroupDone(t, svc, res.Status.ID)
+	if g.Created != 3 || g.Failed != 0 || g.FinishedAt == nil {
+		t.Fatalf("expected 3 created messages, got %+v", g)
+	}
+}
+
+func TestMulticast_BackgroundGroupTakesACreateSlot(t *testing.T) {
+	repo := &blockingSaveRepo{
+		fakeRepo: &fakeRepo{},
+		entered:  make(chan struct{}, 3),
+		release:  make(chan struct{}),
+	}
+	svc := NewMessageService(repo, nil, nil, 0, 0, 0, WithMulticast(10, 2, 2), WithCreateConcurrency(1))
+
+	if _, err := svc.Multicast(context.Background(), newGroup(t, 3)); err != nil {
+		t.Fatalf("Multicast: %v", err)
+	}
+	<-repo.entered
+
+	// The group holds the only writer slot while it is being written.
+	if err := svc.Create(context.Background(), newPendingMessage(t, "+905000000099", "hi")); !errors.Is(err, ErrCreateSaturated) {
+		t.Fatalf("expected ErrCreateSaturated for a single create, got %v", err)
+	}
+	if _, err := svc.Multicast(context.Background(), newGroup(t, 3)); !errors.Is(err, ErrCreateSaturated) {
+		t.Fatalf("expected ErrCreateSaturated for a second group, got %v", err)
+	}
+	close(repo.release)
+}
+
+func TestClose_WaitsForBackgroundGroups(t *testing.T) {
+	repo := &blockingSaveRepo{
+		fakeRepo: &fakeRepo{},
+		entered:  make(chan struct{}, 3),
+		release:  make(chan struct{}),
+	}
+	svc := NewMessageService(repo, nil, nil, 0, 0, 0, WithMulticast(10, 2, 1))
+
+	res, err := svc.Multicast(context.Background(), newGroup(t, 3))
+	if err != nil {
+		t.Fatalf("Multicast: %v", err)
+	}
+	<-repo.entered
+
+	closed := make(chan struct{})
+	go func() {
+		_ = svc.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+		t.Fatal("expected Close to wait for the group being written")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(repo.release)
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("expected Close to return once the group was written")
+	}
+	if g, _ := svc.GroupStatus(res.Status.ID); !g.Done || g.Created != 3 {
+		t.Fatalf("expected the whole group written before Close returned, got %+v", g)
+	}
+}
+
+// failingSaveRepo fails Save for one recipient.
+type failingSaveRepo struct {
+	*fakeRepo
+	failTo string
+}
+
+func (f *failingSaveRepo) Save(ctx context.Context, m *domain.Message) error {
+	if m.To == f.failTo {
+		return domain.ErrDuplicateMessage
+	}
+	return f.fakeRepo.Save(ctx, m)
+}
+
+func TestMulticast_CountsFailedMessages(t *testing.T) {
+	msgs := newGroup(t, 3)
+	repo := &failingSaveRepo{fakeRepo: &fakeRepo{}, failTo: msgs[1].To}
+	svc := NewMessageService(repo, nil, nil, 0, 0, 0)
+
+	res, err := svc.Multicast(context.Background(), msgs)
+	if err != nil {
+		t.Fatalf("Multicast: %v", err)
+	}
+	if res.Status.Created != 2 || res.Status.Failed != 1 || len(res.Messages) != 2 {
+		t.Fatalf("expected 2 created and 1 failed, got %+v", res.Status)
+	}
+}
+
+func TestMulticast_RejectsTooManyRecipients(t *testing.T) {
+	repo := &fakeRepo{}
+	svc := NewMessageService(repo, nil, nil, 0, 0, 0, WithMulticast(2, 0, 0))
+
+	if _, err := svc.Multicast(context.Background(), newGroup(t, 3)); !errors.Is(err, ErrTooManyRecipients) {
+		t.Fatalf("expected ErrTooManyRecipients, got %v", err)
+	}
+	if len(repo.pending) != 0 {
+		t.Fatalf("expected nothing saved, got %d", len(repo.pending))
+	}
+}
+
+func TestGroupStatus_UnknownGroup(t *testing.T) {
+	svc := NewMessageService(&fakeRepo{}, nil, nil, 0, 0, 0)
+	if _, err := svc.GroupStatus(uuid.New()); !errors.Is(err, ErrGroupNotFound) {
+		t.Fatalf("expected ErrGroupNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,65 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	domain "github.com/oggyb/insider-assessment/internal/domain/message"
+)
+
+// WithOptOutLinks appends a personal unsubscribe link, baseURL followed by
+// the recipient's token from r, to every message's content. The link counts
+// toward the length limit. Recipients who followed their link are rejected
+// by the pre-send check like opted-out numbers. An empty baseURL or nil r
+// leaves links off.
+func WithOptOutLinks(r domain.OptOutRepository, baseURL string) Option {
+	return func(s *messageService) {
+		baseURL = strings.TrimRight(strings.TrimSpace(baseURL), "/")
+		if r == nil || baseURL == "" {
+			return
+		}
+		s.optOuts = r
+		s.optOutBaseURL = baseURL
+	}
+}
+
+// appendOptOutLink adds the recipient's opt-out link to msg.Content,
+// creating their token on first use.
+func (s *messageService) appendOptOutLink(ctx context.Context, msg *domain.Message) error {
+	if s.optOuts == nil {
+		return nil
+	}
+	tok, err := s.optOuts.OptOutTokenFor(ctx, msg.To)
+	if err != nil {
+		return fmt.Errorf("opt-out token: %w", err)
+	}
+	return msg.AppendLink(s.optOutBaseURL+"/"+tok.Token, s.contentRules)
+}
+
+// checkOptedOut rejects recipients who followed their opt-out link.
+func (s *messageService) checkOptedOut(ctx context.Context, to string) error {
+	if s.optOuts == nil {
+		return nil
+	}
+	opted, err := s.optOuts.IsOptedOut(ctx, to)
+	if err != nil {
+		return fmt.Errorf("check opt-out: %w", err)
+	}
+	if opted {
+		return ErrRecipientOptedOut
+	}
+	return nil
+}
+
+// ResolveOptOut records the opt-out of the recipient behind token, as
+// followed from their unsubscribe link. It returns
+// domain.ErrOptOutTokenNotFound for unknown tokens or when links are off.
+func (s *messageService) ResolveOptOut(ctx context.Context, token string) (*domain.OptOutToken, error) {
+	token = strings.TrimSpace(token)
+	if s.optOuts == nil || token == "" {
+		return nil, domain.ErrOptOutTokenNotFound
+	}
+	return s.optOuts.ResolveOptOutToken(ctx, token, time.Now())
+}
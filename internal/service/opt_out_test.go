@@ -0,0 +1,167 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	domain "github.com/oggyb/insider-assessment/internal/domain/message"
+	"github.com/oggyb/insider-assessment/internal/sms/smstest"
+)
+
+// fakeOptOuts is an in-memory domain.OptOutRepository keyed by recipient.
+type fakeOptOuts struct {
+	mu   sync.Mutex
+	byTo map[string]*domain.OptOutToken
+}
+
+func newFakeOptOuts() *fakeOptOuts {
+	return &fakeOptOuts{byTo: map[string]*domain.OptOutToken{}}
+}
+
+func (f *fakeOptOuts) OptOutTokenFor(ctx context.Context, to string) (*domain.OptOutToken, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if tok, ok := f.byTo[to]; ok {
+		return tok, nil
+	}
+	tok, err := domain.NewOptOutToken(to)
+	if err != nil {
+		return nil, err
+	}
+	f.byTo[to] = tok
+	return tok, nil
+}
+
+func (f *fakeOptOuts) ResolveOptOutToken(ctx context.Context, token string, at time.Time) (*domain.OptOutToken, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, tok := range f.byTo {
+		if tok.Token == token {
+			if tok.OptedOutAt == nil {
+				tok.OptedOutAt = &at
+			}
+			return tok, nil
+		}
+	}
+	return nil, domain.ErrOptOutTokenNotFound
+}
+
+func (f *fakeOptOuts) IsOptedOut(ctx context.Context, to string) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	tok, ok := f.byTo[to]
+	return ok && tok.OptedOutAt != nil, nil
+}
+
+const optOutBase = "https://sms.example.com/optout"
+
+func TestCreate_AppendsStoredOptOutLink(t *testing.T) {
+	repo := &fakeRepo{}
+	optOuts := newFakeOptOuts()
+	svc := NewMessageService(repo, nil, nil, 10, 1, time.Second, WithOptOutLinks(optOuts, optOutBase+"/"))
+
+	first := newPendingMessage(t, "+905000000001", "Big sale today")
+	second := newPendingMessage(t, "+905000000001", "Last day of the sale")
+	for _, msg := range []*domain.Message{first, second} {
+		if err := svc.Create(context.Background(), msg); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+	}
+
+	tok, ok := optOuts.byTo["+905000000001"]
+	if !ok || tok.Token == "" {
+		t.Fatal("expected a token to be stored for the recipient")
+	}
+	if want := "Big sale today " + optOutBase + "/" + tok.Token; first.Content != want {
+		t.Fatalf("content = %q, want %q", first.Content, want)
+	}
+	// The recipient keeps a single token across messages.
+	if !strings.HasSuffix(second.Content, "/"+tok.Token) || len(optOuts.byTo) != 1 {
+		t.Fatalf("expected the second message to reuse the token, got %q", second.Content)
+	}
+	if len(repo.pending) != 2 || repo.pending[0].Content != first.Content {
+		t.Fatal("expected the messages to be saved with the link")
+	}
+}
+
+func TestCreate_OptOutLinkCountsTowardLengthLimit(t *testing.T) {
+	repo := &fakeRepo{}
+	svc := NewMessageService(repo, nil, nil, 10, 1, time.Second, WithOptOutLinks(newFakeOptOuts(), optOutBase))
+
+	// Fits on its own, but not with the link appended.
+	msg := newPendingMessage(t, "+905000000001", strings.Repeat("a", domain.MaxContentLength-10))
+	if err := svc.Create(context.Background(), msg); !errors.Is(err, domain.ErrContentTooLong) {
+		t.Fatalf("expected ErrContentTooLong, got %v", err)
+	}
+	if len(repo.pending) != 0 {
+		t.Fatal("expected the message not to be saved")
+	}
+}
+
+func TestProcessBatch_TemplatedMessageGetsOptOutLink(t *testing.T) {
+	templates := fakeTemplates{}
+	tmpl := newTemplate(t, templates, "promo", "Hi {{name}}")
+	optOuts := newFakeOptOuts()
+
+	repo := &fakeRepo{}
+	svc := NewMessageService(repo, smstest.NewFakeClient(), nil, 10, 1, time.Second,
+		WithTemplates(templates), WithOptOutLinks(optOuts, optOutBase))
+
+	msg, _ := domain.NewMessage("+905000000001", "preview",
+		domain.WithTemplate(tmpl.ID), domain.WithTags(map[string]string{"name": "Ada"}))
+	if err := svc.Create(context.Background(), msg); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if msg.Content != "preview" {
+		t.Fatalf("expected the link to wait for rendering, got %q", msg.Content)
+	}
+
+	if _, err := svc.ProcessBatch(context.Background()); err != nil {
+		t.Fatalf("ProcessBatch: %v", err)
+	}
+	if want := "Hi Ada " + optOutBase + "/" + optOuts.byTo[msg.To].Token; msg.Content != want {
+		t.Fatalf("sent %q, want %q", msg.Content, want)
+	}
+}
+
+func TestResolveOptOut_StopsFurtherSends(t *testing.T) {
+	optOuts := newFakeOptOuts()
+	repo := &fakeRepo{}
+	client := smstest.NewFakeClient()
+	svc := NewMessageService(repo, client, nil, 10, 1, time.Second, WithOptOutLinks(optOuts, optOutBase))
+
+	msg := newPendingMessage(t, "+905000000001", "hello")
+	if err := svc.Create(context.Background(), msg); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	if _, err := svc.ResolveOptOut(context.Background(), "unknown"); !errors.Is(err, domain.ErrOptOutTokenNotFound) {
+		t.Fatalf("expected ErrOptOutTokenNotFound, got %v", err)
+	}
+	tok, err := svc.ResolveOptOut(context.Background(), optOuts.byTo[msg.To].Token)
+	if err != nil || tok.OptedOutAt == nil {
+		t.Fatalf("ResolveOptOut: tok=%+v err=%v", tok, err)
+	}
+
+	if _, err := svc.ProcessBatch(context.Background()); err != nil {
+		t.Fatalf("ProcessBatch: %v", err)
+	}
+	client.AssertCallCount(t, 0)
+	if msg.Status != domain.StatusFailed || !strings.Contains(msg.StatusReason, ErrRecipientOptedOut.Error()) {
+		t.Fatalf("expected FAILED as opted out, got %s %q", msg.Status, msg.StatusReason)
+	}
+}
+
+func TestResolveOptOut_WithoutLinksIsNotFound(t *testing.T) {
+	svc := NewMessageService(&fakeRepo{}, nil, nil, 10, 1, time.Second, WithOptOutLinks(newFakeOptOuts(), ""))
+	if _, err := svc.ResolveOptOut(context.Background(), "abc"); !errors.Is(err, domain.ErrOptOutTokenNotFound) {
+		t.Fatalf("expected ErrOptOutTokenNotFound, got %v", err)
+	}
+}
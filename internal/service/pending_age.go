@@ -0,0 +1,66 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// StalePendingEvent is the name StalePending is published under.
+const StalePendingEvent = "StalePending"
+
+// StalePending is published when the oldest pending message has waited
+// longer than the configured threshold.
+type StalePending struct {
+	Age       time.Duration
+	Threshold time.Duration
+}
+
+// Name implements event.Event.
+func (StalePending) Name() string { return StalePendingEvent }
+
+// String implements fmt.Stringer.
+func (e StalePending) String() string {
+	return fmt.Sprintf("oldest pending message has waited %s (threshold %s)",
+		e.Age.Round(time.Second), e.Threshold)
+}
+
+// PendingAge reports how long the oldest pending message has been waiting.
+type PendingAge struct {
+	Age time.Duration
+	// Threshold is the configured alert age; zero means no alert.
+	Threshold time.Duration
+	// Healthy is false when Age exceeds a non-zero Threshold.
+	Healthy bool
+}
+
+// WithPendingAgeAlert flags OldestPendingAge as unhealthy and publishes a
+// StalePending event to p whenever the oldest pending message is older
+// than threshold. A non-positive threshold disables the alert; a nil p
+// only disables the event.
+func WithPendingAgeAlert(p EventPublisher, threshold time.Duration) Option {
+	return func(s *messageService) {
+		if threshold > 0 {
+			s.pendingAgeEvents = p
+			s.pendingAgeAlert = threshold
+		}
+	}
+}
+
+// OldestPendingAge returns the age of the oldest pending message (zero when
+// nothing is pending) checked against the configured alert threshold.
+func (s *messageService) OldestPendingAge(ctx context.Context) (PendingAge, error) {
+	age, err := s.repo.OldestPendingAge(ctx)
+	if err != nil {
+		return PendingAge{}, fmt.Errorf("failed to load oldest pending age: %w", err)
+	}
+
+	res := PendingAge{Age: age, Threshold: s.pendingAgeAlert, Healthy: true}
+	if s.pendingAgeAlert > 0 && age > s.pendingAgeAlert {
+		res.Healthy = false
+		if s.pendingAgeEvents != nil {
+			s.pendingAgeEvents.Publish(StalePending{Age: age, Threshold: s.pendingAgeAlert})
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,86 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	domain "github.com/oggyb/insider-assessment/internal/domain/message"
+)
+
+// seedPendingAges saves one message per age, created that long ago, plus an
+// older message that was already sent and must not count.
+func seedPendingAges(t *testing.T, ages ...time.Duration) *fakeRepo {
+	t.Helper()
+
+	repo := &fakeRepo{}
+	for _, age := range ages {
+		m := newPendingMessage(t, "+905000000000", "hello")
+		m.CreatedAt = time.Now().Add(-age)
+		_ = repo.Save(context.Background(), m)
+	}
+
+	sent := newPendingMessage(t, "+905000000000", "done")
+	sent.CreatedAt = time.Now().Add(-24 * time.Hour)
+	sent.Status = domain.StatusSuccess
+	_ = repo.Save(context.Background(), sent)
+	return repo
+}
+
+func TestOldestPendingAge_ReturnsOldestPendingMessage(t *testing.T) {
+	repo := seedPendingAges(t, 5*time.Minute, 90*time.Minute, time.Minute)
+	svc := NewMessageService(repo, nil, nil, 0, 0, 0)
+
+	res, err := svc.OldestPendingAge(context.Background())
+	if err != nil {
+		t.Fatalf("OldestPendingAge: %v", err)
+	}
+	if res.Age < 90*time.Minute || res.Age > 91*time.Minute {
+		t.Fatalf("expected an age of about 90m, got %v", res.Age)
+	}
+	if !res.Healthy || res.Threshold != 0 {
+		t.Fatalf("expected healthy without a threshold, got %+v", res)
+	}
+}
+
+func TestOldestPendingAge_NothingPendingIsZero(t *testing.T) {
+	pub := &recordingPublisher{}
+	svc := NewMessageService(seedPendingAges(t), nil, nil, 0, 0, 0, WithPendingAgeAlert(pub, time.Minute))
+
+	res, err := svc.OldestPendingAge(context.Background())
+	if err != nil {
+		t.Fatalf("OldestPendingAge: %v", err)
+	}
+	if res.Age != 0 || !res.Healthy {
+		t.Fatalf("expected zero age and healthy, got %+v", res)
+	}
+	if len(pub.events) != 0 {
+		t.Fatalf("expected no events, got %v", pub.events)
+	}
+}
+
+func TestOldestPendingAge_ThresholdFlagsUnhealthyAndPublishes(t *testing.T) {
+	pub := &recordingPublisher{}
+	repo := seedPendingAges(t, 20*time.Minute)
+
+	svc := NewMessageService(repo, nil, nil, 0, 0, 0, WithPendingAgeAlert(pub, 30*time.Minute))
+	if res, _ := svc.OldestPendingAge(context.Background()); !res.Healthy || len(pub.events) != 0 {
+		t.Fatalf("expected healthy below the threshold, got %+v and %d events", res, len(pub.events))
+	}
+
+	svc = NewMessageService(repo, nil, nil, 0, 0, 0, WithPendingAgeAlert(pub, 10*time.Minute))
+	res, err := svc.OldestPendingAge(context.Background())
+	if err != nil {
+		t.Fatalf("OldestPendingAge: %v", err)
+	}
+	if res.Healthy || res.Threshold != 10*time.Minute {
+		t.Fatalf("expected unhealthy with a 10m threshold, got %+v", res)
+	}
+	if len(pub.events) != 1 {
+		t.Fatalf("expected one event, got %d", len(pub.events))
+	}
+	e, ok := pub.events[0].(StalePending)
+	if !ok || e.Name() != StalePendingEvent || e.Age < 20*time.Minute {
+		t.Fatalf("unexpected event %#v", pub.events[0])
+	}
+}
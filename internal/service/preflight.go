@@ -0,0 +1,96 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	domain "github.com/oggyb/insider-assessment/internal/domain/message"
+)
+
+var (
+	// ErrRecipientOptedOut is returned by a RecipientPolicy for recipients
+	// that asked not to be messaged.
+	ErrRecipientOptedOut = errors.New("recipient has opted out")
+	// ErrRecipientNotAllowed is returned by a RecipientPolicy for recipients
+	// outside the allow-list.
+	ErrRecipientNotAllowed = errors.New("recipient is not on the allow-list")
+)
+
+// RecipientPolicy decides whether a recipient may be messaged right now.
+// It is consulted just before each send, so rules that changed after a
+// message was enqueued still apply. A non-nil error is the reason the
+// message is failed.
+type RecipientPolicy interface {
+	Check(ctx context.Context, to string) error
+}
+
+// StaticRecipientPolicy is a RecipientPolicy backed by fixed lists, usually
+// taken from config.
+type StaticRecipientPolicy struct {
+	optedOut        map[string]struct{}
+	allowedPrefixes []string
+}
+
+// NewStaticRecipientPolicy rejects the optedOut numbers and, if
+// allowedPrefixes is not empty, every number that starts with none of them.
+// Blank entries are ignored.
+func NewStaticRecipientPolicy(optedOut, allowedPrefixes []string) *StaticRecipientPolicy {
+	p := &StaticRecipientPolicy{optedOut: map[string]struct{}{}}
+	for _, to := range optedOut {
+		if to = strings.TrimSpace(to); to != "" {
+			p.optedOut[to] = struct{}{}
+		}
+	}
+	for _, prefix := range allowedPrefixes {
+		if prefix = strings.TrimSpace(prefix); prefix != "" {
+			p.allowedPrefixes = append(p.allowedPrefixes, prefix)
+		}
+	}
+	return p
+}
+
+// Check implements RecipientPolicy.
+func (p *StaticRecipientPolicy) Check(ctx context.Context, to string) error {
+	if _, ok := p.optedOut[to]; ok {
+		return ErrRecipientOptedOut
+	}
+	if len(p.allowedPrefixes) == 0 {
+		return nil
+	}
+	for _, prefix := range p.allowedPrefixes {
+		if strings.HasPrefix(to, prefix) {
+			return nil
+		}
+	}
+	return ErrRecipientNotAllowed
+}
+
+// WithRecipientPolicy re-checks every recipient against p right before its
+// message is sent. Messages it rejects are marked FAILED with the reason and
+// never reach the provider.
+func WithRecipientPolicy(p RecipientPolicy) Option {
+	return func(s *messageService) {
+		s.recipientPolicy = p
+	}
+}
+
+// preflight re-validates msg against the current rules before sending.
+func (s *messageService) preflight(ctx context.Context, msg *domain.Message) error {
+	if strings.TrimSpace(msg.To) == "" {
+		return domain.ErrEmptyRecipient
+	}
+	if s.recipientPolicy != nil {
+		if err := s.recipientPolicy.Check(ctx, msg.To); err != nil {
+			return fmt.Errorf("recipient %s: %w", msg.To, err)
+		}
+	}
+	if err := s.checkOptedOut(ctx, msg.To); err != nil {
+		return fmt.Errorf("recipient %s: %w", msg.To, err)
+	}
+	return nil
+}
+
+// compile-time interface check
+var _ RecipientPolicy = (*StaticRecipientPolicy)(nil)
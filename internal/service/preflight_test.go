@@ -0,0 +1,56 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	domain "github.com/oggyb/insider-assessment/internal/domain/message"
+	"github.com/oggyb/insider-assessment/internal/sms/smstest"
+)
+
+func TestProcessBatch_PreflightFailsNowInvalidRecipients(t *testing.T) {
+	repo := &fakeRepo{}
+	optedOut := newPendingMessage(t, "+905000000001", "hello")
+	foreign := newPendingMessage(t, "+15550000000", "hello")
+	ok := newPendingMessage(t, "+905000000002", "hello")
+	for _, msg := range []*domain.Message{optedOut, foreign, ok} {
+		_ = repo.Save(context.Background(), msg)
+	}
+
+	// The rules changed after the messages were enqueued.
+	policy := NewStaticRecipientPolicy([]string{"+905000000001"}, []string{"+90"})
+
+	client := smstest.NewFakeClient()
+	svc := NewMessageService(repo, client, nil, 10, 1, time.Second, WithRecipientPolicy(policy))
+	res, err := svc.ProcessBatch(context.Background())
+	if err != nil {
+		t.Fatalf("ProcessBatch: %v", err)
+	}
+
+	client.AssertRecipients(t, "+905000000002")
+	if res.Failed != 2 || res.Succeeded != 1 {
+		t.Fatalf("expected 2 failed and 1 sent, got %+v", res)
+	}
+	for msg, reason := range map[*domain.Message]string{
+		optedOut: ErrRecipientOptedOut.Error(),
+		foreign:  ErrRecipientNotAllowed.Error(),
+	} {
+		if msg.Status != domain.StatusFailed || !strings.Contains(msg.StatusReason, reason) {
+			t.Errorf("%s: expected FAILED with %q, got %s %q", msg.To, reason, msg.Status, msg.StatusReason)
+		}
+	}
+}
+
+func TestStaticRecipientPolicy_Check(t *testing.T) {
+	p := NewStaticRecipientPolicy([]string{" +905000000001 ", ""}, nil)
+
+	if err := p.Check(context.Background(), "+905000000001"); !errors.Is(err, ErrRecipientOptedOut) {
+		t.Fatalf("expected ErrRecipientOptedOut, got %v", err)
+	}
+	if err := p.Check(context.Background(), "+15550000000"); err != nil {
+		t.Fatalf("expected any number to pass without an allow-list, got %v", err)
+	}
+}
@@ -0,0 +1,104 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	domain "github.com/oggyb/insider-assessment/internal/domain/message"
+)
+
+// MaxReportDays caps how many days a single report may span.
+const MaxReportDays = 92
+
+// MaxTopRecipients caps how many recipients TopRecipients may return.
+const MaxTopRecipients = 100
+
+// ErrInvalidReportRange is returned when a report range is empty, reversed
+// or longer than MaxReportDays.
+var ErrInvalidReportRange = errors.New("invalid report range")
+
+// ErrInvalidTopLimit is returned by TopRecipients when limit is outside
+// 1..MaxTopRecipients.
+var ErrInvalidTopLimit = errors.New("invalid top recipients limit")
+
+// DailyReport returns one DayStat per UTC day from the day of from through
+// the day of until (both inclusive). Days without finished messages are
+// included with zero counts so callers get a continuous series.
+func (s *messageService) DailyReport(ctx context.Context, from, until time.Time) ([]domain.DayStat, error) {
+	from = truncateDayUTC(from)
+	until = truncateDayUTC(until)
+
+	if until.Before(from) {
+		return nil, fmt.Errorf("%w: from must not be after until", ErrInvalidReportRange)
+	}
+	days := int(until.Sub(from)/(24*time.Hour)) + 1
+	if days > MaxReportDays {
+		return nil, fmt.Errorf("%w: range must not exceed %d days", ErrInvalidReportRange, MaxReportDays)
+	}
+
+	stats, err := s.repo.SentStatsByDay(ctx, from, until.AddDate(0, 0, 1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load report: %w", err)
+	}
+
+	byDay := make(map[time.Time]domain.DayStat, len(stats))
+	for _, st := range stats {
+		byDay[truncateDayUTC(st.Day)] = st
+	}
+
+	out := make([]domain.DayStat, days)
+	for i := range out {
+		day := from.AddDate(0, 0, i)
+		st := byDay[day]
+		st.Day = day
+		out[i] = st
+	}
+	return out, nil
+}
+
+// SendLatency returns the average and 95th percentile provider call duration
+// of messages sent successfully in [from, until). The window must be
+// non-empty and span at most MaxReportDays.
+func (s *messageService) SendLatency(ctx context.Context, from, until time.Time) (time.Duration, time.Duration, error) {
+	if !from.Before(until) {
+		return 0, 0, fmt.Errorf("%w: from must be before until", ErrInvalidReportRange)
+	}
+	if until.Sub(from) > MaxReportDays*24*time.Hour {
+		return 0, 0, fmt.Errorf("%w: range must not exceed %d days", ErrInvalidReportRange, MaxReportDays)
+	}
+
+	avg, p95, err := s.repo.SendLatencyStats(ctx, from, until)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to load latency stats: %w", err)
+	}
+	return avg, p95, nil
+}
+
+// TopRecipients returns the limit recipients with the most messages created
+// in [from, until), most first. The window must be non-empty and span at
+// most MaxReportDays; limit must be 1..MaxTopRecipients.
+func (s *messageService) TopRecipients(ctx context.Context, from, until time.Time, limit int) ([]domain.RecipientCount, error) {
+	if !from.Before(until) {
+		return nil, fmt.Errorf("%w: from must be before until", ErrInvalidReportRange)
+	}
+	if until.Sub(from) > MaxReportDays*24*time.Hour {
+		return nil, fmt.Errorf("%w: range must not exceed %d days", ErrInvalidReportRange, MaxReportDays)
+	}
+	if limit < 1 || limit > MaxTopRecipients {
+		return nil, fmt.Errorf("%w: must be between 1 and %d", ErrInvalidTopLimit, MaxTopRecipients)
+	}
+
+	top, err := s.repo.TopRecipients(ctx, from, until, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load top recipients: %w", err)
+	}
+	return top, nil
+}
+
+// truncateDayUTC returns midnight UTC of the day t falls on in UTC.
+func truncateDayUTC(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,236 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	domain "github.com/oggyb/insider-assessment/internal/domain/message"
+	"github.com/oggyb/insider-assessment/internal/sms"
+	"github.com/oggyb/insider-assessment/internal/sms/smstest"
+)
+
+func TestDailyReport_AggregatesPerDay(t *testing.T) {
+	repo := &fakeRepo{}
+	day := func(d, h int) time.Time { return time.Date(2026, time.March, d, h, 0, 0, 0, time.UTC) }
+
+	sent := func(at time.Time) {
+		m := newPendingMessage(t, "+905000000000", "hello")
+		m.MarkSent("ext", "{}")
+		m.SentAt = &at
+		_ = repo.Save(context.Background(), m)
+	}
+	failed := func(at time.Time) {
+		m := newPendingMessage(t, "+905000000000", "hello")
+		m.MarkFailed("")
+		m.UpdatedAt = at
+		_ = repo.Save(context.Background(), m)
+	}
+
+	sent(day(1, 9))
+	sent(day(1, 23))
+	failed(day(1, 12))
+	failed(day(3, 0))
+	sent(day(3, 8))
+	sent(day(5, 8)) // outside the range
+	_ = repo.Save(context.Background(), newPendingMessage(t, "+905000000000", "still pending"))
+
+	svc := NewMessageService(repo, nil, nil, 0, 0, 0)
+	stats, err := svc.DailyReport(context.Background(), day(1, 15), day(3, 15))
+	if err != nil {
+		t.Fatalf("DailyReport: %v", err)
+	}
+
+	want := []domain.DayStat{
+		{Day: day(1, 0), Sent: 2, Failed: 1},
+		{Day: day(2, 0)},
+		{Day: day(3, 0), Sent: 1, Failed: 1},
+	}
+	if len(stats) != len(want) {
+		t.Fatalf("expected %d days, got %d: %+v", len(want), len(stats), stats)
+	}
+	for i := range want {
+		if !stats[i].Day.Equal(want[i].Day) || stats[i].Sent != want[i].Sent || stats[i].Failed != want[i].Failed {
+			t.Fatalf("day %d: expected %+v, got %+v", i, want[i], stats[i])
+		}
+	}
+}
+
+func TestDailyReport_RejectsInvalidRanges(t *testing.T) {
+	svc := NewMessageService(&fakeRepo{}, nil, nil, 0, 0, 0)
+	now := time.Now()
+
+	cases := map[string][2]time.Time{
+		"reversed": {now, now.AddDate(0, 0, -1)},
+		"too long": {now.AddDate(0, 0, -MaxReportDays), now},
+	}
+	for name, r := range cases {
+		if _, err := svc.DailyReport(context.Background(), r[0], r[1]); !errors.Is(err, ErrInvalidReportRange) {
+			t.Fatalf("%s: expected ErrInvalidReportRange, got %v", name, err)
+		}
+	}
+
+	if _, err := svc.DailyReport(context.Background(), now.AddDate(0, 0, -(MaxReportDays-1)), now); err != nil {
+		t.Fatalf("expected the maximum range to be accepted, got %v", err)
+	}
+}
+
+func TestProcessBatch_RecordsSendDuration(t *testing.T) {
+	repo := &fakeRepo{}
+	msg := newPendingMessage(t, "+905000000000", "hello")
+	_ = repo.Save(context.Background(), msg)
+
+	client := smstest.NewFakeClient().Delay(20 * time.Millisecond)
+	svc := NewMessageService(repo, client, nil, 10, 1, 0)
+
+	if _, err := svc.ProcessBatch(context.Background()); err != nil {
+		t.Fatalf("ProcessBatch: %v", err)
+	}
+
+	if msg.Status != domain.StatusSuccess {
+		t.Fatalf("expected SUCCESS, got %s", msg.Status)
+	}
+	if msg.SendDuration == nil || *msg.SendDuration < 20*time.Millisecond {
+		t.Fatalf("expected a send duration of at least 20ms, got %v", msg.SendDuration)
+	}
+}
+
+// queuedClient waits locally before handing the send to the provider, like
+// a client-side rate limiter would.
+type queuedClient struct {
+	sms.Client
+	wait time.Duration
+}
+
+func (c queuedClient) Send(ctx context.Context, to, content string) (string, string, error) {
+	time.Sleep(c.wait)
+	return c.Client.Send(ctx, to, content)
+}
+
+func TestProcessBatch_PrefersClientMeasuredLatency(t *testing.T) {
+	srv := sms.NewTestServer(sms.WithTestLatency(30 * time.Millisecond))
+	defer srv.Close()
+
+	repo := &fakeRepo{}
+	msg := newPendingMessage(t, "+905000000000", "hello")
+	_ = repo.Save(context.Background(), msg)
+
+	client := queuedClient{Client: sms.NewWebhookClient(srv.URL, "key"), wait: 200 * time.Millisecond}
+	svc := NewMessageService(repo, client, nil, 10, 1, time.Second)
+
+	if _, err := svc.ProcessBatch(context.Background()); err != nil {
+		t.Fatalf("ProcessBatch: %v", err)
+	}
+
+	if msg.Status != domain.StatusSuccess {
+		t.Fatalf("expected SUCCESS, got %s", msg.Status)
+	}
+	// Only the provider round trip counts, not the local wait.
+	if d := msg.SendDuration; d == nil || *d < 30*time.Millisecond || *d >= 200*time.Millisecond {
+		t.Fatalf("expected the ~30ms provider round trip, got %v", d)
+	}
+}
+
+func TestSendLatency_ReturnsAverageAndP95(t *testing.T) {
+	repo := &fakeRepo{}
+	at := time.Date(2026, time.March, 1, 12, 0, 0, 0, time.UTC)
+
+	// 1ms..100ms: avg 50.5ms, percentile_cont(0.95) = 95.05ms.
+	for i := 1; i <= 100; i++ {
+		m := newPendingMessage(t, "+905000000000", "hello")
+		m.MarkSent("ext", "{}")
+		m.SentAt = &at
+		d := time.Duration(i) * time.Millisecond
+		m.SendDuration = &d
+		_ = repo.Save(context.Background(), m)
+	}
+
+	svc := NewMessageService(repo, nil, nil, 0, 0, 0)
+	avg, p95, err := svc.SendLatency(context.Background(), at.Add(-time.Hour), at.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("SendLatency: %v", err)
+	}
+	if avg != 50500*time.Microsecond {
+		t.Fatalf("expected avg 50.5ms, got %v", avg)
+	}
+	if p95 != 95050*time.Microsecond {
+		t.Fatalf("expected p95 95.05ms, got %v", p95)
+	}
+}
+
+func TestSendLatency_RejectsInvalidWindows(t *testing.T) {
+	svc := NewMessageService(&fakeRepo{}, nil, nil, 0, 0, 0)
+	now := time.Now()
+
+	cases := map[string][2]time.Time{
+		"empty":    {now, now},
+		"reversed": {now, now.Add(-time.Hour)},
+		"too long": {now.AddDate(0, 0, -(MaxReportDays + 1)), now},
+	}
+	for name, r := range cases {
+		if _, _, err := svc.SendLatency(context.Background(), r[0], r[1]); !errors.Is(err, ErrInvalidReportRange) {
+			t.Fatalf("%s: expected ErrInvalidReportRange, got %v", name, err)
+		}
+	}
+
+	if _, _, err := svc.SendLatency(context.Background(), now.AddDate(0, 0, -MaxReportDays), now); err != nil {
+		t.Fatalf("expected the maximum window to be accepted, got %v", err)
+	}
+}
+
+func TestTopRecipients_RanksByCount(t *testing.T) {
+	repo := &fakeRepo{}
+	at := time.Date(2026, time.March, 1, 12, 0, 0, 0, time.UTC)
+
+	save := func(to string, n int, created time.Time) {
+		for range n {
+			m := newPendingMessage(t, to, "hello")
+			m.CreatedAt = created
+			_ = repo.Save(context.Background(), m)
+		}
+	}
+	save("+905000000001", 2, at)
+	save("+905000000002", 5, at)
+	save("+905000000003", 3, at)
+	save("+905000000004", 3, at)
+	// Outside the window, so it must not outrank the others.
+	save("+905000000005", 9, at.Add(-2*time.Hour))
+
+	svc := NewMessageService(repo, nil, nil, 0, 0, 0)
+	top, err := svc.TopRecipients(context.Background(), at.Add(-time.Hour), at.Add(time.Hour), 3)
+	if err != nil {
+		t.Fatalf("TopRecipients: %v", err)
+	}
+
+	want := []domain.RecipientCount{
+		{To: "+905000000002", Count: 5},
+		{To: "+905000000003", Count: 3},
+		{To: "+905000000004", Count: 3},
+	}
+	if !slices.Equal(top, want) {
+		t.Fatalf("top recipients = %v, want %v", top, want)
+	}
+}
+
+func TestTopRecipients_RejectsInvalidWindowAndLimit(t *testing.T) {
+	svc := NewMessageService(&fakeRepo{}, nil, nil, 0, 0, 0)
+	now := time.Now()
+
+	for name, r := range map[string][2]time.Time{
+		"empty":    {now, now},
+		"reversed": {now, now.Add(-time.Hour)},
+		"too long": {now.AddDate(0, 0, -(MaxReportDays + 1)), now},
+	} {
+		if _, err := svc.TopRecipients(context.Background(), r[0], r[1], 10); !errors.Is(err, ErrInvalidReportRange) {
+			t.Fatalf("%s: expected ErrInvalidReportRange, got %v", name, err)
+		}
+	}
+
+	for _, limit := range []int{0, -1, MaxTopRecipients + 1} {
+		if _, err := svc.TopRecipients(context.Background(), now.Add(-time.Hour), now, limit); !errors.Is(err, ErrInvalidTopLimit) {
+			t.Fatalf("limit %d: expected ErrInvalidTopLimit, got %v", limit, err)
+		}
+	}
+}
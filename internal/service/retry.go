@@ -0,0 +1,35 @@
+package service
+
+import (
+	"time"
+
+	domain "github.com/oggyb/insider-assessment/internal/domain/message"
+)
+
+// WithSendRetries puts a message whose provider send failed back to PENDING
+// up to maxRetries times instead of failing it. Each retry waits
+// base*2^(n-1), capped at limit, before GetPending picks the message up
+// again. Only provider errors are retried; pre-flight, routing and
+// rendering failures are final. A non-positive maxRetries or base
+// disables retries.
+func WithSendRetries(maxRetries int, base, limit time.Duration) Option {
+	return func(s *messageService) {
+		if maxRetries <= 0 || base <= 0 {
+			return
+		}
+		s.maxRetries = maxRetries
+		s.retryBase = base
+		s.retryLimit = limit
+	}
+}
+
+// scheduleRetry puts msg back to PENDING with a backoff if it has retries
+// left, and reports whether it did.
+func (s *messageService) scheduleRetry(msg *domain.Message, raw string, sendErr error) bool {
+	if msg.RetryCount >= s.maxRetries {
+		return false
+	}
+	backoff := domain.RetryBackoff(msg.RetryCount+1, s.retryBase, s.retryLimit)
+	msg.ScheduleRetry(raw, sendErr.Error(), time.Now(), backoff)
+	return true
+}
@@ -0,0 +1,89 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	domain "github.com/oggyb/insider-assessment/internal/domain/message"
+)
+
+// failingSMS fails every send and counts the attempts.
+func failingSMS(attempts *atomic.Int32) *fakeSMS {
+	return &fakeSMS{send: func(ctx context.Context, to, content string) (string, string, error) {
+		attempts.Add(1)
+		return "", `{"error":"unavailable"}`, errors.New("provider unavailable")
+	}}
+}
+
+func TestProcessBatch_FailedSendWaitsForRetryBackoff(t *testing.T) {
+	repo := &fakeRepo{}
+	msg := newPendingMessage(t, "+905000000000", "retry me")
+	_ = repo.Save(context.Background(), msg)
+
+	var attempts atomic.Int32
+	svc := NewMessageService(repo, failingSMS(&attempts), nil, 10, 1, time.Second,
+		WithSendRetries(2, time.Hour, 4*time.Hour))
+
+	before := time.Now()
+	if _, err := svc.ProcessBatch(context.Background()); err != nil {
+		t.Fatalf("ProcessBatch: %v", err)
+	}
+	if msg.Status != domain.StatusPending || msg.RetryCount != 1 || msg.NextRetryAt == nil {
+		t.Fatalf("expected a scheduled retry, got status=%s retries=%d next=%v", msg.Status, msg.RetryCount, msg.NextRetryAt)
+	}
+	if wait := msg.NextRetryAt.Sub(before); wait < time.Hour || wait > time.Hour+time.Minute {
+		t.Fatalf("expected the first retry about an hour out, got %v", wait)
+	}
+	if msg.StatusReason == "" || msg.RawResponse == "" {
+		t.Fatalf("expected the failure to be recorded, got reason=%q raw=%q", msg.StatusReason, msg.RawResponse)
+	}
+
+	// Within the backoff the message is not picked up again.
+	if _, err := svc.ProcessBatch(context.Background()); err != nil {
+		t.Fatalf("ProcessBatch: %v", err)
+	}
+	if got := attempts.Load(); got != 1 {
+		t.Fatalf("expected no send while the backoff runs, got %d attempts", got)
+	}
+
+	// Once it has elapsed the message is retried, with a doubled backoff.
+	elapsed := time.Now().Add(-time.Second)
+	msg.NextRetryAt = &elapsed
+	before = time.Now()
+	if _, err := svc.ProcessBatch(context.Background()); err != nil {
+		t.Fatalf("ProcessBatch: %v", err)
+	}
+	if got := attempts.Load(); got != 2 {
+		t.Fatalf("expected a retry after the backoff, got %d attempts", got)
+	}
+	if msg.RetryCount != 2 || msg.NextRetryAt.Sub(before) < 2*time.Hour {
+		t.Fatalf("expected a second retry two hours out, got retries=%d next=%v", msg.RetryCount, msg.NextRetryAt)
+	}
+
+	// Retries exhausted: the next failure is final.
+	msg.NextRetryAt = &elapsed
+	if _, err := svc.ProcessBatch(context.Background()); err != nil {
+		t.Fatalf("ProcessBatch: %v", err)
+	}
+	if msg.Status != domain.StatusFailed || attempts.Load() != 3 {
+		t.Fatalf("expected FAILED after the last retry, got %s after %d attempts", msg.Status, attempts.Load())
+	}
+}
+
+func TestProcessBatch_NoRetriesByDefault(t *testing.T) {
+	repo := &fakeRepo{}
+	msg := newPendingMessage(t, "+905000000000", "fail once")
+	_ = repo.Save(context.Background(), msg)
+
+	var attempts atomic.Int32
+	svc := NewMessageService(repo, failingSMS(&attempts), nil, 10, 1, time.Second)
+	if _, err := svc.ProcessBatch(context.Background()); err != nil {
+		t.Fatalf("ProcessBatch: %v", err)
+	}
+	if msg.Status != domain.StatusFailed || msg.NextRetryAt != nil {
+		t.Fatalf("expected FAILED without a retry, got %s (next=%v)", msg.Status, msg.NextRetryAt)
+	}
+}
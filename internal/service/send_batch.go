@@ -0,0 +1,128 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	domain "github.com/oggyb/insider-assessment/internal/domain/message"
+	"github.com/oggyb/insider-assessment/internal/sms"
+)
+
+// maxSendBatch caps how many messages go into one SendBatch call, so a
+// large batch is still spread over several workers.
+const maxSendBatch = 50
+
+// sendUnit is what a worker picks up at once: a single message, or several
+// routed to a client that accepts them in one SendBatch call. msgs holds
+// indices into the claimed batch. client and routeErr are the routing
+// result of the unit's messages, so they are sent through the client they
+// were grouped for.
+type sendUnit struct {
+	msgs     []int
+	client   sms.Client
+	routeErr error
+	batch    sms.BatchSender
+}
+
+// sendUnits routes every message of a claimed batch and splits it into
+// units. Messages whose provider client implements sms.BatchSender are
+// grouped per provider, in batch order, up to maxSendBatch each; every
+// other message, including one that cannot be routed, is a unit of its own
+// and goes through Send.
+func (s *messageService) sendUnits(messages []*domain.Message) []sendUnit {
+	units := make([]sendUnit, 0, len(messages))
+	open := map[string]int{}
+
+	for i, msg := range messages {
+		client, err := s.router.Client(msg.Provider)
+		bs, ok := client.(sms.BatchSender)
+		if err != nil || !ok {
+			units = append(units, sendUnit{msgs: []int{i}, client: client, routeErr: err})
+			continue
+		}
+
+		provider := strings.ToLower(strings.TrimSpace(msg.Provider))
+		u, found := open[provider]
+		if !found || len(units[u].msgs) == maxSendBatch {
+			units = append(units, sendUnit{client: client, batch: bs})
+			u = len(units) - 1
+			open[provider] = u
+		}
+		units[u].msgs = append(units[u].msgs, i)
+	}
+	return units
+}
+
+// safeProcessGroup runs processGroup behind the same panic guard as
+// safeProcessMessage. A recovered panic is reported once and returned for
+// every message of the group.
+func (s *messageService) safeProcessGroup(ctx context.Context, workerID int, unit sendUnit, msgs []*domain.Message) (errs []error) {
+	defer func() {
+		rec := recover()
+		if rec == nil {
+			return
+		}
+
+		ids := make([]string, len(msgs))
+		for i, msg := range msgs {
+			ids[i] = msg.ID.String()
+		}
+		err := fmt.Errorf("panic while processing group of %d: %v", len(msgs), rec)
+		s.reporter.Report(err, map[string]any{
+			"panic":      rec,
+			"workerId":   workerID,
+			"messageIds": ids,
+			"stack":      string(debug.Stack()),
+		})
+
+		errs = make([]error, len(msgs))
+		for i := range errs {
+			errs[i] = err
+		}
+	}()
+
+	return s.processGroup(ctx, unit, msgs)
+}
+
+// processGroup sends msgs through one SendBatch call on the unit's client.
+// Each message first goes through the same checks as in processMessage;
+// those finished by them are left out of the call. The call's duration is
+// recorded as every sent message's SendDuration. It returns the outcome per
+// message.
+func (s *messageService) processGroup(ctx context.Context, unit sendUnit, msgs []*domain.Message) []error {
+	errs := make([]error, len(msgs))
+
+	var batch []sms.Message
+	var sent []int
+	for i, msg := range msgs {
+		content, ok, err := s.prepareSend(ctx, msg, unit.client, nil)
+		if !ok {
+			errs[i] = err
+			continue
+		}
+		batch = append(batch, sms.Message{To: msg.To, Content: content, Encoding: msg.Encoding})
+		sent = append(sent, i)
+	}
+	if len(batch) == 0 {
+		return errs
+	}
+
+	start := time.Now()
+	results, err := unit.batch.SendBatch(ctx, batch)
+	took := time.Since(start)
+	if err == nil && len(results) != len(batch) {
+		err = fmt.Errorf("provider answered %d of %d messages", len(results), len(batch))
+	}
+
+	for j, i := range sent {
+		res := sms.SendResult{Err: err}
+		if err == nil {
+			res = results[j]
+		}
+		errs[i] = s.finishSend(ctx, msgs[i], res.ExternalID, res.RawResponse, took, res.Err)
+	}
+	return errs
+}
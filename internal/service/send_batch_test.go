@@ -0,0 +1,149 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	domain "github.com/oggyb/insider-assessment/internal/domain/message"
+	"github.com/oggyb/insider-assessment/internal/sms"
+	"github.com/oggyb/insider-assessment/internal/sms/smstest"
+)
+
+// batchSMS is a client with a bulk endpoint. It records every SendBatch
+// call and fails the test on a plain Send.
+type batchSMS struct {
+	t   *testing.T
+	err error
+
+	mu    sync.Mutex
+	calls [][]sms.Message
+}
+
+func (b *batchSMS) Send(ctx context.Context, to, content string) (string, string, error) {
+	b.t.Errorf("unexpected Send to %s", to)
+	return "", "", errors.New("unexpected Send")
+}
+
+func (b *batchSMS) SendBatch(ctx context.Context, msgs []sms.Message) ([]sms.SendResult, error) {
+	b.mu.Lock()
+	b.calls = append(b.calls, msgs)
+	b.mu.Unlock()
+
+	if b.err != nil {
+		return nil, b.err
+	}
+	out := make([]sms.SendResult, len(msgs))
+	for i, m := range msgs {
+		out[i] = sms.SendResult{ExternalID: "ext-" + m.To, RawResponse: "{}"}
+	}
+	return out, nil
+}
+
+func (b *batchSMS) Health(ctx context.Context) error { return nil }
+
+func saveMessages(t *testing.T, repo *fakeRepo, n int) []*domain.Message {
+	t.Helper()
+	msgs := make([]*domain.Message, n)
+	for i := range msgs {
+		msgs[i] = newPendingMessage(t, fmt.Sprintf("+90500000000%d", i), "hello")
+		_ = repo.Save(context.Background(), msgs[i])
+	}
+	return msgs
+}
+
+func TestProcessBatch_UsesSendBatchWhenSupported(t *testing.T) {
+	repo := &fakeRepo{}
+	msgs := saveMessages(t, repo, 3)
+
+	client := &batchSMS{t: t}
+	svc := NewMessageService(repo, client, nil, 10, 2, time.Second)
+
+	res, err := svc.ProcessBatch(context.Background())
+	if err != nil {
+		t.Fatalf("ProcessBatch: %v", err)
+	}
+
+	if len(client.calls) != 1 || len(client.calls[0]) != 3 {
+		t.Fatalf("expected one SendBatch call with 3 messages, got %v", client.calls)
+	}
+	if res.Processed != 3 || res.Succeeded != 3 {
+		t.Fatalf("expected 3 processed and succeeded, got %+v", res)
+	}
+	for _, m := range msgs {
+		if m.Status != domain.StatusSuccess || m.MessageID != "ext-"+m.To {
+			t.Fatalf("expected %s sent with its own external ID, got %s / %q", m.To, m.Status, m.MessageID)
+		}
+	}
+}
+
+func TestProcessBatch_FallsBackToSendWithoutBatchSupport(t *testing.T) {
+	repo := &fakeRepo{}
+	saveMessages(t, repo, 3)
+
+	client := smstest.NewFakeClient()
+	svc := NewMessageService(repo, client, nil, 10, 2, time.Second)
+
+	if _, err := svc.ProcessBatch(context.Background()); err != nil {
+		t.Fatalf("ProcessBatch: %v", err)
+	}
+	client.AssertCallCount(t, 3)
+}
+
+func TestProcessBatch_SendBatchErrorFailsEveryMessage(t *testing.T) {
+	repo := &fakeRepo{}
+	msgs := saveMessages(t, repo, 2)
+
+	client := &batchSMS{t: t, err: errors.New("bulk endpoint down")}
+	svc := NewMessageService(repo, client, nil, 10, 1, time.Second)
+
+	res, err := svc.ProcessBatch(context.Background())
+	if err != nil {
+		t.Fatalf("ProcessBatch: %v", err)
+	}
+	if res.Failed != 2 {
+		t.Fatalf("expected both messages failed, got %+v", res)
+	}
+	for _, m := range msgs {
+		if m.Status != domain.StatusFailed {
+			t.Fatalf("expected %s FAILED, got %s", m.To, m.Status)
+		}
+	}
+}
+
+func TestSendUnits_GroupsPerBatchProvider(t *testing.T) {
+	bulk := &batchSMS{t: t}
+	router := sms.NewRouter(smstest.NewFakeClient())
+	router.Register("bulk", bulk)
+	svc := NewMessageService(&fakeRepo{}, nil, nil, 0, 0, 0, WithProviderRouter(router))
+	ms := svc.(*messageService)
+
+	var msgs []*domain.Message
+	for i := range maxSendBatch + 2 {
+		m, _ := domain.NewMessage(fmt.Sprintf("+9050000%05d", i), "hi", domain.WithProvider("bulk"))
+		msgs = append(msgs, m)
+	}
+	msgs = append(msgs, newPendingMessage(t, "+905000000000", "plain"))
+	unknown, _ := domain.NewMessage("+905000000001", "hi", domain.WithProvider("nope"))
+	msgs = append(msgs, unknown)
+
+	units := ms.sendUnits(msgs)
+	if len(units) != 4 {
+		t.Fatalf("expected 2 bulk groups and 2 single messages, got %d units", len(units))
+	}
+	if units[0].batch == nil || len(units[0].msgs) != maxSendBatch || len(units[1].msgs) != 2 {
+		t.Fatalf("expected groups of %d and 2, got %d and %d", maxSendBatch, len(units[0].msgs), len(units[1].msgs))
+	}
+	if units[0].client != sms.Client(bulk) {
+		t.Fatalf("expected the group to carry the client it was routed to, got %T", units[0].client)
+	}
+	if units[2].batch != nil || len(units[2].msgs) != 1 || units[2].client == nil {
+		t.Fatalf("expected the default-provider message on its own, got %+v", units[2])
+	}
+	if units[3].routeErr == nil || units[3].client != nil {
+		t.Fatalf("expected the unknown provider's routing error on its unit, got %+v", units[3])
+	}
+}
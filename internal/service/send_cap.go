@@ -0,0 +1,69 @@
+package service
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/oggyb/insider-assessment/internal/cache"
+)
+
+// sendCapKeyTTL keeps a day's counter around a little longer than the day,
+// after which it expires on its own.
+const sendCapKeyTTL = 48 * time.Hour
+
+// WithDailySendCap stops sending once n send attempts have been made in the
+// current UTC day, across all instances sharing the cache. Messages that
+// are not sent stay PENDING until the next day. It needs a cache; a
+// non-positive n or a nil cache means no cap.
+func WithDailySendCap(n int) Option {
+	return func(s *messageService) {
+		if n > 0 {
+			s.dailySendCap = int64(n)
+		}
+	}
+}
+
+// sendCapKey returns the counter key for the UTC day of now.
+func sendCapKey(now time.Time) string {
+	return cache.GlobalSent.Key(now.UTC().Format("2006-01-02"))
+}
+
+// dailyCapReached reports whether today's counter has already reached the
+// cap, so a batch can skip claiming messages it may not send. Cache errors
+// are logged and treated as "not reached".
+func (s *messageService) dailyCapReached(ctx context.Context) bool {
+	if s.dailySendCap <= 0 || s.cache == nil {
+		return false
+	}
+
+	v, err := s.cache.Get(ctx, sendCapKey(time.Now()))
+	if err != nil {
+		// Usually just "no sends yet today".
+		return false
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	return err == nil && n >= s.dailySendCap
+}
+
+// reserveSend counts one send attempt against today's cap and reports
+// whether it is allowed. Cache errors are logged and never block sending.
+func (s *messageService) reserveSend(ctx context.Context) bool {
+	if s.dailySendCap <= 0 || s.cache == nil {
+		return true
+	}
+
+	key := sendCapKey(time.Now())
+	n, err := s.cache.Incr(ctx, key)
+	if err != nil {
+		log.Printf("[Service] Failed to count send against the daily cap: %v", err)
+		return true
+	}
+	if n == 1 {
+		if err := s.cache.Expire(ctx, key, sendCapKeyTTL); err != nil {
+			log.Printf("[Service] Failed to set expiry on %s: %v", key, err)
+		}
+	}
+	return n <= s.dailySendCap
+}
@@ -0,0 +1,75 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	domain "github.com/oggyb/insider-assessment/internal/domain/message"
+	"github.com/oggyb/insider-assessment/internal/sms/smstest"
+)
+
+func TestProcessBatch_DailySendCapStopsSending(t *testing.T) {
+	repo := &fakeRepo{}
+	var msgs []*domain.Message
+	for i := 0; i < 5; i++ {
+		msg := newPendingMessage(t, "+905000000000", "hello")
+		_ = repo.Save(context.Background(), msg)
+		msgs = append(msgs, msg)
+	}
+
+	cache := newFakeCache()
+	client := smstest.NewFakeClient()
+	svc := NewMessageService(repo, client, cache, 10, 2, time.Second, WithDailySendCap(3))
+
+	res, err := svc.ProcessBatch(context.Background())
+	if err != nil {
+		t.Fatalf("ProcessBatch: %v", err)
+	}
+	client.AssertCallCount(t, 3)
+	if res.Processed != 3 {
+		t.Fatalf("expected 3 processed, got %d", res.Processed)
+	}
+
+	pending := 0
+	for _, msg := range msgs {
+		if msg.Status == domain.StatusPending {
+			pending++
+		}
+	}
+	if pending != 2 {
+		t.Fatalf("expected the 2 capped messages to stay PENDING, got %d", pending)
+	}
+
+	// Later batches the same day send nothing.
+	if _, err := svc.ProcessBatch(context.Background()); err != nil {
+		t.Fatalf("ProcessBatch: %v", err)
+	}
+	client.AssertCallCount(t, 3)
+
+	// A new day starts from a fresh counter.
+	_ = cache.Del(context.Background(), sendCapKey(time.Now()))
+	if _, err := svc.ProcessBatch(context.Background()); err != nil {
+		t.Fatalf("ProcessBatch: %v", err)
+	}
+	client.AssertCallCount(t, 5)
+}
+
+func TestProcessBatch_NoDailyCapByDefault(t *testing.T) {
+	repo := &fakeRepo{}
+	for i := 0; i < 4; i++ {
+		_ = repo.Save(context.Background(), newPendingMessage(t, "+905000000000", "hello"))
+	}
+
+	cache := newFakeCache()
+	client := smstest.NewFakeClient()
+	svc := NewMessageService(repo, client, cache, 10, 2, time.Second)
+	if _, err := svc.ProcessBatch(context.Background()); err != nil {
+		t.Fatalf("ProcessBatch: %v", err)
+	}
+
+	client.AssertCallCount(t, 4)
+	if _, err := cache.Get(context.Background(), sendCapKey(time.Now())); err == nil {
+		t.Fatal("expected no counter to be kept without a cap")
+	}
+}
@@ -0,0 +1,50 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	domain "github.com/oggyb/insider-assessment/internal/domain/message"
+)
+
+// errNoTemplates is returned when a message references a template but the
+// service was built without WithTemplates.
+var errNoTemplates = errors.New("message references a template but no template repository is configured")
+
+// WithTemplates lets messages reference a stored template (see
+// domain.WithTemplate). The template is looked up and rendered with the
+// message's tags when the message is sent.
+func WithTemplates(r domain.TemplateRepository) Option {
+	return func(s *messageService) {
+		s.templates = r
+	}
+}
+
+// renderContent returns the content to send for msg: its own Content, or
+// the current body of its template rendered with its tags (plus the opt-out
+// link, see WithOptOutLinks). On success the rendered text is stored on
+// msg, so the record shows what was sent.
+func (s *messageService) renderContent(ctx context.Context, msg *domain.Message) (string, error) {
+	if msg.TemplateID == nil {
+		return msg.Content, nil
+	}
+	if s.templates == nil {
+		return "", errNoTemplates
+	}
+
+	tmpl, err := s.templates.GetTemplate(ctx, *msg.TemplateID)
+	if err != nil {
+		return "", fmt.Errorf("load template %s: %w", msg.TemplateID.String(), err)
+	}
+	content, err := tmpl.Render(msg.Tags, s.contentRules)
+	if err != nil {
+		return "", fmt.Errorf("render template %q: %w", tmpl.Name, err)
+	}
+
+	msg.Content = content
+	if err := s.appendOptOutLink(ctx, msg); err != nil {
+		return "", fmt.Errorf("render template %q: %w", tmpl.Name, err)
+	}
+	return msg.Content, nil
+}
@@ -0,0 +1,110 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	domain "github.com/oggyb/insider-assessment/internal/domain/message"
+	"github.com/oggyb/insider-assessment/internal/sms/smstest"
+)
+
+// fakeTemplates is an in-memory domain.TemplateRepository keyed by name.
+type fakeTemplates map[string]*domain.Template
+
+func (f fakeTemplates) SaveTemplate(ctx context.Context, t *domain.Template) error {
+	if cur, ok := f[t.Name]; ok {
+		cur.Body = t.Body
+		return nil
+	}
+	f[t.Name] = t
+	return nil
+}
+
+func (f fakeTemplates) GetTemplate(ctx context.Context, id uuid.UUID) (*domain.Template, error) {
+	for _, t := range f {
+		if t.ID == id {
+			return t, nil
+		}
+	}
+	return nil, domain.ErrTemplateNotFound
+}
+
+func (f fakeTemplates) GetTemplateByName(ctx context.Context, name string) (*domain.Template, error) {
+	if t, ok := f[name]; ok {
+		return t, nil
+	}
+	return nil, domain.ErrTemplateNotFound
+}
+
+func newTemplate(t *testing.T, templates fakeTemplates, name, body string) *domain.Template {
+	t.Helper()
+
+	tmpl, err := domain.NewTemplate(name, body)
+	if err != nil {
+		t.Fatalf("NewTemplate: %v", err)
+	}
+	if err := templates.SaveTemplate(context.Background(), tmpl); err != nil {
+		t.Fatalf("SaveTemplate: %v", err)
+	}
+	return tmpl
+}
+
+func TestProcessBatch_PendingMessageUsesUpdatedTemplate(t *testing.T) {
+	templates := fakeTemplates{}
+	tmpl := newTemplate(t, templates, "otp", "Your code is {{code}}")
+
+	repo := &fakeRepo{}
+	msg, err := domain.NewMessage("+905000000001", "preview",
+		domain.WithTemplate(tmpl.ID), domain.WithTags(map[string]string{"code": "1234"}))
+	if err != nil {
+		t.Fatalf("NewMessage: %v", err)
+	}
+	_ = repo.Save(context.Background(), msg)
+
+	// Edited after the message was enqueued, before it is sent.
+	newTemplate(t, templates, "otp", "Code: {{ code }}. Do not share it.")
+
+	client := smstest.NewFakeClient()
+	svc := NewMessageService(repo, client, nil, 10, 1, time.Second, WithTemplates(templates))
+	if _, err := svc.ProcessBatch(context.Background()); err != nil {
+		t.Fatalf("ProcessBatch: %v", err)
+	}
+
+	client.AssertCallCount(t, 1)
+	const want = "Code: 1234. Do not share it."
+	if got := client.Calls()[0].Content; got != want {
+		t.Fatalf("sent %q, want %q", got, want)
+	}
+	if msg.Status != domain.StatusSuccess || msg.Content != want {
+		t.Fatalf("expected SUCCESS with the rendered content stored, got %s %q", msg.Status, msg.Content)
+	}
+}
+
+func TestProcessBatch_UnrenderableTemplateFailsMessage(t *testing.T) {
+	templates := fakeTemplates{}
+	tmpl := newTemplate(t, templates, "welcome", "Hi {{name}}")
+
+	repo := &fakeRepo{}
+	missingVar, _ := domain.NewMessage("+905000000001", "preview", domain.WithTemplate(tmpl.ID))
+	unknown, _ := domain.NewMessage("+905000000002", "preview", domain.WithTemplate(uuid.New()))
+	_ = repo.Save(context.Background(), missingVar)
+	_ = repo.Save(context.Background(), unknown)
+
+	client := smstest.NewFakeClient()
+	svc := NewMessageService(repo, client, nil, 10, 1, time.Second, WithTemplates(templates))
+	if _, err := svc.ProcessBatch(context.Background()); err != nil {
+		t.Fatalf("ProcessBatch: %v", err)
+	}
+
+	client.AssertCallCount(t, 0)
+	for _, msg := range []*domain.Message{missingVar, unknown} {
+		if msg.Status != domain.StatusFailed || msg.StatusReason == "" {
+			t.Fatalf("expected FAILED with a reason, got %s %q", msg.Status, msg.StatusReason)
+		}
+	}
+	if want := `render template "welcome": missing template variable: name`; missingVar.StatusReason != want {
+		t.Fatalf("reason = %q, want %q", missingVar.StatusReason, want)
+	}
+}
@@ -0,0 +1,119 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"golang.org/x/text/unicode/norm"
+
+	domain "github.com/oggyb/insider-assessment/internal/domain/message"
+)
+
+// ContentTransformer rewrites a message right before it is sent, e.g. to
+// shorten URLs or normalize unicode. It changes msg.Content in place; the
+// transformed content is what the provider receives and what is stored. A
+// non-nil error fails the message with that reason, as does a result that
+// breaks the content rules (see domain.Message.SetContent). The stored
+// content_hash keeps identifying the content as created, so
+// MESSAGE_DEDUP_CONTENT still catches resends of the original.
+type ContentTransformer interface {
+	Transform(ctx context.Context, msg *domain.Message) error
+}
+
+// ContentTransformerFunc adapts a function to ContentTransformer.
+type ContentTransformerFunc func(ctx context.Context, msg *domain.Message) error
+
+// Transform implements ContentTransformer.
+func (f ContentTransformerFunc) Transform(ctx context.Context, msg *domain.Message) error {
+	return f(ctx, msg)
+}
+
+// ChainTransformers runs the given transformers in order, stopping at the
+// first error. Nil entries are skipped; an empty chain is a no-op.
+func ChainTransformers(ts ...ContentTransformer) ContentTransformer {
+	return ContentTransformerFunc(func(ctx context.Context, msg *domain.Message) error {
+		for _, t := range ts {
+			if t == nil {
+				continue
+			}
+			if err := t.Transform(ctx, msg); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// WithContentTransformer applies t to every message before it is sent.
+// Without it messages are sent as stored.
+func WithContentTransformer(t ContentTransformer) Option {
+	return func(s *messageService) {
+		s.transformer = t
+	}
+}
+
+// TransformerRegistry maps names to transformers so the chain can be picked
+// from config at startup.
+type TransformerRegistry struct {
+	transformers map[string]ContentTransformer
+}
+
+// NewTransformerRegistry returns a registry holding the built-in
+// transformers:
+//   - "nfc": normalizes content to Unicode NFC, so composed and decomposed
+//     spellings of the same text are sent (and counted) alike.
+//   - "uppercase": upper-cases the content.
+//   - "collapse-spaces": trims the content and collapses runs of whitespace
+//     into single spaces.
+func NewTransformerRegistry() *TransformerRegistry {
+	r := &TransformerRegistry{transformers: map[string]ContentTransformer{}}
+	r.Register("nfc", contentFunc(norm.NFC.String))
+	r.Register("uppercase", contentFunc(strings.ToUpper))
+	r.Register("collapse-spaces", contentFunc(func(s string) string {
+		return strings.Join(strings.Fields(s), " ")
+	}))
+	return r
+}
+
+// Register adds or replaces the transformer known as name.
+func (r *TransformerRegistry) Register(name string, t ContentTransformer) {
+	r.transformers[strings.ToLower(strings.TrimSpace(name))] = t
+}
+
+// Build chains the named transformers in the given order. Blank names are
+// ignored; an unknown name is an error.
+func (r *TransformerRegistry) Build(names []string) (ContentTransformer, error) {
+	var chain []ContentTransformer
+	for _, name := range names {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		t, ok := r.transformers[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown content transformer %q (known: %s)", name, strings.Join(r.names(), ", "))
+		}
+		chain = append(chain, t)
+	}
+	return ChainTransformers(chain...), nil
+}
+
+// names returns the registered names, sorted.
+func (r *TransformerRegistry) names() []string {
+	names := make([]string, 0, len(r.transformers))
+	for name := range r.transformers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// contentFunc wraps a plain string rewrite as a ContentTransformer.
+func contentFunc(fn func(string) string) ContentTransformer {
+	return ContentTransformerFunc(func(ctx context.Context, msg *domain.Message) error {
+		msg.Content = fn(msg.Content)
+		return nil
+	})
+}
@@ -0,0 +1,123 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	domain "github.com/oggyb/insider-assessment/internal/domain/message"
+	"github.com/oggyb/insider-assessment/internal/sms/smstest"
+)
+
+// appendTransformer appends suffix to the content, so the order in which
+// transformers ran can be read off the result.
+func appendTransformer(suffix string) ContentTransformer {
+	return ContentTransformerFunc(func(ctx context.Context, msg *domain.Message) error {
+		msg.Content += suffix
+		return nil
+	})
+}
+
+func TestProcessBatch_TransformersRunInOrder(t *testing.T) {
+	repo := &fakeRepo{}
+	msg := newPendingMessage(t, "+905000000000", "hello")
+	_ = repo.Save(context.Background(), msg)
+
+	client := smstest.NewFakeClient()
+	chain := ChainTransformers(appendTransformer(" a"), nil, appendTransformer(" b"))
+	svc := NewMessageService(repo, client, nil, 10, 1, time.Second, WithContentTransformer(chain))
+	if _, err := svc.ProcessBatch(context.Background()); err != nil {
+		t.Fatalf("ProcessBatch: %v", err)
+	}
+
+	calls := client.Calls()
+	if len(calls) != 1 || calls[0].Content != "hello a b" {
+		t.Fatalf("expected the transformed content to be sent, got %+v", calls)
+	}
+	if msg.Status != domain.StatusSuccess || msg.Content != "hello a b" {
+		t.Fatalf("expected SUCCESS with the sent content stored, got %s %q", msg.Status, msg.Content)
+	}
+}
+
+func TestProcessBatch_FailingTransformFailsMessage(t *testing.T) {
+	repo := &fakeRepo{}
+	msg := newPendingMessage(t, "+905000000000", "hello")
+	_ = repo.Save(context.Background(), msg)
+
+	ran := false
+	chain := ChainTransformers(
+		ContentTransformerFunc(func(ctx context.Context, msg *domain.Message) error {
+			return errors.New("url shortener unavailable")
+		}),
+		ContentTransformerFunc(func(ctx context.Context, msg *domain.Message) error {
+			ran = true
+			return nil
+		}),
+	)
+
+	client := smstest.NewFakeClient()
+	svc := NewMessageService(repo, client, nil, 10, 1, time.Second, WithContentTransformer(chain))
+	res, err := svc.ProcessBatch(context.Background())
+	if err != nil {
+		t.Fatalf("ProcessBatch: %v", err)
+	}
+
+	client.AssertCallCount(t, 0)
+	if ran {
+		t.Fatal("expected the chain to stop at the failing transform")
+	}
+	if res.Failed != 1 || msg.Status != domain.StatusFailed || !strings.Contains(msg.StatusReason, "url shortener unavailable") {
+		t.Fatalf("expected FAILED with the transform error, got %+v %s %q", res, msg.Status, msg.StatusReason)
+	}
+}
+
+func TestProcessBatch_TransformedContentIsRevalidated(t *testing.T) {
+	cases := map[string]ContentTransformer{
+		"too long": appendTransformer(" " + strings.Repeat("x", domain.MaxContentLength)),
+		"blank":    contentFunc(func(string) string { return "  " }),
+	}
+	for name, transform := range cases {
+		t.Run(name, func(t *testing.T) {
+			repo := &fakeRepo{}
+			msg := newPendingMessage(t, "+905000000000", "hello")
+			_ = repo.Save(context.Background(), msg)
+
+			client := smstest.NewFakeClient()
+			svc := NewMessageService(repo, client, nil, 10, 1, time.Second, WithContentTransformer(transform))
+			res, err := svc.ProcessBatch(context.Background())
+			if err != nil {
+				t.Fatalf("ProcessBatch: %v", err)
+			}
+
+			client.AssertCallCount(t, 0)
+			if res.Failed != 1 || msg.Status != domain.StatusFailed || !strings.HasPrefix(msg.StatusReason, "transform content:") {
+				t.Fatalf("expected FAILED with a transform reason, got %+v %s %q", res, msg.Status, msg.StatusReason)
+			}
+			if msg.Content != "hello" {
+				t.Fatalf("expected the content as created to be kept, got %q", msg.Content)
+			}
+		})
+	}
+}
+
+func TestTransformerRegistry_Build(t *testing.T) {
+	chain, err := NewTransformerRegistry().Build([]string{" Collapse-Spaces ", "", "uppercase", "nfc"})
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+
+	// "e" followed by a combining acute accent composes to a single "É".
+	msg := &domain.Message{Content: "  cafe\u0301   au  lait "}
+	if err := chain.Transform(context.Background(), msg); err != nil {
+		t.Fatalf("Transform: %v", err)
+	}
+	if msg.Content != "CAFÉ AU LAIT" {
+		t.Fatalf("Content = %q, want %q", msg.Content, "CAFÉ AU LAIT")
+	}
+
+	if _, err := NewTransformerRegistry().Build([]string{"shorten-urls"}); err == nil {
+		t.Fatal("expected an unknown transformer to be rejected")
+	}
+}
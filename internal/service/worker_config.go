@@ -0,0 +1,91 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Upper bounds for runtime worker tuning. They keep a typo in a PATCH
+// request from overwhelming the database or the provider.
+const (
+	MaxBatchSize         = 1000
+	MaxWorkers           = 100
+	MaxPerMessageTimeout = 5 * time.Minute
+)
+
+// ErrInvalidWorkerConfig is returned when a worker config update is rejected.
+var ErrInvalidWorkerConfig = errors.New("invalid worker config")
+
+// WorkerConfig is a snapshot of the batch processing settings.
+type WorkerConfig struct {
+	BatchSize         int
+	MaxWorkers        int
+	PerMessageTimeout time.Duration
+}
+
+// WorkerConfigUpdate is a partial update; nil fields are left unchanged.
+type WorkerConfigUpdate struct {
+	BatchSize         *int
+	MaxWorkers        *int
+	PerMessageTimeout *time.Duration
+}
+
+// Validate checks that every setting is within its allowed range.
+func (c WorkerConfig) Validate() error {
+	if c.BatchSize < 1 || c.BatchSize > MaxBatchSize {
+		return fmt.Errorf("%w: batchSize must be between 1 and %d", ErrInvalidWorkerConfig, MaxBatchSize)
+	}
+	if c.MaxWorkers < 1 || c.MaxWorkers > MaxWorkers {
+		return fmt.Errorf("%w: maxWorkers must be between 1 and %d", ErrInvalidWorkerConfig, MaxWorkers)
+	}
+	if c.PerMessageTimeout <= 0 || c.PerMessageTimeout > MaxPerMessageTimeout {
+		return fmt.Errorf("%w: perMessageTimeout must be greater than 0 and at most %s", ErrInvalidWorkerConfig, MaxPerMessageTimeout)
+	}
+	return nil
+}
+
+// WorkerConfig returns the current batch processing settings.
+func (s *messageService) WorkerConfig() WorkerConfig {
+	s.cfgMu.RLock()
+	defer s.cfgMu.RUnlock()
+
+	return WorkerConfig{
+		BatchSize:         s.batchSize,
+		MaxWorkers:        s.maxWorkers,
+		PerMessageTimeout: s.perMessageTimeout,
+	}
+}
+
+// UpdateWorkerConfig applies a partial update atomically and returns the
+// resulting settings. Nothing is changed if the result would be invalid.
+// Batches already in flight keep the settings they started with.
+func (s *messageService) UpdateWorkerConfig(u WorkerConfigUpdate) (WorkerConfig, error) {
+	s.cfgMu.Lock()
+	defer s.cfgMu.Unlock()
+
+	next := WorkerConfig{
+		BatchSize:         s.batchSize,
+		MaxWorkers:        s.maxWorkers,
+		PerMessageTimeout: s.perMessageTimeout,
+	}
+	if u.BatchSize != nil {
+		next.BatchSize = *u.BatchSize
+	}
+	if u.MaxWorkers != nil {
+		next.MaxWorkers = *u.MaxWorkers
+	}
+	if u.PerMessageTimeout != nil {
+		next.PerMessageTimeout = *u.PerMessageTimeout
+	}
+
+	if err := next.Validate(); err != nil {
+		return WorkerConfig{}, err
+	}
+
+	s.batchSize = next.BatchSize
+	s.maxWorkers = next.MaxWorkers
+	s.perMessageTimeout = next.PerMessageTimeout
+
+	return next, nil
+}
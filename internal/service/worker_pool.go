@@ -0,0 +1,104 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+// WithWorkerPool runs ProcessBatch's workers on a pool of size long-lived
+// goroutines, created once and fed every batch, instead of starting and
+// stopping goroutines per batch. A batch then uses at most size workers,
+// even if MaxWorkers is higher. Close stops the pool. A non-positive size
+// keeps the per-batch goroutines.
+func WithWorkerPool(size int) Option {
+	return func(s *messageService) {
+		if size > 0 {
+			s.pool = newWorkerPool(size)
+		}
+	}
+}
+
+// workerPool is a fixed set of goroutines running the jobs submitted to it.
+type workerPool struct {
+	size int
+	jobs chan func()
+	wg   sync.WaitGroup
+
+	// mu guards closed against submits racing Close.
+	mu     sync.RWMutex
+	closed bool
+
+	// ran counts the jobs run by the pool's goroutines.
+	ran atomic.Int64
+}
+
+func newWorkerPool(size int) *workerPool {
+	p := &workerPool{size: size, jobs: make(chan func())}
+	p.wg.Add(size)
+	for range size {
+		go p.run()
+	}
+	return p
+}
+
+func (p *workerPool) run() {
+	defer p.wg.Done()
+	for job := range p.jobs {
+		p.ran.Add(1)
+		job()
+	}
+}
+
+// submit hands job to an idle goroutine, waiting for one if all are busy.
+// It reports false without running job if the pool is closed or ctx ends
+// first.
+func (p *workerPool) submit(ctx context.Context, job func()) bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.closed {
+		return false
+	}
+
+	select {
+	case p.jobs <- job:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// close stops accepting jobs and waits for running ones to finish. It is
+// safe to call more than once.
+func (p *workerPool) close() {
+	p.mu.Lock()
+	if !p.closed {
+		p.closed = true
+		close(p.jobs)
+	}
+	p.mu.Unlock()
+
+	p.wg.Wait()
+}
+
+// startWorker runs job on the shared pool (WithWorkerPool) or, without one
+// or once it is closed, on a goroutine of its own.
+func (s *messageService) startWorker(ctx context.Context, job func()) {
+	if s.pool != nil && s.pool.submit(ctx, job) {
+		return
+	}
+	go job()
+}
+
+// Close waits for multicast groups still being written in the background
+// and stops the shared worker pool, if any, after the jobs it is running
+// have finished. Call it once no more batches or groups are started, e.g.
+// after the scheduler and the HTTP server are closed; later batches fall
+// back to per-batch goroutines.
+func (s *messageService) Close() error {
+	s.multicastWG.Wait()
+	if s.pool != nil {
+		s.pool.close()
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/oggyb/insider-assessment/internal/sms/smstest"
+)
+
+func TestProcessBatch_WorkerPoolIsReusedAcrossBatches(t *testing.T) {
+	repo := &fakeRepo{}
+	client := smstest.NewFakeClient()
+	svc := NewMessageService(repo, client, nil, 4, 2, time.Second, WithWorkerPool(2))
+	defer svc.Close()
+	pool := svc.(*messageService).pool
+
+	workers := 0
+	for batch := range 3 {
+		for i := range 4 {
+			_ = repo.Save(context.Background(), newPendingMessage(t, fmt.Sprintf("+9050000%02d%02d", batch, i), "hi"))
+		}
+		res, err := svc.ProcessBatch(context.Background())
+		if err != nil {
+			t.Fatalf("ProcessBatch: %v", err)
+		}
+		if res.Succeeded != 4 {
+			t.Fatalf("batch %d: expected 4 sent, got %+v", batch, res)
+		}
+		workers += res.Workers
+	}
+
+	client.AssertCallCount(t, 12)
+	if svc.(*messageService).pool != pool {
+		t.Fatal("expected the same pool for every batch")
+	}
+	if got := pool.ran.Load(); got != int64(workers) || workers != 6 {
+		t.Fatalf("expected all 6 workers to run on the pool, got %d of %d", got, workers)
+	}
+}
+
+func TestProcessBatch_WorkerPoolCapsWorkers(t *testing.T) {
+	repo := &fakeRepo{}
+	for i := range 5 {
+		_ = repo.Save(context.Background(), newPendingMessage(t, fmt.Sprintf("+90500000000%d", i), "hi"))
+	}
+	svc := NewMessageService(repo, smstest.NewFakeClient(), nil, 10, 4, time.Second, WithWorkerPool(2))
+	defer svc.Close()
+
+	res, err := svc.ProcessBatch(context.Background())
+	if err != nil {
+		t.Fatalf("ProcessBatch: %v", err)
+	}
+	if res.Workers != 2 || res.Succeeded != 5 {
+		t.Fatalf("expected 5 messages sent by 2 pooled workers, got %+v", res)
+	}
+}
+
+func TestWorkerPool_CloseWaitsForRunningJobs(t *testing.T) {
+	pool := newWorkerPool(2)
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	if !pool.submit(context.Background(), func() {
+		close(started)
+		<-release
+	}) {
+		t.Fatal("expected submit to succeed")
+	}
+	<-started
+
+	closed := make(chan struct{})
+	go func() {
+		pool.close()
+		close(closed)
+	}()
+
+	select {
+	case <-closed:
+		t.Fatal("close returned while a job was still running")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	close(release)
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("close did not return after the job finished")
+	}
+
+	if pool.submit(context.Background(), func() {}) {
+		t.Fatal("expected submit to fail on a closed pool")
+	}
+	pool.close() // idempotent
+}
+
+func TestProcessBatch_AfterCloseFallsBackToPerBatchWorkers(t *testing.T) {
+	repo := &fakeRepo{}
+	_ = repo.Save(context.Background(), newPendingMessage(t, "+905000000001", "hi"))
+	client := smstest.NewFakeClient()
+	svc := NewMessageService(repo, client, nil, 10, 2, time.Second, WithWorkerPool(2))
+
+	if err := svc.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if _, err := svc.ProcessBatch(context.Background()); err != nil {
+		t.Fatalf("ProcessBatch: %v", err)
+	}
+	client.AssertCallCount(t, 1)
+}
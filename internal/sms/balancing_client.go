@@ -0,0 +1,145 @@
+package sms
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+var _ Client = (*BalancingClient)(nil)
+
+// ErrNoHealthyProvider is returned by BalancingClient when every member is
+// currently unhealthy.
+var ErrNoHealthyProvider = errors.New("no healthy sms provider")
+
+// Strategy decides how BalancingClient spreads sends over its members.
+type Strategy string
+
+const (
+	// StrategyRoundRobin sends to each healthy member in turn.
+	StrategyRoundRobin Strategy = "round-robin"
+	// StrategyWeighted sends to healthy members in proportion to their
+	// weights, interleaved rather than in bursts.
+	StrategyWeighted Strategy = "weighted"
+)
+
+// StrategyFor resolves a configured strategy name.
+func StrategyFor(name string) (Strategy, error) {
+	switch s := Strategy(strings.ToLower(strings.TrimSpace(name))); s {
+	case StrategyRoundRobin, StrategyWeighted:
+		return s, nil
+	default:
+		return "", fmt.Errorf("unknown sms balancing strategy %q", name)
+	}
+}
+
+// HealthReporter reports whether a provider is currently usable, e.g. a
+// HealthMonitor polling it.
+type HealthReporter interface {
+	Healthy() bool
+}
+
+// BalancedMember is one provider behind a BalancingClient.
+type BalancedMember struct {
+	Name   string
+	Client Client
+	// Weight is the member's share under StrategyWeighted; values below 1
+	// count as 1. It is ignored by StrategyRoundRobin.
+	Weight int
+	// Health optionally reports whether the member may be picked. Nil
+	// means always healthy.
+	Health HealthReporter
+}
+
+// BalancingClient spreads sends over several providers, skipping those
+// whose HealthReporter says they are down. It is safe for concurrent use.
+type BalancingClient struct {
+	mu      sync.Mutex
+	members []balancedMember
+}
+
+// balancedMember is a member plus its smooth weighted round-robin state.
+type balancedMember struct {
+	BalancedMember
+	current int
+}
+
+// NewBalancingClient builds a BalancingClient over members using strategy.
+func NewBalancingClient(strategy Strategy, members ...BalancedMember) (*BalancingClient, error) {
+	if strategy != StrategyRoundRobin && strategy != StrategyWeighted {
+		return nil, fmt.Errorf("unknown sms balancing strategy %q", strategy)
+	}
+	if len(members) == 0 {
+		return nil, errors.New("sms balancing needs at least one provider")
+	}
+
+	b := &BalancingClient{members: make([]balancedMember, len(members))}
+	for i, m := range members {
+		if m.Client == nil {
+			return nil, fmt.Errorf("sms balancing provider %q has no client", m.Name)
+		}
+		if strategy == StrategyRoundRobin || m.Weight < 1 {
+			m.Weight = 1
+		}
+		b.members[i] = balancedMember{BalancedMember: m}
+	}
+	return b, nil
+}
+
+// Send implements Client.Send through the next healthy member.
+func (b *BalancingClient) Send(ctx context.Context, to, content string) (string, string, error) {
+	m, err := b.pick()
+	if err != nil {
+		return "", "", err
+	}
+	externalID, raw, err := m.Client.Send(ctx, to, content)
+	if err != nil {
+		return externalID, raw, fmt.Errorf("provider %q: %w", m.Name, err)
+	}
+	return externalID, raw, nil
+}
+
+// Health implements Client.Health: the balancer is usable while any member
+// passes its health check.
+func (b *BalancingClient) Health(ctx context.Context) error {
+	var errs []error
+	for _, m := range b.members {
+		err := m.Client.Health(ctx)
+		if err == nil {
+			return nil
+		}
+		errs = append(errs, fmt.Errorf("provider %q: %w", m.Name, err))
+	}
+	return errors.Join(errs...)
+}
+
+// pick selects the next healthy member with smooth weighted round-robin:
+// every healthy member gains its weight, the one with the most credit is
+// chosen and pays back the total. With equal weights this is plain
+// round-robin.
+func (b *BalancingClient) pick() (*BalancedMember, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var best *balancedMember
+	total := 0
+	for i := range b.members {
+		m := &b.members[i]
+		if m.Health != nil && !m.Health.Healthy() {
+			continue
+		}
+		m.current += m.Weight
+		total += m.Weight
+		if best == nil || m.current > best.current {
+			best = m
+		}
+	}
+	if best == nil {
+		return nil, ErrNoHealthyProvider
+	}
+
+	best.current -= total
+	return &best.BalancedMember, nil
+}
@@ -0,0 +1,139 @@
+package sms
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+)
+
+// countingClient counts sends and reports a fixed health result.
+type countingClient struct {
+	sends     atomic.Int32
+	healthErr error
+}
+
+func (c *countingClient) Send(ctx context.Context, to, content string) (string, string, error) {
+	c.sends.Add(1)
+	return "id", "{}", nil
+}
+
+func (c *countingClient) Health(ctx context.Context) error { return c.healthErr }
+
+// switchHealth is a HealthReporter that can be flipped by tests.
+type switchHealth struct{ down atomic.Bool }
+
+func (h *switchHealth) Healthy() bool { return !h.down.Load() }
+
+func sendN(t *testing.T, c Client, n int) {
+	t.Helper()
+	for range n {
+		if _, _, err := c.Send(context.Background(), "+905000000000", "hi"); err != nil {
+			t.Fatalf("Send: %v", err)
+		}
+	}
+}
+
+func TestBalancingClient_RoundRobinDistributesEvenly(t *testing.T) {
+	a, b, c := &countingClient{}, &countingClient{}, &countingClient{}
+	bal, err := NewBalancingClient(StrategyRoundRobin,
+		BalancedMember{Name: "a", Client: a, Weight: 5}, // ignored by round-robin
+		BalancedMember{Name: "b", Client: b},
+		BalancedMember{Name: "c", Client: c},
+	)
+	if err != nil {
+		t.Fatalf("NewBalancingClient: %v", err)
+	}
+
+	sendN(t, bal, 9)
+
+	for name, cl := range map[string]*countingClient{"a": a, "b": b, "c": c} {
+		if got := cl.sends.Load(); got != 3 {
+			t.Fatalf("provider %s got %d sends, want 3", name, got)
+		}
+	}
+}
+
+func TestBalancingClient_WeightedFollowsWeights(t *testing.T) {
+	a, b := &countingClient{}, &countingClient{}
+	bal, err := NewBalancingClient(StrategyWeighted,
+		BalancedMember{Name: "a", Client: a, Weight: 3},
+		BalancedMember{Name: "b", Client: b, Weight: 1},
+	)
+	if err != nil {
+		t.Fatalf("NewBalancingClient: %v", err)
+	}
+
+	// Smooth weighting interleaves: the light member is used within every
+	// window of four sends, not only after the heavy one is done.
+	sendN(t, bal, 4)
+	if a.sends.Load() != 3 || b.sends.Load() != 1 {
+		t.Fatalf("after 4 sends got a=%d b=%d, want 3/1", a.sends.Load(), b.sends.Load())
+	}
+
+	sendN(t, bal, 396)
+	if a.sends.Load() != 300 || b.sends.Load() != 100 {
+		t.Fatalf("after 400 sends got a=%d b=%d, want 300/100", a.sends.Load(), b.sends.Load())
+	}
+}
+
+func TestBalancingClient_SkipsUnhealthyProvider(t *testing.T) {
+	a, b := &countingClient{}, &countingClient{}
+	health := &switchHealth{}
+	bal, err := NewBalancingClient(StrategyRoundRobin,
+		BalancedMember{Name: "a", Client: a, Health: health},
+		BalancedMember{Name: "b", Client: b},
+	)
+	if err != nil {
+		t.Fatalf("NewBalancingClient: %v", err)
+	}
+
+	health.down.Store(true)
+	sendN(t, bal, 4)
+	if a.sends.Load() != 0 || b.sends.Load() != 4 {
+		t.Fatalf("with a down got a=%d b=%d, want 0/4", a.sends.Load(), b.sends.Load())
+	}
+
+	health.down.Store(false)
+	sendN(t, bal, 4)
+	if a.sends.Load() != 2 || b.sends.Load() != 6 {
+		t.Fatalf("after a recovered got a=%d b=%d, want 2/6", a.sends.Load(), b.sends.Load())
+	}
+}
+
+func TestBalancingClient_AllUnhealthy(t *testing.T) {
+	health := &switchHealth{}
+	health.down.Store(true)
+	bal, _ := NewBalancingClient(StrategyRoundRobin,
+		BalancedMember{Name: "a", Client: &countingClient{healthErr: errors.New("down")}, Health: health},
+	)
+
+	if _, _, err := bal.Send(context.Background(), "+905000000000", "hi"); !errors.Is(err, ErrNoHealthyProvider) {
+		t.Fatalf("expected ErrNoHealthyProvider, got %v", err)
+	}
+	if err := bal.Health(context.Background()); err == nil {
+		t.Fatal("expected Health to fail when every provider is down")
+	}
+}
+
+func TestBalancingClient_HealthPassesWhileAnyMemberIsUp(t *testing.T) {
+	bal, _ := NewBalancingClient(StrategyWeighted,
+		BalancedMember{Name: "a", Client: &countingClient{healthErr: errors.New("down")}},
+		BalancedMember{Name: "b", Client: &countingClient{}},
+	)
+	if err := bal.Health(context.Background()); err != nil {
+		t.Fatalf("expected healthy, got %v", err)
+	}
+}
+
+func TestStrategyFor(t *testing.T) {
+	if s, err := StrategyFor(" Weighted "); err != nil || s != StrategyWeighted {
+		t.Fatalf("StrategyFor(weighted) = %q, %v", s, err)
+	}
+	if _, err := StrategyFor("random"); err == nil {
+		t.Fatal("expected an unknown strategy to be rejected")
+	}
+	if _, err := NewBalancingClient(StrategyRoundRobin); err == nil {
+		t.Fatal("expected a balancer without providers to be rejected")
+	}
+}
@@ -0,0 +1,27 @@
+package sms
+
+import (
+	"context"
+
+	"github.com/oggyb/insider-assessment/internal/domain/message"
+)
+
+// encodingKey is the context key for a per-message encoding override.
+type encodingKey struct{}
+
+// ContextWithEncoding attaches an encoding hint that overrides the one
+// clients would derive from the content. The Client interface only carries
+// recipient and content, so per-message hints travel with the context.
+func ContextWithEncoding(ctx context.Context, e message.Encoding) context.Context {
+	return context.WithValue(ctx, encodingKey{}, e)
+}
+
+// EncodingFor returns the encoding hint for sending content: the override
+// attached by ContextWithEncoding if any, otherwise the encoding the content
+// needs.
+func EncodingFor(ctx context.Context, content string) message.Encoding {
+	if e, ok := ctx.Value(encodingKey{}).(message.Encoding); ok && e != "" {
+		return e
+	}
+	return message.ClassifyEncoding(content)
+}
@@ -0,0 +1,67 @@
+package sms
+
+import (
+	"context"
+	"log"
+	"sync/atomic"
+	"time"
+)
+
+// HealthMonitor periodically calls Client.Health and keeps the latest result
+// in a flag that can be read cheaply from hot paths (e.g. before each batch).
+type HealthMonitor struct {
+	client   Client
+	interval time.Duration
+	timeout  time.Duration
+	healthy  atomic.Bool
+}
+
+// NewHealthMonitor creates a monitor for client polling every interval.
+// The provider is assumed healthy until the first failed check.
+func NewHealthMonitor(client Client, interval time.Duration) *HealthMonitor {
+	m := &HealthMonitor{
+		client:   client,
+		interval: interval,
+		timeout:  2 * time.Second,
+	}
+	m.healthy.Store(true)
+	return m
+}
+
+// Healthy reports the result of the most recent health check.
+func (m *HealthMonitor) Healthy() bool {
+	return m.healthy.Load()
+}
+
+// Run polls the provider until ctx is cancelled. It is meant to be started
+// in its own goroutine.
+func (m *HealthMonitor) Run(ctx context.Context) {
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.check(ctx)
+		}
+	}
+}
+
+// check runs a single health probe and logs state transitions.
+func (m *HealthMonitor) check(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+
+	err := m.client.Health(ctx)
+	healthy := err == nil
+
+	if prev := m.healthy.Swap(healthy); prev != healthy {
+		if healthy {
+			log.Println("[SMS] Provider healthy again, resuming sends.")
+		} else {
+			log.Printf("[SMS] Provider health check failed, pausing sends: %v", err)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package sms
+
+import (
+	"context"
+	"time"
+)
+
+// latencyKey is the context key for a provider latency recorder.
+type latencyKey struct{}
+
+// LatencyRecorder receives the provider round-trip time measured by a
+// client during Send. It stays zero for clients that do not measure it.
+type LatencyRecorder struct {
+	d time.Duration
+}
+
+// Duration returns the recorded round-trip time, or 0 if none was
+// recorded.
+func (r *LatencyRecorder) Duration() time.Duration {
+	return r.d
+}
+
+// ContextWithLatencyRecorder attaches a recorder that clients fill with the
+// provider round-trip time of a Send. Like the encoding hint it travels
+// with the context because the Client interface only returns the
+// provider's answer. A recorder must not be shared by concurrent sends.
+func ContextWithLatencyRecorder(ctx context.Context) (context.Context, *LatencyRecorder) {
+	r := &LatencyRecorder{}
+	return context.WithValue(ctx, latencyKey{}, r), r
+}
+
+// recordLatency stores d in the recorder attached to ctx, if any.
+func recordLatency(ctx context.Context, d time.Duration) {
+	if r, ok := ctx.Value(latencyKey{}).(*LatencyRecorder); ok {
+		r.d = d
+	}
+}
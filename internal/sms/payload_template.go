@@ -0,0 +1,64 @@
+package sms
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"text/template"
+
+	"github.com/oggyb/insider-assessment/internal/request"
+)
+
+// PayloadTemplate renders the webhook request body from a text/template, for
+// providers that expect a different shape than the default {to, content}.
+// The template sees .To, .Content and .Encoding (GSM7 or UCS2); the "json"
+// function quotes a value as a JSON literal, e.g.
+//
+//	{"sms":{"recipient":{{json .To}},"text":{{json .Content}}}}
+type PayloadTemplate struct {
+	tmpl *template.Template
+}
+
+// ParsePayloadTemplate parses and validates a payload template. A template is
+// rejected if it does not parse, fails to execute, or does not produce valid
+// JSON for a sample message containing characters that need escaping.
+func ParsePayloadTemplate(text string) (*PayloadTemplate, error) {
+	tmpl, err := template.New("payload").
+		Option("missingkey=error").
+		Funcs(template.FuncMap{"json": jsonLiteral}).
+		Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid payload template: %w", err)
+	}
+
+	pt := &PayloadTemplate{tmpl: tmpl}
+
+	sample := request.WebhookRequest{To: "+905000000000", Content: `sample "quoted" \ text`, Encoding: "GSM7"}
+	body, err := pt.Render(sample)
+	if err != nil {
+		return nil, fmt.Errorf("invalid payload template: %w", err)
+	}
+	if !json.Valid(body) {
+		return nil, fmt.Errorf("invalid payload template: output is not valid JSON: %s", body)
+	}
+
+	return pt, nil
+}
+
+// Render executes the template for a single message.
+func (p *PayloadTemplate) Render(data request.WebhookRequest) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := p.tmpl.Execute(&buf, data); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// jsonLiteral marshals v as a JSON value for embedding into a template.
+func jsonLiteral(v any) (string, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
@@ -0,0 +1,62 @@
+package sms
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// DefaultProvider is the name under which the fallback client is registered.
+const DefaultProvider = "default"
+
+// Router maps provider names to Client instances so individual messages
+// can be routed to a specific provider (e.g. OTP vs marketing).
+// It is built once at startup and is safe for concurrent reads.
+type Router struct {
+	clients map[string]Client
+	def     Client
+}
+
+// NewRouter creates a router whose fallback is def. def is also registered
+// under DefaultProvider so it can be selected explicitly.
+func NewRouter(def Client) *Router {
+	return &Router{
+		clients: map[string]Client{DefaultProvider: def},
+		def:     def,
+	}
+}
+
+// Register adds (or replaces) a named provider. Names are case-insensitive.
+func (r *Router) Register(name string, c Client) {
+	r.clients[normalizeProvider(name)] = c
+}
+
+// Client returns the client for the given provider name. An empty name
+// selects the default provider; an unknown name is an error so a message
+// meant for a specific provider is never silently sent through another one.
+func (r *Router) Client(name string) (Client, error) {
+	name = normalizeProvider(name)
+	if name == "" {
+		return r.def, nil
+	}
+
+	c, ok := r.clients[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown sms provider %q", name)
+	}
+	return c, nil
+}
+
+// Names returns the registered provider names in sorted order.
+func (r *Router) Names() []string {
+	out := make([]string, 0, len(r.clients))
+	for name := range r.clients {
+		out = append(out, name)
+	}
+	sort.Strings(out)
+	return out
+}
+
+func normalizeProvider(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
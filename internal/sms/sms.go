@@ -2,7 +2,11 @@
 // and checking the health of the underlying provider.
 package sms
 
-import "context"
+import (
+	"context"
+
+	"github.com/oggyb/insider-assessment/internal/domain/message"
+)
 
 // Client is the contract for an SMS provider implementation.
 type Client interface {
@@ -13,3 +17,30 @@ type Client interface {
 	// Health checks whether the SMS provider is reachable and usable.
 	Health(ctx context.Context) error
 }
+
+// Message is one message of a SendBatch call.
+type Message struct {
+	To      string
+	Content string
+	// Encoding overrides the encoding derived from Content, like
+	// ContextWithEncoding does for Send; empty derives it.
+	Encoding message.Encoding
+}
+
+// SendResult is the provider's answer for one message of a SendBatch call,
+// the same values Send returns.
+type SendResult struct {
+	ExternalID  string
+	RawResponse string
+	Err         error
+}
+
+// BatchSender is implemented by clients whose provider accepts several
+// messages in one call (e.g. a bulk endpoint). It is optional: callers
+// check for it and fall back to Send per message.
+type BatchSender interface {
+	// SendBatch sends msgs in one provider call and returns one result per
+	// message, in the same order. An error means the call as a whole
+	// failed and applies to every message.
+	SendBatch(ctx context.Context, msgs []Message) ([]SendResult, error)
+}
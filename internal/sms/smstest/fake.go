@@ -0,0 +1,156 @@
+// Package smstest provides a programmable in-memory sms.Client for tests.
+package smstest
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/oggyb/insider-assessment/internal/sms"
+	"github.com/oggyb/insider-assessment/internal/timeutil"
+)
+
+var _ sms.Client = (*FakeClient)(nil)
+
+// Call is a single recorded Send invocation.
+type Call struct {
+	To      string
+	Content string
+}
+
+// FakeClient is an sms.Client whose behaviour is programmed by the test.
+// By default every Send succeeds with a generated external ID. It is safe
+// for concurrent use, so it can back the service worker pool.
+type FakeClient struct {
+	mu         sync.Mutex
+	externalID string
+	raw        string
+	err        error
+	delay      time.Duration
+	panicValue any
+	healthErr  error
+	calls      []Call
+}
+
+// NewFakeClient returns a fake that accepts every message.
+func NewFakeClient() *FakeClient {
+	return &FakeClient{raw: "{}"}
+}
+
+// Succeed makes subsequent sends return externalID and raw. An empty
+// externalID keeps the generated "fake-<n>" IDs.
+func (f *FakeClient) Succeed(externalID, raw string) *FakeClient {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.externalID, f.raw, f.err, f.panicValue = externalID, raw, nil, nil
+	return f
+}
+
+// Fail makes subsequent sends return err.
+func (f *FakeClient) Fail(err error) *FakeClient {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.err, f.panicValue = err, nil
+	return f
+}
+
+// Delay makes every send wait d before answering. The wait is cut short
+// (and ctx.Err returned) if the send context ends first.
+func (f *FakeClient) Delay(d time.Duration) *FakeClient {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.delay = d
+	return f
+}
+
+// Panic makes subsequent sends panic with v.
+func (f *FakeClient) Panic(v any) *FakeClient {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.panicValue = v
+	return f
+}
+
+// SetHealth makes Health return err; nil reports the provider healthy.
+func (f *FakeClient) SetHealth(err error) *FakeClient {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.healthErr = err
+	return f
+}
+
+// Send records the call and answers according to the programmed behaviour.
+func (f *FakeClient) Send(ctx context.Context, to, content string) (string, string, error) {
+	f.mu.Lock()
+	f.calls = append(f.calls, Call{To: to, Content: content})
+	n := len(f.calls)
+	externalID, raw, err, delay, panicValue := f.externalID, f.raw, f.err, f.delay, f.panicValue
+	f.mu.Unlock()
+
+	if delay > 0 && !timeutil.SleepCtx(ctx, delay) {
+		return "", "", ctx.Err()
+	}
+
+	if panicValue != nil {
+		panic(panicValue)
+	}
+	if err != nil {
+		return "", "", err
+	}
+	if externalID == "" {
+		externalID = fmt.Sprintf("fake-%d", n)
+	}
+	return externalID, raw, nil
+}
+
+// Health returns the error set with SetHealth.
+func (f *FakeClient) Health(ctx context.Context) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.healthErr
+}
+
+// Calls returns a copy of the recorded sends in call order.
+func (f *FakeClient) Calls() []Call {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]Call(nil), f.calls...)
+}
+
+// CallCount returns how many times Send was called.
+func (f *FakeClient) CallCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.calls)
+}
+
+// Recipients returns the recipient of every recorded send in call order.
+func (f *FakeClient) Recipients() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	out := make([]string, len(f.calls))
+	for i, c := range f.calls {
+		out[i] = c.To
+	}
+	return out
+}
+
+// AssertCallCount fails t unless Send was called exactly want times.
+func (f *FakeClient) AssertCallCount(t testing.TB, want int) {
+	t.Helper()
+	if got := f.CallCount(); got != want {
+		t.Fatalf("expected %d sends, got %d", want, got)
+	}
+}
+
+// AssertRecipients fails t unless the recorded recipients equal want, in
+// order.
+func (f *FakeClient) AssertRecipients(t testing.TB, want ...string) {
+	t.Helper()
+	if got := f.Recipients(); !reflect.DeepEqual(got, want) && (len(got) != 0 || len(want) != 0) {
+		t.Fatalf("expected recipients %v, got %v", want, got)
+	}
+}
@@ -0,0 +1,100 @@
+package smstest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestFakeClient_SucceedsByDefault(t *testing.T) {
+	f := NewFakeClient()
+
+	id, raw, err := f.Send(context.Background(), "+905000000001", "hi")
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if id != "fake-1" || raw != "{}" {
+		t.Fatalf("unexpected result id=%q raw=%q", id, raw)
+	}
+
+	f.Succeed("ext-42", `{"ok":true}`)
+	id, raw, _ = f.Send(context.Background(), "+905000000002", "hi")
+	if id != "ext-42" || raw != `{"ok":true}` {
+		t.Fatalf("unexpected result id=%q raw=%q", id, raw)
+	}
+}
+
+func TestFakeClient_Fail(t *testing.T) {
+	want := errors.New("provider down")
+	f := NewFakeClient().Fail(want)
+
+	if _, _, err := f.Send(context.Background(), "+905000000001", "hi"); !errors.Is(err, want) {
+		t.Fatalf("expected %v, got %v", want, err)
+	}
+	f.AssertCallCount(t, 1)
+}
+
+func TestFakeClient_DelayHonoursContext(t *testing.T) {
+	f := NewFakeClient().Delay(time.Second)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, _, err := f.Send(ctx, "+905000000001", "hi")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed >= time.Second {
+		t.Fatalf("expected the delay to be cut short, took %s", elapsed)
+	}
+
+	f.Delay(20 * time.Millisecond)
+	start = time.Now()
+	if _, _, err := f.Send(context.Background(), "+905000000001", "hi"); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+		t.Fatalf("expected send to wait for the delay, took %s", elapsed)
+	}
+}
+
+func TestFakeClient_Panic(t *testing.T) {
+	f := NewFakeClient().Panic("boom")
+
+	defer func() {
+		if rec := recover(); rec != "boom" {
+			t.Fatalf("expected panic %q, got %v", "boom", rec)
+		}
+		f.AssertCallCount(t, 1)
+	}()
+	_, _, _ = f.Send(context.Background(), "+905000000001", "hi")
+}
+
+func TestFakeClient_RecordsCalls(t *testing.T) {
+	f := NewFakeClient()
+	f.AssertRecipients(t)
+
+	_, _, _ = f.Send(context.Background(), "+905000000001", "first")
+	_, _, _ = f.Send(context.Background(), "+905000000002", "second")
+
+	f.AssertCallCount(t, 2)
+	f.AssertRecipients(t, "+905000000001", "+905000000002")
+	if calls := f.Calls(); calls[1].Content != "second" {
+		t.Fatalf("expected second call content to be recorded, got %+v", calls)
+	}
+}
+
+func TestFakeClient_Health(t *testing.T) {
+	f := NewFakeClient()
+	if err := f.Health(context.Background()); err != nil {
+		t.Fatalf("expected healthy, got %v", err)
+	}
+
+	want := errors.New("down")
+	f.SetHealth(want)
+	if err := f.Health(context.Background()); !errors.Is(err, want) {
+		t.Fatalf("expected %v, got %v", want, err)
+	}
+}
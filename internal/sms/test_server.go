@@ -0,0 +1,78 @@
+package sms
+
+import (
+	"encoding/json"
+	"math/rand/v2"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/oggyb/insider-assessment/internal/response"
+	"github.com/oggyb/insider-assessment/internal/timeutil"
+)
+
+// TestServerOption customizes the provider started by NewTestServer.
+type TestServerOption func(*testProvider)
+
+// WithTestFailureRate makes the given fraction of sends (0 to 1) fail with
+// a 500, e.g. to exercise retries. Values outside the range are clamped.
+func WithTestFailureRate(rate float64) TestServerOption {
+	return func(p *testProvider) {
+		p.failureRate = min(max(rate, 0), 1)
+	}
+}
+
+// WithTestLatency delays every send by d, or until the client gives up.
+func WithTestLatency(d time.Duration) TestServerOption {
+	return func(p *testProvider) {
+		p.latency = d
+	}
+}
+
+// testProvider is the handler behind NewTestServer.
+type testProvider struct {
+	failureRate float64
+	latency     time.Duration
+}
+
+// NewTestServer starts an in-process HTTP server that mimics the SMS
+// provider for tests and demos: GET answers 200 (health), POST answers 202
+// with a random messageId in the shape DefaultSuccessChecker expects, or a
+// 500 for the configured share of sends. The caller must Close it.
+func NewTestServer(opts ...TestServerOption) *httptest.Server {
+	p := &testProvider{}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return httptest.NewServer(p)
+}
+
+func (p *testProvider) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		w.WriteHeader(http.StatusOK)
+		return
+	case http.MethodPost:
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !timeutil.SleepCtx(r.Context(), p.latency) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if p.failureRate > 0 && rand.Float64() < p.failureRate {
+		w.WriteHeader(http.StatusInternalServerError)
+		_ = json.NewEncoder(w).Encode(response.WebhookResponse{Message: "Internal Server Error"})
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	_ = json.NewEncoder(w).Encode(response.WebhookResponse{
+		Message:   "Accepted",
+		MessageID: uuid.NewString(),
+	})
+}
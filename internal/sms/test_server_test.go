@@ -0,0 +1,85 @@
+package sms
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTestServer_DrivesWebhookClientToSuccess(t *testing.T) {
+	srv := NewTestServer()
+	defer srv.Close()
+
+	c := NewWebhookClient(srv.URL, "key")
+	if err := c.Health(context.Background()); err != nil {
+		t.Fatalf("Health: %v", err)
+	}
+
+	seen := map[string]bool{}
+	for range 3 {
+		id, _, err := c.Send(context.Background(), "+905000000000", "hi")
+		if err != nil {
+			t.Fatalf("Send: %v", err)
+		}
+		if id == "" || seen[id] {
+			t.Fatalf("expected a fresh messageId, got %q", id)
+		}
+		seen[id] = true
+	}
+}
+
+func TestTestServer_ForcedFailure(t *testing.T) {
+	srv := NewTestServer(WithTestFailureRate(1))
+	defer srv.Close()
+
+	c := NewWebhookClient(srv.URL, "key")
+	_, raw, err := c.Send(context.Background(), "+905000000000", "hi")
+	if err == nil || !strings.Contains(err.Error(), "500") {
+		t.Fatalf("expected a non-2xx failure, got %v", err)
+	}
+	if raw == "" {
+		t.Fatal("expected the provider's error body to be returned")
+	}
+
+	// Health stays green so a flaky provider is still considered reachable.
+	if err := c.Health(context.Background()); err != nil {
+		t.Fatalf("Health: %v", err)
+	}
+}
+
+func TestTestServer_PartialFailureRate(t *testing.T) {
+	srv := NewTestServer(WithTestFailureRate(0.5))
+	defer srv.Close()
+
+	c := NewWebhookClient(srv.URL, "key")
+	var ok, failed int
+	for range 200 {
+		if _, _, err := c.Send(context.Background(), "+905000000000", "hi"); err != nil {
+			failed++
+		} else {
+			ok++
+		}
+	}
+	if ok == 0 || failed == 0 {
+		t.Fatalf("expected a mix of outcomes at a 50%% failure rate, got ok=%d failed=%d", ok, failed)
+	}
+}
+
+func TestTestServer_LatencyHonoursClientTimeout(t *testing.T) {
+	srv := NewTestServer(WithTestLatency(300 * time.Millisecond))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, _, err := NewWebhookClient(srv.URL, "key").Send(ctx, "+905000000000", "hi")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected a deadline error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 250*time.Millisecond {
+		t.Fatalf("expected Send to give up at the deadline, took %v", elapsed)
+	}
+}
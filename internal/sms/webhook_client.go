@@ -6,31 +6,363 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"github.com/google/uuid"
+	"github.com/oggyb/insider-assessment/internal/domain/message"
 	"github.com/oggyb/insider-assessment/internal/request"
 	"github.com/oggyb/insider-assessment/internal/response"
 	"io"
+	"net"
 	"net/http"
+	"net/url"
+	"strings"
+	"sync"
 	"time"
 )
 
 var _ Client = (*WebhookClient)(nil)
 
+// DefaultAuthHeader is the header that carries the provider key unless
+// WithAuthHeader says otherwise.
+const DefaultAuthHeader = "x-ins-auth-key"
+
+// DefaultMaxResponseBytes caps how much of a provider response is read
+// unless WithMaxResponseBytes says otherwise.
+const DefaultMaxResponseBytes int64 = 1 << 20
+
+// ErrResponseTooLarge is returned when a provider response exceeds the
+// configured maximum size. The send is treated as failed.
+var ErrResponseTooLarge = errors.New("webhook response exceeds maximum size")
+
+// ErrRedirectNotAllowed is returned when a provider answers with a redirect
+// under RedirectNone. The send is treated as failed.
+var ErrRedirectNotAllowed = errors.New("webhook redirects are not allowed")
+
+// maxRedirects is how many redirects a request may follow, as in
+// net/http's default policy.
+const maxRedirects = 10
+
+// RedirectPolicy decides how a WebhookClient handles 3xx responses.
+type RedirectPolicy string
+
+const (
+	// RedirectNone fails the request on any redirect.
+	RedirectNone RedirectPolicy = "none"
+	// RedirectSameHost follows redirects, but sends the auth header only
+	// to the scheme and host of the original request.
+	RedirectSameHost RedirectPolicy = "same-host"
+)
+
+// RedirectPolicyFor resolves a configured redirect policy name; empty means
+// RedirectSameHost.
+func RedirectPolicyFor(name string) (RedirectPolicy, error) {
+	switch p := RedirectPolicy(strings.ToLower(strings.TrimSpace(name))); p {
+	case "", RedirectSameHost:
+		return RedirectSameHost, nil
+	case RedirectNone:
+		return p, nil
+	default:
+		return "", fmt.Errorf("unknown sms redirect policy %q (want none or same-host)", name)
+	}
+}
+
+// SuccessChecker decides whether a provider response counts as a successful send.
+// It receives the HTTP status code and the raw response body and returns the
+// external message ID (may be empty if the provider does not assign one), whether
+// the send succeeded, and an error describing why the response could not be
+// interpreted. A false ok with a nil error is treated as a provider rejection.
+// If it accepts a body that is not a JSON object without returning an ID,
+// Send synthesizes a local one so the message can still be tracked.
+type SuccessChecker func(statusCode int, body []byte) (externalID string, ok bool, err error)
+
+// PayloadFormat is how a WebhookClient encodes its request body.
+type PayloadFormat string
+
+const (
+	// PayloadJSON posts {"to", "content", "encoding"} as application/json.
+	PayloadJSON PayloadFormat = "json"
+	// PayloadForm posts to, content and encoding as
+	// application/x-www-form-urlencoded, for legacy providers.
+	PayloadForm PayloadFormat = "form"
+)
+
+// PayloadFormatFor resolves a configured payload format name; empty means
+// PayloadJSON.
+func PayloadFormatFor(name string) (PayloadFormat, error) {
+	switch f := PayloadFormat(strings.ToLower(strings.TrimSpace(name))); f {
+	case "", PayloadJSON:
+		return PayloadJSON, nil
+	case PayloadForm:
+		return f, nil
+	default:
+		return "", fmt.Errorf("unknown sms payload format %q (want json or form)", name)
+	}
+}
+
+// WebhookOption customizes a WebhookClient at construction time.
+type WebhookOption func(*WebhookClient)
+
+// WithSuccessChecker overrides the default success rule (2xx + non-empty messageId).
+func WithSuccessChecker(fn SuccessChecker) WebhookOption {
+	return func(c *WebhookClient) {
+		if fn != nil {
+			c.successChecker = fn
+		}
+	}
+}
+
+// WithPayloadTemplate replaces the default {to, content} request body with
+// the output of a template built by ParsePayloadTemplate.
+func WithPayloadTemplate(t *PayloadTemplate) WebhookOption {
+	return func(c *WebhookClient) {
+		if t != nil {
+			c.payloadTemplate = t
+		}
+	}
+}
+
+// WithPayloadFormat selects how the request body is encoded. With
+// PayloadForm the default success rule also accepts a form-encoded response
+// (see FormSuccessChecker). A payload template is sent as rendered, with the
+// format's content type. An empty format keeps PayloadJSON.
+func WithPayloadFormat(f PayloadFormat) WebhookOption {
+	return func(c *WebhookClient) {
+		if f != "" {
+			c.payloadFormat = f
+		}
+	}
+}
+
+// WithAuthHeader sends the auth key in the given header instead of
+// DefaultAuthHeader. A non-empty scheme is prepended to the key with a space,
+// e.g. WithAuthHeader("Authorization", "Bearer"). An empty header keeps the
+// default.
+func WithAuthHeader(header, scheme string) WebhookOption {
+	return func(c *WebhookClient) {
+		if header != "" {
+			c.authHeader = header
+		}
+		c.authScheme = scheme
+	}
+}
+
+// WithMaxResponseBytes caps how many bytes of a provider response are read,
+// so a broken provider streaming an endless body cannot exhaust memory.
+// Larger responses fail with ErrResponseTooLarge. A non-positive n keeps
+// DefaultMaxResponseBytes.
+func WithMaxResponseBytes(n int64) WebhookOption {
+	return func(c *WebhookClient) {
+		if n > 0 {
+			c.maxResponseBytes = n
+		}
+	}
+}
+
+// WithConnectTimeout bounds how long establishing the TCP connection to the
+// provider may take, separately from the overall request timeout, so an
+// unreachable provider fails fast while a slow response is still awaited.
+// A non-positive d keeps the transport's default.
+func WithConnectTimeout(d time.Duration) WebhookOption {
+	return func(c *WebhookClient) {
+		if d > 0 {
+			c.connectTimeout = d
+		}
+	}
+}
+
+// WithRedirectPolicy sets how provider redirects are handled. Without it
+// the client uses RedirectSameHost: net/http forwards custom headers such
+// as DefaultAuthHeader to any host, which would hand the key to whoever
+// the provider redirects to.
+func WithRedirectPolicy(p RedirectPolicy) WebhookOption {
+	return func(c *WebhookClient) {
+		c.redirectPolicy = p
+	}
+}
+
 // WebhookClient is an SMS client that sends messages to a webhook-style HTTP endpoint.
 type WebhookClient struct {
-	endpoint   string
-	authKey    string
-	httpClient *http.Client
+	// mu guards endpoint and authKey, which UpdateConfig may rotate while
+	// sends are in flight.
+	mu       sync.RWMutex
+	endpoint string
+	authKey  string
+
+	authHeader      string
+	authScheme      string
+	httpClient      *http.Client
+	successChecker  SuccessChecker
+	payloadTemplate *PayloadTemplate
+	payloadFormat   PayloadFormat
+	redirectPolicy  RedirectPolicy
+
+	// maxResponseBytes caps how much of a response body is read.
+	maxResponseBytes int64
+
+	// connectTimeout bounds each dial; dial makes the connection and
+	// defaults to a net.Dialer.
+	connectTimeout time.Duration
+	dial           func(ctx context.Context, network, addr string) (net.Conn, error)
 }
 
 // NewWebhookClient creates a new WebhookClient with the given endpoint and auth key.
-func NewWebhookClient(endpoint, authKey string) *WebhookClient {
-	return &WebhookClient{
-		endpoint: endpoint,
-		authKey:  authKey,
+func NewWebhookClient(endpoint, authKey string, opts ...WebhookOption) *WebhookClient {
+	c := &WebhookClient{
+		endpoint:   endpoint,
+		authKey:    authKey,
+		authHeader: DefaultAuthHeader,
 		httpClient: &http.Client{
 			Timeout: 10 * time.Second, // ekstra güvenlik, yine de ctx ile de sınırlarız
 		},
+		maxResponseBytes: DefaultMaxResponseBytes,
+		payloadFormat:    PayloadJSON,
+		redirectPolicy:   RedirectSameHost,
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	if c.successChecker == nil {
+		c.successChecker = DefaultSuccessChecker
+		if c.payloadFormat == PayloadForm {
+			c.successChecker = FormSuccessChecker
+		}
+	}
+
+	if c.connectTimeout > 0 {
+		c.httpClient.Transport = c.connectTimeoutTransport()
 	}
+	c.httpClient.CheckRedirect = c.checkRedirect
+
+	return c
+}
+
+// checkRedirect enforces redirectPolicy on the next request of a redirect
+// chain; via holds the requests made so far, oldest first.
+func (c *WebhookClient) checkRedirect(req *http.Request, via []*http.Request) error {
+	if c.redirectPolicy == RedirectNone {
+		return fmt.Errorf("%w: redirected to %s", ErrRedirectNotAllowed, req.URL.Redacted())
+	}
+	if len(via) >= maxRedirects {
+		return fmt.Errorf("stopped after %d redirects", maxRedirects)
+	}
+
+	orig := via[0].URL
+	if req.URL.Scheme != orig.Scheme || req.URL.Host != orig.Host {
+		req.Header.Del(c.authHeader)
+	}
+	return nil
+}
+
+// connectTimeoutTransport clones the default transport with every dial
+// limited to connectTimeout.
+func (c *WebhookClient) connectTimeoutTransport() *http.Transport {
+	if c.dial == nil {
+		c.dial = (&net.Dialer{Timeout: c.connectTimeout, KeepAlive: 30 * time.Second}).DialContext
+	}
+
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		ctx, cancel := context.WithTimeout(ctx, c.connectTimeout)
+		defer cancel()
+		return c.dial(ctx, network, addr)
+	}
+	return t
+}
+
+// UpdateConfig points the client at a new endpoint and auth key, e.g. after
+// the provider moved or the key was rotated. Sends and health checks started
+// afterwards use the new values; requests already in flight finish with the
+// ones they started with. An empty endpoint keeps the current one; an empty
+// key sends requests without auth.
+func (c *WebhookClient) UpdateConfig(endpoint, authKey string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if endpoint != "" {
+		c.endpoint = endpoint
+	}
+	c.authKey = authKey
+}
+
+// target returns the endpoint and auth key a new request should use.
+func (c *WebhookClient) target() (endpoint, authKey string) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.endpoint, c.authKey
+}
+
+// plainTextAcceptances are the non-JSON 2xx bodies (case-insensitive) that
+// DefaultSuccessChecker treats as an accepted message.
+var plainTextAcceptances = map[string]bool{
+	"accepted": true,
+	"ok":       true,
+	"queued":   true,
+	"sent":     true,
+	"success":  true,
+}
+
+// DefaultSuccessChecker implements the standard provider contract: a 2xx status
+// and a JSON body carrying a non-empty messageId. A 2xx plain-text body such as
+// "accepted" also counts as success, without an external ID.
+func DefaultSuccessChecker(statusCode int, body []byte) (string, bool, error) {
+	if statusCode < 200 || statusCode >= 300 {
+		return "", false, fmt.Errorf("webhook returned non-2xx status: %d", statusCode)
+	}
+
+	var parsed response.WebhookResponse
+	if err := json.Unmarshal(body, &parsed); err != nil {
+		if isPlainTextAcceptance(body) {
+			return "", true, nil
+		}
+		return "", false, fmt.Errorf("failed to parse webhook response: %w", err)
+	}
+
+	if parsed.MessageID == "" {
+		return "", false, fmt.Errorf("webhook response missing messageId")
+	}
+
+	return parsed.MessageID, true, nil
+}
+
+// FormSuccessChecker is the success rule for form-encoded providers: a 2xx
+// status and a messageId, either in a JSON object as DefaultSuccessChecker
+// expects or as a form-encoded body such as "status=ok&messageId=abc". A
+// plain-text acceptance counts as success too.
+func FormSuccessChecker(statusCode int, body []byte) (string, bool, error) {
+	if statusCode < 200 || statusCode >= 300 || isJSONObject(body) {
+		return DefaultSuccessChecker(statusCode, body)
+	}
+	if isPlainTextAcceptance(body) {
+		return "", true, nil
+	}
+
+	values, err := url.ParseQuery(strings.TrimSpace(string(body)))
+	if err != nil {
+		return "", false, fmt.Errorf("failed to parse webhook response: %w", err)
+	}
+	if id := values.Get("messageId"); id != "" {
+		return id, true, nil
+	}
+	return "", false, fmt.Errorf("webhook response missing messageId")
+}
+
+// isPlainTextAcceptance reports whether body is one of plainTextAcceptances,
+// ignoring surrounding whitespace, quotes and case.
+func isPlainTextAcceptance(body []byte) bool {
+	text := strings.Trim(strings.TrimSpace(string(body)), `"`)
+	return plainTextAcceptances[strings.ToLower(text)]
+}
+
+// isJSONObject reports whether body is a well-formed JSON object.
+func isJSONObject(body []byte) bool {
+	trimmed := bytes.TrimSpace(body)
+	return len(trimmed) > 0 && trimmed[0] == '{' && json.Valid(trimmed)
+}
+
+// synthesizeExternalID returns a locally generated ID for a message the
+// provider accepted without assigning one in a machine-readable body.
+func synthesizeExternalID() string {
+	return "local-" + uuid.NewString()
 }
 
 // withTimeout wraps the context with a timeout if it doesn't already have one.
@@ -48,28 +380,26 @@ func (c *WebhookClient) Send(ctx context.Context, to, content string) (string, s
 	ctx, cancel := withTimeout(ctx, 5*time.Second)
 	defer cancel()
 
-	payload := request.WebhookRequest{
-		To:      to,
-		Content: content,
-	}
-
-	body, err := json.Marshal(payload)
+	body, contentType, err := c.buildPayload(to, content, EncodingFor(ctx, content))
 	if err != nil {
-		return "", "", fmt.Errorf("failed to marshal webhook payload: %w", err)
+		return "", "", fmt.Errorf("failed to build webhook payload: %w", err)
 	}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint, bytes.NewReader(body))
+	endpoint, authKey := c.target()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
 	if err != nil {
 		return "", "", fmt.Errorf("failed to create request: %w", err)
 	}
 
-	req.Header.Set("Content-Type", "application/json")
-	if c.authKey != "" {
-		req.Header.Set("x-ins-auth-key", c.authKey)
-	}
+	req.Header.Set("Content-Type", contentType)
+	c.setAuth(req, authKey)
 
+	// The round trip runs from sending the request until the response body
+	// is read, so it covers the provider's processing time.
+	start := time.Now()
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
+		recordLatency(ctx, time.Since(start))
 		// context timeout / cancel ise bunu özellikle belirtelim
 		if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
 			return "", "", fmt.Errorf("webhook request timeout or canceled: %w", err)
@@ -78,26 +408,84 @@ func (c *WebhookClient) Send(ctx context.Context, to, content string) (string, s
 	}
 	defer resp.Body.Close()
 
-	rawBytes, err := io.ReadAll(resp.Body)
+	rawBytes, err := c.readBody(resp.Body)
+	recordLatency(ctx, time.Since(start))
 	if err != nil {
-		return "", "", fmt.Errorf("failed to read webhook response: %w", err)
+		return "", "", err
 	}
 	raw := string(rawBytes)
 
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return "", raw, fmt.Errorf("webhook returned non-2xx status: %d", resp.StatusCode)
+	externalID, ok, err := c.successChecker(resp.StatusCode, rawBytes)
+	if err != nil {
+		return "", raw, err
+	}
+	if !ok {
+		return "", raw, fmt.Errorf("webhook rejected message (status %d)", resp.StatusCode)
 	}
 
-	var parsed response.WebhookResponse
-	if err := json.Unmarshal(rawBytes, &parsed); err != nil {
-		return "", raw, fmt.Errorf("failed to parse webhook response: %w", err)
+	// A plain-text acceptance carries no ID to track the message by.
+	if externalID == "" && !isJSONObject(rawBytes) {
+		externalID = synthesizeExternalID()
 	}
 
-	if parsed.MessageID == "" {
-		return "", raw, fmt.Errorf("webhook response missing messageId")
+	return externalID, raw, nil
+}
+
+// readBody reads at most maxResponseBytes of body. Reading one byte more
+// than allowed tells a body that is exactly at the limit apart from a
+// truncated one.
+func (c *WebhookClient) readBody(body io.Reader) ([]byte, error) {
+	raw, err := io.ReadAll(io.LimitReader(body, c.maxResponseBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read webhook response: %w", err)
+	}
+	if int64(len(raw)) > c.maxResponseBytes {
+		return nil, fmt.Errorf("%w (limit %d bytes)", ErrResponseTooLarge, c.maxResponseBytes)
+	}
+	return raw, nil
+}
+
+// buildPayload renders the request body and its content type, using the
+// payload template if one is configured and the default to, content and
+// encoding fields in the configured format otherwise.
+func (c *WebhookClient) buildPayload(to, content string, encoding message.Encoding) ([]byte, string, error) {
+	data := request.WebhookRequest{
+		To:       to,
+		Content:  content,
+		Encoding: string(encoding),
+	}
+
+	contentType := "application/json"
+	if c.payloadFormat == PayloadForm {
+		contentType = "application/x-www-form-urlencoded"
 	}
 
-	return parsed.MessageID, raw, nil
+	if c.payloadTemplate != nil {
+		body, err := c.payloadTemplate.Render(data)
+		return body, contentType, err
+	}
+	if c.payloadFormat == PayloadForm {
+		form := url.Values{}
+		form.Set("to", data.To)
+		form.Set("content", data.Content)
+		form.Set("encoding", data.Encoding)
+		return []byte(form.Encode()), contentType, nil
+	}
+	body, err := json.Marshal(data)
+	return body, contentType, err
+}
+
+// setAuth adds the configured auth header carrying key to req, if a key is
+// set.
+func (c *WebhookClient) setAuth(req *http.Request, key string) {
+	if key == "" {
+		return
+	}
+	value := key
+	if c.authScheme != "" {
+		value = c.authScheme + " " + key
+	}
+	req.Header.Set(c.authHeader, value)
 }
 
 // Health implements Client.Health with a simple GET request to the webhook endpoint.
@@ -106,14 +494,13 @@ func (c *WebhookClient) Health(ctx context.Context) error {
 	ctx, cancel := withTimeout(ctx, 2*time.Second)
 	defer cancel()
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.endpoint, nil)
+	endpoint, authKey := c.target()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
 	if err != nil {
 		return fmt.Errorf("health: failed to create request: %w", err)
 	}
 
-	if c.authKey != "" {
-		req.Header.Set("x-ins-auth-key", c.authKey)
-	}
+	c.setAuth(req, authKey)
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
@@ -0,0 +1,631 @@
+package sms
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"maps"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/oggyb/insider-assessment/internal/domain/message"
+	"github.com/oggyb/insider-assessment/internal/request"
+)
+
+// newProvider starts a fake webhook provider that always answers with the
+// given status code and body.
+func newProvider(t *testing.T, status int, body string) *httptest.Server {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(body))
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+// queuedChecker accepts {"status":"queued"} without an ID and rejects
+// {"status":"rejected"}.
+func queuedChecker(statusCode int, body []byte) (string, bool, error) {
+	var parsed struct {
+		Status    string `json:"status"`
+		MessageID string `json:"messageId"`
+	}
+	if err := json.Unmarshal(body, &parsed); err != nil {
+		return "", false, err
+	}
+	switch parsed.Status {
+	case "queued", "sent":
+		return parsed.MessageID, true, nil
+	default:
+		return "", false, nil
+	}
+}
+
+func TestWebhookClient_DefaultCheckerRequiresMessageID(t *testing.T) {
+	srv := newProvider(t, http.StatusOK, `{"status":"queued"}`)
+	c := NewWebhookClient(srv.URL, "")
+
+	if _, _, err := c.Send(context.Background(), "+905000000000", "hi"); err == nil {
+		t.Fatalf("expected default checker to reject a response without messageId")
+	}
+}
+
+func TestWebhookClient_DefaultCheckerAcceptsMessageID(t *testing.T) {
+	srv := newProvider(t, http.StatusAccepted, `{"message":"Accepted","messageId":"abc-123"}`)
+	c := NewWebhookClient(srv.URL, "")
+
+	id, _, err := c.Send(context.Background(), "+905000000000", "hi")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if id != "abc-123" {
+		t.Fatalf("expected external ID abc-123, got %q", id)
+	}
+}
+
+func TestWebhookClient_CustomCheckerQueuedWithoutID(t *testing.T) {
+	srv := newProvider(t, http.StatusOK, `{"status":"queued"}`)
+	c := NewWebhookClient(srv.URL, "", WithSuccessChecker(queuedChecker))
+
+	id, raw, err := c.Send(context.Background(), "+905000000000", "hi")
+	if err != nil {
+		t.Fatalf("expected queued-without-id to count as success, got %v", err)
+	}
+	if id != "" {
+		t.Fatalf("expected empty external ID, got %q", id)
+	}
+	if raw != `{"status":"queued"}` {
+		t.Fatalf("unexpected raw response %q", raw)
+	}
+}
+
+func TestWebhookClient_CustomCheckerRejected(t *testing.T) {
+	srv := newProvider(t, http.StatusOK, `{"status":"rejected"}`)
+	c := NewWebhookClient(srv.URL, "", WithSuccessChecker(queuedChecker))
+
+	_, raw, err := c.Send(context.Background(), "+905000000000", "hi")
+	if err == nil {
+		t.Fatalf("expected rejected status to be reported as an error")
+	}
+	if raw != `{"status":"rejected"}` {
+		t.Fatalf("expected raw response to be preserved, got %q", raw)
+	}
+}
+
+func TestWebhookClient_PlainTextAcceptanceCountsAsSent(t *testing.T) {
+	for _, body := range []string{"accepted", "OK\n", `"queued"`} {
+		srv := newProvider(t, http.StatusOK, body)
+		c := NewWebhookClient(srv.URL, "")
+
+		id, raw, err := c.Send(context.Background(), "+905000000000", "hi")
+		if err != nil {
+			t.Fatalf("body %q: expected plain-text acceptance to count as sent, got %v", body, err)
+		}
+		if !strings.HasPrefix(id, "local-") {
+			t.Fatalf("body %q: expected a synthesized external ID, got %q", body, id)
+		}
+		if raw != body {
+			t.Fatalf("body %q: expected raw response to be preserved, got %q", body, raw)
+		}
+	}
+}
+
+func TestWebhookClient_PlainTextRejectionStillFails(t *testing.T) {
+	for _, tc := range []struct {
+		status int
+		body   string
+	}{
+		{http.StatusOK, "error: invalid number"},
+		{http.StatusBadGateway, "accepted"},
+	} {
+		srv := newProvider(t, tc.status, tc.body)
+		c := NewWebhookClient(srv.URL, "")
+
+		if _, _, err := c.Send(context.Background(), "+905000000000", "hi"); err == nil {
+			t.Fatalf("status %d body %q: expected an error", tc.status, tc.body)
+		}
+	}
+}
+
+func TestParsePayloadTemplate_RejectsInvalidTemplates(t *testing.T) {
+	cases := map[string]string{
+		"syntax error":  `{"to": {{.To}`,
+		"unknown field": `{"to": {{json .Phone}}}`,
+		"not json":      `to={{.To}}`,
+		"unquoted text": `{"text": "{{.Content}}"}`,
+	}
+	for name, text := range cases {
+		if _, err := ParsePayloadTemplate(text); err == nil {
+			t.Fatalf("%s: expected template to be rejected", name)
+		}
+	}
+}
+
+func TestWebhookClient_PostsCustomPayloadTemplate(t *testing.T) {
+	var got map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("provider received invalid JSON: %v", err)
+		}
+		w.WriteHeader(http.StatusAccepted)
+		_, _ = w.Write([]byte(`{"messageId":"abc-123"}`))
+	}))
+	t.Cleanup(srv.Close)
+
+	tmpl, err := ParsePayloadTemplate(`{"sms":{"recipient":{{json .To}},"text":{{json .Content}}},"channel":"otp"}`)
+	if err != nil {
+		t.Fatalf("ParsePayloadTemplate: %v", err)
+	}
+
+	c := NewWebhookClient(srv.URL, "", WithPayloadTemplate(tmpl))
+	if _, _, err := c.Send(context.Background(), "+905000000000", `say "hi"`); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	sms, ok := got["sms"].(map[string]any)
+	if !ok {
+		t.Fatalf("expected nested sms object, got %v", got)
+	}
+	if sms["recipient"] != "+905000000000" || sms["text"] != `say "hi"` || got["channel"] != "otp" {
+		t.Fatalf("unexpected payload %v", got)
+	}
+}
+
+func TestWebhookClient_DefaultPayloadShape(t *testing.T) {
+	var got map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&got)
+		_, _ = w.Write([]byte(`{"messageId":"abc-123"}`))
+	}))
+	t.Cleanup(srv.Close)
+
+	c := NewWebhookClient(srv.URL, "")
+	if _, _, err := c.Send(context.Background(), "+905000000000", "hi"); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if len(got) != 3 || got["to"] != "+905000000000" || got["content"] != "hi" || got["encoding"] != "GSM7" {
+		t.Fatalf("expected {to, content, encoding}, got %v", got)
+	}
+}
+
+func TestWebhookClient_FormPayload(t *testing.T) {
+	var contentType string
+	var got url.Values
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contentType = r.Header.Get("Content-Type")
+		if err := r.ParseForm(); err != nil {
+			t.Errorf("ParseForm: %v", err)
+		}
+		got = r.PostForm
+		_, _ = w.Write([]byte("status=queued&messageId=form-123"))
+	}))
+	t.Cleanup(srv.Close)
+
+	c := NewWebhookClient(srv.URL, "", WithPayloadFormat(PayloadForm))
+	id, _, err := c.Send(context.Background(), "+905000000000", "Şifre: 1&2")
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if id != "form-123" {
+		t.Fatalf("expected the form response's messageId, got %q", id)
+	}
+	if contentType != "application/x-www-form-urlencoded" {
+		t.Fatalf("expected a form content type, got %q", contentType)
+	}
+	if len(got) != 3 || got.Get("to") != "+905000000000" || got.Get("content") != "Şifre: 1&2" || got.Get("encoding") != "UCS2" {
+		t.Fatalf("expected to, content and encoding form values, got %v", got)
+	}
+}
+
+func TestFormSuccessChecker(t *testing.T) {
+	cases := []struct {
+		name   string
+		status int
+		body   string
+		wantID string
+		wantOK bool
+	}{
+		{"form id", 200, "messageId=abc&status=ok", "abc", true},
+		{"json id", 202, `{"messageId":"def"}`, "def", true},
+		{"plain text", 200, "accepted", "", true},
+		{"form without id", 200, "status=ok", "", false},
+		{"json without id", 200, `{"status":"ok"}`, "", false},
+		{"bad encoding", 200, "messageId=%zz", "", false},
+		{"non-2xx", 500, "messageId=abc", "", false},
+	}
+	for _, tc := range cases {
+		id, ok, _ := FormSuccessChecker(tc.status, []byte(tc.body))
+		if id != tc.wantID || ok != tc.wantOK {
+			t.Errorf("%s: got (%q, %v), want (%q, %v)", tc.name, id, ok, tc.wantID, tc.wantOK)
+		}
+	}
+
+	if _, err := PayloadFormatFor("xml"); err == nil {
+		t.Fatal("expected an unknown payload format to be rejected")
+	}
+	if f, err := PayloadFormatFor(" Form "); err != nil || f != PayloadForm {
+		t.Fatalf("PayloadFormatFor(form) = %q, %v", f, err)
+	}
+	if f, err := PayloadFormatFor(""); err != nil || f != PayloadJSON {
+		t.Fatalf("PayloadFormatFor(\"\") = %q, %v", f, err)
+	}
+}
+
+func TestWebhookClient_EncodingHint(t *testing.T) {
+	var got request.WebhookRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&got)
+		_, _ = w.Write([]byte(`{"messageId":"abc-123"}`))
+	}))
+	t.Cleanup(srv.Close)
+
+	c := NewWebhookClient(srv.URL, "")
+	cases := []struct {
+		name    string
+		ctx     context.Context
+		content string
+		want    string
+	}{
+		{"ascii", context.Background(), "Your code is 1234", "GSM7"},
+		{"turkish", context.Background(), "Şifreniz: 1234, iyi günler", "UCS2"},
+		{"emoji", context.Background(), "Thanks! 🎉", "UCS2"},
+		{"override", ContextWithEncoding(context.Background(), message.EncodingUCS2), "plain text", "UCS2"},
+	}
+	for _, tc := range cases {
+		if _, _, err := c.Send(tc.ctx, "+905000000000", tc.content); err != nil {
+			t.Fatalf("%s: Send: %v", tc.name, err)
+		}
+		if got.Encoding != tc.want {
+			t.Fatalf("%s: encoding = %q, want %q", tc.name, got.Encoding, tc.want)
+		}
+	}
+}
+
+func TestWebhookClient_PayloadTemplateSeesEncoding(t *testing.T) {
+	var got map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&got)
+		_, _ = w.Write([]byte(`{"messageId":"abc-123"}`))
+	}))
+	t.Cleanup(srv.Close)
+
+	tmpl, err := ParsePayloadTemplate(`{"to":{{json .To}},"text":{{json .Content}},"dataCoding":{{json .Encoding}}}`)
+	if err != nil {
+		t.Fatalf("ParsePayloadTemplate: %v", err)
+	}
+	c := NewWebhookClient(srv.URL, "", WithPayloadTemplate(tmpl))
+	if _, _, err := c.Send(context.Background(), "+905000000000", "ığüşöç"); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if got["dataCoding"] != "UCS2" {
+		t.Fatalf("expected the template to render the encoding, got %v", got)
+	}
+}
+
+// headerRecorder starts a provider that accepts every request and records
+// the given header of each one, keyed by HTTP method.
+func headerRecorder(t *testing.T, header string) (*httptest.Server, func() map[string]string) {
+	t.Helper()
+
+	var mu sync.Mutex
+	seen := map[string]string{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		seen[r.Method] = r.Header.Get(header)
+		mu.Unlock()
+		_, _ = w.Write([]byte(`{"messageId":"abc-123"}`))
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv, func() map[string]string {
+		mu.Lock()
+		defer mu.Unlock()
+		return maps.Clone(seen)
+	}
+}
+
+func TestWebhookClient_DefaultAuthHeader(t *testing.T) {
+	srv, seen := headerRecorder(t, DefaultAuthHeader)
+	c := NewWebhookClient(srv.URL, "secret")
+
+	if _, _, err := c.Send(context.Background(), "+905000000000", "hi"); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if err := c.Health(context.Background()); err != nil {
+		t.Fatalf("Health: %v", err)
+	}
+
+	got := seen()
+	if got[http.MethodPost] != "secret" || got[http.MethodGet] != "secret" {
+		t.Fatalf("expected the key in %s on send and health, got %v", DefaultAuthHeader, got)
+	}
+}
+
+func TestWebhookClient_ConfiguredAuthHeaderAndScheme(t *testing.T) {
+	srv, seen := headerRecorder(t, "Authorization")
+	c := NewWebhookClient(srv.URL, "secret", WithAuthHeader("Authorization", "Bearer"))
+
+	if _, _, err := c.Send(context.Background(), "+905000000000", "hi"); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if err := c.Health(context.Background()); err != nil {
+		t.Fatalf("Health: %v", err)
+	}
+
+	got := seen()
+	if got[http.MethodPost] != "Bearer secret" || got[http.MethodGet] != "Bearer secret" {
+		t.Fatalf("expected Bearer auth on send and health, got %v", got)
+	}
+
+	// The default header must not be sent alongside the configured one.
+	srv, seen = headerRecorder(t, DefaultAuthHeader)
+	c = NewWebhookClient(srv.URL, "secret", WithAuthHeader("X-API-Key", ""))
+	if _, _, err := c.Send(context.Background(), "+905000000000", "hi"); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if got := seen()[http.MethodPost]; got != "" {
+		t.Fatalf("expected no %s header, got %q", DefaultAuthHeader, got)
+	}
+}
+
+func TestWebhookClient_OversizedResponseIsCapped(t *testing.T) {
+	// A broken provider that streams until the client hangs up.
+	var written atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		chunk := []byte(strings.Repeat("x", 4096))
+		for {
+			n, err := w.Write(chunk)
+			written.Add(int64(n))
+			if err != nil || written.Load() > 64<<20 {
+				return
+			}
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	c := NewWebhookClient(srv.URL, "key", WithMaxResponseBytes(1024))
+	_, raw, err := c.Send(context.Background(), "+905000000000", "hello")
+	if !errors.Is(err, ErrResponseTooLarge) {
+		t.Fatalf("expected ErrResponseTooLarge, got %v", err)
+	}
+	if raw != "" {
+		t.Fatalf("expected no raw response for an oversized body, got %d bytes", len(raw))
+	}
+}
+
+func TestWebhookClient_ResponseAtLimitIsRead(t *testing.T) {
+	body := `{"messageId":"abc"}`
+	srv := newProvider(t, http.StatusAccepted, body)
+
+	c := NewWebhookClient(srv.URL, "key", WithMaxResponseBytes(int64(len(body))))
+	id, _, err := c.Send(context.Background(), "+905000000000", "hello")
+	if err != nil || id != "abc" {
+		t.Fatalf("expected a body exactly at the limit to be accepted, got %q, %v", id, err)
+	}
+}
+
+func TestWebhookClient_UpdateConfigAppliesToNextSend(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	var oldKey atomic.Value
+	oldSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		oldKey.Store(r.Header.Get(DefaultAuthHeader))
+		close(started)
+		<-release
+		_, _ = w.Write([]byte(`{"messageId":"old-1"}`))
+	}))
+	t.Cleanup(oldSrv.Close)
+	newSrv, seen := headerRecorder(t, DefaultAuthHeader)
+
+	c := NewWebhookClient(oldSrv.URL, "old-key")
+
+	type result struct {
+		id  string
+		err error
+	}
+	inFlight := make(chan result, 1)
+	go func() {
+		id, _, err := c.Send(context.Background(), "+905000000000", "hi")
+		inFlight <- result{id, err}
+	}()
+	<-started
+
+	c.UpdateConfig(newSrv.URL, "new-key")
+
+	id, _, err := c.Send(context.Background(), "+905000000000", "hi")
+	if err != nil || id != "abc-123" {
+		t.Fatalf("expected the next send to reach the new endpoint, got id=%q err=%v", id, err)
+	}
+	if got := seen()[http.MethodPost]; got != "new-key" {
+		t.Fatalf("expected the rotated key on the next send, got %q", got)
+	}
+	if err := c.Health(context.Background()); err != nil || seen()[http.MethodGet] != "new-key" {
+		t.Fatalf("expected health checks to use the new config, got err=%v key=%q", err, seen()[http.MethodGet])
+	}
+
+	// The request that was already running finishes against the old config.
+	close(release)
+	res := <-inFlight
+	if res.err != nil || res.id != "old-1" {
+		t.Fatalf("expected the in-flight send to complete on the old endpoint, got id=%q err=%v", res.id, res.err)
+	}
+	if got := oldKey.Load(); got != "old-key" {
+		t.Fatalf("expected the in-flight send to carry the old key, got %v", got)
+	}
+}
+
+func TestWebhookClient_UpdateConfigKeepsEndpointWhenEmpty(t *testing.T) {
+	srv, seen := headerRecorder(t, DefaultAuthHeader)
+	c := NewWebhookClient(srv.URL, "old-key")
+
+	c.UpdateConfig("", "new-key")
+	if _, _, err := c.Send(context.Background(), "+905000000000", "hi"); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if got := seen()[http.MethodPost]; got != "new-key" {
+		t.Fatalf("expected only the key to change, got %q", got)
+	}
+}
+
+// withDial replaces the client's dialer, e.g. with one that never connects.
+func withDial(dial func(ctx context.Context, network, addr string) (net.Conn, error)) WebhookOption {
+	return func(c *WebhookClient) { c.dial = dial }
+}
+
+func TestWebhookClient_ConnectTimeoutFiresBeforeRequestTimeout(t *testing.T) {
+	var dialDeadline atomic.Int64
+	hang := withDial(func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if d, ok := ctx.Deadline(); ok {
+			dialDeadline.Store(int64(time.Until(d)))
+		}
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	c := NewWebhookClient("http://provider.invalid", "key", WithConnectTimeout(50*time.Millisecond), hang)
+
+	// The request itself may take much longer than the connect timeout.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	start := time.Now()
+	_, _, err := c.Send(ctx, "+905000000000", "hi")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the dial to time out, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("expected Send to fail at the connect timeout, took %v", elapsed)
+	}
+	if ctx.Err() != nil {
+		t.Fatal("expected the request context to still be live")
+	}
+	if d := time.Duration(dialDeadline.Load()); d <= 0 || d > 50*time.Millisecond {
+		t.Fatalf("expected the dial to be bounded by the connect timeout, got %v", d)
+	}
+}
+
+func TestWebhookClient_ConnectTimeoutDoesNotLimitSlowResponse(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(150 * time.Millisecond)
+		w.WriteHeader(http.StatusAccepted)
+		_, _ = w.Write([]byte(`{"message":"Accepted","messageId":"abc"}`))
+	}))
+	t.Cleanup(srv.Close)
+
+	c := NewWebhookClient(srv.URL, "key", WithConnectTimeout(50*time.Millisecond))
+	id, _, err := c.Send(context.Background(), "+905000000000", "hi")
+	if err != nil || id != "abc" {
+		t.Fatalf("expected a slow response to be awaited, got id=%q err=%v", id, err)
+	}
+}
+
+func TestWebhookClient_RecordsProviderLatency(t *testing.T) {
+	srv := NewTestServer(WithTestLatency(50 * time.Millisecond))
+	defer srv.Close()
+
+	ctx, latency := ContextWithLatencyRecorder(context.Background())
+	if _, _, err := NewWebhookClient(srv.URL, "key").Send(ctx, "+905000000000", "hi"); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if d := latency.Duration(); d < 50*time.Millisecond || d > time.Second {
+		t.Fatalf("expected the ~50ms round trip to be recorded, got %v", d)
+	}
+
+	// A failed send still records how long the provider took to answer.
+	srv = NewTestServer(WithTestLatency(50*time.Millisecond), WithTestFailureRate(1))
+	defer srv.Close()
+
+	ctx, latency = ContextWithLatencyRecorder(context.Background())
+	if _, _, err := NewWebhookClient(srv.URL, "key").Send(ctx, "+905000000000", "hi"); err == nil {
+		t.Fatal("expected the send to fail")
+	}
+	if d := latency.Duration(); d < 50*time.Millisecond {
+		t.Fatalf("expected the failed round trip to be recorded, got %v", d)
+	}
+}
+
+// redirector starts a provider that answers every request with a 307 to
+// target, so the POST body is replayed.
+func redirector(t *testing.T, target string) *httptest.Server {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, target, http.StatusTemporaryRedirect)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestWebhookClient_CrossHostRedirectDropsAuthHeader(t *testing.T) {
+	other, seen := headerRecorder(t, DefaultAuthHeader)
+	origin := redirector(t, other.URL)
+	c := NewWebhookClient(origin.URL, "secret")
+
+	if _, _, err := c.Send(context.Background(), "+905551112233", "hi"); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	got, ok := seen()[http.MethodPost]
+	if !ok {
+		t.Fatal("expected the redirect to be followed")
+	}
+	if got != "" {
+		t.Fatalf("expected no auth header on the other host, got %q", got)
+	}
+}
+
+func TestWebhookClient_SameHostRedirectKeepsAuthHeader(t *testing.T) {
+	var got atomic.Value
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v2" {
+			http.Redirect(w, r, "/v2", http.StatusPermanentRedirect)
+			return
+		}
+		got.Store(r.Header.Get(DefaultAuthHeader))
+		_, _ = w.Write([]byte(`{"messageId":"abc-123"}`))
+	}))
+	t.Cleanup(srv.Close)
+
+	c := NewWebhookClient(srv.URL+"/v1", "secret")
+	if _, _, err := c.Send(context.Background(), "+905551112233", "hi"); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if got.Load() != "secret" {
+		t.Fatalf("expected the auth header on the same host, got %v", got.Load())
+	}
+}
+
+func TestWebhookClient_RedirectNoneFailsSend(t *testing.T) {
+	other, seen := headerRecorder(t, DefaultAuthHeader)
+	origin := redirector(t, other.URL)
+	c := NewWebhookClient(origin.URL, "secret", WithRedirectPolicy(RedirectNone))
+
+	_, _, err := c.Send(context.Background(), "+905551112233", "hi")
+	if !errors.Is(err, ErrRedirectNotAllowed) {
+		t.Fatalf("expected ErrRedirectNotAllowed, got %v", err)
+	}
+	if len(seen()) != 0 {
+		t.Fatalf("expected the redirect target not to be contacted, got %v", seen())
+	}
+}
+
+func TestRedirectPolicyFor(t *testing.T) {
+	if p, err := RedirectPolicyFor(""); err != nil || p != RedirectSameHost {
+		t.Fatalf("RedirectPolicyFor(\"\") = %q, %v", p, err)
+	}
+	if p, err := RedirectPolicyFor(" None "); err != nil || p != RedirectNone {
+		t.Fatalf("RedirectPolicyFor(none) = %q, %v", p, err)
+	}
+	if _, err := RedirectPolicyFor("follow"); err == nil {
+		t.Fatal("expected an unknown policy to be rejected")
+	}
+}
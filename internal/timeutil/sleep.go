@@ -0,0 +1,27 @@
+// Package timeutil holds small time helpers shared across packages.
+package timeutil
+
+import (
+	"context"
+	"time"
+)
+
+// SleepCtx waits for d or until ctx is done, reporting whether the full
+// delay elapsed. Use it instead of time.Sleep for any backoff, pacing or
+// quiet-hours delay so a shutdown never waits on a sleeping goroutine.
+// A non-positive d returns immediately, reporting whether ctx is still live.
+func SleepCtx(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
@@ -0,0 +1,45 @@
+package timeutil
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestSleepCtx_WaitsFullDuration(t *testing.T) {
+	start := time.Now()
+	if !SleepCtx(context.Background(), 30*time.Millisecond) {
+		t.Fatal("expected the full delay to elapse")
+	}
+	if elapsed := time.Since(start); elapsed < 30*time.Millisecond {
+		t.Fatalf("returned after %v, before the delay", elapsed)
+	}
+}
+
+func TestSleepCtx_ReturnsPromptlyOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+
+	start := time.Now()
+	if SleepCtx(ctx, time.Minute) {
+		t.Fatal("expected the sleep to be cut short")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("took %v to notice cancellation", elapsed)
+	}
+}
+
+func TestSleepCtx_AlreadyDoneOrNoDelay(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if SleepCtx(ctx, time.Minute) {
+		t.Fatal("expected a cancelled context to end the sleep")
+	}
+	if SleepCtx(ctx, 0) {
+		t.Fatal("expected a zero delay on a cancelled context to report false")
+	}
+	if !SleepCtx(context.Background(), 0) {
+		t.Fatal("expected a zero delay on a live context to report true")
+	}
+}